@@ -0,0 +1,125 @@
+// Command bench runs the hot path benchmarks with go test and exits with a non-zero status when one is over its
+// latency budget.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// benchPackages are the packages with the hot path benchmarks.
+var benchPackages = []string{"./internal/client", "./internal/server"}
+
+type budgetsFile struct {
+	Budgets map[string]string `toml:"budgets"`
+}
+
+type result struct {
+	// Name is the name of the package and the benchmark, e.g. client/BenchmarkBlibliDescriptionParser.
+	Name        string
+	NsPerOp     float64
+	AllocsPerOp int64
+	// Budget is zero when the benchmark has no budget.
+	Budget time.Duration
+}
+
+func (r result) overBudget() bool {
+	return r.Budget > 0 && time.Duration(r.NsPerOp) > r.Budget
+}
+
+func main() {
+	budgetsPath := flag.String("budgets", "bench_budgets.toml", "TOML file with the latency budget per benchmark")
+	run := flag.String("run", ".", "only run benchmarks matching this regular expression, as go test -bench")
+	flag.Parse()
+
+	budgets, err := readBudgets(*budgetsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading budgets:", err)
+		os.Exit(2)
+	}
+
+	args := append([]string{"test", "-run", "^$", "-bench", *run, "-benchmem"}, benchPackages...)
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		os.Stdout.Write(out)
+		fmt.Fprintln(os.Stderr, "Error running benchmarks:", err)
+		os.Exit(2)
+	}
+
+	var over int
+	for _, r := range parseResults(out, budgets) {
+		status := "ok  "
+		if r.overBudget() {
+			status = "FAIL"
+			over++
+		}
+		budget := "-"
+		if r.Budget > 0 {
+			budget = r.Budget.String()
+		}
+		fmt.Printf("%s %-50s %12v/op %8d allocs/op  budget: %s\n",
+			status, r.Name, time.Duration(r.NsPerOp), r.AllocsPerOp, budget)
+	}
+	if over > 0 {
+		fmt.Printf("%d benchmark(s) over budget\n", over)
+		os.Exit(1)
+	}
+}
+
+// benchLine matches the result lines of go test -bench -benchmem, e.g.
+// "BenchmarkX-8   1000   1234 ns/op   56 B/op   7 allocs/op".
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([\d.]+) ns/op(?:\s+\d+ B/op\s+(\d+) allocs/op)?`)
+
+// parseResults reads the results from the output of go test -bench and attaches their budgets.
+func parseResults(out []byte, budgets map[string]time.Duration) []result {
+	var results []result
+	var pkg string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = path.Base(strings.TrimPrefix(line, "pkg: "))
+			continue
+		}
+		m := benchLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		r := result{Name: pkg + "/" + m[1]}
+		r.NsPerOp, _ = strconv.ParseFloat(m[2], 64)
+		if m[3] != "" {
+			r.AllocsPerOp, _ = strconv.ParseInt(m[3], 10, 64)
+		}
+		r.Budget = budgets[r.Name]
+		results = append(results, r)
+	}
+	return results
+}
+
+func readBudgets(path string) (map[string]time.Duration, error) {
+	var bf budgetsFile
+	if _, err := toml.DecodeFile(path, &bf); err != nil {
+		return nil, err
+	}
+	budgets := make(map[string]time.Duration, len(bf.Budgets))
+	for name, d := range bf.Budgets {
+		budget, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget for %s: %v", name, err)
+		}
+		budgets[name] = budget
+	}
+	return budgets, nil
+}
@@ -0,0 +1,158 @@
+// Command genmock generates mocks of interfaces whose methods call func fields of the same name, it is run by
+// go generate in internal/mock with the interfaces given as import/path.Interface arguments.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", "", "output file")
+	pkgName := flag.String("pkg", "mock", "package name of the output file")
+	flag.Parse()
+	if *out == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: genmock -out file.go [-pkg mock] import/path.Interface...")
+		os.Exit(2)
+	}
+
+	src, err := generate(*pkgName, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error generating mocks:", err)
+		os.Exit(1)
+	}
+	if err = os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing mocks:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(pkgName string, targets []string) ([]byte, error) {
+	var paths []string
+	for _, t := range targets {
+		idx := strings.LastIndex(t, ".")
+		if idx <= 0 {
+			return nil, errors.Errorf("invalid interface: %s", t)
+		}
+		paths = append(paths, t[:idx])
+	}
+	// The source importer type-checks the packages and their dependencies from source, as the module tree
+	// may have no up-to-date export data.
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	byPath := make(map[string]*types.Package, len(paths))
+	for _, path := range paths {
+		if _, ok := byPath[path]; ok {
+			continue
+		}
+		p, err := imp.Import(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error importing package: %s", path)
+		}
+		byPath[path] = p
+	}
+
+	imports := make(map[string]string)
+	qualifier := func(p *types.Package) string {
+		imports[p.Path()] = p.Name()
+		return p.Name()
+	}
+	var body bytes.Buffer
+	for idx, t := range targets {
+		p := byPath[paths[idx]]
+		name := t[len(paths[idx])+1:]
+		obj := p.Scope().Lookup(name)
+		if obj == nil {
+			return nil, errors.Errorf("%s not found", t)
+		}
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			return nil, errors.Errorf("%s is not an interface", t)
+		}
+		writeMock(&body, qualifier(p)+"."+name, name, iface, qualifier)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by genmock %s. DO NOT EDIT.\n\n", strings.Join(targets, " "))
+	fmt.Fprintf(&src, "package %s\n\n", pkgName)
+	importPaths := make([]string, 0, len(imports))
+	for path := range imports {
+		importPaths = append(importPaths, path)
+	}
+	sort.Strings(importPaths)
+	src.WriteString("import (\n")
+	for _, path := range importPaths {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// writeMock writes the mock of iface and asserts that it implements iface, every method panics when its func field is
+// not set.
+func writeMock(w *bytes.Buffer, ifaceName string, mockName string, iface *types.Interface, q types.Qualifier) {
+	fmt.Fprintf(w, "\nvar _ %s = (*%s)(nil)\n", ifaceName, mockName)
+	fmt.Fprintf(w, "\n// %s is a mock of %s, its methods call the func field of the same name.\n", mockName, ifaceName)
+	fmt.Fprintf(w, "type %s struct {\n", mockName)
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		params, _, results := signature(m.Type().(*types.Signature), q)
+		fmt.Fprintf(w, "\t%sFunc func(%s)%s\n", m.Name(), params, results)
+	}
+	w.WriteString("}\n")
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		params, args, results := signature(m.Type().(*types.Signature), q)
+		fmt.Fprintf(w, "\nfunc (m *%s) %s(%s)%s {\n", mockName, m.Name(), params, results)
+		fmt.Fprintf(w, "\tif m.%sFunc == nil {\n", m.Name())
+		fmt.Fprintf(w, "\t\tpanic(%q)\n\t}\n", fmt.Sprintf("%s.%s called without %sFunc", mockName, m.Name(), m.Name()))
+		ret := "return "
+		if results == "" {
+			ret = ""
+		}
+		fmt.Fprintf(w, "\t%sm.%sFunc(%s)\n}\n", ret, m.Name(), args)
+	}
+}
+
+// signature returns the parameters, the arguments passing them on and the results of sig.
+func signature(sig *types.Signature, q types.Qualifier) (params string, args string, results string) {
+	var ps, as []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		p := sig.Params().At(i)
+		name := p.Name()
+		// m is the receiver of the mock methods.
+		if name == "" || name == "_" || name == "m" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		typ := types.TypeString(p.Type(), q)
+		arg := name
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			typ = "..." + types.TypeString(p.Type().(*types.Slice).Elem(), q)
+			arg += "..."
+		}
+		ps = append(ps, name+" "+typ)
+		as = append(as, arg)
+	}
+	var rs []string
+	for i := 0; i < sig.Results().Len(); i++ {
+		rs = append(rs, types.TypeString(sig.Results().At(i).Type(), q))
+	}
+	switch len(rs) {
+	case 0:
+	case 1:
+		results = " " + rs[0]
+	default:
+		results = " (" + strings.Join(rs, ", ") + ")"
+	}
+	return strings.Join(ps, ", "), strings.Join(as, ", "), results
+}
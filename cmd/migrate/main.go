@@ -0,0 +1,57 @@
+// Command migrate copies the item_histories collection into the time-series collection used when
+// item_history_time_series is set, the migration can be run again to resume it or to copy what was written since.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"pricetracker/internal/configuration"
+	"pricetracker/internal/database"
+)
+
+func main() {
+	configPath := flag.String("config", "config.toml", "configuration file")
+	batchSize := flag.Int("batch", 1000, "number of ItemHistory inserted per batch")
+	flag.Parse()
+	if *batchSize < 1 {
+		fmt.Fprintln(os.Stderr, "Invalid -batch:", *batchSize)
+		os.Exit(2)
+	}
+
+	config, err := configuration.GetConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error getting configuration:", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	dbConn, err := database.ConnectDB(ctx, config.DatabaseURI, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to DB:", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = dbConn.Disconnect(ctx)
+	}()
+	db := database.Database{
+		Database:             dbConn.Database(database.Name),
+		ItemHistoryRetention: config.ItemHistoryRetention,
+	}
+
+	// Only the time-series collection is set up, EnsureIndexes would already drop the TTL index of item_histories
+	// while the server may still be writing to it.
+	if err = db.ItemHistoryTimeSeriesEnsure(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error creating time-series collection and indexes:", err)
+		os.Exit(1)
+	}
+	copied, err := db.ItemHistoryMigrateTimeSeries(ctx, *batchSize, func(copied int64) {
+		fmt.Printf("Copied %d ItemHistory\n", copied)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error migrating ItemHistory:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Done, copied %d ItemHistory to %s\n", copied, database.CollectionItemHistoriesTimeSeries)
+}
@@ -3,15 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"golang.org/x/crypto/acme/autocert"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"pricetracker/internal/client"
 	"pricetracker/internal/configuration"
 	"pricetracker/internal/database"
 	"pricetracker/internal/logger"
 	"pricetracker/internal/server"
+	"pricetracker/internal/service"
+	"pricetracker/internal/tracing"
 	"runtime/debug"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -22,7 +30,9 @@ func main() {
 }
 
 func runApp() error {
-	appContext := context.Background()
+	// appContext is cancelled on SIGINT and SIGTERM, aborting running fetch cycles.
+	appContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	logOutput := io.Writer(os.Stdout)
 	appLogger := logger.New(logger.LevelInfo, logOutput)
 
@@ -71,37 +81,210 @@ func runApp() error {
 	}
 	appLogger.Infof("Config:\n%s", conf)
 
+	var tracer *tracing.Tracer
+	if config.TracingOTLPEndpoint != "" {
+		appLogger.Infof("Exporting traces to %s with sample ratio: %v", config.TracingOTLPEndpoint, config.TracingSampleRatio)
+		tracer = tracing.New(config.TracingOTLPEndpoint, config.TracingServiceName, config.TracingSampleRatio, appLogger)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			tracer.Close(ctx)
+		}()
+	}
+
 	appLogger.Info("Connecting to DB at", config.DatabaseURI)
-	dbConn, err := database.ConnectDB(appContext, config.DatabaseURI)
+	dbConn, err := database.ConnectDB(appContext, config.DatabaseURI, tracer.CommandMonitor())
 	if err != nil {
 		appLogger.Error("Error connecting to DB:", err)
 		return err
 	}
 	defer func() {
-		if err := dbConn.Disconnect(appContext); err != nil {
+		if err := dbConn.Disconnect(context.Background()); err != nil {
 			appLogger.Error("Error disconnecting from DB:", err)
 		}
 	}()
 
+	db := database.Database{
+		Database:              dbConn.Database(database.Name),
+		ItemHistoryRetention:  config.ItemHistoryRetention,
+		ItemHistoryTimeSeries: config.ItemHistoryTimeSeries,
+		ItemHistoryMaxPoints:  config.ItemHistoryMaxPoints,
+		LoginEventRetention:   config.LoginEventRetention,
+		Transactions:          config.DatabaseTransactions,
+	}
+	if config.DatabaseEnsureIndexes {
+		appLogger.Info("Ensuring DB indexes")
+		if err = db.EnsureIndexes(appContext); err != nil {
+			appLogger.Error("Error ensuring DB indexes:", err)
+			return err
+		}
+	} else if drifts, err := db.IndexesDrift(appContext); err != nil {
+		appLogger.Error("Error checking DB index drift:", err)
+	} else {
+		for _, d := range drifts {
+			appLogger.Errorf("DB index drift on collection %s, missing: %v, extra: %v", d.Collection, d.Missing, d.Extra)
+		}
+	}
+	if migrated, err := db.UsersRolesMigrate(appContext); err != nil {
+		appLogger.Error("Error migrating User roles:", err)
+		return err
+	} else if migrated > 0 {
+		appLogger.Infof("Migrated %d User(s) with no roles to the default role", migrated)
+	}
+
+	var redisClient *redis.Client
+	var cache client.Cache = client.NoopCache{}
+	if config.RedisEnabled {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     config.RedisAddress,
+			Password: config.RedisPassword,
+			DB:       config.RedisDB,
+		})
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				appLogger.Error("Error closing Redis client:", err)
+			}
+		}()
+		if tracer != nil {
+			redisClient.AddHook(tracing.RedisHook{Tracer: tracer})
+		}
+		if err = redisClient.Ping(appContext).Err(); err != nil {
+			appLogger.Error("Error connecting to Redis at", config.RedisAddress, "caching will be unavailable:", err)
+		}
+		cache = client.RedisCache{Redis: redisClient}
+	} else {
+		appLogger.Info("Redis is disabled, running without caching and rate limiting")
+	}
+
+	emailPolicy, err := server.NewEmailPolicy(
+		config.EmailDomainAllowlist, config.EmailDomainDenylist, config.DisposableEmailDomainsFile)
+	if err != nil {
+		appLogger.Error("Error creating EmailPolicy:", err)
+		return err
+	}
+
+	siteFingerprints, err := client.NewSiteFingerprints(config.SiteFingerprintsFile)
+	if err != nil {
+		appLogger.Error("Error loading site fingerprints:", err)
+		return err
+	}
+
+	var headlessBrowser *client.HeadlessBrowser
+	if config.ShopeeHeadlessFallback {
+		if headlessBrowser, err = client.NewHeadlessBrowser(config.HeadlessBrowserPath, config.HeadlessBrowserTimeout); err != nil {
+			appLogger.Error("Error starting headless browser for Shopee fallback:", err)
+			return err
+		}
+		defer headlessBrowser.Close()
+	}
+
 	t := http.DefaultTransport.(*http.Transport).Clone()
 	t.MaxConnsPerHost = 8
 	t.MaxIdleConnsPerHost = 8
 	t.IdleConnTimeout = 180 * time.Second
-	srv := server.Server{
-		DB: database.Database{Database: dbConn.Database(database.Name)},
-		Client: client.Client{
-			Client: &http.Client{
-				Timeout: 10 * time.Second,
-				CheckRedirect: func(req *http.Request, via []*http.Request) error {
-					return http.ErrUseLastResponse
-				},
-				Transport: t,
-			},
-			FCMKey: config.FCMKey,
-			Logger: appLogger,
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
 		},
+		Transport: tracer.Transport(t),
+	}
+	var googleKeySet jwk.Set
+	if len(config.GoogleClientIDs) > 0 {
+		if googleKeySet, err = client.NewGoogleKeySet(appContext, httpClient); err != nil {
+			appLogger.Error("Error creating Google key set:", err)
+			return err
+		}
+	}
+	var fcmCredentials *client.FCMCredentials
+	if config.FCMServiceAccountFile != "" {
+		serviceAccountJSON, err := os.ReadFile(config.FCMServiceAccountFile)
+		if err != nil {
+			appLogger.Error("Error reading FCM service account file:", err)
+			return err
+		}
+		if fcmCredentials, err = client.NewFCMCredentials(serviceAccountJSON); err != nil {
+			appLogger.Error("Error creating FCM credentials:", err)
+			return err
+		}
+	}
+	var ebayCredentials *client.EbayCredentials
+	if config.EbayClientID != "" && config.EbayClientSecret != "" {
+		if ebayCredentials, err = client.NewEbayCredentials(config.EbayClientID, config.EbayClientSecret, config.EbayMarketplaceID); err != nil {
+			appLogger.Error("Error creating eBay credentials:", err)
+			return err
+		}
+	}
+	var smtpCredentials *client.SMTPCredentials
+	if config.SMTPHost != "" {
+		smtpCredentials = &client.SMTPCredentials{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+		}
+	}
+	siteClient := client.Client{
+		Client:            httpClient,
+		FCMKey:            config.FCMKey,
+		FCMCredentials:    fcmCredentials,
+		GoogleClientIDs:   config.GoogleClientIDs,
+		GoogleKeySet:      googleKeySet,
+		TelegramBotToken:  config.TelegramBotToken,
+		MidtransServerKey: config.MidtransServerKey,
+		GoUPCAPIKey:       config.GoUPCAPIKey,
+		SMTP:              smtpCredentials,
+		Ebay:              ebayCredentials,
+		Fingerprints:      siteFingerprints,
+		Headless:          headlessBrowser,
+		VisionURL:         config.VisionURL,
+		Limiters:          client.NewSiteLimiters(config.SiteRateLimits),
+		Cache:             cache,
+		CacheTTLs:         config.ClientCacheTTLs,
+		Flights:           &client.SingleFlight{},
+		Logger:            appLogger,
+	}
+	srv := server.Server{
+		DB:            db,
+		Redis:         redisClient,
+		Cache:         cache,
+		Client:        siteClient,
 		Logger:        appLogger,
 		AuthSecretKey: config.AuthSecretKey,
+		StartedAt:     time.Now(),
+		Context:       appContext,
+		EmailPolicy:   emailPolicy,
+		AdminAPIKey:   config.AdminAPIKey,
+		Tracer:        tracer,
+
+		TrustTraceparent: config.TracingTrustTraceparent,
+
+		ReferralRewardTrackedItems: config.ReferralRewardTrackedItems,
+		PremiumDurationDays:        config.PremiumDurationDays,
+		PremiumPrice:               config.PremiumPrice,
+		TelegramBotUsername:        config.TelegramBotUsername,
+		TrackedItemsLimits:         config.TrackedItemsLimits,
+
+		NotificationCooldown:           config.NotificationCooldown,
+		NotificationMaxPerThresholdHit: config.NotificationMaxPerThresholdHit,
+
+		FetcherWorkersPerSite: config.FetcherWorkersPerSite,
+		FetcherQueueSize:      config.FetcherQueueSize,
+
+		SearchResultLimits: config.SearchResultLimits,
+
+		OrphanedItemGracePeriod: config.OrphanedItemGracePeriod,
+	}
+	srv.Scrapers = service.NewScrapers(srv.Client)
+	srv.Items = service.NewItemService(srv.DB, srv.Scrapers, appLogger)
+	srv.Users = service.NewUserService(srv.DB, appLogger)
+	srv.Notifications = service.NewNotificationService(srv.DB, srv.Client, appLogger)
+	if config.NotificationBatchWindow > 0 {
+		srv.NotificationBatcher = server.NewNotificationBatcher(config.NotificationBatchWindow)
+	}
+	if config.ServerEnabled {
+		srv.PriceStream = server.NewPriceStream()
 	}
 
 	if !(config.ServerEnabled || config.FetcherEnabled) {
@@ -109,23 +292,156 @@ func runApp() error {
 		return nil
 	}
 
+	if config.SiteFingerprintsFile != "" {
+		appLogger.Info("Starting site fingerprints reloader with interval:", config.SiteFingerprintsReloadInterval)
+		go srv.ReloadSiteFingerprintsInInterval(appContext, time.NewTicker(config.SiteFingerprintsReloadInterval))
+	}
+
+	var fetchDataTicker *time.Ticker
+	var fetchers sync.WaitGroup
+	// Aborts the fetchers and waits for them before disconnecting from DB, also when exiting on a server error.
+	defer func() {
+		stop()
+		fetchers.Wait()
+	}()
 	if config.FetcherEnabled {
 		appLogger.Info("Starting fetcher with interval:", config.FetchDataInterval)
-		go srv.FetchDataInInterval(appContext, time.NewTicker(config.FetchDataInterval))
+		fetchDataTicker = time.NewTicker(config.FetchDataInterval)
+		srv.FetcherStatus = server.NewFetcherStatus(config.FetchDataInterval)
+		fetchers.Add(2)
+		go func() {
+			defer fetchers.Done()
+			srv.FetchDataInInterval(appContext, fetchDataTicker)
+		}()
+		go func() {
+			defer fetchers.Done()
+			srv.FetchPriorityDataInInterval(appContext, time.NewTicker(time.Minute))
+		}()
+		go srv.PublishFetcherStatusInInterval(appContext, time.NewTicker(10*time.Second))
+		go srv.DeliverQueuedNotificationsInInterval(appContext, time.NewTicker(time.Minute))
+		if config.DatabaseTransactions {
+			go srv.DedupItemsInInterval(appContext, time.NewTicker(time.Hour))
+		} else {
+			appLogger.Info("Not deduplicating Items, merging them needs database_transactions")
+		}
+		if config.SMTPHost != "" {
+			appLogger.Info("Starting price digest emails through SMTP server:", config.SMTPHost)
+			go srv.SendPriceDigestsInInterval(appContext, time.NewTicker(10*time.Minute))
+		}
 	}
 
+	// SIGHUP reloads the log level, fetch interval and site rate limits without restarting,
+	// other changes to the configuration need a restart.
+	reloadSignal := make(chan os.Signal, 1)
+	signal.Notify(reloadSignal, syscall.SIGHUP)
+	go func(current *configuration.Config) {
+		for range reloadSignal {
+			reloaded, err := configuration.GetConfig("config.toml")
+			if err != nil {
+				appLogger.Error("Error reloading configuration, keeping current one:", err)
+				continue
+			}
+			if reloaded.LogLevel != current.LogLevel {
+				appLogger.Infof("Reloaded log level: %v -> %v", current.LogLevel, reloaded.LogLevel)
+				appLogger.SetLevel(reloaded.LogLevel)
+			}
+			if fetchDataTicker != nil && reloaded.FetchDataInterval != current.FetchDataInterval {
+				appLogger.Infof("Reloaded fetch data interval: %v -> %v", current.FetchDataInterval, reloaded.FetchDataInterval)
+				fetchDataTicker.Reset(reloaded.FetchDataInterval)
+				srv.FetcherStatus.Reschedule(reloaded.FetchDataInterval)
+			}
+			siteClient.Limiters.SetLimits(reloaded.SiteRateLimits)
+			appLogger.Info("Reloaded configuration")
+			current = reloaded
+		}
+	}(config)
+
 	if config.ServerEnabled {
+		if config.DisposableEmailDomainsFile != "" {
+			appLogger.Info("Starting disposable email domains refresher with interval:", config.DisposableEmailDomainsRefreshInterval)
+			go srv.RefreshEmailPolicyInInterval(appContext, time.NewTicker(config.DisposableEmailDomainsRefreshInterval))
+		}
+		go srv.DeleteExpiredUserExportsInInterval(appContext, time.NewTicker(time.Hour))
+		go srv.RelayPriceUpdates(appContext)
+		if config.TelegramWebhookURL != "" && siteClient.TelegramEnabled() {
+			if err = siteClient.TelegramSetWebhook(appContext, config.TelegramWebhookURL); err != nil {
+				appLogger.Error("Error setting Telegram webhook:", err)
+			}
+		}
+		router := srv.Router()
+		timeoutHandler := http.TimeoutHandler(router, 15*time.Second, http.StatusText(http.StatusServiceUnavailable))
 		httpSrv := &http.Server{
-			Handler:        http.TimeoutHandler(srv.Router(), 15*time.Second, http.StatusText(http.StatusServiceUnavailable)),
+			// The price stream stays open for up to StreamMaxDuration and sets its own write deadlines,
+			// every other request is bounded by the handler timeout.
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == server.StreamPath {
+					router.ServeHTTP(w, r)
+					return
+				}
+				timeoutHandler.ServeHTTP(w, r)
+			}),
+			ConnContext:    server.ConnContext,
 			Addr:           config.ServerAddress,
 			WriteTimeout:   20 * time.Second,
 			ReadTimeout:    15 * time.Second,
 			IdleTimeout:    60 * time.Second,
 			MaxHeaderBytes: 1024,
 		}
-		appLogger.Info("Serving on", httpSrv.Addr)
-		return httpSrv.ListenAndServe()
+		httpSrv.RegisterOnShutdown(srv.PriceStream.Close)
+		go func() {
+			<-appContext.Done()
+			appLogger.Info("Shutting down server")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+				appLogger.Error("Error shutting down server:", err)
+			}
+		}()
+
+		var redirectHandler http.Handler
+		switch {
+		case len(config.AutocertDomains) > 0:
+			certManager := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				HostPolicy: autocert.HostWhitelist(config.AutocertDomains...),
+				Cache:      autocert.DirCache(config.AutocertCacheDir),
+				Email:      config.AutocertEmail,
+			}
+			httpSrv.TLSConfig = certManager.TLSConfig()
+			redirectHandler = certManager.HTTPHandler(server.HTTPSRedirect(config.ServerAddress))
+		case config.TLSCertFile != "":
+			redirectHandler = server.HTTPSRedirect(config.ServerAddress)
+		default:
+			appLogger.Info("Serving on", httpSrv.Addr)
+			if err = httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		}
+
+		if config.HTTPRedirectAddress != "" {
+			redirectSrv := &http.Server{
+				Handler:        redirectHandler,
+				Addr:           config.HTTPRedirectAddress,
+				WriteTimeout:   20 * time.Second,
+				ReadTimeout:    15 * time.Second,
+				IdleTimeout:    60 * time.Second,
+				MaxHeaderBytes: 1024,
+			}
+			go func() {
+				appLogger.Info("Redirecting HTTP to HTTPS on", redirectSrv.Addr)
+				if err := redirectSrv.ListenAndServe(); err != nil {
+					appLogger.Error("Error serving HTTP to HTTPS redirect:", err)
+				}
+			}()
+		}
+		appLogger.Info("Serving TLS on", httpSrv.Addr)
+		if err = httpSrv.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
 	}
 
-	select {}
+	<-appContext.Done()
+	return nil
 }
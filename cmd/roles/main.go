@@ -0,0 +1,56 @@
+// Command roles adds a role to the registered Users with the given emails, e.g. to make them admins. Roles are only
+// granted through this command, as emails of registered Users are not verified.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"pricetracker/internal/configuration"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+func main() {
+	configPath := flag.String("config", "config.toml", "configuration file")
+	role := flag.String("role", model.RoleAdmin, "role to add")
+	flag.Parse()
+	emails := flag.Args()
+	if len(emails) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: roles [-config config.toml] [-role admin] email...")
+		os.Exit(2)
+	}
+	if !model.ValidRole(*role) {
+		fmt.Fprintln(os.Stderr, "Invalid -role:", *role)
+		os.Exit(2)
+	}
+	for idx := range emails {
+		emails[idx] = strings.TrimSpace(emails[idx])
+	}
+
+	config, err := configuration.GetConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error getting configuration:", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	dbConn, err := database.ConnectDB(ctx, config.DatabaseURI, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to DB:", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = dbConn.Disconnect(ctx)
+	}()
+	db := database.Database{Database: dbConn.Database(database.Name)}
+
+	added, err := db.UsersRoleAddByEmail(ctx, emails, *role)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error adding role:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added role %s to %d User(s)\n", *role, added)
+}
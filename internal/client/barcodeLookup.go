@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+var ErrBarcodeLookup = errors.New("barcode lookup error")
+var ErrBarcodeNotFound = errors.New("barcode not found")
+
+// Sources of the Barcodes resolved by BarcodeLookup.
+const (
+	BarcodeSourceGoUPC         = "go-upc"
+	BarcodeSourceOpenFoodFacts = "openfoodfacts"
+)
+
+type goUPCResponse struct {
+	Product *struct {
+		Name  string `json:"name"`
+		Brand string `json:"brand"`
+	} `json:"product"`
+}
+
+type openFoodFactsResponse struct {
+	Status  int `json:"status"`
+	Product struct {
+		ProductName string `json:"product_name"`
+		Brands      string `json:"brands"`
+	} `json:"product"`
+}
+
+// BarcodeLookup resolves the product of barcode with the external barcode databases, go-upc when GoUPCAPIKey
+// is set and then OpenFoodFacts. It returns an error wrapping ErrBarcodeNotFound when none of them know it.
+func (c Client) BarcodeLookup(ctx context.Context, barcode string) (model.Barcode, error) {
+	return cached(ctx, c, CacheOpBarcode, "", barcode, ErrBarcodeNotFound, func() (model.Barcode, error) {
+		return c.barcodeLookup(ctx, barcode)
+	})
+}
+
+func (c Client) barcodeLookup(ctx context.Context, barcode string) (model.Barcode, error) {
+	var errs []string
+	if c.GoUPCAPIKey != "" {
+		name, brand, err := c.goUPCLookup(ctx, barcode)
+		if err == nil {
+			return newLookedUpBarcode(barcode, name, brand, BarcodeSourceGoUPC), nil
+		}
+		if !errors.Is(err, ErrBarcodeNotFound) {
+			errs = append(errs, err.Error())
+		}
+	}
+	name, brand, err := c.openFoodFactsLookup(ctx, barcode)
+	if err == nil {
+		return newLookedUpBarcode(barcode, name, brand, BarcodeSourceOpenFoodFacts), nil
+	}
+	if !errors.Is(err, ErrBarcodeNotFound) {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return model.Barcode{}, errors.Wrapf(ErrBarcodeLookup, "barcode: %s, errors: %s", barcode, strings.Join(errs, "; "))
+	}
+	return model.Barcode{}, errors.Wrapf(ErrBarcodeNotFound, "barcode: %s", barcode)
+}
+
+// newLookedUpBarcode builds the Barcode of a looked up product, q1 is the brand and name and q2 only the name.
+func newLookedUpBarcode(barcode string, name string, brand string, source string) model.Barcode {
+	name = misc.CleanString(name)
+	brand = misc.CleanString(strings.Split(brand, ",")[0])
+	q1 := name
+	if brand != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(brand)) {
+		q1 = brand + " " + name
+	}
+	return model.Barcode{
+		BarcodeNumber: barcode,
+		ProductName:   name,
+		Query1:        q1[:misc.Min(len(q1), 100)],
+		Query2:        name[:misc.Min(len(name), 100)],
+		Source:        source,
+	}
+}
+
+func (c Client) goUPCLookup(ctx context.Context, barcode string) (name string, brand string, err error) {
+	var gr goUPCResponse
+	status, err := c.barcodeLookupGet(ctx, fmt.Sprintf("https://go-upc.com/api/v1/code/%s", barcode), c.GoUPCAPIKey, &gr)
+	if status == http.StatusNotFound || (err == nil && (gr.Product == nil || gr.Product.Name == "")) {
+		return "", "", errors.Wrapf(ErrBarcodeNotFound, "go-upc, barcode: %s", barcode)
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "go-upc, barcode: %s", barcode)
+	}
+	return gr.Product.Name, gr.Product.Brand, nil
+}
+
+func (c Client) openFoodFactsLookup(ctx context.Context, barcode string) (name string, brand string, err error) {
+	var or openFoodFactsResponse
+	apiURL := fmt.Sprintf("https://world.openfoodfacts.org/api/v2/product/%s.json?fields=product_name,brands", barcode)
+	status, err := c.barcodeLookupGet(ctx, apiURL, "", &or)
+	if status == http.StatusNotFound || (err == nil && (or.Status != 1 || or.Product.ProductName == "")) {
+		return "", "", errors.Wrapf(ErrBarcodeNotFound, "OpenFoodFacts, barcode: %s", barcode)
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "OpenFoodFacts, barcode: %s", barcode)
+	}
+	return or.Product.ProductName, or.Product.Brands, nil
+}
+
+// barcodeLookupGet gets apiURL and unmarshals the response body into v, it returns the response status code.
+func (c Client) barcodeLookupGet(ctx context.Context, apiURL string, bearer string, v any) (int, error) {
+	req, err := newRequest(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating HTTP request")
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "error doing request")
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("barcodeLookupGet: Error closing response body, err: %v, TraceID: %s", err, TraceID(ctx))
+		}
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 100000))
+	if err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "error reading response body, status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, errors.Errorf("status: %s, body:\n%s", resp.Status, misc.BytesLimit(body, 500))
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "error unmarshalling response body:\n%s", misc.BytesLimit(body, 500))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+// benchTokopediaPage mimics the parts of a Tokopedia product page read by tokopediaParseProductPage.
+var benchTokopediaPage = []byte(`<html><head><script>window.__cache={"pdpSession":"{\"sid\":1234567,\"pi\":7654321,` +
+	`\"sd\":\"benchshop\",\"pn\":\"Bench Item 128GB - Black\",\"pr\":1500000,\"st\":25,\"cat\":\"x\"}",` +
+	`"basicInfo":{"alias":"bench-item-128gb-black","rating":4.8,"countSold":"1200","ttl":1},` +
+	`"media":[{"type":"image","URLThumbnail":"https://images.tokopedia.net/img/cache/200-square/bench.jpg","x":1}],` +
+	`"content":[{"title":"Deskripsi","subtitle":"` + strings.Repeat("Bench item description. ", 80) + `","x":1}]` +
+	strings.Repeat(" ", 8000) + `</script></head><body></body></html>`)
+
+func BenchmarkTokopediaParseProductPage(b *testing.B) {
+	if _, err := tokopediaParseProductPage(benchTokopediaPage); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = tokopediaParseProductPage(benchTokopediaPage)
+	}
+}
+
+var benchBlibliDescription = "<html><body>" +
+	strings.Repeat("<p>Bench <b>item</b> description &amp; specification<br/>\\nline</p>", 60) +
+	"<ul><li>Spec 1</li><li>Spec 2</li></ul></body></html>"
+
+func BenchmarkBlibliDescriptionParser(b *testing.B) {
+	if _, err := blibliDescriptionParser(benchBlibliDescription); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = blibliDescriptionParser(benchBlibliDescription)
+	}
+}
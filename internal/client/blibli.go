@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,8 +40,10 @@ type blibliProductDetailData struct {
 		Thumbnail string `json:"thumbnail"`
 	} `json:"images"`
 	Merchant struct {
-		Name string `json:"name"`
-		Code string `json:"code"`
+		Name     string  `json:"name"`
+		Code     string  `json:"code"`
+		Location string  `json:"location"`
+		Rating   float64 `json:"rating"`
 	} `json:"merchant"`
 	Review struct {
 		DecimalRating float64 `json:"decimalRating"`
@@ -48,6 +51,29 @@ type blibliProductDetailData struct {
 	Statistics struct {
 		Sold int `json:"sold"`
 	} `json:"statistics"`
+	Categories []struct {
+		Name string `json:"name"`
+	} `json:"categories"`
+}
+
+type blibliProductVariantsResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Name     string                 `json:"name"`
+		Variants []blibliProductVariant `json:"variants"`
+	} `json:"data"`
+}
+
+type blibliProductVariant struct {
+	ItemSKU    string `json:"itemSku"`
+	Attributes []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"attributes"`
+	Stock int `json:"stock"`
+	Price struct {
+		Offered float64 `json:"offered"`
+	} `json:"price"`
 }
 
 type blibliProductDescriptionResponse struct {
@@ -57,21 +83,28 @@ type blibliProductDescriptionResponse struct {
 	} `json:"data"`
 }
 
-func (c Client) BlibliGetItem(url string) (model.Item, error) {
+func (c Client) BlibliGetItem(ctx context.Context, url string) (model.Item, error) {
+	return flight(ctx, c, CacheOpGetItem+":"+productKey(SiteBlibli, url), func(ctx context.Context) (model.Item, error) {
+		return cached(ctx, c, CacheOpGetItem, SiteBlibli, url, ErrBlibliItemNotFound, func() (model.Item, error) {
+			return c.blibliGetItem(ctx, url)
+		})
+	})
+}
+
+func (c Client) blibliGetItem(ctx context.Context, url string) (model.Item, error) {
 	var i model.Item
-	sku, err := c.blibliGetSKU(url)
+	sku, err := c.blibliGetSKU(ctx, url)
 	if err != nil {
 		return i, fmt.Errorf("%w: failed getting SKU from URL: %#v, err: %v", ErrBlibliItemNotFound, url, err)
 	}
-	apiURL := fmt.Sprintf("https://www.blibli.com/backend/product-detail/products/%s/_summary", sku)
-	req, err := newRequest(http.MethodGet, apiURL, nil)
+	apiPath := fmt.Sprintf("/backend/product-detail/products/%s/_summary", sku)
+	req, err := c.siteAPIRequest(ctx, SiteBlibli, http.MethodGet, apiPath, nil)
 	if err != nil {
-		return i, fmt.Errorf("failed to create request to URL: %s, err: %v", apiURL, err)
+		return i, fmt.Errorf("failed to create request to path: %s, err: %v", apiPath, err)
 	}
-	req.Header.Add("Accept-Language", "en")
-	resp, err := c.Do(req)
+	resp, err := c.doSite(SiteBlibli, req)
 	if err != nil {
-		return i, fmt.Errorf("%w: error doing request:\n%#v,\nerr: %v", ErrBlibli, req, err)
+		return i, fmt.Errorf("error doing request:\n%#v,\nerr: %w", req, err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -100,27 +133,96 @@ func (c Client) BlibliGetItem(url string) (model.Item, error) {
 	if i.ProductID == "" || i.URL == "" || i.ImageURL == "" {
 		return i, fmt.Errorf("error parsing Blibli product: %+v, Item: %+v", blibliResp.Data, i)
 	}
-	i.Description, err = c.blibliGetItemDescription(i.ProductID)
+	i.Description, err = c.blibliGetItemDescription(ctx, i.ProductID)
 	if err != nil {
 		return i, fmt.Errorf("error getting Blibli product description, Item: %+v, err: %w", i, err)
 	}
 	return i, nil
 }
 
-func (c Client) blibliGetItemDescription(sku string) (string, error) {
+// BlibliGetItemVariants returns every variant of the product of url, each with its own item SKU and URL.
+func (c Client) BlibliGetItemVariants(ctx context.Context, url string) ([]model.ItemVariant, error) {
+	return cached(ctx, c, CacheOpVariants, SiteBlibli, url, ErrBlibliItemNotFound, func() ([]model.ItemVariant, error) {
+		return c.blibliGetItemVariants(ctx, url)
+	})
+}
+
+func (c Client) blibliGetItemVariants(ctx context.Context, url string) ([]model.ItemVariant, error) {
+	var vs []model.ItemVariant
+	sku, err := c.blibliGetSKU(ctx, url)
+	if err != nil {
+		return vs, fmt.Errorf("%w: failed getting SKU from URL: %#v, err: %v", ErrBlibliItemNotFound, url, err)
+	}
+	if strings.HasPrefix(sku, "ps--") || strings.HasPrefix(sku, "is--") {
+		sku = sku[4:]
+	}
+	apiPath := fmt.Sprintf("/backend/product-detail/products/ps--%s/variants", sku[:15])
+	req, err := c.siteAPIRequest(ctx, SiteBlibli, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return vs, fmt.Errorf("failed to create request to path: %s, err: %v", apiPath, err)
+	}
+	resp, err := c.doSite(SiteBlibli, req)
+	if err != nil {
+		return vs, fmt.Errorf("error doing request:\n%#v,\nerr: %w", req, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 300*1024))
+	if err != nil {
+		return vs, fmt.Errorf(
+			"error reading BlibliProductVariantsAPI response body, status: %s, body:\n%s,\nreq:\n%#v,\nerr: %v",
+			resp.Status, misc.BytesLimit(body, 2000), req, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return vs, fmt.Errorf("%w: status: %s, body:\n%s,\nreq:\n%#v",
+			ErrBlibliItemNotFound, resp.Status, misc.BytesLimit(body, 2000), req)
+	}
+	blibliResp := blibliProductVariantsResponse{}
+	if err = json.Unmarshal(body, &blibliResp); err != nil {
+		return vs, fmt.Errorf(
+			"error unmarshalling BlibliProductVariantsAPI response body, status: %s, body:\n%s,\nreq:\n%#v,\nerr: %v",
+			resp.Status, misc.BytesLimit(body, 2000), req, err)
+	}
+	if blibliResp.Code != 200 {
+		return vs, fmt.Errorf("%w: error getting data from BlibliProductVariantsAPI, status: %s, body:\n%s,\nreq:\n%#v",
+			ErrBlibli, resp.Status, misc.BytesLimit(body, 2000), req)
+	}
+	vs = make([]model.ItemVariant, 0, len(blibliResp.Data.Variants))
+	for _, bv := range blibliResp.Data.Variants {
+		normItemSKU, _ := blibliNormalizeSKU(bv.ItemSKU)
+		if len(normItemSKU) != 21 {
+			c.Logger.Warnf("blibliGetItemVariants: Invalid item SKU of Blibli product variant: %+v, url: %s, TraceID: %s", bv, url, TraceID(ctx))
+			continue
+		}
+		names := make([]string, 0, len(bv.Attributes))
+		for _, a := range bv.Attributes {
+			names = append(names, strings.TrimSpace(a.Value))
+		}
+		vs = append(vs, model.ItemVariant{
+			VariationID: normItemSKU,
+			Name:        strings.Join(names, ", "),
+			Price:       int(bv.Price.Offered),
+			Stock:       bv.Stock,
+			URL:         blibliItemURL(blibliResp.Data.Name, normItemSKU),
+		})
+	}
+	return vs, nil
+}
+
+func (c Client) blibliGetItemDescription(ctx context.Context, sku string) (string, error) {
 	normSKU, ok := blibliNormalizeSKU(sku)
 	if !ok || len(normSKU) != 21 {
 		return "", fmt.Errorf("invalid SKU: %#v", sku)
 	}
-	apiURL := fmt.Sprintf("https://www.blibli.com/backend/product-detail/products/%s/description", sku)
-	req, err := newRequest(http.MethodGet, apiURL, nil)
+	apiPath := fmt.Sprintf("/backend/product-detail/products/%s/description", sku)
+	req, err := c.siteAPIRequest(ctx, SiteBlibli, http.MethodGet, apiPath, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request to URL: %s, err: %v", apiURL, err)
+		return "", fmt.Errorf("failed to create request to path: %s, err: %v", apiPath, err)
 	}
-	req.Header.Add("Accept-Language", "en")
-	resp, err := c.Do(req)
+	resp, err := c.doSite(SiteBlibli, req)
 	if err != nil {
-		return "", fmt.Errorf("%w: error doing request:\n%#v,\nerr: %v", ErrBlibli, req, err)
+		return "", fmt.Errorf("error doing request:\n%#v,\nerr: %w", req, err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -203,13 +305,13 @@ func htmlBodyFinder(node *html.Node) (*html.Node, error) {
 	return nil, errors.New("traverse limit exceeded")
 }
 
-func (c Client) blibliGetSKU(urlStr string) (string, error) {
+func (c Client) blibliGetSKU(ctx context.Context, urlStr string) (string, error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
 		return "", fmt.Errorf("error parsing URL: %v", err)
 	}
 	if parsedURL.Host == "blibli.app.link" && len(parsedURL.Path) > 5 {
-		if resolvedURL, err := c.blibliResolveShareLink("https://blibli.app.link" + parsedURL.Path); err != nil {
+		if resolvedURL, err := c.blibliResolveShareLink(ctx, "https://blibli.app.link"+parsedURL.Path); err != nil {
 			return "", fmt.Errorf("failed to get SKU from share link, err: %v", err)
 		} else if parsedURL, err = url.Parse(resolvedURL); err != nil {
 			return "", fmt.Errorf("error parsing resolved URL from share link, err: %v", err)
@@ -228,15 +330,14 @@ func (c Client) blibliGetSKU(urlStr string) (string, error) {
 	return "", fmt.Errorf("invalid URL: %s", parsedURL)
 }
 
-func (c Client) blibliResolveShareLink(url string) (string, error) {
-	req, err := newRequest(http.MethodGet, url, nil)
+func (c Client) blibliResolveShareLink(ctx context.Context, url string) (string, error) {
+	req, err := c.siteShareLinkRequest(ctx, SiteBlibli, url)
 	if err != nil {
 		return "", fmt.Errorf("error creating request from URL: %s, err: %v", url, err)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 Windows")
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteBlibli, req)
 	if err != nil {
-		return "", fmt.Errorf("error doing request, req:\n%#v,\nerr: %v", req, err)
+		return "", fmt.Errorf("error doing request, req:\n%#v,\nerr: %w", req, err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -275,6 +376,12 @@ func blibliNormalizeSKU(sku string) (string, bool) {
 	return "", false
 }
 
+// blibliItemURL returns the URL of the item with the normalized item SKU of the product named name.
+func blibliItemURL(name string, normItemSKU string) string {
+	return fmt.Sprintf("https://www.blibli.com/p/%s/is--%s",
+		strings.ToLower(strings.ReplaceAll(misc.CleanString(name), " ", "-")), normItemSKU)
+}
+
 func (bp blibliProductDetailData) toItem() model.Item {
 	var itemURL string
 	normItemSKU, _ := blibliNormalizeSKU(bp.ItemSKU)
@@ -282,8 +389,7 @@ func (bp blibliProductDetailData) toItem() model.Item {
 		normItemSKU = ""
 	}
 	if normItemSKU != "" {
-		itemURL = fmt.Sprintf("https://www.blibli.com/p/%s/is--%s",
-			strings.ToLower(strings.ReplaceAll(misc.CleanString(bp.Name), " ", "-")), normItemSKU)
+		itemURL = blibliItemURL(bp.Name, normItemSKU)
 	}
 	itemName := strings.TrimSpace(strings.ReplaceAll(bp.Name, "\n", " "))
 	var imageURL string
@@ -293,20 +399,29 @@ func (bp blibliProductDetailData) toItem() model.Item {
 			imageURL = s
 		}
 	}
+	categories := make([]string, 0, len(bp.Categories))
+	for _, c := range bp.Categories {
+		categories = append(categories, c.Name)
+	}
 	return model.Item{
-		Site:        "Blibli",
-		MerchantID:  normItemSKU[:misc.Min(9, len(normItemSKU))],
-		ProductID:   normItemSKU,
-		ParentID:    normItemSKU[:misc.Min(15, len(normItemSKU))],
-		VariationID: normItemSKU,
-		URL:         itemURL,
-		Name:        itemName,
-		Price:       int(bp.Price.Offered),
-		Stock:       bp.Stock,
-		ImageURL:    imageURL,
-		Description: "",
-		Rating:      bp.Review.DecimalRating,
-		Sold:        bp.Statistics.Sold,
+		Site:             "Blibli",
+		MerchantID:       normItemSKU[:misc.Min(9, len(normItemSKU))],
+		MerchantName:     strings.TrimSpace(bp.Merchant.Name),
+		MerchantLocation: strings.TrimSpace(bp.Merchant.Location),
+		MerchantRating:   bp.Merchant.Rating,
+		ProductID:        normItemSKU,
+		ParentID:         normItemSKU[:misc.Min(15, len(normItemSKU))],
+		VariationID:      normItemSKU,
+		URL:              itemURL,
+		Name:             itemName,
+		Price:            int(bp.Price.Offered),
+		Currency:         model.CurrencyIDR,
+		Stock:            bp.Stock,
+		ImageURL:         imageURL,
+		Description:      "",
+		Rating:           bp.Review.DecimalRating,
+		Sold:             bp.Statistics.Sold,
+		Categories:       model.ItemCategories(categories),
 	}
 }
 
@@ -333,12 +448,18 @@ type blibliSearchProduct struct {
 	} `json:"soldRangeCount"`
 }
 
-func (c Client) BlibliSearch(query string) ([]model.Item, error) {
+func (c Client) BlibliSearch(ctx context.Context, query string) ([]model.Item, error) {
+	return cached(ctx, c, CacheOpSearch, SiteBlibli, query, nil, func() ([]model.Item, error) {
+		return c.blibliSearch(ctx, query)
+	})
+}
+
+func (c Client) blibliSearch(ctx context.Context, query string) ([]model.Item, error) {
 	var is []model.Item
-	apiURL := "https://www.blibli.com/backend/search/products"
-	req, err := newRequest(http.MethodGet, apiURL, nil)
+	apiPath := "/backend/search/products"
+	req, err := c.siteAPIRequest(ctx, SiteBlibli, http.MethodGet, apiPath, nil)
 	if err != nil {
-		return is, fmt.Errorf("failed to create request to URL: %s, err: %v", apiURL, err)
+		return is, fmt.Errorf("failed to create request to path: %s, err: %v", apiPath, err)
 	}
 	qp := url.Values{
 		"intent":         []string{"true"},
@@ -348,10 +469,9 @@ func (c Client) BlibliSearch(query string) ([]model.Item, error) {
 		"userIdentifier": []string{"undefined"},
 	}.Encode()
 	req.URL.RawQuery = strings.ReplaceAll(qp, "+", "%20")
-	req.Header.Add("Accept-Language", "en")
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteBlibli, req)
 	if err != nil {
-		return is, fmt.Errorf("%w: error doing request:\n%#v,\nerr: %v", ErrBlibli, req, err)
+		return is, fmt.Errorf("error doing request:\n%#v,\nerr: %w", req, err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -377,7 +497,7 @@ func (c Client) BlibliSearch(query string) ([]model.Item, error) {
 	for _, bsp := range bsps[:misc.Min(10, len(bsps))] {
 		i := bsp.toItem()
 		if i.ProductID == "" || i.URL == "" || i.ImageURL == "" {
-			c.Logger.Warnf("BlibliSearch: Error parsing Blibli product: %+v, Item: %+v", bsp, i)
+			c.Logger.Warnf("blibliSearch: Error parsing Blibli product: %+v, Item: %+v, TraceID: %s", bsp, i, TraceID(ctx))
 			continue
 		}
 		is = append(is, i)
@@ -392,8 +512,7 @@ func (bsp blibliSearchProduct) toItem() model.Item {
 		normItemSKU = ""
 	}
 	if normItemSKU != "" {
-		itemURL = fmt.Sprintf("https://www.blibli.com/p/%s/is--%s",
-			strings.ToLower(strings.ReplaceAll(misc.CleanString(bsp.Name), " ", "-")), normItemSKU)
+		itemURL = blibliItemURL(bsp.Name, normItemSKU)
 	}
 	itemName := strings.TrimSpace(strings.ReplaceAll(bsp.Name, "\n", " "))
 	var imageURL string
@@ -412,6 +531,7 @@ func (bsp blibliSearchProduct) toItem() model.Item {
 		URL:         itemURL,
 		Name:        itemName,
 		Price:       int(bsp.Price.MinPrice),
+		Currency:    model.CurrencyIDR,
 		Stock:       -1,
 		ImageURL:    imageURL,
 		Description: "",
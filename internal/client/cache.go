@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"reflect"
+	"time"
+)
+
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cached operations of the site clients, CacheOpNotFound is the TTL of item not found responses and empty
+// search results, which are cached briefly so repeated lookups of bad URLs and barcodes do not reach the site.
+// CacheOpBarcode is the external barcode lookup, which is not tied to a site.
+const (
+	CacheOpGetItem  = "get_item"
+	CacheOpSearch   = "search"
+	CacheOpVariants = "variants"
+	CacheOpBarcode  = "barcode"
+	CacheOpNotFound = "not_found"
+)
+
+// DefaultCacheTTLs are how long the results of each cached operation are kept, operations missing from
+// Client.CacheTTLs use these. Client.CacheTTLs can also override the TTL of an operation on a single site
+// with a key of the operation and site joined by a dot, e.g. "search.shopee".
+var DefaultCacheTTLs = map[string]time.Duration{
+	CacheOpGetItem:  5 * time.Minute,
+	CacheOpSearch:   10 * time.Minute,
+	CacheOpVariants: 5 * time.Minute,
+	CacheOpBarcode:  time.Hour,
+	CacheOpNotFound: time.Minute,
+}
+
+// cacheTimeout bounds the time spent on the cache per operation, the site is requested on cache errors.
+const cacheTimeout = time.Second
+
+// Cache stores values by key until their TTL expires.
+type Cache interface {
+	// Get returns ErrCacheMiss when key is not cached.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX sets key only when it is not cached yet, and reports whether it was set.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+}
+
+type RedisCache struct {
+	Redis *redis.Client
+}
+
+func (rc RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := rc.Redis.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	return b, err
+}
+
+func (rc RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return rc.Redis.Set(ctx, key, value, ttl).Err()
+}
+
+func (rc RedisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return rc.Redis.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (rc RedisCache) Del(ctx context.Context, keys ...string) error {
+	return rc.Redis.Del(ctx, keys...).Err()
+}
+
+// NoopCache caches nothing, for deployments without Redis.
+type NoopCache struct{}
+
+func (NoopCache) Get(context.Context, string) ([]byte, error) {
+	return nil, ErrCacheMiss
+}
+
+func (NoopCache) Set(context.Context, string, []byte, time.Duration) error {
+	return nil
+}
+
+// SetNX always sets, as there is nothing shared to coordinate with.
+func (NoopCache) SetNX(context.Context, string, []byte, time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (NoopCache) Del(context.Context, ...string) error {
+	return nil
+}
+
+func cacheKey(op string, site string, key string) string {
+	return "client:" + op + ":" + site + ":" + key
+}
+
+func (c Client) cacheTTL(op string, site string) time.Duration {
+	if ttl, ok := c.CacheTTLs[op+"."+site]; ok {
+		return ttl
+	}
+	if ttl, ok := c.CacheTTLs[op]; ok {
+		return ttl
+	}
+	return DefaultCacheTTLs[op]
+}
+
+// cached returns the cached result of op for key on site, or calls get and caches its result when it succeeds.
+// Errors wrapping notFound are cached as well for the not found TTL, and returned wrapping notFound again,
+// notFound may be nil. Empty slices are cached for the not found TTL too.
+// Nothing is cached when Cache is nil or the TTL is zero.
+func cached[T any](
+	ctx context.Context, c Client, op string, site string, key string, notFound error, get func() (T, error),
+) (T, error) {
+	var v T
+	if c.Cache == nil || c.cacheTTL(op, site) <= 0 {
+		return get()
+	}
+	traceID := TraceID(ctx)
+	cacheCtx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+	k := cacheKey(op, site, key)
+	notFoundKey := cacheKey(CacheOpNotFound, site, op+":"+key)
+
+	keys := []string{k}
+	if notFound != nil {
+		keys = append(keys, notFoundKey)
+	}
+	for _, ck := range keys {
+		b, err := c.Cache.Get(cacheCtx, ck)
+		if err == ErrCacheMiss {
+			continue
+		}
+		if err != nil {
+			c.Logger.Errorf("cached: Error getting cached %s result, key: %s, err: %v, TraceID: %s", op, ck, err, traceID)
+			break
+		}
+		if ck == notFoundKey {
+			return v, errors.Wrapf(notFound, "cached response: %s", b)
+		}
+		if err = json.Unmarshal(b, &v); err == nil {
+			return v, nil
+		}
+		c.Logger.Errorf("cached: Error unmarshalling cached %s result, key: %s, err: %v, TraceID: %s", op, ck, err, traceID)
+	}
+
+	v, err := get()
+	if err != nil {
+		if notFound != nil && errors.Is(err, notFound) {
+			if ttl := c.cacheTTL(CacheOpNotFound, site); ttl > 0 {
+				if err := c.Cache.Set(cacheCtx, notFoundKey, []byte(err.Error()), ttl); err != nil {
+					c.Logger.Errorf("cached: Error setting cached %s not found response, key: %s, err: %v, TraceID: %s", op, notFoundKey, err, traceID)
+				}
+			}
+		}
+		return v, err
+	}
+
+	ttl := c.cacheTTL(op, site)
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Len() == 0 {
+		if ttl = c.cacheTTL(CacheOpNotFound, site); ttl <= 0 {
+			return v, nil
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		c.Logger.Errorf("cached: Error marshalling %s result, key: %s, err: %v, TraceID: %s", op, k, err, traceID)
+	} else if err = c.Cache.Set(cacheCtx, k, b, ttl); err != nil {
+		c.Logger.Errorf("cached: Error setting cached %s result, key: %s, err: %v, TraceID: %s", op, k, err, traceID)
+	}
+	return v, nil
+}
+
+// CacheInvalidateItem removes the cached item of url on site along with a cached not found response,
+// so the next GetItem requests the site.
+func (c Client) CacheInvalidateItem(site string, url string) error {
+	if c.Cache == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+	return c.Cache.Del(ctx, cacheKey(CacheOpGetItem, site, url), cacheKey(CacheOpNotFound, site, CacheOpGetItem+":"+url))
+}
@@ -1,14 +1,37 @@
 package client
 
 import (
+	"context"
+	"github.com/lestrrat-go/jwx/v2/jwk"
 	"io"
 	"net/http"
+	"time"
 )
 
 type Client struct {
 	*http.Client
-	FCMKey string
-	Logger logger
+	FCMKey            string
+	FCMCredentials    *FCMCredentials
+	GoogleClientIDs   []string
+	GoogleKeySet      jwk.Set
+	TelegramBotToken  string
+	MidtransServerKey string
+	GoUPCAPIKey       string
+	// SMTP is nil when sending emails is not enabled.
+	SMTP *SMTPCredentials
+	// Ebay is nil when eBay is not enabled.
+	Ebay         *EbayCredentials
+	Fingerprints *SiteFingerprints
+	Headless     *HeadlessBrowser
+	// VisionURL is the vision backend turning product photos into search queries, empty when there is none.
+	VisionURL string
+	Limiters  *SiteLimiters
+	// Cache is nil when site responses are not cached, CacheTTLs overrides DefaultCacheTTLs per operation.
+	Cache     Cache
+	CacheTTLs map[string]time.Duration
+	// Flights is nil when concurrent requests for the same product are not deduplicated.
+	Flights *SingleFlight
+	Logger  logger
 }
 
 type logger interface {
@@ -18,12 +41,30 @@ type logger interface {
 	Errorf(format string, v ...any)
 }
 
-func newRequest(method string, url string, body io.Reader) (*http.Request, error) {
-	r, err := http.NewRequest(method, url, body)
+type traceIDKey struct{}
+
+// WithTraceID returns a copy of ctx with the trace ID of the request it serves, requests of a Client done with the
+// context are sent with it as their X-Request-ID header and their log lines include it.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID returns the trace ID of ctx, empty when it has none.
+func TraceID(ctx context.Context) string {
+	tid, _ := ctx.Value(traceIDKey{}).(string)
+	return tid
+}
+
+// newRequest returns a request cancelled when ctx is done, with the trace ID of ctx as its X-Request-ID header.
+func newRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 	setDefaultRequestHeader(r)
+	if tid := TraceID(ctx); tid != "" {
+		r.Header.Set("X-Request-ID", tid)
+	}
 	return r, nil
 }
 
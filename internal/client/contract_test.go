@@ -0,0 +1,136 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "write the parsed contract fixtures to their golden files")
+
+// contractDir is the directory of the recorded site responses, the fixtures, and the golden files of their parsed results.
+var contractDir = filepath.Join("testdata", "contract")
+
+// TestContract runs the site parsers on the recorded site responses and compares the results to their golden files,
+// -update rewrites the golden files.
+func TestContract(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		parse   func(fixture []byte) (any, error)
+	}{
+		{name: "shopee/item", fixture: "shopee_item.json", parse: contractShopeeItem},
+		{name: "shopee/item_variants", fixture: "shopee_item_variants.json", parse: contractShopeeItem},
+		{name: "tokopedia/product_page", fixture: "tokopedia_product_page.html", parse: contractTokopediaProductPage},
+		{name: "tokopedia/product_page_no_stock", fixture: "tokopedia_product_page_no_stock.html",
+			parse: contractTokopediaProductPage},
+		{name: "blibli/description", fixture: "blibli_description.json", parse: contractBlibliDescription},
+		{name: "blibli/sold", fixture: "blibli_sold.json", parse: contractBlibliSold},
+		{name: "ebay/item", fixture: "ebay_item.json", parse: contractEbayItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, err := os.ReadFile(filepath.Join(contractDir, tt.fixture))
+			if err != nil {
+				t.Fatalf("error reading fixture: %v", err)
+			}
+			result, err := tt.parse(fixture)
+			if err != nil {
+				t.Fatalf("error parsing fixture: %v", err)
+			}
+			got, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatalf("error marshalling result: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := tt.fixture + ".golden.json"
+			if *update {
+				if err = os.WriteFile(filepath.Join(contractDir, golden), got, 0644); err != nil {
+					t.Fatalf("error writing golden file: %v", err)
+				}
+				return
+			}
+			want, err := os.ReadFile(filepath.Join(contractDir, golden))
+			if err != nil {
+				t.Fatalf("error reading golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("result differs from %s\n%s", golden, contractDiff(string(want), string(got)))
+			}
+		})
+	}
+}
+
+// contractDiff returns the first differing line of want and got.
+func contractDiff(want string, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("  line %d\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+// contractShopeeItem parses a ShopeeItemAPI response body.
+func contractShopeeItem(fixture []byte) (any, error) {
+	var resp shopeeItemResponse
+	if err := json.Unmarshal(fixture, &resp); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling ShopeeItemAPI response")
+	}
+	if resp.Error != 0 || resp.Data == nil {
+		return nil, errors.Errorf("ShopeeItemAPI response has no data, error: %d", resp.Error)
+	}
+	return resp.Data.toItem(), nil
+}
+
+// contractTokopediaProductPage parses a Tokopedia product page.
+func contractTokopediaProductPage(fixture []byte) (any, error) {
+	return tokopediaParseProductPage(fixture)
+}
+
+// contractBlibliDescription parses the description in a BlibliProductDescriptionAPI response body.
+func contractBlibliDescription(fixture []byte) (any, error) {
+	var resp blibliProductDescriptionResponse
+	if err := json.Unmarshal(fixture, &resp); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling BlibliProductDescriptionAPI response")
+	}
+	return blibliDescriptionParser(resp.Data.Value)
+}
+
+// contractBlibliSold parses a JSON array of sold range count IDs seen in Blibli product responses,
+// the result maps each ID to its parsed sold count.
+func contractBlibliSold(fixture []byte) (any, error) {
+	var ids []string
+	if err := json.Unmarshal(fixture, &ids); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling sold range count IDs")
+	}
+	sold := make(map[string]int, len(ids))
+	for _, id := range ids {
+		sold[id] = blibliSoldParser(id)
+	}
+	return sold, nil
+}
+
+// contractEbayItem parses an EbayItemAPI response body.
+func contractEbayItem(fixture []byte) (any, error) {
+	var ei ebayItem
+	if err := json.Unmarshal(fixture, &ei); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling EbayItemAPI response")
+	}
+	return ei.toItem(ebayMarketplaceHosts["EBAY_US"])
+}
@@ -0,0 +1,320 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEbay = errors.New("eBay error")
+var ErrEbayItemNotFound = errors.New("eBay item not found")
+
+const ebayScope = "https://api.ebay.com/oauth/api_scope"
+
+// ebayMarketplaceHosts are the hosts of the item pages of the supported eBay marketplaces, by marketplace ID.
+var ebayMarketplaceHosts = map[string]string{
+	"EBAY_AT": "www.ebay.at",
+	"EBAY_AU": "www.ebay.com.au",
+	"EBAY_CA": "www.ebay.ca",
+	"EBAY_CH": "www.ebay.ch",
+	"EBAY_DE": "www.ebay.de",
+	"EBAY_ES": "www.ebay.es",
+	"EBAY_FR": "www.ebay.fr",
+	"EBAY_GB": "www.ebay.co.uk",
+	"EBAY_HK": "www.ebay.com.hk",
+	"EBAY_IE": "www.ebay.ie",
+	"EBAY_IT": "www.ebay.it",
+	"EBAY_MY": "www.ebay.com.my",
+	"EBAY_NL": "www.ebay.nl",
+	"EBAY_PH": "www.ebay.ph",
+	"EBAY_PL": "www.ebay.pl",
+	"EBAY_SG": "www.ebay.com.sg",
+	"EBAY_US": "www.ebay.com",
+}
+
+// EbayCredentials holds the eBay application keys and caches the application access token created from them.
+type EbayCredentials struct {
+	clientID      string
+	clientSecret  string
+	marketplaceID string
+	// host is the host of the item pages of the marketplace, e.g. www.ebay.com.
+	host string
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+// NewEbayCredentials creates the credentials of an eBay application, marketplaceID is the eBay marketplace
+// items are searched on, e.g. EBAY_US.
+func NewEbayCredentials(clientID string, clientSecret string, marketplaceID string) (*EbayCredentials, error) {
+	if marketplaceID == "" {
+		marketplaceID = "EBAY_US"
+	}
+	host, ok := ebayMarketplaceHosts[marketplaceID]
+	if !ok {
+		return nil, errors.Errorf("unsupported eBay marketplace: %s", marketplaceID)
+	}
+	return &EbayCredentials{clientID: clientID, clientSecret: clientSecret, marketplaceID: marketplaceID, host: host}, nil
+}
+
+type ebayTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type ebayAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type ebayItem struct {
+	LegacyItemID string     `json:"legacyItemId"`
+	Title        string     `json:"title"`
+	Price        ebayAmount `json:"price"`
+	Image        struct {
+		ImageURL string `json:"imageUrl"`
+	} `json:"image"`
+	ShortDescription string `json:"shortDescription"`
+	Seller           struct {
+		Username           string `json:"username"`
+		FeedbackPercentage string `json:"feedbackPercentage"`
+	} `json:"seller"`
+	ItemLocation struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	} `json:"itemLocation"`
+	EstimatedAvailabilities []struct {
+		EstimatedAvailableQuantity  int    `json:"estimatedAvailableQuantity"`
+		EstimatedSoldQuantity       int    `json:"estimatedSoldQuantity"`
+		EstimatedAvailabilityStatus string `json:"estimatedAvailabilityStatus"`
+	} `json:"estimatedAvailabilities"`
+	ReviewRating struct {
+		AverageRating string `json:"averageRating"`
+	} `json:"reviewRating"`
+	// CategoryPath is the category breadcrumb of the item separated by "|", only set on item details.
+	CategoryPath string `json:"categoryPath"`
+}
+
+type ebaySearchResponse struct {
+	Total         int        `json:"total"`
+	ItemSummaries []ebayItem `json:"itemSummaries"`
+}
+
+func (c Client) EbayEnabled() bool {
+	return c.Ebay != nil
+}
+
+// EbayHost returns the host of the item pages of the eBay marketplace, e.g. www.ebay.com, empty when eBay is not
+// enabled.
+func (c Client) EbayHost() string {
+	if c.Ebay == nil {
+		return ""
+	}
+	return c.Ebay.host
+}
+
+func (c Client) EbayGetItem(ctx context.Context, url string) (model.Item, error) {
+	return flight(ctx, c, CacheOpGetItem+":"+productKey(SiteEbay, url), func(ctx context.Context) (model.Item, error) {
+		return cached(ctx, c, CacheOpGetItem, SiteEbay, url, ErrEbayItemNotFound, func() (model.Item, error) {
+			return c.ebayGetItem(ctx, url)
+		})
+	})
+}
+
+func (c Client) ebayGetItem(ctx context.Context, urlStr string) (model.Item, error) {
+	var i model.Item
+	legacyID, ok := ebayGetLegacyItemID(urlStr)
+	if !ok {
+		return i, errors.Wrapf(ErrEbayItemNotFound, "error getting item ID from URL: %s", urlStr)
+	}
+	apiPath := "/buy/browse/v1/item/get_item_by_legacy_id?legacy_item_id=" + legacyID
+	body, status, err := c.ebayAPIGet(ctx, apiPath)
+	if err != nil {
+		return i, err
+	}
+	if status == http.StatusNotFound {
+		return i, errors.Wrapf(ErrEbayItemNotFound, "eBay item not found, status: %d, body:\n%s", status, misc.BytesLimit(body, 2000))
+	}
+	if status != http.StatusOK {
+		return i, errors.Wrapf(ErrEbay, "error getting data from EbayItemAPI, status: %d, body:\n%s", status, misc.BytesLimit(body, 2000))
+	}
+	var ei ebayItem
+	if err = json.Unmarshal(body, &ei); err != nil {
+		return i, errors.Wrapf(err, "error unmarshalling EbayItemAPI response body, body:\n%s", misc.BytesLimit(body, 2000))
+	}
+	return ei.toItem(c.Ebay.host)
+}
+
+func (c Client) EbaySearch(ctx context.Context, query string) ([]model.Item, error) {
+	return cached(ctx, c, CacheOpSearch, SiteEbay, query, nil, func() ([]model.Item, error) {
+		return c.ebaySearch(ctx, query)
+	})
+}
+
+func (c Client) ebaySearch(ctx context.Context, query string) ([]model.Item, error) {
+	var is []model.Item
+	apiPath := "/buy/browse/v1/item_summary/search?" + url.Values{
+		"q":      []string{query},
+		"limit":  []string{"10"},
+		"filter": []string{"buyingOptions:{FIXED_PRICE}"},
+	}.Encode()
+	body, status, err := c.ebayAPIGet(ctx, apiPath)
+	if err != nil {
+		return is, err
+	}
+	if status != http.StatusOK {
+		return is, errors.Wrapf(ErrEbay, "error getting data from EbaySearchAPI, status: %d, body:\n%s", status, misc.BytesLimit(body, 2000))
+	}
+	var searchResp ebaySearchResponse
+	if err = json.Unmarshal(body, &searchResp); err != nil {
+		return is, errors.Wrapf(err, "error unmarshalling EbaySearchAPI response body, body:\n%s", misc.BytesLimit(body, 2000))
+	}
+	for _, ei := range searchResp.ItemSummaries {
+		i, err := ei.toItem(c.Ebay.host)
+		if err != nil {
+			c.Logger.Debugf("ebaySearch: Skipping item, legacyItemId: %s, err: %v, TraceID: %s", ei.LegacyItemID, err, TraceID(ctx))
+			continue
+		}
+		is = append(is, i)
+	}
+	return is, nil
+}
+
+// ebayAPIGet requests apiPath of the eBay Browse API, it returns the response body and status.
+func (c Client) ebayAPIGet(ctx context.Context, apiPath string) ([]byte, int, error) {
+	if c.Ebay == nil {
+		return nil, 0, errors.Wrap(ErrEbay, "eBay is not enabled")
+	}
+	accessToken, err := c.ebayAccessToken(ctx)
+	if err != nil {
+		return nil, 0, errors.Wrapf(ErrEbay, "error getting access token, err: %v", err)
+	}
+	req, err := c.siteAPIRequest(ctx, SiteEbay, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error creating request from path: %s", apiPath)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-EBAY-C-MARKETPLACE-ID", c.Ebay.marketplaceID)
+	resp, err := c.doSite(SiteEbay, req)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error doing request:\n%#v", req)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("ebayAPIGet: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
+		}
+	}()
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 300000))
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error reading eBay API response body, status: %s, body:\n%s", resp.Status, body)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// ebayAccessToken returns the cached application access token, a new one is requested shortly before it expires.
+func (c Client) ebayAccessToken(ctx context.Context) (string, error) {
+	e := c.Ebay
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.accessToken != "" && time.Now().Before(e.expiry.Add(-time.Minute)) {
+		return e.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": []string{"client_credentials"}, "scope": []string{ebayScope}}
+	req, err := c.siteAPIRequest(ctx, SiteEbay, http.MethodPost, "/identity/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "error creating token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(e.clientID, e.clientSecret)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "error doing token request")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 100*1024))
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading token response body, status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("error getting token, status: %s, body:\n%s", resp.Status, misc.BytesLimit(body, 500))
+	}
+	var tokenResp ebayTokenResponse
+	if err = json.Unmarshal(body, &tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return "", errors.Errorf("invalid token response, status: %s, body:\n%s, err: %v", resp.Status, misc.BytesLimit(body, 500), err)
+	}
+	e.accessToken = tokenResp.AccessToken
+	e.expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return e.accessToken, nil
+}
+
+// toItem returns the Item of ei with the URL of its page on host.
+func (ei ebayItem) toItem(host string) (model.Item, error) {
+	price, err := model.ParsePrice(ei.Price.Value, ei.Price.Currency)
+	if err != nil {
+		return model.Item{}, errors.Wrapf(err, "invalid price of eBay item: %s", ei.LegacyItemID)
+	}
+	stock, sold := -1, 0
+	for _, a := range ei.EstimatedAvailabilities {
+		sold += a.EstimatedSoldQuantity
+		switch {
+		case a.EstimatedAvailableQuantity > 0:
+			stock = a.EstimatedAvailableQuantity
+		case a.EstimatedAvailabilityStatus == "OUT_OF_STOCK":
+			stock = 0
+		}
+	}
+	merchantRating, _ := strconv.ParseFloat(ei.Seller.FeedbackPercentage, 64)
+	rating, _ := strconv.ParseFloat(ei.ReviewRating.AverageRating, 64)
+	location := strings.Trim(ei.ItemLocation.City+", "+ei.ItemLocation.Country, ", ")
+	return model.Item{
+		Site:             "eBay",
+		MerchantID:       ei.Seller.Username,
+		MerchantName:     ei.Seller.Username,
+		MerchantLocation: location,
+		// The feedback percentage is scaled to the 5 star merchant ratings of the other sites.
+		MerchantRating: math.Round(merchantRating*5) / 100,
+		ProductID:      ei.LegacyItemID,
+		URL:            fmt.Sprintf("https://%s/itm/%s", host, ei.LegacyItemID),
+		Name:           ei.Title,
+		Price:          price,
+		Currency:       ei.Price.Currency,
+		Stock:          stock,
+		ImageURL:       ei.Image.ImageURL,
+		Description:    misc.StringLimit(ei.ShortDescription, 2500),
+		Rating:         rating,
+		Sold:           sold,
+		Categories:     model.ItemCategories(strings.Split(ei.CategoryPath, "|")),
+	}, nil
+}
+
+// ebayGetLegacyItemID returns the item ID of an eBay item URL, e.g. https://www.ebay.com/itm/title/123456789012.
+func ebayGetLegacyItemID(urlStr string) (string, bool) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return "", false
+	}
+	sp := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	if len(sp) < 2 || sp[0] != "itm" {
+		return "", false
+	}
+	id := sp[len(sp)-1]
+	if _, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return "", false
+	}
+	return id, true
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/pkg/errors"
 	"io"
@@ -19,9 +20,11 @@ type FCMSendResult struct {
 }
 
 type FCMSendRequest struct {
-	Notification    FCMNotification `json:"notification"`
-	Data            FCMData         `json:"data"`
-	RegistrationIDs []string        `json:"registration_ids"`
+	Notification FCMNotification `json:"notification"`
+	Data         FCMData         `json:"data"`
+	// MutableContent lets the iOS notification service extension download the image of an expanded notification.
+	MutableContent  bool     `json:"mutable_content,omitempty"`
+	RegistrationIDs []string `json:"registration_ids"`
 }
 
 type FCMNotification struct {
@@ -29,19 +32,47 @@ type FCMNotification struct {
 	Body        string `json:"body"`
 	ClickAction string `json:"click_action"`
 	Sound       string `json:"sound"`
+	// Image is the URL of the image shown in the expanded notification.
+	Image string `json:"image,omitempty"`
 }
 
+// FCMData is the data payload of a notification, the values are strings as FCM only accepts string data.
 type FCMData struct {
-	ItemID string `json:"item_id"`
+	ItemID         string `json:"item_id"`
+	Type           string `json:"type,omitempty"`
+	Action         string `json:"action,omitempty"`
+	ReplacementURL string `json:"replacement_url,omitempty"`
+	ImageURL       string `json:"image_url,omitempty"`
+	// OldPrice and NewPrice are formatted prices, e.g. "Rp. 15000", OldPrice is empty when it is not known.
+	OldPrice string `json:"old_price,omitempty"`
+	NewPrice string `json:"new_price,omitempty"`
+	// DeepLink is the URI of the app screen opened by the notification, e.g. pricetracker://item/<item_id>.
+	DeepLink string `json:"deep_link,omitempty"`
+	// Items are the Items of a combined notification as a JSON array of FCMDataItem, as FCM data values are strings.
+	Items string `json:"items,omitempty"`
 }
 
-func (c Client) FCMSendNotification(fcmReqBody FCMSendRequest) (FCMSendResponse, error) {
+// FCMDataItem is an Item of a combined notification, with its own image, prices and deep link.
+type FCMDataItem struct {
+	ItemID   string `json:"item_id"`
+	ImageURL string `json:"image_url,omitempty"`
+	OldPrice string `json:"old_price,omitempty"`
+	NewPrice string `json:"new_price,omitempty"`
+	DeepLink string `json:"deep_link,omitempty"`
+}
+
+// FCMSendNotification sends through the FCM HTTP v1 API when FCMCredentials is set,
+// falling back to the legacy HTTP API otherwise.
+func (c Client) FCMSendNotification(ctx context.Context, fcmReqBody FCMSendRequest) (FCMSendResponse, error) {
+	if c.fcmV1Enabled() {
+		return c.fcmV1SendNotification(ctx, fcmReqBody)
+	}
 	reqBody, err := json.Marshal(fcmReqBody)
 	if err != nil {
 		return FCMSendResponse{}, errors.Wrapf(err, "FCMSendNotification: FCMSendRequest JSON marshalling error, req: %+v", fcmReqBody)
 	}
 
-	req, err := newRequest(http.MethodPost, "https://fcm.googleapis.com/fcm/send", bytes.NewReader(reqBody))
+	req, err := newRequest(ctx, http.MethodPost, "https://fcm.googleapis.com/fcm/send", bytes.NewReader(reqBody))
 	if err != nil {
 		return FCMSendResponse{}, errors.Wrapf(err, "FCMSendNotification: error creating HTTP request from body:\n%s", reqBody)
 	}
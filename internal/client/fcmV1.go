@@ -0,0 +1,312 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fcmV1Scope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmV1SendConcurrency limits concurrent per-token requests, FCM HTTP v1 has no multicast.
+const fcmV1SendConcurrency = 8
+
+const (
+	FCMErrorNotRegistered       = "NotRegistered"
+	FCMErrorInvalidRegistration = "InvalidRegistration"
+	FCMErrorUnregistered        = "UNREGISTERED"
+	FCMErrorInvalidArgument     = "INVALID_ARGUMENT"
+)
+
+// FCMCredentials holds a Firebase service account and caches the OAuth2 access token created from it.
+type FCMCredentials struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	privateKey  jwk.Key
+
+	mu          sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+type fcmServiceAccount struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+func NewFCMCredentials(serviceAccountJSON []byte) (*FCMCredentials, error) {
+	var sa fcmServiceAccount
+	if err := json.Unmarshal(serviceAccountJSON, &sa); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling service account JSON")
+	}
+	if sa.Type != "service_account" || sa.ProjectID == "" || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, errors.Errorf("invalid service account, type: %s, project_id: %s, client_email: %s",
+			sa.Type, sa.ProjectID, sa.ClientEmail)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	key, err := jwk.ParseKey([]byte(sa.PrivateKey), jwk.WithPEM(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing service account private key")
+	}
+	return &FCMCredentials{
+		projectID:   sa.ProjectID,
+		clientEmail: sa.ClientEmail,
+		tokenURI:    sa.TokenURI,
+		privateKey:  key,
+	}, nil
+}
+
+type fcmV1Request struct {
+	Message fcmV1Message `json:"message"`
+}
+
+type fcmV1Message struct {
+	Token        string             `json:"token"`
+	Notification fcmV1Notification  `json:"notification"`
+	Data         map[string]string  `json:"data,omitempty"`
+	Android      fcmV1AndroidConfig `json:"android"`
+	APNS         *fcmV1APNSConfig   `json:"apns,omitempty"`
+}
+
+type fcmV1Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Image string `json:"image,omitempty"`
+}
+
+// fcmV1APNSConfig marks iOS notifications with an image as mutable,
+// so the notification service extension of the app can download it.
+type fcmV1APNSConfig struct {
+	Payload struct {
+		APS struct {
+			MutableContent int `json:"mutable-content"`
+		} `json:"aps"`
+	} `json:"payload"`
+	FCMOptions struct {
+		Image string `json:"image"`
+	} `json:"fcm_options"`
+}
+
+type fcmV1AndroidConfig struct {
+	Notification fcmV1AndroidNotification `json:"notification"`
+}
+
+type fcmV1AndroidNotification struct {
+	ClickAction string `json:"click_action,omitempty"`
+	Sound       string `json:"sound,omitempty"`
+}
+
+type fcmV1ErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type      string `json:"@type"`
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// Unregistered reports whether the result indicates that the registration token is no longer valid.
+func (r FCMSendResult) Unregistered() bool {
+	if r.Error == nil {
+		return false
+	}
+	switch *r.Error {
+	case FCMErrorNotRegistered, FCMErrorInvalidRegistration, FCMErrorUnregistered:
+		return true
+	}
+	return false
+}
+
+func (c Client) fcmV1Enabled() bool {
+	return c.FCMCredentials != nil
+}
+
+// fcmV1SendNotification sends the request to every registration ID through the FCM HTTP v1 API,
+// results are in the same order as the registration IDs like the legacy API.
+func (c Client) fcmV1SendNotification(ctx context.Context, fcmReqBody FCMSendRequest) (FCMSendResponse, error) {
+	accessToken, err := c.fcmV1AccessToken(ctx)
+	if err != nil {
+		return FCMSendResponse{}, errors.Wrap(err, "fcmV1SendNotification: error getting access token")
+	}
+	dataJSON, err := json.Marshal(fcmReqBody.Data)
+	if err != nil {
+		return FCMSendResponse{}, errors.Wrapf(err, "fcmV1SendNotification: FCMData JSON marshalling error, data: %+v", fcmReqBody.Data)
+	}
+	var data map[string]string
+	if err = json.Unmarshal(dataJSON, &data); err != nil {
+		return FCMSendResponse{}, errors.Wrapf(err, "fcmV1SendNotification: FCMData JSON unmarshalling error, data: %s", dataJSON)
+	}
+
+	results := make([]FCMSendResult, len(fcmReqBody.RegistrationIDs))
+	sem := make(chan struct{}, fcmV1SendConcurrency)
+	var wg sync.WaitGroup
+	for idx, token := range fcmReqBody.RegistrationIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, token string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			msg := fcmV1Message{
+				Token: token,
+				Notification: fcmV1Notification{
+					Title: fcmReqBody.Notification.Title,
+					Body:  fcmReqBody.Notification.Body,
+					Image: fcmReqBody.Notification.Image,
+				},
+				Data: data,
+				Android: fcmV1AndroidConfig{Notification: fcmV1AndroidNotification{
+					ClickAction: fcmReqBody.Notification.ClickAction,
+					Sound:       fcmReqBody.Notification.Sound,
+				}},
+			}
+			if fcmReqBody.MutableContent && fcmReqBody.Notification.Image != "" {
+				msg.APNS = &fcmV1APNSConfig{}
+				msg.APNS.Payload.APS.MutableContent = 1
+				msg.APNS.FCMOptions.Image = fcmReqBody.Notification.Image
+			}
+			if errCode, err := c.fcmV1Send(ctx, accessToken, msg); err != nil {
+				c.Logger.Debugf("fcmV1SendNotification: Error sending message, err: %v", err)
+				results[idx].Error = &errCode
+			}
+		}(idx, token)
+	}
+	wg.Wait()
+
+	resp := FCMSendResponse{Results: results}
+	for _, r := range results {
+		if r.Error == nil {
+			resp.Success++
+		} else {
+			resp.Failure++
+		}
+	}
+	return resp, nil
+}
+
+// fcmV1Send sends a single message and returns the FCM error code on failure.
+func (c Client) fcmV1Send(ctx context.Context, accessToken string, msg fcmV1Message) (string, error) {
+	reqBody, err := json.Marshal(fcmV1Request{Message: msg})
+	if err != nil {
+		return "INTERNAL", errors.Wrap(err, "fcmV1Send: request JSON marshalling error")
+	}
+	apiURL := fmt.Sprintf("https://fcm.googleapis.com/v1/projects/%s/messages:send", c.FCMCredentials.projectID)
+	req, err := newRequest(ctx, http.MethodPost, apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return "INTERNAL", errors.Wrap(err, "fcmV1Send: error creating HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "UNAVAILABLE", errors.Wrap(err, "fcmV1Send: error doing request")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 100*1024))
+	if err != nil {
+		return "UNAVAILABLE", errors.Wrapf(err, "fcmV1Send: error reading response body, status: %s", resp.Status)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return "", nil
+	}
+
+	errResp := fcmV1ErrorResponse{}
+	if err = json.Unmarshal(respBody, &errResp); err != nil {
+		return "UNKNOWN", errors.Wrapf(err, "fcmV1Send: error unmarshalling error response, status: %s, body:\n%s",
+			resp.Status, respBody)
+	}
+	errCode := errResp.Error.Status
+	for _, d := range errResp.Error.Details {
+		if strings.HasSuffix(d.Type, "google.firebase.fcm.v1.FcmError") && d.ErrorCode != "" {
+			errCode = d.ErrorCode
+		}
+	}
+	if errCode == FCMErrorInvalidArgument && strings.Contains(errResp.Error.Message, "registration token") {
+		errCode = FCMErrorUnregistered
+	}
+	return errCode, errors.Errorf("fcmV1Send: FCM error, status: %s, code: %s, message: %s",
+		resp.Status, errCode, errResp.Error.Message)
+}
+
+func (c Client) fcmV1AccessToken(ctx context.Context) (string, error) {
+	creds := c.FCMCredentials
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	if creds.accessToken != "" && time.Now().Before(creds.expiry) {
+		return creds.accessToken, nil
+	}
+
+	now := time.Now()
+	token, err := jwt.NewBuilder().
+		Issuer(creds.clientEmail).
+		Audience([]string{creds.tokenURI}).
+		IssuedAt(now).
+		Expiration(now.Add(time.Hour)).
+		Claim("scope", fcmV1Scope).
+		Build()
+	if err != nil {
+		return "", errors.Wrap(err, "error building service account JWT")
+	}
+	assertion, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, creds.privateKey))
+	if err != nil {
+		return "", errors.Wrap(err, "error signing service account JWT")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", string(assertion))
+	req, err := newRequest(ctx, http.MethodPost, creds.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "error creating token HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "error doing token request")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 100*1024))
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading token response body, status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("token request failed, status: %s, body:\n%s", resp.Status, respBody)
+	}
+	tokenResp := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err = json.Unmarshal(respBody, &tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return "", errors.Errorf("invalid token response, status: %s, err: %v", resp.Status, err)
+	}
+
+	creds.accessToken = tokenResp.AccessToken
+	creds.expiry = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+	return creds.accessToken, nil
+}
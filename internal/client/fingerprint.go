@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	SiteShopee    = "shopee"
+	SiteTokopedia = "tokopedia"
+	SiteBlibli    = "blibli"
+	SiteEbay      = "ebay"
+)
+
+type SiteFingerprint struct {
+	APIHost          string            `toml:"api_host" json:"api_host"`
+	Headers          map[string]string `toml:"headers" json:"headers"`
+	Cookies          map[string]string `toml:"cookies" json:"cookies"`
+	ShareLinkHeaders map[string]string `toml:"share_link_headers" json:"share_link_headers"`
+}
+
+var defaultSiteFingerprints = map[string]SiteFingerprint{
+	SiteShopee: {
+		APIHost: "https://shopee.co.id",
+		Cookies: map[string]string{"SPC_U": "-", "SPC_F": "-"},
+	},
+	SiteTokopedia: {
+		APIHost:          "https://gql.tokopedia.com",
+		Headers:          map[string]string{"Origin": "https://www.tokopedia.com"},
+		ShareLinkHeaders: map[string]string{"User-Agent": "Mozilla/5.0 Windows"},
+	},
+	SiteBlibli: {
+		APIHost:          "https://www.blibli.com",
+		Headers:          map[string]string{"Accept-Language": "en"},
+		ShareLinkHeaders: map[string]string{"User-Agent": "Mozilla/5.0 Windows"},
+	},
+	SiteEbay: {
+		APIHost: "https://api.ebay.com",
+	},
+}
+
+type SiteFingerprints struct {
+	file string
+
+	mu      sync.RWMutex
+	sites   map[string]SiteFingerprint
+	modTime time.Time
+}
+
+func NewSiteFingerprints(file string) (*SiteFingerprints, error) {
+	sf := &SiteFingerprints{
+		file:  file,
+		sites: defaultSiteFingerprints,
+	}
+	if _, err := sf.Reload(); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// Reload reads the fingerprints file again if it has been modified since the last load,
+// sites missing from the file keep their built-in defaults.
+func (sf *SiteFingerprints) Reload() (bool, error) {
+	if sf.file == "" {
+		return false, nil
+	}
+	fi, err := os.Stat(sf.file)
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting site fingerprints file info: %s", sf.file)
+	}
+	sf.mu.RLock()
+	unchanged := fi.ModTime().Equal(sf.modTime)
+	sf.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var fileSites map[string]SiteFingerprint
+	if _, err = toml.DecodeFile(sf.file, &fileSites); err != nil {
+		return false, errors.Wrapf(err, "error decoding site fingerprints file: %s", sf.file)
+	}
+
+	sites := make(map[string]SiteFingerprint, len(defaultSiteFingerprints))
+	for site, d := range defaultSiteFingerprints {
+		sites[site] = d
+	}
+	for site, fp := range fileSites {
+		d, ok := defaultSiteFingerprints[site]
+		if !ok {
+			return false, errors.Errorf("unknown site in site fingerprints file: %s", site)
+		}
+		if fp.APIHost == "" {
+			fp.APIHost = d.APIHost
+		}
+		if u, err := url.Parse(fp.APIHost); err != nil || u.Scheme != "https" || u.Host == "" || u.Path != "" {
+			return false, errors.Errorf("invalid api_host for site %s: %s", site, fp.APIHost)
+		}
+		if fp.Headers == nil {
+			fp.Headers = d.Headers
+		}
+		if fp.Cookies == nil {
+			fp.Cookies = d.Cookies
+		}
+		if fp.ShareLinkHeaders == nil {
+			fp.ShareLinkHeaders = d.ShareLinkHeaders
+		}
+		sites[site] = fp
+	}
+
+	sf.mu.Lock()
+	sf.sites = sites
+	sf.modTime = fi.ModTime()
+	sf.mu.Unlock()
+	return true, nil
+}
+
+func (sf *SiteFingerprints) Get(site string) SiteFingerprint {
+	if sf == nil {
+		return defaultSiteFingerprints[site]
+	}
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.sites[site]
+}
+
+func (sf *SiteFingerprints) All() map[string]SiteFingerprint {
+	if sf == nil {
+		return defaultSiteFingerprints
+	}
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.sites
+}
+
+// SiteFingerprints returns the fingerprints currently used for every site.
+func (c Client) SiteFingerprints() map[string]SiteFingerprint {
+	return c.Fingerprints.All()
+}
+
+// SiteFingerprintsReload reloads the site fingerprints file, see SiteFingerprints.Reload.
+func (c Client) SiteFingerprintsReload() (bool, error) {
+	if c.Fingerprints == nil {
+		return false, nil
+	}
+	return c.Fingerprints.Reload()
+}
+
+func (c Client) siteAPIRequest(
+	ctx context.Context, site string, method string, path string, body io.Reader,
+) (*http.Request, error) {
+	fp := c.Fingerprints.Get(site)
+	req, err := newRequest(ctx, method, fp.APIHost+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range fp.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range fp.Cookies {
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+	return req, nil
+}
+
+func (c Client) siteShareLinkRequest(ctx context.Context, site string, url string) (*http.Request, error) {
+	req, err := newRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Fingerprints.Get(site).ShareLinkHeaders {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+const googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var ErrGoogleIDTokenInvalid = errors.New("Google ID token invalid")
+
+type GoogleIDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+func NewGoogleKeySet(ctx context.Context, httpClient *http.Client) (jwk.Set, error) {
+	c := jwk.NewCache(ctx)
+	if err := c.Register(googleCertsURL, jwk.WithHTTPClient(httpClient), jwk.WithMinRefreshInterval(time.Hour)); err != nil {
+		return nil, errors.Wrapf(err, "error registering Google certs URL: %s", googleCertsURL)
+	}
+	return jwk.NewCachedSet(c, googleCertsURL), nil
+}
+
+// GoogleEnabled reports whether Google sign-in is configured.
+func (c Client) GoogleEnabled() bool {
+	return c.GoogleKeySet != nil
+}
+
+func (c Client) GoogleVerifyIDToken(ctx context.Context, idToken string) (GoogleIDTokenClaims, error) {
+	var claims GoogleIDTokenClaims
+	if c.GoogleKeySet == nil || len(c.GoogleClientIDs) == 0 {
+		return claims, errors.New("GoogleVerifyIDToken: Google sign-in is not configured")
+	}
+	token, err := jwt.Parse([]byte(idToken),
+		jwt.WithKeySet(c.GoogleKeySet),
+		jwt.WithValidate(true),
+		jwt.WithContext(ctx),
+	)
+	if err != nil {
+		return claims, errors.Wrapf(ErrGoogleIDTokenInvalid, "error parsing ID token, err: %v", err)
+	}
+	if token.Issuer() != "accounts.google.com" && token.Issuer() != "https://accounts.google.com" {
+		return claims, errors.Wrapf(ErrGoogleIDTokenInvalid, "invalid issuer: %s", token.Issuer())
+	}
+	var audienceValid bool
+	for _, aud := range token.Audience() {
+		for _, clientID := range c.GoogleClientIDs {
+			if aud == clientID {
+				audienceValid = true
+			}
+		}
+	}
+	if !audienceValid {
+		return claims, errors.Wrapf(ErrGoogleIDTokenInvalid, "invalid audience: %v", token.Audience())
+	}
+	if token.Subject() == "" {
+		return claims, errors.Wrap(ErrGoogleIDTokenInvalid, "subject is empty")
+	}
+
+	claims.Subject = token.Subject()
+	if email, ok := token.Get("email"); ok {
+		claims.Email, _ = email.(string)
+	}
+	if emailVerified, ok := token.Get("email_verified"); ok {
+		switch v := emailVerified.(type) {
+		case bool:
+			claims.EmailVerified = v
+		case string:
+			claims.EmailVerified = v == "true"
+		}
+	}
+	if name, ok := token.Get("name"); ok {
+		claims.Name, _ = name.(string)
+	}
+	return claims, nil
+}
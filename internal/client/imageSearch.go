@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+var ErrVision = errors.New("vision backend error")
+
+type shopeeImageUploadResponse struct {
+	Error int `json:"error"`
+	Data  struct {
+		ImageID string `json:"image_id"`
+	} `json:"data"`
+}
+
+type visionResponse struct {
+	Query string `json:"query"`
+}
+
+// imageSearchKey is the cache key of an image search, image is identified by its SHA-256 hash.
+func imageSearchKey(image []byte) string {
+	h := sha256.Sum256(image)
+	return "image:" + hex.EncodeToString(h[:])
+}
+
+// ShopeeSearchByImage searches Shopee for the items looking like the product in image.
+func (c Client) ShopeeSearchByImage(ctx context.Context, image []byte) ([]model.Item, error) {
+	return cached(ctx, c, CacheOpSearch, SiteShopee, imageSearchKey(image), nil, func() ([]model.Item, error) {
+		return c.shopeeSearchByImage(ctx, image)
+	})
+}
+
+func (c Client) shopeeSearchByImage(ctx context.Context, image []byte) ([]model.Item, error) {
+	imageID, err := c.shopeeUploadSearchImage(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	apiPath := "/api/v4/search/search_items"
+	req, err := c.siteAPIRequest(ctx, SiteShopee, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request from path: %s", apiPath)
+	}
+	req.URL.RawQuery = url.Values{
+		"by":        []string{"relevancy"},
+		"image_id":  []string{imageID},
+		"limit":     []string{"10"},
+		"newest":    []string{"0"},
+		"order":     []string{"desc"},
+		"page_type": []string{"search"},
+		"scenario":  []string{"PAGE_IMAGE_SEARCH"},
+		"version":   []string{"2"},
+	}.Encode()
+
+	resp, err := c.doSite(SiteShopee, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error doing request:\n%#v", req)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("shopeeSearchByImage: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
+		}
+	}()
+
+	shopeeSearchResp := shopeeSearchResponse{}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 300000))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading Shopee image search response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 500))
+	}
+	if err = json.Unmarshal(body, &shopeeSearchResp); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshalling Shopee image search response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 500))
+	}
+	if len(shopeeSearchResp.Items) == 0 && !shopeeSearchResp.NoMore {
+		return nil, errors.Wrapf(ErrShopee, "error getting data from Shopee image search, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 500))
+	}
+
+	var is []model.Item
+	for _, searchItem := range shopeeSearchResp.Items {
+		if searchItem.AdsID != 0 {
+			continue
+		}
+		is = append(is, searchItem.ItemBasic.toItem())
+	}
+	return is, nil
+}
+
+// shopeeUploadSearchImage uploads image for an image search and returns its ID.
+func (c Client) shopeeUploadSearchImage(ctx context.Context, image []byte) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("image", "image")
+	if err != nil {
+		return "", errors.Wrap(err, "error creating multipart image field")
+	}
+	if _, err = fw.Write(image); err != nil {
+		return "", errors.Wrap(err, "error writing multipart image field")
+	}
+	if err = mw.Close(); err != nil {
+		return "", errors.Wrap(err, "error closing multipart writer")
+	}
+
+	apiPath := "/api/v4/image_search/upload_image"
+	req, err := c.siteAPIRequest(ctx, SiteShopee, http.MethodPost, apiPath, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating request from path: %s", apiPath)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := c.doSite(SiteShopee, req)
+	if err != nil {
+		return "", errors.Wrapf(err, "error doing request:\n%#v", req)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("shopeeUploadSearchImage: Error closing response body, resp:\n%#v,\nerr: %v, TraceID: %s", resp, err, TraceID(ctx))
+		}
+	}()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10000))
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading Shopee image upload response body, status: %s", resp.Status)
+	}
+	uploadResp := shopeeImageUploadResponse{}
+	if err = json.Unmarshal(respBody, &uploadResp); err != nil {
+		return "", errors.Wrapf(err, "error unmarshalling Shopee image upload response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	if uploadResp.Error != 0 || uploadResp.Data.ImageID == "" {
+		return "", errors.Wrapf(ErrShopee, "error uploading image to Shopee, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	return uploadResp.Data.ImageID, nil
+}
+
+// VisionEnabled reports whether a vision backend is configured.
+func (c Client) VisionEnabled() bool {
+	return c.VisionURL != ""
+}
+
+// VisionQuery sends image to the vision backend and returns the search query it describes the product with.
+// The backend receives the image as the request body and responds with {"query": "..."}.
+func (c Client) VisionQuery(ctx context.Context, image []byte, contentType string) (string, error) {
+	if !c.VisionEnabled() {
+		return "", errors.Wrap(ErrVision, "vision backend URL is not set")
+	}
+	req, err := newRequest(ctx, http.MethodPost, c.VisionURL, bytes.NewReader(image))
+	if err != nil {
+		return "", errors.Wrap(err, "VisionQuery: error creating HTTP request")
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(ErrVision, "VisionQuery: error doing request, err: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("VisionQuery: Error closing response body, err: %v, TraceID: %s", err, TraceID(ctx))
+		}
+	}()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10000))
+	if err != nil {
+		return "", errors.Wrapf(err, "VisionQuery: error reading response body, status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrapf(ErrVision, "VisionQuery: status: %s, body:\n%s", resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	vr := visionResponse{}
+	if err = json.Unmarshal(respBody, &vr); err != nil {
+		return "", errors.Wrapf(err, "VisionQuery: error unmarshalling response body, body:\n%s", misc.BytesLimit(respBody, 500))
+	}
+	return strings.TrimSpace(vr.Query), nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"math"
+	"strconv"
+)
+
+const (
+	MidtransTransactionStatusCapture           = "capture"
+	MidtransTransactionStatusSettlement        = "settlement"
+	MidtransTransactionStatusDeny              = "deny"
+	MidtransTransactionStatusCancel            = "cancel"
+	MidtransTransactionStatusExpire            = "expire"
+	MidtransTransactionStatusRefund            = "refund"
+	MidtransTransactionStatusPartialRefund     = "partial_refund"
+	MidtransTransactionStatusChargeback        = "chargeback"
+	MidtransTransactionStatusPartialChargeback = "partial_chargeback"
+	MidtransFraudStatusAccept                  = "accept"
+)
+
+type MidtransNotification struct {
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	TransactionTime   string `json:"transaction_time"`
+	OrderID           string `json:"order_id"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"`
+	PaymentType       string `json:"payment_type"`
+	FraudStatus       string `json:"fraud_status"`
+	SignatureKey      string `json:"signature_key"`
+}
+
+// Paid reports whether the notification marks the transaction as successfully paid.
+func (n MidtransNotification) Paid() bool {
+	switch n.TransactionStatus {
+	case MidtransTransactionStatusSettlement:
+		return true
+	case MidtransTransactionStatusCapture:
+		return n.FraudStatus == MidtransFraudStatusAccept
+	}
+	return false
+}
+
+// Reversed reports whether the notification marks the transaction as not or no longer paid.
+func (n MidtransNotification) Reversed() bool {
+	switch n.TransactionStatus {
+	case MidtransTransactionStatusDeny, MidtransTransactionStatusCancel, MidtransTransactionStatusExpire,
+		MidtransTransactionStatusRefund, MidtransTransactionStatusPartialRefund,
+		MidtransTransactionStatusChargeback, MidtransTransactionStatusPartialChargeback:
+		return true
+	}
+	return false
+}
+
+// GrossAmountIDR returns the gross_amount of n, e.g. "150000.00", in whole IDR.
+func (n MidtransNotification) GrossAmountIDR() (int, error) {
+	amount, err := strconv.ParseFloat(n.GrossAmount, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error parsing gross_amount: %s", n.GrossAmount)
+	}
+	return int(math.Round(amount)), nil
+}
+
+func (c Client) MidtransEnabled() bool {
+	return c.MidtransServerKey != ""
+}
+
+// MidtransVerifyNotification checks the signature_key of n, which is
+// SHA512(order_id + status_code + gross_amount + server_key).
+func (c Client) MidtransVerifyNotification(n MidtransNotification) bool {
+	if !c.MidtransEnabled() {
+		return false
+	}
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + c.MidtransServerKey))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(n.SignatureKey)) == 1
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+)
+
+// TestMidtransVerifyNotification checks that only notifications signed with the server key over their order ID,
+// status code and gross amount are accepted.
+func TestMidtransVerifyNotification(t *testing.T) {
+	const serverKey = "test-server-key"
+	sign := func(n MidtransNotification, key string) string {
+		sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + key))
+		return hex.EncodeToString(sum[:])
+	}
+	signed := MidtransNotification{OrderID: "PREMIUM-1-1", StatusCode: "200", GrossAmount: "150000.00"}
+	signed.SignatureKey = sign(signed, serverKey)
+	unchanged := func(n MidtransNotification) MidtransNotification { return n }
+
+	tests := []struct {
+		name      string
+		serverKey string
+		n         func(n MidtransNotification) MidtransNotification
+		want      bool
+	}{
+		{name: "valid", serverKey: serverKey, n: unchanged, want: true},
+		{name: "not configured", n: unchanged},
+		{name: "other server key", serverKey: serverKey, n: func(n MidtransNotification) MidtransNotification {
+			n.SignatureKey = sign(n, "other-server-key")
+			return n
+		}},
+		{name: "changed gross amount", serverKey: serverKey, n: func(n MidtransNotification) MidtransNotification {
+			n.GrossAmount = "1000.00"
+			return n
+		}},
+		{name: "changed status code", serverKey: serverKey, n: func(n MidtransNotification) MidtransNotification {
+			n.StatusCode = "201"
+			return n
+		}},
+		{name: "changed order ID", serverKey: serverKey, n: func(n MidtransNotification) MidtransNotification {
+			n.OrderID = "PREMIUM-2-1"
+			return n
+		}},
+		{name: "missing signature", serverKey: serverKey, n: func(n MidtransNotification) MidtransNotification {
+			n.SignatureKey = ""
+			return n
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Client{MidtransServerKey: tt.serverKey}
+			if got := c.MidtransVerifyNotification(tt.n(signed)); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMidtransGrossAmountIDR checks that gross amounts are parsed to whole IDR.
+func TestMidtransGrossAmountIDR(t *testing.T) {
+	tests := []struct {
+		grossAmount string
+		want        int
+		wantErr     bool
+	}{
+		{grossAmount: "150000.00", want: 150000},
+		{grossAmount: "149999.50", want: 150000},
+		{grossAmount: "149999.49", want: 149999},
+		{grossAmount: "150000", want: 150000},
+		{grossAmount: "", wantErr: true},
+		{grossAmount: "IDR 150000", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := MidtransNotification{GrossAmount: tt.grossAmount}.GrossAmountIDR()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("gross_amount %#v: got %d, err: %v, want %d, error: %t",
+				tt.grossAmount, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
@@ -24,6 +25,7 @@ type shopeeItemResponse struct {
 
 type shopeeItem struct {
 	ShopID         int              `json:"shopid"`
+	ShopLocation   string           `json:"shop_location"`
 	ItemID         int              `json:"itemid"`
 	Name           string           `json:"name"`
 	Price          int              `json:"price"`
@@ -32,6 +34,25 @@ type shopeeItem struct {
 	Description    string           `json:"description"`
 	HistoricalSold int              `json:"historical_sold"`
 	ItemRating     shopeeItemRating `json:"item_rating"`
+	Categories     []struct {
+		DisplayName string `json:"display_name"`
+	} `json:"categories"`
+	TierVariations []struct {
+		Name    string   `json:"name"`
+		Options []string `json:"options"`
+	} `json:"tier_variations"`
+	Models []shopeeItemModel `json:"models"`
+}
+
+// shopeeItemModel is a variation of a Shopee item, e.g. a color and size, with its own price and stock.
+type shopeeItemModel struct {
+	ModelID int64  `json:"modelid"`
+	Name    string `json:"name"`
+	Price   int    `json:"price"`
+	Stock   int    `json:"stock"`
+	ExtInfo struct {
+		TierIndex []int `json:"tier_index"`
+	} `json:"extinfo"`
 }
 
 type shopeeItemRating struct {
@@ -48,25 +69,44 @@ type shopeeSearchItem struct {
 	AdsID     int        `json:"adsid"`
 }
 
-func (c Client) ShopeeGetItem(url string) (model.Item, error) {
-	var i model.Item
+func (c Client) ShopeeGetItem(ctx context.Context, url string) (model.Item, error) {
+	return flight(ctx, c, CacheOpGetItem+":"+productKey(SiteShopee, url), func(ctx context.Context) (model.Item, error) {
+		return cached(ctx, c, CacheOpGetItem, SiteShopee, url, ErrShopeeItemNotFound, func() (model.Item, error) {
+			return c.shopeeGetItem(ctx, url)
+		})
+	})
+}
+
+// shopeeGetItem gets the item from the Shopee API, falling back to the headless browser when it is set
+// and the API request gets blocked.
+func (c Client) shopeeGetItem(ctx context.Context, url string) (model.Item, error) {
 	shopID, itemID, ok := shopeeGetShopAndItemID(url)
 	if !ok {
-		return i, errors.Wrapf(ErrShopeeItemNotFound, "error getting ShopID and ItemID from URL: %s", url)
+		return model.Item{}, errors.Wrapf(ErrShopeeItemNotFound, "error getting ShopID and ItemID from URL: %s", url)
 	}
-	apiURL := fmt.Sprintf("https://shopee.co.id/api/v4/item/get?shopid=%s&itemid=%s", shopID, itemID)
+	i, err := c.shopeeGetItemAPI(ctx, shopID, itemID)
+	if err != nil && c.Headless != nil && errors.Is(err, ErrShopee) {
+		c.Logger.Warnf("shopeeGetItem: Shopee API failed, falling back to headless browser, url: %s, err: %v, TraceID: %s", url, err, TraceID(ctx))
+		return c.shopeeGetItemHeadless(ctx, url, shopID, itemID)
+	}
+	return i, err
+}
+
+func (c Client) shopeeGetItemAPI(ctx context.Context, shopID string, itemID string) (model.Item, error) {
+	var i model.Item
+	apiPath := fmt.Sprintf("/api/v4/item/get?shopid=%s&itemid=%s", shopID, itemID)
 
-	req, err := shopeeNewRequest(http.MethodGet, apiURL, nil)
+	req, err := c.siteAPIRequest(ctx, SiteShopee, http.MethodGet, apiPath, nil)
 	if err != nil {
-		return i, err
+		return i, errors.Wrapf(err, "error creating request from path: %s", apiPath)
 	}
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteShopee, req)
 	if err != nil {
-		return i, errors.Wrapf(ErrShopee, "error doing request:\n%#v,\nerr: %v", req, err)
+		return i, errors.Wrapf(err, "error doing request:\n%#v", req)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			c.Logger.Errorf("ShopeeGetItem: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v", resp, req, err)
+			c.Logger.Errorf("shopeeGetItemAPI: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
 		}
 	}()
 
@@ -90,6 +130,60 @@ func (c Client) ShopeeGetItem(url string) (model.Item, error) {
 	return shopeeItemResp.Data.toItem(), nil
 }
 
+type shopeeShopDetailResponse struct {
+	Error int               `json:"error"`
+	Data  *shopeeShopDetail `json:"data"`
+}
+
+type shopeeShopDetail struct {
+	ShopID        int     `json:"shopid"`
+	Name          string  `json:"name"`
+	RatingStar    float64 `json:"rating_star"`
+	FollowerCount int     `json:"follower_count"`
+}
+
+func (c Client) ShopeeGetMerchant(ctx context.Context, shopID string) (model.MerchantHistory, error) {
+	var mh model.MerchantHistory
+	apiPath := fmt.Sprintf("/api/v4/shop/get_shop_detail?shopid=%s", url.QueryEscape(shopID))
+
+	req, err := c.siteAPIRequest(ctx, SiteShopee, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return mh, errors.Wrapf(err, "error creating request from path: %s", apiPath)
+	}
+	resp, err := c.doSite(SiteShopee, req)
+	if err != nil {
+		return mh, errors.Wrapf(err, "error doing request:\n%#v", req)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.Logger.Errorf("ShopeeGetMerchant: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
+		}
+	}()
+
+	shopDetailResp := shopeeShopDetailResponse{}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 300000))
+	if err != nil {
+		return mh, errors.Wrapf(err, "error reading ShopeeShopDetailAPI response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 2000))
+	}
+	if err = json.Unmarshal(body, &shopDetailResp); err != nil {
+		return mh, errors.Wrapf(err, "error unmarshalling ShopeeShopDetailAPI response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 2000))
+	}
+	if shopDetailResp.Error != 0 || shopDetailResp.Data == nil {
+		return mh, errors.Wrapf(ErrShopee, "error getting data from ShopeeShopDetailAPI, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(body, 2000))
+	}
+
+	return model.MerchantHistory{
+		Site:          "Shopee",
+		MerchantID:    strconv.Itoa(shopDetailResp.Data.ShopID),
+		Name:          shopDetailResp.Data.Name,
+		Rating:        shopDetailResp.Data.RatingStar,
+		FollowerCount: shopDetailResp.Data.FollowerCount,
+	}, nil
+}
+
 func shopeeGetShopAndItemID(urlStr string) (shopID string, itemID string, ok bool) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -107,12 +201,18 @@ func shopeeGetShopAndItemID(urlStr string) (shopID string, itemID string, ok boo
 	return "", "", false
 }
 
-func (c Client) ShopeeSearch(query string) ([]model.Item, error) {
+func (c Client) ShopeeSearch(ctx context.Context, query string) ([]model.Item, error) {
+	return cached(ctx, c, CacheOpSearch, SiteShopee, query, nil, func() ([]model.Item, error) {
+		return c.shopeeSearch(ctx, query)
+	})
+}
+
+func (c Client) shopeeSearch(ctx context.Context, query string) ([]model.Item, error) {
 	var is []model.Item
-	apiURL := "https://shopee.co.id/api/v4/search/search_items"
-	req, err := shopeeNewRequest(http.MethodGet, apiURL, nil)
+	apiPath := "/api/v4/search/search_items"
+	req, err := c.siteAPIRequest(ctx, SiteShopee, http.MethodGet, apiPath, nil)
 	if err != nil {
-		return is, err
+		return is, errors.Wrapf(err, "error creating request from path: %s", apiPath)
 	}
 	qp := url.Values{
 		"by":        []string{"relevancy"},
@@ -126,13 +226,13 @@ func (c Client) ShopeeSearch(query string) ([]model.Item, error) {
 	}.Encode()
 	req.URL.RawQuery = strings.ReplaceAll(qp, "+", "%20")
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteShopee, req)
 	if err != nil {
-		return is, errors.Wrapf(ErrShopee, "error doing request:\n%#v,\nerr: %v", req, err)
+		return is, errors.Wrapf(err, "error doing request:\n%#v", req)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			c.Logger.Errorf("ShopeeSearch: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v", resp, req, err)
+			c.Logger.Errorf("shopeeSearch: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
 		}
 	}()
 
@@ -160,33 +260,53 @@ func (c Client) ShopeeSearch(query string) ([]model.Item, error) {
 }
 
 func (si shopeeItem) toItem() model.Item {
+	itemURL := fmt.Sprintf("https://shopee.co.id/product/%d/%d", si.ShopID, si.ItemID)
+	var variants []model.ItemVariant
+	if len(si.Models) > 1 {
+		variants = make([]model.ItemVariant, 0, len(si.Models))
+		for _, m := range si.Models {
+			variants = append(variants, model.ItemVariant{
+				VariationID: strconv.FormatInt(m.ModelID, 10),
+				Name:        si.modelName(m),
+				Price:       m.Price / 100000,
+				Stock:       m.Stock,
+				URL:         itemURL,
+			})
+		}
+	}
+	categories := make([]string, 0, len(si.Categories))
+	for _, c := range si.Categories {
+		categories = append(categories, c.DisplayName)
+	}
 	return model.Item{
-		Site:        "Shopee",
-		MerchantID:  strconv.Itoa(si.ShopID),
-		ProductID:   strconv.Itoa(si.ItemID),
-		URL:         fmt.Sprintf("https://shopee.co.id/product/%d/%d", si.ShopID, si.ItemID),
-		Name:        si.Name,
-		Price:       si.Price / 100000,
-		Stock:       si.Stock,
-		ImageURL:    "https://cf.shopee.co.id/file/" + si.Image,
-		Description: misc.StringLimit(si.Description, 2500),
-		Rating:      si.ItemRating.RatingStar,
-		Sold:        si.HistoricalSold,
-	}
-}
-
-func shopeeNewRequest(method string, url string, body io.Reader) (*http.Request, error) {
-	req, err := newRequest(method, url, body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error creating request from URL: %s", url)
+		Site:             "Shopee",
+		MerchantID:       strconv.Itoa(si.ShopID),
+		MerchantLocation: strings.TrimSpace(si.ShopLocation),
+		ProductID:        strconv.Itoa(si.ItemID),
+		URL:              itemURL,
+		Name:             si.Name,
+		Price:            si.Price / 100000,
+		Currency:         model.CurrencyIDR,
+		Stock:            si.Stock,
+		ImageURL:         "https://cf.shopee.co.id/file/" + si.Image,
+		Description:      misc.StringLimit(si.Description, 2500),
+		Rating:           si.ItemRating.RatingStar,
+		Sold:             si.HistoricalSold,
+		Categories:       model.ItemCategories(categories),
+		Variants:         variants,
 	}
-	req.AddCookie(&http.Cookie{
-		Name:  "SPC_U",
-		Value: "-",
-	})
-	req.AddCookie(&http.Cookie{
-		Name:  "SPC_F",
-		Value: "-",
-	})
-	return req, nil
+}
+
+// modelName returns the name of m, built from the tier variation options of m when Shopee leaves it empty.
+func (si shopeeItem) modelName(m shopeeItemModel) string {
+	if m.Name != "" {
+		return m.Name
+	}
+	options := make([]string, 0, len(m.ExtInfo.TierIndex))
+	for tier, idx := range m.ExtInfo.TierIndex {
+		if tier < len(si.TierVariations) && idx >= 0 && idx < len(si.TierVariations[tier].Options) {
+			options = append(options, si.TierVariations[tier].Options[idx])
+		}
+	}
+	return strings.Join(options, ",")
 }
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/pkg/errors"
+	"pricetracker/internal/model"
+	"sync"
+	"time"
+)
+
+// headlessMaxTimeouts is how many runs in a row may time out before headless Chrome is considered hung.
+const headlessMaxTimeouts = 3
+
+// HeadlessBrowser renders pages in a shared headless Chrome instance, one tab at a time. Chrome is restarted when it
+// crashed or hung.
+type HeadlessBrowser struct {
+	execPath string
+	timeout  time.Duration
+	tab      chan struct{}
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	// timeouts is how many runs in a row timed out.
+	timeouts int
+	closed   bool
+}
+
+// NewHeadlessBrowser starts headless Chrome, execPath may be empty to look up Chrome in the usual locations.
+func NewHeadlessBrowser(execPath string, timeout time.Duration) (*HeadlessBrowser, error) {
+	hb := &HeadlessBrowser{execPath: execPath, timeout: timeout, tab: make(chan struct{}, 1)}
+	if err := hb.start(); err != nil {
+		return nil, err
+	}
+	return hb, nil
+}
+
+// start starts a new headless Chrome instance, hb.mu must be held once hb is shared.
+func (hb *HeadlessBrowser) start() error {
+	opts := chromedp.DefaultExecAllocatorOptions[:]
+	if hb.execPath != "" {
+		opts = append(opts, chromedp.ExecPath(hb.execPath))
+	}
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		return errors.Wrap(err, "error starting headless browser")
+	}
+	hb.ctx = ctx
+	hb.cancel = func() {
+		cancel()
+		allocCancel()
+	}
+	hb.timeouts = 0
+	return nil
+}
+
+func (hb *HeadlessBrowser) Close() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	hb.closed = true
+	hb.cancel()
+}
+
+// run runs actions in a new tab which is closed afterwards, the tab is closed early when reqCtx is done.
+func (hb *HeadlessBrowser) run(reqCtx context.Context, actions ...chromedp.Action) error {
+	select {
+	case hb.tab <- struct{}{}:
+	case <-reqCtx.Done():
+		return reqCtx.Err()
+	}
+	defer func() { <-hb.tab }()
+	hb.mu.Lock()
+	browserCtx := hb.ctx
+	hb.mu.Unlock()
+	tabCtx, tabCancel := chromedp.NewContext(browserCtx)
+	defer tabCancel()
+	ctx, cancel := context.WithTimeout(tabCtx, hb.timeout)
+	defer cancel()
+	// The tab has to be derived from the browser context, so it is only tied to reqCtx by cancelling it.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-reqCtx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	err := chromedp.Run(ctx, actions...)
+	if reqCtx.Err() != nil {
+		// A run cancelled by its caller tells nothing about the health of the browser.
+		return err
+	}
+	if restartErr := hb.restartIfUnhealthy(browserCtx, err); restartErr != nil {
+		return errors.Wrapf(err, "error restarting headless browser: %v", restartErr)
+	}
+	return err
+}
+
+// restartIfUnhealthy restarts the Chrome instance of browserCtx when err of a run in it shows that it crashed, or when
+// runs kept timing out as it hung. A failed restart is tried again on the next run.
+func (hb *HeadlessBrowser) restartIfUnhealthy(browserCtx context.Context, err error) error {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.closed || hb.ctx != browserCtx {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		hb.timeouts++
+	} else {
+		hb.timeouts = 0
+	}
+	crashed := browserCtx.Err() != nil ||
+		errors.Is(err, chromedp.ErrInvalidContext) ||
+		errors.Is(err, chromedp.ErrChannelClosed) ||
+		errors.Is(err, chromedp.ErrInvalidWebsocketMessage)
+	if !crashed && hb.timeouts < headlessMaxTimeouts {
+		return nil
+	}
+	hb.cancel()
+	return hb.start()
+}
+
+// shopeeGetItemHeadless opens the product page in the headless browser and requests the item API from within the
+// page, so the request carries the cookies and anti-bot tokens set by the page scripts.
+func (c Client) shopeeGetItemHeadless(ctx context.Context, url string, shopID string, itemID string) (model.Item, error) {
+	var i model.Item
+	apiPath := fmt.Sprintf("/api/v4/item/get?shopid=%s&itemid=%s", shopID, itemID)
+	pageURL := c.Fingerprints.Get(SiteShopee).APIHost + fmt.Sprintf("/product/%s/%s", shopID, itemID)
+
+	var body string
+	err := c.Headless.run(
+		ctx,
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady("body"),
+		chromedp.Evaluate(
+			fmt.Sprintf(`fetch(%q, {credentials: "include"}).then(r => r.text())`, apiPath),
+			&body,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) },
+		),
+	)
+	if err != nil {
+		return i, errors.Wrapf(ErrShopee, "error rendering Shopee product page in headless browser, url: %s, err: %v", url, err)
+	}
+
+	shopeeItemResp := shopeeItemResponse{}
+	if err = json.Unmarshal([]byte(body), &shopeeItemResp); err != nil {
+		return i, errors.Wrapf(err, "error unmarshalling headless ShopeeItemAPI response body, body:\n%s,\nurl: %s", body, url)
+	}
+	if shopeeItemResp.Error == 4 {
+		return i, errors.Wrapf(ErrShopeeItemNotFound, "Shopee item not found in headless browser, body:\n%s,\nurl: %s", body, url)
+	}
+	if shopeeItemResp.ActionType != 0 || shopeeItemResp.Data == nil {
+		return i, errors.Wrapf(ErrShopee, "error getting data from headless ShopeeItemAPI, body:\n%s,\nurl: %s", body, url)
+	}
+
+	return shopeeItemResp.Data.toItem(), nil
+}
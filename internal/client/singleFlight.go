@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SingleFlight deduplicates concurrent requests for the same product, a caller requesting a product that is
+// already being requested waits for and shares the result of the request in flight, including its error.
+// The zero value is ready to use.
+type SingleFlight struct {
+	g singleflight.Group
+}
+
+// flightTimeout bounds a request in flight, which is not cancelled with the context of any of its callers.
+const flightTimeout = 30 * time.Second
+
+// flight calls get once for all concurrent callers with the same key, get is called directly with ctx when Flights
+// is nil. Otherwise get is called with a context detached from the caller that keeps its trace ID and times out after
+// flightTimeout, so a caller going away does not fail the request for the others, and every caller stops waiting
+// when its own ctx is done.
+func flight[T any](ctx context.Context, c Client, key string, get func(ctx context.Context) (T, error)) (T, error) {
+	if c.Flights == nil {
+		return get(ctx)
+	}
+	ch := c.Flights.g.DoChan(key, func() (any, error) {
+		flightCtx, cancel := context.WithTimeout(WithTraceID(context.Background(), TraceID(ctx)), flightTimeout)
+		defer cancel()
+		return get(flightCtx)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Shared {
+			c.Logger.Debugf("flight: Shared result of request in flight, key: %s, TraceID: %s", key, TraceID(ctx))
+		}
+		return res.Val.(T), res.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, errors.Wrapf(ctx.Err(), "flight: stopped waiting for request in flight, key: %s", key)
+	}
+}
+
+// productKey identifies the product of url on site without requesting the site, URLs that can not be parsed
+// without requesting the site, such as share links, are their own key.
+func productKey(site string, urlStr string) string {
+	switch site {
+	case SiteShopee:
+		if shopID, itemID, ok := shopeeGetShopAndItemID(urlStr); ok {
+			return site + ":" + shopID + "." + itemID
+		}
+	case SiteTokopedia:
+		if normURL, isShareLink, err := tokopediaNormalizeURL(urlStr); err == nil && !isShareLink {
+			return site + ":" + strings.TrimPrefix(normURL, "https://www.tokopedia.com/")
+		}
+	case SiteBlibli:
+		if parsedURL, err := url.Parse(urlStr); err == nil {
+			sp := strings.Split(parsedURL.Path, "/")
+			if sku, ok := blibliNormalizeSKU(sp[len(sp)-1]); ok && (len(sp) == 4 || len(sp) == 5) {
+				return site + ":" + sku
+			}
+		}
+	case SiteEbay:
+		if itemID, ok := ebayGetLegacyItemID(urlStr); ok {
+			return site + ":" + itemID
+		}
+	}
+	return site + ":" + urlStr
+}
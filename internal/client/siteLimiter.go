@@ -0,0 +1,141 @@
+package client
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrSiteBackoff = errors.New("site backoff")
+
+type SiteLimit struct {
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	Burst             int           `json:"burst"`
+	BackoffInitial    time.Duration `json:"-"`
+	BackoffMax        time.Duration `json:"-"`
+}
+
+func (sl SiteLimit) MarshalJSON() ([]byte, error) {
+	type localSiteLimit SiteLimit
+	type myType struct {
+		localSiteLimit
+		BackoffInitial string `json:"backoff_initial"`
+		BackoffMax     string `json:"backoff_max"`
+	}
+	return json.Marshal(myType{
+		localSiteLimit: localSiteLimit(sl),
+		BackoffInitial: sl.BackoffInitial.String(),
+		BackoffMax:     sl.BackoffMax.String(),
+	})
+}
+
+var DefaultSiteLimits = map[string]SiteLimit{
+	SiteShopee:    {RequestsPerSecond: 1, Burst: 2, BackoffInitial: 30 * time.Second, BackoffMax: 10 * time.Minute},
+	SiteTokopedia: {RequestsPerSecond: 2, Burst: 2, BackoffInitial: 30 * time.Second, BackoffMax: 10 * time.Minute},
+	SiteBlibli:    {RequestsPerSecond: 2, Burst: 2, BackoffInitial: 30 * time.Second, BackoffMax: 10 * time.Minute},
+	SiteEbay:      {RequestsPerSecond: 2, Burst: 2, BackoffInitial: 30 * time.Second, BackoffMax: 10 * time.Minute},
+}
+
+// SiteLimiters rate limits the requests to every site, and backs off a site exponentially while it keeps
+// responding with 429 or 403, requests during a backoff fail with ErrSiteBackoff instead of waiting.
+type SiteLimiters struct {
+	sites map[string]*siteLimiter
+}
+
+type siteLimiter struct {
+	limiter *rate.Limiter
+
+	mu           sync.Mutex
+	limit        SiteLimit
+	backoff      time.Duration
+	blockedUntil time.Time
+}
+
+// NewSiteLimiters creates the limiters from limits, sites missing from limits use DefaultSiteLimits.
+func NewSiteLimiters(limits map[string]SiteLimit) *SiteLimiters {
+	sl := &SiteLimiters{sites: make(map[string]*siteLimiter, len(DefaultSiteLimits))}
+	for site, d := range DefaultSiteLimits {
+		l, ok := limits[site]
+		if !ok {
+			l = d
+		}
+		sl.sites[site] = &siteLimiter{limit: l, limiter: rate.NewLimiter(rate.Limit(l.RequestsPerSecond), l.Burst)}
+	}
+	return sl
+}
+
+// SetLimits changes the limits of every site to limits, sites missing from limits use DefaultSiteLimits.
+// Current backoffs are kept.
+func (sl *SiteLimiters) SetLimits(limits map[string]SiteLimit) {
+	for site, s := range sl.sites {
+		l, ok := limits[site]
+		if !ok {
+			l = DefaultSiteLimits[site]
+		}
+		s.mu.Lock()
+		s.limit = l
+		s.mu.Unlock()
+		s.limiter.SetLimit(rate.Limit(l.RequestsPerSecond))
+		s.limiter.SetBurst(l.Burst)
+	}
+}
+
+func (sl *siteLimiter) wait(req *http.Request) error {
+	sl.mu.Lock()
+	blockedUntil := sl.blockedUntil
+	sl.mu.Unlock()
+	if wait := time.Until(blockedUntil); wait > 0 {
+		return errors.Wrapf(ErrSiteBackoff, "backing off for %v", wait.Round(time.Second))
+	}
+	return sl.limiter.Wait(req.Context())
+}
+
+// record doubles the backoff when the site is limiting requests, and resets it on any other response.
+// It returns how long the site is backed off for.
+func (sl *siteLimiter) record(resp *http.Response) time.Duration {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		sl.backoff = 0
+		return 0
+	}
+	if sl.backoff == 0 {
+		sl.backoff = sl.limit.BackoffInitial
+	} else {
+		sl.backoff *= 2
+	}
+	if sl.backoff > sl.limit.BackoffMax {
+		sl.backoff = sl.limit.BackoffMax
+	}
+	wait := sl.backoff
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		if d := time.Duration(retryAfter) * time.Second; d > wait {
+			wait = d
+		}
+	}
+	sl.blockedUntil = time.Now().Add(wait)
+	return wait
+}
+
+// doLimited does req to site within the site limits, limits are not applied when Limiters is nil.
+func (c Client) doLimited(site string, req *http.Request) (*http.Response, error) {
+	if c.Limiters == nil {
+		return c.Client.Do(req)
+	}
+	sl := c.Limiters.sites[site]
+	if err := sl.wait(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if backoff := sl.record(resp); backoff > 0 {
+		c.Logger.Warnf("doLimited: %s responded with status %s, backing off for %v, TraceID: %s", site, resp.Status, backoff, TraceID(req.Context()))
+	}
+	return resp, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// siteRequestAttempts is how many times a site request is attempted before giving up on transient failures.
+	siteRequestAttempts = 3
+	// siteRetryDelay is the delay before the first retry, doubled for every further retry and jittered.
+	siteRetryDelay = 500 * time.Millisecond
+)
+
+// siteErrors are the errors RequestError matches for each site.
+var siteErrors = map[string]error{
+	SiteShopee:    ErrShopee,
+	SiteTokopedia: ErrTokopedia,
+	SiteBlibli:    ErrBlibli,
+}
+
+// RequestError is returned by site requests that failed, it matches the error of its site, e.g. ErrShopee.
+// Retryable failures are network errors, 5xx responses and site backoffs, which persisted over every attempt
+// but may succeed later, other failures such as a cancelled context are permanent.
+type RequestError struct {
+	Site       string
+	Attempts   int
+	StatusCode int
+	Retryable  bool
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s request failed after %d attempt(s) with status %d: %v", e.Site, e.Attempts, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s request failed after %d attempt(s): %v", e.Site, e.Attempts, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	return target != nil && target == siteErrors[e.Site]
+}
+
+// IsRetryable reports whether err was caused by a site request failure that may succeed later.
+func IsRetryable(err error) bool {
+	var re *RequestError
+	return errors.As(err, &re) && re.Retryable
+}
+
+// doSite does req to site within the site limits, retrying network errors and 5xx responses with jittered
+// exponential backoff until siteRequestAttempts is reached or the request context is done.
+// Failures are returned as *RequestError, other responses are returned as they are.
+func (c Client) doSite(site string, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, &RequestError{Site: site, Attempts: attempt - 1, Err: errors.Wrap(err, "error getting request body")}
+			}
+			req.Body = body
+		}
+
+		resp, err := c.doLimited(site, req)
+		re := &RequestError{Site: site, Attempts: attempt, Err: err}
+		switch {
+		case err == nil && resp.StatusCode < http.StatusInternalServerError:
+			return resp, nil
+		case err == nil:
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			re.StatusCode = resp.StatusCode
+			re.Err = errors.Errorf("response status: %s", resp.Status)
+			re.Retryable = true
+		case ctx.Err() != nil:
+			re.Err = ctx.Err()
+			return nil, re
+		case errors.Is(err, ErrSiteBackoff):
+			// Retrying would fail again until the backoff is over.
+			re.Retryable = true
+			return nil, re
+		default:
+			re.Retryable = true
+		}
+
+		canRetry := req.Body == nil || req.GetBody != nil
+		if attempt == siteRequestAttempts || !canRetry {
+			return nil, re
+		}
+		delay := siteRetryDelay << (attempt - 1)
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+		c.Logger.Debugf("doSite: Retrying %s request in %v, attempt %d failed, err: %v, TraceID: %s", site, delay, attempt, re.Err, TraceID(ctx))
+		if err = sleepContext(ctx, delay); err != nil {
+			re.Err = err
+			re.Retryable = false
+			return nil, re
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
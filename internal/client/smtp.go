@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/pkg/errors"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSMTP = errors.New("SMTP error")
+
+// SMTPCredentials holds the SMTP server emails are sent through.
+type SMTPCredentials struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From is the sender address of the emails, e.g. Price Tracker <noreply@example.com>.
+	From string
+}
+
+func (c Client) SMTPEnabled() bool {
+	return c.SMTP != nil
+}
+
+// SMTPSendMail sends an HTML email with subject to the address to, the connection is upgraded with STARTTLS when the
+// server supports it, and the credentials are only sent over TLS or to localhost.
+func (c Client) SMTPSendMail(ctx context.Context, to string, subject string, htmlBody string) error {
+	if !c.SMTPEnabled() {
+		return errors.Wrap(ErrSMTP, "SMTP is not enabled")
+	}
+	from, err := mail.ParseAddress(c.SMTP.From)
+	if err != nil {
+		return errors.Wrapf(err, "SMTPSendMail: invalid from address: %s", c.SMTP.From)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return errors.Wrapf(err, "SMTPSendMail: invalid to address: %s", to)
+	}
+
+	var msg bytes.Buffer
+	header := [][2]string{
+		{"From", from.String()},
+		{"To", rcpt.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/html; charset="utf-8"`},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, h := range header {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(htmlBody, "\r\n", "\n"), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if c.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", c.SMTP.Username, c.SMTP.Password, c.SMTP.Host)
+	}
+	if err = c.smtpSend(ctx, auth, from.Address, rcpt.Address, msg.Bytes()); err != nil {
+		return errors.Wrapf(ErrSMTP, "SMTPSendMail: error sending email to: %s, err: %v", rcpt.Address, err)
+	}
+	return nil
+}
+
+// smtpSend does what smtp.SendMail does over a connection that is closed when ctx is done.
+func (c Client) smtpSend(ctx context.Context, auth smtp.Auth, from string, to string, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(c.SMTP.Host, strconv.Itoa(c.SMTP.Port)))
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	sc, err := smtp.NewClient(conn, c.SMTP.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer sc.Close()
+	if ok, _ := sc.Extension("STARTTLS"); ok {
+		if err = sc.StartTLS(&tls.Config{ServerName: c.SMTP.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := sc.Extension("AUTH"); !ok {
+			return errors.New("server does not support AUTH")
+		}
+		if err = sc.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = sc.Mail(from); err != nil {
+		return err
+	}
+	if err = sc.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := sc.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return sc.Quit()
+}
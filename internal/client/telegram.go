@@ -0,0 +1,150 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"pricetracker/internal/misc"
+)
+
+var ErrTelegram = errors.New("Telegram error")
+var ErrTelegramChatNotFound = errors.New("Telegram chat not found")
+
+type telegramSendMessageRequest struct {
+	ChatID                int64  `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (c Client) TelegramEnabled() bool {
+	return c.TelegramBotToken != ""
+}
+
+func (c Client) TelegramSendMessage(ctx context.Context, chatID int64, text string) error {
+	if !c.TelegramEnabled() {
+		return errors.Wrap(ErrTelegram, "Telegram bot token is not set")
+	}
+	reqBody, err := json.Marshal(telegramSendMessageRequest{
+		ChatID:                chatID,
+		Text:                  text,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "TelegramSendMessage: request JSON marshalling error, ChatID: %d", chatID)
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", c.TelegramBotToken)
+	req, err := newRequest(ctx, http.MethodPost, apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return errors.Wrapf(err, "TelegramSendMessage: error creating HTTP request, ChatID: %d", chatID)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.Wrapf(ErrTelegram, "TelegramSendMessage: error doing request, ChatID: %d, err: %v", chatID, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 100*1024))
+	if err != nil {
+		return errors.Wrapf(err, "TelegramSendMessage: error reading response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	telegramResp := telegramResponse{}
+	if err = json.Unmarshal(respBody, &telegramResp); err != nil {
+		return errors.Wrapf(err, "TelegramSendMessage: error unmarshalling response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	if !telegramResp.OK {
+		if telegramResp.ErrorCode == http.StatusBadRequest || telegramResp.ErrorCode == http.StatusForbidden {
+			return errors.Wrapf(ErrTelegramChatNotFound, "ChatID: %d, error code: %d, description: %s",
+				chatID, telegramResp.ErrorCode, telegramResp.Description)
+		}
+		return errors.Wrapf(ErrTelegram, "ChatID: %d, error code: %d, description: %s",
+			chatID, telegramResp.ErrorCode, telegramResp.Description)
+	}
+	return nil
+}
+
+type telegramSetWebhookRequest struct {
+	URL            string   `json:"url"`
+	SecretToken    string   `json:"secret_token"`
+	AllowedUpdates []string `json:"allowed_updates"`
+}
+
+// TelegramWebhookSecret is the secret Telegram sends in the X-Telegram-Bot-Api-Secret-Token header of the updates
+// it posts to the webhook, derived from the bot token so it does not need configuring on its own.
+func (c Client) TelegramWebhookSecret() string {
+	sum := sha256.Sum256([]byte("pricetracker-telegram-webhook:" + c.TelegramBotToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// TelegramSetWebhook makes Telegram post the messages sent to the bot to webhookURL.
+func (c Client) TelegramSetWebhook(ctx context.Context, webhookURL string) error {
+	if !c.TelegramEnabled() {
+		return errors.Wrap(ErrTelegram, "Telegram bot token is not set")
+	}
+	reqBody, err := json.Marshal(telegramSetWebhookRequest{
+		URL:            webhookURL,
+		SecretToken:    c.TelegramWebhookSecret(),
+		AllowedUpdates: []string{"message"},
+	})
+	if err != nil {
+		return errors.Wrap(err, "TelegramSetWebhook: request JSON marshalling error")
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/setWebhook", c.TelegramBotToken)
+	req, err := newRequest(ctx, http.MethodPost, apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return errors.Wrap(err, "TelegramSetWebhook: error creating HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.Wrapf(ErrTelegram, "TelegramSetWebhook: error doing request, err: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, 100*1024))
+	if err != nil {
+		return errors.Wrapf(err, "TelegramSetWebhook: error reading response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	telegramResp := telegramResponse{}
+	if err = json.Unmarshal(respBody, &telegramResp); err != nil {
+		return errors.Wrapf(err, "TelegramSetWebhook: error unmarshalling response body, status: %s, body:\n%s",
+			resp.Status, misc.BytesLimit(respBody, 500))
+	}
+	if !telegramResp.OK {
+		return errors.Wrapf(ErrTelegram, "TelegramSetWebhook: error code: %d, description: %s",
+			telegramResp.ErrorCode, telegramResp.Description)
+	}
+	return nil
+}
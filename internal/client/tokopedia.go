@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
@@ -21,29 +22,37 @@ var ErrTokopediaItemNotFound = errors.New("Tokopedia item not found")
 var errTokopediaNotPDP = errors.New("Tokopedia page is not PDP")
 var errTokopediaFieldKeyNotFound = errors.New("Tokopedia field key not found")
 
-func (c Client) TokopediaGetItem(url string) (model.Item, error) {
+func (c Client) TokopediaGetItem(ctx context.Context, url string) (model.Item, error) {
+	return flight(ctx, c, CacheOpGetItem+":"+productKey(SiteTokopedia, url), func(ctx context.Context) (model.Item, error) {
+		return cached(ctx, c, CacheOpGetItem, SiteTokopedia, url, ErrTokopediaItemNotFound, func() (model.Item, error) {
+			return c.tokopediaGetItem(ctx, url)
+		})
+	})
+}
+
+func (c Client) tokopediaGetItem(ctx context.Context, url string) (model.Item, error) {
 	var i model.Item
 	normURL, isShareLink, err := tokopediaNormalizeURL(url)
 	if err != nil {
 		return i, fmt.Errorf("%w: error normalizing URL, err: %v", ErrTokopediaItemNotFound, err)
 	}
 	if isShareLink {
-		normURL, err = c.tokopediaResolveShareLink(normURL)
+		normURL, err = c.tokopediaResolveShareLink(ctx, normURL)
 		if err != nil {
 			return i, fmt.Errorf("%w: error resolving share link, err: %v", ErrTokopediaItemNotFound, err)
 		}
 	}
-	req, err := newRequest(http.MethodGet, normURL, nil)
+	req, err := newRequest(ctx, http.MethodGet, normURL, nil)
 	if err != nil {
 		return i, errors.Wrapf(err, "error creating request from URL: %s", normURL)
 	}
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteTokopedia, req)
 	if err != nil {
-		return i, errors.Wrapf(ErrTokopedia, "error doing request:\n%#v,\nerr: %v", req, err)
+		return i, errors.Wrapf(err, "error doing request:\n%#v", req)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			c.Logger.Errorf("TokopediaGetItem: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v", resp, req, err)
+			c.Logger.Errorf("tokopediaGetItem: Error closing response body, resp:\n%#v,\nreq:\n%#v,\nerr: %v, TraceID: %s", resp, req, err, TraceID(ctx))
 		}
 	}()
 
@@ -95,13 +104,12 @@ func tokopediaNormalizeURL(urlStr string) (string, bool, error) {
 	}
 }
 
-func (c Client) tokopediaResolveShareLink(url string) (string, error) {
-	req, err := newRequest(http.MethodGet, url, nil)
+func (c Client) tokopediaResolveShareLink(ctx context.Context, url string) (string, error) {
+	req, err := c.siteShareLinkRequest(ctx, SiteTokopedia, url)
 	if err != nil {
 		return "", fmt.Errorf("error creating request from URL: %s, err: %w", url, err)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 Windows")
-	resp, err := c.Client.Do(req)
+	resp, err := c.doSite(SiteTokopedia, req)
 	if err != nil {
 		return "", fmt.Errorf("error doing request, req:\n%#v,\nerr: %w", req, err)
 	}
@@ -146,13 +154,6 @@ func tokopediaParseProductPage(pageBytes []byte) (model.Item, error) {
 		return i, errors.Wrapf(err, "invalid productID")
 	}
 
-	parentID, err := tokopediaFindValue(page, "pi\\\":", ",", false, 32)
-	if err != nil {
-		parentID = ""
-	} else if _, err = strconv.Atoi(parentID); err != nil {
-		return i, errors.Wrapf(err, "invalid parentID")
-	}
-
 	shopHandle, err := tokopediaFindValue(page, "sd\\\":", ",", true, 32)
 	if err != nil {
 		return i, errors.Wrapf(err, "failed getting shopHandle")
@@ -215,23 +216,63 @@ func tokopediaParseProductPage(pageBytes []byte) (model.Item, error) {
 		return i, errors.Wrapf(err, "invalid itemSold")
 	}
 
+	// Merchant information is optional, the product page is still usable without it.
+	merchantName, _ := tokopediaFindValue(page, "\"shopName\":", ",", true, 200)
+	merchantLocation, _ := tokopediaFindValue(page, "\"shopLocation\":", ",", true, 200)
+	var merchantRating float64
+	if merchantRatingStr, err := tokopediaFindValue(page, "\"shopRating\":", ",", false, 32); err == nil {
+		merchantRating, _ = strconv.ParseFloat(merchantRatingStr, 64)
+	}
+	// So is the category breadcrumb.
+	categories := tokopediaParseCategories(page)
+
+	// Tokopedia variants are listed as their own products, the product page has no parent product.
 	return model.Item{
-		Site:        "Tokopedia",
-		MerchantID:  merchantID,
-		ProductID:   productID,
-		ParentID:    parentID,
-		VariationID: variationID,
-		URL:         fmt.Sprintf("www.tokopedia.com/%s/%s", shopHandle, urlPart),
-		Name:        itemName,
-		Price:       itemPrice,
-		Stock:       itemStock,
-		ImageURL:    imageURL,
-		Description: misc.StringLimit(itemDescription, 2500),
-		Rating:      itemRating,
-		Sold:        itemSold,
+		Site:             "Tokopedia",
+		MerchantID:       merchantID,
+		MerchantName:     merchantName,
+		MerchantLocation: merchantLocation,
+		MerchantRating:   merchantRating,
+		ProductID:        productID,
+		ParentID:         productID,
+		VariationID:      variationID,
+		URL:              fmt.Sprintf("www.tokopedia.com/%s/%s", shopHandle, urlPart),
+		Name:             itemName,
+		Price:            itemPrice,
+		Currency:         model.CurrencyIDR,
+		Stock:            itemStock,
+		ImageURL:         imageURL,
+		Description:      misc.StringLimit(itemDescription, 2500),
+		Rating:           itemRating,
+		Sold:             itemSold,
+		Categories:       categories,
 	}, nil
 }
 
+// tokopediaParseCategories returns the category breadcrumb in the basic info of a product page, nil when it is not found.
+func tokopediaParseCategories(page string) []string {
+	categoryIdx := strings.Index(page, "\"category\":{")
+	if categoryIdx < 0 {
+		return nil
+	}
+	page = page[categoryIdx:]
+	detailIdx := strings.Index(page, "\"detail\":[")
+	if detailIdx < 0 || detailIdx > 1000 {
+		return nil
+	}
+	var detail []struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(strings.NewReader(page[detailIdx+len("\"detail\":"):])).Decode(&detail); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(detail))
+	for _, d := range detail {
+		names = append(names, d.Name)
+	}
+	return model.ItemCategories(names)
+}
+
 func tokopediaFindValue(page string, key string, sep string, unquote bool, maxLength int) (string, error) {
 	keyIdx := strings.Index(page, key)
 	if keyIdx < 0 {
@@ -310,10 +351,17 @@ type tokopediaSearchProductShop struct {
 	ShopID int    `json:"shopId"`
 	Name   string `json:"name"`
 	URL    string `json:"url"`
+	City   string `json:"city"`
+}
+
+func (c Client) TokopediaSearch(ctx context.Context, query string) ([]model.Item, error) {
+	return cached(ctx, c, CacheOpSearch, SiteTokopedia, query, nil, func() ([]model.Item, error) {
+		return c.tokopediaSearch(ctx, query)
+	})
 }
 
-func (c Client) TokopediaSearch(query string) ([]model.Item, error) {
-	apiURL := "https://gql.tokopedia.com/graphql/SearchProductQueryV4"
+func (c Client) tokopediaSearch(ctx context.Context, query string) ([]model.Item, error) {
+	apiPath := "/graphql/SearchProductQueryV4"
 	params := url.Values{
 		"device":      []string{"desktop"},
 		"q":           []string{query},
@@ -346,15 +394,14 @@ func (c Client) TokopediaSearch(query string) ([]model.Item, error) {
 	}
 	reqBody := bytes.TrimSuffix(reqBodyBuf.Bytes(), []byte("\n"))
 
-	req, err := newRequest(http.MethodPost, apiURL, bytes.NewReader(reqBody))
+	req, err := c.siteAPIRequest(ctx, SiteTokopedia, http.MethodPost, apiPath, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("error creating request to URL: %s, with body:\n%s,\nerr: %w", apiURL, reqBody, err)
+		return nil, fmt.Errorf("error creating request to path: %s, with body:\n%s,\nerr: %w", apiPath, reqBody, err)
 	}
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Origin", "https://www.tokopedia.com")
-	resp, err := c.Do(req)
+	resp, err := c.doSite(SiteTokopedia, req)
 	if err != nil {
-		return nil, fmt.Errorf("%w: error doing request:\n%#v,\nreq body:\n%s,\nerr: %v", ErrTokopedia, req, reqBody, err)
+		return nil, fmt.Errorf("error doing request:\n%#v,\nreq body:\n%s,\nerr: %w", req, reqBody, err)
 	}
 	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 300*1024))
 	if err != nil {
@@ -380,7 +427,7 @@ func (c Client) TokopediaSearch(query string) ([]model.Item, error) {
 	for _, p := range tokopediaProducts {
 		i := p.toItem()
 		if i.URL == "" || i.Price == -1 || i.ImageURL == "" || i.Rating == -1 || i.Sold == -1 {
-			c.Logger.Warnf("TokopediaSearch: Parsing error on Tokopedia product: %#v, Item: %#v", p, i)
+			c.Logger.Warnf("tokopediaSearch: Parsing error on Tokopedia product: %#v, Item: %#v, TraceID: %s", p, i, TraceID(ctx))
 			continue
 		}
 		is = append(is, i)
@@ -427,15 +474,18 @@ func (ti tokopediaSearchProduct) toItem() model.Item {
 		}
 	}
 	return model.Item{
-		Site:       "Tokopedia",
-		MerchantID: strconv.Itoa(ti.Shop.ShopID),
-		ProductID:  strconv.Itoa(ti.ID),
-		URL:        itemURL,
-		Name:       ti.Name,
-		Price:      price,
-		Stock:      ti.Stock,
-		ImageURL:   imageURL,
-		Rating:     rating,
-		Sold:       sold,
+		Site:             "Tokopedia",
+		MerchantID:       strconv.Itoa(ti.Shop.ShopID),
+		MerchantName:     ti.Shop.Name,
+		MerchantLocation: ti.Shop.City,
+		ProductID:        strconv.Itoa(ti.ID),
+		URL:              itemURL,
+		Name:             ti.Name,
+		Price:            price,
+		Currency:         model.CurrencyIDR,
+		Stock:            ti.Stock,
+		ImageURL:         imageURL,
+		Rating:           rating,
+		Sold:             sold,
 	}
 }
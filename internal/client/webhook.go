@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrWebhookURLInvalid = errors.New("invalid webhook URL")
+
+const (
+	WebhookSignatureHeader = "X-Pricetracker-Signature"
+	WebhookTimestampHeader = "X-Pricetracker-Timestamp"
+
+	// webhookTimeout bounds a webhook request from dialing until its response body is read.
+	webhookTimeout = 10 * time.Second
+)
+
+// webhookBlockedNets are the non-public ranges not covered by the net.IP predicates, e.g. the shared address space
+// some clouds serve instance metadata from.
+var webhookBlockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// webhookHTTPClient sends webhooks without following redirects, connecting only to public addresses. The address is
+// checked when dialing, after the host name is resolved, so host names resolving to private addresses are refused.
+var webhookHTTPClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        20,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func webhookDialControl(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrapf(ErrWebhookURLInvalid, "error parsing dial address: %s, err: %v", address, err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip) {
+		return errors.Wrapf(ErrWebhookURLInvalid, "address is not public: %s", address)
+	}
+	return nil
+}
+
+// webhookIPAllowed reports whether ip is a public address webhooks may be sent to.
+func webhookIPAllowed(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range webhookBlockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookPayload is sent as JSON to user registered webhooks, Text and Content are set to the same message
+// so the payload can be posted directly to Slack and Discord incoming webhooks.
+type WebhookPayload struct {
+	Event   string      `json:"event"`
+	Text    string      `json:"text"`
+	Content string      `json:"content"`
+	Item    WebhookItem `json:"item"`
+
+	Alternatives []WebhookItem `json:"alternatives,omitempty"`
+}
+
+type WebhookItem struct {
+	ID          string `json:"id,omitempty"`
+	VariationID string `json:"variation_id,omitempty"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Price       int    `json:"price"`
+	Currency    string `json:"currency,omitempty"`
+	Stock       int    `json:"stock,omitempty"`
+	ImageURL    string `json:"image_url"`
+}
+
+// WebhookValidateURL rejects webhook URLs that are not HTTPS or that point to local and private addresses.
+// Host names are only resolved when sending, where addresses that are not public are refused as well.
+func WebhookValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrapf(ErrWebhookURLInvalid, "error parsing URL: %s, err: %v", rawURL, err)
+	}
+	if u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return errors.Wrapf(ErrWebhookURLInvalid, "URL must be an HTTPS URL without credentials: %s", rawURL)
+	}
+	// A trailing dot makes the host name fully qualified without changing where it resolves to.
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return errors.Wrapf(ErrWebhookURLInvalid, "URL host is local: %s", rawURL)
+	}
+	if ip := net.ParseIP(host); ip != nil && !webhookIPAllowed(ip) {
+		return errors.Wrapf(ErrWebhookURLInvalid, "URL host is not a public address: %s", rawURL)
+	}
+	return nil
+}
+
+// WebhookSignature returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with secret.
+func WebhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSend posts payload signed with secret to webhookURL. Redirects are not followed, a redirect response is
+// returned as an unexpected response status.
+func (c Client) WebhookSend(ctx context.Context, webhookURL string, secret string, payload WebhookPayload) error {
+	if err := WebhookValidateURL(webhookURL); err != nil {
+		return err
+	}
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "WebhookSend: request JSON marshalling error, payload: %+v", payload)
+	}
+
+	req, err := newRequest(ctx, http.MethodPost, webhookURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return errors.Wrapf(err, "WebhookSend: error creating HTTP request, URL: %s", webhookURL)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(secret, timestamp, reqBody))
+
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "WebhookSend: error doing request, URL: %s", webhookURL)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("WebhookSend: unexpected response status: %s, URL: %s", resp.Status, webhookURL)
+	}
+	return nil
+}
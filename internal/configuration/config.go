@@ -5,42 +5,220 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/pkg/errors"
+	"net/mail"
+	"net/url"
+	"os"
+	"pricetracker/internal/client"
 	"pricetracker/internal/logger"
+	"pricetracker/internal/model"
+	"strings"
 	"time"
 )
 
+// searchResultLimitMax is the most search results of a site search_result_limits can set.
+const searchResultLimitMax = 20
+
 type Config struct {
-	ServerEnabled     bool          `json:"server_enabled"`
-	ServerAddress     string        `json:"server_address"`
-	DatabaseURI       string        `json:"database_uri"`
-	FetcherEnabled    bool          `json:"fetcher_enabled"`
-	FetchDataInterval time.Duration `json:"-"`
-	LogLevel          logger.Level  `json:"-"`
-	LogToFile         bool          `json:"log_to_file"`
-	AuthSecretKey     jwk.Key       `json:"-"`
-	FCMKey            string        `json:"-"`
+	ServerEnabled         bool          `json:"server_enabled"`
+	ServerAddress         string        `json:"server_address"`
+	TLSCertFile           string        `json:"tls_cert_file"`
+	TLSKeyFile            string        `json:"tls_key_file"`
+	AutocertDomains       []string      `json:"autocert_domains"`
+	AutocertEmail         string        `json:"autocert_email"`
+	AutocertCacheDir      string        `json:"autocert_cache_dir"`
+	HTTPRedirectAddress   string        `json:"http_redirect_address"`
+	DatabaseURI           string        `json:"database_uri"`
+	DatabaseEnsureIndexes bool          `json:"database_ensure_indexes"`
+	DatabaseTransactions  bool          `json:"database_transactions"`
+	ItemHistoryRetention  time.Duration `json:"-"`
+	ItemHistoryTimeSeries bool          `json:"item_history_time_series"`
+	ItemHistoryMaxPoints  int           `json:"item_history_max_points"`
+	LoginEventRetention   time.Duration `json:"-"`
+	RedisEnabled          bool          `json:"redis_enabled"`
+	RedisAddress          string        `json:"redis_address"`
+	RedisPassword         string        `json:"-"`
+	RedisDB               int           `json:"redis_db"`
+	FetcherEnabled        bool          `json:"fetcher_enabled"`
+	FetchDataInterval     time.Duration `json:"-"`
+	LogLevel              logger.Level  `json:"-"`
+	LogToFile             bool          `json:"log_to_file"`
+	AuthSecretKey         jwk.Key       `json:"-"`
+	FCMKey                string        `json:"-"`
+	FCMServiceAccountFile string        `json:"fcm_service_account_file"`
+	GoogleClientIDs       []string      `json:"google_client_ids"`
+	TelegramBotToken      string        `json:"-"`
+	// TelegramBotUsername makes Telegram link tokens come with a t.me deep link, TelegramWebhookURL is where
+	// Telegram posts the messages sent to the bot, registered on startup when set.
+	TelegramBotUsername string `json:"telegram_bot_username"`
+	TelegramWebhookURL  string `json:"telegram_webhook_url"`
+	AdminAPIKey         string `json:"-"`
+	MidtransServerKey   string `json:"-"`
+	GoUPCAPIKey         string `json:"-"`
+
+	EmailDomainAllowlist                  []string      `json:"email_domain_allowlist"`
+	EmailDomainDenylist                   []string      `json:"email_domain_denylist"`
+	DisposableEmailDomainsFile            string        `json:"disposable_email_domains_file"`
+	DisposableEmailDomainsRefreshInterval time.Duration `json:"-"`
+
+	SiteFingerprintsFile           string        `json:"site_fingerprints_file"`
+	SiteFingerprintsReloadInterval time.Duration `json:"-"`
+
+	ShopeeHeadlessFallback bool          `json:"shopee_headless_fallback"`
+	HeadlessBrowserPath    string        `json:"headless_browser_path"`
+	HeadlessBrowserTimeout time.Duration `json:"-"`
+
+	VisionURL string `json:"vision_url"`
+
+	EbayClientID      string `json:"ebay_client_id"`
+	EbayClientSecret  string `json:"-"`
+	EbayMarketplaceID string `json:"ebay_marketplace_id"`
+
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"-"`
+	SMTPFrom     string `json:"smtp_from"`
+
+	SiteRateLimits map[string]client.SiteLimit `json:"site_rate_limits"`
+
+	FetcherWorkersPerSite int `json:"fetcher_workers_per_site"`
+	FetcherQueueSize      int `json:"fetcher_queue_size"`
+
+	ClientCacheTTLs map[string]time.Duration `json:"-"`
+
+	// SearchResultLimits is how many search results of each site in it are returned.
+	SearchResultLimits map[string]int `json:"search_result_limits"`
+
+	OrphanedItemGracePeriod time.Duration `json:"-"`
+
+	ReferralRewardTrackedItems int `json:"referral_reward_tracked_items"`
+	PremiumDurationDays        int `json:"premium_duration_days"`
+	// PremiumPrice is the price of a premium purchase in IDR, payments of less do not activate premium.
+	PremiumPrice int `json:"premium_price"`
+	// TrackedItemsLimits overrides the tracked items limit of the tiers in it.
+	TrackedItemsLimits map[string]int `json:"tracked_items_limits"`
+
+	NotificationCooldown           time.Duration `json:"-"`
+	NotificationMaxPerThresholdHit int           `json:"notification_max_per_threshold_hit"`
+	NotificationBatchWindow        time.Duration `json:"-"`
+
+	// TracingOTLPEndpoint is the OTLP/HTTP endpoint spans are exported to, tracing is disabled when it is empty.
+	TracingOTLPEndpoint string  `json:"tracing_otlp_endpoint"`
+	TracingServiceName  string  `json:"tracing_service_name"`
+	TracingSampleRatio  float64 `json:"tracing_sample_ratio"`
+	// TracingTrustTraceparent continues the traces of the traceparent headers of API requests, for deployments whose
+	// clients are trusted, otherwise every API request starts a trace of its own.
+	TracingTrustTraceparent bool `json:"tracing_trust_traceparent"`
 }
 
 type tomlConfig struct {
-	ServerEnabled     bool   `toml:"server_enabled"`
-	ServerAddress     string `toml:"server_address"`
-	DatabaseURI       string `toml:"database_uri"`
-	FetcherEnabled    bool   `toml:"fetcher_enabled"`
-	FetchDataInterval string `toml:"fetch_data_interval"`
-	LogLevel          string `toml:"log_level"`
-	LogToFile         bool   `toml:"log_to_file"`
-	AuthSecretKey     string `toml:"auth_secret_key"`
-	FCMKey            string `toml:"fcm_key"`
+	ServerEnabled         bool     `toml:"server_enabled"`
+	ServerAddress         string   `toml:"server_address"`
+	TLSCertFile           string   `toml:"tls_cert_file"`
+	TLSKeyFile            string   `toml:"tls_key_file"`
+	AutocertDomains       []string `toml:"autocert_domains"`
+	AutocertEmail         string   `toml:"autocert_email"`
+	AutocertCacheDir      string   `toml:"autocert_cache_dir"`
+	HTTPRedirectAddress   string   `toml:"http_redirect_address"`
+	DatabaseURI           string   `toml:"database_uri"`
+	DatabaseEnsureIndexes *bool    `toml:"database_ensure_indexes"`
+	DatabaseTransactions  bool     `toml:"database_transactions"`
+	ItemHistoryRetention  string   `toml:"item_history_retention"`
+	ItemHistoryTimeSeries bool     `toml:"item_history_time_series"`
+	ItemHistoryMaxPoints  int      `toml:"item_history_max_points"`
+	LoginEventRetention   string   `toml:"login_event_retention"`
+	RedisEnabled          *bool    `toml:"redis_enabled"`
+	RedisAddress          string   `toml:"redis_address"`
+	RedisPassword         string   `toml:"redis_password"`
+	RedisDB               int      `toml:"redis_db"`
+	FetcherEnabled        bool     `toml:"fetcher_enabled"`
+	FetchDataInterval     string   `toml:"fetch_data_interval"`
+	LogLevel              string   `toml:"log_level"`
+	LogToFile             bool     `toml:"log_to_file"`
+	AuthSecretKey         string   `toml:"auth_secret_key"`
+	FCMKey                string   `toml:"fcm_key"`
+	FCMServiceAccountFile string   `toml:"fcm_service_account_file"`
+	GoogleClientIDs       []string `toml:"google_client_ids"`
+	TelegramBotToken      string   `toml:"telegram_bot_token"`
+	TelegramBotUsername   string   `toml:"telegram_bot_username"`
+	TelegramWebhookURL    string   `toml:"telegram_webhook_url"`
+	AdminAPIKey           string   `toml:"admin_api_key"`
+	MidtransServerKey     string   `toml:"midtrans_server_key"`
+	GoUPCAPIKey           string   `toml:"go_upc_api_key"`
+
+	EmailDomainAllowlist                  []string `toml:"email_domain_allowlist"`
+	EmailDomainDenylist                   []string `toml:"email_domain_denylist"`
+	DisposableEmailDomainsFile            string   `toml:"disposable_email_domains_file"`
+	DisposableEmailDomainsRefreshInterval string   `toml:"disposable_email_domains_refresh_interval"`
+
+	SiteFingerprintsFile           string `toml:"site_fingerprints_file"`
+	SiteFingerprintsReloadInterval string `toml:"site_fingerprints_reload_interval"`
+
+	ShopeeHeadlessFallback bool   `toml:"shopee_headless_fallback"`
+	HeadlessBrowserPath    string `toml:"headless_browser_path"`
+	HeadlessBrowserTimeout string `toml:"headless_browser_timeout"`
+
+	VisionURL string `toml:"vision_url"`
+
+	EbayClientID      string `toml:"ebay_client_id"`
+	EbayClientSecret  string `toml:"ebay_client_secret"`
+	EbayMarketplaceID string `toml:"ebay_marketplace_id"`
+
+	SMTPHost     string `toml:"smtp_host"`
+	SMTPPort     int    `toml:"smtp_port"`
+	SMTPUsername string `toml:"smtp_username"`
+	SMTPPassword string `toml:"smtp_password"`
+	SMTPFrom     string `toml:"smtp_from"`
+
+	SiteRateLimits map[string]tomlSiteRateLimit `toml:"site_rate_limits"`
+
+	FetcherWorkersPerSite int `toml:"fetcher_workers_per_site"`
+	FetcherQueueSize      int `toml:"fetcher_queue_size"`
+
+	ClientCacheTTLs map[string]string `toml:"client_cache_ttls"`
+
+	SearchResultLimits map[string]int `toml:"search_result_limits"`
+
+	OrphanedItemGracePeriod string `toml:"orphaned_item_grace_period"`
+
+	ReferralRewardTrackedItems *int           `toml:"referral_reward_tracked_items"`
+	PremiumDurationDays        int            `toml:"premium_duration_days"`
+	PremiumPrice               int            `toml:"premium_price"`
+	TrackedItemsLimits         map[string]int `toml:"tracked_items_limits"`
+
+	NotificationCooldown           string `toml:"notification_cooldown"`
+	NotificationMaxPerThresholdHit *int   `toml:"notification_max_per_threshold_hit"`
+	NotificationBatchWindow        string `toml:"notification_batch_window"`
+
+	TracingOTLPEndpoint     string   `toml:"tracing_otlp_endpoint"`
+	TracingServiceName      string   `toml:"tracing_service_name"`
+	TracingSampleRatio      *float64 `toml:"tracing_sample_ratio"`
+	TracingTrustTraceparent bool     `toml:"tracing_trust_traceparent"`
+}
+
+type tomlSiteRateLimit struct {
+	RequestsPerSecond float64 `toml:"requests_per_second"`
+	Burst             int     `toml:"burst"`
+	BackoffInitial    string  `toml:"backoff_initial"`
+	BackoffMax        string  `toml:"backoff_max"`
 }
 
 func GetConfig(path string) (*Config, error) {
 	var tc tomlConfig
+	// The file is optional when the configuration is given entirely through environment variables.
 	md, err := toml.DecodeFile(path, &tc)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return nil, errors.Wrapf(err, "failed to decode toml file with path: %s", path)
 	}
+	envDefined, err := applyEnvOverrides(&tc)
+	if err != nil {
+		return nil, err
+	}
+	isDefined := func(key string) bool {
+		return md.IsDefined(key) || envDefined[key]
+	}
 
-	if !md.IsDefined("server_enabled") {
+	if !isDefined("server_enabled") {
 		return nil, errors.New("server_enabled option is not set")
 	}
 
@@ -48,11 +226,75 @@ func GetConfig(path string) (*Config, error) {
 		tc.ServerAddress = "localhost:8888"
 	}
 
+	if (tc.TLSCertFile == "") != (tc.TLSKeyFile == "") {
+		return nil, errors.New("tls_cert_file and tls_key_file must be set together")
+	}
+	if len(tc.AutocertDomains) > 0 {
+		if tc.TLSCertFile != "" {
+			return nil, errors.New("autocert_domains can not be set together with tls_cert_file and tls_key_file")
+		}
+		if tc.AutocertCacheDir == "" {
+			tc.AutocertCacheDir = "autocert"
+		}
+		if tc.HTTPRedirectAddress == "" {
+			// The HTTP-01 challenge is always served on port 80.
+			tc.HTTPRedirectAddress = ":80"
+		}
+	}
+	if tc.HTTPRedirectAddress != "" && tc.TLSCertFile == "" && len(tc.AutocertDomains) == 0 {
+		return nil, errors.New("http_redirect_address is set but TLS is not enabled")
+	}
+
 	if tc.DatabaseURI == "" {
 		tc.DatabaseURI = "mongodb://localhost:27017"
 	}
 
-	if !md.IsDefined("fetcher_enabled") {
+	var itemHistoryRetention time.Duration
+	if tc.ItemHistoryRetention != "" {
+		itemHistoryRetention, err = time.ParseDuration(tc.ItemHistoryRetention)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse item_history_retention")
+		}
+		if itemHistoryRetention < 24*time.Hour {
+			return nil, errors.Errorf("item_history_retention too short (%v), minimum retention: 24h", itemHistoryRetention)
+		}
+	}
+	if tc.LoginEventRetention == "" {
+		tc.LoginEventRetention = "2160h"
+	}
+	loginEventRetention, err := time.ParseDuration(tc.LoginEventRetention)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse login_event_retention")
+	}
+	if loginEventRetention < 24*time.Hour && loginEventRetention != 0 {
+		return nil, errors.Errorf("login_event_retention too short (%v), minimum retention: 24h", loginEventRetention)
+	}
+	if tc.ItemHistoryMaxPoints < 0 {
+		return nil, errors.Errorf("item_history_max_points is negative (%d)", tc.ItemHistoryMaxPoints)
+	}
+	// Time-series collections can not be written to in transactions.
+	if tc.DatabaseTransactions && tc.ItemHistoryTimeSeries {
+		return nil, errors.New("database_transactions can not be set together with item_history_time_series")
+	}
+
+	// The unique and text indexes are relied on, so they are ensured unless explicitly disabled.
+	databaseEnsureIndexes := true
+	if tc.DatabaseEnsureIndexes != nil {
+		databaseEnsureIndexes = *tc.DatabaseEnsureIndexes
+	}
+
+	redisEnabled := true
+	if tc.RedisEnabled != nil {
+		redisEnabled = *tc.RedisEnabled
+	}
+	if tc.RedisAddress == "" {
+		tc.RedisAddress = "localhost:6379"
+	}
+	if tc.RedisDB < 0 || tc.RedisDB > 15 {
+		return nil, errors.Errorf("redis_db out of range (%d), maximum db: 15", tc.RedisDB)
+	}
+
+	if !isDefined("fetcher_enabled") {
 		return nil, errors.New("fetcher_enabled option is not set")
 	}
 
@@ -84,20 +326,318 @@ func GetConfig(path string) (*Config, error) {
 		return nil, errors.Wrap(err, "failed to create key from auth_secret_key")
 	}
 
-	if tc.FCMKey == "" {
-		return nil, errors.New("fcm_key is not set")
+	if tc.FCMKey == "" && tc.FCMServiceAccountFile == "" {
+		return nil, errors.New("fcm_key or fcm_service_account_file is not set")
+	}
+
+	if tc.AdminAPIKey != "" && len(tc.AdminAPIKey) < 32 {
+		return nil, errors.Errorf("admin_api_key too short (%d), minimum length: 32", len(tc.AdminAPIKey))
+	}
+
+	var disposableEmailDomainsRefreshInterval time.Duration
+	if tc.DisposableEmailDomainsFile != "" {
+		if tc.DisposableEmailDomainsRefreshInterval == "" {
+			tc.DisposableEmailDomainsRefreshInterval = "24h"
+		}
+		disposableEmailDomainsRefreshInterval, err = time.ParseDuration(tc.DisposableEmailDomainsRefreshInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse disposable_email_domains_refresh_interval")
+		}
+		if disposableEmailDomainsRefreshInterval < time.Minute {
+			return nil, errors.Errorf("disposable_email_domains_refresh_interval too short (%v), minimum interval: 1m",
+				disposableEmailDomainsRefreshInterval)
+		}
+	}
+
+	var siteFingerprintsReloadInterval time.Duration
+	if tc.SiteFingerprintsFile != "" {
+		if tc.SiteFingerprintsReloadInterval == "" {
+			tc.SiteFingerprintsReloadInterval = "1m"
+		}
+		siteFingerprintsReloadInterval, err = time.ParseDuration(tc.SiteFingerprintsReloadInterval)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse site_fingerprints_reload_interval")
+		}
+		if siteFingerprintsReloadInterval < 10*time.Second {
+			return nil, errors.Errorf("site_fingerprints_reload_interval too short (%v), minimum interval: 10s",
+				siteFingerprintsReloadInterval)
+		}
+	}
+
+	var headlessBrowserTimeout time.Duration
+	if tc.ShopeeHeadlessFallback {
+		if tc.HeadlessBrowserTimeout == "" {
+			tc.HeadlessBrowserTimeout = "30s"
+		}
+		headlessBrowserTimeout, err = time.ParseDuration(tc.HeadlessBrowserTimeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse headless_browser_timeout")
+		}
+		if headlessBrowserTimeout < 5*time.Second {
+			return nil, errors.Errorf("headless_browser_timeout too short (%v), minimum timeout: 5s", headlessBrowserTimeout)
+		}
+	}
+
+	if tc.SMTPHost != "" {
+		if tc.SMTPPort == 0 {
+			tc.SMTPPort = 587
+		}
+		if tc.SMTPPort < 0 || tc.SMTPPort > 65535 {
+			return nil, errors.Errorf("smtp_port out of range (%d)", tc.SMTPPort)
+		}
+		if _, err = mail.ParseAddress(tc.SMTPFrom); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse smtp_from")
+		}
+	}
+
+	siteRateLimits := make(map[string]client.SiteLimit, len(tc.SiteRateLimits))
+	for site, tl := range tc.SiteRateLimits {
+		l, ok := client.DefaultSiteLimits[site]
+		if !ok {
+			return nil, errors.Errorf("unknown site in site_rate_limits: %s", site)
+		}
+		if tl.RequestsPerSecond != 0 {
+			l.RequestsPerSecond = tl.RequestsPerSecond
+		}
+		if tl.Burst != 0 {
+			l.Burst = tl.Burst
+		}
+		if tl.BackoffInitial != "" {
+			if l.BackoffInitial, err = time.ParseDuration(tl.BackoffInitial); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse site_rate_limits.%s.backoff_initial", site)
+			}
+		}
+		if tl.BackoffMax != "" {
+			if l.BackoffMax, err = time.ParseDuration(tl.BackoffMax); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse site_rate_limits.%s.backoff_max", site)
+			}
+		}
+		if l.RequestsPerSecond < 0 || l.Burst < 1 {
+			return nil, errors.Errorf("invalid site_rate_limits.%s (%v requests per second, burst %d), minimum burst: 1",
+				site, l.RequestsPerSecond, l.Burst)
+		}
+		if l.BackoffInitial <= 0 || l.BackoffMax < l.BackoffInitial {
+			return nil, errors.Errorf("invalid site_rate_limits.%s backoff (initial %v, max %v), max must not be less than initial",
+				site, l.BackoffInitial, l.BackoffMax)
+		}
+		siteRateLimits[site] = l
+	}
+
+	if tc.FetcherWorkersPerSite == 0 {
+		tc.FetcherWorkersPerSite = 2
+	}
+	if tc.FetcherWorkersPerSite < 0 || tc.FetcherWorkersPerSite > 16 {
+		return nil, errors.Errorf("fetcher_workers_per_site out of range (%d), maximum workers: 16", tc.FetcherWorkersPerSite)
+	}
+	if tc.FetcherQueueSize == 0 {
+		tc.FetcherQueueSize = 100
+	}
+	if tc.FetcherQueueSize < 0 {
+		return nil, errors.Errorf("fetcher_queue_size is negative (%d)", tc.FetcherQueueSize)
+	}
+
+	clientCacheTTLs := make(map[string]time.Duration, len(client.DefaultCacheTTLs))
+	for op, ttl := range client.DefaultCacheTTLs {
+		clientCacheTTLs[op] = ttl
+	}
+	for op, s := range tc.ClientCacheTTLs {
+		baseOp, site, perSite := strings.Cut(op, ".")
+		if _, ok := client.DefaultCacheTTLs[baseOp]; !ok {
+			return nil, errors.Errorf("unknown operation in client_cache_ttls: %s", op)
+		}
+		if _, ok := client.DefaultSiteLimits[site]; perSite && !ok {
+			return nil, errors.Errorf("unknown site in client_cache_ttls: %s", op)
+		}
+		ttl, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse client_cache_ttls.%s", op)
+		}
+		if ttl < 0 {
+			return nil, errors.Errorf("client_cache_ttls.%s is negative (%v)", op, ttl)
+		}
+		clientCacheTTLs[op] = ttl
+	}
+
+	for site, limit := range tc.SearchResultLimits {
+		if _, ok := client.DefaultSiteLimits[site]; !ok {
+			return nil, errors.Errorf("unknown site in search_result_limits: %s", site)
+		}
+		if limit < 1 || limit > searchResultLimitMax {
+			return nil, errors.Errorf("search_result_limits.%s out of range (%d), minimum: 1, maximum: %d",
+				site, limit, searchResultLimitMax)
+		}
+	}
+
+	if tc.OrphanedItemGracePeriod == "" {
+		tc.OrphanedItemGracePeriod = "168h"
+	}
+	orphanedItemGracePeriod, err := time.ParseDuration(tc.OrphanedItemGracePeriod)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse orphaned_item_grace_period")
+	}
+	if orphanedItemGracePeriod < 0 {
+		return nil, errors.Errorf("orphaned_item_grace_period is negative (%v)", orphanedItemGracePeriod)
+	}
+
+	referralRewardTrackedItems := 10
+	if tc.ReferralRewardTrackedItems != nil {
+		if *tc.ReferralRewardTrackedItems < 0 {
+			return nil, errors.Errorf("referral_reward_tracked_items is negative (%d)", *tc.ReferralRewardTrackedItems)
+		}
+		referralRewardTrackedItems = *tc.ReferralRewardTrackedItems
+	}
+
+	if tc.PremiumDurationDays == 0 {
+		tc.PremiumDurationDays = 30
+	}
+	if tc.PremiumDurationDays < 0 {
+		return nil, errors.Errorf("premium_duration_days is negative (%d)", tc.PremiumDurationDays)
+	}
+	if tc.PremiumPrice < 0 {
+		return nil, errors.Errorf("premium_price is negative (%d)", tc.PremiumPrice)
+	}
+	if tc.MidtransServerKey != "" && tc.PremiumPrice == 0 {
+		return nil, errors.New("premium_price must be set together with midtrans_server_key")
+	}
+
+	for tier, limit := range tc.TrackedItemsLimits {
+		if tier != model.TierFree && tier != model.TierPremium {
+			return nil, errors.Errorf("unknown tier in tracked_items_limits: %s", tier)
+		}
+		if limit <= 0 {
+			return nil, errors.Errorf("tracked_items_limits.%s is not positive (%d)", tier, limit)
+		}
+	}
+
+	if tc.NotificationCooldown == "" {
+		tc.NotificationCooldown = "1h"
+	}
+	notificationCooldown, err := time.ParseDuration(tc.NotificationCooldown)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notification_cooldown")
+	}
+	if notificationCooldown < 0 {
+		return nil, errors.Errorf("notification_cooldown is negative (%v)", notificationCooldown)
+	}
+
+	notificationMaxPerThresholdHit := 3
+	if tc.NotificationMaxPerThresholdHit != nil {
+		if *tc.NotificationMaxPerThresholdHit < 0 {
+			return nil, errors.Errorf("notification_max_per_threshold_hit is negative (%d)", *tc.NotificationMaxPerThresholdHit)
+		}
+		notificationMaxPerThresholdHit = *tc.NotificationMaxPerThresholdHit
+	}
+
+	if tc.NotificationBatchWindow == "" {
+		tc.NotificationBatchWindow = "2m"
+	}
+	notificationBatchWindow, err := time.ParseDuration(tc.NotificationBatchWindow)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notification_batch_window")
+	}
+	if notificationBatchWindow < 0 || notificationBatchWindow > 15*time.Minute {
+		return nil, errors.Errorf("notification_batch_window out of range (%v), maximum window: 15m", notificationBatchWindow)
+	}
+
+	if tc.TracingOTLPEndpoint != "" {
+		if u, err := url.Parse(tc.TracingOTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("tracing_otlp_endpoint is not an http(s) URL: %#v", tc.TracingOTLPEndpoint)
+		}
+	}
+	if tc.TracingServiceName == "" {
+		tc.TracingServiceName = "pricetracker"
+	}
+	tracingSampleRatio := 1.0
+	if tc.TracingSampleRatio != nil {
+		if *tc.TracingSampleRatio < 0 || *tc.TracingSampleRatio > 1 {
+			return nil, errors.Errorf("tracing_sample_ratio out of range (%v), minimum: 0, maximum: 1", *tc.TracingSampleRatio)
+		}
+		tracingSampleRatio = *tc.TracingSampleRatio
 	}
 
 	return &Config{
-		ServerEnabled:     tc.ServerEnabled,
-		ServerAddress:     tc.ServerAddress,
-		DatabaseURI:       tc.DatabaseURI,
-		FetcherEnabled:    tc.FetcherEnabled,
-		FetchDataInterval: fetchDataInterval,
-		LogLevel:          logLevel,
-		LogToFile:         tc.LogToFile,
-		AuthSecretKey:     authSecretKey,
-		FCMKey:            tc.FCMKey,
+		ServerEnabled:         tc.ServerEnabled,
+		ServerAddress:         tc.ServerAddress,
+		TLSCertFile:           tc.TLSCertFile,
+		TLSKeyFile:            tc.TLSKeyFile,
+		AutocertDomains:       tc.AutocertDomains,
+		AutocertEmail:         tc.AutocertEmail,
+		AutocertCacheDir:      tc.AutocertCacheDir,
+		HTTPRedirectAddress:   tc.HTTPRedirectAddress,
+		DatabaseURI:           tc.DatabaseURI,
+		DatabaseEnsureIndexes: databaseEnsureIndexes,
+		DatabaseTransactions:  tc.DatabaseTransactions,
+		ItemHistoryRetention:  itemHistoryRetention,
+		ItemHistoryTimeSeries: tc.ItemHistoryTimeSeries,
+		ItemHistoryMaxPoints:  tc.ItemHistoryMaxPoints,
+		LoginEventRetention:   loginEventRetention,
+		RedisEnabled:          redisEnabled,
+		RedisAddress:          tc.RedisAddress,
+		RedisPassword:         tc.RedisPassword,
+		RedisDB:               tc.RedisDB,
+		FetcherEnabled:        tc.FetcherEnabled,
+		FetchDataInterval:     fetchDataInterval,
+		LogLevel:              logLevel,
+		LogToFile:             tc.LogToFile,
+		AuthSecretKey:         authSecretKey,
+		FCMKey:                tc.FCMKey,
+		FCMServiceAccountFile: tc.FCMServiceAccountFile,
+		GoogleClientIDs:       tc.GoogleClientIDs,
+		TelegramBotToken:      tc.TelegramBotToken,
+		TelegramBotUsername:   tc.TelegramBotUsername,
+		TelegramWebhookURL:    tc.TelegramWebhookURL,
+		AdminAPIKey:           tc.AdminAPIKey,
+		MidtransServerKey:     tc.MidtransServerKey,
+		GoUPCAPIKey:           tc.GoUPCAPIKey,
+
+		EmailDomainAllowlist:                  tc.EmailDomainAllowlist,
+		EmailDomainDenylist:                   tc.EmailDomainDenylist,
+		DisposableEmailDomainsFile:            tc.DisposableEmailDomainsFile,
+		DisposableEmailDomainsRefreshInterval: disposableEmailDomainsRefreshInterval,
+
+		SiteFingerprintsFile:           tc.SiteFingerprintsFile,
+		SiteFingerprintsReloadInterval: siteFingerprintsReloadInterval,
+
+		ShopeeHeadlessFallback: tc.ShopeeHeadlessFallback,
+		HeadlessBrowserPath:    tc.HeadlessBrowserPath,
+		HeadlessBrowserTimeout: headlessBrowserTimeout,
+
+		VisionURL: tc.VisionURL,
+
+		EbayClientID:      tc.EbayClientID,
+		EbayClientSecret:  tc.EbayClientSecret,
+		EbayMarketplaceID: tc.EbayMarketplaceID,
+
+		SMTPHost:     tc.SMTPHost,
+		SMTPPort:     tc.SMTPPort,
+		SMTPUsername: tc.SMTPUsername,
+		SMTPPassword: tc.SMTPPassword,
+		SMTPFrom:     tc.SMTPFrom,
+
+		SiteRateLimits: siteRateLimits,
+
+		FetcherWorkersPerSite: tc.FetcherWorkersPerSite,
+		FetcherQueueSize:      tc.FetcherQueueSize,
+
+		ClientCacheTTLs: clientCacheTTLs,
+
+		SearchResultLimits: tc.SearchResultLimits,
+
+		OrphanedItemGracePeriod: orphanedItemGracePeriod,
+
+		ReferralRewardTrackedItems: referralRewardTrackedItems,
+		PremiumDurationDays:        tc.PremiumDurationDays,
+		PremiumPrice:               tc.PremiumPrice,
+		TrackedItemsLimits:         tc.TrackedItemsLimits,
+
+		NotificationCooldown:           notificationCooldown,
+		NotificationMaxPerThresholdHit: notificationMaxPerThresholdHit,
+		NotificationBatchWindow:        notificationBatchWindow,
+
+		TracingOTLPEndpoint:     tc.TracingOTLPEndpoint,
+		TracingServiceName:      tc.TracingServiceName,
+		TracingSampleRatio:      tracingSampleRatio,
+		TracingTrustTraceparent: tc.TracingTrustTraceparent,
 	}, nil
 }
 
@@ -109,15 +649,66 @@ func (c Config) MarshalJSON() ([]byte, error) {
 		FetchDataInterval string `json:"fetch_data_interval"`
 		AuthSecretKey     string `json:"auth_secret_key"`
 		FCMKey            string `json:"fcm_key"`
+		TelegramBotToken  string `json:"telegram_bot_token"`
+		AdminAPIKey       string `json:"admin_api_key"`
+		MidtransServerKey string `json:"midtrans_server_key"`
+		GoUPCAPIKey       string `json:"go_upc_api_key"`
+		EbayClientSecret  string `json:"ebay_client_secret"`
+		SMTPPassword      string `json:"smtp_password"`
+		RedisPassword     string `json:"redis_password"`
+
+		ItemHistoryRetention                  string `json:"item_history_retention"`
+		LoginEventRetention                   string `json:"login_event_retention"`
+		DisposableEmailDomainsRefreshInterval string `json:"disposable_email_domains_refresh_interval"`
+		SiteFingerprintsReloadInterval        string `json:"site_fingerprints_reload_interval"`
+		HeadlessBrowserTimeout                string `json:"headless_browser_timeout"`
+		OrphanedItemGracePeriod               string `json:"orphaned_item_grace_period"`
+		NotificationCooldown                  string `json:"notification_cooldown"`
+		NotificationBatchWindow               string `json:"notification_batch_window"`
+
+		ClientCacheTTLs map[string]string `json:"client_cache_ttls"`
 	}
 	mt := myType{localConfig: localConfig(c)}
 	mt.LogLevel = c.LogLevel.String()
 	mt.FetchDataInterval = c.FetchDataInterval.String()
+	mt.ItemHistoryRetention = c.ItemHistoryRetention.String()
+	mt.LoginEventRetention = c.LoginEventRetention.String()
+	mt.DisposableEmailDomainsRefreshInterval = c.DisposableEmailDomainsRefreshInterval.String()
+	mt.SiteFingerprintsReloadInterval = c.SiteFingerprintsReloadInterval.String()
+	mt.HeadlessBrowserTimeout = c.HeadlessBrowserTimeout.String()
+	mt.OrphanedItemGracePeriod = c.OrphanedItemGracePeriod.String()
+	mt.NotificationCooldown = c.NotificationCooldown.String()
+	mt.NotificationBatchWindow = c.NotificationBatchWindow.String()
+	mt.ClientCacheTTLs = make(map[string]string, len(c.ClientCacheTTLs))
+	for op, ttl := range c.ClientCacheTTLs {
+		mt.ClientCacheTTLs[op] = ttl.String()
+	}
 	if len(c.FCMKey) > 21 {
 		mt.FCMKey = c.FCMKey[:21] + "..."
 	} else {
 		mt.FCMKey = c.FCMKey
 	}
 	mt.AuthSecretKey = "SET"
+	if c.TelegramBotToken != "" {
+		mt.TelegramBotToken = "SET"
+	}
+	if c.AdminAPIKey != "" {
+		mt.AdminAPIKey = "SET"
+	}
+	if c.MidtransServerKey != "" {
+		mt.MidtransServerKey = "SET"
+	}
+	if c.GoUPCAPIKey != "" {
+		mt.GoUPCAPIKey = "SET"
+	}
+	if c.EbayClientSecret != "" {
+		mt.EbayClientSecret = "SET"
+	}
+	if c.SMTPPassword != "" {
+		mt.SMTPPassword = "SET"
+	}
+	if c.RedisPassword != "" {
+		mt.RedisPassword = "SET"
+	}
 	return json.Marshal(mt)
 }
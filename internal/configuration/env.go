@@ -0,0 +1,134 @@
+package configuration
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding config.toml keys, the variable name of a key is the
+// prefix followed by the upper-cased key, e.g. PRICETRACKER_DATABASE_URI. Keys of tables are joined with underscores,
+// e.g. PRICETRACKER_SITE_RATE_LIMITS_SHOPEE_BURST, and arrays are comma separated.
+const EnvPrefix = "PRICETRACKER_"
+
+// applyEnvOverrides sets the fields of tc from the environment and returns the keys that were set.
+func applyEnvOverrides(tc *tomlConfig) (map[string]bool, error) {
+	defined := make(map[string]bool)
+	v := reflect.ValueOf(tc).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("toml")
+		name := EnvPrefix + strings.ToUpper(key)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Map {
+			set, err := setEnvTable(fv, name+"_")
+			if err != nil {
+				return nil, err
+			}
+			defined[key] = set
+			continue
+		}
+
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, s); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse environment variable %s", name)
+		}
+		defined[key] = true
+	}
+	return defined, nil
+}
+
+// setEnvTable sets the entries of the map m from the environment variables named prefix + upper-cased key,
+// or prefix + upper-cased table name + _ + upper-cased key for maps of tables.
+func setEnvTable(m reflect.Value, prefix string) (bool, error) {
+	et := m.Type().Elem()
+	set := false
+	for _, env := range os.Environ() {
+		name, s, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if m.IsNil() {
+			m.Set(reflect.MakeMap(m.Type()))
+		}
+		if et.Kind() != reflect.Struct {
+			entry := reflect.New(et).Elem()
+			if err := setEnvValue(entry, s); err != nil {
+				return false, errors.Wrapf(err, "failed to parse environment variable %s", name)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.ToLower(rest)), entry)
+			set = true
+			continue
+		}
+		for i := 0; i < et.NumField(); i++ {
+			suffix := "_" + strings.ToUpper(et.Field(i).Tag.Get("toml"))
+			if !strings.HasSuffix(rest, suffix) || len(rest) == len(suffix) {
+				continue
+			}
+			table := reflect.ValueOf(strings.ToLower(strings.TrimSuffix(rest, suffix)))
+			entry := reflect.New(et).Elem()
+			if existing := m.MapIndex(table); existing.IsValid() {
+				entry.Set(existing)
+			}
+			if err := setEnvValue(entry.Field(i), s); err != nil {
+				return false, errors.Wrapf(err, "failed to parse environment variable %s", name)
+			}
+			m.SetMapIndex(table, entry)
+			set = true
+			break
+		}
+	}
+	return set, nil
+}
+
+func setEnvValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		if err := setEnvValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported type %v", v.Type())
+		}
+		var ss []string
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				ss = append(ss, e)
+			}
+		}
+		v.Set(reflect.ValueOf(ss))
+	default:
+		return errors.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
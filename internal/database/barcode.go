@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"pricetracker/internal/model"
 )
 
@@ -12,3 +14,64 @@ func (db Database) BarcodeFind(ctx context.Context, barcodeNumber string) (model
 	err := db.Collection(CollectionBarcodes).FindOne(ctx, bson.M{"barcode": barcodeNumber}).Decode(&b)
 	return b, errors.WithMessagef(err, "error finding barcode: %s", barcodeNumber)
 }
+
+func (db Database) BarcodeInsert(ctx context.Context, b model.Barcode) error {
+	_, err := db.Collection(CollectionBarcodes).InsertOne(ctx, b)
+	return errors.Wrapf(err, "error inserting Barcode: %+v", b)
+}
+
+func (db Database) BarcodeUpdate(ctx context.Context, b model.Barcode) error {
+	res, err := db.Collection(CollectionBarcodes).UpdateOne(
+		ctx,
+		bson.M{"barcode": b.BarcodeNumber},
+		bson.M{"$set": bson.M{
+			"product_name": b.ProductName,
+			"q1":           b.Query1,
+			"q2":           b.Query2,
+			"source":       b.Source,
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error updating Barcode: %+v", b)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "Barcode not found when updating Barcode: %s", b.BarcodeNumber)
+	}
+	return nil
+}
+
+func (db Database) BarcodeDelete(ctx context.Context, barcodeNumber string) error {
+	res, err := db.Collection(CollectionBarcodes).DeleteOne(ctx, bson.M{"barcode": barcodeNumber})
+	if err != nil {
+		return errors.Wrapf(err, "error deleting Barcode: %s", barcodeNumber)
+	}
+	if res.DeletedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "Barcode not found when deleting Barcode: %s", barcodeNumber)
+	}
+	return nil
+}
+
+// BarcodesUpsert inserts or replaces every Barcode in bs by barcode number, it returns the inserted and updated counts.
+func (db Database) BarcodesUpsert(ctx context.Context, bs []model.Barcode) (inserted int, updated int, err error) {
+	if len(bs) == 0 {
+		return 0, 0, nil
+	}
+	models := make([]mongo.WriteModel, 0, len(bs))
+	for _, b := range bs {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"barcode": b.BarcodeNumber}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"barcode":      b.BarcodeNumber,
+				"product_name": b.ProductName,
+				"q1":           b.Query1,
+				"q2":           b.Query2,
+				"source":       b.Source,
+			}}).
+			SetUpsert(true))
+	}
+	res, err := db.Collection(CollectionBarcodes).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "error bulk upserting %d Barcode(s)", len(bs))
+	}
+	return int(res.UpsertedCount), int(res.MatchedCount), nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) BarcodeSubmissionInsert(ctx context.Context, bs model.BarcodeSubmission) (id string, err error) {
+	bs.Status = model.BarcodeSubmissionPending
+	bs.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	res, err := db.Collection(CollectionBarcodeSubmissions).InsertOne(ctx, bs)
+	if err != nil {
+		return "", errors.Wrapf(err, "error inserting BarcodeSubmission: %+v", bs)
+	}
+	objID, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return "", errors.Errorf("error asserting InsertedID type, InsertedID: %#v", res.InsertedID)
+	}
+	return objID.Hex(), nil
+}
+
+func (db Database) BarcodeSubmissionFind(ctx context.Context, id string) (model.BarcodeSubmission, error) {
+	var bs model.BarcodeSubmission
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return bs, errors.Wrapf(err, "error creating ObjectID from hex: %s", id)
+	}
+	err = db.Collection(CollectionBarcodeSubmissions).FindOne(ctx, bson.M{"_id": objID}).Decode(&bs)
+	return bs, errors.Wrapf(err, "error finding BarcodeSubmission with ID: %s", id)
+}
+
+func (db Database) BarcodeSubmissionsFindByStatus(ctx context.Context, status string, limit int64) ([]model.BarcodeSubmission, error) {
+	var bss []model.BarcodeSubmission
+	cur, err := db.Collection(CollectionBarcodeSubmissions).Find(ctx,
+		bson.M{"status": status},
+		options.Find().SetSort(bson.M{"created_at": 1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find BarcodeSubmissions with Status: %s", status)
+	}
+	if err = cur.All(ctx, &bss); err != nil {
+		return nil, errors.Wrapf(err, "error getting BarcodeSubmissions with Status: %s from cursor", status)
+	}
+	return bss, nil
+}
+
+func (db Database) BarcodeSubmissionsPendingCount(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	n, err := db.Collection(CollectionBarcodeSubmissions).CountDocuments(ctx,
+		bson.M{"user_id": userID, "status": model.BarcodeSubmissionPending})
+	return int(n), errors.Wrapf(err, "error counting pending BarcodeSubmissions for UserID: %s", userID.Hex())
+}
+
+// BarcodeSubmissionReview moves a pending BarcodeSubmission to status, it fails with ErrNoDocumentsModified
+// if the submission was already reviewed.
+func (db Database) BarcodeSubmissionReview(
+	ctx context.Context, id string, status string, reviewedBy string, rejectReason string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", id)
+	}
+	res, err := db.Collection(CollectionBarcodeSubmissions).UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "status": model.BarcodeSubmissionPending},
+		bson.M{"$set": bson.M{
+			"status":        status,
+			"reviewed_by":   reviewedBy,
+			"reviewed_at":   primitive.NewDateTimeFromTime(time.Now()),
+			"reject_reason": rejectReason,
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error reviewing BarcodeSubmission with ID: %s, Status: %s", id, status)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "pending BarcodeSubmission not found with ID: %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) BillingEventInsert(ctx context.Context, be model.BillingEvent) (id string, err error) {
+	be.Timestamp = primitive.NewDateTimeFromTime(time.Now())
+	res, err := db.Collection(CollectionBillingEvents).InsertOne(ctx, be)
+	if err != nil {
+		return "", errors.Wrapf(err, "error inserting BillingEvent: %+v", be)
+	}
+	objID, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return "", errors.Errorf("error asserting InsertedID type, InsertedID: %#v", res.InsertedID)
+	}
+	return objID.Hex(), nil
+}
+
+func (db Database) BillingEventUpdate(ctx context.Context, id string, be model.BillingEvent) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", id)
+	}
+	_, err = db.Collection(CollectionBillingEvents).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"applied":    be.Applied,
+			"revoked":    be.Revoked,
+			"tier":       be.Tier,
+			"expires_at": be.ExpiresAt,
+		}},
+	)
+	return errors.Wrapf(err, "error updating BillingEvent with ID: %s", id)
+}
+
+func (db Database) BillingEventDelete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", id)
+	}
+	_, err = db.Collection(CollectionBillingEvents).DeleteOne(ctx, bson.M{"_id": objID})
+	return errors.Wrapf(err, "error deleting BillingEvent with ID: %s", id)
+}
+
+func (db Database) BillingEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.BillingEvent, error) {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating userOID from hex: %s", userID)
+	}
+	var bes []model.BillingEvent
+	cur, err := db.Collection(CollectionBillingEvents).Find(ctx,
+		bson.M{"user_id": userOID},
+		options.Find().SetSort(bson.M{"ts": -1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find BillingEvents for UserID: %s", userID)
+	}
+	if err = cur.All(ctx, &bes); err != nil {
+		return nil, errors.Wrapf(err, "error getting BillingEvents for UserID: %s from cursor", userID)
+	}
+	return bes, nil
+}
+
+// BillingEventsFindByOrder finds the BillingEvents of the order with orderID of provider, oldest first.
+func (db Database) BillingEventsFindByOrder(ctx context.Context, provider string, orderID string) ([]model.BillingEvent, error) {
+	var bes []model.BillingEvent
+	cur, err := db.Collection(CollectionBillingEvents).Find(ctx,
+		bson.M{"provider": provider, "order_id": orderID},
+		options.Find().SetSort(bson.M{"ts": 1}),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find BillingEvents for OrderID: %s", orderID)
+	}
+	if err = cur.All(ctx, &bes); err != nil {
+		return nil, errors.Wrapf(err, "error getting BillingEvents for OrderID: %s from cursor", orderID)
+	}
+	return bes, nil
+}
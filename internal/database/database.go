@@ -3,91 +3,101 @@ package database
 import (
 	"context"
 	"github.com/pkg/errors"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 const (
 	Name                    = "price_tracker_db"
 	CollectionItems         = "items"
 	CollectionItemHistories = "item_histories"
-	CollectionUsers         = "users"
-	CollectionBarcodes      = "barcodes"
+	// CollectionItemHistoriesTimeSeries is the time-series collection replacing item_histories
+	// when ItemHistoryTimeSeries is set.
+	CollectionItemHistoriesTimeSeries = "item_histories_ts"
+	CollectionUsers                   = "users"
+	CollectionBarcodes                = "barcodes"
+	CollectionLoginEvents             = "login_events"
+	CollectionBillingEvents           = "billing_events"
+	CollectionMerchantHistories       = "merchant_histories"
+	CollectionFetchCycles             = "fetch_cycles"
+	CollectionBarcodeSubmissions      = "barcode_submissions"
+	CollectionQueuedNotifications     = "queued_notifications"
+	CollectionUserExports             = "user_exports"
+	CollectionItemMatches             = "item_matches"
+	CollectionSearchHistories         = "search_histories"
+
+	// BucketUserExportFiles is the GridFS bucket storing UserExport archives.
+	BucketUserExportFiles = "user_export_files"
 )
 
 type Database struct {
 	*mongo.Database
+	// ItemHistoryRetention is how long ItemHistory documents are kept before a TTL index expires them,
+	// zero keeps them forever.
+	ItemHistoryRetention time.Duration
+	// ItemHistoryTimeSeries stores ItemHistory in the time-series collection CollectionItemHistoriesTimeSeries,
+	// requires MongoDB 5.0 or later.
+	ItemHistoryTimeSeries bool
+	// ItemHistoryMaxPoints is how many of the newest ItemHistory documents of each Item are kept, older ones are
+	// deleted when ItemHistory is written. Zero keeps them all.
+	ItemHistoryMaxPoints int
+	// LoginEventRetention is how long LoginEvent documents are kept before a TTL index expires them,
+	// zero keeps them forever.
+	LoginEventRetention time.Duration
+	// Transactions runs the multi-document writes of WithTransaction in transactions, requires a replica set or
+	// sharded cluster and can not be used with ItemHistoryTimeSeries.
+	Transactions bool
 }
 
+// UserDevicesLimit is how many Devices a User keeps, the least recently seen ones are removed beyond it.
+const UserDevicesLimit = 5
+
 var ErrNoDocumentsModified = errors.New("no documents modified")
 
-func ConnectDB(ctx context.Context, dbURI string) (*mongo.Client, error) {
-	c, err := mongo.Connect(ctx, options.Client().ApplyURI(dbURI))
-	if err != nil {
-		return nil, err
-	}
+// ErrTrackedItemsLimit is returned when adding TrackedItems to a User would exceed the tracked items limit.
+var ErrTrackedItemsLimit = errors.New("tracked items limit reached")
 
-	_, err = c.Database(Name).Collection(CollectionItems).Indexes().CreateOne(
-		ctx,
-		mongo.IndexModel{
-			Keys: bson.D{
-				{Key: "site", Value: 1},
-				{Key: "merchant_id", Value: 1},
-				{Key: "product_id", Value: 1},
-			},
-			Options: options.Index().SetUnique(true),
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
+// ErrItemVersionConflict is returned when an Item is replaced from an outdated Version.
+var ErrItemVersionConflict = errors.New("Item version conflict")
 
-	_, err = c.Database(Name).Collection(CollectionItemHistories).Indexes().CreateOne(
-		ctx,
-		mongo.IndexModel{
-			Keys: bson.D{
-				{Key: "item_id", Value: 1},
-				{Key: "ts", Value: -1},
-			},
-			Options: options.Index().SetUnique(true),
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
+// ErrNoTransaction is returned by writes that must be done in a transaction of WithTransaction when they are not.
+var ErrNoTransaction = errors.New("not in a transaction")
 
-	_, err = c.Database(Name).Collection(CollectionUsers).Indexes().CreateMany(
-		ctx,
-		[]mongo.IndexModel{
-			{
-				Keys:    bson.D{{Key: "email", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			},
-			{
-				Keys:    bson.D{{Key: "tracked_items.item_id", Value: 1}},
-				Options: options.Index().SetUnique(false),
-			},
-			{
-				Keys:    bson.D{{Key: "devices.fcm_token", Value: 1}},
-				Options: options.Index().SetUnique(true).SetSparse(true),
-			},
-		},
-	)
+// inTransaction returns whether ctx is the ctx of a running transaction of WithTransaction.
+func inTransaction(ctx context.Context) bool {
+	sess, ok := mongo.SessionFromContext(ctx).(mongo.XSession)
+	return ok && sess.ClientSession().TransactionRunning()
+}
+
+// WithTransaction runs fn in a transaction when Transactions is set, the operations of fn are part of the transaction
+// when they are done with the ctx given to fn. The whole transaction, and so fn, is retried on transient errors.
+// fn is run once with ctx as is when Transactions is not set.
+func (db Database) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !db.Transactions {
+		return fn(ctx)
+	}
+	sess, err := db.Client().StartSession()
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, "error starting session")
 	}
+	defer sess.EndSession(ctx)
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
 
-	_, err = c.Database(Name).Collection(CollectionBarcodes).Indexes().CreateOne(
-		ctx,
-		mongo.IndexModel{
-			Keys:    bson.D{{Key: "barcode", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-	)
+// ConnectDB connects to the MongoDB deployment at dbURI, monitor is notified of every command when it is not nil.
+func ConnectDB(ctx context.Context, dbURI string, monitor *event.CommandMonitor) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(dbURI)
+	if monitor != nil {
+		opts.SetMonitor(monitor)
+	}
+	c, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-
 	return c, nil
 }
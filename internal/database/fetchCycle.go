@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// FetchCycleSave inserts fc or replaces it if it was saved before, so progress can be saved while it runs.
+func (db Database) FetchCycleSave(ctx context.Context, fc model.FetchCycle) error {
+	_, err := db.Collection(CollectionFetchCycles).ReplaceOne(ctx, bson.M{"_id": fc.ID}, fc, options.Replace().SetUpsert(true))
+	return errors.Wrapf(err, "error saving FetchCycle: %+v", fc)
+}
+
+func (db Database) FetchCycleFind(ctx context.Context, id string) (model.FetchCycle, error) {
+	var fc model.FetchCycle
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fc, errors.Wrapf(err, "error creating ObjectID from hex: %s", id)
+	}
+	err = db.Collection(CollectionFetchCycles).FindOne(ctx, bson.M{"_id": objID}).Decode(&fc)
+	return fc, errors.Wrapf(err, "error finding FetchCycle with ID: %s", id)
+}
+
+func (db Database) FetchCyclesFindLatest(ctx context.Context, limit int64) ([]model.FetchCycle, error) {
+	var fcs []model.FetchCycle
+	cur, err := db.Collection(CollectionFetchCycles).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.M{"started_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to find FetchCycles")
+	}
+	if err = cur.All(ctx, &fcs); err != nil {
+		return nil, errors.Wrap(err, "error getting FetchCycles from cursor")
+	}
+	return fcs, nil
+}
+
+// FetchCycleFindLastFinished finds the most recently finished FetchCycle of kind.
+func (db Database) FetchCycleFindLastFinished(ctx context.Context, kind string) (model.FetchCycle, error) {
+	var fc model.FetchCycle
+	err := db.Collection(CollectionFetchCycles).FindOne(ctx,
+		bson.M{"kind": kind, "finished_at": bson.M{"$gt": primitive.DateTime(0)}},
+		options.FindOne().SetSort(bson.M{"started_at": -1}),
+	).Decode(&fc)
+	return fc, errors.Wrapf(err, "error finding last finished FetchCycle of kind: %s", kind)
+}
+
+// FetchCyclesFindRunning finds the FetchCycles started after since that have not finished yet,
+// since should be recent enough to exclude cycles that were interrupted without finishing.
+func (db Database) FetchCyclesFindRunning(ctx context.Context, since time.Time) ([]model.FetchCycle, error) {
+	var fcs []model.FetchCycle
+	cur, err := db.Collection(CollectionFetchCycles).Find(ctx, bson.M{
+		"started_at":  bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+		"finished_at": primitive.DateTime(0),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to find running FetchCycles")
+	}
+	if err = cur.All(ctx, &fcs); err != nil {
+		return nil, errors.Wrap(err, "error getting running FetchCycles from cursor")
+	}
+	return fcs, nil
+}
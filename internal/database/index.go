@@ -0,0 +1,422 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+type collectionIndexes struct {
+	collection string
+	indexes    []mongo.IndexModel
+}
+
+// collectionsIndexes are the indexes every collection is expected to have besides _id.
+var collectionsIndexes = []collectionIndexes{
+	{
+		collection: CollectionItems,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "site", Value: 1},
+					{Key: "merchant_id", Value: 1},
+					{Key: "product_id", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "site", Value: 1}, {Key: "product_id", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "barcode", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "updated_at", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "name_tokens", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "recheck_at", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "tracker_count", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "orphaned_at", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true),
+			},
+			{
+				// MongoDB has no Indonesian stemming, words are matched as they are.
+				Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetUnique(false).SetDefaultLanguage("none").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 1}}),
+			},
+			{
+				Keys:    bson.D{{Key: "categories", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true).SetCollation(categoryCollation),
+			},
+		},
+	},
+	{
+		collection: CollectionItemMatches,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "item_id", Value: 1}, {Key: "matched_item_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	},
+	{
+		// The (item_id, ts) index used to be unique, which rejected retried and concurrent history writes,
+		// uniqueness is now enforced per (item_id, fetch_cycle_id) instead.
+		collection: CollectionItemHistories,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "item_id", Value: 1},
+					{Key: "ts", Value: -1},
+				},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys: bson.D{
+					{Key: "item_id", Value: 1},
+					{Key: "fetch_cycle_id", Value: 1},
+				},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"fetch_cycle_id": bson.M{"$exists": true}}),
+			},
+		},
+	},
+	{
+		collection: CollectionUsers,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "tracked_items.item_id", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "devices.fcm_token", Value: 1}},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "telegram.link_token_hash", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "google_id", Value: 1}},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "referral.code", Value: 1}},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "notification.digest", Value: 1}, {Key: "digest_sent_at", Value: 1}},
+				Options: options.Index().SetUnique(false).SetSparse(true),
+			},
+		},
+	},
+	{
+		collection: CollectionBarcodes,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "barcode", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	},
+	{
+		collection: CollectionLoginEvents,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "user_id", Value: 1},
+					{Key: "ts", Value: -1},
+				},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionBillingEvents,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "event_id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "order_id", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "ts", Value: -1}},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionMerchantHistories,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "site", Value: 1},
+					{Key: "merchant_id", Value: 1},
+					{Key: "ts", Value: -1},
+				},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionFetchCycles,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "started_at", Value: -1}},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionBarcodeSubmissions,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionQueuedNotifications,
+		indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "deliver_at", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionUserExports,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "user_id", Value: 1},
+					{Key: "created_at", Value: -1},
+				},
+				Options: options.Index().SetUnique(false),
+			},
+			{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+	{
+		collection: CollectionSearchHistories,
+		indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{
+					{Key: "user_id", Value: 1},
+					{Key: "query", Value: 1},
+					{Key: "barcode", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{
+					{Key: "user_id", Value: 1},
+					{Key: "ts", Value: -1},
+				},
+				Options: options.Index().SetUnique(false),
+			},
+		},
+	},
+}
+
+// itemHistoryTTLIndexKeys are the keys of the TTL index expiring ItemHistory documents.
+var itemHistoryTTLIndexKeys = bson.D{{Key: "ts", Value: 1}}
+
+var loginEventTTLIndexKeys = bson.D{{Key: "ts", Value: 1}}
+
+// itemHistoryTimeSeriesIndexes replace the item_histories indexes on the time-series collection,
+// which does not support unique indexes.
+var itemHistoryTimeSeriesIndexes = collectionIndexes{
+	collection: CollectionItemHistoriesTimeSeries,
+	indexes: []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "item_id", Value: 1},
+				{Key: "ts", Value: -1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				{Key: "item_id", Value: 1},
+				{Key: "fetch_cycle_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	},
+}
+
+// collectionsIndexes returns collectionsIndexes with the ItemHistory TTL index added when ItemHistoryRetention is set,
+// or with the item_histories indexes replaced by itemHistoryTimeSeriesIndexes when ItemHistoryTimeSeries is set.
+// Retention of the time-series collection is a collection option instead of an index.
+// The LoginEvent TTL index is added when LoginEventRetention is set.
+func (db Database) collectionsIndexes() []collectionIndexes {
+	if db.ItemHistoryRetention <= 0 && !db.ItemHistoryTimeSeries && db.LoginEventRetention <= 0 {
+		return collectionsIndexes
+	}
+	cis := make([]collectionIndexes, len(collectionsIndexes))
+	copy(cis, collectionsIndexes)
+	for i, ci := range cis {
+		switch {
+		case ci.collection == CollectionItemHistories && db.ItemHistoryTimeSeries:
+			cis[i] = itemHistoryTimeSeriesIndexes
+		case ci.collection == CollectionItemHistories && db.ItemHistoryRetention > 0:
+			cis[i].indexes = append(ci.indexes[:len(ci.indexes):len(ci.indexes)], mongo.IndexModel{
+				Keys:    itemHistoryTTLIndexKeys,
+				Options: options.Index().SetExpireAfterSeconds(int32(db.ItemHistoryRetention.Seconds())),
+			})
+		case ci.collection == CollectionLoginEvents && db.LoginEventRetention > 0:
+			cis[i].indexes = append(ci.indexes[:len(ci.indexes):len(ci.indexes)], mongo.IndexModel{
+				Keys:    loginEventTTLIndexKeys,
+				Options: options.Index().SetExpireAfterSeconds(int32(db.LoginEventRetention.Seconds())),
+			})
+		}
+	}
+	return cis
+}
+
+// indexName returns the name MongoDB generates for an index with keys.
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", k.Key, k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// EnsureIndexes creates the missing indexes of every collection, indexes whose definition
+// has changed since they were created are dropped and created again.
+// The ItemHistory TTL index is dropped when ItemHistoryRetention is not set or ItemHistoryTimeSeries is set, and the
+// LoginEvent TTL index when LoginEventRetention is not set. The time-series collection is created first when
+// ItemHistoryTimeSeries is set.
+// Index builds can take a long time on large collections so this should be run as an explicit step.
+func (db Database) EnsureIndexes(ctx context.Context) error {
+	if db.ItemHistoryTimeSeries {
+		if err := db.ItemHistoryTimeSeriesEnsure(ctx); err != nil {
+			return err
+		}
+	}
+	for _, ci := range db.collectionsIndexes() {
+		if err := db.collectionIndexesEnsure(ctx, ci); err != nil {
+			return err
+		}
+	}
+	if db.ItemHistoryRetention <= 0 || db.ItemHistoryTimeSeries {
+		if err := db.indexDropIfExists(ctx, CollectionItemHistories, itemHistoryTTLIndexKeys); err != nil {
+			return err
+		}
+	}
+	if db.LoginEventRetention <= 0 {
+		if err := db.indexDropIfExists(ctx, CollectionLoginEvents, loginEventTTLIndexKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexDropIfExists drops the index with keys on collection, an index or collection that does not exist is not an error.
+func (db Database) indexDropIfExists(ctx context.Context, collection string, keys bson.D) error {
+	name := indexName(keys)
+	_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+	var ce mongo.CommandError
+	if err != nil && !(errors.As(err, &ce) && (ce.Name == "IndexNotFound" || ce.Name == "NamespaceNotFound")) {
+		return errors.Wrapf(err, "error dropping index %s on collection %s", name, collection)
+	}
+	return nil
+}
+
+// collectionIndexesEnsure creates the missing indexes of ci, dropping and creating again the ones that have changed.
+func (db Database) collectionIndexesEnsure(ctx context.Context, ci collectionIndexes) error {
+	iv := db.Collection(ci.collection).Indexes()
+	for _, im := range ci.indexes {
+		name := indexName(im.Keys.(bson.D))
+		_, err := iv.CreateOne(ctx, im)
+		if err == nil {
+			continue
+		}
+		var ce mongo.CommandError
+		if !errors.As(err, &ce) || (ce.Name != "IndexOptionsConflict" && ce.Name != "IndexKeySpecsConflict") {
+			return errors.Wrapf(err, "error creating index %s on collection %s", name, ci.collection)
+		}
+		if _, err = iv.DropOne(ctx, name); err != nil {
+			return errors.Wrapf(err, "error dropping conflicting index %s on collection %s", name, ci.collection)
+		}
+		if _, err = iv.CreateOne(ctx, im); err != nil {
+			return errors.Wrapf(err, "error recreating index %s on collection %s", name, ci.collection)
+		}
+	}
+	return nil
+}
+
+type IndexDrift struct {
+	Collection string   `json:"collection"`
+	Missing    []string `json:"missing"`
+	Extra      []string `json:"extra"`
+}
+
+// IndexesDrift compares the indexes of every collection to collectionsIndexes by name,
+// it only returns the collections that have missing or extra indexes.
+func (db Database) IndexesDrift(ctx context.Context) ([]IndexDrift, error) {
+	var drifts []IndexDrift
+	for _, ci := range db.collectionsIndexes() {
+		specs, err := db.Collection(ci.collection).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing indexes on collection %s", ci.collection)
+		}
+		existing := make(map[string]bool, len(specs))
+		for _, spec := range specs {
+			existing[spec.Name] = true
+		}
+		expected := make(map[string]bool, len(ci.indexes))
+		d := IndexDrift{Collection: ci.collection}
+		for _, im := range ci.indexes {
+			name := indexName(im.Keys.(bson.D))
+			expected[name] = true
+			if !existing[name] {
+				d.Missing = append(d.Missing, name)
+			}
+		}
+		for _, spec := range specs {
+			if spec.Name != "_id_" && !expected[spec.Name] {
+				d.Extra = append(d.Extra, spec.Name)
+			}
+		}
+		if len(d.Missing) > 0 || len(d.Extra) > 0 {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
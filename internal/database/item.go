@@ -5,11 +5,33 @@ import (
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"pricetracker/internal/model"
 	"time"
 )
 
+// categoryCollation matches Item categories case-insensitively, queries on categories must use it to use their index.
+var categoryCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// itemCategoryStages are the aggregation stages keeping the documents whose _id is an Item in category,
+// the aggregation must use categoryCollation.
+func itemCategoryStages(category string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{
+			"from":         CollectionItems,
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "item",
+		}}},
+		{{Key: "$match", Value: bson.M{"item.categories": category}}},
+		{{Key: "$project", Value: bson.M{"item": 0}}},
+	}
+}
+
 func (db Database) ItemInsert(ctx context.Context, i model.Item) (id string, err error) {
+	i.NormalizeKeys()
+	i.NameTokens = model.ItemNameTokens(i.Name)
 	i.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	i.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 	r, err := db.Collection(CollectionItems).InsertOne(ctx, i)
@@ -19,30 +41,106 @@ func (db Database) ItemInsert(ctx context.Context, i model.Item) (id string, err
 	return r.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
+// itemUpdateAttempts is how many times ItemUpdateFunc replaces an Item before giving up on version conflicts.
+const itemUpdateAttempts = 3
+
+// ItemUpdate replaces the stored Item with i when its Version is still the Version of i, and increments the Version.
+// The returned error wraps ErrItemVersionConflict when the Item was replaced since i was read, or was deleted.
 func (db Database) ItemUpdate(ctx context.Context, i model.Item) error {
 	if i.ID.IsZero() {
 		return errors.Errorf("Item ID is empty, Item: %+v", i)
 	}
+	filter := bson.M{"_id": i.ID, "version": i.Version}
+	if i.Version == 0 {
+		// Items stored before versioning have no version.
+		filter["version"] = bson.M{"$in": bson.A{nil, 0}}
+	}
+	i.Version++
+	i.NormalizeKeys()
+	i.NameTokens = model.ItemNameTokens(i.Name)
 	i.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	res, err := db.Collection(CollectionItems).ReplaceOne(ctx, bson.M{"_id": i.ID}, i)
+	res, err := db.Collection(CollectionItems).ReplaceOne(ctx, filter, i)
 	if err != nil {
 		return errors.Wrapf(err, "error when updating Item: %+v", i)
 	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrItemVersionConflict, "Item with ID: %s not found with version: %d", i.ID.Hex(), i.Version-1)
+	}
 	if res.ModifiedCount == 0 {
 		return errors.Errorf("Item not modified when updating Item: %+v", i)
 	}
 	return nil
 }
 
+// ItemUpdateFunc applies fn to i and replaces the stored Item with it. When the Item was replaced since i was read,
+// fn is applied to the Item read again and the replacement retried, up to itemUpdateAttempts times. It returns the
+// Item as replaced, or as fn left it on the last attempt when the returned error is not nil.
+func (db Database) ItemUpdateFunc(ctx context.Context, i model.Item, fn func(i *model.Item)) (model.Item, error) {
+	for attempt := 1; ; attempt++ {
+		fn(&i)
+		err := db.ItemUpdate(ctx, i)
+		if err == nil {
+			i.Version++
+			return i, nil
+		}
+		if !errors.Is(err, ErrItemVersionConflict) || attempt == itemUpdateAttempts {
+			return i, err
+		}
+		var current model.Item
+		if err = db.Collection(CollectionItems).FindOne(ctx, bson.M{"_id": i.ID}).Decode(&current); err != nil {
+			return i, errors.Wrapf(err, "error finding Item with ID: %s after version conflict", i.ID.Hex())
+		}
+		i = current
+	}
+}
+
+// ItemPriceUpdate sets the price of the Item with itemID and returns the updated Item. When the price changes, the
+// previous price, the price history bounds, the price change time and the decayed PriceVolatility are updated in the
+// same single update, so concurrent updates of the Item never lose a price change or an extreme.
+func (db Database) ItemPriceUpdate(ctx context.Context, itemID primitive.ObjectID, price int) (model.Item, error) {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	changed := bson.M{"$ne": bson.A{"$price", price}}
+	decayed := bson.M{"$multiply": bson.A{
+		bson.M{"$ifNull": bson.A{"$price_volatility", 0}},
+		bson.M{"$pow": bson.A{0.5, bson.M{"$divide": bson.A{
+			// Items stored before price changes were timed count as changed at the epoch, so fully decayed.
+			bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$price_last_changed_at", primitive.DateTime(0)}}}},
+			model.PriceVolatilityHalfLife.Milliseconds(),
+		}}}},
+	}}
+	var i model.Item
+	err := db.Collection(CollectionItems).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": itemID},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"price":                  price,
+			"price_history_previous": bson.M{"$cond": bson.A{changed, "$price", "$price_history_previous"}},
+			"price_last_changed_at":  bson.M{"$cond": bson.A{changed, now, "$price_last_changed_at"}},
+			"price_volatility": bson.M{"$cond": bson.A{
+				changed, bson.M{"$add": bson.A{decayed, 1}}, "$price_volatility",
+			}},
+			"price_history_highest": bson.M{"$max": bson.A{"$price_history_highest", price}},
+			"price_history_lowest":  bson.M{"$min": bson.A{"$price_history_lowest", price}},
+			"version":               bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+			"updated_at":            now,
+		}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&i)
+	return i, errors.Wrapf(err, "error updating price of Item with ID: %s to: %d", itemID.Hex(), price)
+}
+
+// ItemFindExisting finds the stored Item of the listing of i by its site and canonical ProductID, merchants are left
+// out since the merchant of a listing can be parsed differently between its URLs. The oldest Item is returned when
+// there are duplicates not yet merged by ItemMerge.
 func (db Database) ItemFindExisting(ctx context.Context, i model.Item) (model.Item, error) {
 	var existingI model.Item
 	err := db.Collection(CollectionItems).FindOne(
 		ctx,
 		bson.M{
-			"site":        i.Site,
-			"merchant_id": i.MerchantID,
-			"product_id":  i.ProductID,
+			"site":       i.Site,
+			"product_id": model.CanonicalProductID(i.ProductID),
 		},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}),
 	).Decode(&existingI)
 	return existingI, errors.Wrapf(err, "error trying to find existing Item: %+v", i)
 }
@@ -57,9 +155,45 @@ func (db Database) ItemFindOne(ctx context.Context, itemID string) (model.Item,
 	return i, errors.Wrapf(err, "error finding Item with ID: %s", itemID)
 }
 
+// ItemsInCategory returns the IDs of the Items of itemIDs in category, regardless of case.
+func (db Database) ItemsInCategory(
+	ctx context.Context, itemIDs []primitive.ObjectID, category string) ([]primitive.ObjectID, error) {
+	cur, err := db.Collection(CollectionItems).Find(
+		ctx,
+		bson.M{"_id": bson.M{"$in": itemIDs}, "categories": category},
+		options.Find().SetProjection(bson.M{"_id": 1}).SetCollation(categoryCollation),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find Items in category: %s", category)
+	}
+	var is []model.Item
+	if err = cur.All(ctx, &is); err != nil {
+		return nil, errors.Wrapf(err, "error getting Items in category: %s from cursor", category)
+	}
+	ids := make([]primitive.ObjectID, 0, len(is))
+	for _, i := range is {
+		ids = append(ids, i.ID)
+	}
+	return ids, nil
+}
+
 func (db Database) ItemsFind(ctx context.Context, itemIDs []primitive.ObjectID) ([]model.Item, error) {
+	return db.ItemsFindFields(ctx, itemIDs, nil)
+}
+
+// ItemsFindFields finds the Items of itemIDs with only their fields of the bson field names in fields and their ID,
+// the other fields are left empty. All fields are found when fields is empty.
+func (db Database) ItemsFindFields(ctx context.Context, itemIDs []primitive.ObjectID, fields []string) ([]model.Item, error) {
 	var is []model.Item
-	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{"_id": bson.M{"$in": itemIDs}})
+	opts := options.Find()
+	if len(fields) > 0 {
+		projection := make(bson.M, len(fields))
+		for _, f := range fields {
+			projection[f] = 1
+		}
+		opts.SetProjection(projection)
+	}
+	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{"_id": bson.M{"$in": itemIDs}}, opts)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting cursor to find Items, itemIDs: %v", itemIDs)
 	}
@@ -69,9 +203,81 @@ func (db Database) ItemsFind(ctx context.Context, itemIDs []primitive.ObjectID)
 	return is, nil
 }
 
+func (db Database) ItemsFindBySite(ctx context.Context, site string, merchantID string) ([]model.Item, error) {
+	var is []model.Item
+	filter := bson.M{"site": site, "archived": bson.M{"$ne": true}}
+	if merchantID != "" {
+		filter["merchant_id"] = merchantID
+	}
+	cur, err := db.Collection(CollectionItems).Find(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find Items for Site: %s, MerchantID: %s", site, merchantID)
+	}
+	if err = cur.All(ctx, &is); err != nil {
+		return nil, errors.Wrapf(err, "error getting Items from cursor for Site: %s, MerchantID: %s", site, merchantID)
+	}
+	return is, nil
+}
+
+// ItemsTextSearch returns up to limit tracked and not archived Items whose name or description match the words of query,
+// best match first, after skipping skip Items. Names weigh more than descriptions, ties go to the most tracked Items.
+func (db Database) ItemsTextSearch(ctx context.Context, query string, skip int64, limit int64) ([]model.Item, error) {
+	var is []model.Item
+	cur, err := db.Collection(CollectionItems).Find(
+		ctx,
+		bson.M{
+			"$text":         bson.M{"$search": query},
+			"tracker_count": bson.M{"$gt": 0},
+			"archived":      bson.M{"$ne": true},
+		},
+		options.Find().
+			SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+			SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "tracker_count", Value: -1}}).
+			SetSkip(skip).
+			SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to search Items with query: %#v", query)
+	}
+	if err = cur.All(ctx, &is); err != nil {
+		return nil, errors.Wrapf(err, "error getting Items from cursor, query: %#v", query)
+	}
+	return is, nil
+}
+
+// ItemsMerchantSet sets the merchant name and rating of every Item of the merchant with merchantID on site.
+func (db Database) ItemsMerchantSet(ctx context.Context, site string, merchantID string, name string, rating float64) error {
+	_, err := db.Collection(CollectionItems).UpdateMany(
+		ctx,
+		bson.M{"site": site, "merchant_id": merchantID},
+		bson.M{
+			"$set": bson.M{"merchant_name": name, "merchant_rating": rating},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	return errors.Wrapf(err, "error setting merchant of Items for Site: %s, MerchantID: %s", site, merchantID)
+}
+
+// ItemsRecheckDueFind returns the Items that are not archived and have a recheck due at now.
+func (db Database) ItemsRecheckDueFind(ctx context.Context, now time.Time) ([]model.Item, error) {
+	var is []model.Item
+	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{
+		"recheck_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+		"archived":   bson.M{"$ne": true},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find Items due for a recheck")
+	}
+	if err = cur.All(ctx, &is); err != nil {
+		return nil, errors.Wrapf(err, "error getting Items due for a recheck from cursor")
+	}
+	return is, nil
+}
+
+// ItemsFindAll returns every Item that is not archived.
 func (db Database) ItemsFindAll(ctx context.Context) ([]model.Item, error) {
 	var is []model.Item
-	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{})
+	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{"archived": bson.M{"$ne": true}})
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting cursor to find all Items")
 	}
@@ -80,3 +286,220 @@ func (db Database) ItemsFindAll(ctx context.Context) ([]model.Item, error) {
 	}
 	return is, nil
 }
+
+// ItemsTrackerCountSet sets the tracker count of every Item in counts.
+func (db Database) ItemsTrackerCountSet(ctx context.Context, counts map[primitive.ObjectID]int) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(counts))
+	for itemID, count := range counts {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": itemID}).
+			SetUpdate(bson.M{"$set": bson.M{"tracker_count": count}, "$inc": bson.M{"version": 1}}))
+	}
+	if _, err := db.Collection(CollectionItems).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return errors.Wrapf(err, "error bulk setting tracker count of %d Item(s)", len(counts))
+	}
+	return nil
+}
+
+// ItemsNextFetchAtSet sets when every Item in nextFetchAt is due in a full fetch cycle again.
+func (db Database) ItemsNextFetchAtSet(ctx context.Context, nextFetchAt map[primitive.ObjectID]time.Time) error {
+	if len(nextFetchAt) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(nextFetchAt))
+	for itemID, t := range nextFetchAt {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": itemID}).
+			SetUpdate(bson.M{"$set": bson.M{"next_fetch_at": primitive.NewDateTimeFromTime(t)}, "$inc": bson.M{"version": 1}}))
+	}
+	if _, err := db.Collection(CollectionItems).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return errors.Wrapf(err, "error bulk setting next fetch time of %d Item(s)", len(nextFetchAt))
+	}
+	return nil
+}
+
+// itemsOrphanedBatchSize is how many Items are marked or unmarked as orphaned per update.
+const itemsOrphanedBatchSize = 1000
+
+// itemIDsFind returns the IDs of the Items matching filter.
+func (db Database) itemIDsFind(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	var res []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	cur, err := db.Collection(CollectionItems).Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to find Item IDs")
+	}
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrap(err, "error getting all Item IDs from cursor")
+	}
+	itemIDs := make([]primitive.ObjectID, 0, len(res))
+	for _, r := range res {
+		itemIDs = append(itemIDs, r.ID)
+	}
+	return itemIDs, nil
+}
+
+// ItemsOrphanedFind returns the IDs of the Items that are not archived and no User tracks by their tracker count,
+// which must be up to date.
+func (db Database) ItemsOrphanedFind(ctx context.Context) ([]primitive.ObjectID, error) {
+	// The tracker count is missing on untracked Items stored before it.
+	itemIDs, err := db.itemIDsFind(ctx, bson.M{
+		"tracker_count": bson.M{"$not": bson.M{"$gt": 0}},
+		"archived":      bson.M{"$ne": true},
+	})
+	return itemIDs, errors.Wrap(err, "error finding orphaned Items")
+}
+
+// ItemsOrphanedSet marks the Items in itemIDs as orphaned since now unless they already are,
+// and clears the mark from every other Item that is not archived.
+func (db Database) ItemsOrphanedSet(ctx context.Context, itemIDs []primitive.ObjectID, now time.Time) error {
+	if err := db.itemsUpdateInBatches(ctx, itemIDs,
+		bson.M{"orphaned_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"orphaned_at": primitive.NewDateTimeFromTime(now)}, "$inc": bson.M{"version": 1}},
+	); err != nil {
+		return errors.Wrapf(err, "error marking %d Item(s) as orphaned", len(itemIDs))
+	}
+
+	marked, err := db.itemIDsFind(ctx, bson.M{"orphaned_at": bson.M{"$exists": true}, "archived": bson.M{"$ne": true}})
+	if err != nil {
+		return errors.Wrap(err, "error finding Items marked as orphaned")
+	}
+	orphaned := make(map[primitive.ObjectID]struct{}, len(itemIDs))
+	for _, itemID := range itemIDs {
+		orphaned[itemID] = struct{}{}
+	}
+	tracked := make([]primitive.ObjectID, 0)
+	for _, itemID := range marked {
+		if _, ok := orphaned[itemID]; !ok {
+			tracked = append(tracked, itemID)
+		}
+	}
+	err = db.itemsUpdateInBatches(ctx, tracked,
+		bson.M{"orphaned_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"orphaned_at": ""}, "$inc": bson.M{"version": 1}},
+	)
+	return errors.Wrapf(err, "error clearing orphaned mark of %d tracked Item(s)", len(tracked))
+}
+
+// itemsUpdateInBatches applies update to the Items in itemIDs matching filter, itemsOrphanedBatchSize Items at a time.
+func (db Database) itemsUpdateInBatches(ctx context.Context, itemIDs []primitive.ObjectID, filter bson.M, update bson.M) error {
+	for start := 0; start < len(itemIDs); start += itemsOrphanedBatchSize {
+		end := start + itemsOrphanedBatchSize
+		if end > len(itemIDs) {
+			end = len(itemIDs)
+		}
+		f := bson.M{"_id": bson.M{"$in": itemIDs[start:end]}}
+		for k, v := range filter {
+			f[k] = v
+		}
+		if _, err := db.Collection(CollectionItems).UpdateMany(ctx, f, update); err != nil {
+			return errors.Wrapf(err, "error updating Items %d to %d of %d", start, end, len(itemIDs))
+		}
+	}
+	return nil
+}
+
+// ItemArchive archives the Item with itemID, archived Items are not fetched and are left out of search results.
+func (db Database) ItemArchive(ctx context.Context, itemID primitive.ObjectID, reason string, now time.Time) error {
+	res, err := db.Collection(CollectionItems).UpdateOne(
+		ctx,
+		bson.M{"_id": itemID, "archived": bson.M{"$ne": true}},
+		bson.M{
+			"$set": bson.M{
+				"archived":       true,
+				"archived_at":    primitive.NewDateTimeFromTime(now),
+				"archive_reason": reason,
+			},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error archiving Item with ID: %s", itemID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "Item with ID: %s not found or already archived", itemID.Hex())
+	}
+	return nil
+}
+
+func (db Database) ItemUnarchive(ctx context.Context, itemID primitive.ObjectID) error {
+	res, err := db.Collection(CollectionItems).UpdateOne(
+		ctx,
+		bson.M{"_id": itemID, "archived": true},
+		bson.M{
+			"$set":   bson.M{"archived": false},
+			"$unset": bson.M{"archived_at": "", "archive_reason": "", "orphaned_at": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error unarchiving Item with ID: %s", itemID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "Item with ID: %s not found or not archived", itemID.Hex())
+	}
+	return nil
+}
+
+// ItemsArchiveOrphaned archives the Items orphaned before orphanedBefore, it returns the number of Items archived.
+func (db Database) ItemsArchiveOrphaned(ctx context.Context, orphanedBefore time.Time, now time.Time) (int, error) {
+	res, err := db.Collection(CollectionItems).UpdateMany(
+		ctx,
+		bson.M{
+			"orphaned_at": bson.M{"$lt": primitive.NewDateTimeFromTime(orphanedBefore)},
+			"archived":    bson.M{"$ne": true},
+		},
+		bson.M{
+			"$set": bson.M{
+				"archived":       true,
+				"archived_at":    primitive.NewDateTimeFromTime(now),
+				"archive_reason": model.ItemArchiveReasonOrphaned,
+			},
+			"$inc": bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error archiving Items orphaned before: %v", orphanedBefore)
+	}
+	return int(res.ModifiedCount), nil
+}
+
+// ItemsArchivedFind returns the archived Items among is, matched by site, merchant and product.
+func (db Database) ItemsArchivedFind(ctx context.Context, is []model.Item) ([]model.Item, error) {
+	if len(is) == 0 {
+		return nil, nil
+	}
+	keys := make(bson.A, 0, len(is))
+	for _, i := range is {
+		keys = append(keys, bson.M{"site": i.Site, "merchant_id": i.MerchantID, "product_id": i.ProductID})
+	}
+	var archived []model.Item
+	cur, err := db.Collection(CollectionItems).Find(ctx, bson.M{"archived": true, "$or": keys})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find archived Items among %d Item(s)", len(is))
+	}
+	if err = cur.All(ctx, &archived); err != nil {
+		return nil, errors.Wrapf(err, "error getting archived Items from cursor among %d Item(s)", len(is))
+	}
+	return archived, nil
+}
+
+// ItemNotFoundCountInc increments how many consecutive fetches of the Item with itemID found it missing,
+// it returns the incremented count.
+func (db Database) ItemNotFoundCountInc(ctx context.Context, itemID primitive.ObjectID) (int, error) {
+	var i model.Item
+	err := db.Collection(CollectionItems).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": itemID},
+		bson.M{"$inc": bson.M{"not_found_count": 1, "version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"not_found_count": 1}),
+	).Decode(&i)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error incrementing not found count of Item with ID: %s", itemID.Hex())
+	}
+	return i.NotFoundCount, nil
+}
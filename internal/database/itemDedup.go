@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// ItemDuplicates are the Items stored for the same listing, ItemIDs are sorted from the oldest Item.
+type ItemDuplicates struct {
+	Key struct {
+		Site      string `bson:"site"`
+		ProductID string `bson:"product_id"`
+	} `bson:"_id"`
+	ItemIDs []primitive.ObjectID `bson:"item_ids"`
+}
+
+// ItemDuplicatesFind returns up to limit listings with more than one Item, Items are the same listing when they are
+// on the same site and have the same canonical ProductID. Only the listings of Items created or updated since since
+// are looked at, every listing when since is zero.
+func (db Database) ItemDuplicatesFind(ctx context.Context, since time.Time, limit int64) ([]ItemDuplicates, error) {
+	var pipeline mongo.Pipeline
+	if !since.IsZero() {
+		keys, err := db.itemKeysUpdatedSince(ctx, since)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": keys}}})
+	}
+	cur, err := db.Collection(CollectionItems).Aggregate(ctx, append(pipeline, mongo.Pipeline{
+		{{Key: "$project", Value: bson.M{"site": 1, "product_id": 1, "created_at": 1}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"site":       "$site",
+				"product_id": bson.M{"$toUpper": bson.M{"$trim": bson.M{"input": "$product_id"}}},
+			},
+			"item_ids": bson.M{"$push": "$_id"},
+		}}},
+		{{Key: "$match", Value: bson.M{"item_ids.1": bson.M{"$exists": true}}}},
+		{{Key: "$limit", Value: limit}},
+	}...), options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "error aggregating duplicate Items")
+	}
+	var res []ItemDuplicates
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrap(err, "error getting duplicate Items from cursor")
+	}
+	return res, nil
+}
+
+// itemKeysUpdatedSince returns the site and ProductID of the listings of the Items created or updated since since.
+// Items are stored with their canonical ProductID, so they match every Item of their listing.
+func (db Database) itemKeysUpdatedSince(ctx context.Context, since time.Time) (bson.A, error) {
+	cur, err := db.Collection(CollectionItems).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"updated_at": bson.M{"$gte": primitive.NewDateTimeFromTime(since)}}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"site": "$site", "product_id": "$product_id"}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error aggregating Items updated since: %v", since)
+	}
+	var res []struct {
+		Key bson.M `bson:"_id"`
+	}
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrap(err, "error getting updated Items from cursor")
+	}
+	keys := make(bson.A, 0, len(res))
+	for _, r := range res {
+		keys = append(keys, r.Key)
+	}
+	return keys, nil
+}
+
+// ItemMerge merges the duplicate Item with dupID into the Item with itemID and deletes it. The ItemHistory of the
+// duplicate is moved to the Item, except the ones of fetch cycles the Item already has ItemHistory of, Users tracking
+// the duplicate track the Item instead unless they already track it, and the ItemMatches of the duplicate are deleted
+// to be matched again with the Item. The price history bounds of the Item are widened to the ones of the duplicate.
+// It must be called in a transaction of WithTransaction, otherwise ErrNoTransaction is returned.
+func (db Database) ItemMerge(ctx context.Context, itemID primitive.ObjectID, dupID primitive.ObjectID) error {
+	if !inTransaction(ctx) {
+		return errors.Wrapf(ErrNoTransaction, "error merging Item with ID: %s into Item with ID: %s",
+			dupID.Hex(), itemID.Hex())
+	}
+	if itemID == dupID {
+		return errors.Errorf("error merging Item with ID: %s into itself", itemID.Hex())
+	}
+	var dup model.Item
+	if err := db.Collection(CollectionItems).FindOne(ctx, bson.M{"_id": dupID}).Decode(&dup); err != nil {
+		return errors.Wrapf(err, "error finding duplicate Item with ID: %s", dupID.Hex())
+	}
+	update := bson.M{"$max": bson.M{"price_history_highest": dup.PriceHistoryHighest}, "$inc": bson.M{"version": 1}}
+	if dup.PriceHistoryLowest > 0 {
+		update["$min"] = bson.M{"price_history_lowest": dup.PriceHistoryLowest}
+	}
+	res, err := db.Collection(CollectionItems).UpdateOne(ctx, bson.M{"_id": itemID}, update)
+	if err != nil {
+		return errors.Wrapf(err, "error merging price history bounds of Item with ID: %s into Item with ID: %s",
+			dupID.Hex(), itemID.Hex())
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "Item with ID: %s not found", itemID.Hex())
+	}
+	if dup.Barcode != "" {
+		if _, err = db.Collection(CollectionItems).UpdateOne(ctx,
+			bson.M{"_id": itemID, "barcode": bson.M{"$in": bson.A{nil, ""}}},
+			bson.M{"$set": bson.M{"barcode": dup.Barcode}, "$inc": bson.M{"version": 1}},
+		); err != nil {
+			return errors.Wrapf(err, "error setting barcode of Item with ID: %s", itemID.Hex())
+		}
+	}
+
+	if err = db.itemHistoryMerge(ctx, itemID, dupID); err != nil {
+		return err
+	}
+
+	users := db.Collection(CollectionUsers)
+	if _, err = users.UpdateMany(ctx,
+		bson.M{"$and": bson.A{bson.M{"tracked_items.item_id": dupID}, bson.M{"tracked_items.item_id": itemID}}},
+		bson.M{"$pull": bson.M{"tracked_items": bson.M{"item_id": dupID}}},
+	); err != nil {
+		return errors.Wrapf(err, "error removing duplicate Item with ID: %s from Users also tracking Item with ID: %s",
+			dupID.Hex(), itemID.Hex())
+	}
+	if _, err = users.UpdateMany(ctx,
+		bson.M{"tracked_items.item_id": dupID},
+		bson.M{"$set": bson.M{"tracked_items.$[ti].item_id": itemID}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []any{bson.M{"ti.item_id": dupID}}}),
+	); err != nil {
+		return errors.Wrapf(err, "error reassigning tracked duplicate Item with ID: %s to Item with ID: %s",
+			dupID.Hex(), itemID.Hex())
+	}
+
+	if _, err = db.Collection(CollectionQueuedNotifications).UpdateMany(ctx,
+		bson.M{"item_id": dupID}, bson.M{"$set": bson.M{"item_id": itemID}}); err != nil {
+		return errors.Wrapf(err, "error reassigning QueuedNotifications of duplicate Item with ID: %s", dupID.Hex())
+	}
+	if _, err = db.Collection(CollectionItemMatches).DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"item_id": dupID},
+		bson.M{"matched_item_id": dupID},
+	}}); err != nil {
+		return errors.Wrapf(err, "error deleting ItemMatches of duplicate Item with ID: %s", dupID.Hex())
+	}
+
+	if _, err = db.Collection(CollectionItems).DeleteOne(ctx, bson.M{"_id": dupID}); err != nil {
+		return errors.Wrapf(err, "error deleting duplicate Item with ID: %s", dupID.Hex())
+	}
+	return nil
+}
+
+// itemHistoryMerge moves the ItemHistory of the Item with dupID to the Item with itemID, ItemHistory of fetch cycles
+// the Item already has ItemHistory of is deleted instead. Time-series collections only support deletes by their meta
+// field, ItemHistory of the same fetch cycle is kept there for both.
+func (db Database) itemHistoryMerge(ctx context.Context, itemID primitive.ObjectID, dupID primitive.ObjectID) error {
+	ihs := db.itemHistories()
+	// item_id is the meta field of the time-series collection, which can be updated there as well.
+	if db.ItemHistoryTimeSeries {
+		_, err := ihs.UpdateMany(ctx, bson.M{"item_id": dupID}, bson.M{"$set": bson.M{"item_id": itemID}})
+		return errors.Wrapf(err, "error moving ItemHistory for ItemID: %s to ItemID: %s", dupID.Hex(), itemID.Hex())
+	}
+	cycleIDs, err := ihs.Distinct(ctx, "fetch_cycle_id", bson.M{"item_id": dupID, "fetch_cycle_id": bson.M{"$exists": true}})
+	if err != nil {
+		return errors.Wrapf(err, "error finding fetch cycles of ItemHistory for ItemID: %s", dupID.Hex())
+	}
+	if len(cycleIDs) > 0 {
+		both, err := ihs.Distinct(ctx, "fetch_cycle_id", bson.M{"item_id": itemID, "fetch_cycle_id": bson.M{"$in": cycleIDs}})
+		if err != nil {
+			return errors.Wrapf(err, "error finding fetch cycles of ItemHistory for ItemID: %s", itemID.Hex())
+		}
+		if len(both) > 0 {
+			if _, err = ihs.DeleteMany(ctx, bson.M{"item_id": dupID, "fetch_cycle_id": bson.M{"$in": both}}); err != nil {
+				return errors.Wrapf(err, "error deleting overlapping ItemHistory for ItemID: %s", dupID.Hex())
+			}
+		}
+	}
+	if _, err = ihs.UpdateMany(ctx, bson.M{"item_id": dupID}, bson.M{"$set": bson.M{"item_id": itemID}}); err != nil {
+		return errors.Wrapf(err, "error moving ItemHistory for ItemID: %s to ItemID: %s", dupID.Hex(), itemID.Hex())
+	}
+	return nil
+}
@@ -5,30 +5,104 @@ import (
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"pricetracker/internal/model"
 	"time"
 )
 
-func (db Database) ItemHistoryInsert(ctx context.Context, ih model.ItemHistory) (err error) {
-	_, err = db.Collection(CollectionItemHistories).InsertOne(ctx, ih)
-	return errors.Wrapf(err, "error inserting ItemHistory: %+v", ih)
+// itemHistories returns the collection ItemHistory is stored in.
+func (db Database) itemHistories() *mongo.Collection {
+	if db.ItemHistoryTimeSeries {
+		return db.Collection(CollectionItemHistoriesTimeSeries)
+	}
+	return db.Collection(CollectionItemHistories)
 }
 
+// ItemHistoryUpsert writes ih once per (ItemID, FetchCycleID), a retried write within the same fetch cycle
+// updates the values of the existing ItemHistory and keeps its original Timestamp.
+// Time-series collections can not be upserted into, a retried write there keeps the existing ItemHistory as is.
+func (db Database) ItemHistoryUpsert(ctx context.Context, ih model.ItemHistory) error {
+	if ih.FetchCycleID.IsZero() {
+		return errors.Errorf("error upserting ItemHistory with no FetchCycleID: %+v", ih)
+	}
+	if db.ItemHistoryTimeSeries {
+		if err := db.itemHistoryTimeSeriesInsert(ctx, ih); err != nil {
+			return err
+		}
+		return db.itemHistoryTrim(ctx, ih.ItemID)
+	}
+	set := bson.M{
+		"pr": ih.Price,
+		"st": ih.Stock,
+		"rt": ih.Rating,
+		"sl": ih.Sold,
+	}
+	if ih.Currency != "" {
+		set["cur"] = ih.Currency
+	}
+	if ih.Anomaly != "" {
+		set["an"] = ih.Anomaly
+	}
+	if ih.FlashSale {
+		set["fs"] = true
+	}
+	_, err := db.itemHistories().UpdateOne(ctx,
+		bson.M{"item_id": ih.ItemID, "fetch_cycle_id": ih.FetchCycleID},
+		bson.M{
+			"$set":         set,
+			"$setOnInsert": bson.M{"ts": ih.Timestamp},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent writer upserted the same (ItemID, FetchCycleID) first.
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error upserting ItemHistory: %+v", ih)
+	}
+	return db.itemHistoryTrim(ctx, ih.ItemID)
+}
+
+// itemHistoryTrim deletes the ItemHistory of an Item older than its newest ItemHistoryMaxPoints documents.
+func (db Database) itemHistoryTrim(ctx context.Context, itemID primitive.ObjectID) error {
+	if db.ItemHistoryMaxPoints <= 0 {
+		return nil
+	}
+	var oldestKept struct {
+		Timestamp primitive.DateTime `bson:"ts"`
+	}
+	err := db.itemHistories().FindOne(ctx, bson.M{"item_id": itemID}, options.FindOne().
+		SetSort(bson.M{"ts": -1}).SetSkip(int64(db.ItemHistoryMaxPoints-1)).SetProjection(bson.M{"ts": 1}),
+	).Decode(&oldestKept)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error finding oldest kept ItemHistory for ItemID: %s", itemID.Hex())
+	}
+	_, err = db.itemHistories().DeleteMany(ctx, bson.M{"item_id": itemID, "ts": bson.M{"$lt": oldestKept.Timestamp}})
+	return errors.Wrapf(err, "error deleting ItemHistory older than the newest %d for ItemID: %s",
+		db.ItemHistoryMaxPoints, itemID.Hex())
+}
+
+// ItemHistoryFindRange finds the ItemHistory of an Item between start and end, newest first,
+// skipping the first offset entries and returning at most limit entries when limit is not zero.
 func (db Database) ItemHistoryFindRange(
-	ctx context.Context, itemID string, start time.Time, end time.Time) ([]model.ItemHistory, error) {
+	ctx context.Context, itemID string, start time.Time, end time.Time, offset int64, limit int64) ([]model.ItemHistory, error) {
 	itemOID, err := primitive.ObjectIDFromHex(itemID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error generating ObjectID from hex: %s", itemID)
 	}
 	var ihs []model.ItemHistory
-	cur, err := db.Collection(CollectionItemHistories).Find(ctx, bson.M{
+	cur, err := db.itemHistories().Find(ctx, bson.M{
 		"item_id": itemOID,
 		"ts": bson.M{
 			"$gte": primitive.NewDateTimeFromTime(start),
 			"$lte": primitive.NewDateTimeFromTime(end),
 		},
-	}, options.Find().SetSort(bson.M{"ts": -1}))
+	}, options.Find().SetSort(bson.M{"ts": -1}).SetSkip(offset).SetLimit(limit))
 	if err != nil {
 		return nil, errors.Wrapf(err,
 			"error getting cursor to find ItemHistory for ItemID: %s, start: %s, end: %s",
@@ -41,3 +115,335 @@ func (db Database) ItemHistoryFindRange(
 	}
 	return ihs, nil
 }
+
+// ItemHistoryForEach calls fn with every ItemHistory of an Item between start and end, oldest first,
+// without loading them all at once. Iteration stops at the first error returned by fn.
+func (db Database) ItemHistoryForEach(
+	ctx context.Context, itemID string, start time.Time, end time.Time, fn func(ih model.ItemHistory) error) error {
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return errors.Wrapf(err, "error generating ObjectID from hex: %s", itemID)
+	}
+	cur, err := db.itemHistories().Find(ctx, bson.M{
+		"item_id": itemOID,
+		"ts": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(start),
+			"$lte": primitive.NewDateTimeFromTime(end),
+		},
+	}, options.Find().SetSort(bson.M{"ts": 1}))
+	if err != nil {
+		return errors.Wrapf(err, "error getting cursor to find ItemHistory for ItemID: %s", itemID)
+	}
+	defer func() {
+		_ = cur.Close(ctx)
+	}()
+	for cur.Next(ctx) {
+		var ih model.ItemHistory
+		if err = cur.Decode(&ih); err != nil {
+			return errors.Wrapf(err, "error decoding ItemHistory for ItemID: %s", itemID)
+		}
+		if err = fn(ih); err != nil {
+			return err
+		}
+	}
+	return errors.Wrapf(cur.Err(), "error iterating ItemHistory cursor for ItemID: %s", itemID)
+}
+
+// ItemHistoryAggregate groups the ItemHistory of an Item between start and end into day or week buckets,
+// weeks are ISO weeks starting on Monday. Bucket boundaries are calculated in the timezone loc.
+func (db Database) ItemHistoryAggregate(
+	ctx context.Context, itemID string, start time.Time, end time.Time, interval string, loc *time.Location,
+) ([]model.ItemHistoryBucket, error) {
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating ObjectID from hex: %s", itemID)
+	}
+
+	tz := loc.String()
+	dateParts := func(op string) bson.M {
+		return bson.M{op: bson.M{"date": "$ts", "timezone": tz}}
+	}
+	var bucketStart bson.M
+	switch interval {
+	case model.ItemHistoryIntervalDay:
+		bucketStart = bson.M{"$dateFromParts": bson.M{
+			"year":     dateParts("$year"),
+			"month":    dateParts("$month"),
+			"day":      dateParts("$dayOfMonth"),
+			"timezone": tz,
+		}}
+	case model.ItemHistoryIntervalWeek:
+		bucketStart = bson.M{"$dateFromParts": bson.M{
+			"isoWeekYear":  dateParts("$isoWeekYear"),
+			"isoWeek":      dateParts("$isoWeek"),
+			"isoDayOfWeek": 1,
+			"timezone":     tz,
+		}}
+	default:
+		return nil, errors.Errorf("invalid ItemHistory aggregate interval: %s", interval)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"item_id": itemOID,
+			"ts": bson.M{
+				"$gte": primitive.NewDateTimeFromTime(start),
+				"$lte": primitive.NewDateTimeFromTime(end),
+			},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bucketStart,
+			"pr_min": bson.M{"$min": "$pr"},
+			"pr_max": bson.M{"$max": "$pr"},
+			"pr_avg": bson.M{"$avg": "$pr"},
+			"count":  bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	var ihbs []model.ItemHistoryBucket
+	cur, err := db.itemHistories().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting cursor to aggregate ItemHistory for ItemID: %s, start: %s, end: %s, interval: %s",
+			itemID, start.Format(time.RFC3339), end.Format(time.RFC3339), interval)
+	}
+	if err = cur.All(ctx, &ihbs); err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting all ItemHistoryBuckets from cursor for ItemID: %s, start: %s, end: %s, interval: %s",
+			itemID, start.Format(time.RFC3339), end.Format(time.RFC3339), interval)
+	}
+	return ihbs, nil
+}
+
+// ItemHistoryPricesSince returns the prices of an Item since the time since, leaving out prices flagged
+// as spikes so they do not shift the baseline they are compared with.
+func (db Database) ItemHistoryPricesSince(ctx context.Context, itemID primitive.ObjectID, since time.Time) ([]int, error) {
+	var ihs []model.ItemHistory
+	cur, err := db.itemHistories().Find(ctx, bson.M{
+		"item_id": itemID,
+		"ts":      bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+		"an":      bson.M{"$ne": model.ItemHistoryAnomalySpike},
+	}, options.Find().SetProjection(bson.M{"pr": 1}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find ItemHistory prices for ItemID: %s, since: %s",
+			itemID.Hex(), since.Format(time.RFC3339))
+	}
+	if err = cur.All(ctx, &ihs); err != nil {
+		return nil, errors.Wrapf(err, "error getting ItemHistory prices from cursor for ItemID: %s, since: %s",
+			itemID.Hex(), since.Format(time.RFC3339))
+	}
+	prices := make([]int, 0, len(ihs))
+	for _, ih := range ihs {
+		prices = append(prices, ih.Price)
+	}
+	return prices, nil
+}
+
+// ItemHistoryFirstPricesSince returns the price of the first ItemHistory since since of each of the Items,
+// Items with no ItemHistory since then are left out.
+func (db Database) ItemHistoryFirstPricesSince(
+	ctx context.Context, itemIDs []primitive.ObjectID, since time.Time) (map[primitive.ObjectID]int, error) {
+	cur, err := db.itemHistories().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"item_id": bson.M{"$in": itemIDs},
+			"ts":      bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+			"an":      bson.M{"$ne": model.ItemHistoryAnomalySpike},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "item_id", Value: 1}, {Key: "ts", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$item_id", "pr": bson.M{"$first": "$pr"}}}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error aggregating first ItemHistory prices of %d Item(s) since: %s",
+			len(itemIDs), since.Format(time.RFC3339))
+	}
+	var res []struct {
+		ItemID primitive.ObjectID `bson:"_id"`
+		Price  int                `bson:"pr"`
+	}
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrapf(err, "error getting first ItemHistory prices from cursor since: %s", since.Format(time.RFC3339))
+	}
+	prices := make(map[primitive.ObjectID]int, len(res))
+	for _, r := range res {
+		prices[r.ItemID] = r.Price
+	}
+	return prices, nil
+}
+
+type ItemPriceDrop struct {
+	ItemID    primitive.ObjectID `bson:"_id"`
+	PriceFrom int                `bson:"from"`
+	PriceTo   int                `bson:"to"`
+	// Drop is the share of PriceFrom the price dropped by, between 0 and 1.
+	Drop float64 `bson:"drop"`
+}
+
+// ItemHistoryPriceDropsSince returns the limit Items whose price dropped the most since since, relative to their
+// first price since then, biggest drop first. Only Items in category are returned when category is set.
+func (db Database) ItemHistoryPriceDropsSince(
+	ctx context.Context, since time.Time, category string, limit int64) ([]ItemPriceDrop, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"ts": bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+			"an": bson.M{"$ne": model.ItemHistoryAnomalySpike},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "item_id", Value: 1}, {Key: "ts", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":  "$item_id",
+			"from": bson.M{"$first": "$pr"},
+			"to":   bson.M{"$last": "$pr"},
+		}}},
+		{{Key: "$match", Value: bson.M{"from": bson.M{"$gt": 0}, "$expr": bson.M{"$lt": bson.A{"$to", "$from"}}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"drop": bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$from", "$to"}}, "$from"}},
+		}}},
+	}
+	opts := options.Aggregate().SetAllowDiskUse(true)
+	if category != "" {
+		pipeline = append(pipeline, itemCategoryStages(category)...)
+		opts.SetCollation(categoryCollation)
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "drop", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+	cur, err := db.itemHistories().Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error aggregating ItemHistory price drops since: %s", since.Format(time.RFC3339))
+	}
+	var res []ItemPriceDrop
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrapf(err, "error getting ItemHistory price drops since: %s from cursor", since.Format(time.RFC3339))
+	}
+	return res, nil
+}
+
+// ItemHistoryPriceStats calculates the all-time, 30 and 90 day price statistics of an Item up to now
+// in a single aggregation, along with the percentile of price among the prices of the last 90 days.
+func (db Database) ItemHistoryPriceStats(
+	ctx context.Context, itemID string, price int, now time.Time) (model.ItemPriceStats, error) {
+	stats := model.ItemPriceStats{Current: price}
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return stats, errors.Wrapf(err, "error generating ObjectID from hex: %s", itemID)
+	}
+	since := func(days int) bson.D {
+		return bson.D{{Key: "$match", Value: bson.M{
+			"ts": bson.M{"$gte": primitive.NewDateTimeFromTime(now.AddDate(0, 0, -days))},
+		}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"item_id": itemOID,
+			"ts":      bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"all_time": bson.A{
+				bson.D{{Key: "$group", Value: bson.M{
+					"_id":    nil,
+					"pr_min": bson.M{"$min": "$pr"},
+					"pr_max": bson.M{"$max": "$pr"},
+				}}},
+			},
+			"days_30": bson.A{
+				since(30),
+				bson.D{{Key: "$group", Value: bson.M{"_id": nil, "pr_avg": bson.M{"$avg": "$pr"}}}},
+			},
+			"days_90": bson.A{
+				since(90),
+				bson.D{{Key: "$group", Value: bson.M{
+					"_id":    nil,
+					"pr_min": bson.M{"$min": "$pr"},
+					"pr_max": bson.M{"$max": "$pr"},
+					"pr_avg": bson.M{"$avg": "$pr"},
+					"count":  bson.M{"$sum": 1},
+					"below":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$pr", price}}, 1, 0}}},
+				}}},
+			},
+		}}},
+	}
+	type group struct {
+		PriceMin int     `bson:"pr_min"`
+		PriceMax int     `bson:"pr_max"`
+		PriceAvg float64 `bson:"pr_avg"`
+		Count    int     `bson:"count"`
+		Below    int     `bson:"below"`
+	}
+	var res []struct {
+		AllTime []group `bson:"all_time"`
+		Days30  []group `bson:"days_30"`
+		Days90  []group `bson:"days_90"`
+	}
+	cur, err := db.itemHistories().Aggregate(ctx, pipeline)
+	if err != nil {
+		return stats, errors.Wrapf(err, "error getting cursor to aggregate ItemHistory price stats for ItemID: %s", itemID)
+	}
+	if err = cur.All(ctx, &res); err != nil {
+		return stats, errors.Wrapf(err, "error getting ItemHistory price stats from cursor for ItemID: %s", itemID)
+	}
+	if len(res) == 0 {
+		return stats, nil
+	}
+	if len(res[0].AllTime) > 0 {
+		stats.AllTimeLow = res[0].AllTime[0].PriceMin
+		stats.AllTimeHigh = res[0].AllTime[0].PriceMax
+	}
+	if len(res[0].Days30) > 0 {
+		stats.Average30Days = res[0].Days30[0].PriceAvg
+	}
+	if len(res[0].Days90) > 0 {
+		d90 := res[0].Days90[0]
+		stats.Average90Days = d90.PriceAvg
+		stats.Low90Days = d90.PriceMin
+		stats.High90Days = d90.PriceMax
+		if d90.Count > 0 {
+			stats.Percentile90Days = float64(d90.Below) / float64(d90.Count) * 100
+		}
+	}
+	return stats, nil
+}
+
+// ItemHistoryStockFindRange returns the stock of an Item between start and end sorted by ascending timestamp,
+// preceded by the last ItemHistory before start so the stock at the start of the range is known.
+func (db Database) ItemHistoryStockFindRange(
+	ctx context.Context, itemID string, start time.Time, end time.Time) ([]model.ItemHistory, error) {
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating ObjectID from hex: %s", itemID)
+	}
+	projection := bson.M{"st": 1, "ts": 1}
+
+	var ihs []model.ItemHistory
+	var previous model.ItemHistory
+	err = db.itemHistories().FindOne(ctx, bson.M{
+		"item_id": itemOID,
+		"ts":      bson.M{"$lt": primitive.NewDateTimeFromTime(start)},
+	}, options.FindOne().SetSort(bson.M{"ts": -1}).SetProjection(projection)).Decode(&previous)
+	if err == nil {
+		ihs = append(ihs, previous)
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.Wrapf(err, "error finding ItemHistory before start for ItemID: %s, start: %s",
+			itemID, start.Format(time.RFC3339))
+	}
+
+	cur, err := db.itemHistories().Find(ctx, bson.M{
+		"item_id": itemOID,
+		"ts": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(start),
+			"$lte": primitive.NewDateTimeFromTime(end),
+		},
+	}, options.Find().SetSort(bson.M{"ts": 1}).SetProjection(projection))
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting cursor to find ItemHistory stock for ItemID: %s, start: %s, end: %s",
+			itemID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	var inRange []model.ItemHistory
+	if err = cur.All(ctx, &inRange); err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting all ItemHistory stock from cursor for ItemID: %s, start: %s, end: %s",
+			itemID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return append(ihs, inRange...), nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+)
+
+// ItemHistoryTimeSeriesEnsure creates the ItemHistory time-series collection when it does not exist,
+// and otherwise updates its expiry to ItemHistoryRetention, then ensures its indexes.
+// Unlike EnsureIndexes it leaves item_histories and its TTL index as they are.
+func (db Database) ItemHistoryTimeSeriesEnsure(ctx context.Context) error {
+	if err := db.itemHistoryTimeSeriesCollectionEnsure(ctx); err != nil {
+		return err
+	}
+	return db.collectionIndexesEnsure(ctx, itemHistoryTimeSeriesIndexes)
+}
+
+func (db Database) itemHistoryTimeSeriesCollectionEnsure(ctx context.Context) error {
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": CollectionItemHistoriesTimeSeries})
+	if err != nil {
+		return errors.Wrapf(err, "error listing collection %s", CollectionItemHistoriesTimeSeries)
+	}
+
+	if len(names) == 0 {
+		opts := options.CreateCollection().SetTimeSeriesOptions(
+			options.TimeSeries().SetTimeField("ts").SetMetaField("item_id").SetGranularity("hours"))
+		if db.ItemHistoryRetention > 0 {
+			opts.SetExpireAfterSeconds(int64(db.ItemHistoryRetention.Seconds()))
+		}
+		return errors.Wrapf(db.CreateCollection(ctx, CollectionItemHistoriesTimeSeries, opts),
+			"error creating time-series collection %s", CollectionItemHistoriesTimeSeries)
+	}
+
+	var expireAfterSeconds any = "off"
+	if db.ItemHistoryRetention > 0 {
+		expireAfterSeconds = int64(db.ItemHistoryRetention.Seconds())
+	}
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: CollectionItemHistoriesTimeSeries},
+		{Key: "expireAfterSeconds", Value: expireAfterSeconds},
+	}).Err()
+	return errors.Wrapf(err, "error setting expiry of time-series collection %s", CollectionItemHistoriesTimeSeries)
+}
+
+func (db Database) itemHistoryTimeSeriesInsert(ctx context.Context, ih model.ItemHistory) error {
+	coll := db.Collection(CollectionItemHistoriesTimeSeries)
+	n, err := coll.CountDocuments(ctx, bson.M{"item_id": ih.ItemID, "fetch_cycle_id": ih.FetchCycleID},
+		options.Count().SetLimit(1))
+	if err != nil {
+		return errors.Wrapf(err, "error checking for existing ItemHistory: %+v", ih)
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = coll.InsertOne(ctx, ih)
+	return errors.Wrapf(err, "error inserting ItemHistory: %+v", ih)
+}
+
+// ItemHistoryMigrateTimeSeries copies ItemHistory from item_histories into the time-series collection in batches
+// of batchSize, oldest first, calling progress with the number copied so far after every batch. The migration can be
+// resumed after an interruption, it continues after the newest ItemHistory already in the time-series collection.
+// item_histories is left as is.
+func (db Database) ItemHistoryMigrateTimeSeries(ctx context.Context, batchSize int, progress func(copied int64)) (int64, error) {
+	dst := db.Collection(CollectionItemHistoriesTimeSeries)
+
+	filter := bson.M{}
+	var last model.ItemHistory
+	err := dst.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"ts": -1})).Decode(&last)
+	if err == nil {
+		// The batch interrupted last may have copied only some of the ItemHistory with the newest timestamp.
+		var copied []primitive.ObjectID
+		cur, err := dst.Find(ctx, bson.M{"ts": last.Timestamp}, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return 0, errors.Wrap(err, "error getting cursor to find ItemHistory with the newest timestamp")
+		}
+		for cur.Next(ctx) {
+			copied = append(copied, cur.Current.Lookup("_id").ObjectID())
+		}
+		if err = cur.Err(); err != nil {
+			return 0, errors.Wrap(err, "error iterating ItemHistory with the newest timestamp")
+		}
+		filter = bson.M{"$or": bson.A{
+			bson.M{"ts": bson.M{"$gt": last.Timestamp}},
+			bson.M{"ts": last.Timestamp, "_id": bson.M{"$nin": copied}},
+		}}
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, errors.Wrap(err, "error finding newest ItemHistory in time-series collection")
+	}
+
+	cur, err := db.Collection(CollectionItemHistories).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "ts", Value: 1}, {Key: "_id", Value: 1}}).SetBatchSize(int32(batchSize)))
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting cursor to find ItemHistory to migrate")
+	}
+	defer func() {
+		_ = cur.Close(ctx)
+	}()
+
+	var copied int64
+	batch := make([]any, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := dst.InsertMany(ctx, batch); err != nil {
+			return errors.Wrapf(err, "error inserting batch of %d ItemHistory after %d copied", len(batch), copied)
+		}
+		copied += int64(len(batch))
+		batch = batch[:0]
+		progress(copied)
+		return nil
+	}
+	for cur.Next(ctx) {
+		var ih model.ItemHistory
+		if err = cur.Decode(&ih); err != nil {
+			return copied, errors.Wrap(err, "error decoding ItemHistory to migrate")
+		}
+		if batch = append(batch, ih); len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err = cur.Err(); err != nil {
+		return copied, errors.Wrap(err, "error iterating ItemHistory to migrate")
+	}
+	return copied, flush()
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// ItemsFindMatchCandidates returns up to limit Items on other sites than i that share its barcode or any of its
+// name tokens, Items sharing the barcode come first followed by the ones sharing the most name tokens.
+func (db Database) ItemsFindMatchCandidates(ctx context.Context, i model.Item, limit int) ([]model.Item, error) {
+	var is []model.Item
+	tokens := i.NameTokens
+	if tokens == nil {
+		tokens = []string{}
+	}
+	or := bson.A{bson.M{"name_tokens": bson.M{"$in": tokens}}}
+	barcode := "-"
+	if i.Barcode != "" {
+		or = append(or, bson.M{"barcode": i.Barcode})
+		barcode = i.Barcode
+	}
+	cur, err := db.Collection(CollectionItems).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"site":     bson.M{"$ne": i.Site},
+			"archived": bson.M{"$ne": true},
+			"$or":      or,
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"barcode_match": bson.M{"$eq": bson.A{"$barcode", barcode}},
+			"common_tokens": bson.M{"$size": bson.M{
+				"$setIntersection": bson.A{bson.M{"$ifNull": bson.A{"$name_tokens", bson.A{}}}, tokens},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "barcode_match", Value: -1}, {Key: "common_tokens", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error aggregating match candidates for ItemID: %s", i.ID.Hex())
+	}
+	if err = cur.All(ctx, &is); err != nil {
+		return nil, errors.Wrapf(err, "error getting match candidates from cursor for ItemID: %s", i.ID.Hex())
+	}
+	return is, nil
+}
+
+// ItemMatchesUpsert stores ims, and the reverse of every ItemMatch, updating the reason and similarity
+// of already stored ones.
+func (db Database) ItemMatchesUpsert(ctx context.Context, ims []model.ItemMatch) error {
+	if len(ims) == 0 {
+		return nil
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	wms := make([]mongo.WriteModel, 0, 2*len(ims))
+	for _, im := range ims {
+		for _, ids := range [][2]primitive.ObjectID{{im.ItemID, im.MatchedItemID}, {im.MatchedItemID, im.ItemID}} {
+			wms = append(wms, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"item_id": ids[0], "matched_item_id": ids[1]}).
+				SetUpdate(bson.M{
+					"$set":         bson.M{"reason": im.Reason, "similarity": im.Similarity},
+					"$setOnInsert": bson.M{"created_at": now},
+				}).
+				SetUpsert(true))
+		}
+	}
+	_, err := db.Collection(CollectionItemMatches).BulkWrite(ctx, wms, options.BulkWrite().SetOrdered(false))
+	return errors.Wrapf(err, "error upserting %d ItemMatch(es)", len(ims))
+}
+
+// ItemMatchesFind returns the ItemMatches of the Item with itemID.
+func (db Database) ItemMatchesFind(ctx context.Context, itemID primitive.ObjectID) ([]model.ItemMatch, error) {
+	var ims []model.ItemMatch
+	cur, err := db.Collection(CollectionItemMatches).Find(ctx, bson.M{"item_id": itemID})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find ItemMatches for ItemID: %s", itemID.Hex())
+	}
+	if err = cur.All(ctx, &ims); err != nil {
+		return nil, errors.Wrapf(err, "error getting ItemMatches from cursor for ItemID: %s", itemID.Hex())
+	}
+	return ims, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) LoginEventInsert(ctx context.Context, le model.LoginEvent) error {
+	le.Timestamp = primitive.NewDateTimeFromTime(time.Now())
+	_, err := db.Collection(CollectionLoginEvents).InsertOne(ctx, le)
+	return errors.Wrapf(err, "error inserting LoginEvent: %+v", le)
+}
+
+func (db Database) LoginEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.LoginEvent, error) {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating userOID from hex: %s", userID)
+	}
+	var les []model.LoginEvent
+	cur, err := db.Collection(CollectionLoginEvents).Find(ctx,
+		bson.M{"user_id": userOID},
+		options.Find().SetSort(bson.M{"ts": -1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find LoginEvents for UserID: %s", userID)
+	}
+	if err = cur.All(ctx, &les); err != nil {
+		return nil, errors.Wrapf(err, "error getting LoginEvents for UserID: %s from cursor", userID)
+	}
+	return les, nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) MerchantHistoryInsert(ctx context.Context, mh model.MerchantHistory) error {
+	mh.Timestamp = primitive.NewDateTimeFromTime(time.Now())
+	_, err := db.Collection(CollectionMerchantHistories).InsertOne(ctx, mh)
+	return errors.Wrapf(err, "error inserting MerchantHistory: %+v", mh)
+}
+
+func (db Database) MerchantHistoryFindRange(
+	ctx context.Context, site string, merchantID string, start time.Time, end time.Time) ([]model.MerchantHistory, error) {
+	var mhs []model.MerchantHistory
+	cur, err := db.Collection(CollectionMerchantHistories).Find(ctx, bson.M{
+		"site":        site,
+		"merchant_id": merchantID,
+		"ts": bson.M{
+			"$gte": primitive.NewDateTimeFromTime(start),
+			"$lte": primitive.NewDateTimeFromTime(end),
+		},
+	}, options.Find().SetSort(bson.M{"ts": 1}))
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting cursor to find MerchantHistory for Site: %s, MerchantID: %s, start: %s, end: %s",
+			site, merchantID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	if err = cur.All(ctx, &mhs); err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting all MerchantHistory from cursor for Site: %s, MerchantID: %s, start: %s, end: %s",
+			site, merchantID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return mhs, nil
+}
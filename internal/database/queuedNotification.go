@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) QueuedNotificationsInsert(ctx context.Context, qns []model.QueuedNotification) error {
+	docs := make([]any, 0, len(qns))
+	for _, qn := range qns {
+		qn.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+		docs = append(docs, qn)
+	}
+	_, err := db.Collection(CollectionQueuedNotifications).InsertMany(ctx, docs)
+	return errors.Wrapf(err, "error inserting %d QueuedNotification(s)", len(qns))
+}
+
+// QueuedNotificationsFindDue finds the QueuedNotifications to be delivered at or before now, oldest first.
+func (db Database) QueuedNotificationsFindDue(ctx context.Context, now time.Time, limit int64) ([]model.QueuedNotification, error) {
+	var qns []model.QueuedNotification
+	cur, err := db.Collection(CollectionQueuedNotifications).Find(ctx,
+		bson.M{"deliver_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+		options.Find().SetSort(bson.M{"deliver_at": 1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to find due QueuedNotifications")
+	}
+	if err = cur.All(ctx, &qns); err != nil {
+		return nil, errors.Wrap(err, "error getting due QueuedNotifications from cursor")
+	}
+	return qns, nil
+}
+
+func (db Database) QueuedNotificationsDelete(ctx context.Context, ids []primitive.ObjectID) (int, error) {
+	res, err := db.Collection(CollectionQueuedNotifications).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, errors.Wrapf(err, "error deleting QueuedNotifications with IDs: %v", ids)
+	}
+	return int(res.DeletedCount), nil
+}
+
+// QueuedNotificationsRetry counts a failed delivery attempt of the QueuedNotifications with ids and moves their
+// DeliverAt to deliverAt.
+func (db Database) QueuedNotificationsRetry(ctx context.Context, ids []primitive.ObjectID, deliverAt time.Time) (int, error) {
+	res, err := db.Collection(CollectionQueuedNotifications).UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{
+			"$inc": bson.M{"attempts": 1},
+			"$set": bson.M{"deliver_at": primitive.NewDateTimeFromTime(deliverAt)},
+		},
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error retrying QueuedNotifications with IDs: %v", ids)
+	}
+	return int(res.ModifiedCount), nil
+}
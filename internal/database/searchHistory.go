@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// searchHistoriesPerUser is how many of the most recent searches of a User are kept.
+const searchHistoriesPerUser = 20
+
+// SearchHistoryUpsert records the search of sh as the most recent search of its User, the oldest searches of the
+// User past searchHistoriesPerUser are deleted. Barcode searches are the same search whatever their queries, which
+// are updated to the ones of sh.
+func (db Database) SearchHistoryUpsert(ctx context.Context, sh model.SearchHistory) error {
+	shs := db.Collection(CollectionSearchHistories)
+	filter := bson.M{"user_id": sh.UserID, "query": sh.Query, "barcode": sh.Barcode}
+	if sh.Barcode != "" {
+		filter = bson.M{"user_id": sh.UserID, "barcode": sh.Barcode}
+	}
+	if _, err := shs.UpdateOne(ctx,
+		filter,
+		bson.M{"$set": bson.M{"query": sh.Query, "query2": sh.Query2, "ts": primitive.NewDateTimeFromTime(time.Now())}},
+		options.Update().SetUpsert(true),
+	); err != nil {
+		return errors.Wrapf(err, "error upserting SearchHistory: %+v", sh)
+	}
+
+	cur, err := shs.Find(ctx,
+		bson.M{"user_id": sh.UserID},
+		options.Find().SetSort(bson.M{"ts": -1}).SetSkip(searchHistoriesPerUser).SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error getting cursor to find old SearchHistories for UserID: %s", sh.UserID.Hex())
+	}
+	var old []model.SearchHistory
+	if err = cur.All(ctx, &old); err != nil {
+		return errors.Wrapf(err, "error getting old SearchHistories for UserID: %s from cursor", sh.UserID.Hex())
+	}
+	if len(old) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(old))
+	for _, o := range old {
+		ids = append(ids, o.ID)
+	}
+	_, err = shs.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return errors.Wrapf(err, "error deleting old SearchHistories for UserID: %s", sh.UserID.Hex())
+}
+
+// SearchHistoriesFindByUser returns the most recent searches of the User with userID first.
+func (db Database) SearchHistoriesFindByUser(
+	ctx context.Context, userID primitive.ObjectID, limit int64) ([]model.SearchHistory, error) {
+	var shs []model.SearchHistory
+	cur, err := db.Collection(CollectionSearchHistories).Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.M{"ts": -1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find SearchHistories for UserID: %s", userID.Hex())
+	}
+	if err = cur.All(ctx, &shs); err != nil {
+		return nil, errors.Wrapf(err, "error getting SearchHistories for UserID: %s from cursor", userID.Hex())
+	}
+	return shs, nil
+}
+
+// SearchHistoryFindOne finds the SearchHistory with searchID of the User with userID.
+func (db Database) SearchHistoryFindOne(
+	ctx context.Context, userID primitive.ObjectID, searchID string) (model.SearchHistory, error) {
+	var sh model.SearchHistory
+	searchOID, err := primitive.ObjectIDFromHex(searchID)
+	if err != nil {
+		return sh, errors.Wrapf(err, "error generating ObjectID from hex: %s", searchID)
+	}
+	err = db.Collection(CollectionSearchHistories).FindOne(ctx, bson.M{"_id": searchOID, "user_id": userID}).Decode(&sh)
+	return sh, errors.Wrapf(err, "error finding SearchHistory with ID: %s", searchID)
+}
+
+// SearchHistoriesDeleteByUser deletes every recent search of the User with userID and returns how many were deleted.
+func (db Database) SearchHistoriesDeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	res, err := db.Collection(CollectionSearchHistories).DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, errors.Wrapf(err, "error deleting SearchHistories for UserID: %s", userID.Hex())
+	}
+	return int(res.DeletedCount), nil
+}
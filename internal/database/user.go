@@ -2,16 +2,22 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"pricetracker/internal/model"
+	"strconv"
 	"time"
 )
 
 func (db Database) UserInsert(ctx context.Context, u model.User) (id string, err error) {
 	u.TrackedItems = []model.TrackedItem{}
+	if len(u.Roles) == 0 {
+		u.Roles = []string{model.RoleUser}
+	}
 	u.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	u.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 
@@ -38,11 +44,452 @@ func (db Database) UserFindByID(ctx context.Context, id string) (model.User, err
 	return u, errors.Wrapf(err, "error finding User with ID: %s", id)
 }
 
+func (db Database) UserFindByGoogleID(ctx context.Context, googleID string) (model.User, error) {
+	var u model.User
+	err := db.Collection(CollectionUsers).FindOne(ctx, bson.M{"google_id": googleID}).Decode(&u)
+	return u, errors.Wrapf(err, "error finding User with GoogleID: %s", googleID)
+}
+
+func (db Database) UserGoogleIDSet(ctx context.Context, userID string, googleID string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"google_id":  googleID,
+			"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting GoogleID on User with ID: %s", userID)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when setting GoogleID on User with ID: %s", userID)
+	}
+	return nil
+}
+
+func (db Database) UserCredentialsSet(ctx context.Context, userID string, email string, password []byte) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"email":      email,
+			"password":   password,
+			"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting credentials on User with ID: %s, email: %s", userID, email)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when setting credentials on User with ID: %s, email: %s", userID, email)
+	}
+	return nil
+}
+
+// UserMerge moves the Devices and TrackedItems of source into target and deletes source in a transaction, it fails
+// with ErrNoTransaction when Transactions is not set since the Devices of source are removed before they are added to
+// target. It fails with ErrTrackedItemsLimit when target would track more than trackedItemsLimit TrackedItems.
+// Only the UserDevicesLimit most recently seen Devices are kept, dropped is how many were left out.
+func (db Database) UserMerge(ctx context.Context, target model.User, source model.User, trackedItemsLimit int) (dropped int, err error) {
+	if target.ID == source.ID {
+		return 0, errors.Errorf("cannot merge User with itself, UserID: %s", target.ID.Hex())
+	}
+	if !db.Transactions {
+		return 0, errors.Wrapf(ErrNoTransaction, "error merging User with ID: %s into User with ID: %s",
+			source.ID.Hex(), target.ID.Hex())
+	}
+
+	devices := make([]model.Device, 0, len(source.Devices))
+	for _, sd := range source.Devices {
+		duplicated := false
+		for _, td := range target.Devices {
+			if td.DeviceID == sd.DeviceID {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			devices = append(devices, sd)
+		}
+	}
+	trackedItems := make([]model.TrackedItem, 0, len(source.TrackedItems))
+	for _, sti := range source.TrackedItems {
+		duplicated := false
+		for _, tti := range target.TrackedItems {
+			if tti.ItemID == sti.ItemID {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			trackedItems = append(trackedItems, sti)
+		}
+	}
+
+	free := trackedItemsLimit - len(trackedItems)
+	if free < 0 || len(target.TrackedItems) > free {
+		return 0, errors.Wrapf(ErrTrackedItemsLimit, "merging User with ID: %s into User with ID: %s exceeds the limit of %d",
+			source.ID.Hex(), target.ID.Hex(), trackedItemsLimit)
+	}
+
+	if n := len(target.Devices) + len(devices); n > UserDevicesLimit {
+		dropped = n - UserDevicesLimit
+	}
+
+	return dropped, db.WithTransaction(ctx, func(ctx context.Context) error {
+		users := db.Collection(CollectionUsers)
+		// The Devices of source are removed first to release their unique FCMToken keys for target.
+		if len(devices) > 0 {
+			if _, err := users.UpdateOne(ctx, bson.M{"_id": source.ID}, bson.M{"$set": bson.M{"devices": bson.A{}}}); err != nil {
+				return errors.Wrapf(err, "error removing Devices of source User with ID: %s", source.ID.Hex())
+			}
+		}
+
+		res, err := users.UpdateOne(
+			ctx,
+			// Checked again in the filter in case target tracked more Items since it was read.
+			bson.M{"_id": target.ID, "tracked_items." + strconv.Itoa(free): bson.M{"$exists": false}},
+			bson.M{
+				"$push": bson.M{
+					"devices": bson.M{
+						"$each":  devices,
+						"$sort":  bson.M{"last_seen": -1},
+						"$slice": UserDevicesLimit,
+					},
+					"tracked_items": bson.M{
+						"$each": trackedItems,
+						"$sort": bson.M{"updated_at": -1},
+					},
+				},
+				"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+			},
+		)
+		if err != nil {
+			return errors.Wrapf(err, "error merging User with ID: %s into User with ID: %s", source.ID.Hex(), target.ID.Hex())
+		}
+		if res.MatchedCount == 0 {
+			return errors.Wrapf(ErrTrackedItemsLimit, "merging User with ID: %s into User with ID: %s exceeds the limit of %d",
+				source.ID.Hex(), target.ID.Hex(), trackedItemsLimit)
+		}
+
+		delRes, err := users.DeleteOne(ctx, bson.M{"_id": source.ID})
+		if err != nil {
+			return errors.Wrapf(err, "error deleting source User when merging User with ID: %s into User with ID: %s",
+				source.ID.Hex(), target.ID.Hex())
+		}
+		if delRes.DeletedCount == 0 {
+			return errors.Wrapf(ErrNoDocumentsModified, "source User not deleted when merging User with ID: %s into User with ID: %s",
+				source.ID.Hex(), target.ID.Hex())
+		}
+		return nil
+	})
+}
+
+func (db Database) UserFindByReferralCode(ctx context.Context, code string) (model.User, error) {
+	var u model.User
+	err := db.Collection(CollectionUsers).FindOne(ctx, bson.M{"referral.code": code}).Decode(&u)
+	return u, errors.Wrapf(err, "error finding User with referral code: %s", code)
+}
+
+func (db Database) UserReferralCodeSet(ctx context.Context, userID string, code string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "referral.code": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"referral.code": code,
+			"updated_at":    primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting referral code on User with ID: %s, code: %s", userID, code)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when setting referral code on User with ID: %s, code: %s", userID, code)
+	}
+	return nil
+}
+
+// UserReferralRewardClaim marks the referral of the referred User with userID as rewarded and returns the ID of
+// its referrer, it fails with mongo.ErrNoDocuments when the User was not referred or is already rewarded.
+func (db Database) UserReferralRewardClaim(ctx context.Context, userID primitive.ObjectID) (primitive.ObjectID, error) {
+	var u model.User
+	err := db.Collection(CollectionUsers).FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":                  userID,
+			"referral.referred_by": bson.M{"$exists": true},
+			"referral.rewarded_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"referral.rewarded_at": primitive.NewDateTimeFromTime(time.Now())}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"referral.referred_by": 1}),
+	).Decode(&u)
+	if err != nil {
+		return primitive.NilObjectID, errors.Wrapf(err, "error claiming referral reward of User with ID: %s", userID.Hex())
+	}
+	return u.Referral.ReferredBy, nil
+}
+
+// UserReferralRewardAdd counts a referral of the User with userID and adds trackedItemsBonus to its tracked items
+// bonus, which does not go over trackedItemsBonusMax.
+func (db Database) UserReferralRewardAdd(
+	ctx context.Context, userID primitive.ObjectID, trackedItemsBonus int, trackedItemsBonusMax int,
+) error {
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.A{bson.M{"$set": bson.M{
+			"referral.count": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$referral.count", 0}}, 1}},
+			"referral.tracked_items_bonus": bson.M{"$max": bson.A{
+				bson.M{"$ifNull": bson.A{"$referral.tracked_items_bonus", 0}},
+				bson.M{"$min": bson.A{
+					bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$referral.tracked_items_bonus", 0}}, trackedItemsBonus}},
+					trackedItemsBonusMax,
+				}},
+			}},
+			"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+		}}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when adding referral reward to User with ID: %s", userID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when adding referral reward to User with ID: %s", userID.Hex())
+	}
+	return nil
+}
+
+func (db Database) UserTelegramSet(ctx context.Context, userID string, t model.Telegram) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"telegram":                      t,
+			"notification.telegram_enabled": t.ChatID != 0,
+			"updated_at":                    primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting Telegram on User with ID: %s, ChatID: %d", userID, t.ChatID)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when setting Telegram on User with ID: %s, ChatID: %d", userID, t.ChatID)
+	}
+	return nil
+}
+
+// UserTelegramLinkTokenSet sets the pending Telegram link token of the User with userID, replacing an earlier one.
+func (db Database) UserTelegramLinkTokenSet(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"telegram.link_token_hash":       tokenHash,
+			"telegram.link_token_expires_at": primitive.NewDateTimeFromTime(expiresAt),
+			"updated_at":                     primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting Telegram link token on User with ID: %s", userID)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting Telegram link token on User with ID: %s", userID)
+	}
+	return nil
+}
+
+// UserTelegramLinkByToken links chatID to the User with the unexpired link token hashed to tokenHash, using the token
+// up. It returns the linked User, or mongo.ErrNoDocuments when no User has such a token.
+func (db Database) UserTelegramLinkByToken(ctx context.Context, tokenHash string, chatID int64, now time.Time) (model.User, error) {
+	var u model.User
+	nowDT := primitive.NewDateTimeFromTime(now)
+	err := db.Collection(CollectionUsers).FindOneAndUpdate(
+		ctx,
+		bson.M{"telegram.link_token_hash": tokenHash, "telegram.link_token_expires_at": bson.M{"$gt": nowDT}},
+		bson.M{"$set": bson.M{
+			"telegram":                      model.Telegram{ChatID: chatID, LinkedAt: nowDT},
+			"notification.telegram_enabled": true,
+			"updated_at":                    nowDT,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&u)
+	return u, errors.Wrapf(err, "error linking Telegram ChatID: %d by link token", chatID)
+}
+
+func (db Database) UserNotificationPreferencesUpdate(ctx context.Context, userID string, np model.NotificationPreferences) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"notification": np,
+			"updated_at":   primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when updating NotificationPreferences on User with ID: %s", userID)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when updating NotificationPreferences on User with ID: %s", userID)
+	}
+	return nil
+}
+
+func (db Database) UserLocaleSet(ctx context.Context, userID string, locale string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"locale":     locale,
+			"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting Locale on User with ID: %s", userID)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting Locale on User with ID: %s", userID)
+	}
+	return nil
+}
+
+func (db Database) UserEntitlementSet(ctx context.Context, userID string, e model.Entitlement) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"entitlement": e,
+			"updated_at":  primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting Entitlement on User with ID: %s, Tier: %s", userID, e.Tier)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting Entitlement on User with ID: %s", userID)
+	}
+	return nil
+}
+
+// UserTrackedItemsQuotaSet sets the tracked items quota of the User with userID, a quota of 0 unsets it.
+func (db Database) UserTrackedItemsQuotaSet(ctx context.Context, userID string, quota int) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	set := bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())}
+	update := bson.M{"$set": set}
+	if quota > 0 {
+		set["tracked_items_quota"] = quota
+	} else {
+		update["$unset"] = bson.M{"tracked_items_quota": ""}
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting tracked items quota on User with ID: %s, quota: %d", userID, quota)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting quota on User with ID: %s", userID)
+	}
+	return nil
+}
+
+func (db Database) UserRolesSet(ctx context.Context, userID string, roles []string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{
+			"roles":      roles,
+			"updated_at": primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting Roles on User with ID: %s, Roles: %v", userID, roles)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting Roles on User with ID: %s", userID)
+	}
+	return nil
+}
+
+func (db Database) UsersRoleAddByEmail(ctx context.Context, emails []string, role string) (int, error) {
+	res, err := db.Collection(CollectionUsers).UpdateMany(
+		ctx,
+		bson.M{"email": bson.M{"$in": emails}, "roles": bson.M{"$ne": role}},
+		bson.M{
+			"$addToSet": bson.M{"roles": role},
+			"$set":      bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		},
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error when adding Role: %s to Users with emails: %v", role, emails)
+	}
+	return int(res.ModifiedCount), nil
+}
+
+// UsersRolesMigrate sets the default user Role on Users created before Roles existed.
+func (db Database) UsersRolesMigrate(ctx context.Context) (int, error) {
+	res, err := db.Collection(CollectionUsers).UpdateMany(
+		ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"roles": bson.M{"$exists": false}},
+			bson.M{"roles": nil},
+			bson.M{"roles": bson.M{"$size": 0}},
+		}},
+		bson.M{"$set": bson.M{"roles": bson.A{model.RoleUser}}},
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "error when migrating Users with no Roles")
+	}
+	return int(res.ModifiedCount), nil
+}
+
 func (db Database) UsersDeviceFCMTokensFindByTrackedItem(ctx context.Context, itemID primitive.ObjectID) ([]model.User, error) {
 	var us []model.User
 	cur, err := db.Collection(CollectionUsers).Find(ctx,
 		bson.M{"tracked_items.item_id": itemID},
-		options.Find().SetProjection(bson.M{"tracked_items.$": 1, "devices.fcm_token": 1}),
+		options.Find().SetProjection(bson.M{
+			"tracked_items.$":   1,
+			"devices.fcm_token": 1,
+			"telegram":          1,
+			"notification":      1,
+			"locale":            1,
+		}),
 	)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error getting cursor to find Users that tracked ItemID: %s", itemID.Hex())
@@ -53,54 +500,314 @@ func (db Database) UsersDeviceFCMTokensFindByTrackedItem(ctx context.Context, it
 	return us, nil
 }
 
-func (db Database) UserTrackedItemAdd(ctx context.Context, userID string, ti model.TrackedItem) error {
+// UserTrackedItemAdd adds ti to the User with userID, failing with ErrTrackedItemsLimit when the User already
+// tracks trackedItemsLimit TrackedItems.
+func (db Database) UserTrackedItemAdd(ctx context.Context, userID string, ti model.TrackedItem, trackedItemsLimit int) error {
+	return errors.Wrapf(db.UserTrackedItemsAdd(ctx, userID, []model.TrackedItem{ti}, trackedItemsLimit),
+		"error adding TrackedItem with ItemID: %s", ti.ItemID.Hex())
+}
+
+// UserTrackedItemsAdd adds every TrackedItem of tis to the User with userID in a single update, failing with
+// ErrTrackedItemsLimit when the User would track more than trackedItemsLimit TrackedItems. The limit is checked in
+// the update filter, so concurrent adds can not exceed it.
+func (db Database) UserTrackedItemsAdd(ctx context.Context, userID string, tis []model.TrackedItem, trackedItemsLimit int) error {
 	userOID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return errors.Wrapf(err, "error creating userOID from hex: %s", userID)
 	}
-	ti.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
-	ti.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	// The User can have at most trackedItemsLimit-len(tis) TrackedItems, so that index must not exist.
+	free := trackedItemsLimit - len(tis)
+	if free < 0 {
+		return errors.Wrapf(ErrTrackedItemsLimit, "adding %d TrackedItems to User with ID: %s exceeds the limit of %d",
+			len(tis), userID, trackedItemsLimit)
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	for idx := range tis {
+		tis[idx].CreatedAt = now
+		tis[idx].UpdatedAt = now
+	}
 	res, err := db.Collection(CollectionUsers).UpdateOne(
 		ctx,
-		bson.M{"_id": userOID},
+		bson.M{"_id": userOID, "tracked_items." + strconv.Itoa(free): bson.M{"$exists": false}},
 		bson.M{
 			"$push": bson.M{
 				"tracked_items": bson.M{
-					"$each":     []model.TrackedItem{ti},
+					"$each":     tis,
 					"$position": 0,
-					"$slice":    25,
 				},
 			},
-			"$set": bson.M{
-				"updated_at": primitive.NewDateTimeFromTime(time.Now()),
-			},
+			"$set": bson.M{"updated_at": now},
 		},
 	)
 	if err != nil {
-		return errors.Wrapf(err, "error when adding TrackedItem to User with ID: %s, ItemID: %s", userID, ti.ItemID.Hex())
+		return errors.Wrapf(err, "error when adding %d TrackedItems to User with ID: %s", len(tis), userID)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrTrackedItemsLimit,
+			"User not found or tracking too many Items to add %d TrackedItems to User with ID: %s, limit: %d",
+			len(tis), userID, trackedItemsLimit)
 	}
 	if res.ModifiedCount == 0 {
 		return errors.Wrapf(ErrNoDocumentsModified,
-			"User not modified when adding TrackedItem to User with ID: %s, ItemID: %s", userID, ti.ItemID.Hex())
+			"User not modified when adding %d TrackedItems to User with ID: %s", len(tis), userID)
 	}
 	return nil
 }
 
-func (db Database) UserTrackedItemUpdate(ctx context.Context, userID string, ti model.TrackedItem) error {
+func (db Database) UserTrackedItemFetchIntervalSet(
+	ctx context.Context, userID string, itemID string, minutes int, until primitive.DateTime) error {
 	userOID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return errors.Wrapf(err, "error creating userOID from hex: %s", userID)
 	}
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating itemOID from hex: %s", itemID)
+	}
+	set := bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())}
+	unset := bson.M{}
+	if minutes > 0 {
+		set["tracked_items.$.fetch_interval_minutes"] = minutes
+	} else {
+		unset["tracked_items.$.fetch_interval_minutes"] = ""
+	}
+	if minutes > 0 && until != 0 {
+		set["tracked_items.$.fetch_interval_until"] = until
+	} else {
+		unset["tracked_items.$.fetch_interval_until"] = ""
+	}
+	update := bson.M{"$set": set, "$unset": unset}
 	res, err := db.Collection(CollectionUsers).UpdateOne(
 		ctx,
-		bson.M{"_id": userOID, "tracked_items.item_id": ti.ItemID},
+		bson.M{"_id": userOID, "tracked_items.item_id": itemOID},
+		update,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error setting TrackedItem fetch interval on User with ID: %s, ItemID: %s", userID, itemID)
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified,
+			"TrackedItem not found when setting fetch interval on User with ID: %s, ItemID: %s", userID, itemID)
+	}
+	return nil
+}
+
+type ItemFetchInterval struct {
+	ItemID          primitive.ObjectID `bson:"_id"`
+	IntervalMinutes int                `bson:"interval_minutes"`
+}
+
+// ItemFetchIntervalsFind returns the shortest active custom fetch interval of every Item,
+// only TrackedItems of Users with an unexpired paid tier are considered.
+func (db Database) ItemFetchIntervalsFind(ctx context.Context, now time.Time) ([]ItemFetchInterval, error) {
+	nowDT := primitive.NewDateTimeFromTime(now)
+	activeInterval := bson.M{
+		"tracked_items.fetch_interval_minutes": bson.M{"$gt": 0},
+		"$or": bson.A{
+			bson.M{"tracked_items.fetch_interval_until": bson.M{"$exists": false}},
+			bson.M{"tracked_items.fetch_interval_until": bson.M{"$gt": nowDT}},
+		},
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"tracked_items.fetch_interval_minutes": bson.M{"$gt": 0},
+			"entitlement.tier":                     bson.M{"$exists": true, "$ne": model.TierFree},
+			"$or": bson.A{
+				bson.M{"entitlement.expires_at": bson.M{"$exists": false}},
+				bson.M{"entitlement.expires_at": bson.M{"$gt": nowDT}},
+			},
+		}}},
+		{{Key: "$unwind", Value: "$tracked_items"}},
+		{{Key: "$match", Value: activeInterval}},
+		{{Key: "$group", Value: bson.M{
+			"_id":              "$tracked_items.item_id",
+			"interval_minutes": bson.M{"$min": "$tracked_items.fetch_interval_minutes"},
+		}}},
+	}
+	var ifis []ItemFetchInterval
+	cur, err := db.Collection(CollectionUsers).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to aggregate Item fetch intervals")
+	}
+	if err = cur.All(ctx, &ifis); err != nil {
+		return nil, errors.Wrap(err, "error getting all Item fetch intervals from cursor")
+	}
+	return ifis, nil
+}
+
+// ItemTrackerCountsFind returns how many Users track every tracked Item.
+func (db Database) ItemTrackerCountsFind(ctx context.Context) (map[primitive.ObjectID]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$tracked_items"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$tracked_items.item_id",
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	var res []struct {
+		ItemID primitive.ObjectID `bson:"_id"`
+		Count  int                `bson:"count"`
+	}
+	cur, err := db.Collection(CollectionUsers).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cursor to aggregate Item tracker counts")
+	}
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrap(err, "error getting all Item tracker counts from cursor")
+	}
+	counts := make(map[primitive.ObjectID]int, len(res))
+	for _, r := range res {
+		counts[r.ItemID] = r.Count
+	}
+	return counts, nil
+}
+
+type ItemTrackCount struct {
+	ItemID primitive.ObjectID `bson:"_id"`
+	Count  int                `bson:"count"`
+}
+
+// ItemsTrackedSinceCount returns the limit Items tracked by the most Users since since, most tracked first.
+// Only Items in category are counted when category is set.
+func (db Database) ItemsTrackedSinceCount(
+	ctx context.Context, since time.Time, category string, limit int64) ([]ItemTrackCount, error) {
+	sinceDT := primitive.NewDateTimeFromTime(since)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tracked_items.created_at": bson.M{"$gte": sinceDT}}}},
+		{{Key: "$unwind", Value: "$tracked_items"}},
+		{{Key: "$match", Value: bson.M{"tracked_items.created_at": bson.M{"$gte": sinceDT}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$tracked_items.item_id",
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	opts := options.Aggregate()
+	if category != "" {
+		pipeline = append(pipeline, itemCategoryStages(category)...)
+		opts.SetCollation(categoryCollation)
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+	cur, err := db.Collection(CollectionUsers).Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to aggregate Items tracked since: %s", since.Format(time.RFC3339))
+	}
+	var res []ItemTrackCount
+	if err = cur.All(ctx, &res); err != nil {
+		return nil, errors.Wrapf(err, "error getting Items tracked since: %s from cursor", since.Format(time.RFC3339))
+	}
+	return res, nil
+}
+
+// UsersDigestDueFind finds up to limit Users with an email who opted in to price digests of frequency
+// and were last sent one before sentBefore, or never.
+func (db Database) UsersDigestDueFind(
+	ctx context.Context, frequency string, sentBefore time.Time, limit int64) ([]model.User, error) {
+	var us []model.User
+	cur, err := db.Collection(CollectionUsers).Find(ctx, bson.M{
+		"notification.digest": frequency,
+		"email":               bson.M{"$ne": ""},
+		"$or": bson.A{
+			bson.M{"digest_sent_at": bson.M{"$exists": false}},
+			bson.M{"digest_sent_at": bson.M{"$lt": primitive.NewDateTimeFromTime(sentBefore)}},
+		},
+	}, options.Find().SetProjection(bson.M{
+		"name":                       1,
+		"email":                      1,
+		"locale":                     1,
+		"digest_sent_at":             1,
+		"tracked_items.item_id":      1,
+		"tracked_items.variation_id": 1,
+	}).SetLimit(limit))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting cursor to find Users due a %s digest", frequency)
+	}
+	if err = cur.All(ctx, &us); err != nil {
+		return nil, errors.Wrapf(err, "error getting Users due a %s digest from cursor", frequency)
+	}
+	return us, nil
+}
+
+func (db Database) UserDigestSentAtSet(ctx context.Context, userID primitive.ObjectID, sentAt time.Time) error {
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"digest_sent_at": primitive.NewDateTimeFromTime(sentAt)}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when setting DigestSentAt on User with ID: %s", userID.Hex())
+	}
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not found when setting DigestSentAt on User with ID: %s", userID.Hex())
+	}
+	return nil
+}
+
+// UsersTrackedItemAlertsExpire disables notifications on every TrackedItem whose alert window ended before now,
+// it returns the number of Users modified.
+func (db Database) UsersTrackedItemAlertsExpire(ctx context.Context, now time.Time) (int, error) {
+	nowDT := primitive.NewDateTimeFromTime(now)
+	res, err := db.Collection(CollectionUsers).UpdateMany(
+		ctx,
+		bson.M{"tracked_items": bson.M{"$elemMatch": bson.M{
+			"notification_enabled": true,
+			"active_until":         bson.M{"$lte": nowDT},
+		}}},
 		bson.M{"$set": bson.M{
-			"tracked_items.$.price_lower_threshold": ti.PriceLowerThreshold,
-			"tracked_items.$.notification_enabled":  ti.NotificationEnabled,
-			"tracked_items.$.notification_count":    ti.NotificationCount,
-			"tracked_items.$.updated_at":            primitive.NewDateTimeFromTime(time.Now()),
-			"updated_at":                            primitive.NewDateTimeFromTime(time.Now()),
+			"tracked_items.$[ti].notification_enabled": false,
+			"tracked_items.$[ti].updated_at":           nowDT,
 		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []any{
+			bson.M{"ti.notification_enabled": true, "ti.active_until": bson.M{"$lte": nowDT}},
+		}}),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "error disabling TrackedItem alerts with expired windows")
+	}
+	return int(res.ModifiedCount), nil
+}
+
+func (db Database) UserTrackedItemUpdate(ctx context.Context, userID string, ti model.TrackedItem) error {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating userOID from hex: %s", userID)
+	}
+	set := bson.M{
+		"tracked_items.$.price_lower_threshold":     ti.PriceLowerThreshold,
+		"tracked_items.$.percentage_drop_threshold": ti.PercentageDropThreshold,
+		"tracked_items.$.notification_enabled":      ti.NotificationEnabled,
+		"tracked_items.$.notify_on_restock":         ti.NotifyOnRestock,
+		"tracked_items.$.notification_count":        ti.NotificationCount,
+		"tracked_items.$.updated_at":                primitive.NewDateTimeFromTime(time.Now()),
+		"updated_at":                                primitive.NewDateTimeFromTime(time.Now()),
+	}
+	unset := bson.M{}
+	if ti.RatingAlertBelow != 0 {
+		set["tracked_items.$.rating_alert_below"] = ti.RatingAlertBelow
+	} else {
+		unset["tracked_items.$.rating_alert_below"] = ""
+	}
+	if ti.SoldJumpAlert != 0 {
+		set["tracked_items.$.sold_jump_alert"] = ti.SoldJumpAlert
+	} else {
+		unset["tracked_items.$.sold_jump_alert"] = ""
+	}
+	if ti.ActiveFrom != 0 {
+		set["tracked_items.$.active_from"] = ti.ActiveFrom
+	} else {
+		unset["tracked_items.$.active_from"] = ""
+	}
+	if ti.ActiveUntil != 0 {
+		set["tracked_items.$.active_until"] = ti.ActiveUntil
+	} else {
+		unset["tracked_items.$.active_until"] = ""
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userOID, "tracked_items.item_id": ti.ItemID},
+		bson.M{"$set": set, "$unset": unset},
 	)
 	if err != nil {
 		return errors.Wrapf(err, "error updating TrackedItem on User with ID: %s, ItemID: %s", userID, ti.ItemID.Hex())
@@ -124,6 +831,86 @@ func (db Database) UserTrackedItemUpdate(ctx context.Context, userID string, ti
 	return nil
 }
 
+// UserTrackedItemVariationSet changes the tracked variant of the TrackedItem with itemID along with its PriceInitial,
+// an empty variationID tracks the Item itself.
+func (db Database) UserTrackedItemVariationSet(
+	ctx context.Context, userID string, itemID primitive.ObjectID, variationID string, priceInitial int,
+) error {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating userOID from hex: %s", userID)
+	}
+	update := bson.M{"$set": bson.M{
+		"tracked_items.$.variation_id":  variationID,
+		"tracked_items.$.price_initial": priceInitial,
+	}}
+	if variationID == "" {
+		update = bson.M{
+			"$set":   bson.M{"tracked_items.$.price_initial": priceInitial},
+			"$unset": bson.M{"tracked_items.$.variation_id": ""},
+		}
+	}
+	_, err = db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userOID, "tracked_items.item_id": itemID},
+		update,
+	)
+	return errors.Wrapf(err, "error setting TrackedItem variation on User with ID: %s, ItemID: %s", userID, itemID.Hex())
+}
+
+// TrackedItemUpdate is a partial update of a TrackedItem, nil fields are left unchanged.
+type TrackedItemUpdate struct {
+	ItemID              primitive.ObjectID
+	PriceLowerThreshold *int
+	NotificationEnabled *bool
+}
+
+func (db Database) UserTrackedItemsBulkUpdate(ctx context.Context, userID string, tius []TrackedItemUpdate) (int, error) {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error creating userOID from hex: %s", userID)
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	models := make([]mongo.WriteModel, 0, len(tius))
+	for _, tiu := range tius {
+		set := bson.M{
+			"tracked_items.$.notification_count": 0,
+			"tracked_items.$.updated_at":         now,
+			"updated_at":                         now,
+		}
+		if tiu.PriceLowerThreshold != nil {
+			set["tracked_items.$.price_lower_threshold"] = *tiu.PriceLowerThreshold
+		}
+		if tiu.NotificationEnabled != nil {
+			set["tracked_items.$.notification_enabled"] = *tiu.NotificationEnabled
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": userOID, "tracked_items.item_id": tiu.ItemID}).
+			SetUpdate(bson.M{"$set": set}))
+	}
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	res, err := db.Collection(CollectionUsers).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, errors.Wrapf(err, "error bulk updating %d TrackedItem(s) on User with ID: %s", len(models), userID)
+	}
+	if _, err = db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userOID},
+		bson.M{"$push": bson.M{
+			"tracked_items": bson.M{
+				"$each": []model.TrackedItem{},
+				"$sort": bson.M{"updated_at": -1},
+			},
+		}},
+	); err != nil {
+		return int(res.MatchedCount), errors.Wrapf(err, "error sorting TrackedItems after bulk update on User with ID: %s", userID)
+	}
+	return int(res.MatchedCount), nil
+}
+
 func (db Database) UserTrackedItemRemove(ctx context.Context, userID string, itemID string) error {
 	userOID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -147,6 +934,83 @@ func (db Database) UserTrackedItemRemove(ctx context.Context, userID string, ite
 	return nil
 }
 
+func (db Database) UserTrackedItemWebhookAdd(
+	ctx context.Context, userID string, itemID string, wh model.Webhook, webhooksLimit int,
+) error {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating User ObjectID from hex: %s", userID)
+	}
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating Item ObjectID from hex: %s", itemID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{
+			"_id": userOID,
+			"tracked_items": bson.M{"$elemMatch": bson.M{
+				"item_id": itemOID,
+				fmt.Sprintf("webhooks.%d", webhooksLimit-1): bson.M{"$exists": false},
+			}},
+		},
+		bson.M{
+			"$push": bson.M{"tracked_items.$.webhooks": wh},
+			"$set":  bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error adding Webhook to TrackedItem on User with ID: %s, ItemID: %s", userID, itemID)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when adding Webhook to TrackedItem on User with ID: %s, ItemID: %s", userID, itemID)
+	}
+	return nil
+}
+
+func (db Database) UserTrackedItemWebhookRemove(ctx context.Context, userID string, itemID string, webhookID string) error {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating User ObjectID from hex: %s", userID)
+	}
+	itemOID, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating Item ObjectID from hex: %s", itemID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": userOID, "tracked_items.item_id": itemOID},
+		bson.M{
+			"$pull": bson.M{"tracked_items.$.webhooks": bson.M{"id": webhookID}},
+			"$set":  bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error removing Webhook from TrackedItem on User with ID: %s, ItemID: %s", userID, itemID)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when removing Webhook: %s from TrackedItem on User with ID: %s, ItemID: %s",
+			webhookID, userID, itemID)
+	}
+	return nil
+}
+
+func (db Database) UsersTrackedItemAlternativesSet(
+	ctx context.Context, itemID primitive.ObjectID, alternatives []model.ItemAlternative) (int, error) {
+	res, err := db.Collection(CollectionUsers).UpdateMany(
+		ctx,
+		bson.M{"tracked_items.item_id": itemID},
+		bson.M{"$set": bson.M{
+			"tracked_items.$.alternatives": alternatives,
+			"tracked_items.$.updated_at":   primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return -1, errors.Wrapf(err, "error when setting Users TrackedItem Alternatives, ItemID: %s", itemID.Hex())
+	}
+	return int(res.ModifiedCount), nil
+}
+
 func (db Database) UserTrackedItemNotificationCountIncrement(
 	ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error) {
 	res, err := db.Collection(CollectionUsers).UpdateMany(
@@ -169,6 +1033,19 @@ func (db Database) UserTrackedItemNotificationCountIncrement(
 	return int(res.ModifiedCount), nil
 }
 
+func (db Database) UserTrackedItemNotificationCountReset(
+	ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error) {
+	res, err := db.Collection(CollectionUsers).UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": userIDs}, "tracked_items.item_id": itemID},
+		bson.M{"$set": bson.M{"tracked_items.$.notification_count": 0}},
+	)
+	if err != nil {
+		return -1, errors.Wrapf(err, "error when resetting Users TrackedItem Notification Count, UserIDs: %v, ItemID: %s", userIDs, itemID.Hex())
+	}
+	return int(res.ModifiedCount), nil
+}
+
 func (db Database) UserDeviceAdd(ctx context.Context, userID string, d model.Device) error {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -185,7 +1062,7 @@ func (db Database) UserDeviceAdd(ctx context.Context, userID string, d model.Dev
 					"$each":     []model.Device{d},
 					"$position": 0,
 					"$sort":     bson.M{"last_seen": -1},
-					"$slice":    5,
+					"$slice":    UserDevicesLimit,
 				},
 			},
 			"$set": bson.M{
@@ -246,6 +1123,47 @@ func (db Database) UserDeviceFCMTokenUpdate(ctx context.Context, userID string,
 	return nil
 }
 
+func (db Database) UserDeviceFCMTokenUnset(ctx context.Context, fcmToken string) error {
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"devices.fcm_token": fcmToken},
+		bson.M{
+			"$unset": bson.M{"devices.$.fcm_token": ""},
+			"$set":   bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when unsetting Device FCMToken: %s", fcmToken)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when unsetting Device FCMToken: %s", fcmToken)
+	}
+	return nil
+}
+
+func (db Database) UserDeviceLoginTokenUpdate(ctx context.Context, userID string, deviceID string, lt model.LoginToken) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.Wrapf(err, "error creating ObjectID from hex: %s", userID)
+	}
+	res, err := db.Collection(CollectionUsers).UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "devices.device_id": deviceID},
+		bson.M{"$set": bson.M{
+			"devices.$.login_token": lt,
+			"devices.$.last_seen":   primitive.NewDateTimeFromTime(time.Now()),
+			"updated_at":            primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "error when updating Device LoginToken on User with ID: %s, DeviceID: %s", userID, deviceID)
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "User not modified when updating Device LoginToken on User with ID: %s, DeviceID: %s", userID, deviceID)
+	}
+	return nil
+}
+
 func (db Database) UserDeviceLastSeenUpdate(ctx context.Context, userID string, deviceID string) error {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
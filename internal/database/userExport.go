@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"pricetracker/internal/model"
+	"time"
+)
+
+func (db Database) UserExportInsert(ctx context.Context, ue model.UserExport) (primitive.ObjectID, error) {
+	ue.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	r, err := db.Collection(CollectionUserExports).InsertOne(ctx, ue)
+	if err != nil {
+		return primitive.NilObjectID, errors.Wrapf(err, "error inserting UserExport: %+v", ue)
+	}
+	return r.InsertedID.(primitive.ObjectID), nil
+}
+
+func (db Database) UserExportFindOne(ctx context.Context, userID primitive.ObjectID, exportID string) (model.UserExport, error) {
+	var ue model.UserExport
+	exportOID, err := primitive.ObjectIDFromHex(exportID)
+	if err != nil {
+		return ue, errors.Wrapf(err, "error generating ObjectID from hex: %s", exportID)
+	}
+	err = db.Collection(CollectionUserExports).FindOne(ctx, bson.M{"_id": exportOID, "user_id": userID}).Decode(&ue)
+	return ue, errors.Wrapf(err, "error finding UserExport with ID: %s for UserID: %s", exportID, userID.Hex())
+}
+
+// UserExportsCountSince counts the UserExports of a User created at or after since.
+func (db Database) UserExportsCountSince(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error) {
+	n, err := db.Collection(CollectionUserExports).CountDocuments(ctx, bson.M{
+		"user_id":    userID,
+		"created_at": bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+	})
+	return n, errors.Wrapf(err, "error counting UserExports for UserID: %s", userID.Hex())
+}
+
+// UserExportFinish saves the result of generating a UserExport.
+func (db Database) UserExportFinish(ctx context.Context, ue model.UserExport) error {
+	set := bson.M{
+		"status":      ue.Status,
+		"finished_at": primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if !ue.FileID.IsZero() {
+		set["file_id"] = ue.FileID
+		set["size"] = ue.Size
+	}
+	if ue.Error != "" {
+		set["error"] = ue.Error
+	}
+	res, err := db.Collection(CollectionUserExports).UpdateOne(ctx, bson.M{"_id": ue.ID}, bson.M{"$set": set})
+	if err != nil {
+		return errors.Wrapf(err, "error finishing UserExport with ID: %s", ue.ID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return errors.Wrapf(ErrNoDocumentsModified, "UserExport with ID: %s not found", ue.ID.Hex())
+	}
+	return nil
+}
+
+func (db Database) userExportBucket() (*gridfs.Bucket, error) {
+	b, err := gridfs.NewBucket(db.Database, options.GridFSBucket().SetName(BucketUserExportFiles))
+	return b, errors.Wrap(err, "error creating UserExport files bucket")
+}
+
+// UserExportFileCreate opens a file for the archive of a UserExport, the file is stored when closed.
+func (db Database) UserExportFileCreate(filename string) (*gridfs.UploadStream, error) {
+	b, err := db.userExportBucket()
+	if err != nil {
+		return nil, err
+	}
+	us, err := b.OpenUploadStream(filename)
+	return us, errors.Wrapf(err, "error opening UserExport file: %s", filename)
+}
+
+func (db Database) UserExportFileOpen(fileID primitive.ObjectID) (io.ReadCloser, error) {
+	b, err := db.userExportBucket()
+	if err != nil {
+		return nil, err
+	}
+	ds, err := b.OpenDownloadStream(fileID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening UserExport file with ID: %s", fileID.Hex())
+	}
+	return ds, nil
+}
+
+// UserExportsExpiredDelete deletes the UserExports expired before now along with their files,
+// it returns the number of UserExports deleted.
+func (db Database) UserExportsExpiredDelete(ctx context.Context, now time.Time) (int, error) {
+	filter := bson.M{"expires_at": bson.M{"$lt": primitive.NewDateTimeFromTime(now)}}
+	var ues []model.UserExport
+	cur, err := db.Collection(CollectionUserExports).Find(ctx, filter)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting cursor to find expired UserExports")
+	}
+	if err = cur.All(ctx, &ues); err != nil {
+		return 0, errors.Wrap(err, "error getting expired UserExports from cursor")
+	}
+	if len(ues) == 0 {
+		return 0, nil
+	}
+	b, err := db.userExportBucket()
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(ues))
+	for _, ue := range ues {
+		if !ue.FileID.IsZero() {
+			if err = b.Delete(ue.FileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+				return 0, errors.Wrapf(err, "error deleting file of UserExport with ID: %s", ue.ID.Hex())
+			}
+		}
+		ids = append(ids, ue.ID)
+	}
+	res, err := db.Collection(CollectionUserExports).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, errors.Wrapf(err, "error deleting %d expired UserExport(s)", len(ids))
+	}
+	return int(res.DeletedCount), nil
+}
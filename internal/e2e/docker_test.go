@@ -0,0 +1,72 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// container is a Docker container started for the harness, it is removed when stopped.
+type container struct {
+	id   string
+	addr string
+}
+
+// startContainer runs image detached with its containerPort published on a random host port.
+func startContainer(ctx context.Context, image string, containerPort string) (container, error) {
+	var c container
+	out, err := exec.CommandContext(ctx, "docker", "run", "-d", "--rm", "-p", "127.0.0.1::"+containerPort, image).Output()
+	if err != nil {
+		return c, errors.Wrapf(commandError(err), "error running container from image: %s", image)
+	}
+	c.id = strings.TrimSpace(string(out))
+
+	out, err = exec.CommandContext(ctx, "docker", "port", c.id, containerPort+"/tcp").Output()
+	if err != nil {
+		c.stop()
+		return c, errors.Wrapf(commandError(err), "error getting published port of container: %s", c.id)
+	}
+	c.addr = strings.TrimSpace(strings.Split(string(out), "\n")[0])
+	if err = waitTCP(ctx, c.addr, 30*time.Second); err != nil {
+		c.stop()
+		return c, errors.Wrapf(err, "container from image %s not reachable", image)
+	}
+	return c, nil
+}
+
+func (c container) stop() {
+	if c.id != "" {
+		_ = exec.Command("docker", "rm", "-f", c.id).Run()
+	}
+}
+
+func waitTCP(ctx context.Context, addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return errors.Wrapf(err, "timed out waiting for %s", addr)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+func commandError(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+		return errors.Errorf("%v: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"pricetracker/internal/client"
+	"strconv"
+	"sync"
+)
+
+// fakeHosts are the external hosts whose requests are served by FakeSites instead.
+var fakeHosts = map[string]bool{
+	"shopee.co.id":       true,
+	"fcm.googleapis.com": true,
+}
+
+type FakeShopeeItem struct {
+	Name  string
+	Price int
+	Stock int
+}
+
+// FakeSites serves the Shopee item and shop APIs from in-memory items and records the FCM notifications sent.
+type FakeSites struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	shopeeItems map[[2]string]FakeShopeeItem
+	fcmRequests []client.FCMSendRequest
+}
+
+func NewFakeSites() *FakeSites {
+	fs := &FakeSites{shopeeItems: make(map[[2]string]FakeShopeeItem)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/item/get", fs.shopeeItemGet)
+	mux.HandleFunc("/api/v4/shop/get_shop_detail", fs.shopeeShopDetail)
+	mux.HandleFunc("/fcm/send", fs.fcmSend)
+	fs.server = httptest.NewTLSServer(mux)
+	return fs
+}
+
+func (fs *FakeSites) Close() {
+	fs.server.Close()
+}
+
+// HTTPClient returns a client that sends the requests to fakeHosts to FakeSites.
+func (fs *FakeSites) HTTPClient() *http.Client {
+	c := fs.server.Client()
+	target, _ := url.Parse(fs.server.URL)
+	c.Transport = rewriteTransport{target: target, base: c.Transport}
+	return c
+}
+
+func (fs *FakeSites) SetShopeeItem(shopID string, itemID string, i FakeShopeeItem) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.shopeeItems[[2]string{shopID, itemID}] = i
+}
+
+func (fs *FakeSites) FCMRequests() []client.FCMSendRequest {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]client.FCMSendRequest(nil), fs.fcmRequests...)
+}
+
+func (fs *FakeSites) shopeeItemGet(w http.ResponseWriter, r *http.Request) {
+	shopID, itemID := r.URL.Query().Get("shopid"), r.URL.Query().Get("itemid")
+	fs.mu.Lock()
+	i, ok := fs.shopeeItems[[2]string{shopID, itemID}]
+	fs.mu.Unlock()
+	if !ok {
+		writeJSON(w, map[string]any{"error": 4})
+		return
+	}
+	sid, _ := strconv.Atoi(shopID)
+	iid, _ := strconv.Atoi(itemID)
+	writeJSON(w, map[string]any{
+		"error": 0,
+		"data": map[string]any{
+			"shopid": sid,
+			"itemid": iid,
+			"name":   i.Name,
+			// Shopee prices are in 1/100000 rupiah.
+			"price": i.Price * 100000,
+			"stock": i.Stock,
+			"image": "e2e",
+		},
+	})
+}
+
+func (fs *FakeSites) shopeeShopDetail(w http.ResponseWriter, r *http.Request) {
+	sid, _ := strconv.Atoi(r.URL.Query().Get("shopid"))
+	writeJSON(w, map[string]any{
+		"error": 0,
+		"data":  map[string]any{"shopid": sid, "name": "E2E Shop"},
+	})
+}
+
+func (fs *FakeSites) fcmSend(w http.ResponseWriter, r *http.Request) {
+	var req client.FCMSendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fs.mu.Lock()
+	fs.fcmRequests = append(fs.fcmRequests, req)
+	fs.mu.Unlock()
+	results := make([]map[string]any, len(req.RegistrationIDs))
+	for idx := range results {
+		results[idx] = map[string]any{"message_id": strconv.Itoa(idx)}
+	}
+	writeJSON(w, map[string]any{"success": len(req.RegistrationIDs), "failure": 0, "results": results})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (rt rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if fakeHosts[r.URL.Hostname()] {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = rt.target.Scheme
+		r.URL.Host = rt.target.Host
+	}
+	return rt.base.RoundTrip(r)
+}
@@ -0,0 +1,200 @@
+//go:build e2e
+
+// Package e2e tests the real router against MongoDB and Redis in Docker containers and fake marketplace and FCM APIs,
+// so flows across the server, database and client packages are exercised end to end.
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/client"
+	"pricetracker/internal/database"
+	"pricetracker/internal/logger"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+	"pricetracker/internal/service"
+	"time"
+)
+
+const (
+	mongoImage = "mongo:6"
+	redisImage = "redis:7"
+)
+
+type Harness struct {
+	Server    server.Server
+	Fake      *FakeSites
+	AdminKey  string
+	BaseURL   string
+	APIClient *http.Client
+
+	mongo     container
+	redis     container
+	dbConn    *mongo.Client
+	apiServer *httptest.Server
+}
+
+// Start starts MongoDB and Redis containers and serves the router with every external API faked,
+// Close must be called to remove the containers.
+func Start(ctx context.Context, logOutput io.Writer, logLevel logger.Level) (_ *Harness, err error) {
+	h := &Harness{Fake: NewFakeSites()}
+	defer func() {
+		if err != nil {
+			h.Close()
+		}
+	}()
+
+	if h.mongo, err = startContainer(ctx, mongoImage, "27017"); err != nil {
+		return nil, err
+	}
+	if h.redis, err = startContainer(ctx, redisImage, "6379"); err != nil {
+		return nil, err
+	}
+
+	if h.dbConn, err = database.ConnectDB(ctx, "mongodb://"+h.mongo.addr, nil); err != nil {
+		return nil, errors.Wrap(err, "error connecting to MongoDB container")
+	}
+	db := database.Database{Database: h.dbConn.Database(database.Name)}
+	if err = db.EnsureIndexes(ctx); err != nil {
+		return nil, errors.Wrap(err, "error ensuring indexes")
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: h.redis.addr})
+	if err = redisClient.Ping(ctx).Err(); err != nil {
+		return nil, errors.Wrap(err, "error pinging Redis container")
+	}
+
+	authSecretKey, err := jwk.FromRaw([]byte(randomHex(32)))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating auth secret key")
+	}
+	h.AdminKey = randomHex(32)
+	appLogger := logger.New(logLevel, logOutput)
+	h.Server = server.Server{
+		DB:    db,
+		Redis: redisClient,
+		Cache: client.RedisCache{Redis: redisClient},
+		Client: client.Client{
+			Client: h.Fake.HTTPClient(),
+			FCMKey: "e2e",
+			Logger: appLogger,
+		},
+		Logger:        appLogger,
+		AuthSecretKey: authSecretKey,
+		AdminAPIKey:   h.AdminKey,
+		StartedAt:     time.Now(),
+
+		ReferralRewardTrackedItems: 20,
+		PremiumDurationDays:        30,
+	}
+	h.Server.Scrapers = service.NewScrapers(h.Server.Client)
+	h.Server.Items = service.NewItemService(db, h.Server.Scrapers, appLogger)
+	h.Server.Users = service.NewUserService(db, appLogger)
+	h.Server.Notifications = service.NewNotificationService(db, h.Server.Client, appLogger)
+	h.apiServer = httptest.NewServer(h.Server.Router())
+	h.BaseURL = h.apiServer.URL
+	h.APIClient = h.apiServer.Client()
+	return h, nil
+}
+
+func (h *Harness) Close() {
+	if h.apiServer != nil {
+		h.apiServer.Close()
+	}
+	if h.dbConn != nil {
+		_ = h.dbConn.Disconnect(context.Background())
+	}
+	if h.Server.Redis != nil {
+		_ = h.Server.Redis.Close()
+	}
+	h.mongo.stop()
+	h.redis.stop()
+	h.Fake.Close()
+}
+
+// Seed inserts the fixtures the scenarios rely on.
+func (h *Harness) Seed(ctx context.Context) error {
+	_, _, err := h.Server.DB.BarcodesUpsert(ctx, []model.Barcode{{
+		BarcodeNumber: "8990000000001",
+		ProductName:   "E2E Product",
+		Query1:        "e2e product",
+		Source:        "e2e",
+	}})
+	return errors.Wrap(err, "error seeding Barcodes")
+}
+
+// Do sends a JSON request to the router and decodes the JSON response into out when out is not nil,
+// it returns an error when the response status is not wantStatus.
+func (h *Harness) Do(ctx context.Context, method string, path string, header http.Header, in any, out any, wantStatus int) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return errors.Wrapf(err, "error marshalling request body for %s %s", method, path)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.BaseURL+path, body)
+	if err != nil {
+		return errors.Wrapf(err, "error creating request for %s %s", method, path)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.APIClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "error doing request %s %s", method, path)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "error reading response body of %s %s", method, path)
+	}
+	if resp.StatusCode != wantStatus {
+		return errors.Errorf("%s %s: got status %d, want %d, body: %s", method, path, resp.StatusCode, wantStatus, respBody)
+	}
+	if out != nil {
+		if err = json.Unmarshal(respBody, out); err != nil {
+			return errors.Wrapf(err, "error unmarshalling response body of %s %s: %s", method, path, respBody)
+		}
+	}
+	return nil
+}
+
+// waitFetchCycle waits until the FetchCycle with id is finished.
+func waitFetchCycle(ctx context.Context, h *Harness, adminHeader http.Header, id string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		var fc struct {
+			FinishedAt time.Time `json:"finished_at"`
+		}
+		if err := h.Do(ctx, http.MethodGet, "/api/admin/fetch/cycles/"+id, adminHeader, nil, &fc, http.StatusOK); err != nil {
+			return err
+		}
+		if fc.FinishedAt.After(time.Unix(0, 0)) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return errors.Errorf("timed out waiting for FetchCycle with ID: %s", id)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n/2)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestTrackedItemHistory registers a User, logs in on another device, tracks a Shopee Item with the new
+// login token and expects the first ItemHistory of the Item to be returned.
+func TestTrackedItemHistory(t *testing.T) {
+	ctx := scenarioContext(t)
+	const shopID, itemID, price = "1003", "2004", 120000
+	email := fmt.Sprintf("e2e-%s@example.com", randomHex(8))
+	h.Fake.SetShopeeItem(shopID, itemID, FakeShopeeItem{Name: "E2E History Item", Price: price, Stock: 3})
+
+	if err := h.Do(ctx, http.MethodPost, "/api/user/register", nil, map[string]string{
+		"name":      "E2E User",
+		"email":     email,
+		"password":  "e2e-password",
+		"device_id": "e2e-device",
+	}, nil, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+	var loggedIn struct {
+		LoginToken string `json:"login_token"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/user/login", nil, map[string]string{
+		"email":     email,
+		"password":  "e2e-password",
+		"device_id": "e2e-device-2",
+	}, &loggedIn, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	userHeader := http.Header{"Authorization": {"Bearer " + loggedIn.LoginToken}}
+
+	var added struct {
+		ItemID string `json:"item_id"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/item/add", userHeader, map[string]any{
+		"url": fmt.Sprintf("https://shopee.co.id/product/%s/%s", shopID, itemID),
+	}, &added, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	var history []struct {
+		Price int `json:"pr"`
+	}
+	start := url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339))
+	if err := h.Do(ctx, http.MethodGet, "/api/item/history/"+added.ItemID+"?start="+start, userHeader, nil,
+		&history, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 || history[0].Price != price {
+		t.Errorf("unexpected history of ItemID: %s, got: %+v, want price: %d", added.ItemID, history, price)
+	}
+}
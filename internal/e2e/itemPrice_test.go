@@ -0,0 +1,36 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/database"
+	"testing"
+)
+
+// TestItemPriceUpdateWithoutPriceLastChangedAt updates the price of an Item stored without price_last_changed_at
+// and expects its PriceVolatility to be fully decayed before the price change is added.
+func TestItemPriceUpdateWithoutPriceLastChangedAt(t *testing.T) {
+	ctx := scenarioContext(t)
+	db := h.Server.DB.(database.Database)
+	itemID := primitive.NewObjectID()
+	if _, err := db.Collection(database.CollectionItems).InsertOne(ctx, bson.M{
+		"_id":              itemID,
+		"site":             "shopee",
+		"name":             "E2E Legacy Item",
+		"price":            100000,
+		"price_volatility": 3.0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := db.ItemPriceUpdate(ctx, itemID, 90000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Price != 90000 || i.PriceVolatility != 1 || i.PriceLastChangedAt == 0 {
+		t.Errorf("got price %d, PriceVolatility %v and PriceLastChangedAt %v, want 90000, 1 and the update time",
+			i.Price, i.PriceVolatility, i.PriceLastChangedAt)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"pricetracker/internal/logger"
+	"testing"
+	"time"
+)
+
+// scenarioTimeout is the timeout of each scenario.
+const scenarioTimeout = 2 * time.Minute
+
+// h is the harness shared by every test, started by TestMain.
+var h *Harness
+
+// TestMain starts the harness and seeds its fixtures once for all tests, the application logs are printed with -v.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	ctx := context.Background()
+	logLevel := logger.LevelOff
+	if testing.Verbose() {
+		logLevel = logger.LevelDebug
+	}
+	var err error
+	if h, err = Start(ctx, os.Stderr, logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, "Error starting harness:", err)
+		return 1
+	}
+	defer h.Close()
+	if err = h.Seed(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error seeding fixtures:", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// scenarioContext returns the context of a test, it times out after scenarioTimeout and is canceled when the test ends.
+func scenarioContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), scenarioTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,66 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestPriceDrop registers a User, tracks a Shopee Item, lowers its price on the fake site,
+// refetches it as an admin and expects an FCM notification to the User's device.
+func TestPriceDrop(t *testing.T) {
+	ctx := scenarioContext(t)
+	const shopID, itemID = "1001", "2002"
+	fcmToken := "e2e-fcm-" + randomHex(8)
+	h.Fake.SetShopeeItem(shopID, itemID, FakeShopeeItem{Name: "E2E Item", Price: 100000, Stock: 5})
+
+	var registered struct {
+		LoginToken string `json:"login_token"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/user/register", nil, map[string]string{
+		"name":      "E2E User",
+		"email":     fmt.Sprintf("e2e-%s@example.com", randomHex(8)),
+		"password":  "e2e-password",
+		"device_id": "e2e-device",
+		"fcm_token": fcmToken,
+	}, &registered, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+	userHeader := http.Header{"Authorization": {"Bearer " + registered.LoginToken}}
+
+	var added struct {
+		ItemID string `json:"item_id"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/item/add", userHeader, map[string]any{
+		"url":                   fmt.Sprintf("https://shopee.co.id/product/%s/%s", shopID, itemID),
+		"price_lower_threshold": 90000,
+		"notification_enabled":  true,
+	}, &added, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Fake.SetShopeeItem(shopID, itemID, FakeShopeeItem{Name: "E2E Item", Price: 85000, Stock: 5})
+	adminHeader := http.Header{"X-Admin-Key": {h.AdminKey}}
+	var refetch struct {
+		FetchCycleID string `json:"fetch_cycle_id"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/admin/fetch/refetch", adminHeader,
+		map[string]string{"site": "Shopee", "merchant_id": shopID}, &refetch, http.StatusAccepted); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitFetchCycle(ctx, h, adminHeader, refetch.FetchCycleID, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fcmReq := range h.Fake.FCMRequests() {
+		for _, token := range fcmReq.RegistrationIDs {
+			if token == fcmToken && fcmReq.Data.ItemID == added.ItemID {
+				return
+			}
+		}
+	}
+	t.Errorf("no FCM notification sent to token %s for ItemID: %s", fcmToken, added.ItemID)
+}
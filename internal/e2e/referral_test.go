@@ -0,0 +1,77 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestReferralRewardCap registers Users with the referral code of a referrer, expects the referrer to be rewarded only
+// once each of them tracks an Item, and the tracked items bonus to stop at its cap.
+func TestReferralRewardCap(t *testing.T) {
+	ctx := scenarioContext(t)
+	referrerHeader := registerE2EUser(ctx, t, "")
+	var code struct {
+		Code string `json:"code"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/user/referral/code", referrerHeader, nil, &code, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	type referral struct {
+		Count             int `json:"count"`
+		TrackedItemsBonus int `json:"tracked_items_bonus"`
+	}
+	const referred = 3
+	for idx := 0; idx < referred; idx++ {
+		userHeader := registerE2EUser(ctx, t, code.Code)
+		if idx == 0 {
+			var got referral
+			if err := h.Do(ctx, http.MethodGet, "/api/user/referral", referrerHeader, nil, &got, http.StatusOK); err != nil {
+				t.Fatal(err)
+			}
+			if got.Count != 0 {
+				t.Errorf("got referral count %d before the referred User tracked an Item, want 0", got.Count)
+			}
+		}
+
+		shopID, itemID := "3001", fmt.Sprintf("%d", 4000+idx)
+		h.Fake.SetShopeeItem(shopID, itemID, FakeShopeeItem{Name: "E2E Referral Item", Price: 50000, Stock: 5})
+		if err := h.Do(ctx, http.MethodPost, "/api/item/add", userHeader, map[string]any{
+			"url": fmt.Sprintf("https://shopee.co.id/product/%s/%s", shopID, itemID),
+		}, nil, http.StatusOK); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got referral
+	if err := h.Do(ctx, http.MethodGet, "/api/user/referral", referrerHeader, nil, &got, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	// Every referral is worth 20 tracked items in the harness, the bonus is capped at 50.
+	if got.Count != referred || got.TrackedItemsBonus != 50 {
+		t.Errorf("got referral count %d and tracked items bonus %d, want %d and 50", got.Count, got.TrackedItemsBonus, referred)
+	}
+}
+
+// registerE2EUser registers a User with referralCode, which may be empty, and returns its authorization header.
+func registerE2EUser(ctx context.Context, t *testing.T, referralCode string) http.Header {
+	t.Helper()
+	var registered struct {
+		LoginToken string `json:"login_token"`
+	}
+	if err := h.Do(ctx, http.MethodPost, "/api/user/register", nil, map[string]string{
+		"name":          "E2E User",
+		"email":         fmt.Sprintf("e2e-%s@example.com", randomHex(8)),
+		"password":      "e2e-password",
+		"device_id":     "e2e-device",
+		"fcm_token":     "e2e-fcm-" + randomHex(8),
+		"referral_code": referralCode,
+	}, &registered, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+	return http.Header{"Authorization": {"Bearer " + registered.LoginToken}}
+}
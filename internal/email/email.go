@@ -0,0 +1,71 @@
+// Package email renders the HTML emails sent to Users from the templates in templates/,
+// the texts of the templates are taken from i18n in the locale of the User.
+package email
+
+import (
+	"bytes"
+	"embed"
+	"github.com/pkg/errors"
+	"html/template"
+	"pricetracker/internal/i18n"
+	"pricetracker/internal/model"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// templates are parsed with a placeholder t function, render replaces it with one bound to the locale of the email.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"t": func(key string, args ...any) string { return key },
+}).ParseFS(templateFS, "templates/*.html"))
+
+type DigestEntry struct {
+	Name     string
+	URL      string
+	ImageURL string
+	// OldPrice is the formatted price at the start of the digest period, NewPrice the current one.
+	OldPrice string
+	NewPrice string
+	Dropped  bool
+}
+
+type Digest struct {
+	Locale    string
+	UserName  string
+	Frequency string
+	Since     time.Time
+	Entries   []DigestEntry
+}
+
+// RenderDigest returns the subject and HTML body of the price digest email d.
+func RenderDigest(d Digest) (string, string, error) {
+	d.Locale = i18n.Normalize(d.Locale)
+	subject := i18n.T(d.Locale, i18n.DigestDailySubject)
+	if d.Frequency == model.DigestWeekly {
+		subject = i18n.T(d.Locale, i18n.DigestWeeklySubject)
+	}
+	body, err := render("digest.html", d.Locale, struct {
+		Digest
+		Subject string
+	}{d, subject})
+	if err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func render(name string, locale string, data any) (string, error) {
+	tmpl, err := templates.Clone()
+	if err != nil {
+		return "", errors.Wrapf(err, "error cloning templates to render: %s", name)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"t": func(key string, args ...any) string { return i18n.T(locale, key, args...) },
+	})
+	var buf bytes.Buffer
+	if err = tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", errors.Wrapf(err, "error rendering email template: %s", name)
+	}
+	return buf.String(), nil
+}
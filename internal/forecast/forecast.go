@@ -0,0 +1,124 @@
+// Package forecast estimates the price range of an Item over the coming days from its daily price history:
+// the linear trend fitted to the daily average prices is projected from their recent moving average, and the range
+// around it is the spread of the prices around the trend.
+package forecast
+
+import (
+	"math"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// MinDays is the least number of days of price history a forecast is made from.
+const MinDays = 7
+
+// movingAverageDays is how many of the latest days the baseline of the projection is averaged over.
+const movingAverageDays = 7
+
+// rangeZ is the z-score of the price range, about 80% of the prices around the trend fall within it.
+const rangeZ = 1.28
+
+// trendThreshold is the relative change over the horizon below which the trend is flat.
+const trendThreshold = 0.02
+
+const (
+	TrendDown = "down"
+	TrendFlat = "flat"
+	TrendUp   = "up"
+)
+
+// Range is the expected price and price range in the minor unit of the Item currency Days after the forecast.
+type Range struct {
+	Days     int    `json:"days"`
+	Expected int    `json:"expected"`
+	Low      int    `json:"low"`
+	High     int    `json:"high"`
+	Trend    string `json:"trend"`
+	// LikelyToDrop is set when the expected price is lower than the current price by more than the flat trend.
+	LikelyToDrop bool `json:"likely_to_drop"`
+}
+
+// Forecast returns the price Range of each of the horizons in days after the last bucket of ihbs, which must be
+// daily buckets sorted by ascending start, current is the current price of the Item. ok is false when ihbs has
+// fewer than MinDays buckets with prices.
+func Forecast(ihbs []model.ItemHistoryBucket, current int, horizons ...int) (rs []Range, ok bool) {
+	var xs, ys []float64
+	var first time.Time
+	for _, b := range ihbs {
+		if b.Count == 0 {
+			continue
+		}
+		if first.IsZero() {
+			first = b.Start.Time()
+		}
+		xs = append(xs, b.Start.Time().Sub(first).Hours()/24)
+		ys = append(ys, b.PriceAvg)
+	}
+	n := len(xs)
+	if n < MinDays {
+		return nil, false
+	}
+
+	slope, intercept := linearFit(xs, ys)
+	var sse float64
+	for idx := range xs {
+		r := ys[idx] - (intercept + slope*xs[idx])
+		sse += r * r
+	}
+	sd := math.Sqrt(sse / float64(n-2))
+
+	// The baseline is the moving average of the latest days placed at their average day.
+	var baseline, baselineX float64
+	window := xs[n-movingAverageDays:]
+	for idx, x := range window {
+		baseline += ys[n-movingAverageDays+idx]
+		baselineX += x
+	}
+	baseline /= float64(len(window))
+	baselineX /= float64(len(window))
+	lastX := xs[n-1]
+
+	for _, days := range horizons {
+		expected := math.Max(0, baseline+slope*(lastX+float64(days)-baselineX))
+		spread := rangeZ * sd * math.Sqrt(1+float64(days)/float64(n))
+		r := Range{
+			Days:     days,
+			Expected: int(math.Round(expected)),
+			Low:      int(math.Round(math.Max(0, expected-spread))),
+			High:     int(math.Round(expected + spread)),
+			Trend:    TrendFlat,
+		}
+		if baseline > 0 {
+			switch change := slope * float64(days) / baseline; {
+			case change < -trendThreshold:
+				r.Trend = TrendDown
+			case change > trendThreshold:
+				r.Trend = TrendUp
+			}
+		}
+		r.LikelyToDrop = expected < float64(current)*(1-trendThreshold)
+		rs = append(rs, r)
+	}
+	return rs, true
+}
+
+// linearFit returns the slope and intercept of the least squares line through the points of xs and ys.
+func linearFit(xs []float64, ys []float64) (float64, float64) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for idx := range xs {
+		sumX += xs[idx]
+		sumY += ys[idx]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for idx := range xs {
+		cov += (xs[idx] - meanX) * (ys[idx] - meanY)
+		variance += (xs[idx] - meanX) * (xs[idx] - meanX)
+	}
+	if variance == 0 {
+		return 0, meanY
+	}
+	slope := cov / variance
+	return slope, meanY - slope*meanX
+}
@@ -0,0 +1,130 @@
+// Package i18n holds the localized texts sent to Users, e.g. notification titles and bodies.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	LocaleEnglish    = "en"
+	LocaleIndonesian = "id"
+	// DefaultLocale is the locale of Users who have not chosen one.
+	DefaultLocale = LocaleEnglish
+)
+
+// Message keys, the texts are fmt formats whose arguments are listed with each key.
+const (
+	// PriceDropTitle has no arguments.
+	PriceDropTitle = "price_drop.title"
+	// PriceDropBody has the Item name and its formatted price as arguments.
+	PriceDropBody = "price_drop.body"
+	// PriceDropBatchTitle has the number of Items as argument.
+	PriceDropBatchTitle = "price_drop_batch.title"
+	// RestockTitle has no arguments.
+	RestockTitle = "restock.title"
+	// RestockBody has the Item name and its formatted price as arguments.
+	RestockBody = "restock.body"
+	// RatingDropTitle has no arguments.
+	RatingDropTitle = "rating_drop.title"
+	// RatingDropBody has the Item name and its rating as arguments.
+	RatingDropBody = "rating_drop.body"
+	// SoldJumpTitle has no arguments.
+	SoldJumpTitle = "sold_jump.title"
+	// SoldJumpBody has the Item name and the number sold since the previous fetch as arguments.
+	SoldJumpBody = "sold_jump.body"
+	// DelistedTitle has no arguments.
+	DelistedTitle = "delisted.title"
+	// DelistedBody has the Item name as argument.
+	DelistedBody = "delisted.body"
+	// DelistedReplacementBody has the Item name and the formatted price of the replacement as arguments.
+	DelistedReplacementBody = "delisted.replacement_body"
+	// QuietHoursDigestTitle has the number of notifications as argument.
+	QuietHoursDigestTitle = "quiet_hours_digest.title"
+	// ListMore has the number of entries left out of a list as argument.
+	ListMore = "list.more"
+	// DigestDailySubject has no arguments.
+	DigestDailySubject = "digest.daily_subject"
+	// DigestWeeklySubject has no arguments.
+	DigestWeeklySubject = "digest.weekly_subject"
+	// DigestGreeting has the User name as argument.
+	DigestGreeting = "digest.greeting"
+	// DigestIntro has the formatted start date of the digest period as argument.
+	DigestIntro = "digest.intro"
+	// DigestFooter has no arguments.
+	DigestFooter = "digest.footer"
+)
+
+var messages = map[string]map[string]string{
+	LocaleEnglish: {
+		PriceDropTitle:          "The price of an item has dropped!",
+		PriceDropBody:           "%s is now %s",
+		PriceDropBatchTitle:     "The prices of %d items have dropped!",
+		RestockTitle:            "An item you tracked is back in stock!",
+		RestockBody:             "%s is back in stock for %s",
+		RatingDropTitle:         "The rating of an item you tracked has dropped",
+		RatingDropBody:          "%s is now rated %.1f",
+		SoldJumpTitle:           "An item you tracked is selling fast!",
+		SoldJumpBody:            "%s sold %d more since the last check",
+		DelistedTitle:           "An item you tracked is no longer available",
+		DelistedBody:            "%s has been delisted",
+		DelistedReplacementBody: "%s has been delisted, a replacement is available for %s",
+		QuietHoursDigestTitle:   "%d updates on items you tracked",
+		ListMore:                "and %d more",
+		DigestDailySubject:      "Your daily price digest",
+		DigestWeeklySubject:     "Your weekly price digest",
+		DigestGreeting:          "Hi %s,",
+		DigestIntro:             "The prices of these items you tracked have changed since %s:",
+		DigestFooter:            "You can turn off price digests in the notification settings of the app.",
+	},
+	LocaleIndonesian: {
+		PriceDropTitle:          "Harga barang turun!",
+		PriceDropBody:           "%s sekarang %s",
+		PriceDropBatchTitle:     "Harga %d barang turun!",
+		RestockTitle:            "Barang yang kamu lacak tersedia kembali!",
+		RestockBody:             "%s tersedia kembali seharga %s",
+		RatingDropTitle:         "Rating barang yang kamu lacak turun",
+		RatingDropBody:          "Rating %s sekarang %.1f",
+		SoldJumpTitle:           "Barang yang kamu lacak laris!",
+		SoldJumpBody:            "%s terjual %d lagi sejak pengecekan terakhir",
+		DelistedTitle:           "Barang yang kamu lacak sudah tidak tersedia",
+		DelistedBody:            "%s sudah tidak dijual",
+		DelistedReplacementBody: "%s sudah tidak dijual, penggantinya tersedia seharga %s",
+		QuietHoursDigestTitle:   "%d pembaruan untuk barang yang kamu lacak",
+		ListMore:                "dan %d lainnya",
+		DigestDailySubject:      "Ringkasan harga harianmu",
+		DigestWeeklySubject:     "Ringkasan harga mingguanmu",
+		DigestGreeting:          "Hai %s,",
+		DigestIntro:             "Harga barang yang kamu lacak ini berubah sejak %s:",
+		DigestFooter:            "Kamu bisa mematikan ringkasan harga di pengaturan notifikasi aplikasi.",
+	},
+}
+
+// Supported reports whether locale, e.g. id or id-ID, is one of the supported locales.
+func Supported(locale string) bool {
+	_, ok := messages[language(locale)]
+	return ok
+}
+
+// Normalize returns the supported locale of locale, or DefaultLocale when it is not supported.
+func Normalize(locale string) string {
+	if l := language(locale); Supported(l) {
+		return l
+	}
+	return DefaultLocale
+}
+
+// T returns the text of key in locale formatted with args, falling back to the DefaultLocale text.
+func T(locale string, key string, args ...any) string {
+	text, ok := messages[Normalize(locale)][key]
+	if !ok {
+		text = messages[DefaultLocale][key]
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// language returns the lower-cased language of locale, e.g. id for id-ID.
+func language(locale string) string {
+	l, _, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	return strings.ToLower(strings.TrimSpace(l))
+}
@@ -7,28 +7,38 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type logger struct {
 	logger *log.Logger
-	level  Level
+	level  int32
+}
+
+func (l *logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+// SetLevel changes the level of l, it is safe to call while l is in use.
+func (l *logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.level, int32(level))
 }
 
 func (l *logger) ErrorEnabled() bool {
-	return l.level >= LevelError
+	return l.Level() >= LevelError
 }
 func (l *logger) WarnEnabled() bool {
-	return l.level >= LevelWarn
+	return l.Level() >= LevelWarn
 }
 func (l *logger) InfoEnabled() bool {
-	return l.level >= LevelInfo
+	return l.Level() >= LevelInfo
 }
 func (l *logger) DebugEnabled() bool {
-	return l.level >= LevelDebug
+	return l.Level() >= LevelDebug
 }
 func (l *logger) TraceEnabled() bool {
-	return l.level >= LevelTrace
+	return l.Level() >= LevelTrace
 }
 
 func (l *logger) Error(v ...any) {
@@ -93,7 +103,7 @@ func (l *logger) outputf(level Level, format string, v ...any) {
 func New(level Level, output io.Writer) *logger {
 	return &logger{
 		logger: log.New(output, "", 0),
-		level:  level,
+		level:  int32(level),
 	}
 }
 
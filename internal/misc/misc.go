@@ -20,6 +20,15 @@ func Min[T constraints.Ordered](a, b T) T {
 	return b
 }
 
+func Contains[T comparable](s []T, v T) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
 func StringLimit(s string, n int) string {
 	if n < 0 {
 		return ""
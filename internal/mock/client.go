@@ -0,0 +1,256 @@
+// Code generated by genmock pricetracker/internal/server.Client. DO NOT EDIT.
+
+package mock
+
+import (
+	"context"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+)
+
+var _ server.Client = (*Client)(nil)
+
+// Client is a mock of server.Client, its methods call the func field of the same name.
+type Client struct {
+	BarcodeLookupFunc              func(ctx context.Context, barcode string) (model.Barcode, error)
+	BlibliGetItemFunc              func(ctx context.Context, url string) (model.Item, error)
+	BlibliGetItemVariantsFunc      func(ctx context.Context, url string) ([]model.ItemVariant, error)
+	BlibliSearchFunc               func(ctx context.Context, query string) ([]model.Item, error)
+	CacheInvalidateItemFunc        func(site string, url string) error
+	EbayEnabledFunc                func() bool
+	EbayGetItemFunc                func(ctx context.Context, url string) (model.Item, error)
+	EbayHostFunc                   func() string
+	EbaySearchFunc                 func(ctx context.Context, query string) ([]model.Item, error)
+	FCMSendNotificationFunc        func(ctx context.Context, fcmReqBody client.FCMSendRequest) (client.FCMSendResponse, error)
+	GoogleEnabledFunc              func() bool
+	GoogleVerifyIDTokenFunc        func(ctx context.Context, idToken string) (client.GoogleIDTokenClaims, error)
+	MidtransEnabledFunc            func() bool
+	MidtransVerifyNotificationFunc func(n client.MidtransNotification) bool
+	SMTPEnabledFunc                func() bool
+	SMTPSendMailFunc               func(ctx context.Context, to string, subject string, htmlBody string) error
+	ShopeeGetItemFunc              func(ctx context.Context, url string) (model.Item, error)
+	ShopeeGetMerchantFunc          func(ctx context.Context, shopID string) (model.MerchantHistory, error)
+	ShopeeSearchFunc               func(ctx context.Context, query string) ([]model.Item, error)
+	ShopeeSearchByImageFunc        func(ctx context.Context, image []byte) ([]model.Item, error)
+	SiteFingerprintsFunc           func() map[string]client.SiteFingerprint
+	SiteFingerprintsReloadFunc     func() (bool, error)
+	TelegramEnabledFunc            func() bool
+	TelegramSendMessageFunc        func(ctx context.Context, chatID int64, text string) error
+	TelegramWebhookSecretFunc      func() string
+	TokopediaGetItemFunc           func(ctx context.Context, url string) (model.Item, error)
+	TokopediaSearchFunc            func(ctx context.Context, query string) ([]model.Item, error)
+	VisionEnabledFunc              func() bool
+	VisionQueryFunc                func(ctx context.Context, image []byte, contentType string) (string, error)
+	WebhookSendFunc                func(ctx context.Context, webhookURL string, secret string, payload client.WebhookPayload) error
+}
+
+func (m *Client) BarcodeLookup(ctx context.Context, barcode string) (model.Barcode, error) {
+	if m.BarcodeLookupFunc == nil {
+		panic("Client.BarcodeLookup called without BarcodeLookupFunc")
+	}
+	return m.BarcodeLookupFunc(ctx, barcode)
+}
+
+func (m *Client) BlibliGetItem(ctx context.Context, url string) (model.Item, error) {
+	if m.BlibliGetItemFunc == nil {
+		panic("Client.BlibliGetItem called without BlibliGetItemFunc")
+	}
+	return m.BlibliGetItemFunc(ctx, url)
+}
+
+func (m *Client) BlibliGetItemVariants(ctx context.Context, url string) ([]model.ItemVariant, error) {
+	if m.BlibliGetItemVariantsFunc == nil {
+		panic("Client.BlibliGetItemVariants called without BlibliGetItemVariantsFunc")
+	}
+	return m.BlibliGetItemVariantsFunc(ctx, url)
+}
+
+func (m *Client) BlibliSearch(ctx context.Context, query string) ([]model.Item, error) {
+	if m.BlibliSearchFunc == nil {
+		panic("Client.BlibliSearch called without BlibliSearchFunc")
+	}
+	return m.BlibliSearchFunc(ctx, query)
+}
+
+func (m *Client) CacheInvalidateItem(site string, url string) error {
+	if m.CacheInvalidateItemFunc == nil {
+		panic("Client.CacheInvalidateItem called without CacheInvalidateItemFunc")
+	}
+	return m.CacheInvalidateItemFunc(site, url)
+}
+
+func (m *Client) EbayEnabled() bool {
+	if m.EbayEnabledFunc == nil {
+		panic("Client.EbayEnabled called without EbayEnabledFunc")
+	}
+	return m.EbayEnabledFunc()
+}
+
+func (m *Client) EbayGetItem(ctx context.Context, url string) (model.Item, error) {
+	if m.EbayGetItemFunc == nil {
+		panic("Client.EbayGetItem called without EbayGetItemFunc")
+	}
+	return m.EbayGetItemFunc(ctx, url)
+}
+
+func (m *Client) EbayHost() string {
+	if m.EbayHostFunc == nil {
+		panic("Client.EbayHost called without EbayHostFunc")
+	}
+	return m.EbayHostFunc()
+}
+
+func (m *Client) EbaySearch(ctx context.Context, query string) ([]model.Item, error) {
+	if m.EbaySearchFunc == nil {
+		panic("Client.EbaySearch called without EbaySearchFunc")
+	}
+	return m.EbaySearchFunc(ctx, query)
+}
+
+func (m *Client) FCMSendNotification(ctx context.Context, fcmReqBody client.FCMSendRequest) (client.FCMSendResponse, error) {
+	if m.FCMSendNotificationFunc == nil {
+		panic("Client.FCMSendNotification called without FCMSendNotificationFunc")
+	}
+	return m.FCMSendNotificationFunc(ctx, fcmReqBody)
+}
+
+func (m *Client) GoogleEnabled() bool {
+	if m.GoogleEnabledFunc == nil {
+		panic("Client.GoogleEnabled called without GoogleEnabledFunc")
+	}
+	return m.GoogleEnabledFunc()
+}
+
+func (m *Client) GoogleVerifyIDToken(ctx context.Context, idToken string) (client.GoogleIDTokenClaims, error) {
+	if m.GoogleVerifyIDTokenFunc == nil {
+		panic("Client.GoogleVerifyIDToken called without GoogleVerifyIDTokenFunc")
+	}
+	return m.GoogleVerifyIDTokenFunc(ctx, idToken)
+}
+
+func (m *Client) MidtransEnabled() bool {
+	if m.MidtransEnabledFunc == nil {
+		panic("Client.MidtransEnabled called without MidtransEnabledFunc")
+	}
+	return m.MidtransEnabledFunc()
+}
+
+func (m *Client) MidtransVerifyNotification(n client.MidtransNotification) bool {
+	if m.MidtransVerifyNotificationFunc == nil {
+		panic("Client.MidtransVerifyNotification called without MidtransVerifyNotificationFunc")
+	}
+	return m.MidtransVerifyNotificationFunc(n)
+}
+
+func (m *Client) SMTPEnabled() bool {
+	if m.SMTPEnabledFunc == nil {
+		panic("Client.SMTPEnabled called without SMTPEnabledFunc")
+	}
+	return m.SMTPEnabledFunc()
+}
+
+func (m *Client) SMTPSendMail(ctx context.Context, to string, subject string, htmlBody string) error {
+	if m.SMTPSendMailFunc == nil {
+		panic("Client.SMTPSendMail called without SMTPSendMailFunc")
+	}
+	return m.SMTPSendMailFunc(ctx, to, subject, htmlBody)
+}
+
+func (m *Client) ShopeeGetItem(ctx context.Context, url string) (model.Item, error) {
+	if m.ShopeeGetItemFunc == nil {
+		panic("Client.ShopeeGetItem called without ShopeeGetItemFunc")
+	}
+	return m.ShopeeGetItemFunc(ctx, url)
+}
+
+func (m *Client) ShopeeGetMerchant(ctx context.Context, shopID string) (model.MerchantHistory, error) {
+	if m.ShopeeGetMerchantFunc == nil {
+		panic("Client.ShopeeGetMerchant called without ShopeeGetMerchantFunc")
+	}
+	return m.ShopeeGetMerchantFunc(ctx, shopID)
+}
+
+func (m *Client) ShopeeSearch(ctx context.Context, query string) ([]model.Item, error) {
+	if m.ShopeeSearchFunc == nil {
+		panic("Client.ShopeeSearch called without ShopeeSearchFunc")
+	}
+	return m.ShopeeSearchFunc(ctx, query)
+}
+
+func (m *Client) ShopeeSearchByImage(ctx context.Context, image []byte) ([]model.Item, error) {
+	if m.ShopeeSearchByImageFunc == nil {
+		panic("Client.ShopeeSearchByImage called without ShopeeSearchByImageFunc")
+	}
+	return m.ShopeeSearchByImageFunc(ctx, image)
+}
+
+func (m *Client) SiteFingerprints() map[string]client.SiteFingerprint {
+	if m.SiteFingerprintsFunc == nil {
+		panic("Client.SiteFingerprints called without SiteFingerprintsFunc")
+	}
+	return m.SiteFingerprintsFunc()
+}
+
+func (m *Client) SiteFingerprintsReload() (bool, error) {
+	if m.SiteFingerprintsReloadFunc == nil {
+		panic("Client.SiteFingerprintsReload called without SiteFingerprintsReloadFunc")
+	}
+	return m.SiteFingerprintsReloadFunc()
+}
+
+func (m *Client) TelegramEnabled() bool {
+	if m.TelegramEnabledFunc == nil {
+		panic("Client.TelegramEnabled called without TelegramEnabledFunc")
+	}
+	return m.TelegramEnabledFunc()
+}
+
+func (m *Client) TelegramSendMessage(ctx context.Context, chatID int64, text string) error {
+	if m.TelegramSendMessageFunc == nil {
+		panic("Client.TelegramSendMessage called without TelegramSendMessageFunc")
+	}
+	return m.TelegramSendMessageFunc(ctx, chatID, text)
+}
+
+func (m *Client) TelegramWebhookSecret() string {
+	if m.TelegramWebhookSecretFunc == nil {
+		panic("Client.TelegramWebhookSecret called without TelegramWebhookSecretFunc")
+	}
+	return m.TelegramWebhookSecretFunc()
+}
+
+func (m *Client) TokopediaGetItem(ctx context.Context, url string) (model.Item, error) {
+	if m.TokopediaGetItemFunc == nil {
+		panic("Client.TokopediaGetItem called without TokopediaGetItemFunc")
+	}
+	return m.TokopediaGetItemFunc(ctx, url)
+}
+
+func (m *Client) TokopediaSearch(ctx context.Context, query string) ([]model.Item, error) {
+	if m.TokopediaSearchFunc == nil {
+		panic("Client.TokopediaSearch called without TokopediaSearchFunc")
+	}
+	return m.TokopediaSearchFunc(ctx, query)
+}
+
+func (m *Client) VisionEnabled() bool {
+	if m.VisionEnabledFunc == nil {
+		panic("Client.VisionEnabled called without VisionEnabledFunc")
+	}
+	return m.VisionEnabledFunc()
+}
+
+func (m *Client) VisionQuery(ctx context.Context, image []byte, contentType string) (string, error) {
+	if m.VisionQueryFunc == nil {
+		panic("Client.VisionQuery called without VisionQueryFunc")
+	}
+	return m.VisionQueryFunc(ctx, image, contentType)
+}
+
+func (m *Client) WebhookSend(ctx context.Context, webhookURL string, secret string, payload client.WebhookPayload) error {
+	if m.WebhookSendFunc == nil {
+		panic("Client.WebhookSend called without WebhookSendFunc")
+	}
+	return m.WebhookSendFunc(ctx, webhookURL, secret, payload)
+}
@@ -0,0 +1,1004 @@
+// Code generated by genmock pricetracker/internal/server.Database. DO NOT EDIT.
+
+package mock
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"io"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+	"time"
+)
+
+var _ server.Database = (*Database)(nil)
+
+// Database is a mock of server.Database, its methods call the func field of the same name.
+type Database struct {
+	BarcodeDeleteFunc                             func(ctx context.Context, barcodeNumber string) error
+	BarcodeFindFunc                               func(ctx context.Context, barcodeNumber string) (model.Barcode, error)
+	BarcodeInsertFunc                             func(ctx context.Context, b model.Barcode) error
+	BarcodeSubmissionFindFunc                     func(ctx context.Context, id string) (model.BarcodeSubmission, error)
+	BarcodeSubmissionInsertFunc                   func(ctx context.Context, bs model.BarcodeSubmission) (string, error)
+	BarcodeSubmissionReviewFunc                   func(ctx context.Context, id string, status string, reviewedBy string, rejectReason string) error
+	BarcodeSubmissionsFindByStatusFunc            func(ctx context.Context, status string, limit int64) ([]model.BarcodeSubmission, error)
+	BarcodeSubmissionsPendingCountFunc            func(ctx context.Context, userID primitive.ObjectID) (int, error)
+	BarcodeUpdateFunc                             func(ctx context.Context, b model.Barcode) error
+	BarcodesUpsertFunc                            func(ctx context.Context, bs []model.Barcode) (int, int, error)
+	BillingEventDeleteFunc                        func(ctx context.Context, id string) error
+	BillingEventInsertFunc                        func(ctx context.Context, be model.BillingEvent) (string, error)
+	BillingEventUpdateFunc                        func(ctx context.Context, id string, be model.BillingEvent) error
+	BillingEventsFindByOrderFunc                  func(ctx context.Context, provider string, orderID string) ([]model.BillingEvent, error)
+	BillingEventsFindByUserFunc                   func(ctx context.Context, userID string, limit int64) ([]model.BillingEvent, error)
+	EnsureIndexesFunc                             func(ctx context.Context) error
+	FetchCycleFindFunc                            func(ctx context.Context, id string) (model.FetchCycle, error)
+	FetchCycleFindLastFinishedFunc                func(ctx context.Context, kind string) (model.FetchCycle, error)
+	FetchCycleSaveFunc                            func(ctx context.Context, fc model.FetchCycle) error
+	FetchCyclesFindLatestFunc                     func(ctx context.Context, limit int64) ([]model.FetchCycle, error)
+	FetchCyclesFindRunningFunc                    func(ctx context.Context, since time.Time) ([]model.FetchCycle, error)
+	IndexesDriftFunc                              func(ctx context.Context) ([]database.IndexDrift, error)
+	ItemArchiveFunc                               func(ctx context.Context, itemID primitive.ObjectID, reason string, now time.Time) error
+	ItemDuplicatesFindFunc                        func(ctx context.Context, since time.Time, limit int64) ([]database.ItemDuplicates, error)
+	ItemFetchIntervalsFindFunc                    func(ctx context.Context, now time.Time) ([]database.ItemFetchInterval, error)
+	ItemFindExistingFunc                          func(ctx context.Context, i model.Item) (model.Item, error)
+	ItemFindOneFunc                               func(ctx context.Context, itemID string) (model.Item, error)
+	ItemHistoryAggregateFunc                      func(ctx context.Context, itemID string, start time.Time, end time.Time, interval string, loc *time.Location) ([]model.ItemHistoryBucket, error)
+	ItemHistoryFindRangeFunc                      func(ctx context.Context, itemID string, start time.Time, end time.Time, offset int64, limit int64) ([]model.ItemHistory, error)
+	ItemHistoryFirstPricesSinceFunc               func(ctx context.Context, itemIDs []primitive.ObjectID, since time.Time) (map[primitive.ObjectID]int, error)
+	ItemHistoryForEachFunc                        func(ctx context.Context, itemID string, start time.Time, end time.Time, fn func(ih model.ItemHistory) error) error
+	ItemHistoryPriceDropsSinceFunc                func(ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemPriceDrop, error)
+	ItemHistoryPriceStatsFunc                     func(ctx context.Context, itemID string, price int, now time.Time) (model.ItemPriceStats, error)
+	ItemHistoryPricesSinceFunc                    func(ctx context.Context, itemID primitive.ObjectID, since time.Time) ([]int, error)
+	ItemHistoryStockFindRangeFunc                 func(ctx context.Context, itemID string, start time.Time, end time.Time) ([]model.ItemHistory, error)
+	ItemHistoryUpsertFunc                         func(ctx context.Context, ih model.ItemHistory) error
+	ItemInsertFunc                                func(ctx context.Context, i model.Item) (string, error)
+	ItemMatchesFindFunc                           func(ctx context.Context, itemID primitive.ObjectID) ([]model.ItemMatch, error)
+	ItemMatchesUpsertFunc                         func(ctx context.Context, ims []model.ItemMatch) error
+	ItemMergeFunc                                 func(ctx context.Context, itemID primitive.ObjectID, dupID primitive.ObjectID) error
+	ItemNotFoundCountIncFunc                      func(ctx context.Context, itemID primitive.ObjectID) (int, error)
+	ItemPriceUpdateFunc                           func(ctx context.Context, itemID primitive.ObjectID, price int) (model.Item, error)
+	ItemTrackerCountsFindFunc                     func(ctx context.Context) (map[primitive.ObjectID]int, error)
+	ItemUnarchiveFunc                             func(ctx context.Context, itemID primitive.ObjectID) error
+	ItemUpdateFuncFunc                            func(ctx context.Context, i model.Item, fn func(i *model.Item)) (model.Item, error)
+	ItemsArchiveOrphanedFunc                      func(ctx context.Context, orphanedBefore time.Time, now time.Time) (int, error)
+	ItemsArchivedFindFunc                         func(ctx context.Context, is []model.Item) ([]model.Item, error)
+	ItemsFindFunc                                 func(ctx context.Context, itemIDs []primitive.ObjectID) ([]model.Item, error)
+	ItemsFindAllFunc                              func(ctx context.Context) ([]model.Item, error)
+	ItemsFindBySiteFunc                           func(ctx context.Context, site string, merchantID string) ([]model.Item, error)
+	ItemsFindFieldsFunc                           func(ctx context.Context, itemIDs []primitive.ObjectID, fields []string) ([]model.Item, error)
+	ItemsFindMatchCandidatesFunc                  func(ctx context.Context, i model.Item, limit int) ([]model.Item, error)
+	ItemsInCategoryFunc                           func(ctx context.Context, itemIDs []primitive.ObjectID, category string) ([]primitive.ObjectID, error)
+	ItemsMerchantSetFunc                          func(ctx context.Context, site string, merchantID string, name string, rating float64) error
+	ItemsNextFetchAtSetFunc                       func(ctx context.Context, nextFetchAt map[primitive.ObjectID]time.Time) error
+	ItemsOrphanedFindFunc                         func(ctx context.Context) ([]primitive.ObjectID, error)
+	ItemsOrphanedSetFunc                          func(ctx context.Context, itemIDs []primitive.ObjectID, now time.Time) error
+	ItemsRecheckDueFindFunc                       func(ctx context.Context, now time.Time) ([]model.Item, error)
+	ItemsTextSearchFunc                           func(ctx context.Context, query string, skip int64, limit int64) ([]model.Item, error)
+	ItemsTrackedSinceCountFunc                    func(ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemTrackCount, error)
+	ItemsTrackerCountSetFunc                      func(ctx context.Context, counts map[primitive.ObjectID]int) error
+	LoginEventInsertFunc                          func(ctx context.Context, le model.LoginEvent) error
+	LoginEventsFindByUserFunc                     func(ctx context.Context, userID string, limit int64) ([]model.LoginEvent, error)
+	MerchantHistoryFindRangeFunc                  func(ctx context.Context, site string, merchantID string, start time.Time, end time.Time) ([]model.MerchantHistory, error)
+	MerchantHistoryInsertFunc                     func(ctx context.Context, mh model.MerchantHistory) error
+	QueuedNotificationsDeleteFunc                 func(ctx context.Context, ids []primitive.ObjectID) (int, error)
+	QueuedNotificationsFindDueFunc                func(ctx context.Context, now time.Time, limit int64) ([]model.QueuedNotification, error)
+	QueuedNotificationsInsertFunc                 func(ctx context.Context, qns []model.QueuedNotification) error
+	QueuedNotificationsRetryFunc                  func(ctx context.Context, ids []primitive.ObjectID, deliverAt time.Time) (int, error)
+	SearchHistoriesDeleteByUserFunc               func(ctx context.Context, userID primitive.ObjectID) (int, error)
+	SearchHistoriesFindByUserFunc                 func(ctx context.Context, userID primitive.ObjectID, limit int64) ([]model.SearchHistory, error)
+	SearchHistoryFindOneFunc                      func(ctx context.Context, userID primitive.ObjectID, searchID string) (model.SearchHistory, error)
+	SearchHistoryUpsertFunc                       func(ctx context.Context, sh model.SearchHistory) error
+	UserCredentialsSetFunc                        func(ctx context.Context, userID string, email string, password []byte) error
+	UserDeviceAddFunc                             func(ctx context.Context, userID string, d model.Device) error
+	UserDeviceFCMTokenUnsetFunc                   func(ctx context.Context, fcmToken string) error
+	UserDeviceFCMTokenUpdateFunc                  func(ctx context.Context, userID string, deviceID string, fcmToken string) error
+	UserDeviceLastSeenUpdateFunc                  func(ctx context.Context, userID string, deviceID string) error
+	UserDeviceLoginTokenUpdateFunc                func(ctx context.Context, userID string, deviceID string, lt model.LoginToken) error
+	UserDeviceTokensRemoveFunc                    func(ctx context.Context, userID string, deviceID string) error
+	UserDeviceUpdateFunc                          func(ctx context.Context, userID string, d model.Device) error
+	UserDigestSentAtSetFunc                       func(ctx context.Context, userID primitive.ObjectID, sentAt time.Time) error
+	UserEntitlementSetFunc                        func(ctx context.Context, userID string, e model.Entitlement) error
+	UserExportFileCreateFunc                      func(filename string) (*gridfs.UploadStream, error)
+	UserExportFileOpenFunc                        func(fileID primitive.ObjectID) (io.ReadCloser, error)
+	UserExportFindOneFunc                         func(ctx context.Context, userID primitive.ObjectID, exportID string) (model.UserExport, error)
+	UserExportFinishFunc                          func(ctx context.Context, ue model.UserExport) error
+	UserExportInsertFunc                          func(ctx context.Context, ue model.UserExport) (primitive.ObjectID, error)
+	UserExportsCountSinceFunc                     func(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error)
+	UserExportsExpiredDeleteFunc                  func(ctx context.Context, now time.Time) (int, error)
+	UserFindByEmailFunc                           func(ctx context.Context, email string) (model.User, error)
+	UserFindByGoogleIDFunc                        func(ctx context.Context, googleID string) (model.User, error)
+	UserFindByIDFunc                              func(ctx context.Context, id string) (model.User, error)
+	UserFindByReferralCodeFunc                    func(ctx context.Context, code string) (model.User, error)
+	UserGoogleIDSetFunc                           func(ctx context.Context, userID string, googleID string) error
+	UserInsertFunc                                func(ctx context.Context, u model.User) (string, error)
+	UserLocaleSetFunc                             func(ctx context.Context, userID string, locale string) error
+	UserMergeFunc                                 func(ctx context.Context, target model.User, source model.User, trackedItemsLimit int) (int, error)
+	UserNotificationPreferencesUpdateFunc         func(ctx context.Context, userID string, np model.NotificationPreferences) error
+	UserReferralCodeSetFunc                       func(ctx context.Context, userID string, code string) error
+	UserReferralRewardAddFunc                     func(ctx context.Context, userID primitive.ObjectID, trackedItemsBonus int, trackedItemsBonusMax int) error
+	UserReferralRewardClaimFunc                   func(ctx context.Context, userID primitive.ObjectID) (primitive.ObjectID, error)
+	UserRolesSetFunc                              func(ctx context.Context, userID string, roles []string) error
+	UserTelegramLinkByTokenFunc                   func(ctx context.Context, tokenHash string, chatID int64, now time.Time) (model.User, error)
+	UserTelegramLinkTokenSetFunc                  func(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error
+	UserTelegramSetFunc                           func(ctx context.Context, userID string, t model.Telegram) error
+	UserTrackedItemAddFunc                        func(ctx context.Context, userID string, ti model.TrackedItem, trackedItemsLimit int) error
+	UserTrackedItemFetchIntervalSetFunc           func(ctx context.Context, userID string, itemID string, minutes int, until primitive.DateTime) error
+	UserTrackedItemNotificationCountIncrementFunc func(ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error)
+	UserTrackedItemNotificationCountResetFunc     func(ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error)
+	UserTrackedItemRemoveFunc                     func(ctx context.Context, userID string, itemID string) error
+	UserTrackedItemUpdateFunc                     func(ctx context.Context, userID string, ti model.TrackedItem) error
+	UserTrackedItemVariationSetFunc               func(ctx context.Context, userID string, itemID primitive.ObjectID, variationID string, priceInitial int) error
+	UserTrackedItemWebhookAddFunc                 func(ctx context.Context, userID string, itemID string, wh model.Webhook, webhooksLimit int) error
+	UserTrackedItemWebhookRemoveFunc              func(ctx context.Context, userID string, itemID string, webhookID string) error
+	UserTrackedItemsAddFunc                       func(ctx context.Context, userID string, tis []model.TrackedItem, trackedItemsLimit int) error
+	UserTrackedItemsBulkUpdateFunc                func(ctx context.Context, userID string, tius []database.TrackedItemUpdate) (int, error)
+	UserTrackedItemsQuotaSetFunc                  func(ctx context.Context, userID string, quota int) error
+	UsersDeviceFCMTokensFindByTrackedItemFunc     func(ctx context.Context, itemID primitive.ObjectID) ([]model.User, error)
+	UsersDigestDueFindFunc                        func(ctx context.Context, frequency string, sentBefore time.Time, limit int64) ([]model.User, error)
+	UsersTrackedItemAlertsExpireFunc              func(ctx context.Context, now time.Time) (int, error)
+	UsersTrackedItemAlternativesSetFunc           func(ctx context.Context, itemID primitive.ObjectID, alternatives []model.ItemAlternative) (int, error)
+	WithTransactionFunc                           func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+func (m *Database) BarcodeDelete(ctx context.Context, barcodeNumber string) error {
+	if m.BarcodeDeleteFunc == nil {
+		panic("Database.BarcodeDelete called without BarcodeDeleteFunc")
+	}
+	return m.BarcodeDeleteFunc(ctx, barcodeNumber)
+}
+
+func (m *Database) BarcodeFind(ctx context.Context, barcodeNumber string) (model.Barcode, error) {
+	if m.BarcodeFindFunc == nil {
+		panic("Database.BarcodeFind called without BarcodeFindFunc")
+	}
+	return m.BarcodeFindFunc(ctx, barcodeNumber)
+}
+
+func (m *Database) BarcodeInsert(ctx context.Context, b model.Barcode) error {
+	if m.BarcodeInsertFunc == nil {
+		panic("Database.BarcodeInsert called without BarcodeInsertFunc")
+	}
+	return m.BarcodeInsertFunc(ctx, b)
+}
+
+func (m *Database) BarcodeSubmissionFind(ctx context.Context, id string) (model.BarcodeSubmission, error) {
+	if m.BarcodeSubmissionFindFunc == nil {
+		panic("Database.BarcodeSubmissionFind called without BarcodeSubmissionFindFunc")
+	}
+	return m.BarcodeSubmissionFindFunc(ctx, id)
+}
+
+func (m *Database) BarcodeSubmissionInsert(ctx context.Context, bs model.BarcodeSubmission) (string, error) {
+	if m.BarcodeSubmissionInsertFunc == nil {
+		panic("Database.BarcodeSubmissionInsert called without BarcodeSubmissionInsertFunc")
+	}
+	return m.BarcodeSubmissionInsertFunc(ctx, bs)
+}
+
+func (m *Database) BarcodeSubmissionReview(ctx context.Context, id string, status string, reviewedBy string, rejectReason string) error {
+	if m.BarcodeSubmissionReviewFunc == nil {
+		panic("Database.BarcodeSubmissionReview called without BarcodeSubmissionReviewFunc")
+	}
+	return m.BarcodeSubmissionReviewFunc(ctx, id, status, reviewedBy, rejectReason)
+}
+
+func (m *Database) BarcodeSubmissionsFindByStatus(ctx context.Context, status string, limit int64) ([]model.BarcodeSubmission, error) {
+	if m.BarcodeSubmissionsFindByStatusFunc == nil {
+		panic("Database.BarcodeSubmissionsFindByStatus called without BarcodeSubmissionsFindByStatusFunc")
+	}
+	return m.BarcodeSubmissionsFindByStatusFunc(ctx, status, limit)
+}
+
+func (m *Database) BarcodeSubmissionsPendingCount(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	if m.BarcodeSubmissionsPendingCountFunc == nil {
+		panic("Database.BarcodeSubmissionsPendingCount called without BarcodeSubmissionsPendingCountFunc")
+	}
+	return m.BarcodeSubmissionsPendingCountFunc(ctx, userID)
+}
+
+func (m *Database) BarcodeUpdate(ctx context.Context, b model.Barcode) error {
+	if m.BarcodeUpdateFunc == nil {
+		panic("Database.BarcodeUpdate called without BarcodeUpdateFunc")
+	}
+	return m.BarcodeUpdateFunc(ctx, b)
+}
+
+func (m *Database) BarcodesUpsert(ctx context.Context, bs []model.Barcode) (int, int, error) {
+	if m.BarcodesUpsertFunc == nil {
+		panic("Database.BarcodesUpsert called without BarcodesUpsertFunc")
+	}
+	return m.BarcodesUpsertFunc(ctx, bs)
+}
+
+func (m *Database) BillingEventDelete(ctx context.Context, id string) error {
+	if m.BillingEventDeleteFunc == nil {
+		panic("Database.BillingEventDelete called without BillingEventDeleteFunc")
+	}
+	return m.BillingEventDeleteFunc(ctx, id)
+}
+
+func (m *Database) BillingEventInsert(ctx context.Context, be model.BillingEvent) (string, error) {
+	if m.BillingEventInsertFunc == nil {
+		panic("Database.BillingEventInsert called without BillingEventInsertFunc")
+	}
+	return m.BillingEventInsertFunc(ctx, be)
+}
+
+func (m *Database) BillingEventUpdate(ctx context.Context, id string, be model.BillingEvent) error {
+	if m.BillingEventUpdateFunc == nil {
+		panic("Database.BillingEventUpdate called without BillingEventUpdateFunc")
+	}
+	return m.BillingEventUpdateFunc(ctx, id, be)
+}
+
+func (m *Database) BillingEventsFindByOrder(ctx context.Context, provider string, orderID string) ([]model.BillingEvent, error) {
+	if m.BillingEventsFindByOrderFunc == nil {
+		panic("Database.BillingEventsFindByOrder called without BillingEventsFindByOrderFunc")
+	}
+	return m.BillingEventsFindByOrderFunc(ctx, provider, orderID)
+}
+
+func (m *Database) BillingEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.BillingEvent, error) {
+	if m.BillingEventsFindByUserFunc == nil {
+		panic("Database.BillingEventsFindByUser called without BillingEventsFindByUserFunc")
+	}
+	return m.BillingEventsFindByUserFunc(ctx, userID, limit)
+}
+
+func (m *Database) EnsureIndexes(ctx context.Context) error {
+	if m.EnsureIndexesFunc == nil {
+		panic("Database.EnsureIndexes called without EnsureIndexesFunc")
+	}
+	return m.EnsureIndexesFunc(ctx)
+}
+
+func (m *Database) FetchCycleFind(ctx context.Context, id string) (model.FetchCycle, error) {
+	if m.FetchCycleFindFunc == nil {
+		panic("Database.FetchCycleFind called without FetchCycleFindFunc")
+	}
+	return m.FetchCycleFindFunc(ctx, id)
+}
+
+func (m *Database) FetchCycleFindLastFinished(ctx context.Context, kind string) (model.FetchCycle, error) {
+	if m.FetchCycleFindLastFinishedFunc == nil {
+		panic("Database.FetchCycleFindLastFinished called without FetchCycleFindLastFinishedFunc")
+	}
+	return m.FetchCycleFindLastFinishedFunc(ctx, kind)
+}
+
+func (m *Database) FetchCycleSave(ctx context.Context, fc model.FetchCycle) error {
+	if m.FetchCycleSaveFunc == nil {
+		panic("Database.FetchCycleSave called without FetchCycleSaveFunc")
+	}
+	return m.FetchCycleSaveFunc(ctx, fc)
+}
+
+func (m *Database) FetchCyclesFindLatest(ctx context.Context, limit int64) ([]model.FetchCycle, error) {
+	if m.FetchCyclesFindLatestFunc == nil {
+		panic("Database.FetchCyclesFindLatest called without FetchCyclesFindLatestFunc")
+	}
+	return m.FetchCyclesFindLatestFunc(ctx, limit)
+}
+
+func (m *Database) FetchCyclesFindRunning(ctx context.Context, since time.Time) ([]model.FetchCycle, error) {
+	if m.FetchCyclesFindRunningFunc == nil {
+		panic("Database.FetchCyclesFindRunning called without FetchCyclesFindRunningFunc")
+	}
+	return m.FetchCyclesFindRunningFunc(ctx, since)
+}
+
+func (m *Database) IndexesDrift(ctx context.Context) ([]database.IndexDrift, error) {
+	if m.IndexesDriftFunc == nil {
+		panic("Database.IndexesDrift called without IndexesDriftFunc")
+	}
+	return m.IndexesDriftFunc(ctx)
+}
+
+func (m *Database) ItemArchive(ctx context.Context, itemID primitive.ObjectID, reason string, now time.Time) error {
+	if m.ItemArchiveFunc == nil {
+		panic("Database.ItemArchive called without ItemArchiveFunc")
+	}
+	return m.ItemArchiveFunc(ctx, itemID, reason, now)
+}
+
+func (m *Database) ItemDuplicatesFind(ctx context.Context, since time.Time, limit int64) ([]database.ItemDuplicates, error) {
+	if m.ItemDuplicatesFindFunc == nil {
+		panic("Database.ItemDuplicatesFind called without ItemDuplicatesFindFunc")
+	}
+	return m.ItemDuplicatesFindFunc(ctx, since, limit)
+}
+
+func (m *Database) ItemFetchIntervalsFind(ctx context.Context, now time.Time) ([]database.ItemFetchInterval, error) {
+	if m.ItemFetchIntervalsFindFunc == nil {
+		panic("Database.ItemFetchIntervalsFind called without ItemFetchIntervalsFindFunc")
+	}
+	return m.ItemFetchIntervalsFindFunc(ctx, now)
+}
+
+func (m *Database) ItemFindExisting(ctx context.Context, i model.Item) (model.Item, error) {
+	if m.ItemFindExistingFunc == nil {
+		panic("Database.ItemFindExisting called without ItemFindExistingFunc")
+	}
+	return m.ItemFindExistingFunc(ctx, i)
+}
+
+func (m *Database) ItemFindOne(ctx context.Context, itemID string) (model.Item, error) {
+	if m.ItemFindOneFunc == nil {
+		panic("Database.ItemFindOne called without ItemFindOneFunc")
+	}
+	return m.ItemFindOneFunc(ctx, itemID)
+}
+
+func (m *Database) ItemHistoryAggregate(ctx context.Context, itemID string, start time.Time, end time.Time, interval string, loc *time.Location) ([]model.ItemHistoryBucket, error) {
+	if m.ItemHistoryAggregateFunc == nil {
+		panic("Database.ItemHistoryAggregate called without ItemHistoryAggregateFunc")
+	}
+	return m.ItemHistoryAggregateFunc(ctx, itemID, start, end, interval, loc)
+}
+
+func (m *Database) ItemHistoryFindRange(ctx context.Context, itemID string, start time.Time, end time.Time, offset int64, limit int64) ([]model.ItemHistory, error) {
+	if m.ItemHistoryFindRangeFunc == nil {
+		panic("Database.ItemHistoryFindRange called without ItemHistoryFindRangeFunc")
+	}
+	return m.ItemHistoryFindRangeFunc(ctx, itemID, start, end, offset, limit)
+}
+
+func (m *Database) ItemHistoryFirstPricesSince(ctx context.Context, itemIDs []primitive.ObjectID, since time.Time) (map[primitive.ObjectID]int, error) {
+	if m.ItemHistoryFirstPricesSinceFunc == nil {
+		panic("Database.ItemHistoryFirstPricesSince called without ItemHistoryFirstPricesSinceFunc")
+	}
+	return m.ItemHistoryFirstPricesSinceFunc(ctx, itemIDs, since)
+}
+
+func (m *Database) ItemHistoryForEach(ctx context.Context, itemID string, start time.Time, end time.Time, fn func(ih model.ItemHistory) error) error {
+	if m.ItemHistoryForEachFunc == nil {
+		panic("Database.ItemHistoryForEach called without ItemHistoryForEachFunc")
+	}
+	return m.ItemHistoryForEachFunc(ctx, itemID, start, end, fn)
+}
+
+func (m *Database) ItemHistoryPriceDropsSince(ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemPriceDrop, error) {
+	if m.ItemHistoryPriceDropsSinceFunc == nil {
+		panic("Database.ItemHistoryPriceDropsSince called without ItemHistoryPriceDropsSinceFunc")
+	}
+	return m.ItemHistoryPriceDropsSinceFunc(ctx, since, category, limit)
+}
+
+func (m *Database) ItemHistoryPriceStats(ctx context.Context, itemID string, price int, now time.Time) (model.ItemPriceStats, error) {
+	if m.ItemHistoryPriceStatsFunc == nil {
+		panic("Database.ItemHistoryPriceStats called without ItemHistoryPriceStatsFunc")
+	}
+	return m.ItemHistoryPriceStatsFunc(ctx, itemID, price, now)
+}
+
+func (m *Database) ItemHistoryPricesSince(ctx context.Context, itemID primitive.ObjectID, since time.Time) ([]int, error) {
+	if m.ItemHistoryPricesSinceFunc == nil {
+		panic("Database.ItemHistoryPricesSince called without ItemHistoryPricesSinceFunc")
+	}
+	return m.ItemHistoryPricesSinceFunc(ctx, itemID, since)
+}
+
+func (m *Database) ItemHistoryStockFindRange(ctx context.Context, itemID string, start time.Time, end time.Time) ([]model.ItemHistory, error) {
+	if m.ItemHistoryStockFindRangeFunc == nil {
+		panic("Database.ItemHistoryStockFindRange called without ItemHistoryStockFindRangeFunc")
+	}
+	return m.ItemHistoryStockFindRangeFunc(ctx, itemID, start, end)
+}
+
+func (m *Database) ItemHistoryUpsert(ctx context.Context, ih model.ItemHistory) error {
+	if m.ItemHistoryUpsertFunc == nil {
+		panic("Database.ItemHistoryUpsert called without ItemHistoryUpsertFunc")
+	}
+	return m.ItemHistoryUpsertFunc(ctx, ih)
+}
+
+func (m *Database) ItemInsert(ctx context.Context, i model.Item) (string, error) {
+	if m.ItemInsertFunc == nil {
+		panic("Database.ItemInsert called without ItemInsertFunc")
+	}
+	return m.ItemInsertFunc(ctx, i)
+}
+
+func (m *Database) ItemMatchesFind(ctx context.Context, itemID primitive.ObjectID) ([]model.ItemMatch, error) {
+	if m.ItemMatchesFindFunc == nil {
+		panic("Database.ItemMatchesFind called without ItemMatchesFindFunc")
+	}
+	return m.ItemMatchesFindFunc(ctx, itemID)
+}
+
+func (m *Database) ItemMatchesUpsert(ctx context.Context, ims []model.ItemMatch) error {
+	if m.ItemMatchesUpsertFunc == nil {
+		panic("Database.ItemMatchesUpsert called without ItemMatchesUpsertFunc")
+	}
+	return m.ItemMatchesUpsertFunc(ctx, ims)
+}
+
+func (m *Database) ItemMerge(ctx context.Context, itemID primitive.ObjectID, dupID primitive.ObjectID) error {
+	if m.ItemMergeFunc == nil {
+		panic("Database.ItemMerge called without ItemMergeFunc")
+	}
+	return m.ItemMergeFunc(ctx, itemID, dupID)
+}
+
+func (m *Database) ItemNotFoundCountInc(ctx context.Context, itemID primitive.ObjectID) (int, error) {
+	if m.ItemNotFoundCountIncFunc == nil {
+		panic("Database.ItemNotFoundCountInc called without ItemNotFoundCountIncFunc")
+	}
+	return m.ItemNotFoundCountIncFunc(ctx, itemID)
+}
+
+func (m *Database) ItemPriceUpdate(ctx context.Context, itemID primitive.ObjectID, price int) (model.Item, error) {
+	if m.ItemPriceUpdateFunc == nil {
+		panic("Database.ItemPriceUpdate called without ItemPriceUpdateFunc")
+	}
+	return m.ItemPriceUpdateFunc(ctx, itemID, price)
+}
+
+func (m *Database) ItemTrackerCountsFind(ctx context.Context) (map[primitive.ObjectID]int, error) {
+	if m.ItemTrackerCountsFindFunc == nil {
+		panic("Database.ItemTrackerCountsFind called without ItemTrackerCountsFindFunc")
+	}
+	return m.ItemTrackerCountsFindFunc(ctx)
+}
+
+func (m *Database) ItemUnarchive(ctx context.Context, itemID primitive.ObjectID) error {
+	if m.ItemUnarchiveFunc == nil {
+		panic("Database.ItemUnarchive called without ItemUnarchiveFunc")
+	}
+	return m.ItemUnarchiveFunc(ctx, itemID)
+}
+
+func (m *Database) ItemUpdateFunc(ctx context.Context, i model.Item, fn func(i *model.Item)) (model.Item, error) {
+	if m.ItemUpdateFuncFunc == nil {
+		panic("Database.ItemUpdateFunc called without ItemUpdateFuncFunc")
+	}
+	return m.ItemUpdateFuncFunc(ctx, i, fn)
+}
+
+func (m *Database) ItemsArchiveOrphaned(ctx context.Context, orphanedBefore time.Time, now time.Time) (int, error) {
+	if m.ItemsArchiveOrphanedFunc == nil {
+		panic("Database.ItemsArchiveOrphaned called without ItemsArchiveOrphanedFunc")
+	}
+	return m.ItemsArchiveOrphanedFunc(ctx, orphanedBefore, now)
+}
+
+func (m *Database) ItemsArchivedFind(ctx context.Context, is []model.Item) ([]model.Item, error) {
+	if m.ItemsArchivedFindFunc == nil {
+		panic("Database.ItemsArchivedFind called without ItemsArchivedFindFunc")
+	}
+	return m.ItemsArchivedFindFunc(ctx, is)
+}
+
+func (m *Database) ItemsFind(ctx context.Context, itemIDs []primitive.ObjectID) ([]model.Item, error) {
+	if m.ItemsFindFunc == nil {
+		panic("Database.ItemsFind called without ItemsFindFunc")
+	}
+	return m.ItemsFindFunc(ctx, itemIDs)
+}
+
+func (m *Database) ItemsFindAll(ctx context.Context) ([]model.Item, error) {
+	if m.ItemsFindAllFunc == nil {
+		panic("Database.ItemsFindAll called without ItemsFindAllFunc")
+	}
+	return m.ItemsFindAllFunc(ctx)
+}
+
+func (m *Database) ItemsFindBySite(ctx context.Context, site string, merchantID string) ([]model.Item, error) {
+	if m.ItemsFindBySiteFunc == nil {
+		panic("Database.ItemsFindBySite called without ItemsFindBySiteFunc")
+	}
+	return m.ItemsFindBySiteFunc(ctx, site, merchantID)
+}
+
+func (m *Database) ItemsFindFields(ctx context.Context, itemIDs []primitive.ObjectID, fields []string) ([]model.Item, error) {
+	if m.ItemsFindFieldsFunc == nil {
+		panic("Database.ItemsFindFields called without ItemsFindFieldsFunc")
+	}
+	return m.ItemsFindFieldsFunc(ctx, itemIDs, fields)
+}
+
+func (m *Database) ItemsFindMatchCandidates(ctx context.Context, i model.Item, limit int) ([]model.Item, error) {
+	if m.ItemsFindMatchCandidatesFunc == nil {
+		panic("Database.ItemsFindMatchCandidates called without ItemsFindMatchCandidatesFunc")
+	}
+	return m.ItemsFindMatchCandidatesFunc(ctx, i, limit)
+}
+
+func (m *Database) ItemsInCategory(ctx context.Context, itemIDs []primitive.ObjectID, category string) ([]primitive.ObjectID, error) {
+	if m.ItemsInCategoryFunc == nil {
+		panic("Database.ItemsInCategory called without ItemsInCategoryFunc")
+	}
+	return m.ItemsInCategoryFunc(ctx, itemIDs, category)
+}
+
+func (m *Database) ItemsMerchantSet(ctx context.Context, site string, merchantID string, name string, rating float64) error {
+	if m.ItemsMerchantSetFunc == nil {
+		panic("Database.ItemsMerchantSet called without ItemsMerchantSetFunc")
+	}
+	return m.ItemsMerchantSetFunc(ctx, site, merchantID, name, rating)
+}
+
+func (m *Database) ItemsNextFetchAtSet(ctx context.Context, nextFetchAt map[primitive.ObjectID]time.Time) error {
+	if m.ItemsNextFetchAtSetFunc == nil {
+		panic("Database.ItemsNextFetchAtSet called without ItemsNextFetchAtSetFunc")
+	}
+	return m.ItemsNextFetchAtSetFunc(ctx, nextFetchAt)
+}
+
+func (m *Database) ItemsOrphanedFind(ctx context.Context) ([]primitive.ObjectID, error) {
+	if m.ItemsOrphanedFindFunc == nil {
+		panic("Database.ItemsOrphanedFind called without ItemsOrphanedFindFunc")
+	}
+	return m.ItemsOrphanedFindFunc(ctx)
+}
+
+func (m *Database) ItemsOrphanedSet(ctx context.Context, itemIDs []primitive.ObjectID, now time.Time) error {
+	if m.ItemsOrphanedSetFunc == nil {
+		panic("Database.ItemsOrphanedSet called without ItemsOrphanedSetFunc")
+	}
+	return m.ItemsOrphanedSetFunc(ctx, itemIDs, now)
+}
+
+func (m *Database) ItemsRecheckDueFind(ctx context.Context, now time.Time) ([]model.Item, error) {
+	if m.ItemsRecheckDueFindFunc == nil {
+		panic("Database.ItemsRecheckDueFind called without ItemsRecheckDueFindFunc")
+	}
+	return m.ItemsRecheckDueFindFunc(ctx, now)
+}
+
+func (m *Database) ItemsTextSearch(ctx context.Context, query string, skip int64, limit int64) ([]model.Item, error) {
+	if m.ItemsTextSearchFunc == nil {
+		panic("Database.ItemsTextSearch called without ItemsTextSearchFunc")
+	}
+	return m.ItemsTextSearchFunc(ctx, query, skip, limit)
+}
+
+func (m *Database) ItemsTrackedSinceCount(ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemTrackCount, error) {
+	if m.ItemsTrackedSinceCountFunc == nil {
+		panic("Database.ItemsTrackedSinceCount called without ItemsTrackedSinceCountFunc")
+	}
+	return m.ItemsTrackedSinceCountFunc(ctx, since, category, limit)
+}
+
+func (m *Database) ItemsTrackerCountSet(ctx context.Context, counts map[primitive.ObjectID]int) error {
+	if m.ItemsTrackerCountSetFunc == nil {
+		panic("Database.ItemsTrackerCountSet called without ItemsTrackerCountSetFunc")
+	}
+	return m.ItemsTrackerCountSetFunc(ctx, counts)
+}
+
+func (m *Database) LoginEventInsert(ctx context.Context, le model.LoginEvent) error {
+	if m.LoginEventInsertFunc == nil {
+		panic("Database.LoginEventInsert called without LoginEventInsertFunc")
+	}
+	return m.LoginEventInsertFunc(ctx, le)
+}
+
+func (m *Database) LoginEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.LoginEvent, error) {
+	if m.LoginEventsFindByUserFunc == nil {
+		panic("Database.LoginEventsFindByUser called without LoginEventsFindByUserFunc")
+	}
+	return m.LoginEventsFindByUserFunc(ctx, userID, limit)
+}
+
+func (m *Database) MerchantHistoryFindRange(ctx context.Context, site string, merchantID string, start time.Time, end time.Time) ([]model.MerchantHistory, error) {
+	if m.MerchantHistoryFindRangeFunc == nil {
+		panic("Database.MerchantHistoryFindRange called without MerchantHistoryFindRangeFunc")
+	}
+	return m.MerchantHistoryFindRangeFunc(ctx, site, merchantID, start, end)
+}
+
+func (m *Database) MerchantHistoryInsert(ctx context.Context, mh model.MerchantHistory) error {
+	if m.MerchantHistoryInsertFunc == nil {
+		panic("Database.MerchantHistoryInsert called without MerchantHistoryInsertFunc")
+	}
+	return m.MerchantHistoryInsertFunc(ctx, mh)
+}
+
+func (m *Database) QueuedNotificationsDelete(ctx context.Context, ids []primitive.ObjectID) (int, error) {
+	if m.QueuedNotificationsDeleteFunc == nil {
+		panic("Database.QueuedNotificationsDelete called without QueuedNotificationsDeleteFunc")
+	}
+	return m.QueuedNotificationsDeleteFunc(ctx, ids)
+}
+
+func (m *Database) QueuedNotificationsFindDue(ctx context.Context, now time.Time, limit int64) ([]model.QueuedNotification, error) {
+	if m.QueuedNotificationsFindDueFunc == nil {
+		panic("Database.QueuedNotificationsFindDue called without QueuedNotificationsFindDueFunc")
+	}
+	return m.QueuedNotificationsFindDueFunc(ctx, now, limit)
+}
+
+func (m *Database) QueuedNotificationsInsert(ctx context.Context, qns []model.QueuedNotification) error {
+	if m.QueuedNotificationsInsertFunc == nil {
+		panic("Database.QueuedNotificationsInsert called without QueuedNotificationsInsertFunc")
+	}
+	return m.QueuedNotificationsInsertFunc(ctx, qns)
+}
+
+func (m *Database) QueuedNotificationsRetry(ctx context.Context, ids []primitive.ObjectID, deliverAt time.Time) (int, error) {
+	if m.QueuedNotificationsRetryFunc == nil {
+		panic("Database.QueuedNotificationsRetry called without QueuedNotificationsRetryFunc")
+	}
+	return m.QueuedNotificationsRetryFunc(ctx, ids, deliverAt)
+}
+
+func (m *Database) SearchHistoriesDeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	if m.SearchHistoriesDeleteByUserFunc == nil {
+		panic("Database.SearchHistoriesDeleteByUser called without SearchHistoriesDeleteByUserFunc")
+	}
+	return m.SearchHistoriesDeleteByUserFunc(ctx, userID)
+}
+
+func (m *Database) SearchHistoriesFindByUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]model.SearchHistory, error) {
+	if m.SearchHistoriesFindByUserFunc == nil {
+		panic("Database.SearchHistoriesFindByUser called without SearchHistoriesFindByUserFunc")
+	}
+	return m.SearchHistoriesFindByUserFunc(ctx, userID, limit)
+}
+
+func (m *Database) SearchHistoryFindOne(ctx context.Context, userID primitive.ObjectID, searchID string) (model.SearchHistory, error) {
+	if m.SearchHistoryFindOneFunc == nil {
+		panic("Database.SearchHistoryFindOne called without SearchHistoryFindOneFunc")
+	}
+	return m.SearchHistoryFindOneFunc(ctx, userID, searchID)
+}
+
+func (m *Database) SearchHistoryUpsert(ctx context.Context, sh model.SearchHistory) error {
+	if m.SearchHistoryUpsertFunc == nil {
+		panic("Database.SearchHistoryUpsert called without SearchHistoryUpsertFunc")
+	}
+	return m.SearchHistoryUpsertFunc(ctx, sh)
+}
+
+func (m *Database) UserCredentialsSet(ctx context.Context, userID string, email string, password []byte) error {
+	if m.UserCredentialsSetFunc == nil {
+		panic("Database.UserCredentialsSet called without UserCredentialsSetFunc")
+	}
+	return m.UserCredentialsSetFunc(ctx, userID, email, password)
+}
+
+func (m *Database) UserDeviceAdd(ctx context.Context, userID string, d model.Device) error {
+	if m.UserDeviceAddFunc == nil {
+		panic("Database.UserDeviceAdd called without UserDeviceAddFunc")
+	}
+	return m.UserDeviceAddFunc(ctx, userID, d)
+}
+
+func (m *Database) UserDeviceFCMTokenUnset(ctx context.Context, fcmToken string) error {
+	if m.UserDeviceFCMTokenUnsetFunc == nil {
+		panic("Database.UserDeviceFCMTokenUnset called without UserDeviceFCMTokenUnsetFunc")
+	}
+	return m.UserDeviceFCMTokenUnsetFunc(ctx, fcmToken)
+}
+
+func (m *Database) UserDeviceFCMTokenUpdate(ctx context.Context, userID string, deviceID string, fcmToken string) error {
+	if m.UserDeviceFCMTokenUpdateFunc == nil {
+		panic("Database.UserDeviceFCMTokenUpdate called without UserDeviceFCMTokenUpdateFunc")
+	}
+	return m.UserDeviceFCMTokenUpdateFunc(ctx, userID, deviceID, fcmToken)
+}
+
+func (m *Database) UserDeviceLastSeenUpdate(ctx context.Context, userID string, deviceID string) error {
+	if m.UserDeviceLastSeenUpdateFunc == nil {
+		panic("Database.UserDeviceLastSeenUpdate called without UserDeviceLastSeenUpdateFunc")
+	}
+	return m.UserDeviceLastSeenUpdateFunc(ctx, userID, deviceID)
+}
+
+func (m *Database) UserDeviceLoginTokenUpdate(ctx context.Context, userID string, deviceID string, lt model.LoginToken) error {
+	if m.UserDeviceLoginTokenUpdateFunc == nil {
+		panic("Database.UserDeviceLoginTokenUpdate called without UserDeviceLoginTokenUpdateFunc")
+	}
+	return m.UserDeviceLoginTokenUpdateFunc(ctx, userID, deviceID, lt)
+}
+
+func (m *Database) UserDeviceTokensRemove(ctx context.Context, userID string, deviceID string) error {
+	if m.UserDeviceTokensRemoveFunc == nil {
+		panic("Database.UserDeviceTokensRemove called without UserDeviceTokensRemoveFunc")
+	}
+	return m.UserDeviceTokensRemoveFunc(ctx, userID, deviceID)
+}
+
+func (m *Database) UserDeviceUpdate(ctx context.Context, userID string, d model.Device) error {
+	if m.UserDeviceUpdateFunc == nil {
+		panic("Database.UserDeviceUpdate called without UserDeviceUpdateFunc")
+	}
+	return m.UserDeviceUpdateFunc(ctx, userID, d)
+}
+
+func (m *Database) UserDigestSentAtSet(ctx context.Context, userID primitive.ObjectID, sentAt time.Time) error {
+	if m.UserDigestSentAtSetFunc == nil {
+		panic("Database.UserDigestSentAtSet called without UserDigestSentAtSetFunc")
+	}
+	return m.UserDigestSentAtSetFunc(ctx, userID, sentAt)
+}
+
+func (m *Database) UserEntitlementSet(ctx context.Context, userID string, e model.Entitlement) error {
+	if m.UserEntitlementSetFunc == nil {
+		panic("Database.UserEntitlementSet called without UserEntitlementSetFunc")
+	}
+	return m.UserEntitlementSetFunc(ctx, userID, e)
+}
+
+func (m *Database) UserExportFileCreate(filename string) (*gridfs.UploadStream, error) {
+	if m.UserExportFileCreateFunc == nil {
+		panic("Database.UserExportFileCreate called without UserExportFileCreateFunc")
+	}
+	return m.UserExportFileCreateFunc(filename)
+}
+
+func (m *Database) UserExportFileOpen(fileID primitive.ObjectID) (io.ReadCloser, error) {
+	if m.UserExportFileOpenFunc == nil {
+		panic("Database.UserExportFileOpen called without UserExportFileOpenFunc")
+	}
+	return m.UserExportFileOpenFunc(fileID)
+}
+
+func (m *Database) UserExportFindOne(ctx context.Context, userID primitive.ObjectID, exportID string) (model.UserExport, error) {
+	if m.UserExportFindOneFunc == nil {
+		panic("Database.UserExportFindOne called without UserExportFindOneFunc")
+	}
+	return m.UserExportFindOneFunc(ctx, userID, exportID)
+}
+
+func (m *Database) UserExportFinish(ctx context.Context, ue model.UserExport) error {
+	if m.UserExportFinishFunc == nil {
+		panic("Database.UserExportFinish called without UserExportFinishFunc")
+	}
+	return m.UserExportFinishFunc(ctx, ue)
+}
+
+func (m *Database) UserExportInsert(ctx context.Context, ue model.UserExport) (primitive.ObjectID, error) {
+	if m.UserExportInsertFunc == nil {
+		panic("Database.UserExportInsert called without UserExportInsertFunc")
+	}
+	return m.UserExportInsertFunc(ctx, ue)
+}
+
+func (m *Database) UserExportsCountSince(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error) {
+	if m.UserExportsCountSinceFunc == nil {
+		panic("Database.UserExportsCountSince called without UserExportsCountSinceFunc")
+	}
+	return m.UserExportsCountSinceFunc(ctx, userID, since)
+}
+
+func (m *Database) UserExportsExpiredDelete(ctx context.Context, now time.Time) (int, error) {
+	if m.UserExportsExpiredDeleteFunc == nil {
+		panic("Database.UserExportsExpiredDelete called without UserExportsExpiredDeleteFunc")
+	}
+	return m.UserExportsExpiredDeleteFunc(ctx, now)
+}
+
+func (m *Database) UserFindByEmail(ctx context.Context, email string) (model.User, error) {
+	if m.UserFindByEmailFunc == nil {
+		panic("Database.UserFindByEmail called without UserFindByEmailFunc")
+	}
+	return m.UserFindByEmailFunc(ctx, email)
+}
+
+func (m *Database) UserFindByGoogleID(ctx context.Context, googleID string) (model.User, error) {
+	if m.UserFindByGoogleIDFunc == nil {
+		panic("Database.UserFindByGoogleID called without UserFindByGoogleIDFunc")
+	}
+	return m.UserFindByGoogleIDFunc(ctx, googleID)
+}
+
+func (m *Database) UserFindByID(ctx context.Context, id string) (model.User, error) {
+	if m.UserFindByIDFunc == nil {
+		panic("Database.UserFindByID called without UserFindByIDFunc")
+	}
+	return m.UserFindByIDFunc(ctx, id)
+}
+
+func (m *Database) UserFindByReferralCode(ctx context.Context, code string) (model.User, error) {
+	if m.UserFindByReferralCodeFunc == nil {
+		panic("Database.UserFindByReferralCode called without UserFindByReferralCodeFunc")
+	}
+	return m.UserFindByReferralCodeFunc(ctx, code)
+}
+
+func (m *Database) UserGoogleIDSet(ctx context.Context, userID string, googleID string) error {
+	if m.UserGoogleIDSetFunc == nil {
+		panic("Database.UserGoogleIDSet called without UserGoogleIDSetFunc")
+	}
+	return m.UserGoogleIDSetFunc(ctx, userID, googleID)
+}
+
+func (m *Database) UserInsert(ctx context.Context, u model.User) (string, error) {
+	if m.UserInsertFunc == nil {
+		panic("Database.UserInsert called without UserInsertFunc")
+	}
+	return m.UserInsertFunc(ctx, u)
+}
+
+func (m *Database) UserLocaleSet(ctx context.Context, userID string, locale string) error {
+	if m.UserLocaleSetFunc == nil {
+		panic("Database.UserLocaleSet called without UserLocaleSetFunc")
+	}
+	return m.UserLocaleSetFunc(ctx, userID, locale)
+}
+
+func (m *Database) UserMerge(ctx context.Context, target model.User, source model.User, trackedItemsLimit int) (int, error) {
+	if m.UserMergeFunc == nil {
+		panic("Database.UserMerge called without UserMergeFunc")
+	}
+	return m.UserMergeFunc(ctx, target, source, trackedItemsLimit)
+}
+
+func (m *Database) UserNotificationPreferencesUpdate(ctx context.Context, userID string, np model.NotificationPreferences) error {
+	if m.UserNotificationPreferencesUpdateFunc == nil {
+		panic("Database.UserNotificationPreferencesUpdate called without UserNotificationPreferencesUpdateFunc")
+	}
+	return m.UserNotificationPreferencesUpdateFunc(ctx, userID, np)
+}
+
+func (m *Database) UserReferralCodeSet(ctx context.Context, userID string, code string) error {
+	if m.UserReferralCodeSetFunc == nil {
+		panic("Database.UserReferralCodeSet called without UserReferralCodeSetFunc")
+	}
+	return m.UserReferralCodeSetFunc(ctx, userID, code)
+}
+
+func (m *Database) UserReferralRewardAdd(ctx context.Context, userID primitive.ObjectID, trackedItemsBonus int, trackedItemsBonusMax int) error {
+	if m.UserReferralRewardAddFunc == nil {
+		panic("Database.UserReferralRewardAdd called without UserReferralRewardAddFunc")
+	}
+	return m.UserReferralRewardAddFunc(ctx, userID, trackedItemsBonus, trackedItemsBonusMax)
+}
+
+func (m *Database) UserReferralRewardClaim(ctx context.Context, userID primitive.ObjectID) (primitive.ObjectID, error) {
+	if m.UserReferralRewardClaimFunc == nil {
+		panic("Database.UserReferralRewardClaim called without UserReferralRewardClaimFunc")
+	}
+	return m.UserReferralRewardClaimFunc(ctx, userID)
+}
+
+func (m *Database) UserRolesSet(ctx context.Context, userID string, roles []string) error {
+	if m.UserRolesSetFunc == nil {
+		panic("Database.UserRolesSet called without UserRolesSetFunc")
+	}
+	return m.UserRolesSetFunc(ctx, userID, roles)
+}
+
+func (m *Database) UserTelegramLinkByToken(ctx context.Context, tokenHash string, chatID int64, now time.Time) (model.User, error) {
+	if m.UserTelegramLinkByTokenFunc == nil {
+		panic("Database.UserTelegramLinkByToken called without UserTelegramLinkByTokenFunc")
+	}
+	return m.UserTelegramLinkByTokenFunc(ctx, tokenHash, chatID, now)
+}
+
+func (m *Database) UserTelegramLinkTokenSet(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error {
+	if m.UserTelegramLinkTokenSetFunc == nil {
+		panic("Database.UserTelegramLinkTokenSet called without UserTelegramLinkTokenSetFunc")
+	}
+	return m.UserTelegramLinkTokenSetFunc(ctx, userID, tokenHash, expiresAt)
+}
+
+func (m *Database) UserTelegramSet(ctx context.Context, userID string, t model.Telegram) error {
+	if m.UserTelegramSetFunc == nil {
+		panic("Database.UserTelegramSet called without UserTelegramSetFunc")
+	}
+	return m.UserTelegramSetFunc(ctx, userID, t)
+}
+
+func (m *Database) UserTrackedItemAdd(ctx context.Context, userID string, ti model.TrackedItem, trackedItemsLimit int) error {
+	if m.UserTrackedItemAddFunc == nil {
+		panic("Database.UserTrackedItemAdd called without UserTrackedItemAddFunc")
+	}
+	return m.UserTrackedItemAddFunc(ctx, userID, ti, trackedItemsLimit)
+}
+
+func (m *Database) UserTrackedItemFetchIntervalSet(ctx context.Context, userID string, itemID string, minutes int, until primitive.DateTime) error {
+	if m.UserTrackedItemFetchIntervalSetFunc == nil {
+		panic("Database.UserTrackedItemFetchIntervalSet called without UserTrackedItemFetchIntervalSetFunc")
+	}
+	return m.UserTrackedItemFetchIntervalSetFunc(ctx, userID, itemID, minutes, until)
+}
+
+func (m *Database) UserTrackedItemNotificationCountIncrement(ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error) {
+	if m.UserTrackedItemNotificationCountIncrementFunc == nil {
+		panic("Database.UserTrackedItemNotificationCountIncrement called without UserTrackedItemNotificationCountIncrementFunc")
+	}
+	return m.UserTrackedItemNotificationCountIncrementFunc(ctx, userIDs, itemID)
+}
+
+func (m *Database) UserTrackedItemNotificationCountReset(ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID) (int, error) {
+	if m.UserTrackedItemNotificationCountResetFunc == nil {
+		panic("Database.UserTrackedItemNotificationCountReset called without UserTrackedItemNotificationCountResetFunc")
+	}
+	return m.UserTrackedItemNotificationCountResetFunc(ctx, userIDs, itemID)
+}
+
+func (m *Database) UserTrackedItemRemove(ctx context.Context, userID string, itemID string) error {
+	if m.UserTrackedItemRemoveFunc == nil {
+		panic("Database.UserTrackedItemRemove called without UserTrackedItemRemoveFunc")
+	}
+	return m.UserTrackedItemRemoveFunc(ctx, userID, itemID)
+}
+
+func (m *Database) UserTrackedItemUpdate(ctx context.Context, userID string, ti model.TrackedItem) error {
+	if m.UserTrackedItemUpdateFunc == nil {
+		panic("Database.UserTrackedItemUpdate called without UserTrackedItemUpdateFunc")
+	}
+	return m.UserTrackedItemUpdateFunc(ctx, userID, ti)
+}
+
+func (m *Database) UserTrackedItemVariationSet(ctx context.Context, userID string, itemID primitive.ObjectID, variationID string, priceInitial int) error {
+	if m.UserTrackedItemVariationSetFunc == nil {
+		panic("Database.UserTrackedItemVariationSet called without UserTrackedItemVariationSetFunc")
+	}
+	return m.UserTrackedItemVariationSetFunc(ctx, userID, itemID, variationID, priceInitial)
+}
+
+func (m *Database) UserTrackedItemWebhookAdd(ctx context.Context, userID string, itemID string, wh model.Webhook, webhooksLimit int) error {
+	if m.UserTrackedItemWebhookAddFunc == nil {
+		panic("Database.UserTrackedItemWebhookAdd called without UserTrackedItemWebhookAddFunc")
+	}
+	return m.UserTrackedItemWebhookAddFunc(ctx, userID, itemID, wh, webhooksLimit)
+}
+
+func (m *Database) UserTrackedItemWebhookRemove(ctx context.Context, userID string, itemID string, webhookID string) error {
+	if m.UserTrackedItemWebhookRemoveFunc == nil {
+		panic("Database.UserTrackedItemWebhookRemove called without UserTrackedItemWebhookRemoveFunc")
+	}
+	return m.UserTrackedItemWebhookRemoveFunc(ctx, userID, itemID, webhookID)
+}
+
+func (m *Database) UserTrackedItemsAdd(ctx context.Context, userID string, tis []model.TrackedItem, trackedItemsLimit int) error {
+	if m.UserTrackedItemsAddFunc == nil {
+		panic("Database.UserTrackedItemsAdd called without UserTrackedItemsAddFunc")
+	}
+	return m.UserTrackedItemsAddFunc(ctx, userID, tis, trackedItemsLimit)
+}
+
+func (m *Database) UserTrackedItemsBulkUpdate(ctx context.Context, userID string, tius []database.TrackedItemUpdate) (int, error) {
+	if m.UserTrackedItemsBulkUpdateFunc == nil {
+		panic("Database.UserTrackedItemsBulkUpdate called without UserTrackedItemsBulkUpdateFunc")
+	}
+	return m.UserTrackedItemsBulkUpdateFunc(ctx, userID, tius)
+}
+
+func (m *Database) UserTrackedItemsQuotaSet(ctx context.Context, userID string, quota int) error {
+	if m.UserTrackedItemsQuotaSetFunc == nil {
+		panic("Database.UserTrackedItemsQuotaSet called without UserTrackedItemsQuotaSetFunc")
+	}
+	return m.UserTrackedItemsQuotaSetFunc(ctx, userID, quota)
+}
+
+func (m *Database) UsersDeviceFCMTokensFindByTrackedItem(ctx context.Context, itemID primitive.ObjectID) ([]model.User, error) {
+	if m.UsersDeviceFCMTokensFindByTrackedItemFunc == nil {
+		panic("Database.UsersDeviceFCMTokensFindByTrackedItem called without UsersDeviceFCMTokensFindByTrackedItemFunc")
+	}
+	return m.UsersDeviceFCMTokensFindByTrackedItemFunc(ctx, itemID)
+}
+
+func (m *Database) UsersDigestDueFind(ctx context.Context, frequency string, sentBefore time.Time, limit int64) ([]model.User, error) {
+	if m.UsersDigestDueFindFunc == nil {
+		panic("Database.UsersDigestDueFind called without UsersDigestDueFindFunc")
+	}
+	return m.UsersDigestDueFindFunc(ctx, frequency, sentBefore, limit)
+}
+
+func (m *Database) UsersTrackedItemAlertsExpire(ctx context.Context, now time.Time) (int, error) {
+	if m.UsersTrackedItemAlertsExpireFunc == nil {
+		panic("Database.UsersTrackedItemAlertsExpire called without UsersTrackedItemAlertsExpireFunc")
+	}
+	return m.UsersTrackedItemAlertsExpireFunc(ctx, now)
+}
+
+func (m *Database) UsersTrackedItemAlternativesSet(ctx context.Context, itemID primitive.ObjectID, alternatives []model.ItemAlternative) (int, error) {
+	if m.UsersTrackedItemAlternativesSetFunc == nil {
+		panic("Database.UsersTrackedItemAlternativesSet called without UsersTrackedItemAlternativesSetFunc")
+	}
+	return m.UsersTrackedItemAlternativesSetFunc(ctx, itemID, alternatives)
+}
+
+func (m *Database) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.WithTransactionFunc == nil {
+		panic("Database.WithTransaction called without WithTransactionFunc")
+	}
+	return m.WithTransactionFunc(ctx, fn)
+}
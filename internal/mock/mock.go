@@ -0,0 +1,6 @@
+// Package mock holds generated mocks of the dependencies of the server package, so handler and fetcher logic can
+// run without Mongo, Redis or network access. Unset func fields panic when their method is called.
+package mock
+
+//go:generate go run pricetracker/cmd/genmock -out database.go pricetracker/internal/server.Database
+//go:generate go run pricetracker/cmd/genmock -out client.go pricetracker/internal/server.Client
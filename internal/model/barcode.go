@@ -3,10 +3,10 @@ package model
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type Barcode struct {
-	ID            primitive.ObjectID `bson:"id"`
-	BarcodeNumber string             `bson:"barcode"`
-	ProductName   string             `bson:"product_name"`
-	Query1        string             `bson:"q1"`
-	Query2        string             `bson:"q2"`
-	Source        string             `bson:"source"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BarcodeNumber string             `bson:"barcode" json:"barcode"`
+	ProductName   string             `bson:"product_name" json:"product_name"`
+	Query1        string             `bson:"q1" json:"q1"`
+	Query2        string             `bson:"q2" json:"q2"`
+	Source        string             `bson:"source" json:"source"`
 }
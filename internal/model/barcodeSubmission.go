@@ -0,0 +1,23 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	BarcodeSubmissionPending  = "pending"
+	BarcodeSubmissionApproved = "approved"
+	BarcodeSubmissionRejected = "rejected"
+)
+
+type BarcodeSubmission struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Barcode Barcode            `bson:"barcode" json:"barcode"`
+	// ItemID is the Item the barcode was added with, approving the submission sets the barcode of the Item instead
+	// of storing it in the barcodes collection. It is zero for submissions of barcode search queries.
+	ItemID       primitive.ObjectID `bson:"item_id,omitempty" json:"item_id,omitempty"`
+	Status       string             `bson:"status" json:"status"`
+	RejectReason string             `bson:"reject_reason,omitempty" json:"reject_reason,omitempty"`
+	ReviewedBy   string             `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt   primitive.DateTime `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	CreatedAt    primitive.DateTime `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	BillingProviderMidtrans = "midtrans"
+)
+
+type BillingEvent struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	EventID           string             `bson:"event_id" json:"event_id"`
+	Provider          string             `bson:"provider" json:"provider"`
+	UserID            primitive.ObjectID `bson:"user_id,omitempty" json:"-"`
+	OrderID           string             `bson:"order_id" json:"order_id"`
+	TransactionID     string             `bson:"transaction_id" json:"transaction_id"`
+	TransactionStatus string             `bson:"transaction_status" json:"transaction_status"`
+	GrossAmount       string             `bson:"gross_amount" json:"gross_amount"`
+	Applied           bool               `bson:"applied" json:"applied"`
+	// Revoked is set on applied BillingEvents that revoked the premium of an earlier applied one of the order.
+	Revoked   bool               `bson:"revoked,omitempty" json:"revoked,omitempty"`
+	Tier      string             `bson:"tier,omitempty" json:"tier,omitempty"`
+	ExpiresAt primitive.DateTime `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Timestamp primitive.DateTime `bson:"ts" json:"ts"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	CurrencyIDR = "IDR"
+	CurrencyUSD = "USD"
+)
+
+// currencySymbols are the symbols prices are formatted with, other currencies are formatted with their code.
+var currencySymbols = map[string]string{
+	CurrencyIDR: "Rp. ",
+	CurrencyUSD: "$",
+	"EUR":       "€",
+	"GBP":       "£",
+	"AUD":       "AU $",
+	"CAD":       "C $",
+}
+
+// CurrencyOrDefault returns currency, or CurrencyIDR for Items stored before currencies were recorded.
+func CurrencyOrDefault(currency string) string {
+	if currency == "" {
+		return CurrencyIDR
+	}
+	return currency
+}
+
+// CurrencyMinorUnits returns the number of decimal digits of the minor unit of currency, prices are stored as
+// integers in the minor unit, e.g. cents for USD, IDR is stored in whole rupiahs.
+func CurrencyMinorUnits(currency string) int {
+	switch CurrencyOrDefault(currency) {
+	case CurrencyIDR, "JPY", "KRW", "VND":
+		return 0
+	}
+	return 2
+}
+
+// ParsePrice parses a decimal amount of currency, e.g. "12.34", into its minor unit.
+func ParsePrice(amount string, currency string) (int, error) {
+	units, fraction, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	digits := CurrencyMinorUnits(currency)
+	if len(fraction) > digits {
+		fraction = fraction[:digits]
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+	price, err := strconv.Atoi(units + fraction)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s amount: %#v", currency, amount)
+	}
+	return price, nil
+}
+
+// FormatPrice formats price, in the minor unit of currency, for notifications, e.g. "Rp. 15000" or "$12.34".
+func FormatPrice(price int, currency string) string {
+	currency = CurrencyOrDefault(currency)
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+	digits := CurrencyMinorUnits(currency)
+	if digits == 0 {
+		return symbol + strconv.Itoa(price)
+	}
+	sign := ""
+	if price < 0 {
+		sign, price = "-", -price
+	}
+	div := 1
+	for d := 0; d < digits; d++ {
+		div *= 10
+	}
+	return fmt.Sprintf("%s%s%d.%0*d", sign, symbol, price/div, digits, price%div)
+}
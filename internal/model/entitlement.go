@@ -0,0 +1,14 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	TierFree    = "free"
+	TierPremium = "premium"
+)
+
+type Entitlement struct {
+	Tier      string             `bson:"tier,omitempty" json:"tier"`
+	ExpiresAt primitive.DateTime `bson:"expires_at,omitempty" json:"expires_at"`
+	GrantedAt primitive.DateTime `bson:"granted_at,omitempty" json:"granted_at"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	FetchCycleKindFull     = "full"
+	FetchCycleKindPriority = "priority"
+	FetchCycleKindRefetch  = "refetch"
+)
+
+type FetchCycle struct {
+	ID            primitive.ObjectID              `bson:"_id,omitempty" json:"id"`
+	Kind          string                          `bson:"kind" json:"kind"`
+	StartedAt     primitive.DateTime              `bson:"started_at" json:"started_at"`
+	FinishedAt    primitive.DateTime              `bson:"finished_at" json:"finished_at"`
+	DurationMs    int64                           `bson:"duration_ms" json:"duration_ms"`
+	Filter        *FetchCycleFilter               `bson:"filter,omitempty" json:"filter,omitempty"`
+	Total         int                             `bson:"total" json:"total"`
+	Done          int                             `bson:"done" json:"done"`
+	Sites         map[string]*FetchCycleSiteStats `bson:"sites" json:"sites"`
+	Notifications int                             `bson:"notifications" json:"notifications"`
+	Error         string                          `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+type FetchCycleSiteStats struct {
+	Total         int   `bson:"total" json:"total"`
+	Attempted     int   `bson:"attempted" json:"attempted"`
+	Succeeded     int   `bson:"succeeded" json:"succeeded"`
+	Failed        int   `bson:"failed" json:"failed"`
+	ParseErrors   int   `bson:"parse_errors" json:"parse_errors"`
+	NotFound      int   `bson:"not_found" json:"not_found"`
+	Notifications int   `bson:"notifications" json:"notifications"`
+	DurationMs    int64 `bson:"duration_ms" json:"duration_ms"`
+}
+
+type FetchCycleFilter struct {
+	Site       string `bson:"site" json:"site"`
+	MerchantID string `bson:"merchant_id,omitempty" json:"merchant_id,omitempty"`
+}
+
+// Copy returns a copy of fc not sharing its Sites stats, so it can be read while fc is still being updated.
+func (fc FetchCycle) Copy() FetchCycle {
+	sites := make(map[string]*FetchCycleSiteStats, len(fc.Sites))
+	for site, stats := range fc.Sites {
+		statsCopy := *stats
+		sites[site] = &statsCopy
+	}
+	fc.Sites = sites
+	if fc.Filter != nil {
+		filter := *fc.Filter
+		fc.Filter = &filter
+	}
+	return fc
+}
@@ -2,19 +2,45 @@ package model
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"strings"
 	"time"
 )
 
+const (
+	ItemArchiveReasonNotFound = "not_found"
+	ItemArchiveReasonOrphaned = "orphaned"
+	ItemArchiveReasonAdmin    = "admin"
+)
+
+// itemCategoriesLimit is the most categories kept from the category breadcrumbs of an Item.
+const itemCategoriesLimit = 10
+
+// PriceVolatilityHalfLife is how long it takes for the PriceVolatility of an Item to halve without price changes.
+const PriceVolatilityHalfLife = 72 * time.Hour
+
 type Item struct {
-	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"-"`
-	Site                 string             `bson:"site" json:"site"`
-	MerchantID           string             `bson:"merchant_id" json:"merchant_id"`
-	ProductID            string             `bson:"product_id" json:"product_id"`
-	ParentID             string             `bson:"parent_id" json:"-"`
-	VariationID          string             `bson:"variation_id" json:"-"`
-	URL                  string             `bson:"url" json:"url"`
-	Name                 string             `bson:"name" json:"name"`
-	Price                int                `bson:"price" json:"price"`
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Site             string             `bson:"site" json:"site"`
+	MerchantID       string             `bson:"merchant_id" json:"merchant_id"`
+	MerchantName     string             `bson:"merchant_name,omitempty" json:"merchant_name"`
+	MerchantLocation string             `bson:"merchant_location,omitempty" json:"merchant_location"`
+	MerchantRating   float64            `bson:"merchant_rating,omitempty" json:"merchant_rating"`
+	// ProductID identifies the listing of the Item on its site regardless of the URL it was found through,
+	// see CanonicalProductID. ParentID is the product the listing is a variant of, its own ProductID when it is not
+	// a variant, and VariationID the variant of ParentID it is, empty when it is not a variant.
+	ProductID   string   `bson:"product_id" json:"product_id"`
+	ParentID    string   `bson:"parent_id" json:"-"`
+	VariationID string   `bson:"variation_id" json:"-"`
+	URL         string   `bson:"url" json:"url"`
+	Name        string   `bson:"name" json:"name"`
+	NameTokens  []string `bson:"name_tokens,omitempty" json:"-"`
+	Barcode     string   `bson:"barcode,omitempty" json:"barcode,omitempty"`
+	// Categories is the category breadcrumb of the Item on its site, from the top level category down.
+	Categories []string `bson:"categories,omitempty" json:"categories,omitempty"`
+	Price      int      `bson:"price" json:"price"`
+	// Currency is the currency of the prices of the Item, prices are in its minor unit, see CurrencyMinorUnits.
+	Currency             string             `bson:"currency,omitempty" json:"currency"`
 	PriceLastChangedAt   primitive.DateTime `bson:"price_last_changed_at" json:"price_last_changed_at"`
 	PriceHistoryPrevious int                `bson:"price_history_previous" json:"price_history_previous"`
 	PriceHistoryHighest  int                `bson:"price_history_highest" json:"price_history_highest"`
@@ -24,26 +50,153 @@ type Item struct {
 	Description          string             `bson:"description" json:"description"`
 	Rating               float64            `bson:"rating" json:"rating"`
 	Sold                 int                `bson:"sold" json:"sold"`
-	CreatedAt            primitive.DateTime `bson:"created_at" json:"-"`
-	UpdatedAt            primitive.DateTime `bson:"updated_at" json:"-"`
+	Variants             []ItemVariant      `bson:"variants,omitempty" json:"variants,omitempty"`
+	TrackerCount         int                `bson:"tracker_count" json:"tracker_count"`
+	PriceVolatility      float64            `bson:"price_volatility" json:"-"`
+	OrphanedAt           primitive.DateTime `bson:"orphaned_at,omitempty" json:"-"`
+	Archived             bool               `bson:"archived" json:"archived"`
+	ArchivedAt           primitive.DateTime `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+	ArchiveReason        string             `bson:"archive_reason,omitempty" json:"-"`
+	NotFoundCount        int                `bson:"not_found_count" json:"-"`
+	RecheckAt            primitive.DateTime `bson:"recheck_at,omitempty" json:"-"`
+	// NextFetchAt is when the Item is due in a full fetch cycle again, it is due right away when not set.
+	NextFetchAt primitive.DateTime `bson:"next_fetch_at,omitempty" json:"-"`
+	Delisted    bool               `bson:"delisted" json:"delisted"`
+	DelistedAt  primitive.DateTime `bson:"delisted_at,omitempty" json:"delisted_at,omitempty"`
+	CreatedAt   primitive.DateTime `bson:"created_at" json:"-"`
+	UpdatedAt   primitive.DateTime `bson:"updated_at" json:"-"`
+	// Version is incremented on every replacement of the Item, replacements of an outdated Version are rejected.
+	Version int64 `bson:"version" json:"-"`
+}
+
+// ItemVariant is one of the variants of a product. Blibli variants are tracked as their own Item, while Shopee
+// variants (models) are stored in the Variants of the Item and tracked through TrackedItem.VariationID.
+type ItemVariant struct {
+	VariationID string `bson:"variation_id" json:"variation_id"`
+	Name        string `bson:"name" json:"name"`
+	Price       int    `bson:"price" json:"price"`
+	Stock       int    `bson:"stock" json:"stock"`
+	URL         string `bson:"url" json:"url"`
 }
 
+// UpdateWith updates i with the fetched data of new, except its price. The price and the fields following it are
+// updated atomically in the database, see database.ItemPriceUpdate.
 func (i *Item) UpdateWith(new Item) {
-	if i.Price != new.Price {
-		i.PriceHistoryPrevious = i.Price
-		i.Price = new.Price
-		i.PriceLastChangedAt = primitive.NewDateTimeFromTime(time.Now())
-		if i.PriceHistoryHighest < new.Price {
-			i.PriceHistoryHighest = new.Price
-		}
-		if i.PriceHistoryLowest > new.Price {
-			i.PriceHistoryLowest = new.Price
-		}
+	if new.Currency != "" {
+		i.Currency = new.Currency
 	}
 	i.Stock = new.Stock
 	i.ImageURL = new.ImageURL
 	i.Description = new.Description
 	i.Rating = new.Rating
 	i.Sold = new.Sold
+	i.Variants = new.Variants
+	// Merchant information is not available from every site response, missing values keep the known ones.
+	if new.MerchantName != "" {
+		i.MerchantName = new.MerchantName
+	}
+	if new.MerchantLocation != "" {
+		i.MerchantLocation = new.MerchantLocation
+	}
+	if new.MerchantRating != 0 {
+		i.MerchantRating = new.MerchantRating
+	}
+	// Search results have no category breadcrumbs.
+	if len(new.Categories) > 0 {
+		i.Categories = new.Categories
+	}
+	i.NotFoundCount = 0
+	i.RecheckAt = 0
+	i.Delisted = false
+	i.DelistedAt = 0
+	i.Archived = false
+	i.ArchivedAt = 0
+	i.ArchiveReason = ""
 	i.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 }
+
+// PriceVolatilityAt returns the PriceVolatility decayed since the last price change, every price change adds 1.
+func (i Item) PriceVolatilityAt(now time.Time) float64 {
+	if i.PriceVolatility == 0 {
+		return 0
+	}
+	since := now.Sub(i.PriceLastChangedAt.Time())
+	return i.PriceVolatility * math.Pow(0.5, float64(since)/float64(PriceVolatilityHalfLife))
+}
+
+// CanonicalProductID returns productID the way it is stored, product IDs are unique per site and differ only in
+// case and spacing between the URLs of a listing.
+func CanonicalProductID(productID string) string {
+	return strings.ToUpper(strings.TrimSpace(productID))
+}
+
+// NormalizeKeys sets the ProductID, ParentID and VariationID of the Item to their canonical form.
+func (i *Item) NormalizeKeys() {
+	i.MerchantID = strings.TrimSpace(i.MerchantID)
+	i.ProductID = CanonicalProductID(i.ProductID)
+	i.ParentID = CanonicalProductID(i.ParentID)
+	i.VariationID = strings.TrimSpace(i.VariationID)
+	if i.ParentID == "" {
+		i.ParentID = i.ProductID
+	}
+}
+
+// ItemCategories returns the non-empty distinct names of a category breadcrumb in order, with extra spaces removed.
+func ItemCategories(names []string) []string {
+	var categories []string
+	seen := make(map[string]struct{})
+	for _, n := range names {
+		n = strings.Join(strings.Fields(n), " ")
+		if n == "" {
+			continue
+		}
+		if _, ok := seen[strings.ToLower(n)]; ok {
+			continue
+		}
+		seen[strings.ToLower(n)] = struct{}{}
+		categories = append(categories, n)
+		if len(categories) == itemCategoriesLimit {
+			break
+		}
+	}
+	return categories
+}
+
+// ShortName returns the Name of the Item shortened for logs and notifications.
+func (i Item) ShortName() string {
+	if len(i.Name) > 45 {
+		return i.Name[:45] + "..."
+	}
+	return i.Name
+}
+
+// Variant returns the variant of the Item with variationID.
+func (i Item) Variant(variationID string) (ItemVariant, bool) {
+	for _, v := range i.Variants {
+		if v.VariationID == variationID {
+			return v, true
+		}
+	}
+	return ItemVariant{}, false
+}
+
+// PriceAndStock returns the price and stock of the variant with variationID, or of the Item itself
+// when variationID is empty or the variant no longer exists.
+func (i Item) PriceAndStock(variationID string) (int, int) {
+	if variationID != "" {
+		if v, ok := i.Variant(variationID); ok {
+			return v.Price, v.Stock
+		}
+	}
+	return i.Price, i.Stock
+}
+
+// VariantPricesChanged reports whether the price of any variant of the Item differs in new.
+func (i Item) VariantPricesChanged(new Item) bool {
+	for _, v := range new.Variants {
+		if old, ok := i.Variant(v.VariationID); !ok || old.Price != v.Price {
+			return true
+		}
+	}
+	return false
+}
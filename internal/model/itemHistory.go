@@ -3,11 +3,50 @@ package model
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type ItemHistory struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
-	ItemID    primitive.ObjectID `bson:"item_id" json:"-"`
-	Price     int                `bson:"pr" json:"pr"`
-	Stock     int                `bson:"st" json:"st"`
-	Rating    float64            `bson:"rt" json:"rt"`
-	Sold      int                `bson:"sl" json:"sl"`
-	Timestamp primitive.DateTime `bson:"ts" json:"ts"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ItemID       primitive.ObjectID `bson:"item_id" json:"-"`
+	FetchCycleID primitive.ObjectID `bson:"fetch_cycle_id,omitempty" json:"-"`
+	Price        int                `bson:"pr" json:"pr"`
+	Currency     string             `bson:"cur,omitempty" json:"cur,omitempty"`
+	Stock        int                `bson:"st" json:"st"`
+	Rating       float64            `bson:"rt" json:"rt"`
+	Sold         int                `bson:"sl" json:"sl"`
+	Anomaly      string             `bson:"an,omitempty" json:"an,omitempty"`
+	FlashSale    bool               `bson:"fs,omitempty" json:"fs,omitempty"`
+	Timestamp    primitive.DateTime `bson:"ts" json:"ts"`
+}
+
+const (
+	// ItemHistoryAnomalySpike flags a price well above the recent baseline.
+	ItemHistoryAnomalySpike = "spike"
+	// ItemHistoryAnomalyFakeDrop flags a price drop that only returns a spiked price to the recent baseline.
+	ItemHistoryAnomalyFakeDrop = "fake_drop"
+)
+
+const (
+	ItemHistoryIntervalDay  = "day"
+	ItemHistoryIntervalWeek = "week"
+)
+
+type ItemHistoryBucket struct {
+	Start    primitive.DateTime `bson:"_id" json:"ts"`
+	PriceMin int                `bson:"pr_min" json:"pr_min"`
+	PriceMax int                `bson:"pr_max" json:"pr_max"`
+	PriceAvg float64            `bson:"pr_avg" json:"pr_avg"`
+	Count    int                `bson:"count" json:"count"`
+}
+
+// ItemPriceStats summarizes the price history of an Item, Percentile90Days is the share of the prices
+// in the last 90 days that are lower than the current price.
+type ItemPriceStats struct {
+	Current          int     `json:"current"`
+	AllTimeLow       int     `json:"all_time_low"`
+	AllTimeHigh      int     `json:"all_time_high"`
+	Average30Days    float64 `json:"average_30_days"`
+	Average90Days    float64 `json:"average_90_days"`
+	Low90Days        int     `json:"low_90_days"`
+	High90Days       int     `json:"high_90_days"`
+	Percentile90Days float64 `json:"percentile_90_days"`
+	LowestIn90Days   bool    `json:"lowest_in_90_days"`
+	LowestEver       bool    `json:"lowest_ever"`
 }
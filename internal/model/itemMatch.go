@@ -0,0 +1,64 @@
+package model
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/misc"
+	"strings"
+)
+
+const (
+	ItemMatchReasonBarcode = "barcode"
+	ItemMatchReasonName    = "name"
+)
+
+// itemNameTokensLimit is the maximum number of name tokens stored for an Item.
+const itemNameTokensLimit = 20
+
+// ItemMatch links an Item to an equivalent Item on another site, matches are stored in both directions.
+type ItemMatch struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ItemID        primitive.ObjectID `bson:"item_id" json:"-"`
+	MatchedItemID primitive.ObjectID `bson:"matched_item_id" json:"-"`
+	Reason        string             `bson:"reason" json:"reason"`
+	Similarity    float64            `bson:"similarity" json:"similarity"`
+	CreatedAt     primitive.DateTime `bson:"created_at" json:"-"`
+}
+
+// ItemNameTokens returns the distinct lower-cased words of name, words shorter than 2 characters are left out.
+func ItemNameTokens(name string) []string {
+	var tokens []string
+	seen := make(map[string]struct{})
+	for _, t := range strings.Fields(strings.ToLower(misc.CleanString(name))) {
+		if len(t) < 2 {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		tokens = append(tokens, t)
+		if len(tokens) == itemNameTokensLimit {
+			break
+		}
+	}
+	return tokens
+}
+
+// NameSimilarity returns the Dice coefficient of the name tokens of a and b, from 0 for no common tokens to 1
+// for the same tokens.
+func NameSimilarity(a []string, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	bTokens := make(map[string]struct{}, len(b))
+	for _, t := range b {
+		bTokens[t] = struct{}{}
+	}
+	var common int
+	for _, t := range a {
+		if _, ok := bTokens[t]; ok {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
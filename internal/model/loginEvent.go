@@ -0,0 +1,20 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	LoginEventTypeRegister       = "register"
+	LoginEventTypeLogin          = "login"
+	LoginEventTypeGoogleRegister = "google_register"
+	LoginEventTypeGoogleLogin    = "google_login"
+)
+
+type LoginEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"-"`
+	Type      string             `bson:"type" json:"type"`
+	DeviceID  string             `bson:"device_id" json:"device_id"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	Timestamp primitive.DateTime `bson:"ts" json:"ts"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type MerchantHistory struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Site          string             `bson:"site" json:"-"`
+	MerchantID    string             `bson:"merchant_id" json:"-"`
+	Name          string             `bson:"name" json:"name"`
+	Rating        float64            `bson:"rt" json:"rt"`
+	FollowerCount int                `bson:"fc" json:"fc"`
+	Timestamp     primitive.DateTime `bson:"ts" json:"ts"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// QueuedNotification is a notification held back during a User's quiet hours until DeliverAt.
+type QueuedNotification struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	UserID         primitive.ObjectID `bson:"user_id"`
+	ItemID         primitive.ObjectID `bson:"item_id,omitempty"`
+	Event          string             `bson:"event"`
+	Title          string             `bson:"title"`
+	Body           string             `bson:"body"`
+	Text           string             `bson:"text"`
+	FCMType        string             `bson:"fcm_type,omitempty"`
+	FCMAction      string             `bson:"fcm_action,omitempty"`
+	ReplacementURL string             `bson:"replacement_url,omitempty"`
+	ImageURL       string             `bson:"image_url,omitempty"`
+	OldPrice       string             `bson:"old_price,omitempty"`
+	NewPrice       string             `bson:"new_price,omitempty"`
+	DeepLink       string             `bson:"deep_link,omitempty"`
+	DeliverAt      primitive.DateTime `bson:"deliver_at"`
+	Attempts       int                `bson:"attempts,omitempty"`
+	CreatedAt      primitive.DateTime `bson:"created_at"`
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// QuietHoursDefaultTimezone is used when QuietHours has no Timezone.
+const QuietHoursDefaultTimezone = "Asia/Jakarta"
+
+type QuietHours struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// Start and End are local clock times formatted as "15:04", the window wraps past midnight when End is before Start.
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+	Timezone string `bson:"timezone,omitempty" json:"timezone"`
+}
+
+func (qh QuietHours) Validate() error {
+	if _, err := time.Parse("15:04", qh.Start); err != nil {
+		return errors.Errorf("invalid start: %#v", qh.Start)
+	}
+	if _, err := time.Parse("15:04", qh.End); err != nil {
+		return errors.Errorf("invalid end: %#v", qh.End)
+	}
+	if qh.Start == qh.End {
+		return errors.New("start and end must differ")
+	}
+	if qh.Timezone != "" {
+		if _, err := time.LoadLocation(qh.Timezone); err != nil {
+			return errors.Errorf("invalid timezone: %#v", qh.Timezone)
+		}
+	}
+	return nil
+}
+
+func (qh QuietHours) location() *time.Location {
+	tz := qh.Timezone
+	if tz == "" {
+		tz = QuietHoursDefaultTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+// Until returns when the quiet hours that now falls into end, ok is false when now is outside of quiet hours.
+func (qh QuietHours) Until(now time.Time) (until time.Time, ok bool) {
+	if !qh.Enabled {
+		return time.Time{}, false
+	}
+	start, errStart := time.Parse("15:04", qh.Start)
+	end, errEnd := time.Parse("15:04", qh.End)
+	if errStart != nil || errEnd != nil {
+		return time.Time{}, false
+	}
+	local := now.In(qh.location())
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	nowMin := local.Hour()*60 + local.Minute()
+
+	var inside bool
+	if startMin < endMin {
+		inside = nowMin >= startMin && nowMin < endMin
+	} else {
+		inside = nowMin >= startMin || nowMin < endMin
+	}
+	if !inside {
+		return time.Time{}, false
+	}
+	until = time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, local.Location())
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
@@ -0,0 +1,15 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// SearchHistory is a recent search of a User, either with a Query or a Barcode. Repeating a search only moves it
+// to the top of the recent searches by updating its Timestamp.
+type SearchHistory struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID primitive.ObjectID `bson:"user_id" json:"-"`
+	// Query is the query searched with, for Barcode searches the first query of the Barcode and Query2 the second.
+	Query     string             `bson:"query" json:"query,omitempty"`
+	Query2    string             `bson:"query2,omitempty" json:"query2,omitempty"`
+	Barcode   string             `bson:"barcode" json:"barcode,omitempty"`
+	Timestamp primitive.DateTime `bson:"ts" json:"ts"`
+}
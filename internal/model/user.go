@@ -1,16 +1,72 @@
 package model
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
 
 type User struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty"`
-	Name         string             `bson:"name"`
-	Email        string             `bson:"email"`
-	Password     []byte             `bson:"password"`
-	Devices      []Device           `bson:"devices"`
-	TrackedItems []TrackedItem      `bson:"tracked_items"`
-	CreatedAt    primitive.DateTime `bson:"created_at"`
-	UpdatedAt    primitive.DateTime `bson:"updated_at"`
+	ID           primitive.ObjectID      `bson:"_id,omitempty"`
+	Name         string                  `bson:"name"`
+	Email        string                  `bson:"email"`
+	Password     []byte                  `bson:"password"`
+	GoogleID     string                  `bson:"google_id,omitempty"`
+	Roles        []string                `bson:"roles"`
+	Devices      []Device                `bson:"devices"`
+	TrackedItems []TrackedItem           `bson:"tracked_items"`
+	Referral     Referral                `bson:"referral"`
+	Telegram     Telegram                `bson:"telegram"`
+	Notification NotificationPreferences `bson:"notification"`
+	// Locale is the i18n locale of the texts sent to the User, empty for i18n.DefaultLocale.
+	Locale string `bson:"locale,omitempty"`
+	// DigestSentAt is when the last price digest email was sent to the User.
+	DigestSentAt primitive.DateTime `bson:"digest_sent_at,omitempty"`
+	Entitlement  Entitlement        `bson:"entitlement"`
+	// TrackedItemsQuota replaces the tracked items limit of the tier when positive.
+	TrackedItemsQuota int                `bson:"tracked_items_quota,omitempty"`
+	CreatedAt         primitive.DateTime `bson:"created_at"`
+	UpdatedAt         primitive.DateTime `bson:"updated_at"`
+}
+
+type Telegram struct {
+	ChatID   int64              `bson:"chat_id,omitempty" json:"chat_id"`
+	LinkedAt primitive.DateTime `bson:"linked_at,omitempty" json:"linked_at"`
+	// LinkTokenHash is the SHA-256 of the pending one-time link token, sent to the bot by the User to link a chat.
+	LinkTokenHash      string             `bson:"link_token_hash,omitempty" json:"-"`
+	LinkTokenExpiresAt primitive.DateTime `bson:"link_token_expires_at,omitempty" json:"-"`
+}
+
+type NotificationPreferences struct {
+	FCMDisabled     bool       `bson:"fcm_disabled" json:"fcm_disabled"`
+	TelegramEnabled bool       `bson:"telegram_enabled" json:"telegram_enabled"`
+	QuietHours      QuietHours `bson:"quiet_hours" json:"quiet_hours"`
+	// Digest is how often price digest emails are sent to the User, empty when the User has not opted in.
+	Digest string `bson:"digest,omitempty" json:"digest"`
+}
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestPeriod returns the period covered by a price digest of frequency, or 0 when frequency is not a digest frequency.
+func DigestPeriod(frequency string) time.Duration {
+	switch frequency {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+type Referral struct {
+	Code       string             `bson:"code,omitempty" json:"code"`
+	ReferredBy primitive.ObjectID `bson:"referred_by,omitempty" json:"-"`
+	// RewardedAt is when the referrer of the User was rewarded, once the User tracked their first Item.
+	RewardedAt        primitive.DateTime `bson:"rewarded_at,omitempty" json:"-"`
+	Count             int                `bson:"count" json:"count"`
+	TrackedItemsBonus int                `bson:"tracked_items_bonus" json:"tracked_items_bonus"`
 }
 
 type Device struct {
@@ -28,13 +84,80 @@ type LoginToken struct {
 }
 
 type TrackedItem struct {
-	ItemID                 primitive.ObjectID `bson:"item_id" json:"-"`
-	PriceInitial           int                `bson:"price_initial" json:"price_initial"`
-	PriceLowerThreshold    int                `bson:"price_lower_threshold" json:"price_lower_threshold"`
-	NotificationEnabled    bool               `bson:"notification_enabled" json:"notification_enabled"`
+	ItemID                  primitive.ObjectID `bson:"item_id" json:"-"`
+	VariationID             string             `bson:"variation_id,omitempty" json:"variation_id,omitempty"`
+	PriceInitial            int                `bson:"price_initial" json:"price_initial"`
+	PriceLowerThreshold     int                `bson:"price_lower_threshold" json:"price_lower_threshold"`
+	PercentageDropThreshold int                `bson:"percentage_drop_threshold" json:"percentage_drop_threshold"`
+	NotificationEnabled     bool               `bson:"notification_enabled" json:"notification_enabled"`
+	NotifyOnRestock         bool               `bson:"notify_on_restock" json:"notify_on_restock"`
+	// RatingAlertBelow notifies the User when the rating of the Item falls below it, 0 when disabled.
+	RatingAlertBelow float64 `bson:"rating_alert_below,omitempty" json:"rating_alert_below,omitempty"`
+	// SoldJumpAlert notifies the User when the sold count of the Item grows by at least it between fetches,
+	// 0 when disabled.
+	SoldJumpAlert          int                `bson:"sold_jump_alert,omitempty" json:"sold_jump_alert,omitempty"`
 	NotificationCount      int                `bson:"notification_count" json:"-"`
 	NotificationCountTotal int                `bson:"notification_count_total" json:"-"`
 	LastNotifiedAt         primitive.DateTime `bson:"last_notified_at" json:"-"`
+	ActiveFrom             primitive.DateTime `bson:"active_from,omitempty" json:"active_from,omitempty"`
+	ActiveUntil            primitive.DateTime `bson:"active_until,omitempty" json:"active_until,omitempty"`
+	FetchIntervalMinutes   int                `bson:"fetch_interval_minutes,omitempty" json:"fetch_interval_minutes,omitempty"`
+	FetchIntervalUntil     primitive.DateTime `bson:"fetch_interval_until,omitempty" json:"fetch_interval_until,omitempty"`
+	Webhooks               []Webhook          `bson:"webhooks,omitempty" json:"webhooks"`
+	Alternatives           []ItemAlternative  `bson:"alternatives,omitempty" json:"alternatives,omitempty"`
 	CreatedAt              primitive.DateTime `bson:"created_at" json:"-"`
 	UpdatedAt              primitive.DateTime `bson:"updated_at" json:"-"`
 }
+
+type Webhook struct {
+	ID        string             `bson:"id" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+type ItemAlternative struct {
+	Site     string `bson:"site" json:"site"`
+	Name     string `bson:"name" json:"name"`
+	URL      string `bson:"url" json:"url"`
+	Price    int    `bson:"price" json:"price"`
+	Currency string `bson:"currency,omitempty" json:"currency,omitempty"`
+	ImageURL string `bson:"image_url" json:"image_url"`
+}
+
+// FetchIntervalActive reports whether the TrackedItem has a custom fetch interval that has not ended at now.
+func (ti TrackedItem) FetchIntervalActive(now time.Time) bool {
+	return ti.FetchIntervalMinutes > 0 && (ti.FetchIntervalUntil == 0 || ti.FetchIntervalUntil.Time().After(now))
+}
+
+// PriceDropReached reports whether price has reached either the absolute PriceLowerThreshold
+// or has dropped by at least PercentageDropThreshold percent from PriceInitial.
+func (ti TrackedItem) PriceDropReached(price int) bool {
+	if price <= ti.PriceLowerThreshold {
+		return true
+	}
+	return ti.PercentageDropThreshold > 0 && ti.PriceInitial > 0 &&
+		price <= ti.PriceInitial*(100-ti.PercentageDropThreshold)/100
+}
+
+// RatingDropReached reports whether the rating of the Item fell below RatingAlertBelow from oldRating to rating,
+// Items with no rating yet are not considered as having fallen.
+func (ti TrackedItem) RatingDropReached(oldRating float64, rating float64) bool {
+	return ti.RatingAlertBelow > 0 && rating > 0 && rating < ti.RatingAlertBelow && oldRating >= ti.RatingAlertBelow
+}
+
+// SoldJumpReached reports whether the sold count of the Item grew by at least SoldJumpAlert from oldSold to sold.
+func (ti TrackedItem) SoldJumpReached(oldSold int, sold int) bool {
+	return ti.SoldJumpAlert > 0 && sold-oldSold >= ti.SoldJumpAlert
+}
+
+// AlertActive reports whether now is inside the TrackedItem's optional alert window.
+func (ti TrackedItem) AlertActive(now time.Time) bool {
+	if ti.ActiveFrom != 0 && now.Before(ti.ActiveFrom.Time()) {
+		return false
+	}
+	if ti.ActiveUntil != 0 && !now.Before(ti.ActiveUntil.Time()) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	UserExportStatusPending = "pending"
+	UserExportStatusReady   = "ready"
+	UserExportStatusFailed  = "failed"
+
+	UserExportFormatJSON = "json"
+	UserExportFormatZIP  = "zip"
+)
+
+// UserExport is a takeout of a User's data, the archive itself is stored as a file with FileID.
+type UserExport struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	Format     string             `bson:"format" json:"format"`
+	Status     string             `bson:"status" json:"status"`
+	FileID     primitive.ObjectID `bson:"file_id,omitempty" json:"-"`
+	Size       int64              `bson:"size,omitempty" json:"size,omitempty"`
+	Error      string             `bson:"error,omitempty" json:"-"`
+	CreatedAt  primitive.DateTime `bson:"created_at" json:"created_at"`
+	FinishedAt primitive.DateTime `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	ExpiresAt  primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/client"
+	"pricetracker/internal/i18n"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"sort"
+	"strings"
+	"time"
+)
+
+const itemAlternativesLimit = 5
+
+// itemNotFoundDelistThreshold is how many consecutive fetches have to find an Item missing
+// before it is delisted and archived, so a site glitch does not delist it.
+const itemNotFoundDelistThreshold = 3
+
+// itemAlternativeMinSimilarity is the minimum share of the delisted Item's name tokens that
+// a search result must contain to be suggested as an alternative.
+const itemAlternativeMinSimilarity = 0.5
+
+// itemNotFound records that i was not found on its site, once it is missing for itemNotFoundDelistThreshold
+// consecutive fetches it is delisted and archived so it is not fetched anymore.
+func (s Server) itemNotFound(ctx context.Context, i model.Item) {
+	itemName := i.ShortName()
+	count, err := s.DB.ItemNotFoundCountInc(ctx, i.ID)
+	if err != nil {
+		s.Logger.Errorf("itemNotFound: Error incrementing not found count, err: %v", err)
+		return
+	}
+	if count < itemNotFoundDelistThreshold {
+		s.Logger.Infof("itemNotFound: Item: %s, ID: %s not found %d time(s) in a row", itemName, i.ID.Hex(), count)
+		return
+	}
+	i.NotFoundCount = count
+	s.itemDelisted(ctx, i)
+	if err = s.DB.ItemArchive(ctx, i.ID, model.ItemArchiveReasonNotFound, time.Now()); err != nil {
+		s.Logger.Errorf("itemNotFound: Error archiving Item: %s, ID: %s, err: %v", itemName, i.ID.Hex(), err)
+		return
+	}
+	s.Logger.Infof("itemNotFound: Archived Item: %s, ID: %s after %d fetch(es) not finding it", itemName, i.ID.Hex(), count)
+}
+
+// itemDelisted marks i as delisted, attaches alternatives found by searching the marketplaces
+// to every TrackedItem of i and notifies the Users tracking it.
+func (s Server) itemDelisted(ctx context.Context, i model.Item) {
+	itemName := i.ShortName()
+	if i.Delisted {
+		s.Logger.Debugf("itemDelisted: Item: %s, ID: %s is already delisted", itemName, i.ID.Hex())
+		return
+	}
+	s.Logger.Infof("itemDelisted: Marking Item: %s, ID: %s as delisted", itemName, i.ID.Hex())
+	i, err := s.DB.ItemUpdateFunc(ctx, i, func(i *model.Item) {
+		i.Delisted = true
+		i.DelistedAt = primitive.NewDateTimeFromTime(time.Now())
+	})
+	if err != nil {
+		s.Logger.Errorf("itemDelisted: Error updating Item, err: %v", err)
+		return
+	}
+
+	alts := s.findItemAlternatives(ctx, i)
+	s.Logger.Infof("itemDelisted: Found %d alternative(s) for Item: %s, ID: %s", len(alts), itemName, i.ID.Hex())
+	if len(alts) > 0 {
+		updated, err := s.DB.UsersTrackedItemAlternativesSet(ctx, i.ID, alts)
+		if err != nil {
+			s.Logger.Errorf("itemDelisted: Error setting TrackedItem alternatives, err: %v", err)
+		} else {
+			s.Logger.Debugf("itemDelisted: Set alternatives on %d User(s) for Item: %s, ID: %s", updated, itemName, i.ID.Hex())
+		}
+	}
+	s.notifyDelisted(ctx, i, alts)
+}
+
+func (s Server) notifyDelisted(ctx context.Context, i model.Item, alts []model.ItemAlternative) {
+	itemName := i.ShortName()
+	us, err := s.DB.UsersDeviceFCMTokensFindByTrackedItem(ctx, i.ID)
+	if err != nil {
+		s.Logger.Errorf("notifyDelisted: Error getting Users that tracked ItemID: %s, err: %v", i.ID.Hex(), err)
+		return
+	}
+	filter := func(ti model.TrackedItem) bool {
+		return ti.NotificationEnabled
+	}
+	rcp := s.notificationRecipients(us, filter)
+	if len(rcp.UserIDs) == 0 {
+		s.Logger.Debugf("notifyDelisted: No Users to be notified for Item: %s, ID: %s", itemName, i.ID.Hex())
+		return
+	}
+
+	msg := func(locale string) service.Message {
+		msg := service.Message{
+			Event: "delisted",
+			Title: i18n.T(locale, i18n.DelistedTitle),
+			Body:  i18n.T(locale, i18n.DelistedBody, itemName),
+			FCMData: client.FCMData{
+				ItemID:   i.ID.Hex(),
+				Type:     "delisted",
+				ImageURL: i.ImageURL,
+				DeepLink: fmt.Sprintf(itemDeepLinkFormat, i.ID.Hex()),
+			},
+			Alternatives: alts,
+		}
+		if len(alts) > 0 {
+			msg.Body = i18n.T(locale, i18n.DelistedReplacementBody, itemName, model.FormatPrice(alts[0].Price, alts[0].Currency))
+			msg.FCMData.Action = "track_replacement"
+			msg.FCMData.ReplacementURL = alts[0].URL
+		}
+		return msg
+	}
+	if !s.sendLocalized(ctx, i, s.localizedRecipients(us, filter), msg) {
+		s.Logger.Errorf("notifyDelisted: No notifications sent for %d User(s) for Item: %s, ID: %s",
+			len(rcp.UserIDs), itemName, i.ID.Hex())
+	}
+}
+
+// findItemAlternatives searches every marketplace with the name of i and returns the most similar results,
+// cheapest first among equally similar ones.
+func (s Server) findItemAlternatives(ctx context.Context, i model.Item) []model.ItemAlternative {
+	query := misc.CleanString(i.Name)
+	if words := strings.Fields(query); len(words) > 8 {
+		query = strings.Join(words[:8], " ")
+	}
+	if query == "" {
+		return nil
+	}
+
+	type candidate struct {
+		item       model.Item
+		similarity float64
+	}
+	var candidates []candidate
+	for _, sr := range s.Scrapers.All() {
+		is, err := sr.Search(ctx, query)
+		if err != nil {
+			s.Logger.Errorf("findItemAlternatives: Error searching %s with query: %#v, err: %v", sr.Site(), query, err)
+			continue
+		}
+		for _, si := range is {
+			if si.Site == i.Site && si.ProductID == i.ProductID {
+				continue
+			}
+			// Prices in other currencies can not be compared with the price of i.
+			if model.CurrencyOrDefault(si.Currency) != model.CurrencyOrDefault(i.Currency) {
+				continue
+			}
+			if sim := nameSimilarity(i.Name, si.Name); sim >= itemAlternativeMinSimilarity {
+				candidates = append(candidates, candidate{item: si, similarity: sim})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].similarity != candidates[b].similarity {
+			return candidates[a].similarity > candidates[b].similarity
+		}
+		return candidates[a].item.Price < candidates[b].item.Price
+	})
+	alts := make([]model.ItemAlternative, 0, misc.Min(len(candidates), itemAlternativesLimit))
+	for _, c := range candidates[:misc.Min(len(candidates), itemAlternativesLimit)] {
+		alts = append(alts, model.ItemAlternative{
+			Site:     c.item.Site,
+			Name:     c.item.Name,
+			URL:      c.item.URL,
+			Price:    c.item.Price,
+			Currency: c.item.Currency,
+			ImageURL: c.item.ImageURL,
+		})
+	}
+	return alts
+}
+
+// nameSimilarity returns the share of tokens in name that are also in other.
+func nameSimilarity(name string, other string) float64 {
+	tokens := strings.Fields(strings.ToLower(misc.CleanString(name)))
+	if len(tokens) == 0 {
+		return 0
+	}
+	otherTokens := make(map[string]struct{})
+	for _, t := range strings.Fields(strings.ToLower(misc.CleanString(other))) {
+		otherTokens[t] = struct{}{}
+	}
+	var matched int
+	for _, t := range tokens {
+		if _, ok := otherTokens[t]; ok {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(tokens))
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"pricetracker/internal/client"
+	"pricetracker/internal/database"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+const (
+	barcodeImportMaxBytes = 1 << 20
+	barcodeImportMaxRows  = 10000
+)
+
+func validateBarcode(b model.Barcode) error {
+	if len(b.BarcodeNumber) < 8 || len(b.BarcodeNumber) > 14 || !misc.IsNum(b.BarcodeNumber) {
+		return errors.Errorf("invalid barcode: %#v", b.BarcodeNumber)
+	}
+	if b.Query1 == "" {
+		return errors.New("q1 is empty")
+	}
+	if len(b.ProductName) > 200 || len(b.Query1) > 100 || len(b.Query2) > 100 || len(b.Source) > 100 {
+		return errors.New("field too long")
+	}
+	return nil
+}
+
+func (s Server) adminBarcodeGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bc := mux.Vars(r)["barcode"]
+		b, err := s.DB.BarcodeFind(r.Context(), bc)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Debugf("adminBarcodeGet: Barcode %#v not found", bc)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminBarcodeGet: Error finding Barcode %#v, err: %v", bc, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, b, http.StatusOK)
+	}
+}
+
+func (s Server) adminBarcodeCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b := model.Barcode{}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			s.Logger.Debugf("adminBarcodeCreate: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if err := validateBarcode(b); err != nil {
+			s.Logger.Debugf("adminBarcodeCreate: Invalid Barcode, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.DB.BarcodeInsert(r.Context(), b); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				s.Logger.Debugf("adminBarcodeCreate: Barcode %#v already exists", b.BarcodeNumber)
+				http.Error(w, "Barcode already exists", http.StatusConflict)
+				return
+			}
+			s.Logger.Errorf("adminBarcodeCreate: Error inserting Barcode, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminBarcodeCreate: Created Barcode %#v", b.BarcodeNumber)
+		s.writeJsonResponse(w, b, http.StatusCreated)
+	}
+}
+
+func (s Server) adminBarcodeUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b := model.Barcode{}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			s.Logger.Debugf("adminBarcodeUpdate: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		b.BarcodeNumber = mux.Vars(r)["barcode"]
+		if err := validateBarcode(b); err != nil {
+			s.Logger.Debugf("adminBarcodeUpdate: Invalid Barcode, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.DB.BarcodeUpdate(r.Context(), b); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("adminBarcodeUpdate: Barcode %#v not found", b.BarcodeNumber)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminBarcodeUpdate: Error updating Barcode, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminBarcodeUpdate: Updated Barcode %#v", b.BarcodeNumber)
+		s.writeJsonResponse(w, b, http.StatusOK)
+	}
+}
+
+func (s Server) adminBarcodeDelete() http.HandlerFunc {
+	type response struct {
+		Success bool `json:"success"`
+	}
+	openAPIRegister("adminBarcodeDelete", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		bc := mux.Vars(r)["barcode"]
+		if err := s.DB.BarcodeDelete(r.Context(), bc); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("adminBarcodeDelete: Barcode %#v not found", bc)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminBarcodeDelete: Error deleting Barcode %#v, err: %v", bc, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminBarcodeDelete: Deleted Barcode %#v", bc)
+		s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
+	}
+}
+
+// adminBarcodeImport upserts Barcodes from a CSV body with a header row, the barcode and q1 columns are required
+// and product_name, q2 and source are optional. Invalid rows are skipped and reported.
+func (s Server) adminBarcodeImport() http.HandlerFunc {
+	type rowError struct {
+		Line  int    `json:"line"`
+		Error string `json:"error"`
+	}
+	type response struct {
+		Inserted int        `json:"inserted"`
+		Updated  int        `json:"updated"`
+		Skipped  int        `json:"skipped"`
+		Errors   []rowError `json:"errors"`
+	}
+	openAPIRegister("adminBarcodeImport", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		cr := csv.NewReader(r.Body)
+		cr.TrimLeadingSpace = true
+		cr.FieldsPerRecord = -1
+
+		header, err := cr.Read()
+		if err != nil {
+			s.Logger.Debugf("adminBarcodeImport: Error reading CSV header, err: %v", err)
+			http.Error(w, "Invalid CSV header", http.StatusBadRequest)
+			return
+		}
+		columns := make(map[string]int, len(header))
+		for idx, h := range header {
+			columns[strings.ToLower(strings.TrimSpace(h))] = idx
+		}
+		for _, required := range []string{"barcode", "q1"} {
+			if _, ok := columns[required]; !ok {
+				s.Logger.Debugf("adminBarcodeImport: CSV header missing column: %s", required)
+				http.Error(w, fmt.Sprintf("CSV header missing column: %s", required), http.StatusBadRequest)
+				return
+			}
+		}
+		field := func(record []string, column string) string {
+			if idx, ok := columns[column]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		resp := response{Errors: []rowError{}}
+		barcodes := make(map[string]model.Barcode)
+		var order []string
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			line, _ := cr.FieldPos(0)
+			if err != nil {
+				var pe *csv.ParseError
+				if errors.As(err, &pe) {
+					resp.Skipped++
+					resp.Errors = append(resp.Errors, rowError{Line: pe.Line, Error: pe.Err.Error()})
+					continue
+				}
+				s.Logger.Debugf("adminBarcodeImport: Error reading CSV, err: %v", err)
+				http.Error(w, "Invalid CSV", http.StatusBadRequest)
+				return
+			}
+			b := model.Barcode{
+				BarcodeNumber: field(record, "barcode"),
+				ProductName:   field(record, "product_name"),
+				Query1:        field(record, "q1"),
+				Query2:        field(record, "q2"),
+				Source:        field(record, "source"),
+			}
+			if err = validateBarcode(b); err != nil {
+				resp.Skipped++
+				resp.Errors = append(resp.Errors, rowError{Line: line, Error: err.Error()})
+				continue
+			}
+			if _, ok := barcodes[b.BarcodeNumber]; !ok {
+				order = append(order, b.BarcodeNumber)
+			}
+			barcodes[b.BarcodeNumber] = b
+			if len(barcodes) > barcodeImportMaxRows {
+				s.Logger.Debugf("adminBarcodeImport: Too many rows, max: %d", barcodeImportMaxRows)
+				http.Error(w, fmt.Sprintf("Too many rows, max: %d", barcodeImportMaxRows), http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+
+		bs := make([]model.Barcode, 0, len(order))
+		for _, bc := range order {
+			bs = append(bs, barcodes[bc])
+		}
+		if resp.Inserted, resp.Updated, err = s.DB.BarcodesUpsert(r.Context(), bs); err != nil {
+			s.Logger.Errorf("adminBarcodeImport: Error upserting %d Barcode(s), err: %v", len(bs), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminBarcodeImport: Imported Barcodes, inserted: %d, updated: %d, skipped: %d",
+			resp.Inserted, resp.Updated, resp.Skipped)
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
+
+// barcodeLookup resolves an unknown barcode with the external barcode databases and stores it, it returns
+// an error wrapping client.ErrBarcodeNotFound when the barcode is invalid or unknown there too.
+func (s Server) barcodeLookup(ctx context.Context, barcode string) (model.Barcode, error) {
+	if len(barcode) < 8 || len(barcode) > 14 || !misc.IsNum(barcode) {
+		return model.Barcode{}, errors.Wrapf(client.ErrBarcodeNotFound, "invalid barcode: %#v", barcode)
+	}
+	b, err := s.Client.BarcodeLookup(ctx, barcode)
+	if err != nil {
+		return model.Barcode{}, errors.Wrapf(err, "error looking up barcode: %s", barcode)
+	}
+	if err = s.DB.BarcodeInsert(ctx, b); err != nil && !mongo.IsDuplicateKeyError(err) {
+		s.Logger.Errorf("barcodeLookup: Error inserting looked up Barcode: %+v, err: %v", b, err)
+	}
+	s.Logger.Infof("barcodeLookup: Resolved barcode %#v from %s, name: %#v", barcode, b.Source, b.ProductName)
+	return b, nil
+}
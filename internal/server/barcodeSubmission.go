@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/database"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strconv"
+)
+
+// barcodeSubmissionsPendingLimit is how many pending BarcodeSubmissions a User can have at the same time.
+const barcodeSubmissionsPendingLimit = 20
+
+func (s Server) barcodeSubmit() http.HandlerFunc {
+	type request struct {
+		Barcode     string `json:"barcode"`
+		ProductName string `json:"product_name"`
+		Query1      string `json:"q1"`
+		Query2      string `json:"q2"`
+	}
+	type response struct {
+		SubmissionID string `json:"submission_id"`
+		Status       string `json:"status"`
+	}
+	openAPIRegister("barcodeSubmit", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("barcodeSubmit: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("barcodeSubmit: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		b := model.Barcode{
+			BarcodeNumber: req.Barcode,
+			ProductName:   misc.CleanString(req.ProductName),
+			Query1:        misc.CleanString(req.Query1),
+			Query2:        misc.CleanString(req.Query2),
+			Source:        "user:" + uc.user.ID.Hex(),
+		}
+		if err = validateBarcode(b); err != nil {
+			s.Logger.Debugf("barcodeSubmit: Invalid Barcode, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pending, err := s.DB.BarcodeSubmissionsPendingCount(r.Context(), uc.user.ID)
+		if err != nil {
+			s.Logger.Errorf("barcodeSubmit: Error counting pending BarcodeSubmissions, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if pending >= barcodeSubmissionsPendingLimit {
+			s.Logger.Debugf("barcodeSubmit: Pending BarcodeSubmissions limit reached for User with ID: %s", uc.user.ID.Hex())
+			http.Error(w, "Too many pending submissions", http.StatusTooManyRequests)
+			return
+		}
+
+		id, err := s.DB.BarcodeSubmissionInsert(r.Context(), model.BarcodeSubmission{UserID: uc.user.ID, Barcode: b})
+		if err != nil {
+			s.Logger.Errorf("barcodeSubmit: Error inserting BarcodeSubmission, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("barcodeSubmit: User with ID: %s submitted Barcode %#v, SubmissionID: %s",
+			uc.user.ID.Hex(), b.BarcodeNumber, id)
+		s.writeJsonResponse(w, response{SubmissionID: id, Status: model.BarcodeSubmissionPending}, http.StatusAccepted)
+	}
+}
+
+// itemBarcodeSubmit submits barcode as the barcode of i for moderation, as an unverified barcode would match i with
+// any other product given the same barcode. It returns the ID of the BarcodeSubmission, or an empty string when it
+// was not submitted, which does not fail adding the Item.
+func (s Server) itemBarcodeSubmit(ctx context.Context, u model.User, i model.Item, barcode string) string {
+	pending, err := s.DB.BarcodeSubmissionsPendingCount(ctx, u.ID)
+	if err != nil {
+		s.Logger.Errorf("itemBarcodeSubmit: Error counting pending BarcodeSubmissions, err: %v", err)
+		return ""
+	}
+	if pending >= barcodeSubmissionsPendingLimit {
+		s.Logger.Debugf("itemBarcodeSubmit: Pending BarcodeSubmissions limit reached for User with ID: %s", u.ID.Hex())
+		return ""
+	}
+	bs := model.BarcodeSubmission{
+		UserID: u.ID,
+		Barcode: model.Barcode{
+			BarcodeNumber: barcode,
+			ProductName:   i.Name,
+			Source:        "user:" + u.ID.Hex(),
+		},
+		ItemID: i.ID,
+	}
+	id, err := s.DB.BarcodeSubmissionInsert(ctx, bs)
+	if err != nil {
+		s.Logger.Errorf("itemBarcodeSubmit: Error inserting BarcodeSubmission, err: %v", err)
+		return ""
+	}
+	s.Logger.Infof("itemBarcodeSubmit: User with ID: %s submitted Barcode %#v for Item with ID: %s, SubmissionID: %s",
+		u.ID.Hex(), barcode, i.ID.Hex(), id)
+	return id
+}
+
+// itemBarcodeApprove sets the barcode of the approved BarcodeSubmission bs on its Item unless the Item already has
+// one, and matches the Item again with the barcode.
+func (s Server) itemBarcodeApprove(ctx context.Context, bs model.BarcodeSubmission) error {
+	i, err := s.DB.ItemFindOne(ctx, bs.ItemID.Hex())
+	if err != nil {
+		return errors.Wrapf(err, "error finding Item with ID: %s", bs.ItemID.Hex())
+	}
+	if i.Barcode != "" {
+		return nil
+	}
+	var barcodeSet bool
+	i, err = s.DB.ItemUpdateFunc(ctx, i, func(i *model.Item) {
+		barcodeSet = i.Barcode == ""
+		if barcodeSet {
+			i.Barcode = bs.Barcode.BarcodeNumber
+		}
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error setting barcode of Item with ID: %s", bs.ItemID.Hex())
+	}
+	if barcodeSet {
+		if _, err = s.Items.Match(ctx, i); err != nil {
+			s.Logger.Errorf("itemBarcodeApprove: Error matching Item with ID: %s, err: %v", i.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+func (s Server) moderationBarcodeSubmissions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = model.BarcodeSubmissionPending
+		case model.BarcodeSubmissionPending, model.BarcodeSubmissionApproved, model.BarcodeSubmissionRejected:
+		default:
+			s.Logger.Debugf("moderationBarcodeSubmissions: Invalid status: %#v", status)
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+		limit := int64(50)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsedLimit, err := strconv.ParseInt(l, 10, 64)
+			if err != nil || parsedLimit <= 0 {
+				s.Logger.Debugf("moderationBarcodeSubmissions: Invalid limit: %#v, err: %v", l, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			limit = misc.Min(parsedLimit, 200)
+		}
+
+		bss, err := s.DB.BarcodeSubmissionsFindByStatus(r.Context(), status, limit)
+		if err != nil {
+			s.Logger.Errorf("moderationBarcodeSubmissions: Error finding BarcodeSubmissions, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if bss == nil {
+			bss = []model.BarcodeSubmission{}
+		}
+		s.writeJsonResponse(w, bss, http.StatusOK)
+	}
+}
+
+// moderationBarcodeSubmissionReview approves or rejects a pending BarcodeSubmission, approved submissions are written
+// to the barcodes collection replacing any existing entry, or set on their Item when they were added with one.
+func (s Server) moderationBarcodeSubmissionReview() http.HandlerFunc {
+	type request struct {
+		Approve bool   `json:"approve"`
+		Reason  string `json:"reason"`
+	}
+	type response struct {
+		SubmissionID string `json:"submission_id"`
+		Status       string `json:"status"`
+	}
+	openAPIRegister("moderationBarcodeSubmissionReview", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("moderationBarcodeSubmissionReview: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		id := mux.Vars(r)["submissionID"]
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("moderationBarcodeSubmissionReview: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		bs, err := s.DB.BarcodeSubmissionFind(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("moderationBarcodeSubmissionReview: BarcodeSubmission with ID: %s not found", id)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("moderationBarcodeSubmissionReview: Error finding BarcodeSubmission with ID: %s, err: %v", id, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if bs.Status != model.BarcodeSubmissionPending {
+			s.Logger.Debugf("moderationBarcodeSubmissionReview: BarcodeSubmission with ID: %s already %s", id, bs.Status)
+			http.Error(w, "Submission already reviewed", http.StatusConflict)
+			return
+		}
+
+		status := model.BarcodeSubmissionRejected
+		if req.Approve {
+			status = model.BarcodeSubmissionApproved
+		}
+		err = s.DB.WithTransaction(r.Context(), func(ctx context.Context) error {
+			// The status is set from pending first, so only one of concurrent reviews stores the Barcode.
+			if err := s.DB.BarcodeSubmissionReview(ctx, id, status, uc.user.ID.Hex(), req.Reason); err != nil {
+				return err
+			}
+			if !req.Approve {
+				return nil
+			}
+			if !bs.ItemID.IsZero() {
+				return s.itemBarcodeApprove(ctx, bs)
+			}
+			_, _, err := s.DB.BarcodesUpsert(ctx, []model.Barcode{bs.Barcode})
+			return errors.Wrap(err, "error storing approved Barcode")
+		})
+		if err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("moderationBarcodeSubmissionReview: BarcodeSubmission with ID: %s reviewed concurrently", id)
+				http.Error(w, "Submission already reviewed", http.StatusConflict)
+				return
+			}
+			s.Logger.Errorf("moderationBarcodeSubmissionReview: Error reviewing BarcodeSubmission with ID: %s, err: %v", id, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("moderationBarcodeSubmissionReview: BarcodeSubmission with ID: %s %s by User with ID: %s",
+			id, status, uc.user.ID.Hex())
+		s.writeJsonResponse(w, response{SubmissionID: id, Status: status}, http.StatusOK)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	applogger "pricetracker/internal/logger"
+	"pricetracker/internal/model"
+	"testing"
+)
+
+func benchServer(b *testing.B) Server {
+	key, err := jwk.FromRaw([]byte("benchmark-auth-secret-key-000000"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return Server{AuthSecretKey: key, Logger: applogger.New(applogger.LevelOff, io.Discard)}
+}
+
+// BenchmarkAuthMwLoginToken measures the login token checks authMw does on every authenticated request.
+func BenchmarkAuthMwLoginToken(b *testing.B) {
+	s := benchServer(b)
+	lt, _, tokenHash, err := s.createLoginTokenAndHash(primitive.NewObjectID().Hex(), "bench-device")
+	if err != nil {
+		b.Fatal(err)
+	}
+	d := model.Device{DeviceID: "bench-device", LoginToken: model.LoginToken{Token: tokenHash}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err = s.loginTokenParse(lt); err != nil {
+			b.Fatal(err)
+		}
+		if err = loginTokenCompare(d, lt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNotificationRecipients measures collecting the recipients of a popular Item tracked by 1000 Users.
+func BenchmarkNotificationRecipients(b *testing.B) {
+	s := benchServer(b)
+	itemID := primitive.NewObjectID()
+	us := make([]model.User, 1000)
+	for idx := range us {
+		us[idx] = model.User{
+			ID: primitive.NewObjectID(),
+			Devices: []model.Device{
+				{DeviceID: "phone", FCMToken: fmt.Sprintf("fcm-token-%d-phone", idx)},
+				{DeviceID: "tablet", FCMToken: fmt.Sprintf("fcm-token-%d-tablet", idx)},
+			},
+			TrackedItems: []model.TrackedItem{{
+				ItemID:              itemID,
+				PriceInitial:        100000,
+				PriceLowerThreshold: 90000 + idx%20*1000,
+				NotificationEnabled: idx%10 != 0,
+			}},
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		rcp := s.notificationRecipients(us, func(ti model.TrackedItem) bool {
+			return ti.NotificationEnabled && ti.PriceDropReached(95000)
+		})
+		if len(rcp.UserIDs) == 0 {
+			b.Fatal("no recipients")
+		}
+	}
+}
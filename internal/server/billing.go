@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/client"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// premiumOrderPrefix prefixes order IDs of premium purchases, which are formatted as PREMIUM-<userID>-<suffix>.
+const premiumOrderPrefix = "PREMIUM"
+
+func premiumOrderUserID(orderID string) (primitive.ObjectID, error) {
+	parts := strings.SplitN(orderID, "-", 3)
+	if len(parts) != 3 || parts[0] != premiumOrderPrefix {
+		return primitive.NilObjectID, errors.Errorf("invalid premium order ID: %s", orderID)
+	}
+	return primitive.ObjectIDFromHex(parts[1])
+}
+
+// renewedEntitlement returns the premium Entitlement of u extended by days, renewals extend from the
+// current expiry so unused days are kept.
+func renewedEntitlement(u model.User, days int, now time.Time) model.Entitlement {
+	base := now
+	if effectiveTier(u) == model.TierPremium {
+		if u.Entitlement.ExpiresAt == 0 {
+			return u.Entitlement
+		}
+		base = u.Entitlement.ExpiresAt.Time()
+	}
+	return model.Entitlement{
+		Tier:      model.TierPremium,
+		ExpiresAt: primitive.NewDateTimeFromTime(base.AddDate(0, 0, days)),
+		GrantedAt: primitive.NewDateTimeFromTime(now),
+	}
+}
+
+// revokedEntitlement returns the Entitlement of u without the days of a reversed premium purchase, reverting it to
+// the free tier when no premium days are left. Premium without expiry, which is only granted by admins, is kept.
+func revokedEntitlement(u model.User, days int, now time.Time) model.Entitlement {
+	e := u.Entitlement
+	if e.Tier != model.TierPremium || e.ExpiresAt == 0 {
+		return e
+	}
+	expiresAt := e.ExpiresAt.Time().AddDate(0, 0, -days)
+	if !expiresAt.After(now) {
+		return model.Entitlement{Tier: model.TierFree, GrantedAt: primitive.NewDateTimeFromTime(now)}
+	}
+	e.ExpiresAt = primitive.NewDateTimeFromTime(expiresAt)
+	return e
+}
+
+// billingMidtransNotification activates premium for paid orders of at least PremiumPrice and revokes it again when
+// the payment of an activated order is reversed.
+func (s Server) billingMidtransNotification() http.HandlerFunc {
+	type response struct {
+		Status string `json:"status"`
+	}
+	openAPIRegister("billingMidtransNotification", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.Client.MidtransEnabled() {
+			s.Logger.Debugf("billingMidtransNotification: Midtrans is not configured")
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		n := client.MidtransNotification{}
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			s.Logger.Debugf("billingMidtransNotification: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if !s.Client.MidtransVerifyNotification(n) {
+			s.Logger.Infof("billingMidtransNotification: Invalid signature for OrderID: %s from %s", n.OrderID, requestIP(r))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		// A transaction goes through several statuses and a capture can be reviewed from challenge to accept, every
+		// status of an order is processed once.
+		eventID := n.OrderID + ":" + n.TransactionStatus
+		if n.FraudStatus != "" {
+			eventID += ":" + n.FraudStatus
+		}
+		be := model.BillingEvent{
+			EventID:           eventID,
+			Provider:          model.BillingProviderMidtrans,
+			OrderID:           n.OrderID,
+			TransactionID:     n.TransactionID,
+			TransactionStatus: n.TransactionStatus,
+			GrossAmount:       n.GrossAmount,
+		}
+		userID, err := premiumOrderUserID(n.OrderID)
+		if err != nil {
+			s.Logger.Errorf("billingMidtransNotification: Error getting UserID from OrderID, err: %v", err)
+		} else {
+			be.UserID = userID
+		}
+
+		beID, err := s.DB.BillingEventInsert(r.Context(), be)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				s.Logger.Infof("billingMidtransNotification: Duplicate BillingEvent with EventID: %s", be.EventID)
+				s.writeJsonResponse(w, response{Status: "duplicate"}, http.StatusOK)
+				return
+			}
+			s.Logger.Errorf("billingMidtransNotification: Error inserting BillingEvent, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if (!n.Paid() && !n.Reversed()) || be.UserID.IsZero() {
+			s.Logger.Infof("billingMidtransNotification: BillingEvent with EventID: %s recorded without activation", be.EventID)
+			s.writeJsonResponse(w, response{Status: "recorded"}, http.StatusOK)
+			return
+		}
+		if n.Paid() {
+			amount, err := n.GrossAmountIDR()
+			if err != nil || amount < s.PremiumPrice {
+				s.Logger.Errorf("billingMidtransNotification: Underpaid OrderID: %s, gross_amount: %s, price: %d, err: %v",
+					n.OrderID, n.GrossAmount, s.PremiumPrice, err)
+				s.writeJsonResponse(w, response{Status: "recorded"}, http.StatusOK)
+				return
+			}
+		}
+
+		bes, err := s.DB.BillingEventsFindByOrder(r.Context(), model.BillingProviderMidtrans, n.OrderID)
+		if err != nil {
+			s.Logger.Errorf("billingMidtransNotification: Error finding BillingEvents for OrderID: %s, err: %v", n.OrderID, err)
+			s.billingEventRollback(r, beID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		var activated, revoked bool
+		for _, obe := range bes {
+			if obe.ID.Hex() == beID || !obe.Applied {
+				continue
+			}
+			if obe.Revoked {
+				revoked = true
+			} else {
+				activated = true
+			}
+		}
+		// An order activates premium once, and a reversal only revokes an activated order once.
+		if (n.Paid() && activated) || (n.Reversed() && (!activated || revoked)) {
+			s.Logger.Infof("billingMidtransNotification: BillingEvent with EventID: %s recorded without changes, "+
+				"order activated: %v, revoked: %v", be.EventID, activated, revoked)
+			s.writeJsonResponse(w, response{Status: "recorded"}, http.StatusOK)
+			return
+		}
+
+		u, err := s.DB.UserFindByID(r.Context(), be.UserID.Hex())
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Errorf("billingMidtransNotification: User with ID: %s not found for OrderID: %s",
+					be.UserID.Hex(), n.OrderID)
+				s.writeJsonResponse(w, response{Status: "recorded"}, http.StatusOK)
+				return
+			}
+			s.Logger.Errorf("billingMidtransNotification: Error finding User with ID: %s, err: %v", be.UserID.Hex(), err)
+			s.billingEventRollback(r, beID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		status := "activated"
+		e := renewedEntitlement(u, s.PremiumDurationDays, time.Now())
+		if n.Reversed() {
+			status = "revoked"
+			e = revokedEntitlement(u, s.PremiumDurationDays, time.Now())
+		}
+		if err = s.DB.UserEntitlementSet(r.Context(), u.ID.Hex(), e); err != nil {
+			s.Logger.Errorf("billingMidtransNotification: Error setting Entitlement on User with ID: %s, err: %v", u.ID.Hex(), err)
+			s.billingEventRollback(r, beID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		be.Applied = true
+		be.Revoked = n.Reversed()
+		be.Tier = e.Tier
+		be.ExpiresAt = e.ExpiresAt
+		if err = s.DB.BillingEventUpdate(r.Context(), beID, be); err != nil {
+			s.Logger.Errorf("billingMidtransNotification: Error updating BillingEvent with ID: %s, err: %v", beID, err)
+		}
+		s.Logger.Infof("billingMidtransNotification: Entitlement %s, Tier: %s for User with ID: %s until %v, OrderID: %s",
+			status, e.Tier, u.ID.Hex(), e.ExpiresAt.Time(), n.OrderID)
+		s.writeJsonResponse(w, response{Status: status}, http.StatusOK)
+	}
+}
+
+// billingEventRollback removes a BillingEvent that could not be applied, so the provider's retry is processed again.
+func (s Server) billingEventRollback(r *http.Request, beID string) {
+	if err := s.DB.BillingEventDelete(r.Context(), beID); err != nil {
+		s.Logger.Errorf("billingEventRollback: Error deleting BillingEvent with ID: %s, err: %v", beID, err)
+	}
+}
+
+func (s Server) adminBillingEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := mux.Vars(r)["userID"]
+		limit := int64(50)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsedLimit, err := strconv.ParseInt(l, 10, 64)
+			if err != nil || parsedLimit <= 0 {
+				s.Logger.Debugf("adminBillingEvents: Invalid limit: %#v, err: %v", l, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			limit = misc.Min(parsedLimit, 100)
+		}
+
+		bes, err := s.DB.BillingEventsFindByUser(r.Context(), userID, limit)
+		if err != nil {
+			if errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("adminBillingEvents: Invalid UserID: %s", userID)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminBillingEvents: Error finding BillingEvents for UserID: %s, err: %v", userID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if bes == nil {
+			bes = []model.BillingEvent{}
+		}
+		s.writeJsonResponse(w, bes, http.StatusOK)
+	}
+}
@@ -0,0 +1,302 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/client"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+	"testing"
+	"time"
+)
+
+// TestRenewedEntitlement checks that premium purchases extend running premium from its expiry and start new premium
+// from now.
+func TestRenewedEntitlement(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Premium expiring in the future is renewed from its expiry, effectiveTier compares it to the real time.
+	runningUntil := time.Now().AddDate(0, 0, 10)
+	at := func(tm time.Time) primitive.DateTime { return primitive.NewDateTimeFromTime(tm) }
+	tests := []struct {
+		name          string
+		entitlement   model.Entitlement
+		wantExpiresAt primitive.DateTime
+	}{
+		{name: "free", entitlement: model.Entitlement{Tier: model.TierFree}, wantExpiresAt: at(now.AddDate(0, 0, 30))},
+		{
+			name:          "expired premium",
+			entitlement:   model.Entitlement{Tier: model.TierPremium, ExpiresAt: at(now.AddDate(0, 0, -5))},
+			wantExpiresAt: at(now.AddDate(0, 0, 30)),
+		},
+		{
+			name:          "running premium",
+			entitlement:   model.Entitlement{Tier: model.TierPremium, ExpiresAt: at(runningUntil)},
+			wantExpiresAt: at(runningUntil.AddDate(0, 0, 30)),
+		},
+		{name: "premium without expiry", entitlement: model.Entitlement{Tier: model.TierPremium}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := server.RenewedEntitlement(model.User{Entitlement: tt.entitlement}, 30, now)
+			if got.Tier != model.TierPremium {
+				t.Errorf("got Tier %s, want %s", got.Tier, model.TierPremium)
+			}
+			if got.ExpiresAt != tt.wantExpiresAt {
+				t.Errorf("got ExpiresAt %v, want %v", got.ExpiresAt.Time(), tt.wantExpiresAt.Time())
+			}
+		})
+	}
+}
+
+// TestRevokedEntitlement checks that reversed premium purchases take their days off the expiry, falling back to the
+// free tier when no premium days are left, and leave premium without expiry alone.
+func TestRevokedEntitlement(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(tm time.Time) primitive.DateTime { return primitive.NewDateTimeFromTime(tm) }
+	tests := []struct {
+		name          string
+		entitlement   model.Entitlement
+		wantTier      string
+		wantExpiresAt primitive.DateTime
+	}{
+		{
+			name:          "renewed premium",
+			entitlement:   model.Entitlement{Tier: model.TierPremium, ExpiresAt: at(now.AddDate(0, 0, 50))},
+			wantTier:      model.TierPremium,
+			wantExpiresAt: at(now.AddDate(0, 0, 20)),
+		},
+		{
+			name:        "premium of the reversed purchase",
+			entitlement: model.Entitlement{Tier: model.TierPremium, ExpiresAt: at(now.AddDate(0, 0, 30))},
+			wantTier:    model.TierFree,
+		},
+		{
+			name:        "partly used premium",
+			entitlement: model.Entitlement{Tier: model.TierPremium, ExpiresAt: at(now.AddDate(0, 0, 10))},
+			wantTier:    model.TierFree,
+		},
+		{
+			name:        "premium without expiry",
+			entitlement: model.Entitlement{Tier: model.TierPremium},
+			wantTier:    model.TierPremium,
+		},
+		{name: "free", entitlement: model.Entitlement{Tier: model.TierFree}, wantTier: model.TierFree},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := server.RevokedEntitlement(model.User{Entitlement: tt.entitlement}, 30, now)
+			if got.Tier != tt.wantTier || got.ExpiresAt != tt.wantExpiresAt {
+				t.Errorf("got Tier %s expiring at %v, want %s expiring at %v",
+					got.Tier, got.ExpiresAt.Time(), tt.wantTier, tt.wantExpiresAt.Time())
+			}
+		})
+	}
+}
+
+// TestBillingMidtransNotification sends sequences of Midtrans notifications for one premium order and checks the
+// response to each, and the Entitlement of the User after the last one.
+func TestBillingMidtransNotification(t *testing.T) {
+	const serverKey, price = "test-server-key", 150000
+	type notification struct {
+		status      string
+		fraudStatus string
+		grossAmount string
+		badKey      bool
+		wantCode    int
+		wantStatus  string
+	}
+	paid := func(status string) notification {
+		return notification{status: status, grossAmount: "150000.00", wantCode: http.StatusOK, wantStatus: "activated"}
+	}
+	recorded := func(status string, fraudStatus string) notification {
+		return notification{status: status, fraudStatus: fraudStatus, grossAmount: "150000.00", wantCode: http.StatusOK,
+			wantStatus: "recorded"}
+	}
+	tests := []struct {
+		name          string
+		notifications []notification
+		wantTier      string
+	}{
+		{
+			name:          "settlement",
+			notifications: []notification{paid(client.MidtransTransactionStatusSettlement)},
+			wantTier:      model.TierPremium,
+		},
+		{
+			name: "invalid signature",
+			notifications: []notification{{status: client.MidtransTransactionStatusSettlement, grossAmount: "150000.00",
+				badKey: true, wantCode: http.StatusUnauthorized}},
+			wantTier: model.TierFree,
+		},
+		{
+			name: "underpaid",
+			notifications: []notification{{status: client.MidtransTransactionStatusSettlement, grossAmount: "149000.00",
+				wantCode: http.StatusOK, wantStatus: "recorded"}},
+			wantTier: model.TierFree,
+		},
+		{
+			name: "duplicate settlement",
+			notifications: []notification{
+				paid(client.MidtransTransactionStatusSettlement),
+				{status: client.MidtransTransactionStatusSettlement, grossAmount: "150000.00", wantCode: http.StatusOK,
+					wantStatus: "duplicate"},
+			},
+			wantTier: model.TierPremium,
+		},
+		{
+			name: "capture reviewed from challenge to accept, then settlement",
+			notifications: []notification{
+				recorded(client.MidtransTransactionStatusCapture, "challenge"),
+				func() notification {
+					n := paid(client.MidtransTransactionStatusCapture)
+					n.fraudStatus = client.MidtransFraudStatusAccept
+					return n
+				}(),
+				recorded(client.MidtransTransactionStatusSettlement, ""),
+			},
+			wantTier: model.TierPremium,
+		},
+		{
+			name:          "denied",
+			notifications: []notification{recorded(client.MidtransTransactionStatusDeny, "")},
+			wantTier:      model.TierFree,
+		},
+		{
+			name: "settlement then refund",
+			notifications: []notification{
+				paid(client.MidtransTransactionStatusSettlement),
+				{status: client.MidtransTransactionStatusRefund, grossAmount: "150000.00", wantCode: http.StatusOK,
+					wantStatus: "revoked"},
+			},
+			wantTier: model.TierFree,
+		},
+		{
+			name: "settlement then refund and chargeback",
+			notifications: []notification{
+				paid(client.MidtransTransactionStatusSettlement),
+				{status: client.MidtransTransactionStatusRefund, grossAmount: "150000.00", wantCode: http.StatusOK,
+					wantStatus: "revoked"},
+				recorded(client.MidtransTransactionStatusChargeback, ""),
+			},
+			wantTier: model.TierFree,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := model.User{ID: primitive.NewObjectID(), Entitlement: model.Entitlement{Tier: model.TierFree}}
+			orderID := "PREMIUM-" + u.ID.Hex() + "-1"
+			var bes []model.BillingEvent
+			db := &mock.Database{
+				BillingEventInsertFunc: func(_ context.Context, be model.BillingEvent) (string, error) {
+					for _, obe := range bes {
+						if obe.Provider == be.Provider && obe.EventID == be.EventID {
+							return "", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+						}
+					}
+					be.ID = primitive.NewObjectID()
+					bes = append(bes, be)
+					return be.ID.Hex(), nil
+				},
+				BillingEventsFindByOrderFunc: func(_ context.Context, provider string, orderID string) (
+					[]model.BillingEvent, error,
+				) {
+					var found []model.BillingEvent
+					for _, be := range bes {
+						if be.Provider == provider && be.OrderID == orderID {
+							found = append(found, be)
+						}
+					}
+					return found, nil
+				},
+				BillingEventUpdateFunc: func(_ context.Context, id string, be model.BillingEvent) error {
+					for idx := range bes {
+						if bes[idx].ID.Hex() == id {
+							be.ID = bes[idx].ID
+							bes[idx] = be
+						}
+					}
+					return nil
+				},
+				UserFindByIDFunc: func(_ context.Context, id string) (model.User, error) {
+					return u, nil
+				},
+				UserEntitlementSetFunc: func(_ context.Context, userID string, e model.Entitlement) error {
+					u.Entitlement = e
+					return nil
+				},
+			}
+			s := newTestServer(t, db)
+			s.Client = &mock.Client{
+				MidtransEnabledFunc: func() bool { return true },
+				MidtransVerifyNotificationFunc: func(n client.MidtransNotification) bool {
+					return client.Client{MidtransServerKey: serverKey}.MidtransVerifyNotification(n)
+				},
+			}
+			s.PremiumPrice = price
+			s.PremiumDurationDays = 30
+			router := s.Router()
+
+			for idx, tn := range tt.notifications {
+				n := client.MidtransNotification{
+					TransactionID:     "test-transaction",
+					TransactionStatus: tn.status,
+					OrderID:           orderID,
+					StatusCode:        "200",
+					GrossAmount:       tn.grossAmount,
+					FraudStatus:       tn.fraudStatus,
+				}
+				key := serverKey
+				if tn.badKey {
+					key = "other-server-key"
+				}
+				n.SignatureKey = midtransSignature(n, key)
+				body, err := json.Marshal(n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req := httptest.NewRequest(http.MethodPost, "/api/billing/midtrans/notification", bytes.NewReader(body))
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != tn.wantCode {
+					t.Fatalf("notification %d (%s): got status %d, want %d, body: %s",
+						idx, tn.status, rec.Code, tn.wantCode, rec.Body)
+				}
+				if tn.wantStatus == "" {
+					continue
+				}
+				var resp struct {
+					Status string `json:"status"`
+				}
+				if err = json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("notification %d (%s): error unmarshalling response: %v", idx, tn.status, err)
+				}
+				if resp.Status != tn.wantStatus {
+					t.Errorf("notification %d (%s): got status %#v, want %#v",
+						idx, tn.status, resp.Status, tn.wantStatus)
+				}
+			}
+
+			if u.Entitlement.Tier != tt.wantTier {
+				t.Errorf("got Tier %s, want %s", u.Entitlement.Tier, tt.wantTier)
+			}
+			// Every order activates premium at most once, so it never runs longer than one purchase.
+			if tt.wantTier == model.TierPremium && u.Entitlement.ExpiresAt.Time().After(time.Now().AddDate(0, 0, 30)) {
+				t.Errorf("got ExpiresAt %v, want at most %d days from now", u.Entitlement.ExpiresAt.Time(), 30)
+			}
+		})
+	}
+}
+
+// midtransSignature returns the signature_key of n signed with serverKey.
+func midtransSignature(n client.MidtransNotification, serverKey string) string {
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"pricetracker/internal/database"
+)
+
+func (s Server) adminDBIndexes() http.HandlerFunc {
+	type response struct {
+		Drift []database.IndexDrift `json:"drift"`
+	}
+	openAPIRegister("adminDBIndexes", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		drifts, err := s.DB.IndexesDrift(r.Context())
+		if err != nil {
+			s.Logger.Errorf("adminDBIndexes: Error checking DB index drift, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if drifts == nil {
+			drifts = []database.IndexDrift{}
+		}
+		s.writeJsonResponse(w, response{Drift: drifts}, http.StatusOK)
+	}
+}
+
+// adminDBIndexesEnsure creates the missing DB indexes in the background since index builds can outlive the request.
+func (s Server) adminDBIndexesEnsure() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.Logger.Infof("adminDBIndexesEnsure: Ensuring DB indexes")
+		go func() {
+			if err := s.DB.EnsureIndexes(context.Background()); err != nil {
+				s.Logger.Errorf("adminDBIndexesEnsure: Error ensuring DB indexes, err: %v", err)
+				return
+			}
+			s.Logger.Infof("adminDBIndexesEnsure: Finished ensuring DB indexes")
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"io"
+	"pricetracker/internal/client"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"time"
+)
+
+// Database is the part of database.Database used by the Server, it lets handlers and the fetcher run against
+// mock.Database.
+type Database interface {
+	service.Database
+	BarcodeDelete(ctx context.Context, barcodeNumber string) error
+	BarcodeFind(ctx context.Context, barcodeNumber string) (model.Barcode, error)
+	BarcodeInsert(ctx context.Context, b model.Barcode) error
+	BarcodeSubmissionFind(ctx context.Context, id string) (model.BarcodeSubmission, error)
+	BarcodeSubmissionInsert(ctx context.Context, bs model.BarcodeSubmission) (id string, err error)
+	BarcodeSubmissionReview(ctx context.Context, id string, status string, reviewedBy string, rejectReason string) error
+	BarcodeSubmissionsFindByStatus(ctx context.Context, status string, limit int64) ([]model.BarcodeSubmission, error)
+	BarcodeSubmissionsPendingCount(ctx context.Context, userID primitive.ObjectID) (int, error)
+	BarcodeUpdate(ctx context.Context, b model.Barcode) error
+	BarcodesUpsert(ctx context.Context, bs []model.Barcode) (inserted int, updated int, err error)
+	BillingEventDelete(ctx context.Context, id string) error
+	BillingEventInsert(ctx context.Context, be model.BillingEvent) (id string, err error)
+	BillingEventUpdate(ctx context.Context, id string, be model.BillingEvent) error
+	BillingEventsFindByOrder(ctx context.Context, provider string, orderID string) ([]model.BillingEvent, error)
+	BillingEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.BillingEvent, error)
+	EnsureIndexes(ctx context.Context) error
+	FetchCycleFind(ctx context.Context, id string) (model.FetchCycle, error)
+	FetchCycleFindLastFinished(ctx context.Context, kind string) (model.FetchCycle, error)
+	FetchCycleSave(ctx context.Context, fc model.FetchCycle) error
+	FetchCyclesFindLatest(ctx context.Context, limit int64) ([]model.FetchCycle, error)
+	FetchCyclesFindRunning(ctx context.Context, since time.Time) ([]model.FetchCycle, error)
+	IndexesDrift(ctx context.Context) ([]database.IndexDrift, error)
+	ItemArchive(ctx context.Context, itemID primitive.ObjectID, reason string, now time.Time) error
+	ItemDuplicatesFind(ctx context.Context, since time.Time, limit int64) ([]database.ItemDuplicates, error)
+	ItemFetchIntervalsFind(ctx context.Context, now time.Time) ([]database.ItemFetchInterval, error)
+	ItemFindOne(ctx context.Context, itemID string) (model.Item, error)
+	ItemHistoryAggregate(
+		ctx context.Context, itemID string, start time.Time, end time.Time, interval string, loc *time.Location,
+	) ([]model.ItemHistoryBucket, error)
+	ItemHistoryFindRange(
+		ctx context.Context, itemID string, start time.Time, end time.Time, offset int64, limit int64,
+	) ([]model.ItemHistory, error)
+	ItemHistoryFirstPricesSince(
+		ctx context.Context, itemIDs []primitive.ObjectID, since time.Time,
+	) (map[primitive.ObjectID]int, error)
+	ItemHistoryForEach(
+		ctx context.Context, itemID string, start time.Time, end time.Time, fn func(ih model.ItemHistory) error,
+	) error
+	ItemHistoryPriceDropsSince(
+		ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemPriceDrop, error)
+	ItemHistoryPriceStats(ctx context.Context, itemID string, price int, now time.Time) (model.ItemPriceStats, error)
+	ItemHistoryPricesSince(ctx context.Context, itemID primitive.ObjectID, since time.Time) ([]int, error)
+	ItemHistoryStockFindRange(
+		ctx context.Context, itemID string, start time.Time, end time.Time,
+	) ([]model.ItemHistory, error)
+	ItemMatchesFind(ctx context.Context, itemID primitive.ObjectID) ([]model.ItemMatch, error)
+	ItemMerge(ctx context.Context, itemID primitive.ObjectID, dupID primitive.ObjectID) error
+	ItemNotFoundCountInc(ctx context.Context, itemID primitive.ObjectID) (int, error)
+	ItemTrackerCountsFind(ctx context.Context) (map[primitive.ObjectID]int, error)
+	ItemUnarchive(ctx context.Context, itemID primitive.ObjectID) error
+	ItemsArchiveOrphaned(ctx context.Context, orphanedBefore time.Time, now time.Time) (int, error)
+	ItemsArchivedFind(ctx context.Context, is []model.Item) ([]model.Item, error)
+	ItemsFind(ctx context.Context, itemIDs []primitive.ObjectID) ([]model.Item, error)
+	ItemsFindFields(ctx context.Context, itemIDs []primitive.ObjectID, fields []string) ([]model.Item, error)
+	ItemsFindAll(ctx context.Context) ([]model.Item, error)
+	ItemsFindBySite(ctx context.Context, site string, merchantID string) ([]model.Item, error)
+	ItemsInCategory(ctx context.Context, itemIDs []primitive.ObjectID, category string) ([]primitive.ObjectID, error)
+	ItemsMerchantSet(ctx context.Context, site string, merchantID string, name string, rating float64) error
+	ItemsNextFetchAtSet(ctx context.Context, nextFetchAt map[primitive.ObjectID]time.Time) error
+	ItemsOrphanedFind(ctx context.Context) ([]primitive.ObjectID, error)
+	ItemsOrphanedSet(ctx context.Context, itemIDs []primitive.ObjectID, now time.Time) error
+	ItemsRecheckDueFind(ctx context.Context, now time.Time) ([]model.Item, error)
+	ItemsTextSearch(ctx context.Context, query string, skip int64, limit int64) ([]model.Item, error)
+	ItemsTrackedSinceCount(
+		ctx context.Context, since time.Time, category string, limit int64) ([]database.ItemTrackCount, error)
+	ItemsTrackerCountSet(ctx context.Context, counts map[primitive.ObjectID]int) error
+	LoginEventInsert(ctx context.Context, le model.LoginEvent) error
+	LoginEventsFindByUser(ctx context.Context, userID string, limit int64) ([]model.LoginEvent, error)
+	MerchantHistoryFindRange(
+		ctx context.Context, site string, merchantID string, start time.Time, end time.Time,
+	) ([]model.MerchantHistory, error)
+	MerchantHistoryInsert(ctx context.Context, mh model.MerchantHistory) error
+	QueuedNotificationsDelete(ctx context.Context, ids []primitive.ObjectID) (int, error)
+	QueuedNotificationsFindDue(ctx context.Context, now time.Time, limit int64) ([]model.QueuedNotification, error)
+	QueuedNotificationsRetry(ctx context.Context, ids []primitive.ObjectID, deliverAt time.Time) (int, error)
+	SearchHistoriesDeleteByUser(ctx context.Context, userID primitive.ObjectID) (int, error)
+	SearchHistoriesFindByUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]model.SearchHistory, error)
+	SearchHistoryFindOne(ctx context.Context, userID primitive.ObjectID, searchID string) (model.SearchHistory, error)
+	SearchHistoryUpsert(ctx context.Context, sh model.SearchHistory) error
+	UserCredentialsSet(ctx context.Context, userID string, email string, password []byte) error
+	UserDeviceAdd(ctx context.Context, userID string, d model.Device) error
+	UserDeviceFCMTokenUpdate(ctx context.Context, userID string, deviceID string, fcmToken string) error
+	UserDeviceLastSeenUpdate(ctx context.Context, userID string, deviceID string) error
+	UserDeviceLoginTokenUpdate(ctx context.Context, userID string, deviceID string, lt model.LoginToken) error
+	UserDeviceTokensRemove(ctx context.Context, userID string, deviceID string) error
+	UserDeviceUpdate(ctx context.Context, userID string, d model.Device) error
+	UserDigestSentAtSet(ctx context.Context, userID primitive.ObjectID, sentAt time.Time) error
+	UserEntitlementSet(ctx context.Context, userID string, e model.Entitlement) error
+	UserExportFileCreate(filename string) (*gridfs.UploadStream, error)
+	UserExportFileOpen(fileID primitive.ObjectID) (io.ReadCloser, error)
+	UserExportFindOne(ctx context.Context, userID primitive.ObjectID, exportID string) (model.UserExport, error)
+	UserExportFinish(ctx context.Context, ue model.UserExport) error
+	UserExportInsert(ctx context.Context, ue model.UserExport) (primitive.ObjectID, error)
+	UserExportsCountSince(ctx context.Context, userID primitive.ObjectID, since time.Time) (int64, error)
+	UserExportsExpiredDelete(ctx context.Context, now time.Time) (int, error)
+	UserFindByEmail(ctx context.Context, email string) (model.User, error)
+	UserFindByGoogleID(ctx context.Context, googleID string) (model.User, error)
+	UserFindByID(ctx context.Context, id string) (model.User, error)
+	UserFindByReferralCode(ctx context.Context, code string) (model.User, error)
+	UserGoogleIDSet(ctx context.Context, userID string, googleID string) error
+	UserInsert(ctx context.Context, u model.User) (id string, err error)
+	UserLocaleSet(ctx context.Context, userID string, locale string) error
+	UserMerge(ctx context.Context, target model.User, source model.User, trackedItemsLimit int) (int, error)
+	UserNotificationPreferencesUpdate(ctx context.Context, userID string, np model.NotificationPreferences) error
+	UserReferralCodeSet(ctx context.Context, userID string, code string) error
+	UserReferralRewardAdd(
+		ctx context.Context, userID primitive.ObjectID, trackedItemsBonus int, trackedItemsBonusMax int,
+	) error
+	UserReferralRewardClaim(ctx context.Context, userID primitive.ObjectID) (primitive.ObjectID, error)
+	UserRolesSet(ctx context.Context, userID string, roles []string) error
+	UserTelegramLinkByToken(ctx context.Context, tokenHash string, chatID int64, now time.Time) (model.User, error)
+	UserTelegramLinkTokenSet(ctx context.Context, userID string, tokenHash string, expiresAt time.Time) error
+	UserTelegramSet(ctx context.Context, userID string, t model.Telegram) error
+	UserTrackedItemFetchIntervalSet(
+		ctx context.Context, userID string, itemID string, minutes int, until primitive.DateTime,
+	) error
+	UserTrackedItemNotificationCountIncrement(
+		ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID,
+	) (int, error)
+	UserTrackedItemNotificationCountReset(
+		ctx context.Context, userIDs []primitive.ObjectID, itemID primitive.ObjectID,
+	) (int, error)
+	UserTrackedItemRemove(ctx context.Context, userID string, itemID string) error
+	UserTrackedItemWebhookAdd(
+		ctx context.Context, userID string, itemID string, wh model.Webhook, webhooksLimit int,
+	) error
+	UserTrackedItemWebhookRemove(ctx context.Context, userID string, itemID string, webhookID string) error
+	UserTrackedItemsBulkUpdate(ctx context.Context, userID string, tius []database.TrackedItemUpdate) (int, error)
+	UserTrackedItemsQuotaSet(ctx context.Context, userID string, quota int) error
+	UsersDeviceFCMTokensFindByTrackedItem(ctx context.Context, itemID primitive.ObjectID) ([]model.User, error)
+	UsersDigestDueFind(ctx context.Context, frequency string, sentBefore time.Time, limit int64) ([]model.User, error)
+	UsersTrackedItemAlertsExpire(ctx context.Context, now time.Time) (int, error)
+	UsersTrackedItemAlternativesSet(
+		ctx context.Context, itemID primitive.ObjectID, alternatives []model.ItemAlternative,
+	) (int, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Client is the part of client.Client used by the Server, it lets handlers and the fetcher run against mock.Client.
+type Client interface {
+	service.Client
+	BarcodeLookup(ctx context.Context, barcode string) (model.Barcode, error)
+	ShopeeSearchByImage(ctx context.Context, image []byte) ([]model.Item, error)
+	ShopeeGetMerchant(ctx context.Context, shopID string) (model.MerchantHistory, error)
+	CacheInvalidateItem(site string, url string) error
+	SiteFingerprints() map[string]client.SiteFingerprint
+	SiteFingerprintsReload() (bool, error)
+	GoogleEnabled() bool
+	GoogleVerifyIDToken(ctx context.Context, idToken string) (client.GoogleIDTokenClaims, error)
+	MidtransEnabled() bool
+	MidtransVerifyNotification(n client.MidtransNotification) bool
+	SMTPEnabled() bool
+	SMTPSendMail(ctx context.Context, to string, subject string, htmlBody string) error
+	TelegramEnabled() bool
+	TelegramWebhookSecret() string
+	VisionEnabled() bool
+	VisionQuery(ctx context.Context, image []byte, contentType string) (string, error)
+}
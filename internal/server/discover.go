@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"net/http"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"strings"
+	"time"
+)
+
+const (
+	// discoverTrendingPeriod is how far back trending Items are aggregated from.
+	discoverTrendingPeriod = 24 * time.Hour
+	// discoverTrendingLimit is how many Items are listed in each trending list.
+	discoverTrendingLimit = 20
+	// discoverTrendingCacheTTL is how long the aggregated trending Items are cached.
+	discoverTrendingCacheTTL = 15 * time.Minute
+	// discoverTrendingCacheKey is suffixed with the lower-cased category of trending Items in a category.
+	discoverTrendingCacheKey = "discover:trending"
+)
+
+type trendingItem struct {
+	Item model.Item `json:"item"`
+	// NewTrackers is how many Users started tracking the Item in the period.
+	NewTrackers int `json:"new_trackers,omitempty"`
+	// PriceFrom is the first price of the Item in the period, DropPercent how much it dropped since.
+	PriceFrom   int     `json:"price_from,omitempty"`
+	DropPercent float64 `json:"drop_percent,omitempty"`
+}
+
+type trending struct {
+	Category     string             `json:"category,omitempty"`
+	MostTracked  []trendingItem     `json:"most_tracked"`
+	BiggestDrops []trendingItem     `json:"biggest_drops"`
+	Since        primitive.DateTime `json:"since"`
+	GeneratedAt  primitive.DateTime `json:"generated_at"`
+}
+
+// discoverTrending returns the Items most tracked and with the biggest price drops in the last 24 hours,
+// across all Users, only Items in the category query parameter when it is supplied. Archived Items and price drops of
+// Items no User tracks are left out.
+func (s Server) discoverTrending() http.HandlerFunc {
+	type response trending
+	openAPIRegister("discoverTrending", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		category, err := parseCategory(r)
+		if err != nil {
+			s.Logger.Debugf("discoverTrending: Invalid category, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cacheKey := discoverTrendingCacheKey
+		if category != "" {
+			cacheKey += ":" + strings.ToLower(category)
+		}
+
+		if b, err := s.Cache.Get(r.Context(), cacheKey); err == nil {
+			var t trending
+			if err = json.Unmarshal(b, &t); err == nil {
+				s.writeJsonResponse(w, response(t), http.StatusOK)
+				return
+			}
+			s.Logger.Errorf("discoverTrending: Error unmarshalling cached trending Items, err: %v", err)
+		} else if err != client.ErrCacheMiss {
+			s.Logger.Errorf("discoverTrending: Error getting cached trending Items, err: %v", err)
+		}
+
+		t, err := s.trendingAggregate(r.Context(), category, time.Now())
+		if err != nil {
+			s.Logger.Errorf("discoverTrending: Error aggregating trending Items, category: %#v, err: %v", category, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if b, err := json.Marshal(t); err != nil {
+			s.Logger.Errorf("discoverTrending: Error marshalling trending Items, err: %v", err)
+		} else if err = s.Cache.Set(r.Context(), cacheKey, b, discoverTrendingCacheTTL); err != nil {
+			s.Logger.Errorf("discoverTrending: Error caching trending Items, err: %v", err)
+		}
+		s.writeJsonResponse(w, response(t), http.StatusOK)
+	}
+}
+
+func (s Server) trendingAggregate(ctx context.Context, category string, now time.Time) (trending, error) {
+	since := now.Add(-discoverTrendingPeriod)
+	t := trending{
+		Category:     category,
+		MostTracked:  []trendingItem{},
+		BiggestDrops: []trendingItem{},
+		Since:        primitive.NewDateTimeFromTime(since),
+		GeneratedAt:  primitive.NewDateTimeFromTime(now),
+	}
+	counts, err := s.DB.ItemsTrackedSinceCount(ctx, since, category, discoverTrendingLimit)
+	if err != nil {
+		return t, err
+	}
+	// Drops of archived and untracked Items are left out after the aggregation, more are aggregated to make up for them.
+	drops, err := s.DB.ItemHistoryPriceDropsSince(ctx, since, category, 3*discoverTrendingLimit)
+	if err != nil {
+		return t, err
+	}
+
+	itemIDs := make([]primitive.ObjectID, 0, len(counts)+len(drops))
+	for _, c := range counts {
+		itemIDs = append(itemIDs, c.ItemID)
+	}
+	for _, d := range drops {
+		itemIDs = append(itemIDs, d.ItemID)
+	}
+	if len(itemIDs) == 0 {
+		return t, nil
+	}
+	is, err := s.DB.ItemsFind(ctx, itemIDs)
+	if err != nil {
+		return t, err
+	}
+	items := make(map[primitive.ObjectID]model.Item, len(is))
+	for _, i := range is {
+		if !i.Archived {
+			items[i.ID] = i
+		}
+	}
+
+	for _, c := range counts {
+		if i, ok := items[c.ItemID]; ok {
+			t.MostTracked = append(t.MostTracked, trendingItem{Item: i, NewTrackers: c.Count})
+		}
+	}
+	for _, d := range drops {
+		if len(t.BiggestDrops) == discoverTrendingLimit {
+			break
+		}
+		if i, ok := items[d.ItemID]; ok && i.TrackerCount > 0 {
+			t.BiggestDrops = append(t.BiggestDrops, trendingItem{
+				Item:        i,
+				PriceFrom:   d.PriceFrom,
+				DropPercent: math.Round(d.Drop*1000) / 10,
+			})
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errEmailDomainNotAllowed = errors.New("email domain not allowed")
+var errEmailDomainDisposable = errors.New("email domain is disposable")
+
+type EmailPolicy struct {
+	allowlist      map[string]struct{}
+	denylist       map[string]struct{}
+	disposableFile string
+
+	mu         sync.RWMutex
+	disposable map[string]struct{}
+}
+
+func NewEmailPolicy(allowlist []string, denylist []string, disposableFile string) (*EmailPolicy, error) {
+	ep := &EmailPolicy{
+		allowlist:      domainSet(allowlist),
+		denylist:       domainSet(denylist),
+		disposableFile: disposableFile,
+		disposable:     map[string]struct{}{},
+	}
+	if err := ep.Refresh(); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+func (ep *EmailPolicy) Refresh() error {
+	if ep.disposableFile == "" {
+		return nil
+	}
+	f, err := os.Open(ep.disposableFile)
+	if err != nil {
+		return errors.Wrapf(err, "error opening disposable email domains file: %s", ep.disposableFile)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var domains []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err = sc.Err(); err != nil {
+		return errors.Wrapf(err, "error reading disposable email domains file: %s", ep.disposableFile)
+	}
+
+	ep.mu.Lock()
+	ep.disposable = domainSet(domains)
+	ep.mu.Unlock()
+	return nil
+}
+
+func (ep *EmailPolicy) DisposableCount() int {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return len(ep.disposable)
+}
+
+func (ep *EmailPolicy) Check(email string) error {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok || domain == "" {
+		return errors.Wrapf(errEmailDomainNotAllowed, "email has no domain: %s", email)
+	}
+	if len(ep.allowlist) > 0 && !domainMatches(domain, ep.allowlist) {
+		return errors.Wrapf(errEmailDomainNotAllowed, "domain not in allowlist: %s", domain)
+	}
+	if domainMatches(domain, ep.denylist) {
+		return errors.Wrapf(errEmailDomainNotAllowed, "domain in denylist: %s", domain)
+	}
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	if domainMatches(domain, ep.disposable) {
+		return errors.Wrapf(errEmailDomainDisposable, "domain: %s", domain)
+	}
+	return nil
+}
+
+func (s Server) RefreshEmailPolicyInInterval(ctx context.Context, ticker *time.Ticker) {
+	for range ticker.C {
+		if err := s.EmailPolicy.Refresh(); err != nil {
+			s.Logger.Errorf("RefreshEmailPolicyInInterval: Error refreshing disposable email domains, err: %v", err)
+			continue
+		}
+		s.Logger.Infof("RefreshEmailPolicyInInterval: Refreshed disposable email domains, count: %d", s.EmailPolicy.DisposableCount())
+	}
+}
+
+func domainSet(domains []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if d != "" {
+			set[d] = struct{}{}
+		}
+	}
+	return set
+}
+
+func domainMatches(domain string, set map[string]struct{}) bool {
+	for d := domain; d != ""; {
+		if _, ok := set[d]; ok {
+			return true
+		}
+		_, parent, ok := strings.Cut(d, ".")
+		if !ok {
+			break
+		}
+		d = parent
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// entitlementLimits are the per-tier limits, features should look them up through userLimits
+// instead of checking the tier directly.
+type entitlementLimits struct {
+	TrackedItems      int           `json:"tracked_items"`
+	RefreshCooldown   time.Duration `json:"-"`
+	Digest            bool          `json:"digest"`
+	APIRequestsPerDay int           `json:"api_requests_per_day"`
+	// FetchIntervalItems is how many TrackedItems can have a custom fetch interval at the same time.
+	FetchIntervalItems int `json:"fetch_interval_items"`
+}
+
+var tierLimits = map[string]entitlementLimits{
+	model.TierFree: {
+		TrackedItems:      25,
+		RefreshCooldown:   15 * time.Minute,
+		Digest:            false,
+		APIRequestsPerDay: 1000,
+	},
+	model.TierPremium: {
+		TrackedItems:      200,
+		RefreshCooldown:   time.Minute,
+		Digest:            true,
+		APIRequestsPerDay: 20000,
+
+		FetchIntervalItems: 10,
+	},
+}
+
+func effectiveTier(u model.User) string {
+	e := u.Entitlement
+	if _, ok := tierLimits[e.Tier]; !ok || e.Tier == model.TierFree {
+		return model.TierFree
+	}
+	if e.ExpiresAt != 0 && e.ExpiresAt.Time().Before(time.Now()) {
+		return model.TierFree
+	}
+	return e.Tier
+}
+
+func userLimits(u model.User) entitlementLimits {
+	return tierLimits[effectiveTier(u)]
+}
+
+// tierTrackedItemsLimit returns the tracked items limit of tier, as configured or else from tierLimits.
+func (s Server) tierTrackedItemsLimit(tier string) int {
+	if limit, ok := s.TrackedItemsLimits[tier]; ok {
+		return limit
+	}
+	return tierLimits[tier].TrackedItems
+}
+
+// trackedItemsLimit returns how many Items u can track, the quota of u replaces the limit of its tier
+// when set, the referral bonus is added to either.
+func (s Server) trackedItemsLimit(u model.User) int {
+	limit := s.tierTrackedItemsLimit(effectiveTier(u))
+	if u.TrackedItemsQuota > 0 {
+		limit = u.TrackedItemsQuota
+	}
+	return limit + u.Referral.TrackedItemsBonus
+}
+
+func (s Server) userEntitlement() http.HandlerFunc {
+	type response struct {
+		Tier                   string             `json:"tier"`
+		ExpiresAt              primitive.DateTime `json:"expires_at,omitempty"`
+		Limits                 entitlementLimits  `json:"limits"`
+		RefreshCooldownSeconds int                `json:"refresh_cooldown_seconds"`
+		TrackedItemsLimit      int                `json:"tracked_items_limit"`
+	}
+	openAPIRegister("userEntitlement", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userEntitlement: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		tier := effectiveTier(uc.user)
+		limits := tierLimits[tier]
+		limits.TrackedItems = s.tierTrackedItemsLimit(tier)
+		resp := response{
+			Tier:                   tier,
+			Limits:                 limits,
+			RefreshCooldownSeconds: int(tierLimits[tier].RefreshCooldown.Seconds()),
+			TrackedItemsLimit:      s.trackedItemsLimit(uc.user),
+		}
+		if tier != model.TierFree {
+			resp.ExpiresAt = uc.user.Entitlement.ExpiresAt
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
+
+func (s Server) adminEntitlementGrant() http.HandlerFunc {
+	type request struct {
+		Tier         string `json:"tier"`
+		DurationDays int    `json:"duration_days"`
+	}
+	type response struct {
+		UserID      string            `json:"user_id"`
+		Entitlement model.Entitlement `json:"entitlement"`
+	}
+	openAPIRegister("adminEntitlementGrant", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := mux.Vars(r)["userID"]
+
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("adminEntitlementGrant: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if _, ok := tierLimits[req.Tier]; !ok {
+			s.Logger.Debugf("adminEntitlementGrant: Invalid tier: %s", req.Tier)
+			http.Error(w, "Invalid tier", http.StatusBadRequest)
+			return
+		}
+		if req.DurationDays < 0 {
+			s.Logger.Debugf("adminEntitlementGrant: Invalid duration_days: %d", req.DurationDays)
+			http.Error(w, "Invalid duration_days", http.StatusBadRequest)
+			return
+		}
+
+		u, err := s.DB.UserFindByID(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("adminEntitlementGrant: User with ID: %s not found, err: %v", userID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminEntitlementGrant: Error finding User with ID: %s, err: %v", userID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		e := model.Entitlement{
+			Tier:      req.Tier,
+			GrantedAt: primitive.NewDateTimeFromTime(now),
+		}
+		if req.DurationDays > 0 && req.Tier != model.TierFree {
+			e.ExpiresAt = primitive.NewDateTimeFromTime(now.AddDate(0, 0, req.DurationDays))
+		}
+		if err = s.DB.UserEntitlementSet(r.Context(), u.ID.Hex(), e); err != nil {
+			s.Logger.Errorf("adminEntitlementGrant: Error setting Entitlement on User with ID: %s, err: %v", u.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminEntitlementGrant: Granted Tier: %s to User with ID: %s, ExpiresAt: %v",
+			e.Tier, u.ID.Hex(), e.ExpiresAt.Time())
+		s.writeJsonResponse(w, response{UserID: u.ID.Hex(), Entitlement: e}, http.StatusOK)
+	}
+}
+
+// adminUserQuota sets the tracked items quota of a User, replacing the limit of its tier, a quota of 0
+// reverts the User to the limit of its tier.
+func (s Server) adminUserQuota() http.HandlerFunc {
+	type request struct {
+		TrackedItems int `json:"tracked_items"`
+	}
+	type response struct {
+		UserID            string `json:"user_id"`
+		TrackedItemsQuota int    `json:"tracked_items_quota"`
+		TrackedItemsLimit int    `json:"tracked_items_limit"`
+	}
+	openAPIRegister("adminUserQuota", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := mux.Vars(r)["userID"]
+
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("adminUserQuota: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if req.TrackedItems < 0 {
+			s.Logger.Debugf("adminUserQuota: Invalid tracked_items: %d", req.TrackedItems)
+			http.Error(w, "Invalid tracked_items", http.StatusBadRequest)
+			return
+		}
+
+		u, err := s.DB.UserFindByID(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("adminUserQuota: User with ID: %s not found, err: %v", userID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminUserQuota: Error finding User with ID: %s, err: %v", userID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if err = s.DB.UserTrackedItemsQuotaSet(r.Context(), u.ID.Hex(), req.TrackedItems); err != nil {
+			s.Logger.Errorf("adminUserQuota: Error setting quota on User with ID: %s, err: %v", u.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		u.TrackedItemsQuota = req.TrackedItems
+		s.Logger.Infof("adminUserQuota: Set tracked items quota of User with ID: %s to %d", u.ID.Hex(), req.TrackedItems)
+		s.writeJsonResponse(w, response{
+			UserID:            u.ID.Hex(),
+			TrackedItemsQuota: u.TrackedItemsQuota,
+			TrackedItemsLimit: s.trackedItemsLimit(u),
+		}, http.StatusOK)
+	}
+}
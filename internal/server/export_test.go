@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// CreateLoginTokenAndHash lets the server_test tests log in Users like userLogin does.
+func (s Server) CreateLoginTokenAndHash(userID string, deviceID string) (string, time.Time, []byte, error) {
+	return s.createLoginTokenAndHash(userID, deviceID)
+}
+
+// RewardReferredUser lets the server_test tests reward referrals like itemAdd does.
+func (s Server) RewardReferredUser(ctx context.Context, u model.User) {
+	s.rewardReferredUser(ctx, u)
+}
+
+// RenewedEntitlement lets the server_test tests renew Entitlements like billingMidtransNotification does.
+func RenewedEntitlement(u model.User, days int, now time.Time) model.Entitlement {
+	return renewedEntitlement(u, days, now)
+}
+
+// RevokedEntitlement lets the server_test tests revoke Entitlements like billingMidtransNotification does.
+func RevokedEntitlement(u model.User, days int, now time.Time) model.Entitlement {
+	return revokedEntitlement(u, days, now)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"container/heap"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"pricetracker/internal/model"
+	"time"
+)
+
+const (
+	// fetchStaleInterval is how often Items without trackers and without recent price changes are fetched.
+	fetchStaleInterval = 6 * time.Hour
+	// fetchTrackedMaxInterval is the longest fetch interval of Items with trackers.
+	fetchTrackedMaxInterval = time.Hour
+	// fetchDueSlack is how early Items are fetched before they are due, as fetch cycles do not start exactly on time.
+	fetchDueSlack = time.Minute
+	// fetchPriorityWeightVolatility weighs price volatility against the log2 of the tracker count.
+	fetchPriorityWeightVolatility = 2
+)
+
+// fetchPriority favors Items tracked by many Users and Items whose price changed often recently.
+func fetchPriority(i model.Item, now time.Time) float64 {
+	return math.Log2(float64(i.TrackerCount)+1) + fetchPriorityWeightVolatility*i.PriceVolatilityAt(now)
+}
+
+// fetchInterval is how long after a full fetch cycle i is due again. It halves from fetchStaleInterval with every
+// point of fetchPriority, Items with trackers are due at least every fetchTrackedMaxInterval. Intervals shorter than
+// the fetch cycles fetch the Item in every cycle.
+func fetchInterval(i model.Item, now time.Time) time.Duration {
+	interval := time.Duration(float64(fetchStaleInterval) / math.Exp2(fetchPriority(i, now)))
+	if i.TrackerCount > 0 && interval > fetchTrackedMaxInterval {
+		interval = fetchTrackedMaxInterval
+	}
+	return interval
+}
+
+// fetchDue reports whether i should be fetched in a full fetch cycle starting at now.
+func fetchDue(i model.Item, now time.Time) bool {
+	return i.NextFetchAt == 0 || !now.Add(fetchDueSlack).Before(i.NextFetchAt.Time())
+}
+
+type fetchQueueEntry struct {
+	item     model.Item
+	priority float64
+}
+
+// fetchQueue is a max-heap of Items by fetch priority.
+type fetchQueue []fetchQueueEntry
+
+func (q fetchQueue) Len() int           { return len(q) }
+func (q fetchQueue) Less(a, b int) bool { return q[a].priority > q[b].priority }
+func (q fetchQueue) Swap(a, b int)      { q[a], q[b] = q[b], q[a] }
+func (q *fetchQueue) Push(x any)        { *q = append(*q, x.(fetchQueueEntry)) }
+func (q *fetchQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// fetchSchedule returns the due Items of is ordered by fetch priority, highest first,
+// along with the number of Items skipped as not due.
+func fetchSchedule(is []model.Item, now time.Time) ([]model.Item, int) {
+	q := make(fetchQueue, 0, len(is))
+	for _, i := range is {
+		if fetchDue(i, now) {
+			q = append(q, fetchQueueEntry{item: i, priority: fetchPriority(i, now)})
+		}
+	}
+	skipped := len(is) - len(q)
+	heap.Init(&q)
+	scheduled := make([]model.Item, 0, len(q))
+	for q.Len() > 0 {
+		scheduled = append(scheduled, heap.Pop(&q).(fetchQueueEntry).item)
+	}
+	return scheduled, skipped
+}
+
+// fetchNextAt returns when each Item of is, fetched in a full fetch cycle starting at now, is due again.
+func fetchNextAt(is []model.Item, now time.Time) map[primitive.ObjectID]time.Time {
+	next := make(map[primitive.ObjectID]time.Time, len(is))
+	for _, i := range is {
+		next[i.ID] = now.Add(fetchInterval(i, now))
+	}
+	return next
+}
@@ -2,96 +2,584 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/misc"
 	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"pricetracker/internal/tracing"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 )
 
+const (
+	// priorityFetchMinInterval is the shortest custom fetch interval honored regardless of tier.
+	priorityFetchMinInterval = 5 * time.Minute
+	// priorityFetchMaxItems caps how many Items are fetched per priority tick, the most overdue first.
+	priorityFetchMaxItems = 50
+	// fetchCycleSaveEvery is how many Items are fetched between FetchCycle progress saves.
+	fetchCycleSaveEvery = 25
+)
+
+// FetchDataInInterval fetches all Items on every tick until ctx is done, a running fetch cycle is aborted then.
 func (s Server) FetchDataInInterval(ctx context.Context, ticker *time.Ticker) {
-	for range ticker.C {
-		s.fetchData(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			s.FetcherStatus.ticked(t)
+			s.fetchData(ctx)
+		}
+	}
+}
+
+func (s Server) FetchPriorityDataInInterval(ctx context.Context, ticker *time.Ticker) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.fetchPriorityData(ctx)
+		}
 	}
 }
 
 func (s Server) fetchData(ctx context.Context) {
 	s.Logger.Info("fetchData: Starting to fetch all Item data")
+	fc := newFetchCycle(model.FetchCycleKindFull)
+	defer s.fetchCycleFinish(ctx, &fc)
+
+	if expired, err := s.DB.UsersTrackedItemAlertsExpire(ctx, time.Now()); err != nil {
+		s.Logger.Errorf("fetchData: Error disabling expired TrackedItem alerts, err: %v", err)
+	} else if expired > 0 {
+		s.Logger.Infof("fetchData: Disabled expired TrackedItem alerts on %d User(s)", expired)
+	}
+
 	is, err := s.DB.ItemsFindAll(ctx)
 	if err != nil {
 		s.Logger.Errorf("fetchData: Error getting all Items from DB, err: %v", err)
+		fc.Error = err.Error()
 		return
 	}
 	s.Logger.Infof("fetchData: Retrieved %d Item(s) from DB", len(is))
 
+	// Orphaned Items are found by their tracker count, so it is refreshed first.
+	s.updateTrackerCounts(ctx, is)
+	if s.markOrphanedItems(ctx) > 0 {
+		if is, err = s.DB.ItemsFindAll(ctx); err != nil {
+			s.Logger.Errorf("fetchData: Error getting all Items from DB after archiving, err: %v", err)
+			fc.Error = err.Error()
+			return
+		}
+	}
+	now := time.Now()
+	scheduled, skipped := fetchSchedule(is, now)
+	s.Logger.Infof("fetchData: Scheduled %d Item(s) by priority, skipped %d Item(s) not due yet", len(scheduled), skipped)
+	if err = s.DB.ItemsNextFetchAtSet(ctx, fetchNextAt(scheduled, now)); err != nil {
+		s.Logger.Errorf("fetchData: Error setting next fetch time of Items, err: %v", err)
+	}
+
+	s.fetchItems(ctx, &fc, scheduled)
+	s.Logger.Info("fetchData: Finished fetching all Item data")
+}
+
+// markOrphanedItems marks the Items nobody tracks as orphaned, they are archived after OrphanedItemGracePeriod.
+// It returns how many Items it archived.
+func (s Server) markOrphanedItems(ctx context.Context) int {
+	itemIDs, err := s.DB.ItemsOrphanedFind(ctx)
+	if err != nil {
+		s.Logger.Errorf("markOrphanedItems: Error finding orphaned Items, err: %v", err)
+		return 0
+	}
+	now := time.Now()
+	if err = s.DB.ItemsOrphanedSet(ctx, itemIDs, now); err != nil {
+		s.Logger.Errorf("markOrphanedItems: Error marking orphaned Items, err: %v", err)
+		return 0
+	}
+	s.Logger.Infof("markOrphanedItems: %d Item(s) are not tracked by any User", len(itemIDs))
+	archived, err := s.DB.ItemsArchiveOrphaned(ctx, now.Add(-s.OrphanedItemGracePeriod), now)
+	if err != nil {
+		s.Logger.Errorf("markOrphanedItems: Error archiving orphaned Items, err: %v", err)
+	} else if archived > 0 {
+		s.Logger.Infof("markOrphanedItems: Archived %d Item(s) orphaned for longer than %v", archived, s.OrphanedItemGracePeriod)
+	}
+	return archived
+}
+
+// updateTrackerCounts refreshes the tracker count of the Items in is, both in is and in DB.
+func (s Server) updateTrackerCounts(ctx context.Context, is []model.Item) {
+	counts, err := s.DB.ItemTrackerCountsFind(ctx)
+	if err != nil {
+		s.Logger.Errorf("updateTrackerCounts: Error getting Item tracker counts, err: %v", err)
+		return
+	}
+	changed := make(map[primitive.ObjectID]int)
+	for idx := range is {
+		if count := counts[is[idx].ID]; count != is[idx].TrackerCount {
+			is[idx].TrackerCount = count
+			changed[is[idx].ID] = count
+		}
+	}
+	if err = s.DB.ItemsTrackerCountSet(ctx, changed); err != nil {
+		s.Logger.Errorf("updateTrackerCounts: Error setting Item tracker counts, err: %v", err)
+	}
+}
+
+// fetchPriorityData fetches the Items whose custom fetch interval has elapsed since they were last updated,
+// preceded by the Items with a due recheck.
+func (s Server) fetchPriorityData(ctx context.Context) {
+	now := time.Now()
+	recheckItems, err := s.DB.ItemsRecheckDueFind(ctx, now)
+	if err != nil {
+		s.Logger.Errorf("fetchPriorityData: Error getting Items due for a recheck, err: %v", err)
+	}
+	ifis, err := s.DB.ItemFetchIntervalsFind(ctx, now)
+	if err != nil {
+		s.Logger.Errorf("fetchPriorityData: Error getting Item fetch intervals, err: %v", err)
+		return
+	}
+	if len(ifis) == 0 && len(recheckItems) == 0 {
+		return
+	}
+	intervals := make(map[primitive.ObjectID]time.Duration, len(ifis))
+	itemIDs := make([]primitive.ObjectID, 0, len(ifis))
+	for _, ifi := range ifis {
+		intervals[ifi.ItemID] = misc.Max(time.Duration(ifi.IntervalMinutes)*time.Minute, priorityFetchMinInterval)
+		itemIDs = append(itemIDs, ifi.ItemID)
+	}
+	var is []model.Item
+	if len(itemIDs) > 0 {
+		if is, err = s.DB.ItemsFind(ctx, itemIDs); err != nil {
+			s.Logger.Errorf("fetchPriorityData: Error getting Items, err: %v", err)
+			return
+		}
+	}
+
+	overdue := func(i model.Item) time.Duration {
+		return now.Sub(i.UpdatedAt.Time()) - intervals[i.ID]
+	}
+	rechecked := make(map[primitive.ObjectID]bool, len(recheckItems))
+	for _, i := range recheckItems {
+		rechecked[i.ID] = true
+	}
+	var dueItems []model.Item
 	for _, i := range is {
-		time.Sleep(300 * time.Millisecond)
-		var itemName string
-		if len(i.Name) > 45 {
-			itemName = i.Name[:45] + "..."
-		} else {
-			itemName = i.Name
-		}
-		s.Logger.Infof("fetchData: Fetching data for Item: %s, ID: %s", itemName, i.ID.Hex())
-		urlSiteType, cleanURL, err := siteTypeAndCleanURL(i.URL)
-		if err != nil {
-			s.Logger.Errorf("fetchData: Error getting site type from url: %s, err: %v", i.URL, err)
-			continue
-		}
-		var ecommerceItem model.Item
-		switch urlSiteType {
-		case siteShopee:
-			s.Logger.Debugf("fetchData: Getting Item data from Shopee for Item: %s, ID: %s", itemName, i.ID.Hex())
-			ecommerceItem, err = s.Client.ShopeeGetItem(cleanURL)
-			if err != nil {
-				s.Logger.Errorf("fetchData: Error getting Shopee item from url: %s, err: %v", cleanURL, err)
-				continue
-			}
-		case siteTokopedia:
-			s.Logger.Debugf("fetchData: Getting Item data from Tokopedia for Item: %s, ID: %s", itemName, i.ID.Hex())
-			ecommerceItem, err = s.Client.TokopediaGetItem(cleanURL)
-			if err != nil {
-				s.Logger.Errorf("fetchData: Error getting Tokopedia item from url: %s, err: %v", cleanURL, err)
-				continue
+		if !i.Archived && !rechecked[i.ID] && overdue(i) >= 0 {
+			dueItems = append(dueItems, i)
+		}
+	}
+	if len(dueItems) == 0 && len(recheckItems) == 0 {
+		return
+	}
+	sort.Slice(dueItems, func(a, b int) bool {
+		return overdue(dueItems[a]) > overdue(dueItems[b])
+	})
+	if len(recheckItems) > 0 {
+		s.Logger.Infof("fetchPriorityData: %d Item(s) due for a recheck", len(recheckItems))
+		dueItems = append(recheckItems, dueItems...)
+	}
+	if len(dueItems) > priorityFetchMaxItems {
+		s.Logger.Infof("fetchPriorityData: %d Item(s) due, fetching the %d most overdue", len(dueItems), priorityFetchMaxItems)
+		dueItems = dueItems[:priorityFetchMaxItems]
+	}
+
+	s.Logger.Infof("fetchPriorityData: Fetching %d Item(s) with custom fetch intervals or rechecks", len(dueItems))
+	fc := newFetchCycle(model.FetchCycleKindPriority)
+	defer s.fetchCycleFinish(ctx, &fc)
+	s.fetchItems(ctx, &fc, dueItems)
+}
+
+func newFetchCycle(kind string) model.FetchCycle {
+	return model.FetchCycle{
+		ID:        primitive.NewObjectID(),
+		Kind:      kind,
+		StartedAt: primitive.NewDateTimeFromTime(time.Now()),
+		Sites:     make(map[string]*model.FetchCycleSiteStats),
+	}
+}
+
+func (s Server) fetchCycleFinish(ctx context.Context, fc *model.FetchCycle) {
+	fc.FinishedAt = primitive.NewDateTimeFromTime(time.Now())
+	fc.DurationMs = fc.FinishedAt.Time().Sub(fc.StartedAt.Time()).Milliseconds()
+	if fc.Kind == model.FetchCycleKindFull {
+		s.FetcherStatus.finished(*fc)
+	}
+	if ctx.Err() != nil {
+		// The cycle was aborted on shutdown, it is still saved so it does not look like it is running.
+		if fc.Error == "" {
+			fc.Error = "aborted: " + ctx.Err().Error()
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+	}
+	if err := s.DB.FetchCycleSave(ctx, *fc); err != nil {
+		s.Logger.Errorf("fetchCycleFinish: Error saving FetchCycle, err: %v", err)
+	}
+}
+
+// fetchItems fetches, updates, records history and notifies for every Item in is, recording the results in fc.
+// Every site has its own bounded queue worked by FetcherWorkersPerSite workers, so a slow or rate limited site
+// does not hold up the others. Progress is saved every fetchCycleSaveEvery Items so running cycles can be followed.
+func (s Server) fetchItems(ctx context.Context, fc *model.FetchCycle, is []model.Item) {
+	fc.Total = len(is)
+	if err := s.DB.FetchCycleSave(ctx, *fc); err != nil {
+		s.Logger.Errorf("fetchItems: Error saving FetchCycle progress, err: %v", err)
+	}
+
+	siteItems := make(map[string][]model.Item)
+	for _, i := range is {
+		siteItems[i.Site] = append(siteItems[i.Site], i)
+		if _, ok := fc.Sites[i.Site]; !ok {
+			fc.Sites[i.Site] = &model.FetchCycleSiteStats{}
+		}
+		fc.Sites[i.Site].Total++
+	}
+
+	fw := &fetchWork{fc: fc, merchantsFetched: make(map[string]bool)}
+	if fc.Kind == model.FetchCycleKindFull {
+		s.FetcherStatus.started(fw)
+	}
+	workers := misc.Max(s.FetcherWorkersPerSite, 1)
+	queueSize := misc.Max(s.FetcherQueueSize, 1)
+	var wg sync.WaitGroup
+	for _, items := range siteItems {
+		queue := make(chan model.Item, queueSize)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range queue {
+					if ctx.Err() != nil {
+						continue
+					}
+					s.fetchItemUpdate(ctx, fw, i)
+				}
+			}()
+		}
+		go func(items []model.Item) {
+			defer close(queue)
+			for _, i := range items {
+				select {
+				case <-ctx.Done():
+					return
+				case queue <- i:
+				}
 			}
-		case siteBlibli:
-			s.Logger.Debugf("fetchData: Getting Item data from Blibli for Item: %s, ID: %s", itemName, i.ID.Hex())
-			ecommerceItem, err = s.Client.BlibliGetItem(cleanURL)
-			if err != nil {
-				s.Logger.Errorf("fetchData: Error getting Blibli item from url: %s, err: %v", cleanURL, err)
-				continue
+		}(items)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		s.Logger.Infof("fetchItems: Aborted after %d of %d Item(s), err: %v", fc.Done, fc.Total, ctx.Err())
+	}
+}
+
+// fetchWork is the state shared by the fetchItems workers.
+type fetchWork struct {
+	mu               sync.Mutex
+	fc               *model.FetchCycle
+	merchantsFetched map[string]bool
+}
+
+func (s Server) fetchItemUpdate(ctx context.Context, fw *fetchWork, i model.Item) {
+	ctx, span := s.Tracer.Start(ctx, "fetchItemUpdate", tracing.KindInternal)
+	span.SetAttribute("item.id", i.ID.Hex())
+	span.SetAttribute("item.site", i.Site)
+	defer span.End()
+	itemStart := time.Now()
+	ecommerceItem, err := s.fetchItem(ctx, i)
+	duration := time.Since(itemStart).Milliseconds()
+	span.SetError(err)
+
+	fw.mu.Lock()
+	fw.fc.Done++
+	var progress *model.FetchCycle
+	if fw.fc.Done%fetchCycleSaveEvery == 0 {
+		fc := fw.fc.Copy()
+		progress = &fc
+	}
+	fw.mu.Unlock()
+	// The progress is saved without holding fw.mu, the other workers would otherwise wait on the database.
+	if progress != nil {
+		if err := s.DB.FetchCycleSave(ctx, *progress); err != nil {
+			s.Logger.Errorf("fetchItemUpdate: Error saving FetchCycle progress, err: %v", err)
+		}
+	}
+
+	fw.mu.Lock()
+	siteStats := fw.fc.Sites[i.Site]
+	siteStats.Attempted++
+	siteStats.DurationMs += duration
+	if err != nil {
+		switch {
+		case errors.Is(err, errFetchItemNotFound):
+			siteStats.NotFound++
+		case errors.Is(err, errFetchRequest):
+			siteStats.Failed++
+		default:
+			siteStats.ParseErrors++
+		}
+		fw.mu.Unlock()
+		return
+	}
+	siteStats.Succeeded++
+	merchantKey := i.Site + "/" + i.MerchantID
+	fetchMerchant := !fw.merchantsFetched[merchantKey]
+	fw.merchantsFetched[merchantKey] = true
+	fw.mu.Unlock()
+
+	updatedI, notified, err := s.fetchedItemUpdate(ctx, i, ecommerceItem, fw.fc.ID)
+	if err != nil && updatedI.ID.IsZero() {
+		return
+	}
+	if fetchMerchant {
+		s.fetchMerchant(ctx, i)
+	}
+	fw.mu.Lock()
+	siteStats.Notifications += notified
+	fw.fc.Notifications += notified
+	fw.mu.Unlock()
+}
+
+// fetchedItemUpdate updates the stored Item i with its fetched data ecommerceItem, inserts an ItemHistory of it in
+// the FetchCycle with ID fcID and notifies the Users tracking i of the changes. It returns the updated Item and how
+// many notifications were sent. The error is returned with a zero Item when nothing was updated, and with the
+// updated Item when only its ItemHistory could not be written.
+func (s Server) fetchedItemUpdate(
+	ctx context.Context, i model.Item, ecommerceItem model.Item, fcID primitive.ObjectID,
+) (model.Item, int, error) {
+	notified := 0
+	itemName := i.ShortName()
+	s.Logger.Debugf("fetchedItemUpdate: Updating Item: %s, ID: %s", itemName, i.ID.Hex())
+	flashSale := isFlashSale(i, ecommerceItem)
+	if flashSale {
+		s.Logger.Infof("fetchedItemUpdate: Flash sale for Item: %s, ID: %s, price: %d -> %d, stock: %d, rechecking in %v",
+			itemName, i.ID.Hex(), i.Price, ecommerceItem.Price, ecommerceItem.Stock, flashSaleRecheckAfter)
+	}
+	updatedI, err := s.DB.ItemPriceUpdate(ctx, i.ID, ecommerceItem.Price)
+	if err != nil {
+		s.Logger.Errorf("fetchedItemUpdate: Error updating Item price, err: %v", err)
+		return model.Item{}, 0, err
+	}
+	updatedI, err = s.DB.ItemUpdateFunc(ctx, updatedI, func(i *model.Item) {
+		i.UpdateWith(ecommerceItem)
+		if flashSale {
+			i.RecheckAt = primitive.NewDateTimeFromTime(time.Now().Add(flashSaleRecheckAfter))
+		}
+	})
+	if err != nil {
+		s.Logger.Errorf("fetchedItemUpdate: Error updating Item, err: %v", err)
+	}
+
+	anomaly := s.priceAnomaly(ctx, i, ecommerceItem.Price)
+	if anomaly != "" {
+		s.Logger.Infof("fetchedItemUpdate: Price anomaly %s for Item: %s, ID: %s, price: %d -> %d",
+			anomaly, itemName, i.ID.Hex(), i.Price, ecommerceItem.Price)
+	}
+
+	s.Logger.Debugf("fetchedItemUpdate: Inserting ItemHistory for Item: %s, ID: %s", itemName, i.ID.Hex())
+	ih := model.ItemHistory{
+		ItemID:       i.ID,
+		FetchCycleID: fcID,
+		Price:        ecommerceItem.Price,
+		Currency:     ecommerceItem.Currency,
+		Stock:        ecommerceItem.Stock,
+		Rating:       ecommerceItem.Rating,
+		Sold:         ecommerceItem.Sold,
+		Anomaly:      anomaly,
+		FlashSale:    flashSale,
+		Timestamp:    primitive.NewDateTimeFromTime(time.Now()),
+	}
+	historyErr := s.DB.ItemHistoryUpsert(ctx, ih)
+	if historyErr != nil {
+		s.Logger.Errorf("fetchedItemUpdate: Error upserting ItemHistory, err: %v", historyErr)
+	} else if ih.Price != i.Price || ih.Stock != i.Stock {
+		s.publishPriceUpdate(ctx, ih)
+	}
+
+	if i.Stock == 0 && ecommerceItem.Stock > 0 {
+		s.Logger.Infof("fetchedItemUpdate: Item back in stock, notifying Users for Item: %s, ID: %s", itemName, i.ID.Hex())
+		notified += s.notifyRestock(ctx, updatedI)
+	}
+
+	if (ecommerceItem.Rating > 0 && ecommerceItem.Rating < i.Rating) || ecommerceItem.Sold > i.Sold {
+		notified += s.notifyRatingAndSold(ctx, i, updatedI)
+	}
+
+	if ecommerceItem.Price == i.Price && !i.VariantPricesChanged(ecommerceItem) {
+		s.Logger.Infof("fetchedItemUpdate: No changes on price for Item: %s, ID: %s, will not notify Users", itemName, i.ID.Hex())
+		return updatedI, notified, historyErr
+	}
+	if ecommerceItem.Stock == 0 {
+		s.Logger.Debugf("fetchedItemUpdate: Stock is 0 for Item: %s, ID: %s, will not notify Users", itemName, i.ID.Hex())
+		return updatedI, notified, historyErr
+	}
+	if anomaly == model.ItemHistoryAnomalyFakeDrop {
+		s.Logger.Infof("fetchedItemUpdate: Price drop returns to the recent baseline for Item: %s, ID: %s, will not notify Users",
+			itemName, i.ID.Hex())
+		return updatedI, notified, historyErr
+	}
+	s.Logger.Infof("fetchedItemUpdate: Price changed, notifying Users for Item: %s, ID: %s", itemName, i.ID.Hex())
+	notified += s.notify(ctx, i, updatedI)
+	return updatedI, notified, historyErr
+}
+
+var errFetchItemNotFound = errors.New("item not found")
+var errFetchRequest = errors.New("request failed")
+
+// fetchItem gets the current data of i from its site, the returned error wraps errFetchItemNotFound
+// when the site reports the Item as gone, errFetchRequest when the site could not be reached or
+// returned an error, and is otherwise a response parsing error.
+func (s Server) fetchItem(ctx context.Context, i model.Item) (model.Item, error) {
+	itemName := i.ShortName()
+	s.Logger.Infof("fetchItem: Fetching data for Item: %s, ID: %s", itemName, i.ID.Hex())
+	sr, cleanURL, err := s.Scrapers.Resolve(i.URL)
+	if err != nil {
+		s.Logger.Errorf("fetchItem: Error getting site scraper from url: %s, err: %v", i.URL, err)
+		return model.Item{}, err
+	}
+	// Cached data, e.g. from adding the Item, would hide the current price from the fetcher.
+	if err = s.Client.CacheInvalidateItem(sr.ClientSite(), cleanURL); err != nil {
+		s.Logger.Errorf("fetchItem: Error invalidating cached Item, url: %s, err: %v", cleanURL, err)
+	}
+	s.Logger.Debugf("fetchItem: Getting Item data from %s for Item: %s, ID: %s", i.Site, itemName, i.ID.Hex())
+	sc, err := s.Items.Scrape(ctx, cleanURL, "")
+	if err != nil {
+		s.Logger.Errorf("fetchItem: Error getting item from url: %s, err: %v", cleanURL, err)
+		if errors.Is(err, service.ErrItemNotFound) {
+			s.itemNotFound(ctx, i)
+			return model.Item{}, errors.Wrap(errFetchItemNotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrSiteUnavailable) {
+			return model.Item{}, errors.Wrap(errFetchRequest, err.Error())
+		}
+		return model.Item{}, err
+	}
+	return sc.Item, nil
+}
+
+func (s Server) adminFetchCycles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := int64(10)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsedLimit, err := strconv.ParseInt(l, 10, 64)
+			if err != nil || parsedLimit <= 0 {
+				s.Logger.Debugf("adminFetchCycles: Invalid limit: %#v, err: %v", l, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
 			}
+			limit = misc.Min(parsedLimit, 100)
+		}
+
+		fcs, err := s.DB.FetchCyclesFindLatest(r.Context(), limit)
+		if err != nil {
+			s.Logger.Errorf("adminFetchCycles: Error finding FetchCycles, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if fcs == nil {
+			fcs = []model.FetchCycle{}
 		}
+		s.writeJsonResponse(w, fcs, http.StatusOK)
+	}
+}
 
-		s.Logger.Debugf("fetchData: Updating Item: %s, ID: %s", itemName, i.ID.Hex())
-		updatedI := i
-		updatedI.UpdateWith(ecommerceItem)
-		if err = s.DB.ItemUpdate(ctx, updatedI); err != nil {
-			s.Logger.Errorf("fetchData: Error updating Item, err: %v", err)
+func (s Server) adminFetchCycle() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fcID := mux.Vars(r)["fetchCycleID"]
+		fc, err := s.DB.FetchCycleFind(r.Context(), fcID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("adminFetchCycle: FetchCycle with ID: %s not found, err: %v", fcID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminFetchCycle: Error finding FetchCycle with ID: %s, err: %v", fcID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
 		}
+		s.writeJsonResponse(w, fc, http.StatusOK)
+	}
+}
 
-		s.Logger.Debugf("fetchData: Inserting ItemHistory for Item: %s, ID: %s", itemName, i.ID.Hex())
-		ih := model.ItemHistory{
-			ItemID:    i.ID,
-			Price:     ecommerceItem.Price,
-			Stock:     ecommerceItem.Stock,
-			Rating:    ecommerceItem.Rating,
-			Sold:      ecommerceItem.Sold,
-			Timestamp: primitive.NewDateTimeFromTime(time.Now()),
+// adminRefetch starts fetching every Item of a site, optionally of a single merchant, in the background.
+// Its progress can be followed through the returned FetchCycle. Only one refetch runs at a time,
+// others are rejected until it finishes.
+func (s Server) adminRefetch() http.HandlerFunc {
+	type request struct {
+		Site       string `json:"site"`
+		MerchantID string `json:"merchant_id"`
+	}
+	type response struct {
+		FetchCycleID string `json:"fetch_cycle_id"`
+		Total        int    `json:"total"`
+	}
+	openAPIRegister("adminRefetch", request{}, response{})
+	running := make(chan struct{}, 1)
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("adminRefetch: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
 		}
-		if err = s.DB.ItemHistoryInsert(ctx, ih); err != nil {
-			s.Logger.Errorf("fetchData: Error inserting ItemHistory, err: %v", err)
+		site, ok := s.itemSiteName(req.Site)
+		if !ok {
+			s.Logger.Debugf("adminRefetch: Invalid site: %#v", req.Site)
+			http.Error(w, "Invalid site", http.StatusBadRequest)
+			return
 		}
 
-		if ecommerceItem.Price != i.Price {
-			if ecommerceItem.Stock == 0 {
-				s.Logger.Debugf("fetchData: Stock is 0 for Item: %s, ID: %s, will not notify Users", itemName, i.ID.Hex())
-				continue
+		select {
+		case running <- struct{}{}:
+		default:
+			s.Logger.Debugf("adminRefetch: Refetch already running, Site: %s, MerchantID: %s", site, req.MerchantID)
+			http.Error(w, "Refetch already running", http.StatusConflict)
+			return
+		}
+		started := false
+		defer func() {
+			if !started {
+				<-running
 			}
-			s.Logger.Infof("fetchData: Price changed, notifying Users for Item: %s, ID: %s", itemName, i.ID.Hex())
-			s.notify(ctx, updatedI)
-		} else {
-			s.Logger.Infof("fetchData: No changes on price for Item: %s, ID: %s, will not notify Users", itemName, i.ID.Hex())
-			continue
+		}()
+
+		is, err := s.DB.ItemsFindBySite(r.Context(), site, req.MerchantID)
+		if err != nil {
+			s.Logger.Errorf("adminRefetch: Error finding Items for Site: %s, MerchantID: %s, err: %v", site, req.MerchantID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
 		}
+		if len(is) == 0 {
+			s.Logger.Debugf("adminRefetch: No Items for Site: %s, MerchantID: %s", site, req.MerchantID)
+			http.Error(w, "No items found", http.StatusNotFound)
+			return
+		}
+
+		fc := newFetchCycle(model.FetchCycleKindRefetch)
+		fc.Filter = &model.FetchCycleFilter{Site: site, MerchantID: req.MerchantID}
+		fc.Total = len(is)
+		if err = s.DB.FetchCycleSave(r.Context(), fc); err != nil {
+			s.Logger.Errorf("adminRefetch: Error saving FetchCycle, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminRefetch: Refetching %d Item(s) for Site: %s, MerchantID: %s, FetchCycleID: %s",
+			len(is), site, req.MerchantID, fc.ID.Hex())
+		started = true
+		go func() {
+			defer func() { <-running }()
+			ctx := s.appContext()
+			defer s.fetchCycleFinish(ctx, &fc)
+			s.fetchItems(ctx, &fc, is)
+		}()
+		s.writeJsonResponse(w, response{FetchCycleID: fc.ID.Hex(), Total: fc.Total}, http.StatusAccepted)
 	}
-	s.Logger.Info("fetchData: Finished fetching all Item data")
 }
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	fetcherStatusKey = "fetcher:status"
+	// fetcherStatusTTL expires the published status when the fetcher stops publishing it, e.g. after a crash.
+	fetcherStatusTTL = time.Minute
+)
+
+// FetcherStatus is the state of the full fetch cycles kept by the fetcher, published to Redis for adminFetcherStatus
+// of every instance. It is nil when the fetcher does not run in this process.
+type FetcherStatus struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	nextRunAt time.Time
+	// current is the work of the running full fetch cycle, nil when none is running.
+	current *fetchWork
+	// last is the last full fetch cycle finished since starting.
+	last *model.FetchCycle
+}
+
+func NewFetcherStatus(interval time.Duration) *FetcherStatus {
+	fs := &FetcherStatus{}
+	fs.Reschedule(interval)
+	return fs
+}
+
+// Reschedule sets the interval of the full fetch cycles, the next one starting after interval like a reset ticker.
+func (fs *FetcherStatus) Reschedule(interval time.Duration) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.interval = interval
+	fs.nextRunAt = time.Now().Add(interval)
+}
+
+func (fs *FetcherStatus) ticked(at time.Time) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.nextRunAt = at.Add(fs.interval)
+}
+
+func (fs *FetcherStatus) started(fw *fetchWork) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.current = fw
+}
+
+func (fs *FetcherStatus) finished(fc model.FetchCycle) {
+	if fs == nil {
+		return
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.current = nil
+	fs.last = &fc
+}
+
+type fetcherSiteProgress struct {
+	Done   int `json:"done"`
+	Total  int `json:"total"`
+	Errors int `json:"errors"`
+	// ETASeconds is estimated from the pace of the site so far, it is missing until the first Item is done.
+	ETASeconds *int64 `json:"eta_seconds,omitempty"`
+}
+
+type fetcherCycleProgress struct {
+	ID        string             `json:"id"`
+	StartedAt primitive.DateTime `json:"started_at"`
+	Done      int                `json:"done"`
+	Total     int                `json:"total"`
+	// ETASeconds is the ETA of the slowest site, as sites are fetched concurrently.
+	ETASeconds *int64                         `json:"eta_seconds,omitempty"`
+	Sites      map[string]fetcherSiteProgress `json:"sites"`
+}
+
+// fetcherCycleProgressOf returns the progress of the cycle of fw at now, fw.mu must be held.
+func fetcherCycleProgressOf(fw *fetchWork, now time.Time) fetcherCycleProgress {
+	fc := fw.fc
+	elapsed := now.Sub(fc.StartedAt.Time())
+	p := fetcherCycleProgress{
+		ID:        fc.ID.Hex(),
+		StartedAt: fc.StartedAt,
+		Done:      fc.Done,
+		Total:     fc.Total,
+		Sites:     make(map[string]fetcherSiteProgress, len(fc.Sites)),
+	}
+	for site, ss := range fc.Sites {
+		sp := fetcherSiteProgress{Done: ss.Attempted, Total: ss.Total, Errors: ss.Failed + ss.ParseErrors}
+		if ss.Attempted > 0 {
+			eta := int64((elapsed / time.Duration(ss.Attempted) * time.Duration(ss.Total-ss.Attempted)).Seconds())
+			sp.ETASeconds = &eta
+			if p.ETASeconds == nil || eta > *p.ETASeconds {
+				p.ETASeconds = &eta
+			}
+		}
+		p.Sites[site] = sp
+	}
+	return p
+}
+
+// fetcherStatusSnapshot is the FetcherStatus at a point in time, as published to Redis.
+type fetcherStatusSnapshot struct {
+	CurrentCycle        *fetcherCycleProgress `json:"current_cycle,omitempty"`
+	LastCycleDurationMs *int64                `json:"last_cycle_duration_ms,omitempty"`
+	LastCycleFinishedAt *primitive.DateTime   `json:"last_cycle_finished_at,omitempty"`
+	NextRunAt           *primitive.DateTime   `json:"next_run_at,omitempty"`
+}
+
+func (fs *FetcherStatus) snapshot(now time.Time) fetcherStatusSnapshot {
+	snap := fetcherStatusSnapshot{}
+	fs.mu.Lock()
+	fw, last := fs.current, fs.last
+	if !fs.nextRunAt.IsZero() {
+		next := primitive.NewDateTimeFromTime(fs.nextRunAt)
+		snap.NextRunAt = &next
+	}
+	fs.mu.Unlock()
+
+	if fw != nil {
+		fw.mu.Lock()
+		p := fetcherCycleProgressOf(fw, now)
+		fw.mu.Unlock()
+		snap.CurrentCycle = &p
+	}
+	if last != nil {
+		snap.LastCycleDurationMs = &last.DurationMs
+		snap.LastCycleFinishedAt = &last.FinishedAt
+	}
+	return snap
+}
+
+// PublishFetcherStatusInInterval publishes the FetcherStatus to Redis on every tick until ctx is done, for the
+// instances not running the fetcher. It returns immediately when Redis is disabled or the fetcher does not run here.
+func (s Server) PublishFetcherStatusInInterval(ctx context.Context, ticker *time.Ticker) {
+	if s.Redis == nil || s.FetcherStatus == nil {
+		return
+	}
+	defer func() {
+		// The status of a stopped fetcher is not shown until it expires.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Redis.Del(ctx, fetcherStatusKey).Err(); err != nil {
+			s.Logger.Errorf("PublishFetcherStatusInInterval: Error deleting fetcher status, err: %v", err)
+		}
+	}()
+	for {
+		s.fetcherStatusPublish(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s Server) fetcherStatusPublish(ctx context.Context) {
+	b, err := json.Marshal(s.FetcherStatus.snapshot(time.Now()))
+	if err != nil {
+		s.Logger.Errorf("fetcherStatusPublish: Error marshalling fetcher status, err: %v", err)
+		return
+	}
+	if err = s.Redis.Set(ctx, fetcherStatusKey, b, fetcherStatusTTL).Err(); err != nil && ctx.Err() == nil {
+		s.Logger.Errorf("fetcherStatusPublish: Error publishing fetcher status, err: %v", err)
+	}
+}
+
+// fetcherStatusGet returns the FetcherStatus published to Redis, or that of this process when Redis is disabled.
+// ok is false when no fetcher is running.
+func (s Server) fetcherStatusGet(ctx context.Context) (snap fetcherStatusSnapshot, ok bool, err error) {
+	if s.Redis == nil {
+		if s.FetcherStatus == nil {
+			return snap, false, nil
+		}
+		return s.FetcherStatus.snapshot(time.Now()), true, nil
+	}
+	b, err := s.Redis.Get(ctx, fetcherStatusKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return snap, false, nil
+		}
+		return snap, false, errors.Wrap(err, "error getting fetcher status")
+	}
+	if err = json.Unmarshal(b, &snap); err != nil {
+		return snap, false, errors.Wrap(err, "error unmarshalling fetcher status")
+	}
+	return snap, true, nil
+}
+
+// adminFetcherStatus returns the progress of the running full fetch cycle, the duration of the last finished one
+// and when the next one starts, as published by the fetcher.
+func (s Server) adminFetcherStatus() http.HandlerFunc {
+	type response struct {
+		Running             bool                  `json:"running"`
+		CurrentCycle        *fetcherCycleProgress `json:"current_cycle,omitempty"`
+		LastCycleDurationMs *int64                `json:"last_cycle_duration_ms"`
+		LastCycleFinishedAt *primitive.DateTime   `json:"last_cycle_finished_at"`
+		NextRunAt           *primitive.DateTime   `json:"next_run_at"`
+	}
+	openAPIRegister("adminFetcherStatus", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap, ok, err := s.fetcherStatusGet(r.Context())
+		if err != nil {
+			s.Logger.Errorf("adminFetcherStatus: Error getting fetcher status, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.Logger.Debugf("adminFetcherStatus: No fetcher status published")
+			http.Error(w, "Fetcher not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		resp := response{
+			Running:             snap.CurrentCycle != nil,
+			CurrentCycle:        snap.CurrentCycle,
+			LastCycleDurationMs: snap.LastCycleDurationMs,
+			LastCycleFinishedAt: snap.LastCycleFinishedAt,
+			NextRunAt:           snap.NextRunAt,
+		}
+		if resp.LastCycleFinishedAt == nil {
+			// Nothing finished since the fetcher started, the last cycle is from before.
+			fc, err := s.DB.FetchCycleFindLastFinished(r.Context(), model.FetchCycleKindFull)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Errorf("adminFetcherStatus: Error finding last finished FetchCycle, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if err == nil {
+				resp.LastCycleDurationMs = &fc.DurationMs
+				resp.LastCycleFinishedAt = &fc.FinishedAt
+			}
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
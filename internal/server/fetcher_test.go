@@ -0,0 +1,75 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/logger"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+	"testing"
+	"time"
+)
+
+// TestAdminFetchCycle runs the admin FetchCycle route on the real router with a mock Database.
+func TestAdminFetchCycle(t *testing.T) {
+	const adminKey = "test-admin-key"
+	fc := model.FetchCycle{
+		ID:        primitive.NewObjectID(),
+		Kind:      model.FetchCycleKindRefetch,
+		StartedAt: primitive.NewDateTimeFromTime(time.Now()),
+		Total:     3,
+	}
+	db := &mock.Database{
+		FetchCycleFindFunc: func(ctx context.Context, id string) (model.FetchCycle, error) {
+			if id != fc.ID.Hex() {
+				return model.FetchCycle{}, mongo.ErrNoDocuments
+			}
+			return fc, nil
+		},
+	}
+	s := server.Server{
+		DB:          db,
+		Client:      &mock.Client{},
+		Logger:      logger.New(logger.LevelOff, io.Discard),
+		AdminAPIKey: adminKey,
+	}
+	router := s.Router()
+
+	tests := []struct {
+		name       string
+		id         string
+		adminKey   string
+		wantStatus int
+	}{
+		{name: "found", id: fc.ID.Hex(), adminKey: adminKey, wantStatus: http.StatusOK},
+		{name: "not found", id: primitive.NewObjectID().Hex(), adminKey: adminKey, wantStatus: http.StatusNotFound},
+		{name: "invalid admin key", id: fc.ID.Hex(), adminKey: "invalid", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/fetch/cycles/"+tt.id, nil)
+			req.Header.Set("X-Admin-Key", tt.adminKey)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d, body: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got model.FetchCycle
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("error unmarshalling response: %v", err)
+			}
+			if got.ID != fc.ID || got.Total != fc.Total {
+				t.Errorf("got FetchCycle %+v, want %+v", got, fc)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"pricetracker/internal/client"
+	"strings"
+	"time"
+)
+
+func (s Server) ReloadSiteFingerprintsInInterval(ctx context.Context, ticker *time.Ticker) {
+	for range ticker.C {
+		reloaded, err := s.Client.SiteFingerprintsReload()
+		if err != nil {
+			s.Logger.Errorf("ReloadSiteFingerprintsInInterval: Error reloading site fingerprints, keeping previous ones, err: %v", err)
+			continue
+		}
+		if reloaded {
+			s.Logger.Infof("ReloadSiteFingerprintsInInterval: Reloaded site fingerprints")
+		}
+	}
+}
+
+// redactSiteFingerprints returns fps with the values of their cookies, and of Cookie headers, replaced with "SET",
+// as they may be session cookies of site accounts.
+func redactSiteFingerprints(fps map[string]client.SiteFingerprint) map[string]client.SiteFingerprint {
+	redacted := make(map[string]client.SiteFingerprint, len(fps))
+	for site, fp := range fps {
+		cookies := make(map[string]string, len(fp.Cookies))
+		for name := range fp.Cookies {
+			cookies[name] = "SET"
+		}
+		fp.Cookies = cookies
+		headers := make(map[string]string, len(fp.Headers))
+		for name, value := range fp.Headers {
+			if strings.EqualFold(name, "Cookie") {
+				value = "SET"
+			}
+			headers[name] = value
+		}
+		fp.Headers = headers
+		redacted[site] = fp
+	}
+	return redacted
+}
+
+func (s Server) adminSiteFingerprints() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeJsonResponse(w, redactSiteFingerprints(s.Client.SiteFingerprints()), http.StatusOK)
+	}
+}
+
+func (s Server) adminSiteFingerprintsReload() http.HandlerFunc {
+	type response struct {
+		Reloaded bool                              `json:"reloaded"`
+		Sites    map[string]client.SiteFingerprint `json:"sites"`
+	}
+	openAPIRegister("adminSiteFingerprintsReload", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		reloaded, err := s.Client.SiteFingerprintsReload()
+		if err != nil {
+			s.Logger.Errorf("adminSiteFingerprintsReload: Error reloading site fingerprints, err: %v", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		s.Logger.Infof("adminSiteFingerprintsReload: Reloaded: %t", reloaded)
+		s.writeJsonResponse(w, response{Reloaded: reloaded, Sites: redactSiteFingerprints(s.Client.SiteFingerprints())}, http.StatusOK)
+	}
+}
@@ -0,0 +1,57 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/client"
+	"pricetracker/internal/logger"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/server"
+	"testing"
+)
+
+// TestAdminSiteFingerprints checks that the admin site fingerprints route does not expose cookie values.
+func TestAdminSiteFingerprints(t *testing.T) {
+	const adminKey = "test-admin-key"
+	c := &mock.Client{
+		SiteFingerprintsFunc: func() map[string]client.SiteFingerprint {
+			return map[string]client.SiteFingerprint{
+				client.SiteShopee: {
+					APIHost: "https://shopee.co.id",
+					Headers: map[string]string{"cookie": "SPC_EC=secret", "Accept-Language": "id"},
+					Cookies: map[string]string{"SPC_EC": "secret"},
+				},
+			}
+		},
+	}
+	s := server.Server{
+		DB:          &mock.Database{},
+		Client:      c,
+		Logger:      logger.New(logger.LevelOff, io.Discard),
+		AdminAPIKey: adminKey,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/fingerprints", nil)
+	req.Header.Set("X-Admin-Key", adminKey)
+	rec := httptest.NewRecorder()
+	s.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got map[string]client.SiteFingerprint
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("error unmarshalling response: %v", err)
+	}
+	fp := got[client.SiteShopee]
+	if fp.Cookies["SPC_EC"] != "SET" {
+		t.Errorf("got cookie SPC_EC %#v, want %#v", fp.Cookies["SPC_EC"], "SET")
+	}
+	if fp.Headers["cookie"] != "SET" {
+		t.Errorf("got cookie header %#v, want %#v", fp.Headers["cookie"], "SET")
+	}
+	if fp.Headers["Accept-Language"] != "id" {
+		t.Errorf("got Accept-Language header %#v, want %#v", fp.Headers["Accept-Language"], "id")
+	}
+}
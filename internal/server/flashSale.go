@@ -0,0 +1,25 @@
+package server
+
+import (
+	"pricetracker/internal/model"
+	"time"
+)
+
+const (
+	// flashSaleMaxStock is the highest stock a price drop can have to be a flash sale.
+	flashSaleMaxStock = 10
+	// flashSaleMinDropPercent is the minimum price drop of a flash sale in percent.
+	flashSaleMinDropPercent = 10
+	// flashSaleRecheckAfter is when an Item is fetched again after a flash sale was detected,
+	// flash sales usually end within this window.
+	flashSaleRecheckAfter = time.Hour
+)
+
+// isFlashSale reports whether the price change of i to the price of new looks like a flash sale,
+// a significant price drop on a nearly sold out Item.
+func isFlashSale(i model.Item, new model.Item) bool {
+	if i.Price <= 0 || new.Price >= i.Price || new.Stock <= 0 || new.Stock > flashSaleMaxStock {
+		return false
+	}
+	return (i.Price-new.Price)*100 >= i.Price*flashSaleMinDropPercent
+}
@@ -1,8 +1,24 @@
 package server
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"github.com/pkg/errors"
+	"net"
 	"net/http"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of the source field of responses with marketplace data, telling where the data was read from.
+const (
+	dataSourceLive  = "live"
+	dataSourceCache = "cache"
 )
 
 func (s Server) writeJsonResponse(w http.ResponseWriter, response any, statusCode int) {
@@ -18,9 +34,166 @@ func (s Server) writeJsonResponse(w http.ResponseWriter, response any, statusCod
 	}
 }
 
+// writeJsonResponseETag writes response with an ETag of its hash, or only 304 Not Modified when the If-None-Match
+// header of r has the same ETag, for clients polling the same response to revalidate it without downloading it again.
+func (s Server) writeJsonResponseETag(w http.ResponseWriter, r *http.Request, response any) {
+	resp, err := json.Marshal(response)
+	if err != nil {
+		s.Logger.Errorf("Error encoding response: %+v, err: %v", response, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	h := sha256.Sum256(resp)
+	etag := `"` + hex.EncodeToString(h[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if t = strings.TrimPrefix(strings.TrimSpace(t), "W/"); t == etag || t == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(resp); err != nil {
+		s.Logger.Errorf("Error writing JSON response: %s, err: %v", resp, err)
+	}
+}
+
+// writeServiceError logs err returned by a service to a handler and writes its status code.
+func (s Server) writeServiceError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		s.Logger.Debugf("%s: Bad url, err: %v", handler, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrVariationUnsupported):
+		s.Logger.Debugf("%s: Variation not supported, err: %v", handler, err)
+		http.Error(w, service.ErrVariationUnsupported.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrVariantNotFound):
+		s.Logger.Debugf("%s: Variant not found, err: %v", handler, err)
+		http.Error(w, service.ErrVariantNotFound.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrItemNotFound):
+		s.Logger.Debugf("%s: Item not found, err: %v", handler, err)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, service.ErrSiteUnavailable):
+		s.Logger.Errorf("%s: Site unavailable, err: %v", handler, err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case errors.Is(err, service.ErrTrackedItemsLimit):
+		s.Logger.Debugf("%s: Failed to add item, err: %v", handler, err)
+		http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity)
+	default:
+		s.Logger.Errorf("%s: Error, err: %v", handler, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
 func (s Server) notFoundHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		s.Logger.Debugf("notFoundHandler: Requested resource not found, TraceID: %s", getTraceContext(r.Context()).traceID)
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 	}
 }
+
+func requestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// pagination holds the offset and limit query parameters of a paginated endpoint, limit is zero when not supplied.
+type pagination struct {
+	offset int64
+	limit  int64
+}
+
+func parsePagination(r *http.Request, maxLimit int64) (pagination, error) {
+	var p pagination
+	if o := r.URL.Query().Get("offset"); o != "" {
+		offset, err := strconv.ParseInt(o, 10, 64)
+		if err != nil || offset < 0 {
+			return p, errors.Errorf("invalid offset: %#v", o)
+		}
+		p.offset = offset
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			return p, errors.Errorf("invalid limit: %#v, maximum limit: %d", l, maxLimit)
+		}
+		p.limit = limit
+	}
+	return p, nil
+}
+
+// parseTimeQuery sets t to the RFC 3339 timestamp of the query parameter name, leaving it as is when not supplied.
+func parseTimeQuery(r *http.Request, name string, t *time.Time) error {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil
+	}
+	pt, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s: %#v", name, v)
+	}
+	*t = pt
+	return nil
+}
+
+// categoryMaxLength is the longest category accepted by the category query parameter.
+const categoryMaxLength = 100
+
+// parseCategory returns the category query parameter of an endpoint filtering Items by category, empty when not supplied.
+func parseCategory(r *http.Request) (string, error) {
+	c := strings.Join(strings.Fields(r.URL.Query().Get("category")), " ")
+	if len(c) > categoryMaxLength {
+		return "", errors.Errorf("invalid category, maximum length: %d", categoryMaxLength)
+	}
+	return c, nil
+}
+
+// itemFields maps the JSON field names of an Item to their bson field names, Item fields not in responses are left out.
+var itemFields = func() map[string]string {
+	t := reflect.TypeOf(model.Item{})
+	m := make(map[string]string, t.NumField())
+	for idx := 0; idx < t.NumField(); idx++ {
+		f := t.Field(idx)
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		bsonName, _, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		if jsonName != "" && jsonName != "-" && bsonName != "" && bsonName != "-" {
+			m[jsonName] = bsonName
+		}
+	}
+	return m
+}()
+
+// parseItemFields returns the Item JSON field names of the comma separated fields query parameter of an endpoint
+// returning Items and their bson field names, both are empty when it is not supplied.
+func parseItemFields(r *http.Request) (jsonFields []string, bsonFields []string, err error) {
+	fs := r.URL.Query().Get("fields")
+	if fs == "" {
+		return nil, nil, nil
+	}
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(fs, ",") {
+		f = strings.TrimSpace(f)
+		bsonName, ok := itemFields[f]
+		if !ok {
+			return nil, nil, errors.Errorf("invalid field: %#v", f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			jsonFields = append(jsonFields, f)
+			bsonFields = append(bsonFields, bsonName)
+		}
+	}
+	return jsonFields, bsonFields, nil
+}
+
+// setNextOffset tells the client where the next page starts when there are more results after the current page.
+func (p pagination) setNextOffset(w http.ResponseWriter, returned int, more bool) {
+	if more {
+		w.Header().Set("X-Next-Offset", strconv.FormatInt(p.offset+int64(returned), 10))
+	}
+}
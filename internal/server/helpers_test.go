@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"context"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/http/httptest"
+	"pricetracker/internal/logger"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"pricetracker/internal/server"
+	"strings"
+	"testing"
+)
+
+const testDeviceID = "test-device"
+
+// newTestServer returns a Server with db, a mock Client and an auth secret key, logging nothing.
+func newTestServer(t *testing.T, db *mock.Database) server.Server {
+	t.Helper()
+	key, err := jwk.FromRaw([]byte("test-auth-secret-key-0000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server.Server{
+		DB:            db,
+		Client:        &mock.Client{TelegramEnabledFunc: func() bool { return true }},
+		Logger:        logger.New(logger.LevelOff, io.Discard),
+		AuthSecretKey: key,
+	}
+}
+
+// loginTestUser adds a Device logged in with the returned login token to u, and makes db find u by its ID for authMw.
+func loginTestUser(t *testing.T, s server.Server, db *mock.Database, u *model.User) string {
+	t.Helper()
+	if u.ID.IsZero() {
+		u.ID = primitive.NewObjectID()
+	}
+	lt, _, tokenHash, err := s.CreateLoginTokenAndHash(u.ID.Hex(), testDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Devices = append(u.Devices, model.Device{DeviceID: testDeviceID, LoginToken: model.LoginToken{Token: tokenHash}})
+	db.UserFindByIDFunc = func(ctx context.Context, id string) (model.User, error) {
+		return *u, nil
+	}
+	db.UserDeviceLastSeenUpdateFunc = func(ctx context.Context, userID string, deviceID string) error {
+		return nil
+	}
+	return lt
+}
+
+// serveAuthenticated serves a request with body and login token lt on the router of s.
+func serveAuthenticated(s server.Server, method string, path string, body string, lt string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+lt)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.Router().ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+)
+
+const routeItemSearchByImage = "itemSearchByImage"
+
+const imageSearchMaxBytes = 5 << 20
+
+// imageSearchContentTypes are the accepted image types, as sniffed by http.DetectContentType.
+var imageSearchContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// itemSearchByImage searches for the product in an uploaded photo, with Shopee image search and, when a vision
+// backend is configured, with the text query the backend describes the photo with on every site.
+func (s Server) itemSearchByImage() http.HandlerFunc {
+	type response struct {
+		Items  []model.Item `json:"items"`
+		Query  string       `json:"query,omitempty"`
+		Source string       `json:"source"`
+		// TimedOutSites are the sites left out of the image or query search because they did not respond in time.
+		TimedOutSites []string `json:"timed_out_sites,omitempty"`
+	}
+	openAPIRegister("itemSearchByImage", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		tid := getTraceContext(r.Context()).traceID
+		f, _, err := r.FormFile("image")
+		if err != nil {
+			s.Logger.Debugf("itemSearchByImage: Error getting uploaded image, err: %v, TraceID: %s", err, tid)
+			http.Error(w, "Missing image", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		image, err := io.ReadAll(f)
+		if err != nil {
+			s.Logger.Debugf("itemSearchByImage: Error reading uploaded image, err: %v, TraceID: %s", err, tid)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		contentType := http.DetectContentType(image)
+		if !imageSearchContentTypes[contentType] {
+			s.Logger.Debugf("itemSearchByImage: Unsupported image type: %s, TraceID: %s", contentType, tid)
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var timedOut []string
+		// The image search is left out past the deadline of the sites in the query search as well.
+		imageCtx, cancel := context.WithTimeout(r.Context(), searchDeadline)
+		items, err := s.Client.ShopeeSearchByImage(imageCtx, image)
+		switch {
+		case err != nil && imageCtx.Err() != nil && r.Context().Err() == nil:
+			s.Logger.Infof("itemSearchByImage: Shopee image search timed out, TraceID: %s", tid)
+			timedOut = append(timedOut, "Shopee")
+		case err != nil:
+			s.Logger.Errorf("itemSearchByImage: Error searching Shopee by image, err: %v, TraceID: %s", err, tid)
+		default:
+			s.Logger.Debugf("itemSearchByImage: Searched Shopee by image, %d item(s) found, TraceID: %s", len(items), tid)
+		}
+		cancel()
+
+		var query string
+		if s.Client.VisionEnabled() {
+			if query, err = s.Client.VisionQuery(r.Context(), image, contentType); err != nil {
+				s.Logger.Errorf("itemSearchByImage: Error getting query from vision backend, err: %v, TraceID: %s", err, tid)
+			} else if query != "" {
+				s.Logger.Infof("itemSearchByImage: Searching items with vision query: %#v, TraceID: %s", query, tid)
+				queryItems, queryTimedOut := s.searchItems(r.Context(), [2]string{query}, tid)
+				items = mergeItemSlices(items, queryItems)
+				for _, site := range queryTimedOut {
+					if !misc.Contains(timedOut, site) {
+						timedOut = append(timedOut, site)
+					}
+				}
+			}
+		}
+		if items == nil {
+			items = []model.Item{}
+		}
+		s.writeJsonResponse(w, response{
+			Items:         s.withoutArchived(r.Context(), items),
+			Query:         query,
+			Source:        dataSourceLive,
+			TimedOutSites: timedOut,
+		}, http.StatusOK)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// withoutArchived removes the Items that are archived in DB from is, is is returned as is when DB fails.
+func (s Server) withoutArchived(ctx context.Context, is []model.Item) []model.Item {
+	archived, err := s.DB.ItemsArchivedFind(ctx, is)
+	if err != nil {
+		s.Logger.Errorf("withoutArchived: Error finding archived Items, err: %v", err)
+		return is
+	}
+	if len(archived) == 0 {
+		return is
+	}
+	archivedKeys := make(map[string]bool, len(archived))
+	for _, i := range archived {
+		archivedKeys[i.Site+"/"+i.MerchantID+"/"+i.ProductID] = true
+	}
+	filtered := make([]model.Item, 0, len(is))
+	for _, i := range is {
+		if !archivedKeys[i.Site+"/"+i.MerchantID+"/"+i.ProductID] {
+			filtered = append(filtered, i)
+		}
+	}
+	return filtered
+}
+
+func (s Server) adminItemArchive() http.HandlerFunc {
+	type response struct {
+		ItemID   string `json:"item_id"`
+		Archived bool   `json:"archived"`
+	}
+	openAPIRegister("adminItemArchive", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID := mux.Vars(r)["itemID"]
+		itemOID, err := primitive.ObjectIDFromHex(itemID)
+		if err != nil {
+			s.Logger.Debugf("adminItemArchive: Invalid ItemID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if err = s.DB.ItemArchive(r.Context(), itemOID, model.ItemArchiveReasonAdmin, time.Now()); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("adminItemArchive: Item with ID: %s not found or already archived, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+				return
+			}
+			s.Logger.Errorf("adminItemArchive: Error archiving Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminItemArchive: Archived Item with ID: %s", itemID)
+		s.writeJsonResponse(w, response{ItemID: itemID, Archived: true}, http.StatusOK)
+	}
+}
+
+func (s Server) adminItemUnarchive() http.HandlerFunc {
+	type response struct {
+		ItemID   string `json:"item_id"`
+		Archived bool   `json:"archived"`
+	}
+	openAPIRegister("adminItemUnarchive", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID := mux.Vars(r)["itemID"]
+		itemOID, err := primitive.ObjectIDFromHex(itemID)
+		if err != nil {
+			s.Logger.Debugf("adminItemUnarchive: Invalid ItemID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if err = s.DB.ItemUnarchive(r.Context(), itemOID); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("adminItemUnarchive: Item with ID: %s not found or not archived, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+				return
+			}
+			s.Logger.Errorf("adminItemUnarchive: Error unarchiving Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminItemUnarchive: Unarchived Item with ID: %s", itemID)
+		s.writeJsonResponse(w, response{ItemID: itemID, Archived: false}, http.StatusOK)
+	}
+}
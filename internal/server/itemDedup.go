@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// itemDedupBatchSize is how many listings with duplicate Items are merged per tick.
+const itemDedupBatchSize = 100
+
+// DedupItemsInInterval merges the Items stored more than once for the same listing on every tick. The first run looks
+// at every listing, the following ones only at the listings of Items created or updated since the last complete run.
+// Merges are done in transactions, so it needs Database transactions.
+func (s Server) DedupItemsInInterval(ctx context.Context, ticker *time.Ticker) {
+	s.Logger.Info("DedupItemsInInterval: Starting Item deduplication")
+	var since time.Time
+	for range ticker.C {
+		startedAt := time.Now()
+		if s.dedupItems(ctx, since) {
+			since = startedAt
+		}
+	}
+}
+
+// dedupItems merges every duplicate Item of the listings of Items updated since since into the oldest Item of the
+// listing, which Users tracking the duplicates track afterwards. It returns whether every duplicate was merged,
+// otherwise the next run looks at the same Items again.
+func (s Server) dedupItems(ctx context.Context, since time.Time) bool {
+	dups, err := s.DB.ItemDuplicatesFind(ctx, since, itemDedupBatchSize)
+	if err != nil {
+		s.Logger.Errorf("dedupItems: Error finding duplicate Items, err: %v", err)
+		return false
+	}
+	complete := len(dups) < itemDedupBatchSize
+	var merged int
+	for _, d := range dups {
+		itemID := d.ItemIDs[0]
+		for _, dupID := range d.ItemIDs[1:] {
+			err = s.DB.WithTransaction(ctx, func(ctx context.Context) error {
+				return s.DB.ItemMerge(ctx, itemID, dupID)
+			})
+			if err != nil {
+				s.Logger.Errorf("dedupItems: Error merging duplicate Item with ID: %s into Item with ID: %s, err: %v",
+					dupID.Hex(), itemID.Hex(), err)
+				complete = false
+				continue
+			}
+			s.Logger.Infof("dedupItems: Merged duplicate Item with ID: %s into Item with ID: %s, Site: %s, ProductID: %s",
+				dupID.Hex(), itemID.Hex(), d.Key.Site, d.Key.ProductID)
+			merged++
+		}
+	}
+	if merged > 0 {
+		s.Logger.Infof("dedupItems: Merged %d duplicate Item(s) of %d listing(s)", merged, len(dups))
+	}
+	return complete
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/forecast"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// itemForecastHistoryDays is how many days of ItemHistory forecasts are made from.
+const itemForecastHistoryDays = 90
+
+// itemForecastHorizons are the days ahead the price range of an Item is forecast for.
+var itemForecastHorizons = []int{7, 30}
+
+// itemForecast returns the expected price range of the Item over the next 7 and 30 days, Forecasts is empty when the
+// Item has less than forecast.MinDays days of ItemHistory.
+func (s Server) itemForecast() http.HandlerFunc {
+	type response struct {
+		ItemID         string           `json:"item_id"`
+		Current        int              `json:"current"`
+		Currency       string           `json:"currency"`
+		HistoryDays    int              `json:"history_days"`
+		MinHistoryDays int              `json:"min_history_days"`
+		Forecasts      []forecast.Range `json:"forecasts"`
+		GeneratedAt    time.Time        `json:"generated_at"`
+	}
+	openAPIRegister("itemForecast", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID := mux.Vars(r)["itemID"]
+		i, err := s.DB.ItemFindOne(r.Context(), itemID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemForecast: No documents found for Item with ID: %s, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("itemForecast: Error finding Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		end := time.Now()
+		start := end.AddDate(0, 0, -itemForecastHistoryDays)
+		ihbs, err := s.DB.ItemHistoryAggregate(r.Context(), i.ID.Hex(), start, end, model.ItemHistoryIntervalDay, time.UTC)
+		if err != nil {
+			s.Logger.Errorf("itemForecast: Error aggregating ItemHistory for Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		forecasts, _ := forecast.Forecast(ihbs, i.Price, itemForecastHorizons...)
+		if forecasts == nil {
+			forecasts = []forecast.Range{}
+		}
+		s.writeJsonResponse(w, response{
+			ItemID:         i.ID.Hex(),
+			Current:        i.Price,
+			Currency:       model.CurrencyOrDefault(i.Currency),
+			HistoryDays:    len(ihbs),
+			MinHistoryDays: forecast.MinDays,
+			Forecasts:      forecasts,
+			GeneratedAt:    end,
+		}, http.StatusOK)
+	}
+}
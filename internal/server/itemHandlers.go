@@ -1,61 +1,55 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/errgroup"
 	"net/http"
-	"net/url"
 	"pricetracker/internal/client"
+	"pricetracker/internal/database"
 	"pricetracker/internal/misc"
 	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-type siteType int
-
-const (
-	siteTypeInvalid siteType = iota
-	siteShopee
-	siteTokopedia
-	siteBlibli
-)
-
-func siteTypeAndCleanURL(urlStr string) (siteType, string, error) {
-	parsedURL, err := url.Parse(urlStr)
-	if err != nil {
-		return siteTypeInvalid, "", err
+// itemSiteName returns the Item Site name matching site case-insensitively.
+func (s Server) itemSiteName(site string) (string, bool) {
+	sr, ok := s.Scrapers.Site(site)
+	if !ok {
+		return "", false
 	}
-	if parsedURL.Host == "" {
-		parsedURL, err = url.Parse("https://" + urlStr)
-		if err != nil {
-			return siteTypeInvalid, "", err
-		}
-	}
-	cleanURL := "https://" + parsedURL.Host + parsedURL.Path
-	if parsedURL.Host == "shopee.co.id" {
-		return siteShopee, cleanURL, nil
-	} else if parsedURL.Host == "www.tokopedia.com" || parsedURL.Host == "tokopedia.com" || parsedURL.Host == "tokopedia.link" {
-		return siteTokopedia, cleanURL, nil
-	} else if parsedURL.Host == "www.blibli.com" || parsedURL.Host == "blibli.com" || parsedURL.Host == "blibli.app.link" {
-		return siteBlibli, cleanURL, nil
-	}
-	return siteTypeInvalid, "", errors.Errorf("invalid site url: %s", cleanURL)
+	return sr.Site(), true
 }
 
 func (s Server) itemAdd() http.HandlerFunc {
 	type request struct {
-		URL                 string `json:"url"`
-		PriceLowerThreshold int    `json:"price_lower_threshold"`
-		NotificationEnabled bool   `json:"notification_enabled"`
+		URL                     string `json:"url"`
+		PriceLowerThreshold     int    `json:"price_lower_threshold"`
+		PercentageDropThreshold int    `json:"percentage_drop_threshold"`
+		NotificationEnabled     bool   `json:"notification_enabled"`
+		NotifyOnRestock         bool   `json:"notify_on_restock"`
+		VariationID             string `json:"variation_id"`
+		// Barcode is the barcode the item was found with, it is submitted for moderation and once approved used to
+		// match the item with the same product on other sites.
+		Barcode string `json:"barcode"`
 	}
 	type response struct {
 		ItemID string `json:"item_id"`
 		model.TrackedItem
 		Item model.Item `json:"item"`
+		// BarcodeSubmissionID is the pending BarcodeSubmission of the barcode, missing when none was submitted.
+		BarcodeSubmissionID string `json:"barcode_submission_id,omitempty"`
 	}
+	openAPIRegister("itemAdd", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -71,139 +65,57 @@ func (s Server) itemAdd() http.HandlerFunc {
 			return
 		}
 
-		urlSiteType, cleanURL, err := siteTypeAndCleanURL(req.URL)
-		if err != nil {
-			s.Logger.Debugf("itemAdd: Bad url: %s, err: %v", req.URL, err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if req.PercentageDropThreshold < 0 || req.PercentageDropThreshold > 99 {
+			s.Logger.Debugf("itemAdd: Invalid percentage_drop_threshold: %d", req.PercentageDropThreshold)
+			http.Error(w, "percentage_drop_threshold must be between 0 and 99", http.StatusBadRequest)
 			return
 		}
-		var ecommerceItem model.Item
-		switch urlSiteType {
-		case siteShopee:
-			ecommerceItem, err = s.Client.ShopeeGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrShopee) {
-					s.Logger.Errorf("itemAdd: Error getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrShopeeItemNotFound) {
-					s.Logger.Debugf("itemAdd: Item not found when getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemAdd: Error getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-		case siteTokopedia:
-			ecommerceItem, err = s.Client.TokopediaGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrTokopedia) {
-					s.Logger.Errorf("itemAdd: Error getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrTokopediaItemNotFound) {
-					s.Logger.Debugf("itemAdd: Item not found when getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemAdd: Error getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-		case siteBlibli:
-			ecommerceItem, err = s.Client.BlibliGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrBlibli) {
-					s.Logger.Errorf("itemAdd: Error getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrBlibliItemNotFound) {
-					s.Logger.Debugf("itemAdd: Item not found when getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemAdd: Error getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-		}
-		i, err := s.DB.ItemFindExisting(r.Context(), ecommerceItem)
-		if err != nil {
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				i = ecommerceItem
-				i.PriceHistoryHighest = i.Price
-				i.PriceHistoryLowest = i.Price
-				itemID, err := s.DB.ItemInsert(r.Context(), i)
-				if err != nil {
-					s.Logger.Errorf("itemAdd: Error inserting Item, err: %v", err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-				i.ID, err = primitive.ObjectIDFromHex(itemID)
-				if err != nil {
-					s.Logger.Errorf("itemAdd: Error creating ObjectID from hex: %s, err: %v", itemID, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-				ih := model.ItemHistory{
-					ItemID:    i.ID,
-					Price:     ecommerceItem.Price,
-					Stock:     ecommerceItem.Stock,
-					Rating:    ecommerceItem.Rating,
-					Sold:      ecommerceItem.Sold,
-					Timestamp: primitive.NewDateTimeFromTime(time.Now()),
-				}
-				if err = s.DB.ItemHistoryInsert(r.Context(), ih); err != nil {
-					s.Logger.Errorf("itemAdd: Error inserting ItemHistory, err: %v", err)
-				}
-			} else {
-				s.Logger.Errorf("itemAdd: Error finding existing Item, err: %v", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-		} else {
-			i.UpdateWith(ecommerceItem)
-			if err = s.DB.ItemUpdate(r.Context(), i); err != nil {
-				s.Logger.Errorf("itemAdd: Error updating existing Item, err: %v", err)
-			}
+		if req.Barcode != "" && (len(req.Barcode) < 8 || len(req.Barcode) > 14 || !misc.IsNum(req.Barcode)) {
+			s.Logger.Debugf("itemAdd: Invalid barcode: %#v", req.Barcode)
+			http.Error(w, "Invalid barcode", http.StatusBadRequest)
+			return
 		}
 
-		tracked := itemTracked(i.ID.Hex(), uc.user.TrackedItems)
-		if len(uc.user.TrackedItems) >= 25 && !tracked {
-			s.Logger.Debugf("itemAdd: Failed to add item, TrackedItems are limited to 25 for each User, UserID: %s, ItemID: %s",
-				uc.user.ID.Hex(), i.ID.Hex())
-			http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity)
+		sc, err := s.Items.Scrape(r.Context(), req.URL, req.VariationID)
+		if err != nil {
+			s.writeServiceError(w, "itemAdd", err)
 			return
 		}
-		ti := model.TrackedItem{
-			ItemID:              i.ID,
-			PriceInitial:        i.Price,
-			PriceLowerThreshold: req.PriceLowerThreshold,
-			NotificationCount:   0,
-			NotificationEnabled: req.NotificationEnabled,
-		}
-		if tracked {
-			if err = s.DB.UserTrackedItemUpdate(r.Context(), uc.user.ID.Hex(), ti); err != nil {
-				s.Logger.Errorf("itemAdd: Error updating TrackedItem on User, err: %v", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
+		// The Item, its first ItemHistory and the TrackedItem are written together or not at all.
+		var i model.Item
+		var ti model.TrackedItem
+		err = s.DB.WithTransaction(r.Context(), func(ctx context.Context) error {
+			var err error
+			if i, err = s.Items.FindOrInsert(ctx, sc.Item, ""); err != nil {
+				return err
 			}
-		} else {
-			if err = s.DB.UserTrackedItemAdd(r.Context(), uc.user.ID.Hex(), ti); err != nil {
-				s.Logger.Errorf("itemAdd: Error adding TrackedItem to User, err: %v", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
+			priceInitial, _ := i.PriceAndStock(sc.VariationID)
+			ti = model.TrackedItem{
+				ItemID:                  i.ID,
+				VariationID:             sc.VariationID,
+				PriceInitial:            priceInitial,
+				PriceLowerThreshold:     req.PriceLowerThreshold,
+				PercentageDropThreshold: req.PercentageDropThreshold,
+				NotificationCount:       0,
+				NotificationEnabled:     req.NotificationEnabled,
+				NotifyOnRestock:         req.NotifyOnRestock,
 			}
+			return s.Users.TrackItem(ctx, uc.user, ti, s.trackedItemsLimit(uc.user))
+		})
+		if err != nil {
+			s.writeServiceError(w, "itemAdd", err)
+			return
 		}
-		s.writeJsonResponse(w, response{
+		s.rewardReferredUser(r.Context(), uc.user)
+		resp := response{
 			ItemID:      i.ID.Hex(),
 			TrackedItem: ti,
 			Item:        i,
-		}, http.StatusOK)
+		}
+		if req.Barcode != "" && i.Barcode == "" {
+			resp.BarcodeSubmissionID = s.itemBarcodeSubmit(r.Context(), uc.user, i, req.Barcode)
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
 	}
 }
 
@@ -211,7 +123,13 @@ func (s Server) itemCheck() http.HandlerFunc {
 	type request struct {
 		URL string `json:"url"`
 	}
-	type response model.Item
+	type response struct {
+		model.Item
+		DataAgeSeconds int64               `json:"data_age_seconds"`
+		Source         string              `json:"source"`
+		Variants       []model.ItemVariant `json:"variants,omitempty"`
+	}
+	openAPIRegister("itemCheck", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := request{}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -220,97 +138,40 @@ func (s Server) itemCheck() http.HandlerFunc {
 			return
 		}
 
-		urlSiteType, cleanURL, err := siteTypeAndCleanURL(req.URL)
+		sc, err := s.Items.Scrape(r.Context(), req.URL, "")
 		if err != nil {
-			s.Logger.Debugf("itemCheck: Bad url: %s, err: %v", req.URL, err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			s.writeServiceError(w, "itemCheck", err)
 			return
 		}
-
-		var ecommerceItem model.Item
-		switch urlSiteType {
-		case siteShopee:
-			ecommerceItem, err = s.Client.ShopeeGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrShopee) {
-					s.Logger.Errorf("itemCheck: Error getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrShopeeItemNotFound) {
-					s.Logger.Debugf("itemCheck: Item not found when getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemCheck: Error getting Shopee item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-		case siteTokopedia:
-			ecommerceItem, err = s.Client.TokopediaGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrTokopedia) {
-					s.Logger.Errorf("itemCheck: Error getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrTokopediaItemNotFound) {
-					s.Logger.Debugf("itemCheck: Item not found when getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemCheck: Error getting Tokopedia item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-		case siteBlibli:
-			ecommerceItem, err = s.Client.BlibliGetItem(cleanURL)
-			if err != nil {
-				if errors.Is(err, client.ErrBlibli) {
-					s.Logger.Errorf("itemCheck: Error getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-					return
-				} else if errors.Is(err, client.ErrBlibliItemNotFound) {
-					s.Logger.Debugf("itemCheck: Item not found when getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				} else {
-					s.Logger.Errorf("itemCheck: Error getting Blibli item with url: %s, err: %v", cleanURL, err)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
+		i, err := s.Items.Refresh(r.Context(), sc.Item)
+		if err != nil {
+			s.writeServiceError(w, "itemCheck", err)
+			return
 		}
-		i, err := s.DB.ItemFindExisting(r.Context(), ecommerceItem)
+		variants, err := s.Items.Variants(r.Context(), sc, i)
 		if err != nil {
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				i = ecommerceItem
-				i.PriceHistoryHighest = i.Price
-				i.PriceHistoryLowest = i.Price
-			} else {
-				s.Logger.Errorf("itemCheck: Error finding existing Item, err: %v", err)
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-		} else {
-			i.UpdateWith(ecommerceItem)
-			if err = s.DB.ItemUpdate(r.Context(), i); err != nil {
-				s.Logger.Errorf("itemCheck: Error updating existing Item, err: %v", err)
-			}
+			s.Logger.Errorf("itemCheck: Error getting item variants with url: %s, err: %v", sc.URL, err)
 		}
-		s.writeJsonResponse(w, response(i), http.StatusOK)
+		s.writeJsonResponse(w, response{Item: i, Source: dataSourceLive, Variants: variants}, http.StatusOK)
 	}
 }
 
 func (s Server) itemUpdate() http.HandlerFunc {
 	type request struct {
-		ItemID              string `json:"item_id"`
-		PriceLowerThreshold int    `json:"price_lower_threshold"`
-		NotificationEnabled bool   `json:"notification_enabled"`
+		ItemID                  string     `json:"item_id"`
+		PriceLowerThreshold     int        `json:"price_lower_threshold"`
+		PercentageDropThreshold int        `json:"percentage_drop_threshold"`
+		NotificationEnabled     bool       `json:"notification_enabled"`
+		NotifyOnRestock         bool       `json:"notify_on_restock"`
+		RatingAlertBelow        float64    `json:"rating_alert_below"`
+		SoldJumpAlert           int        `json:"sold_jump_alert"`
+		ActiveFrom              *time.Time `json:"active_from"`
+		ActiveUntil             *time.Time `json:"active_until"`
 	}
 	type response struct {
 		Success bool `json:"success"`
 	}
+	openAPIRegister("itemUpdate", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -326,6 +187,22 @@ func (s Server) itemUpdate() http.HandlerFunc {
 			return
 		}
 
+		if req.PercentageDropThreshold < 0 || req.PercentageDropThreshold > 99 {
+			s.Logger.Debugf("itemUpdate: Invalid percentage_drop_threshold: %d", req.PercentageDropThreshold)
+			http.Error(w, "percentage_drop_threshold must be between 0 and 99", http.StatusBadRequest)
+			return
+		}
+		if req.RatingAlertBelow < 0 || req.RatingAlertBelow > 5 {
+			s.Logger.Debugf("itemUpdate: Invalid rating_alert_below: %v", req.RatingAlertBelow)
+			http.Error(w, "rating_alert_below must be between 0 and 5", http.StatusBadRequest)
+			return
+		}
+		if req.SoldJumpAlert < 0 {
+			s.Logger.Debugf("itemUpdate: Invalid sold_jump_alert: %d", req.SoldJumpAlert)
+			http.Error(w, "sold_jump_alert must not be negative", http.StatusBadRequest)
+			return
+		}
+
 		if !itemTracked(req.ItemID, uc.user.TrackedItems) {
 			s.Logger.Debugf("itemUpdate: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), req.ItemID)
 			s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
@@ -339,10 +216,25 @@ func (s Server) itemUpdate() http.HandlerFunc {
 			return
 		}
 		ti := model.TrackedItem{
-			ItemID:              itemOID,
-			PriceLowerThreshold: req.PriceLowerThreshold,
-			NotificationEnabled: req.NotificationEnabled,
-			NotificationCount:   0,
+			ItemID:                  itemOID,
+			PriceLowerThreshold:     req.PriceLowerThreshold,
+			PercentageDropThreshold: req.PercentageDropThreshold,
+			NotificationEnabled:     req.NotificationEnabled,
+			NotifyOnRestock:         req.NotifyOnRestock,
+			RatingAlertBelow:        req.RatingAlertBelow,
+			SoldJumpAlert:           req.SoldJumpAlert,
+			NotificationCount:       0,
+		}
+		if req.ActiveFrom != nil {
+			ti.ActiveFrom = primitive.NewDateTimeFromTime(*req.ActiveFrom)
+		}
+		if req.ActiveUntil != nil {
+			if !req.ActiveUntil.After(time.Now()) || (req.ActiveFrom != nil && !req.ActiveUntil.After(*req.ActiveFrom)) {
+				s.Logger.Debugf("itemUpdate: Invalid alert window, active_from: %v, active_until: %v", req.ActiveFrom, req.ActiveUntil)
+				http.Error(w, "active_until must be in the future and after active_from", http.StatusBadRequest)
+				return
+			}
+			ti.ActiveUntil = primitive.NewDateTimeFromTime(*req.ActiveUntil)
 		}
 		if err = s.DB.UserTrackedItemUpdate(r.Context(), uc.user.ID.Hex(), ti); err != nil {
 			s.Logger.Errorf("itemUpdate: Error updating TrackedItem for User with ID: %s, TrackedItem: %+v, err: %v", uc.user.ID.Hex(), ti, err)
@@ -353,6 +245,107 @@ func (s Server) itemUpdate() http.HandlerFunc {
 	}
 }
 
+func (s Server) itemUpdateBatch() http.HandlerFunc {
+	type request struct {
+		ItemIDs                      []string `json:"item_ids"`
+		PriceLowerThreshold          *int     `json:"price_lower_threshold"`
+		ThresholdPercentBelowCurrent *float64 `json:"threshold_percent_below_current"`
+		NotificationEnabled          *bool    `json:"notification_enabled"`
+	}
+	type response struct {
+		Success      bool `json:"success"`
+		UpdatedCount int  `json:"updated_count"`
+	}
+	openAPIRegister("itemUpdateBatch", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemUpdateBatch: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("itemUpdateBatch: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if req.PriceLowerThreshold != nil && req.ThresholdPercentBelowCurrent != nil {
+			s.Logger.Debugf("itemUpdateBatch: Both price_lower_threshold and threshold_percent_below_current supplied")
+			http.Error(w, "Only one of price_lower_threshold or threshold_percent_below_current may be set", http.StatusBadRequest)
+			return
+		}
+		if req.PriceLowerThreshold == nil && req.ThresholdPercentBelowCurrent == nil && req.NotificationEnabled == nil {
+			s.Logger.Debugf("itemUpdateBatch: No updates supplied")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if p := req.ThresholdPercentBelowCurrent; p != nil && (*p < 0 || *p >= 100) {
+			s.Logger.Debugf("itemUpdateBatch: Invalid threshold_percent_below_current: %v", *p)
+			http.Error(w, "Invalid threshold_percent_below_current", http.StatusBadRequest)
+			return
+		}
+
+		var itemIDs []primitive.ObjectID
+		if len(req.ItemIDs) == 0 {
+			for _, ti := range uc.user.TrackedItems {
+				itemIDs = append(itemIDs, ti.ItemID)
+			}
+		} else {
+			for _, id := range req.ItemIDs {
+				if !itemTracked(id, uc.user.TrackedItems) {
+					s.Logger.Debugf("itemUpdateBatch: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), id)
+					s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
+					return
+				}
+				itemOID, _ := primitive.ObjectIDFromHex(id)
+				itemIDs = append(itemIDs, itemOID)
+			}
+		}
+
+		var prices map[primitive.ObjectID]int
+		if req.ThresholdPercentBelowCurrent != nil {
+			is, err := s.DB.ItemsFind(r.Context(), itemIDs)
+			if err != nil {
+				s.Logger.Errorf("itemUpdateBatch: Error finding Items, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			prices = make(map[primitive.ObjectID]int, len(is))
+			for _, i := range is {
+				prices[i.ID] = i.Price
+			}
+		}
+
+		tius := make([]database.TrackedItemUpdate, 0, len(itemIDs))
+		for _, itemID := range itemIDs {
+			tiu := database.TrackedItemUpdate{
+				ItemID:              itemID,
+				PriceLowerThreshold: req.PriceLowerThreshold,
+				NotificationEnabled: req.NotificationEnabled,
+			}
+			if req.ThresholdPercentBelowCurrent != nil {
+				price, ok := prices[itemID]
+				if !ok {
+					continue
+				}
+				threshold := int(float64(price) * (100 - *req.ThresholdPercentBelowCurrent) / 100)
+				tiu.PriceLowerThreshold = &threshold
+			}
+			tius = append(tius, tiu)
+		}
+
+		updated, err := s.DB.UserTrackedItemsBulkUpdate(r.Context(), uc.user.ID.Hex(), tius)
+		if err != nil {
+			s.Logger.Errorf("itemUpdateBatch: Error bulk updating TrackedItems for User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{Success: true, UpdatedCount: updated}, http.StatusOK)
+	}
+}
+
 func (s Server) itemRemove() http.HandlerFunc {
 	type request struct {
 		ItemID string `json:"item_id"`
@@ -360,6 +353,7 @@ func (s Server) itemRemove() http.HandlerFunc {
 	type response struct {
 		Success bool `json:"success"`
 	}
+	openAPIRegister("itemRemove", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -406,8 +400,10 @@ func (s Server) itemGetOne() http.HandlerFunc {
 	type response struct {
 		ItemID string `json:"item_id"`
 		model.TrackedItem
-		Item model.Item `json:"item"`
+		Item          model.Item     `json:"item"`
+		MerchantTrend *merchantTrend `json:"merchant_trend,omitempty"`
 	}
+	openAPIRegister("itemGetOne", nil, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -439,6 +435,9 @@ func (s Server) itemGetOne() http.HandlerFunc {
 			ItemID: i.ID.Hex(),
 			Item:   i,
 		}
+		if resp.MerchantTrend, err = s.getMerchantTrend(r.Context(), i); err != nil {
+			s.Logger.Errorf("itemGetOne: Error getting merchant trend for Item with ID: %s, err: %v", itemID, err)
+		}
 		for _, ti := range uc.user.TrackedItems {
 			if ti.ItemID == i.ID {
 				resp.TrackedItem = ti
@@ -455,7 +454,14 @@ func (s Server) itemGetAll() http.HandlerFunc {
 		model.TrackedItem
 		Item model.Item `json:"item"`
 	}
+	// userItemFields is a userItem with only the Item fields of the fields query parameter.
+	type userItemFields struct {
+		ItemID string `json:"item_id"`
+		model.TrackedItem
+		Item map[string]json.RawMessage `json:"item"`
+	}
 	type response []userItem
+	openAPIRegister("itemGetAll", nil, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -463,24 +469,96 @@ func (s Server) itemGetAll() http.HandlerFunc {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+		p, err := parsePagination(r, 100)
+		if err != nil {
+			s.Logger.Debugf("itemGetAll: Invalid pagination, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		category, err := parseCategory(r)
+		if err != nil {
+			s.Logger.Debugf("itemGetAll: Invalid category, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonFields, bsonFields, err := parseItemFields(r)
+		if err != nil {
+			s.Logger.Debugf("itemGetAll: Invalid fields, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tis := uc.user.TrackedItems
+		if category != "" && len(tis) > 0 {
+			itemIDs := make([]primitive.ObjectID, 0, len(tis))
+			for _, ti := range tis {
+				itemIDs = append(itemIDs, ti.ItemID)
+			}
+			inCategory, err := s.DB.ItemsInCategory(r.Context(), itemIDs, category)
+			if err != nil {
+				s.Logger.Errorf("itemGetAll: Error finding Items in category: %s for User with ID: %s, err: %v",
+					category, uc.user.ID.Hex(), err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			inCategoryIDs := make(map[primitive.ObjectID]bool, len(inCategory))
+			for _, id := range inCategory {
+				inCategoryIDs[id] = true
+			}
+			tis = make([]model.TrackedItem, 0, len(inCategory))
+			for _, ti := range uc.user.TrackedItems {
+				if inCategoryIDs[ti.ItemID] {
+					tis = append(tis, ti)
+				}
+			}
+		}
+		tis = tis[misc.Min(int(p.offset), len(tis)):]
+		more := p.limit > 0 && len(tis) > int(p.limit)
+		if more {
+			tis = tis[:p.limit]
+		}
+		p.setNextOffset(w, len(tis), more)
 
 		var itemIDs []primitive.ObjectID
-		for _, ti := range uc.user.TrackedItems {
+		for _, ti := range tis {
 			itemIDs = append(itemIDs, ti.ItemID)
 		}
 
 		resp := response{}
 		if len(itemIDs) == 0 {
-			s.writeJsonResponse(w, resp, http.StatusOK)
+			s.writeJsonResponseETag(w, r, resp)
 			return
 		}
-		is, err := s.DB.ItemsFind(r.Context(), itemIDs)
+		is, err := s.DB.ItemsFindFields(r.Context(), itemIDs, bsonFields)
 		if err != nil {
 			s.Logger.Errorf("itemGetAll: Error getting all Item for User with ID: %s, err: %v", uc.user.ID.Hex(), err)
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
-		for _, ti := range uc.user.TrackedItems {
+		if len(jsonFields) > 0 {
+			fieldsResp := make([]userItemFields, 0, len(tis))
+			for _, ti := range tis {
+				item := map[string]json.RawMessage{}
+				for _, i := range is {
+					if i.ID == ti.ItemID {
+						if item, err = itemFieldsJSON(i, jsonFields); err != nil {
+							s.Logger.Errorf("itemGetAll: Error encoding fields of Item with ID: %s, err: %v", i.ID.Hex(), err)
+							http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+							return
+						}
+						break
+					}
+				}
+				fieldsResp = append(fieldsResp, userItemFields{
+					ItemID:      ti.ItemID.Hex(),
+					TrackedItem: ti,
+					Item:        item,
+				})
+			}
+			s.writeJsonResponseETag(w, r, fieldsResp)
+			return
+		}
+		for _, ti := range tis {
 			var item model.Item
 			for _, i := range is {
 				if i.ID == ti.ItemID {
@@ -494,23 +572,70 @@ func (s Server) itemGetAll() http.HandlerFunc {
 				Item:        item,
 			})
 		}
-		s.writeJsonResponse(w, resp, http.StatusOK)
+		s.writeJsonResponseETag(w, r, resp)
 	}
 }
 
+// itemFieldsJSON returns the JSON encoded fields of i with the JSON field names in fields, fields left empty by
+// omitempty are left out.
+func itemFieldsJSON(i model.Item, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err = json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	m := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			m[f] = v
+		}
+	}
+	return m, nil
+}
+
+// itemHistory returns a page of the ItemHistory of an Item in a range, newest first. On GET the range is read from the
+// optional start and end RFC 3339 query parameters, ending now by default, and responses may be reused by clients.
 func (s Server) itemHistory() http.HandlerFunc {
 	type request struct {
 		Start time.Time `json:"start"`
 		End   time.Time `json:"end"`
 	}
 	type response []model.ItemHistory
+	openAPIRegister("itemHistory", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := request{}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if r.Method == http.MethodGet {
+			req.End = time.Now()
+			for _, p := range []struct {
+				name string
+				t    *time.Time
+			}{{"start", &req.Start}, {"end", &req.End}} {
+				if err := parseTimeQuery(r, p.name, p.t); err != nil {
+					s.Logger.Debugf("itemHistory: Invalid %s, err: %v", p.name, err)
+					http.Error(w, "Invalid "+p.name, http.StatusBadRequest)
+					return
+				}
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			s.Logger.Debugf("itemHistory: Error decoding JSON, err: %v", err)
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
 		}
+		setCacheControl := func() {
+			if r.Method == http.MethodGet {
+				w.Header().Set("Cache-Control", itemHistoryCacheControl)
+			}
+		}
+
+		p, err := parsePagination(r, 1000)
+		if err != nil {
+			s.Logger.Debugf("itemHistory: Invalid pagination, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		itemID := mux.Vars(r)["itemID"]
 		if itemID == "" {
@@ -518,7 +643,21 @@ func (s Server) itemHistory() http.HandlerFunc {
 			s.writeJsonResponse(w, response{}, http.StatusOK)
 			return
 		}
-		ihs, err := s.DB.ItemHistoryFindRange(r.Context(), itemID, req.Start, req.End)
+		// The widened range is also queried, so the cached pages are the same for every range sharing the key.
+		req.Start, req.End = itemHistoryCacheRange(req.Start, req.End)
+		cacheKey := itemHistoryCacheKey(itemID, req.Start, req.End, p)
+		if ch, ok := s.itemHistoryCacheGet(r.Context(), cacheKey); ok {
+			p.setNextOffset(w, len(ch.ItemHistories), ch.More)
+			setCacheControl()
+			s.writeJsonResponse(w, response(ch.ItemHistories), http.StatusOK)
+			return
+		}
+		// One more entry than the limit is requested to know if there is a next page.
+		limit := p.limit
+		if limit > 0 {
+			limit++
+		}
+		ihs, err := s.DB.ItemHistoryFindRange(r.Context(), itemID, req.Start, req.End, p.offset, limit)
 		if err != nil {
 			if errors.Is(err, primitive.ErrInvalidHex) {
 				s.Logger.Debugf("itemHistory: itemID invalid, err: %v", err)
@@ -535,12 +674,79 @@ func (s Server) itemHistory() http.HandlerFunc {
 			s.writeJsonResponse(w, response{}, http.StatusOK)
 			return
 		}
+		more := p.limit > 0 && len(ihs) > int(p.limit)
+		if more {
+			ihs = ihs[:p.limit]
+		}
+		s.itemHistoryCacheSet(r.Context(), cacheKey, cachedItemHistory{ItemHistories: ihs, More: more})
+		p.setNextOffset(w, len(ihs), more)
+		setCacheControl()
 		s.writeJsonResponse(w, response(ihs), http.StatusOK)
 	}
 }
 
+func (s Server) itemHistoryAggregate() http.HandlerFunc {
+	type request struct {
+		Start    time.Time `json:"start"`
+		End      time.Time `json:"end"`
+		Interval string    `json:"interval"`
+		Timezone string    `json:"timezone"`
+	}
+	type response []model.ItemHistoryBucket
+	openAPIRegister("itemHistoryAggregate", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("itemHistoryAggregate: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if req.Interval == "" {
+			req.Interval = model.ItemHistoryIntervalDay
+		}
+		if req.Interval != model.ItemHistoryIntervalDay && req.Interval != model.ItemHistoryIntervalWeek {
+			s.Logger.Debugf("itemHistoryAggregate: Invalid interval: %s", req.Interval)
+			http.Error(w, "Invalid interval", http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if req.Timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(req.Timezone); err != nil {
+				s.Logger.Debugf("itemHistoryAggregate: Invalid timezone: %s, err: %v", req.Timezone, err)
+				http.Error(w, "Invalid timezone", http.StatusBadRequest)
+				return
+			}
+		}
+
+		itemID := mux.Vars(r)["itemID"]
+		ihbs, err := s.DB.ItemHistoryAggregate(r.Context(), itemID, req.Start, req.End, req.Interval, loc)
+		if err != nil {
+			if errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemHistoryAggregate: itemID invalid, err: %v", err)
+				s.writeJsonResponse(w, response{}, http.StatusOK)
+				return
+			}
+			s.Logger.Errorf("itemHistoryAggregate: Error aggregating ItemHistories, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if len(ihbs) == 0 {
+			s.Logger.Debugf("itemHistoryAggregate: No ItemHistories found for ItemID: %s", itemID)
+			s.writeJsonResponse(w, response{}, http.StatusOK)
+			return
+		}
+		s.writeJsonResponse(w, response(ihbs), http.StatusOK)
+	}
+}
+
+// itemSearch searches the sites by query or by the queries of a barcode. The response is the array of found Items,
+// with X-Data-Source telling whether it was read from the search cache, and then Age and X-Cache-Stale its age and
+// whether it is being revalidated, or listing the sites left out because they did not respond in time in
+// X-Timed-Out-Sites.
 func (s Server) itemSearch() http.HandlerFunc {
 	type response []model.Item
+	openAPIRegister("itemSearch", nil, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		tid := getTraceContext(r.Context()).traceID
 		var bc string
@@ -562,10 +768,14 @@ func (s Server) itemSearch() http.HandlerFunc {
 				return
 			} else {
 				b, err := s.DB.BarcodeFind(r.Context(), bc)
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					b, err = s.barcodeLookup(r.Context(), bc)
+				}
 				if err != nil {
-					if errors.Is(err, mongo.ErrNoDocuments) {
+					if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, client.ErrBarcodeNotFound) {
 						s.Logger.Debugf("itemSearch: Barcode %#v not found, TraceID: %s", bc, tid)
-						s.writeJsonResponse(w, response([]model.Item{}), http.StatusOK)
+						w.Header().Set("X-Data-Source", dataSourceLive)
+						s.writeJsonResponse(w, response{}, http.StatusOK)
 						return
 					} else {
 						s.Logger.Errorf("itemSearch: Error finding barcode %#v, err: %v, TraceID: %s", bc, err, tid)
@@ -578,68 +788,180 @@ func (s Server) itemSearch() http.HandlerFunc {
 				if qa[0] == qa[1] {
 					qa[1] = ""
 				}
+				s.recordSearch(r, qa, bc)
 				s.Logger.Infof("itemSearch: Barcode %#v found, q1: %#v, q2: %#v, TraceID: %s", bc, qa[0], qa[1], tid)
 			}
 		} else {
 			s.Logger.Infof("itemSearch: Searching items with query: %#v, TraceID: %s", qa[0], tid)
+			s.recordSearch(r, qa, "")
 		}
-		var shopeeItems []model.Item
-		var tokopediaItems []model.Item
-		var blibliItems []model.Item
-		for i, q := range qa {
-			if q != "" {
-				if len(shopeeItems) < 3 {
-					is, err := s.Client.ShopeeSearch(q)
-					if err == nil {
-						if len(is) > 0 && len(shopeeItems) > 0 {
-							shopeeItems = mergeItemSlices(shopeeItems, is)
-						} else if len(shopeeItems) == 0 {
-							shopeeItems = is
-						}
-						s.Logger.Debugf("itemSearch: Searched Shopee with q%d: %#v, %d item(s) found, TraceID: %s", i+1, q, len(is), tid)
-					} else {
-						s.Logger.Errorf("itemSearch: Error searching Shopee with q%d: %#v, err: %v, TraceID: %s", i+1, q, err, tid)
-					}
-				}
-				if len(tokopediaItems) < 3 {
-					is, err := s.Client.TokopediaSearch(q)
-					if err == nil {
-						if len(is) > 0 && len(tokopediaItems) > 0 {
-							tokopediaItems = mergeItemSlices(tokopediaItems, is)
-						} else if len(tokopediaItems) == 0 {
-							tokopediaItems = is
-						}
-						s.Logger.Debugf("itemSearch: Searched Tokopedia with q%d: %#v, %d item(s) found, TraceID: %s", i+1, q, len(is), tid)
-					} else {
-						s.Logger.Errorf("itemSearch: Error searching Tokopedia with q%d: %#v, err: %v, TraceID: %s", i+1, q, err, tid)
-					}
-				}
-				if len(blibliItems) < 3 {
-					is, err := s.Client.BlibliSearch(q)
-					if err == nil {
-						if len(is) > 0 && len(blibliItems) > 0 {
-							blibliItems = mergeItemSlices(blibliItems, is)
-						} else if len(blibliItems) == 0 {
-							blibliItems = is
-						}
-						s.Logger.Debugf("itemSearch: Searched Blibli with q%d: %#v, %d item(s) found, TraceID: %s", i+1, q, len(is), tid)
-					} else {
-						s.Logger.Errorf("itemSearch: Error searching Blibli with q%d: %#v, err: %v, TraceID: %s", i+1, q, err, tid)
-					}
-				}
-			} else if bc != "" {
-				s.Logger.Debugf("itemSearch: Barcode %#v q%d is empty, TraceID: %s", bc, i+1, tid)
+
+		if cached, ok := s.searchCacheGet(r.Context(), qa); ok {
+			stale := cached.stale()
+			if stale {
+				s.Logger.Debugf("itemSearch: Returning stale cached results, revalidating, TraceID: %s", tid)
+				go s.searchCacheRevalidate(qa, tid)
+			}
+			w.Header().Set("X-Data-Source", dataSourceCache)
+			w.Header().Set("X-Cache-Stale", strconv.FormatBool(stale))
+			w.Header().Set("Age", strconv.FormatInt(int64(time.Since(cached.CachedAt.Time()).Seconds()), 10))
+			s.writeJsonResponse(w, response(s.withoutArchived(r.Context(), cached.Items)), http.StatusOK)
+			return
+		}
+
+		items, timedOut := s.searchItems(r.Context(), qa, tid)
+		// Results missing the sites that timed out are not cached for the next searches.
+		if len(timedOut) == 0 {
+			s.searchCacheSet(r.Context(), qa, items)
+		} else {
+			w.Header().Set("X-Timed-Out-Sites", strings.Join(timedOut, ", "))
+		}
+		w.Header().Set("X-Data-Source", dataSourceLive)
+		s.writeJsonResponse(w, response(s.withoutArchived(r.Context(), items)), http.StatusOK)
+	}
+}
+
+const (
+	itemSearchLocalDefaultLimit = 20
+	itemSearchLocalMaxLimit     = 50
+)
+
+// itemSearchLocal searches the name and description of the Items already tracked by Users, so clients can offer
+// them, with their price history, before searching the marketplaces.
+func (s Server) itemSearchLocal() http.HandlerFunc {
+	type response struct {
+		Items []model.Item `json:"items"`
+	}
+	openAPIRegister("itemSearchLocal", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("query")
+		q = misc.CleanString(q[:misc.Min(len(q), 100)])
+		if q == "" {
+			s.Logger.Debug("itemSearchLocal: No search query supplied")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		p, err := parsePagination(r, itemSearchLocalMaxLimit)
+		if err != nil {
+			s.Logger.Debugf("itemSearchLocal: Invalid pagination, err: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := p.limit
+		if limit == 0 {
+			limit = itemSearchLocalDefaultLimit
+		}
+
+		// One more Item than the limit is searched to know if there is a next page.
+		is, err := s.DB.ItemsTextSearch(r.Context(), q, p.offset, limit+1)
+		if err != nil {
+			s.Logger.Errorf("itemSearchLocal: Error searching Items with query: %#v, err: %v", q, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		more := len(is) > int(limit)
+		if more {
+			is = is[:limit]
+		}
+		p.setNextOffset(w, len(is), more)
+		if is == nil {
+			is = []model.Item{}
+		}
+		s.Logger.Debugf("itemSearchLocal: Searched Items with query: %#v, %d item(s) found", q, len(is))
+		s.writeJsonResponse(w, response{Items: is}, http.StatusOK)
+	}
+}
+
+const (
+	// searchResultsPerSite is how many search results of a site are returned when SearchResultLimits has no limit for it.
+	searchResultsPerSite = 3
+	// searchDeadline is how long searchItems waits for the sites, sites still searching are left out of the results.
+	searchDeadline = 5 * time.Second
+)
+
+// searchItems searches every marketplace concurrently with up to two queries, the second query is only used to fill
+// up the results of sites with less results than their limit from the first query. The results are ranked by
+// rankSearchItems. The sites are searched with a shared searchDeadline, the results found in time are returned
+// and timedOut lists the Item Site names of the sites that did not finish searching in time.
+func (s Server) searchItems(ctx context.Context, qa [2]string, tid string) (items []model.Item, timedOut []string) {
+	ctx, cancel := context.WithTimeout(ctx, searchDeadline)
+	defer cancel()
+
+	scrapers := s.Scrapers.All()
+	var mu sync.Mutex
+	siteItems := make([][]model.Item, len(scrapers))
+	finished := make([]bool, len(scrapers))
+	var g errgroup.Group
+	for idx, sr := range scrapers {
+		idx, sr := idx, sr
+		g.Go(func() error {
+			is, siteTimedOut := s.searchSite(ctx, sr, qa, tid)
+			mu.Lock()
+			siteItems[idx] = is
+			finished[idx] = !siteTimedOut
+			mu.Unlock()
+			return nil
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = g.Wait()
+		close(done)
+	}()
+	// Site clients not cancelled by ctx are not waited for past the deadline either.
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	results := make([][]model.Item, len(scrapers))
+	for idx, sr := range scrapers {
+		results[idx] = siteItems[idx]
+		if !finished[idx] {
+			s.Logger.Infof("searchItems: Search of %s timed out, %d item(s) found in time, TraceID: %s",
+				sr.Site(), len(siteItems[idx]), tid)
+			timedOut = append(timedOut, sr.Site())
+		}
+	}
+	return rankSearchItems(qa[0], results), timedOut
+}
+
+// searchSite searches the site of sr with up to two queries for up to its search result limit of items, errors are
+// logged and leave the site with the items found until then. timedOut is set when ctx expired during a search.
+func (s Server) searchSite(
+	ctx context.Context, sr service.SiteScraper, qa [2]string, tid string) (items []model.Item, timedOut bool) {
+	limit, ok := s.SearchResultLimits[sr.ClientSite()]
+	if !ok {
+		limit = searchResultsPerSite
+	}
+	for i, q := range qa {
+		if q == "" {
+			s.Logger.Debugf("searchSite: q%d is empty, TraceID: %s", i+1, tid)
+			continue
+		}
+		if len(items) >= limit {
+			break
+		}
+		is, err := sr.Search(ctx, q)
+		if err != nil {
+			if ctx.Err() != nil {
+				s.Logger.Debugf("searchSite: Search of %s with q%d: %#v cancelled, err: %v, TraceID: %s", sr.Site(), i+1, q, err, tid)
+				timedOut = true
+				break
 			}
+			s.Logger.Errorf("searchSite: Error searching %s with q%d: %#v, err: %v, TraceID: %s", sr.Site(), i+1, q, err, tid)
+			continue
 		}
-		shopeeItems = shopeeItems[:misc.Min(len(shopeeItems), 3)]
-		tokopediaItems = tokopediaItems[:misc.Min(len(tokopediaItems), 3)]
-		blibliItems = blibliItems[:misc.Min(len(blibliItems), 3)]
-		items := make([]model.Item, 0, len(shopeeItems)+len(tokopediaItems)+len(blibliItems))
-		items = append(items, shopeeItems...)
-		items = append(items, tokopediaItems...)
-		items = append(items, blibliItems...)
-		s.writeJsonResponse(w, response(items), http.StatusOK)
+		if len(is) > 0 && len(items) > 0 {
+			items = mergeItemSlices(items, is)
+		} else if len(items) == 0 {
+			items = is
+		}
+		s.Logger.Debugf("searchSite: Searched %s with q%d: %#v, %d item(s) found, TraceID: %s", sr.Site(), i+1, q, len(is), tid)
 	}
+	return items[:misc.Min(len(items), limit)], timedOut
 }
 
 func mergeItemSlices(is []model.Item, is2 []model.Item) []model.Item {
@@ -658,3 +980,85 @@ func mergeItemSlices(is []model.Item, is2 []model.Item) []model.Item {
 	}
 	return append(is, deduplicated...)
 }
+
+func (s Server) itemFetchInterval() http.HandlerFunc {
+	type request struct {
+		ItemID          string    `json:"item_id"`
+		IntervalMinutes int       `json:"interval_minutes"`
+		Until           time.Time `json:"until"`
+	}
+	type response struct {
+		Success bool `json:"success"`
+	}
+	openAPIRegister("itemFetchInterval", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemFetchInterval: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("itemFetchInterval: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		if !itemTracked(req.ItemID, uc.user.TrackedItems) {
+			s.Logger.Debugf("itemFetchInterval: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), req.ItemID)
+			s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
+			return
+		}
+
+		var until primitive.DateTime
+		if req.IntervalMinutes != 0 {
+			limits := userLimits(uc.user)
+			if limits.FetchIntervalItems == 0 {
+				s.Logger.Debugf("itemFetchInterval: Custom fetch interval not available for User with ID: %s", uc.user.ID.Hex())
+				http.Error(w, "Custom fetch interval requires premium", http.StatusForbidden)
+				return
+			}
+			minInterval := misc.Max(limits.RefreshCooldown, priorityFetchMinInterval)
+			interval := time.Duration(req.IntervalMinutes) * time.Minute
+			if interval < minInterval || interval > 24*time.Hour {
+				s.Logger.Debugf("itemFetchInterval: Invalid interval_minutes: %d", req.IntervalMinutes)
+				http.Error(w, fmt.Sprintf("interval_minutes must be between %d and %d",
+					int(minInterval.Minutes()), 24*60), http.StatusBadRequest)
+				return
+			}
+			if !req.Until.IsZero() {
+				if req.Until.Before(time.Now()) {
+					s.Logger.Debugf("itemFetchInterval: until is in the past: %v", req.Until)
+					http.Error(w, "until must be in the future", http.StatusBadRequest)
+					return
+				}
+				until = primitive.NewDateTimeFromTime(req.Until)
+			}
+
+			now := time.Now()
+			var activeCount int
+			for _, ti := range uc.user.TrackedItems {
+				if ti.ItemID.Hex() != req.ItemID && ti.FetchIntervalActive(now) {
+					activeCount++
+				}
+			}
+			if activeCount >= limits.FetchIntervalItems {
+				s.Logger.Debugf("itemFetchInterval: Fetch interval item limit reached for User with ID: %s, limit: %d",
+					uc.user.ID.Hex(), limits.FetchIntervalItems)
+				http.Error(w, "Custom fetch interval item limit reached", http.StatusForbidden)
+				return
+			}
+		}
+
+		if err = s.DB.UserTrackedItemFetchIntervalSet(
+			r.Context(), uc.user.ID.Hex(), req.ItemID, req.IntervalMinutes, until); err != nil {
+			s.Logger.Errorf("itemFetchInterval: Error setting fetch interval for User with ID: %s, ItemID: %s, err: %v",
+				uc.user.ID.Hex(), req.ItemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
+	}
+}
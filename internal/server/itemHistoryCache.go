@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+const (
+	// itemHistoryCacheTTL is how long ItemHistory ranges are cached, new ItemHistory is only added by fetch cycles.
+	itemHistoryCacheTTL = 2 * time.Minute
+	// itemHistoryMaxAge is how long clients may reuse ItemHistory responses without requesting them again.
+	itemHistoryMaxAge = time.Minute
+)
+
+// itemHistoryCacheControl is the Cache-Control header of ItemHistory responses.
+var itemHistoryCacheControl = "private, max-age=" + strconv.Itoa(int(itemHistoryMaxAge.Seconds()))
+
+// cachedItemHistory is a page of the ItemHistory of an Item in a range, More is set when there is a next page.
+type cachedItemHistory struct {
+	ItemHistories []model.ItemHistory `json:"item_histories"`
+	More          bool                `json:"more"`
+}
+
+// itemHistoryCacheRange widens a range to whole itemHistoryCacheTTL buckets, so requests of ranges ending now share
+// their cached pages until the cache expires anyway.
+func itemHistoryCacheRange(start time.Time, end time.Time) (time.Time, time.Time) {
+	start = start.Truncate(itemHistoryCacheTTL)
+	if e := end.Truncate(itemHistoryCacheTTL); e.Before(end) {
+		end = e.Add(itemHistoryCacheTTL)
+	}
+	return start, end
+}
+
+// itemHistoryCacheKey is the cache key of a page of a range, the range must already be widened by itemHistoryCacheRange.
+func itemHistoryCacheKey(itemID string, start time.Time, end time.Time, p pagination) string {
+	return fmt.Sprintf("item:history:%s:%d:%d:%d:%d", itemID, start.UnixMilli(), end.UnixMilli(), p.offset, p.limit)
+}
+
+func (s Server) itemHistoryCacheGet(ctx context.Context, key string) (cachedItemHistory, bool) {
+	var ch cachedItemHistory
+	b, err := s.Cache.Get(ctx, key)
+	if err != nil {
+		if err != client.ErrCacheMiss {
+			s.Logger.Errorf("itemHistoryCacheGet: Error getting cached ItemHistory, err: %v", err)
+		}
+		return ch, false
+	}
+	if err = json.Unmarshal(b, &ch); err != nil {
+		s.Logger.Errorf("itemHistoryCacheGet: Error unmarshalling cached ItemHistory, err: %v", err)
+		return ch, false
+	}
+	return ch, true
+}
+
+func (s Server) itemHistoryCacheSet(ctx context.Context, key string, ch cachedItemHistory) {
+	b, err := json.Marshal(ch)
+	if err != nil {
+		s.Logger.Errorf("itemHistoryCacheSet: Error marshalling cached ItemHistory, err: %v", err)
+		return
+	}
+	if err = s.Cache.Set(ctx, key, b, itemHistoryCacheTTL); err != nil {
+		s.Logger.Errorf("itemHistoryCacheSet: Error setting cached ItemHistory, err: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+// itemHistoryExport streams the ItemHistory of an Item as CSV, oldest first. The optional start and end
+// query parameters are RFC 3339 timestamps and default to the whole history.
+func (s Server) itemHistoryExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if format := q.Get("format"); format != "" && format != "csv" {
+			s.Logger.Debugf("itemHistoryExport: Invalid format: %#v", format)
+			http.Error(w, "Invalid format", http.StatusBadRequest)
+			return
+		}
+		start, end := time.Time{}, time.Now()
+		for _, p := range []struct {
+			name string
+			t    *time.Time
+		}{{"start", &start}, {"end", &end}} {
+			if err := parseTimeQuery(r, p.name, p.t); err != nil {
+				s.Logger.Debugf("itemHistoryExport: Invalid %s, err: %v", p.name, err)
+				http.Error(w, "Invalid "+p.name, http.StatusBadRequest)
+				return
+			}
+		}
+
+		itemID := mux.Vars(r)["itemID"]
+		if _, err := primitive.ObjectIDFromHex(itemID); err != nil {
+			s.Logger.Debugf("itemHistoryExport: itemID invalid, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="item-history-%s.csv"`, itemID))
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"timestamp", "price", "stock", "rating", "sold"}); err != nil {
+			s.Logger.Errorf("itemHistoryExport: Error writing CSV header, err: %v", err)
+			return
+		}
+		rows := 0
+		err := s.DB.ItemHistoryForEach(r.Context(), itemID, start, end, func(ih model.ItemHistory) error {
+			rows++
+			return cw.Write([]string{
+				ih.Timestamp.Time().UTC().Format(time.RFC3339),
+				strconv.Itoa(ih.Price),
+				strconv.Itoa(ih.Stock),
+				strconv.FormatFloat(ih.Rating, 'f', -1, 64),
+				strconv.Itoa(ih.Sold),
+			})
+		})
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+		if err != nil {
+			// The status has already been sent, the truncated CSV is all the client gets.
+			s.Logger.Errorf("itemHistoryExport: Error exporting ItemHistory for ItemID: %s after %d row(s), err: %v",
+				itemID, rows, err)
+			return
+		}
+		s.Logger.Debugf("itemHistoryExport: Exported %d ItemHistory row(s) for ItemID: %s", rows, itemID)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"html"
+	"io"
+	"net/http"
+	"pricetracker/internal/misc"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+const routeItemImport = "itemImport"
+
+const (
+	itemImportMaxBytes = 2 << 20
+	// itemImportMaxURLs is how many marketplace URLs of a file are imported, the rest are reported as failed.
+	itemImportMaxURLs = 50
+	// itemImportConcurrency is how many URLs of a file are scraped at the same time.
+	itemImportConcurrency = 4
+)
+
+var bookmarkHref = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*"([^"]*)"`)
+
+// importURLs extracts every marketplace URL of a Netscape bookmarks file or of any cell of a CSV file,
+// in order and without duplicates. It also returns how many other links or cells were ignored.
+func importURLs(scrapers *service.Scrapers, data []byte) ([]string, int, error) {
+	var candidates []string
+	if bytes.Contains(bytes.ToUpper(data[:misc.Min(len(data), 512)]), []byte("NETSCAPE-BOOKMARK-FILE")) ||
+		bookmarkHref.Match(data) {
+		for _, m := range bookmarkHref.FindAllSubmatch(data, -1) {
+			candidates = append(candidates, html.UnescapeString(string(m[1])))
+		}
+	} else {
+		cr := csv.NewReader(bytes.NewReader(data))
+		cr.FieldsPerRecord = -1
+		cr.LazyQuotes = true
+		cr.TrimLeadingSpace = true
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "error reading CSV")
+			}
+			candidates = append(candidates, record...)
+		}
+	}
+
+	var urls []string
+	ignored := 0
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		_, cleanURL, err := scrapers.Resolve(c)
+		if err != nil {
+			ignored++
+			continue
+		}
+		if !seen[cleanURL] {
+			seen[cleanURL] = true
+			urls = append(urls, cleanURL)
+		}
+	}
+	return urls, ignored, nil
+}
+
+// importScrape is the scraped Item of an imported URL, or the error scraping it.
+type importScrape struct {
+	url string
+	sc  service.Scraped
+	err error
+}
+
+// importScrapeAll scrapes urls, at most itemImportConcurrency at a time, and returns the results in the order of urls.
+func (s Server) importScrapeAll(ctx context.Context, urls []string) []importScrape {
+	res := make([]importScrape, len(urls))
+	sem := make(chan struct{}, itemImportConcurrency)
+	var wg sync.WaitGroup
+	for idx, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, u string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			sc, err := s.Items.Scrape(ctx, u, "")
+			res[idx] = importScrape{url: u, sc: sc, err: err}
+		}(idx, u)
+	}
+	wg.Wait()
+	return res
+}
+
+// itemImport tracks the marketplace URLs found in an uploaded CSV or bookmarks file. The Items are scraped a few at
+// a time, then saved and added to the User as TrackedItems in one transaction, so a failure saves none of them.
+func (s Server) itemImport() http.HandlerFunc {
+	type imported struct {
+		URL    string `json:"url"`
+		ItemID string `json:"item_id"`
+	}
+	type failed struct {
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}
+	type response struct {
+		Imported       []imported `json:"imported"`
+		AlreadyTracked []imported `json:"already_tracked"`
+		Failed         []failed   `json:"failed"`
+		Ignored        int        `json:"ignored"`
+	}
+	openAPIRegister("itemImport", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemImport: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			s.Logger.Debugf("itemImport: Error getting uploaded file, err: %v", err)
+			http.Error(w, "Missing file", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			s.Logger.Debugf("itemImport: Error reading uploaded file, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		notificationEnabled := false
+		if v := r.FormValue("notification_enabled"); v != "" {
+			if notificationEnabled, err = strconv.ParseBool(v); err != nil {
+				s.Logger.Debugf("itemImport: Invalid notification_enabled: %#v", v)
+				http.Error(w, "Invalid notification_enabled", http.StatusBadRequest)
+				return
+			}
+		}
+
+		urls, ignored, err := importURLs(s.Scrapers, data)
+		if err != nil {
+			s.Logger.Debugf("itemImport: Error extracting URLs, err: %v", err)
+			http.Error(w, "Invalid file", http.StatusBadRequest)
+			return
+		}
+
+		var tooMany []failed
+		if len(urls) > itemImportMaxURLs {
+			for _, u := range urls[itemImportMaxURLs:] {
+				tooMany = append(tooMany, failed{URL: u, Error: "too many urls in file"})
+			}
+			urls = urls[:itemImportMaxURLs]
+		}
+		var scraped []importScrape
+		var scrapeFailed []failed
+		for _, is := range s.importScrapeAll(r.Context(), urls) {
+			if is.err == nil {
+				scraped = append(scraped, is)
+				continue
+			}
+			reason := "error getting item"
+			if errors.Is(is.err, service.ErrItemNotFound) {
+				s.Logger.Debugf("itemImport: Item not found with url: %s, err: %v", is.url, is.err)
+				reason = "item not found"
+			} else {
+				s.Logger.Errorf("itemImport: Error getting item with url: %s, err: %v", is.url, is.err)
+			}
+			scrapeFailed = append(scrapeFailed, failed{URL: is.url, Error: reason})
+		}
+
+		limit := s.trackedItemsLimit(uc.user)
+		available := limit - len(uc.user.TrackedItems)
+		var resp response
+		// Without transactions the Items saved before a failure are left untracked, they are archived as orphaned.
+		err = s.DB.WithTransaction(r.Context(), func(ctx context.Context) error {
+			// The transaction may be retried, the response is built from scratch every time.
+			resp = response{Imported: []imported{}, AlreadyTracked: []imported{}, Failed: []failed{}, Ignored: ignored}
+			resp.Failed = append(append(resp.Failed, scrapeFailed...), tooMany...)
+			var tis []model.TrackedItem
+			addedItemIDs := make(map[primitive.ObjectID]bool)
+			for _, is := range scraped {
+				i, err := s.Items.FindOrInsert(ctx, is.sc.Item, "")
+				if err != nil {
+					return errors.Wrapf(err, "error saving Item with url: %s", is.url)
+				}
+				if itemTracked(i.ID.Hex(), uc.user.TrackedItems) || addedItemIDs[i.ID] {
+					resp.AlreadyTracked = append(resp.AlreadyTracked, imported{URL: is.url, ItemID: i.ID.Hex()})
+					continue
+				}
+				if len(tis) >= available {
+					resp.Failed = append(resp.Failed, failed{URL: is.url, Error: "tracked items limit reached"})
+					continue
+				}
+				addedItemIDs[i.ID] = true
+				tis = append(tis, model.TrackedItem{
+					ItemID:              i.ID,
+					PriceInitial:        i.Price,
+					NotificationEnabled: notificationEnabled,
+				})
+				resp.Imported = append(resp.Imported, imported{URL: is.url, ItemID: i.ID.Hex()})
+			}
+			return s.Users.TrackItems(ctx, uc.user, tis, limit)
+		})
+		if err != nil {
+			s.writeServiceError(w, "itemImport", err)
+			return
+		}
+		if len(resp.Imported) > 0 {
+			s.rewardReferredUser(r.Context(), uc.user)
+		}
+		s.Logger.Infof("itemImport: Imported %d of %d URL(s) for User with ID: %s",
+			len(resp.Imported), len(urls), uc.user.ID.Hex())
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
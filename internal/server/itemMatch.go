@@ -0,0 +1,96 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/model"
+	"sort"
+)
+
+// itemAlternatives returns the cheapest equivalent listing on each of the other sites of an Item,
+// Items are matched on first request when they have no matches yet.
+func (s Server) itemAlternatives() http.HandlerFunc {
+	type alternative struct {
+		ItemID string `json:"item_id"`
+		model.Item
+		Reason          string  `json:"reason"`
+		Similarity      float64 `json:"similarity"`
+		PriceDifference int     `json:"price_difference"`
+	}
+	type response struct {
+		ItemID       string        `json:"item_id"`
+		Alternatives []alternative `json:"alternatives"`
+	}
+	openAPIRegister("itemAlternatives", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID := mux.Vars(r)["itemID"]
+		i, err := s.DB.ItemFindOne(r.Context(), itemID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemAlternatives: No documents found for Item with ID: %s, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("itemAlternatives: Error finding Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		ims, err := s.DB.ItemMatchesFind(r.Context(), i.ID)
+		if err != nil {
+			s.Logger.Errorf("itemAlternatives: Error finding ItemMatches for Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if len(ims) == 0 {
+			if ims, err = s.Items.Match(r.Context(), i); err != nil {
+				s.Logger.Errorf("itemAlternatives: Error matching Item with ID: %s, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		resp := response{ItemID: i.ID.Hex(), Alternatives: []alternative{}}
+		if len(ims) == 0 {
+			s.writeJsonResponse(w, resp, http.StatusOK)
+			return
+		}
+		matches := make(map[primitive.ObjectID]model.ItemMatch, len(ims))
+		matchedIDs := make([]primitive.ObjectID, 0, len(ims))
+		for _, im := range ims {
+			matches[im.MatchedItemID] = im
+			matchedIDs = append(matchedIDs, im.MatchedItemID)
+		}
+		is, err := s.DB.ItemsFind(r.Context(), matchedIDs)
+		if err != nil {
+			s.Logger.Errorf("itemAlternatives: Error finding matched Items for Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		cheapest := make(map[string]model.Item)
+		for _, mi := range is {
+			if mi.Archived || mi.Site == i.Site || mi.Stock == 0 {
+				continue
+			}
+			if c, ok := cheapest[mi.Site]; !ok || mi.Price < c.Price {
+				cheapest[mi.Site] = mi
+			}
+		}
+		for _, mi := range cheapest {
+			im := matches[mi.ID]
+			resp.Alternatives = append(resp.Alternatives, alternative{
+				ItemID:          mi.ID.Hex(),
+				Item:            mi,
+				Reason:          im.Reason,
+				Similarity:      im.Similarity,
+				PriceDifference: mi.Price - i.Price,
+			})
+		}
+		sort.Slice(resp.Alternatives, func(a, b int) bool { return resp.Alternatives[a].Price < resp.Alternatives[b].Price })
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
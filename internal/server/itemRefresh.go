@@ -0,0 +1,94 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math"
+	"net/http"
+	"pricetracker/internal/model"
+	"strconv"
+)
+
+// itemRefresh fetches a tracked Item from its site right away instead of waiting for the next fetch cycle,
+// updating it and recording an ItemHistory like the fetcher does.
+func (s Server) itemRefresh() http.HandlerFunc {
+	type response struct {
+		ItemID string `json:"item_id"`
+		model.TrackedItem
+		Item model.Item `json:"item"`
+	}
+	openAPIRegister("itemRefresh", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		tid := getTraceContext(r.Context()).traceID
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemRefresh: Error getting userContext, err: %v, TraceID: %s", err, tid)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		itemID := mux.Vars(r)["itemID"]
+		resp := response{ItemID: itemID}
+		tracked := false
+		for _, ti := range uc.user.TrackedItems {
+			if ti.ItemID.Hex() == itemID {
+				resp.TrackedItem = ti
+				tracked = true
+				break
+			}
+		}
+		if !tracked {
+			s.Logger.Debugf("itemRefresh: Item not tracked on User with ID: %s, ItemID: %s, TraceID: %s",
+				uc.user.ID.Hex(), itemID, tid)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if wait := s.refreshCooldownTake(r, uc.user, itemID); wait > 0 {
+			s.Logger.Debugf("itemRefresh: Item with ID: %s refreshed by User with ID: %s within the cooldown, wait: %v, TraceID: %s",
+				itemID, uc.user.ID.Hex(), wait, tid)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Item was refreshed recently", http.StatusTooManyRequests)
+			return
+		}
+
+		i, err := s.DB.ItemFindOne(r.Context(), itemID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemRefresh: No documents found for Item with ID: %s, err: %v, TraceID: %s", itemID, err, tid)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("itemRefresh: Error finding Item with ID: %s, err: %v, TraceID: %s", itemID, err, tid)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		ecommerceItem, err := s.fetchItem(r.Context(), i)
+		if err != nil {
+			switch {
+			case errors.Is(err, errFetchItemNotFound):
+				s.Logger.Debugf("itemRefresh: Item with ID: %s not found on its site, err: %v, TraceID: %s", itemID, err, tid)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			case errors.Is(err, errFetchRequest):
+				s.Logger.Errorf("itemRefresh: Site unavailable for Item with ID: %s, err: %v, TraceID: %s", itemID, err, tid)
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			default:
+				s.Logger.Errorf("itemRefresh: Error fetching Item with ID: %s, err: %v, TraceID: %s", itemID, err, tid)
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			}
+			return
+		}
+
+		// Every refresh is a fetch cycle of its own, so its ItemHistory is never merged into that of another fetch.
+		if resp.Item, _, err = s.fetchedItemUpdate(r.Context(), i, ecommerceItem, primitive.NewObjectID()); err != nil {
+			s.Logger.Errorf("itemRefresh: Error updating Item with ID: %s, err: %v, TraceID: %s", itemID, err, tid)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("itemRefresh: Refreshed Item: %s, ID: %s for User with ID: %s, TraceID: %s",
+			i.ShortName(), itemID, uc.user.ID.Hex(), tid)
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"testing"
+)
+
+// TestItemRefreshCooldown checks that a User refreshing the same Item again within the refresh cooldown of their tier
+// is turned away, while other Items can still be refreshed.
+func TestItemRefreshCooldown(t *testing.T) {
+	itemIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	db := &mock.Database{
+		// The Item lookup fails after the cooldown check, so no site is requested.
+		ItemFindOneFunc: func(ctx context.Context, id string) (model.Item, error) {
+			return model.Item{}, errors.Wrapf(mongo.ErrNoDocuments, "ItemID: %s", id)
+		},
+	}
+	s := newTestServer(t, db)
+	u := model.User{TrackedItems: []model.TrackedItem{{ItemID: itemIDs[0]}, {ItemID: itemIDs[1]}}}
+	lt := loginTestUser(t, s, db, &u)
+	router := s.Router()
+
+	refresh := func(itemID primitive.ObjectID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/item/refresh/"+itemID.Hex(), nil)
+		req.Header.Set("Authorization", "Bearer "+lt)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, tt := range []struct {
+		name   string
+		itemID primitive.ObjectID
+		want   int
+	}{
+		{name: "first refresh", itemID: itemIDs[0], want: http.StatusNotFound},
+		{name: "same item within cooldown", itemID: itemIDs[0], want: http.StatusTooManyRequests},
+		{name: "other item", itemID: itemIDs[1], want: http.StatusNotFound},
+	} {
+		rec := refresh(tt.itemID)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d, body: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+		if tt.want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: missing Retry-After header", tt.name)
+		}
+	}
+}
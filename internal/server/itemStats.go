@@ -0,0 +1,153 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+type availabilityStats struct {
+	AvailabilityPercent   float64             `json:"availability_percent"`
+	SoldOutCount          int                 `json:"sold_out_count"`
+	SoldOutTotalSeconds   int64               `json:"sold_out_total_seconds"`
+	SoldOutLongestSeconds int64               `json:"sold_out_longest_seconds"`
+	SoldOutAverageSeconds int64               `json:"sold_out_average_seconds"`
+	SoldOutSince          *primitive.DateTime `json:"sold_out_since"`
+}
+
+// calculateAvailability derives sold-out periods from stock transitions in ihs, which must be sorted by
+// ascending timestamp. Time before the first ItemHistory is not counted.
+func calculateAvailability(ihs []model.ItemHistory, start time.Time, end time.Time) availabilityStats {
+	var stats availabilityStats
+	if len(ihs) == 0 {
+		return stats
+	}
+
+	var observed, soldOut time.Duration
+	var soldOutStart time.Time
+	var soldOutPeriods []time.Duration
+	closeSoldOut := func(at time.Time) {
+		d := at.Sub(soldOutStart)
+		soldOut += d
+		soldOutPeriods = append(soldOutPeriods, d)
+	}
+
+	for idx, ih := range ihs {
+		ts := ih.Timestamp.Time()
+		if ts.Before(start) {
+			ts = start
+		}
+		next := end
+		if idx+1 < len(ihs) {
+			next = ihs[idx+1].Timestamp.Time()
+		}
+		if next.After(ts) {
+			observed += next.Sub(ts)
+		}
+
+		wasSoldOut := !soldOutStart.IsZero()
+		if ih.Stock <= 0 && !wasSoldOut {
+			soldOutStart = ts
+		} else if ih.Stock > 0 && wasSoldOut {
+			closeSoldOut(ts)
+			soldOutStart = time.Time{}
+		}
+	}
+	if !soldOutStart.IsZero() {
+		closeSoldOut(end)
+		since := primitive.NewDateTimeFromTime(soldOutStart)
+		stats.SoldOutSince = &since
+	}
+
+	stats.SoldOutCount = len(soldOutPeriods)
+	stats.SoldOutTotalSeconds = int64(soldOut.Seconds())
+	for _, d := range soldOutPeriods {
+		if s := int64(d.Seconds()); s > stats.SoldOutLongestSeconds {
+			stats.SoldOutLongestSeconds = s
+		}
+	}
+	if stats.SoldOutCount > 0 {
+		stats.SoldOutAverageSeconds = stats.SoldOutTotalSeconds / int64(stats.SoldOutCount)
+	}
+	if observed > 0 {
+		stats.AvailabilityPercent = float64(observed-soldOut) / float64(observed) * 100
+	}
+	return stats
+}
+
+// withItemPriceBounds includes the price bounds kept on i in ps, they outlive ItemHistory expired by retention,
+// and sets the lowest price badges of ps.
+func withItemPriceBounds(ps model.ItemPriceStats, i model.Item) model.ItemPriceStats {
+	if i.PriceHistoryLowest > 0 && (ps.AllTimeLow == 0 || i.PriceHistoryLowest < ps.AllTimeLow) {
+		ps.AllTimeLow = i.PriceHistoryLowest
+	}
+	if i.PriceHistoryHighest > ps.AllTimeHigh {
+		ps.AllTimeHigh = i.PriceHistoryHighest
+	}
+	ps.LowestIn90Days = ps.Low90Days > 0 && i.Price <= ps.Low90Days
+	ps.LowestEver = ps.AllTimeLow > 0 && i.Price <= ps.AllTimeLow
+	return ps
+}
+
+func (s Server) itemStats() http.HandlerFunc {
+	type response struct {
+		ItemID       string               `json:"item_id"`
+		Start        time.Time            `json:"start"`
+		End          time.Time            `json:"end"`
+		Availability availabilityStats    `json:"availability"`
+		Price        model.ItemPriceStats `json:"price"`
+	}
+	openAPIRegister("itemStats", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemID := mux.Vars(r)["itemID"]
+		days := 90
+		if d := r.URL.Query().Get("days"); d != "" {
+			parsedDays, err := strconv.Atoi(d)
+			if err != nil || parsedDays <= 0 || parsedDays > 365 {
+				s.Logger.Debugf("itemStats: Invalid days: %#v, err: %v", d, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			days = parsedDays
+		}
+
+		i, err := s.DB.ItemFindOne(r.Context(), itemID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemStats: No documents found for Item with ID: %s, err: %v", itemID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("itemStats: Error finding Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		end := time.Now()
+		start := end.AddDate(0, 0, -days)
+		ihs, err := s.DB.ItemHistoryStockFindRange(r.Context(), i.ID.Hex(), start, end)
+		if err != nil {
+			s.Logger.Errorf("itemStats: Error getting ItemHistory stock for Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		ps, err := s.DB.ItemHistoryPriceStats(r.Context(), i.ID.Hex(), i.Price, end)
+		if err != nil {
+			s.Logger.Errorf("itemStats: Error getting ItemHistory price stats for Item with ID: %s, err: %v", itemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{
+			ItemID:       i.ID.Hex(),
+			Start:        start,
+			End:          end,
+			Availability: calculateAvailability(ihs, start, end),
+			Price:        withItemPriceBounds(ps, i),
+		}, http.StatusOK)
+	}
+}
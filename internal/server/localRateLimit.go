@@ -0,0 +1,125 @@
+package server
+
+import (
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+const (
+	// localRateLimitPruneEvery is how often the idle buckets and the quotas of past days are dropped.
+	localRateLimitPruneEvery = time.Minute
+	// localRateLimitRedisBackoff is how long Redis is skipped after an error, so an outage does not add the Redis
+	// timeout to every request.
+	localRateLimitRedisBackoff = 10 * time.Second
+)
+
+// localRateLimits are the in-process fallbacks of the rate limits and the API quota when Redis is unavailable, they
+// only limit the requests to this instance.
+type localRateLimits struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+	// quotas are the requests counted per User and UTC day, keyed like the Redis counters.
+	quotas map[string]int64
+	// cooldowns are when the cooldowns keyed like the Redis keys are over.
+	cooldowns map[string]time.Time
+	prunedAt  time.Time
+	// redisSkippedUntil is when Redis is tried again after an error.
+	redisSkippedUntil time.Time
+}
+
+type localBucket struct {
+	limiter *rate.Limiter
+	// fullAt is when the bucket is full again if no more tokens are taken, it can then be dropped.
+	fullAt time.Time
+}
+
+func newLocalRateLimits() *localRateLimits {
+	return &localRateLimits{
+		buckets:   make(map[string]*localBucket),
+		quotas:    make(map[string]int64),
+		cooldowns: make(map[string]time.Time),
+		prunedAt:  time.Now(),
+	}
+}
+
+// take takes one token from the bucket of rl for key, it returns how long to wait until one is available when the
+// bucket is empty and zero otherwise, like tokenBucketScript.
+func (l *localRateLimits) take(rl rateLimit, key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	k := rl.name + ":" + key
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &localBucket{limiter: rate.NewLimiter(rate.Every(rl.refillEvery), rl.burst)}
+		l.buckets[k] = b
+	}
+	res := b.limiter.ReserveN(now, 1)
+	if wait := res.DelayFrom(now); wait > 0 {
+		res.CancelAt(now)
+		return wait
+	}
+	b.fullAt = now.Add(time.Duration(rl.burst) * rl.refillEvery)
+	return 0
+}
+
+// count counts a request against the quota at key and returns the requests counted.
+func (l *localRateLimits) count(key string, now time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	l.quotas[key]++
+	return l.quotas[key]
+}
+
+// cooldown starts a cooldown of d at key unless one is running, it returns how long until the running one is over and
+// zero when it was started.
+func (l *localRateLimits) cooldown(key string, d time.Duration, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	if overAt, ok := l.cooldowns[key]; ok && overAt.After(now) {
+		return overAt.Sub(now)
+	}
+	l.cooldowns[key] = now.Add(d)
+	return 0
+}
+
+// redisSkipped reports whether Redis is skipped after an error, the limits are then checked in process.
+func (l *localRateLimits) redisSkipped(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Before(l.redisSkippedUntil)
+}
+
+// redisFailed skips Redis for localRateLimitRedisBackoff after an error.
+func (l *localRateLimits) redisFailed(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redisSkippedUntil = now.Add(localRateLimitRedisBackoff)
+}
+
+// prune drops the full buckets, the quotas of past days and the cooldowns that are over every localRateLimitPruneEvery, l.mu must be held.
+func (l *localRateLimits) prune(now time.Time) {
+	if now.Sub(l.prunedAt) < localRateLimitPruneEvery {
+		return
+	}
+	l.prunedAt = now
+	for k, b := range l.buckets {
+		if now.After(b.fullAt) {
+			delete(l.buckets, k)
+		}
+	}
+	for k, overAt := range l.cooldowns {
+		if !overAt.After(now) {
+			delete(l.cooldowns, k)
+		}
+	}
+	today := now.UTC().Format("2006-01-02")
+	for k := range l.quotas {
+		if k[len(k)-len(today):] != today {
+			delete(l.quotas, k)
+		}
+	}
+}
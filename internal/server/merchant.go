@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"pricetracker/internal/model"
+	"sort"
+	"time"
+)
+
+const merchantTrendDays = 30
+
+type merchantTrend struct {
+	Name                string  `json:"name"`
+	Rating              float64 `json:"rating"`
+	RatingChange        float64 `json:"rating_change"`
+	FollowerCount       int     `json:"follower_count"`
+	FollowerCountChange int     `json:"follower_count_change"`
+	Days                int     `json:"days"`
+}
+
+// fetchMerchant records the current metrics of the merchant of i, only Shopee exposes merchant metrics.
+func (s Server) fetchMerchant(ctx context.Context, i model.Item) {
+	if i.Site != "Shopee" || i.MerchantID == "" {
+		return
+	}
+	mh, err := s.Client.ShopeeGetMerchant(ctx, i.MerchantID)
+	if err != nil {
+		s.Logger.Errorf("fetchMerchant: Error getting Shopee merchant with ID: %s, err: %v", i.MerchantID, err)
+		return
+	}
+	if err = s.DB.MerchantHistoryInsert(ctx, mh); err != nil {
+		s.Logger.Errorf("fetchMerchant: Error inserting MerchantHistory, err: %v", err)
+	}
+	// The Shopee item API has no merchant name and rating, so they are kept up to date from the merchant metrics.
+	if err = s.DB.ItemsMerchantSet(ctx, i.Site, i.MerchantID, mh.Name, mh.Rating); err != nil {
+		s.Logger.Errorf("fetchMerchant: Error setting merchant of Items, err: %v", err)
+	}
+}
+
+type merchantInfo struct {
+	Site       string  `json:"site"`
+	MerchantID string  `json:"merchant_id"`
+	Name       string  `json:"name"`
+	Location   string  `json:"location"`
+	Rating     float64 `json:"rating"`
+}
+
+// merchantGet lists the tracked Items of a merchant, so Users can compare the sellers of the same product.
+func (s Server) merchantGet() http.HandlerFunc {
+	type response struct {
+		Merchant merchantInfo   `json:"merchant"`
+		Trend    *merchantTrend `json:"trend,omitempty"`
+		Items    []model.Item   `json:"items"`
+	}
+	openAPIRegister("merchantGet", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		site, ok := s.itemSiteName(vars["site"])
+		if !ok {
+			s.Logger.Debugf("merchantGet: Invalid site: %#v", vars["site"])
+			http.Error(w, "Invalid site", http.StatusBadRequest)
+			return
+		}
+		merchantID := vars["merchantID"]
+
+		is, err := s.DB.ItemsFindBySite(r.Context(), site, merchantID)
+		if err != nil {
+			s.Logger.Errorf("merchantGet: Error finding Items for Site: %s, MerchantID: %s, err: %v", site, merchantID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp := response{
+			Merchant: merchantInfo{Site: site, MerchantID: merchantID},
+			Items:    make([]model.Item, 0, len(is)),
+		}
+		// The merchant information is taken from the most recently updated Item that has it.
+		var merchantUpdatedAt primitive.DateTime
+		for _, i := range is {
+			if i.TrackerCount == 0 {
+				continue
+			}
+			resp.Items = append(resp.Items, i)
+			if i.MerchantName != "" && i.UpdatedAt >= merchantUpdatedAt {
+				merchantUpdatedAt = i.UpdatedAt
+				resp.Merchant.Name = i.MerchantName
+				resp.Merchant.Location = i.MerchantLocation
+				resp.Merchant.Rating = i.MerchantRating
+			}
+		}
+		if len(resp.Items) == 0 {
+			s.Logger.Debugf("merchantGet: No tracked Items for Site: %s, MerchantID: %s", site, merchantID)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		sort.Slice(resp.Items, func(a, b int) bool { return resp.Items[a].Price < resp.Items[b].Price })
+
+		if resp.Trend, err = s.getMerchantTrend(r.Context(), resp.Items[0]); err != nil {
+			s.Logger.Errorf("merchantGet: Error getting merchant trend for Site: %s, MerchantID: %s, err: %v", site, merchantID, err)
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
+
+// getMerchantTrend compares the latest merchant metrics of i with the earliest ones in the last merchantTrendDays,
+// it returns nil if there is no MerchantHistory for the merchant.
+func (s Server) getMerchantTrend(ctx context.Context, i model.Item) (*merchantTrend, error) {
+	end := time.Now()
+	mhs, err := s.DB.MerchantHistoryFindRange(ctx, i.Site, i.MerchantID, end.AddDate(0, 0, -merchantTrendDays), end)
+	if err != nil || len(mhs) == 0 {
+		return nil, err
+	}
+	first, last := mhs[0], mhs[len(mhs)-1]
+	return &merchantTrend{
+		Name:                last.Name,
+		Rating:              last.Rating,
+		RatingChange:        last.Rating - first.Rating,
+		FollowerCount:       last.FollowerCount,
+		FollowerCountChange: last.FollowerCount - first.FollowerCount,
+		Days:                int(last.Timestamp.Time().Sub(first.Timestamp.Time()).Hours() / 24),
+	}, nil
+}
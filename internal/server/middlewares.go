@@ -3,13 +3,16 @@ package server
 import (
 	"context"
 	"crypto/sha256"
-	"github.com/google/uuid"
+	"crypto/subtle"
+	"github.com/gorilla/mux"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"pricetracker/internal/client"
 	"pricetracker/internal/model"
+	"pricetracker/internal/tracing"
 	"runtime/debug"
 	"strings"
 	"time"
@@ -45,14 +48,52 @@ func getTraceContext(ctx context.Context) traceContext {
 	return tc
 }
 
+// maxBytesByRoute overrides the request body limit of maxBytesMw for named routes.
+var maxBytesByRoute = map[string]int64{
+	routeAdminBarcodeImport: barcodeImportMaxBytes,
+	routeItemImport:         itemImportMaxBytes,
+	routeItemSearchByImage:  imageSearchMaxBytes,
+}
+
 func (s Server) maxBytesMw(next http.Handler) http.Handler {
-	return http.MaxBytesHandler(next, 3000)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int64(3000)
+		if route := mux.CurrentRoute(r); route != nil {
+			if routeN, ok := maxBytesByRoute[route.GetName()]; ok {
+				n = routeN
+			}
+		}
+		http.MaxBytesHandler(next, n).ServeHTTP(w, r)
+	})
 }
 
 func (s Server) loggingMw(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		traceID := uuid.NewString()
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		ctx := r.Context()
+		if s.TrustTraceparent {
+			// Clients would otherwise pick the trace IDs their requests are logged and exported with.
+			ctx = tracing.Extract(ctx, r.Header)
+		}
+		ctx, span := s.Tracer.Start(ctx, "HTTP "+r.Method+" "+route, tracing.KindServer)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			span.SetAttribute("http.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(sw.status)))
+			}
+			span.End()
+		}()
+		w = sw
+		traceID := tracing.TraceID(ctx)
 		s.Logger.Debugf("loggingMw: Incoming request %s %s from %s, UA: %s, TraceID: %s",
 			r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent(), traceID)
 
@@ -64,20 +105,54 @@ func (s Server) loggingMw(next http.Handler) http.Handler {
 		}()
 
 		tc := traceContext{traceID: traceID}
-		next.ServeHTTP(w, r.WithContext(setTraceContext(r.Context(), tc)))
+		ctx = client.WithTraceID(setTraceContext(ctx, tc), traceID)
+		next.ServeHTTP(w, r.WithContext(ctx))
 
 		s.Logger.Debugf("loggingMw: Incoming request %s %s took %dms, TraceID: %s",
 			r.Method, r.URL.Path, time.Now().Sub(start).Milliseconds(), traceID)
 	})
 }
 
+// statusResponseWriter records the status code written to its http.ResponseWriter.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying http.ResponseWriter, for the write deadline of the
+// price stream.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush keeps the price stream working through the wrapper.
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (s Server) authMw(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tid := getTraceContext(r.Context()).traceID
 		lt := r.Header.Get("Authorization")
 		if strings.HasPrefix(lt, "Bearer ") {
 			lt = strings.TrimPrefix(lt, "Bearer ")
-			token, err := jwt.Parse([]byte(lt), jwt.WithKey(jwa.HS256, s.AuthSecretKey), jwt.WithValidate(true))
+			token, err := s.loginTokenParse(lt)
 			if err != nil {
 				s.Logger.Debugf("authMw: Failed to validate login token, err: %v, TraceID: %s", err, tid)
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
@@ -100,15 +175,12 @@ func (s Server) authMw(next http.Handler) http.Handler {
 				return
 			}
 
-			tokenHash := sha256.New()
-			tokenHash.Write([]byte(lt))
 			for _, d := range u.Devices {
 				if d.DeviceID != deviceIDStr {
 					continue
 				}
 
-				err = bcrypt.CompareHashAndPassword(d.LoginToken.Token, tokenHash.Sum(nil))
-				if err != nil {
+				if err = loginTokenCompare(d, lt); err != nil {
 					s.Logger.Debugf("authMw: Error when comparing LoginToken hashes for UserID: %s, DeviceID: %s, err: %v, TraceID: %s",
 						u.ID.Hex(), d.DeviceID, err, tid)
 					break
@@ -131,3 +203,58 @@ func (s Server) authMw(next http.Handler) http.Handler {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 	})
 }
+
+// loginTokenParse verifies the signature of a login token and validates its claims.
+func (s Server) loginTokenParse(lt string) (jwt.Token, error) {
+	return jwt.Parse([]byte(lt), jwt.WithKey(jwa.HS256, s.AuthSecretKey), jwt.WithValidate(true))
+}
+
+// loginTokenCompare returns nil if lt is the current login token of Device d.
+func loginTokenCompare(d model.Device, lt string) error {
+	tokenHash := sha256.Sum256([]byte(lt))
+	return bcrypt.CompareHashAndPassword(d.LoginToken.Token, tokenHash[:])
+}
+
+// roleMw only allows Users with role, it must be used after authMw.
+func (s Server) roleMw(role string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tid := getTraceContext(r.Context()).traceID
+			uc, err := getUserContext(r.Context())
+			if err != nil {
+				s.Logger.Errorf("roleMw: Error getting userContext, err: %v, TraceID: %s", err, tid)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !uc.user.HasRole(role) {
+				s.Logger.Infof("roleMw: UserID: %s does not have Role: %s, TraceID: %s", uc.user.ID.Hex(), role, tid)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// adminMw allows requests with a valid X-Admin-Key header, or authenticated Users with the admin Role.
+func (s Server) adminMw(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tid := getTraceContext(r.Context()).traceID
+		if r.Header.Get("X-Admin-Key") == "" && r.Header.Get("Authorization") != "" {
+			s.authMw(s.roleMw(model.RoleAdmin)(next)).ServeHTTP(w, r)
+			return
+		}
+		if s.AdminAPIKey == "" {
+			s.Logger.Debugf("adminMw: Admin API is disabled, TraceID: %s", tid)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		key := r.Header.Get("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.AdminAPIKey)) != 1 {
+			s.Logger.Infof("adminMw: Invalid admin key from %s, TraceID: %s", requestIP(r), tid)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
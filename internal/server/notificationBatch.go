@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/client"
+	"pricetracker/internal/i18n"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notificationBatchListed is how many Items are named in the body of a combined notification.
+const notificationBatchListed = 3
+
+// NotificationBatcher coalesces the price drop notifications of a User within a window into one notification,
+// so many TrackedItems dropping at once during platform-wide sales don't produce a burst of pushes.
+// Pending batches are kept in memory and are lost if the application exits before the window ends.
+type NotificationBatcher struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[primitive.ObjectID]*notificationBatch
+}
+
+type notificationBatch struct {
+	locale string
+	rcp    service.Recipients
+	items  []model.Item
+	msgs   []service.Message
+}
+
+func NewNotificationBatcher(window time.Duration) *NotificationBatcher {
+	return &NotificationBatcher{
+		window:  window,
+		pending: make(map[primitive.ObjectID]*notificationBatch),
+	}
+}
+
+// notificationBatchAdd adds a notification for a single User to the User's batch,
+// the first notification of a batch schedules it to be sent in locale when the window ends.
+func (s Server) notificationBatchAdd(userID primitive.ObjectID, locale string, rcp service.Recipients, i model.Item, msg service.Message) {
+	nb := s.NotificationBatcher
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	b, ok := nb.pending[userID]
+	if !ok {
+		b = &notificationBatch{locale: locale, rcp: rcp}
+		nb.pending[userID] = b
+		time.AfterFunc(nb.window, func() {
+			s.notificationBatchFlush(context.Background(), userID)
+		})
+	}
+	b.items = append(b.items, i)
+	b.msgs = append(b.msgs, msg)
+}
+
+func (s Server) notificationBatchFlush(ctx context.Context, userID primitive.ObjectID) {
+	nb := s.NotificationBatcher
+	nb.mu.Lock()
+	b, ok := nb.pending[userID]
+	delete(nb.pending, userID)
+	nb.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if len(b.items) == 1 {
+		if !s.Notifications.Send(ctx, b.items[0], b.rcp, b.msgs[0]) {
+			s.Logger.Errorf("notificationBatchFlush: No notifications sent for User with ID: %s", userID.Hex())
+		}
+		return
+	}
+
+	var body, text []string
+	for idx, i := range b.items {
+		line := i18n.T(b.locale, i18n.PriceDropBody, i.ShortName(), model.FormatPrice(i.Price, i.Currency))
+		if idx < notificationBatchListed {
+			body = append(body, line)
+		}
+		text = append(text, line+"\n"+i.URL)
+	}
+	if more := len(b.items) - notificationBatchListed; more > 0 {
+		body = append(body, i18n.T(b.locale, i18n.ListMore, more))
+	}
+	title := i18n.T(b.locale, i18n.PriceDropBatchTitle, len(b.items))
+	msg := service.Message{
+		Event:   "price_drop_batch",
+		Title:   title,
+		Body:    strings.Join(body, "\n"),
+		Text:    title + "\n\n" + strings.Join(text, "\n\n"),
+		FCMData: client.FCMData{Type: "price_drop_batch"},
+	}
+	s.Logger.Infof("notificationBatchFlush: Sending combined notification of %d Item(s) for User with ID: %s", len(b.items), userID.Hex())
+	if !s.Notifications.Send(ctx, model.Item{Name: fmt.Sprintf("%d Items", len(b.items))}, b.rcp, msg) {
+		s.Logger.Errorf("notificationBatchFlush: No notifications sent for User with ID: %s", userID.Hex())
+	}
+}
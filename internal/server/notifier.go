@@ -5,88 +5,344 @@ import (
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"pricetracker/internal/client"
+	"pricetracker/internal/i18n"
 	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"time"
 )
 
-func (s Server) notify(ctx context.Context, i model.Item) {
-	var itemName string
-	if len(i.Name) > 45 {
-		itemName = i.Name[:45] + "..."
-	} else {
-		itemName = i.Name
+// itemDeepLinkFormat is the URI of the app screen of an Item, formatted with the Item ID.
+const itemDeepLinkFormat = "pricetracker://item/%s"
+
+// itemFCMData returns the FCMData of a notification about the variant with variationID of i rendered as an expanded
+// notification, oldPrice is the price of the variant before the change or 0 when it is not known.
+func itemFCMData(i model.Item, variationID string, oldPrice int) client.FCMData {
+	price, _ := i.PriceAndStock(variationID)
+	d := client.FCMData{
+		ItemID:   i.ID.Hex(),
+		ImageURL: i.ImageURL,
+		NewPrice: model.FormatPrice(price, i.Currency),
+		DeepLink: fmt.Sprintf(itemDeepLinkFormat, i.ID.Hex()),
+	}
+	if oldPrice > 0 && oldPrice != price {
+		d.OldPrice = model.FormatPrice(oldPrice, i.Currency)
+	}
+	return d
+}
+
+// usersByVariation groups us by the variant of their TrackedItem, as the prices in notifications are the ones of the
+// tracked variant. Users are expected to be projected to the TrackedItem of the notified Item.
+func usersByVariation(us []model.User) map[string][]model.User {
+	byVariation := make(map[string][]model.User)
+	for _, u := range us {
+		var variationID string
+		if len(u.TrackedItems) > 0 {
+			variationID = u.TrackedItems[0].VariationID
+		}
+		byVariation[variationID] = append(byVariation[variationID], u)
 	}
+	return byVariation
+}
+
+// notify notifies Users whose TrackedItem rules match the Item's new price, old is the Item before the change,
+// it returns the number of Users notified.
+func (s Server) notify(ctx context.Context, old model.Item, i model.Item) int {
+	itemName := i.ShortName()
 	s.Logger.Debugf("notify: Finding Users that tracked Item: %s, ID: %s", itemName, i.ID.Hex())
 	us, err := s.DB.UsersDeviceFCMTokensFindByTrackedItem(ctx, i.ID)
 	if err != nil {
 		s.Logger.Errorf("notify: Error getting Users that tracked ItemID: %s, err: %v", i.ID.Hex(), err)
-		return
+		return 0
 	}
 	s.Logger.Debugf("notify: Found %d User(s) that tracked Item: %s, ID: %s", len(us), itemName, i.ID.Hex())
 
-	var notifiedUserIDs []primitive.ObjectID
-	var fcmTokens []string
-	for _, u := range us {
-		if len(u.TrackedItems) > 0 && shouldNotify(u.TrackedItems[0], i.Price, i.Stock) {
-			var notified bool
-			for _, d := range u.Devices {
-				if d.FCMToken != "" {
-					fcmTokens = append(fcmTokens, d.FCMToken)
-					notified = true
-				}
-			}
-			if notified {
-				notifiedUserIDs = append(notifiedUserIDs, u.ID)
-			}
-		}
+	s.resetNotificationCounts(ctx, us, i)
+	now := time.Now()
+	filter := func(ti model.TrackedItem) bool {
+		price, stock := i.PriceAndStock(ti.VariationID)
+		return s.shouldNotify(ti, price, stock, now)
 	}
-	if len(notifiedUserIDs) == 0 {
+	rcp := s.notificationRecipients(us, filter)
+	if len(rcp.UserIDs) == 0 {
 		s.Logger.Debugf("notify: No Users to be notified for Item: %s, ID: %s", itemName, i.ID.Hex())
-		return
+		return 0
 	}
 
-	fcmReq := client.FCMSendRequest{
-		Notification: client.FCMNotification{
-			Title:       "The price of an item has dropped!",
-			Body:        fmt.Sprintf("%s is now Rp. %d", itemName, i.Price),
-			ClickAction: "FLUTTER_NOTIFICATION_CLICK",
-			Sound:       "default",
-		},
-		Data:            client.FCMData{ItemID: i.ID.Hex()},
-		RegistrationIDs: fcmTokens,
+	msg := func(variationID string) func(locale string) service.Message {
+		price, _ := i.PriceAndStock(variationID)
+		oldPrice, _ := old.PriceAndStock(variationID)
+		return func(locale string) service.Message {
+			return service.Message{
+				Event:       "price_drop",
+				Title:       i18n.T(locale, i18n.PriceDropTitle),
+				Body:        i18n.T(locale, i18n.PriceDropBody, itemName, model.FormatPrice(price, i.Currency)),
+				FCMData:     itemFCMData(i, variationID, oldPrice),
+				VariationID: variationID,
+			}
+		}
 	}
-	s.Logger.Infof("notify: Sending notification to %d Device(s) for %d User(s) for Item: %s, ID: %s",
-		len(fcmTokens), len(notifiedUserIDs), itemName, i.ID.Hex())
-	s.Logger.Debugf("notify: FCMSendRequest for Item: %s, ID: %s, req: %+v", itemName, i.ID.Hex(), fcmReq)
-	fcmResp, err := s.Client.FCMSendNotification(fcmReq)
-	if err != nil {
-		s.Logger.Errorf(
-			"notify: Error sending notification to FCM for Item: %s, ID: %s, FCMSendRequest: %+v, err: %v",
-			itemName, i.ID.Hex(), fcmReq, err,
-		)
-		return
+	if s.NotificationBatcher != nil {
+		for variationID, vus := range usersByVariation(us) {
+			vmsg := msg(variationID)
+			// Webhooks belong to a single TrackedItem so they are not batched.
+			for locale, lrcp := range s.localizedRecipients(vus, filter) {
+				if len(lrcp.Webhooks) > 0 {
+					s.Notifications.Send(ctx, i, service.Recipients{Webhooks: lrcp.Webhooks}, vmsg(locale))
+				}
+			}
+			for _, u := range vus {
+				urcp := s.notificationRecipients([]model.User{u}, filter)
+				if len(urcp.FCMTokens) > 0 || len(urcp.TelegramChatIDs) > 0 || len(urcp.Quiet) > 0 {
+					urcp.Webhooks = nil
+					locale := i18n.Normalize(u.Locale)
+					s.notificationBatchAdd(u.ID, locale, urcp, i, vmsg(locale))
+				}
+			}
+		}
+	} else if !s.sendLocalizedByVariation(ctx, i, us, filter, msg) {
+		s.Logger.Errorf("notify: No notifications sent for %d User(s) for Item: %s, ID: %s", len(rcp.UserIDs), itemName, i.ID.Hex())
+		return 0
 	}
-	s.Logger.Infof("notify: Send notification results for Item: %s, ID: %s, success: %d, failure: %d",
-		itemName, i.ID.Hex(), fcmResp.Success, fcmResp.Failure)
-	s.Logger.Debugf("notify: FCMSendResponse for Item: %s, ID: %s, resp: %+v", itemName, i.ID.Hex(), fcmResp)
 
-	updatedUserCount, err := s.DB.UserTrackedItemNotificationCountIncrement(ctx, notifiedUserIDs, i.ID)
+	updatedUserCount, err := s.DB.UserTrackedItemNotificationCountIncrement(ctx, rcp.UserIDs, i.ID)
 	if err != nil {
 		s.Logger.Errorf("notify: Error incrementing User TrackedItem Notification Counts, err: %v", err)
-		return
+		return len(rcp.UserIDs)
 	}
-	if updatedUserCount != len(notifiedUserIDs) {
+	if updatedUserCount != len(rcp.UserIDs) {
 		s.Logger.Errorf(
 			"notify: Updated User count mismatch with notified UserIDs, updated: %d, notified: %d, notifiedUserIDs: %v for Item: %s, ID: %s",
-			updatedUserCount, len(notifiedUserIDs), notifiedUserIDs, itemName, i.ID.Hex(),
+			updatedUserCount, len(rcp.UserIDs), rcp.UserIDs, itemName, i.ID.Hex(),
 		)
 	}
+	return len(rcp.UserIDs)
+}
+
+// notifyRestock notifies Users that asked to be notified when the Item is back in stock,
+// it returns the number of Users notified.
+func (s Server) notifyRestock(ctx context.Context, i model.Item) int {
+	itemName := i.ShortName()
+	us, err := s.DB.UsersDeviceFCMTokensFindByTrackedItem(ctx, i.ID)
+	if err != nil {
+		s.Logger.Errorf("notifyRestock: Error getting Users that tracked ItemID: %s, err: %v", i.ID.Hex(), err)
+		return 0
+	}
+	now := time.Now()
+	filter := func(ti model.TrackedItem) bool {
+		return ti.NotifyOnRestock && ti.AlertActive(now)
+	}
+	rcp := s.notificationRecipients(us, filter)
+	if len(rcp.UserIDs) == 0 {
+		s.Logger.Debugf("notifyRestock: No Users to be notified for Item: %s, ID: %s", itemName, i.ID.Hex())
+		return 0
+	}
+
+	msg := func(variationID string) func(locale string) service.Message {
+		price, _ := i.PriceAndStock(variationID)
+		return func(locale string) service.Message {
+			msg := service.Message{
+				Event:       "restock",
+				Title:       i18n.T(locale, i18n.RestockTitle),
+				Body:        i18n.T(locale, i18n.RestockBody, itemName, model.FormatPrice(price, i.Currency)),
+				FCMData:     itemFCMData(i, variationID, 0),
+				VariationID: variationID,
+			}
+			msg.FCMData.Type = "restock"
+			return msg
+		}
+	}
+	if !s.sendLocalizedByVariation(ctx, i, us, filter, msg) {
+		s.Logger.Errorf("notifyRestock: No notifications sent for %d User(s) for Item: %s, ID: %s",
+			len(rcp.UserIDs), itemName, i.ID.Hex())
+		return 0
+	}
+	return len(rcp.UserIDs)
 }
 
-func shouldNotify(ti model.TrackedItem, itemPrice int, itemStock int) bool {
+// notificationRecipients collects the enabled notification channels of Users whose first TrackedItem passes filter,
+// Users are expected to be projected to the TrackedItem of the notified Item.
+func (s Server) notificationRecipients(us []model.User, filter func(ti model.TrackedItem) bool) service.Recipients {
+	var rcp service.Recipients
+	now := time.Now()
+	for _, u := range us {
+		if len(u.TrackedItems) == 0 || !filter(u.TrackedItems[0]) {
+			continue
+		}
+		var notified bool
+		if until, quiet := u.Notification.QuietHours.Until(now); quiet {
+			rcp.Quiet = append(rcp.Quiet, service.QuietRecipient{UserID: u.ID, Until: until})
+			notified = true
+		} else if s.addUserChannels(&rcp, u) {
+			notified = true
+		}
+		if len(u.TrackedItems[0].Webhooks) > 0 {
+			rcp.Webhooks = append(rcp.Webhooks, u.TrackedItems[0].Webhooks...)
+			notified = true
+		}
+		if notified {
+			rcp.UserIDs = append(rcp.UserIDs, u.ID)
+		}
+	}
+	return rcp
+}
+
+// notifyRatingAndSold notifies Users whose TrackedItem rating or sold count alerts are reached by the change of the
+// Item from old to i, it returns the number of notifications sent.
+func (s Server) notifyRatingAndSold(ctx context.Context, old model.Item, i model.Item) int {
+	itemName := i.ShortName()
+	us, err := s.DB.UsersDeviceFCMTokensFindByTrackedItem(ctx, i.ID)
+	if err != nil {
+		s.Logger.Errorf("notifyRatingAndSold: Error getting Users that tracked ItemID: %s, err: %v", i.ID.Hex(), err)
+		return 0
+	}
+	now := time.Now()
+	alerts := []struct {
+		event  string
+		filter func(ti model.TrackedItem) bool
+		title  func(locale string) string
+		body   func(locale string) string
+	}{
+		{
+			event: "rating_drop",
+			filter: func(ti model.TrackedItem) bool {
+				return ti.AlertActive(now) && ti.RatingDropReached(old.Rating, i.Rating)
+			},
+			title: func(locale string) string { return i18n.T(locale, i18n.RatingDropTitle) },
+			body:  func(locale string) string { return i18n.T(locale, i18n.RatingDropBody, itemName, i.Rating) },
+		},
+		{
+			event: "sold_jump",
+			filter: func(ti model.TrackedItem) bool {
+				return ti.AlertActive(now) && ti.SoldJumpReached(old.Sold, i.Sold)
+			},
+			title: func(locale string) string { return i18n.T(locale, i18n.SoldJumpTitle) },
+			body:  func(locale string) string { return i18n.T(locale, i18n.SoldJumpBody, itemName, i.Sold-old.Sold) },
+		},
+	}
+	var notified int
+	for _, a := range alerts {
+		for variationID, vus := range usersByVariation(us) {
+			rcps := s.localizedRecipients(vus, a.filter)
+			if len(rcps) == 0 {
+				continue
+			}
+			msg := func(locale string) service.Message {
+				msg := service.Message{
+					Event:       a.event,
+					Title:       a.title(locale),
+					Body:        a.body(locale),
+					FCMData:     itemFCMData(i, variationID, 0),
+					VariationID: variationID,
+				}
+				msg.FCMData.Type = a.event
+				return msg
+			}
+			if !s.sendLocalized(ctx, i, rcps, msg) {
+				s.Logger.Errorf("notifyRatingAndSold: No %s notifications sent for Item: %s, ID: %s", a.event, itemName, i.ID.Hex())
+				continue
+			}
+			for _, rcp := range rcps {
+				notified += len(rcp.UserIDs)
+			}
+		}
+	}
+	return notified
+}
+
+// localizedRecipients groups the notificationRecipients of us by the locale of the Users.
+func (s Server) localizedRecipients(us []model.User, filter func(ti model.TrackedItem) bool) map[string]service.Recipients {
+	byLocale := make(map[string][]model.User)
+	for _, u := range us {
+		locale := i18n.Normalize(u.Locale)
+		byLocale[locale] = append(byLocale[locale], u)
+	}
+	rcps := make(map[string]service.Recipients, len(byLocale))
+	for locale, lus := range byLocale {
+		if rcp := s.notificationRecipients(lus, filter); len(rcp.UserIDs) > 0 {
+			rcps[locale] = rcp
+		}
+	}
+	return rcps
+}
+
+// sendLocalized sends the message of each locale in msg to the recipients of the locale,
+// it returns false if no notification was sent.
+func (s Server) sendLocalized(ctx context.Context, i model.Item, rcps map[string]service.Recipients, msg func(locale string) service.Message) bool {
+	var sent bool
+	for locale, rcp := range rcps {
+		if s.Notifications.Send(ctx, i, rcp, msg(locale)) {
+			sent = true
+		}
+	}
+	return sent
+}
+
+// sendLocalizedByVariation sends the message of each tracked variant and locale to the recipients among us that pass
+// filter, it returns false if no notification was sent.
+func (s Server) sendLocalizedByVariation(
+	ctx context.Context, i model.Item, us []model.User, filter func(ti model.TrackedItem) bool,
+	msg func(variationID string) func(locale string) service.Message,
+) bool {
+	var sent bool
+	for variationID, vus := range usersByVariation(us) {
+		if s.sendLocalized(ctx, i, s.localizedRecipients(vus, filter), msg(variationID)) {
+			sent = true
+		}
+	}
+	return sent
+}
+
+// addUserChannels adds the enabled FCM and Telegram channels of u to rcp, it returns false if u has none.
+func (s Server) addUserChannels(rcp *service.Recipients, u model.User) bool {
+	var added bool
+	if !u.Notification.FCMDisabled {
+		for _, d := range u.Devices {
+			if d.FCMToken != "" {
+				rcp.FCMTokens = append(rcp.FCMTokens, d.FCMToken)
+				added = true
+			}
+		}
+	}
+	if u.Notification.TelegramEnabled && u.Telegram.ChatID != 0 && s.Client.TelegramEnabled() {
+		rcp.TelegramChatIDs = append(rcp.TelegramChatIDs, u.Telegram.ChatID)
+		added = true
+	}
+	return added
+}
+
+func (s Server) shouldNotify(ti model.TrackedItem, itemPrice int, itemStock int, now time.Time) bool {
 	if ti.NotificationEnabled &&
-		itemPrice <= ti.PriceLowerThreshold &&
-		itemStock > 0 {
+		ti.AlertActive(now) &&
+		ti.PriceDropReached(itemPrice) &&
+		itemStock > 0 &&
+		(s.NotificationMaxPerThresholdHit == 0 || ti.NotificationCount < s.NotificationMaxPerThresholdHit) &&
+		(ti.LastNotifiedAt == 0 || now.Sub(ti.LastNotifiedAt.Time()) >= s.NotificationCooldown) {
 		return true
 	}
 	return false
 }
+
+// resetNotificationCounts resets the NotificationCount of TrackedItems whose threshold is no longer reached
+// by the price of the Item or of its tracked variant, so the next time the price drops below the threshold counts
+// as a new threshold hit.
+func (s Server) resetNotificationCounts(ctx context.Context, us []model.User, i model.Item) {
+	var userIDs []primitive.ObjectID
+	for _, u := range us {
+		if len(u.TrackedItems) == 0 || u.TrackedItems[0].NotificationCount == 0 {
+			continue
+		}
+		ti := u.TrackedItems[0]
+		if price, _ := i.PriceAndStock(ti.VariationID); !ti.PriceDropReached(price) {
+			userIDs = append(userIDs, u.ID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+	reset, err := s.DB.UserTrackedItemNotificationCountReset(ctx, userIDs, i.ID)
+	if err != nil {
+		s.Logger.Errorf("resetNotificationCounts: Error resetting TrackedItem Notification Counts for ItemID: %s, err: %v", i.ID.Hex(), err)
+		return
+	}
+	s.Logger.Debugf("resetNotificationCounts: Reset Notification Counts of %d User(s) for ItemID: %s", reset, i.ID.Hex())
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type openAPIHandler struct {
+	request  reflect.Type
+	response reflect.Type
+}
+
+// openAPIHandlers are the request and response types of every handler, registered by the handler constructors.
+var openAPIHandlers = struct {
+	sync.RWMutex
+	m map[string]openAPIHandler
+}{m: make(map[string]openAPIHandler)}
+
+// openAPIRegister records the request and response types of the handler named handler, either may be nil.
+func openAPIRegister(handler string, request any, response any) {
+	h := openAPIHandler{}
+	if request != nil {
+		h.request = reflect.TypeOf(request)
+	}
+	if response != nil {
+		h.response = reflect.TypeOf(response)
+	}
+	openAPIHandlers.Lock()
+	openAPIHandlers.m[handler] = h
+	openAPIHandlers.Unlock()
+}
+
+var openAPIHandlerFuncName = regexp.MustCompile(`\.Server\.(\w+)\.func\d+$`)
+
+// openAPIHandlerName returns the name of the Server method that constructed the handler of route,
+// routes whose handler is wrapped in a middleware have to be named after the method instead.
+func openAPIHandlerName(route *mux.Route) string {
+	if name := route.GetName(); name != "" {
+		return name
+	}
+	h := route.GetHandler()
+	if h == nil {
+		return ""
+	}
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return ""
+	}
+	if m := openAPIHandlerFuncName.FindStringSubmatch(f.Name()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{(\w+)(?::[^}]*)?}`)
+
+// openAPIDocument builds an OpenAPI 3 document from the routes of router and the registered handler types.
+func openAPIDocument(router *mux.Router) map[string]any {
+	sg := openAPISchemaGenerator{components: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+	openAPIHandlers.RLock()
+	defer openAPIHandlers.RUnlock()
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path := openAPIPathParam.ReplaceAllString(tmpl, "{$1}")
+		name := openAPIHandlerName(route)
+		h := openAPIHandlers.m[name]
+
+		for _, method := range methods {
+			op := map[string]any{
+				"operationId": name,
+				"tags":        []string{openAPITag(path)},
+			}
+			var params []any
+			for _, m := range openAPIPathParam.FindAllStringSubmatch(tmpl, -1) {
+				params = append(params, map[string]any{
+					"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+				})
+			}
+			if params != nil {
+				op["parameters"] = params
+			}
+			if h.request != nil && method != http.MethodGet {
+				op["requestBody"] = map[string]any{
+					"required": true,
+					"content":  map[string]any{"application/json": map[string]any{"schema": sg.schema(h.request)}},
+				}
+			}
+			success := map[string]any{"description": "Success"}
+			if h.response != nil {
+				success["content"] = map[string]any{"application/json": map[string]any{"schema": sg.schema(h.response)}}
+			}
+			op["responses"] = map[string]any{"2XX": success, "default": map[string]any{"description": "Error"}}
+			if security := openAPISecurity(path); security != nil {
+				op["security"] = security
+			}
+			if paths[path] == nil {
+				paths[path] = make(map[string]any)
+			}
+			paths[path][strings.ToLower(method)] = op
+		}
+		return nil
+	})
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "Price Tracker API", "version": "1.0.0"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": sg.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+				"adminKey":   map[string]any{"type": "apiKey", "in": "header", "name": "X-Admin-Key"},
+			},
+		},
+	}
+}
+
+func openAPITag(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	return parts[0]
+}
+
+func openAPISecurity(path string) []any {
+	switch {
+	case strings.HasPrefix(path, "/api/admin/"):
+		return []any{map[string]any{"adminKey": []string{}}, map[string]any{"bearerAuth": []string{}}}
+	case path == "/api/user/register", strings.HasPrefix(path, "/api/user/login"):
+		return nil
+	case strings.HasPrefix(path, "/api/user/"), strings.HasPrefix(path, "/api/item/"),
+		strings.HasPrefix(path, "/api/barcode/"), strings.HasPrefix(path, "/api/moderation/"),
+		strings.HasPrefix(path, "/api/merchant/"), path == StreamPath:
+		return []any{map[string]any{"bearerAuth": []string{}}}
+	}
+	return nil
+}
+
+// openAPISchemaGenerator generates JSON schemas following encoding/json rules, named types outside
+// this package become components referenced by name.
+type openAPISchemaGenerator struct {
+	components map[string]any
+}
+
+var (
+	openAPITimeType     = reflect.TypeOf(time.Time{})
+	openAPIDateTimeType = reflect.TypeOf(primitive.DateTime(0))
+	openAPIObjectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+func (sg openAPISchemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case openAPITimeType, openAPIDateTimeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case openAPIObjectIDType:
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": sg.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": sg.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" || t.PkgPath() == reflect.TypeOf(Server{}).PkgPath() {
+			return sg.structSchema(t)
+		}
+		name := openAPIComponentName(t)
+		if _, ok := sg.components[name]; !ok {
+			// Registered before generating so self-referencing types terminate.
+			sg.components[name] = map[string]any{}
+			sg.components[name] = sg.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (sg openAPISchemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	sg.addProperties(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (sg openAPISchemaGenerator) addProperties(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				sg.addProperties(ft, properties)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = sg.schema(f.Type)
+	}
+}
+
+func openAPIComponentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if idx := strings.LastIndex(pkg, "/"); idx >= 0 {
+		pkg = pkg[idx+1:]
+	}
+	if pkg == "model" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+func (s Server) openAPI(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeJsonResponse(w, openAPIDocument(router), http.StatusOK)
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Price Tracker API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/api/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func (s Server) apiDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write([]byte(swaggerUIPage)); err != nil {
+			s.Logger.Errorf("apiDocs: Error writing Swagger UI page, err: %v", err)
+		}
+	}
+}
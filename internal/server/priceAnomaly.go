@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"pricetracker/internal/model"
+	"sort"
+	"time"
+)
+
+const (
+	// priceAnomalyWindow is how far back prices are used for the baseline a new price is compared with.
+	priceAnomalyWindow = 14 * 24 * time.Hour
+	// priceAnomalyMinSamples is the minimum number of prices in priceAnomalyWindow to detect anomalies.
+	priceAnomalyMinSamples = 5
+	// priceAnomalySpikePercent is how many percent above the baseline a price has to be to be a spike.
+	priceAnomalySpikePercent = 15
+	// priceAnomalyBaselinePercent is how many percent below the baseline a drop from a spike may land
+	// and still only be a return to the baseline.
+	priceAnomalyBaselinePercent = 3
+)
+
+// priceMedian returns the median of prices, it sorts prices in place.
+func priceMedian(prices []int) int {
+	if len(prices) == 0 {
+		return 0
+	}
+	sort.Ints(prices)
+	mid := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[mid-1] + prices[mid]) / 2
+	}
+	return prices[mid]
+}
+
+// detectPriceAnomaly compares a price change from previous to price with the baseline price, a price well above
+// the baseline is a spike and a drop from a spike back to around the baseline is a fake drop.
+func detectPriceAnomaly(previous int, price int, baseline int) string {
+	if baseline <= 0 || price == previous {
+		return ""
+	}
+	spike := func(p int) bool { return p*100 > baseline*(100+priceAnomalySpikePercent) }
+	if spike(price) {
+		return model.ItemHistoryAnomalySpike
+	}
+	if price < previous && spike(previous) && price*100 >= baseline*(100-priceAnomalyBaselinePercent) {
+		return model.ItemHistoryAnomalyFakeDrop
+	}
+	return ""
+}
+
+// priceAnomaly detects whether the new price of i is an anomaly compared to the median of its recent prices,
+// no anomaly is detected while there are too few recent prices.
+func (s Server) priceAnomaly(ctx context.Context, i model.Item, price int) string {
+	if price == i.Price {
+		return ""
+	}
+	prices, err := s.DB.ItemHistoryPricesSince(ctx, i.ID, time.Now().Add(-priceAnomalyWindow))
+	if err != nil {
+		s.Logger.Errorf("priceAnomaly: Error getting recent prices for ItemID: %s, err: %v", i.ID.Hex(), err)
+		return ""
+	}
+	if len(prices) < priceAnomalyMinSamples {
+		return ""
+	}
+	return detectPriceAnomaly(i.Price, price, priceMedian(prices))
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/email"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// priceDigestBatchSize is how many Users due a digest of each frequency are emailed per tick.
+const priceDigestBatchSize = 200
+
+// SendPriceDigestsInInterval emails the daily and weekly price digests of the Users due one on every tick.
+func (s Server) SendPriceDigestsInInterval(ctx context.Context, ticker *time.Ticker) {
+	s.Logger.Info("SendPriceDigestsInInterval: Starting price digest emails")
+	for range ticker.C {
+		s.sendPriceDigests(ctx, model.DigestDaily, time.Now())
+		s.sendPriceDigests(ctx, model.DigestWeekly, time.Now())
+	}
+}
+
+// sendPriceDigests emails the price changes of the tracked Items over the period of frequency to the Users who were
+// last sent a digest of frequency at least a period before now. Users whose tracked Items kept their price are not
+// emailed until the next period, Users whose email failed to send are retried on the next tick. Users whose tier does
+// not include digests are not emailed either.
+func (s Server) sendPriceDigests(ctx context.Context, frequency string, now time.Time) {
+	since := now.Add(-model.DigestPeriod(frequency))
+	us, err := s.DB.UsersDigestDueFind(ctx, frequency, since, priceDigestBatchSize)
+	if err != nil {
+		s.Logger.Errorf("sendPriceDigests: Error finding Users due a %s digest, err: %v", frequency, err)
+		return
+	}
+	if len(us) == 0 {
+		return
+	}
+
+	var itemIDs []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, u := range us {
+		for _, ti := range u.TrackedItems {
+			if !seen[ti.ItemID] {
+				seen[ti.ItemID] = true
+				itemIDs = append(itemIDs, ti.ItemID)
+			}
+		}
+	}
+	items := make(map[primitive.ObjectID]model.Item, len(itemIDs))
+	var startPrices map[primitive.ObjectID]int
+	if len(itemIDs) > 0 {
+		is, err := s.DB.ItemsFind(ctx, itemIDs)
+		if err != nil {
+			s.Logger.Errorf("sendPriceDigests: Error finding %d tracked Item(s) for %s digests, err: %v", len(itemIDs), frequency, err)
+			return
+		}
+		for _, i := range is {
+			items[i.ID] = i
+		}
+		if startPrices, err = s.DB.ItemHistoryFirstPricesSince(ctx, itemIDs, since); err != nil {
+			s.Logger.Errorf("sendPriceDigests: Error finding prices of %d tracked Item(s) since: %s, err: %v",
+				len(itemIDs), since.Format(time.RFC3339), err)
+			return
+		}
+	}
+
+	var sent int
+	for _, u := range us {
+		d := email.Digest{Locale: u.Locale, UserName: u.Name, Frequency: frequency, Since: since}
+		if !userLimits(u).Digest {
+			// Marked as sent all the same, for the User not to be found due again on every tick.
+			s.Logger.Debugf("sendPriceDigests: Digest not included in tier of User with ID: %s", u.ID.Hex())
+			u.TrackedItems = nil
+		}
+		for _, ti := range u.TrackedItems {
+			i, ok := items[ti.ItemID]
+			startPrice, started := startPrices[ti.ItemID]
+			if !ok || !started || startPrice == i.Price {
+				continue
+			}
+			d.Entries = append(d.Entries, email.DigestEntry{
+				Name:     i.Name,
+				URL:      i.URL,
+				ImageURL: i.ImageURL,
+				OldPrice: model.FormatPrice(startPrice, i.Currency),
+				NewPrice: model.FormatPrice(i.Price, i.Currency),
+				Dropped:  i.Price < startPrice,
+			})
+		}
+		if len(d.Entries) > 0 {
+			subject, body, err := email.RenderDigest(d)
+			if err != nil {
+				s.Logger.Errorf("sendPriceDigests: Error rendering %s digest for User with ID: %s, err: %v", frequency, u.ID.Hex(), err)
+				continue
+			}
+			if err = s.Client.SMTPSendMail(ctx, u.Email, subject, body); err != nil {
+				s.Logger.Errorf("sendPriceDigests: Error sending %s digest to User with ID: %s, err: %v", frequency, u.ID.Hex(), err)
+				continue
+			}
+			sent++
+		}
+		if err = s.DB.UserDigestSentAtSet(ctx, u.ID, now); err != nil {
+			s.Logger.Errorf("sendPriceDigests: Error setting DigestSentAt on User with ID: %s, err: %v", u.ID.Hex(), err)
+		}
+	}
+	s.Logger.Infof("sendPriceDigests: Sent %s digests to %d of %d due User(s)", frequency, sent, len(us))
+}
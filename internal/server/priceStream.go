@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net"
+	"net/http"
+	"pricetracker/internal/model"
+	"sync"
+	"time"
+)
+
+// StreamPath is the path of the price update stream, it has to be served without a handler timeout.
+const StreamPath = "/api/stream"
+
+const (
+	// StreamMaxDuration is how long a stream stays open before the client has to reconnect.
+	StreamMaxDuration = 5 * time.Minute
+	// streamWriteTimeout bounds every write to a stream, replacing the write timeout of the HTTP server which would
+	// otherwise end the stream.
+	streamWriteTimeout = 20 * time.Second
+	// streamsPerUserLimit is how many streams a User can have open at the same time on an instance.
+	streamsPerUserLimit = 3
+	// streamHeartbeatInterval keeps idle streams from being closed by proxies.
+	streamHeartbeatInterval = 30 * time.Second
+	// streamBufferSize is how many updates are buffered per stream, updates to slower clients are dropped.
+	streamBufferSize = 32
+	// priceUpdatesChannel is the Redis channel the fetcher publishes price updates on.
+	priceUpdatesChannel = "price_updates"
+)
+
+type streamConnKey struct{}
+
+// ConnContext has to be set as the ConnContext of the http.Server serving StreamPath, streams set the write deadline
+// of their connection from it.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, streamConnKey{}, c)
+}
+
+// priceUpdate is the price and stock of an Item from a new ItemHistory.
+type priceUpdate struct {
+	ItemID    string             `json:"item_id"`
+	Price     int                `json:"price"`
+	Stock     int                `json:"stock"`
+	FlashSale bool               `json:"flash_sale,omitempty"`
+	Timestamp primitive.DateTime `json:"timestamp"`
+}
+
+// PriceStream fans out price updates to the open streams of the Users tracking the updated Items.
+type PriceStream struct {
+	mu          sync.Mutex
+	subscribers map[*priceStreamSubscriber]struct{}
+	// userStreams is how many streams each User has open, by User ID.
+	userStreams map[string]int
+	closed      bool
+}
+
+type priceStreamSubscriber struct {
+	userID  string
+	itemIDs map[string]bool
+	updates chan priceUpdate
+}
+
+func NewPriceStream() *PriceStream {
+	return &PriceStream{
+		subscribers: make(map[*priceStreamSubscriber]struct{}),
+		userStreams: make(map[string]int),
+	}
+}
+
+// subscribe opens a stream of the updates of itemIDs for the User with userID, it returns false when the User
+// already has streamsPerUserLimit streams open.
+func (ps *PriceStream) subscribe(userID string, itemIDs []string) (*priceStreamSubscriber, bool) {
+	sub := &priceStreamSubscriber{
+		userID:  userID,
+		itemIDs: make(map[string]bool, len(itemIDs)),
+		updates: make(chan priceUpdate, streamBufferSize),
+	}
+	for _, id := range itemIDs {
+		sub.itemIDs[id] = true
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.userStreams[userID] >= streamsPerUserLimit {
+		return nil, false
+	}
+	if ps.closed {
+		close(sub.updates)
+		return sub, true
+	}
+	ps.subscribers[sub] = struct{}{}
+	ps.userStreams[userID]++
+	return sub, true
+}
+
+func (ps *PriceStream) unsubscribe(sub *priceStreamSubscriber) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.subscribers[sub]; ok {
+		delete(ps.subscribers, sub)
+		close(sub.updates)
+		ps.userStreamsDec(sub.userID)
+	}
+}
+
+// userStreamsDec decrements the open streams of the User with userID, ps.mu must be held.
+func (ps *PriceStream) userStreamsDec(userID string) {
+	if ps.userStreams[userID] <= 1 {
+		delete(ps.userStreams, userID)
+	} else {
+		ps.userStreams[userID]--
+	}
+}
+
+// broadcast sends u to the subscribers of its Item without blocking, it reports how many updates were dropped.
+func (ps *PriceStream) broadcast(u priceUpdate) (dropped int) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for sub := range ps.subscribers {
+		if !sub.itemIDs[u.ItemID] {
+			continue
+		}
+		select {
+		case sub.updates <- u:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Close ends every open stream, it is meant to be registered with http.Server.RegisterOnShutdown.
+func (ps *PriceStream) Close() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.closed = true
+	for sub := range ps.subscribers {
+		delete(ps.subscribers, sub)
+		close(sub.updates)
+		ps.userStreamsDec(sub.userID)
+	}
+}
+
+// publishPriceUpdate publishes the new ItemHistory ih to the price streams, through Redis when it is enabled
+// so streams served by other instances receive it too.
+func (s Server) publishPriceUpdate(ctx context.Context, ih model.ItemHistory) {
+	u := priceUpdate{
+		ItemID:    ih.ItemID.Hex(),
+		Price:     ih.Price,
+		Stock:     ih.Stock,
+		FlashSale: ih.FlashSale,
+		Timestamp: ih.Timestamp,
+	}
+	if s.Redis == nil {
+		if s.PriceStream != nil {
+			s.PriceStream.broadcast(u)
+		}
+		return
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		s.Logger.Errorf("publishPriceUpdate: Error marshalling price update: %+v, err: %v", u, err)
+		return
+	}
+	if err = s.Redis.Publish(ctx, priceUpdatesChannel, b).Err(); err != nil {
+		s.Logger.Errorf("publishPriceUpdate: Error publishing price update of ItemID: %s, err: %v", u.ItemID, err)
+	}
+}
+
+// RelayPriceUpdates broadcasts the price updates published on Redis to the price streams of this instance
+// until ctx is done. It returns immediately when Redis is disabled, updates are then broadcast directly.
+func (s Server) RelayPriceUpdates(ctx context.Context) {
+	if s.Redis == nil || s.PriceStream == nil {
+		return
+	}
+	pubSub := s.Redis.Subscribe(ctx, priceUpdatesChannel)
+	defer func() {
+		if err := pubSub.Close(); err != nil {
+			s.Logger.Errorf("RelayPriceUpdates: Error closing subscription, err: %v", err)
+		}
+	}()
+	ch := pubSub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			u := priceUpdate{}
+			if err := json.Unmarshal([]byte(msg.Payload), &u); err != nil {
+				s.Logger.Errorf("RelayPriceUpdates: Error unmarshalling price update: %s, err: %v", msg.Payload, err)
+				continue
+			}
+			if dropped := s.PriceStream.broadcast(u); dropped > 0 {
+				s.Logger.Debugf("RelayPriceUpdates: Dropped price update of ItemID: %s for %d slow stream(s)", u.ItemID, dropped)
+			}
+		}
+	}
+}
+
+// userStream streams the price updates of the Items tracked by the User as Server-Sent Events,
+// Items tracked after the stream was opened are included after reconnecting.
+func (s Server) userStream() http.HandlerFunc {
+	openAPIRegister("userStream", nil, priceUpdate{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userStream: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if s.PriceStream == nil || !ok {
+			s.Logger.Errorf("userStream: Streaming is not supported, PriceStream set: %t", s.PriceStream != nil)
+			http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+			return
+		}
+
+		itemIDs := make([]string, 0, len(uc.user.TrackedItems))
+		for _, ti := range uc.user.TrackedItems {
+			itemIDs = append(itemIDs, ti.ItemID.Hex())
+		}
+		sub, ok := s.PriceStream.subscribe(uc.user.ID.Hex(), itemIDs)
+		if !ok {
+			s.Logger.Debugf("userStream: Open streams limit reached for User with ID: %s", uc.user.ID.Hex())
+			http.Error(w, "Too many open streams", http.StatusTooManyRequests)
+			return
+		}
+		defer s.PriceStream.unsubscribe(sub)
+		// Every write gets its own deadline, the stream outlives the write timeout of the HTTP server. HTTP/2 connections
+		// are shared by streams, their writes stay bounded by the write timeout and the client reconnects after it.
+		conn, _ := r.Context().Value(streamConnKey{}).(net.Conn)
+		writeDeadline := func() bool {
+			if r.ProtoMajor != 1 {
+				return true
+			}
+			if conn == nil {
+				s.Logger.Error("userStream: Error setting write deadline, connection not in request context")
+				return false
+			}
+			if err := conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+				s.Logger.Errorf("userStream: Error setting write deadline, err: %v", err)
+				return false
+			}
+			return true
+		}
+		if !writeDeadline() {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err = fmt.Fprintf(w, "retry: %d\n\n", time.Second.Milliseconds()); err != nil {
+			return
+		}
+		flusher.Flush()
+		s.Logger.Debugf("userStream: Streaming %d Item(s) to User with ID: %s", len(itemIDs), uc.user.ID.Hex())
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		maxDuration := time.NewTimer(StreamMaxDuration)
+		defer maxDuration.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-maxDuration.C:
+				return
+			case <-heartbeat.C:
+				if !writeDeadline() {
+					return
+				}
+				if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case u, ok := <-sub.updates:
+				if !ok {
+					return
+				}
+				b, err := json.Marshal(u)
+				if err != nil {
+					s.Logger.Errorf("userStream: Error marshalling price update: %+v, err: %v", u, err)
+					continue
+				}
+				if !writeDeadline() {
+					return
+				}
+				if _, err = fmt.Fprintf(w, "event: price\ndata: %s\n\n", b); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"pricetracker/internal/client"
+	"pricetracker/internal/i18n"
+	"pricetracker/internal/model"
+	"pricetracker/internal/service"
+	"strings"
+	"time"
+)
+
+const (
+	// queuedNotificationsBatchSize is how many due QueuedNotifications are delivered per tick.
+	queuedNotificationsBatchSize = 500
+	// queuedNotificationMaxAttempts is how many times sending QueuedNotifications is tried before they are dropped.
+	queuedNotificationMaxAttempts = 5
+	// queuedNotificationRetryDelay is how long QueuedNotifications wait after their first failed attempt,
+	// doubling after every other one.
+	queuedNotificationRetryDelay = 5 * time.Minute
+)
+
+func (s Server) DeliverQueuedNotificationsInInterval(ctx context.Context, ticker *time.Ticker) {
+	s.Logger.Info("DeliverQueuedNotificationsInInterval: Starting queued notification delivery")
+	for range ticker.C {
+		s.deliverQueuedNotifications(ctx)
+	}
+}
+
+// deliverQueuedNotifications sends the due QueuedNotifications of every User,
+// several notifications for the same User are combined into one. QueuedNotifications are deleted once sent, or when
+// they can not be delivered as their User is gone or has no channels enabled. Failed ones are sent again after
+// queuedNotificationRetryDelay with exponential backoff, and dropped after queuedNotificationMaxAttempts.
+func (s Server) deliverQueuedNotifications(ctx context.Context) {
+	qns, err := s.DB.QueuedNotificationsFindDue(ctx, time.Now(), queuedNotificationsBatchSize)
+	if err != nil {
+		s.Logger.Errorf("deliverQueuedNotifications: Error finding due QueuedNotifications, err: %v", err)
+		return
+	}
+	if len(qns) == 0 {
+		return
+	}
+
+	var userIDs []primitive.ObjectID
+	byUser := make(map[primitive.ObjectID][]model.QueuedNotification)
+	for _, qn := range qns {
+		if _, ok := byUser[qn.UserID]; !ok {
+			userIDs = append(userIDs, qn.UserID)
+		}
+		byUser[qn.UserID] = append(byUser[qn.UserID], qn)
+	}
+
+	var delivered []primitive.ObjectID
+	for _, userID := range userIDs {
+		uqns := byUser[userID]
+		u, err := s.DB.UserFindByID(ctx, userID.Hex())
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Debugf("deliverQueuedNotifications: User with ID: %s not found, dropping %d QueuedNotification(s)",
+					userID.Hex(), len(uqns))
+				delivered = appendQueuedNotificationIDs(delivered, uqns)
+				continue
+			}
+			s.Logger.Errorf("deliverQueuedNotifications: Error finding User with ID: %s, keeping %d QueuedNotification(s), err: %v",
+				userID.Hex(), len(uqns), err)
+			continue
+		}
+		var rcp service.Recipients
+		if !s.addUserChannels(&rcp, u) {
+			s.Logger.Debugf("deliverQueuedNotifications: No channels enabled for User with ID: %s, dropping %d QueuedNotification(s)",
+				userID.Hex(), len(uqns))
+			delivered = appendQueuedNotificationIDs(delivered, uqns)
+			continue
+		}
+
+		msg := service.Message{
+			Event: uqns[0].Event,
+			Title: uqns[0].Title,
+			Body:  uqns[0].Body,
+			Text:  uqns[0].Text,
+			FCMData: client.FCMData{
+				Type:           uqns[0].FCMType,
+				Action:         uqns[0].FCMAction,
+				ReplacementURL: uqns[0].ReplacementURL,
+				ImageURL:       uqns[0].ImageURL,
+				OldPrice:       uqns[0].OldPrice,
+				NewPrice:       uqns[0].NewPrice,
+				DeepLink:       uqns[0].DeepLink,
+			},
+		}
+		if !uqns[0].ItemID.IsZero() {
+			msg.FCMData.ItemID = uqns[0].ItemID.Hex()
+		}
+		if len(uqns) > 1 {
+			var body, text []string
+			var items []client.FCMDataItem
+			for idx, qn := range uqns {
+				if idx < notificationBatchListed {
+					body = append(body, qn.Body)
+				}
+				text = append(text, qn.Text)
+				if !qn.ItemID.IsZero() {
+					items = append(items, client.FCMDataItem{
+						ItemID:   qn.ItemID.Hex(),
+						ImageURL: qn.ImageURL,
+						OldPrice: qn.OldPrice,
+						NewPrice: qn.NewPrice,
+						DeepLink: qn.DeepLink,
+					})
+				}
+			}
+			if more := len(uqns) - notificationBatchListed; more > 0 {
+				body = append(body, i18n.T(u.Locale, i18n.ListMore, more))
+			}
+			msg = service.Message{
+				Event:   "quiet_hours_digest",
+				Title:   i18n.T(u.Locale, i18n.QuietHoursDigestTitle, len(uqns)),
+				Body:    strings.Join(body, "\n"),
+				Text:    strings.Join(text, "\n\n"),
+				FCMData: client.FCMData{Type: "quiet_hours_digest"},
+			}
+			if len(items) > 0 {
+				itemsJSON, err := json.Marshal(items)
+				if err != nil {
+					s.Logger.Errorf("deliverQueuedNotifications: Error marshalling Items of User with ID: %s, err: %v", userID.Hex(), err)
+				} else {
+					msg.FCMData.Items = string(itemsJSON)
+				}
+			}
+		}
+		s.Logger.Infof("deliverQueuedNotifications: Delivering %d QueuedNotification(s) to User with ID: %s", len(uqns), userID.Hex())
+		if !s.Notifications.Send(ctx, model.Item{Name: fmt.Sprintf("%d queued", len(uqns))}, rcp, msg) {
+			attempts := 1
+			for _, qn := range uqns {
+				if qn.Attempts+1 > attempts {
+					attempts = qn.Attempts + 1
+				}
+			}
+			if attempts >= queuedNotificationMaxAttempts {
+				s.Logger.Errorf("deliverQueuedNotifications: No notifications sent for User with ID: %s in %d attempts, dropping %d QueuedNotification(s)",
+					userID.Hex(), attempts, len(uqns))
+				delivered = appendQueuedNotificationIDs(delivered, uqns)
+				continue
+			}
+			retryAt := time.Now().Add(queuedNotificationRetryDelay << (attempts - 1))
+			s.Logger.Errorf("deliverQueuedNotifications: No notifications sent for User with ID: %s, retrying %d QueuedNotification(s) at %v",
+				userID.Hex(), len(uqns), retryAt)
+			if _, err = s.DB.QueuedNotificationsRetry(ctx, appendQueuedNotificationIDs(nil, uqns), retryAt); err != nil {
+				s.Logger.Errorf("deliverQueuedNotifications: Error retrying QueuedNotifications of User with ID: %s, err: %v",
+					userID.Hex(), err)
+			}
+			continue
+		}
+		delivered = appendQueuedNotificationIDs(delivered, uqns)
+	}
+
+	if len(delivered) == 0 {
+		return
+	}
+	deleted, err := s.DB.QueuedNotificationsDelete(ctx, delivered)
+	if err != nil {
+		s.Logger.Errorf("deliverQueuedNotifications: Error deleting delivered QueuedNotifications, err: %v", err)
+		return
+	}
+	s.Logger.Infof("deliverQueuedNotifications: Delivered %d QueuedNotification(s) of %d User(s)", deleted, len(userIDs))
+}
+
+func appendQueuedNotificationIDs(ids []primitive.ObjectID, qns []model.QueuedNotification) []primitive.ObjectID {
+	for _, qn := range qns {
+		ids = append(ids, qn.ID)
+	}
+	return ids
+}
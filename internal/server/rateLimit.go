@@ -0,0 +1,188 @@
+package server
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"math"
+	"net/http"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+type rateLimit struct {
+	name string
+	// burst is the bucket capacity, refillEvery is how long it takes to refill one token.
+	burst       int
+	refillEvery time.Duration
+}
+
+var (
+	// ipRateLimit applies to every request under /api, keyed by client IP.
+	ipRateLimit = rateLimit{name: "ip", burst: 120, refillEvery: 500 * time.Millisecond}
+	// loginRateLimit applies to unauthenticated credential endpoints, keyed by client IP.
+	loginRateLimit = rateLimit{name: "login", burst: 10, refillEvery: 30 * time.Second}
+	// userRateLimit applies to authenticated endpoints, keyed by user ID.
+	userRateLimit = rateLimit{name: "user", burst: 60, refillEvery: time.Second}
+	// searchRateLimit applies to item search on top of userRateLimit since every uncached search hits the sites.
+	searchRateLimit = rateLimit{name: "search", burst: 20, refillEvery: 6 * time.Second}
+	// refreshRateLimit applies to on-demand Item refreshes on top of userRateLimit since every one hits the sites.
+	refreshRateLimit = rateLimit{name: "refresh", burst: 5, refillEvery: time.Minute}
+	// streamRateLimit applies to opening price streams, keyed by user ID, as streams reconnect on their own.
+	streamRateLimit = rateLimit{name: "stream", burst: 10, refillEvery: 30 * time.Second}
+)
+
+// tokenBucketScript takes one token from the bucket at KEYS[1] after refilling it based on elapsed time.
+// It returns 0 when a token was taken, or the milliseconds to wait until one is available.
+var tokenBucketScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local refill_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + (now - ts) / refill_ms)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * refill_ms)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * refill_ms))
+return wait
+`)
+
+// rateLimitTake returns how long the caller has to wait before the request is allowed, zero meaning allowed.
+// When Redis is unavailable the limit is checked in process instead, limiting the requests to this instance.
+func (s Server) rateLimitTake(r *http.Request, rl rateLimit, key string) time.Duration {
+	if s.Redis == nil || s.localRateLimits.redisSkipped(time.Now()) {
+		return s.localRateLimits.take(rl, key, time.Now())
+	}
+	wait, err := tokenBucketScript.Run(r.Context(), s.Redis, []string{"ratelimit:" + rl.name + ":" + key},
+		rl.burst, rl.refillEvery.Milliseconds(), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		s.Logger.Errorf("rateLimitTake: Error running token bucket script for %s limit, checking it in process, key: %s, err: %v",
+			rl.name, key, err)
+		s.localRateLimits.redisFailed(time.Now())
+		return s.localRateLimits.take(rl, key, time.Now())
+	}
+	return time.Duration(wait) * time.Millisecond
+}
+
+func (s Server) rateLimitMw(rl rateLimit, keyFunc func(r *http.Request) (string, bool)) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := keyFunc(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if wait := s.rateLimitTake(r, rl, key); wait > 0 {
+				s.Logger.Infof("rateLimitMw: Rate limited %s %s, limit: %s, key: %s, wait: %v, TraceID: %s",
+					r.Method, r.URL.Path, rl.name, key, wait, getTraceContext(r.Context()).traceID)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKeyIP(r *http.Request) (string, bool) {
+	return requestIP(r), true
+}
+
+func rateLimitKeyUser(r *http.Request) (string, bool) {
+	uc, err := getUserContext(r.Context())
+	if err != nil {
+		return "", false
+	}
+	return uc.user.ID.Hex(), true
+}
+
+// apiQuotaTake counts a request of u against the APIRequestsPerDay of its tier in a Redis counter per UTC day,
+// it returns how long until the quota resets when it is used up and zero otherwise.
+// When Redis is unavailable requests are counted in process instead, counting the requests to this instance.
+func (s Server) apiQuotaTake(r *http.Request, u model.User) time.Duration {
+	now := time.Now().UTC()
+	key := "apiquota:" + u.ID.Hex() + ":" + now.Format("2006-01-02")
+	var count int64
+	if s.Redis == nil || s.localRateLimits.redisSkipped(now) {
+		count = s.localRateLimits.count(key, now)
+	} else {
+		pipe := s.Redis.TxPipeline()
+		incr := pipe.Incr(r.Context(), key)
+		pipe.Expire(r.Context(), key, 48*time.Hour)
+		if _, err := pipe.Exec(r.Context()); err != nil {
+			s.Logger.Errorf("apiQuotaTake: Error counting request of User with ID: %s, counting it in process, err: %v",
+				u.ID.Hex(), err)
+			s.localRateLimits.redisFailed(now)
+			count = s.localRateLimits.count(key, now)
+		} else {
+			count = incr.Val()
+		}
+	}
+	if count <= int64(userLimits(u).APIRequestsPerDay) {
+		return 0
+	}
+	return now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
+
+// apiQuotaMw enforces the APIRequestsPerDay entitlement limit, it must run after authMw.
+func (s Server) apiQuotaMw(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if wait := s.apiQuotaTake(r, uc.user); wait > 0 {
+			s.Logger.Infof("apiQuotaMw: Daily API quota used up by User with ID: %s, resets in: %v, TraceID: %s",
+				uc.user.ID.Hex(), wait, getTraceContext(r.Context()).traceID)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Daily API request limit reached", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// refreshCooldownTake starts the RefreshCooldown of u for the Item with itemID in Redis, it returns how long until the
+// cooldown started by a previous refresh is over and zero when the refresh is allowed.
+// When Redis is unavailable the cooldown is kept in process instead, only applying to this instance.
+func (s Server) refreshCooldownTake(r *http.Request, u model.User, itemID string) time.Duration {
+	cooldown := userLimits(u).RefreshCooldown
+	if cooldown <= 0 {
+		return 0
+	}
+	key := "refreshcooldown:" + u.ID.Hex() + ":" + itemID
+	if s.Redis == nil || s.localRateLimits.redisSkipped(time.Now()) {
+		return s.localRateLimits.cooldown(key, cooldown, time.Now())
+	}
+	started, err := s.Redis.SetNX(r.Context(), key, 1, cooldown).Result()
+	if err != nil {
+		s.Logger.Errorf("refreshCooldownTake: Error starting refresh cooldown, keeping it in process, key: %s, err: %v", key, err)
+		s.localRateLimits.redisFailed(time.Now())
+		return s.localRateLimits.cooldown(key, cooldown, time.Now())
+	}
+	if started {
+		return 0
+	}
+	wait, err := s.Redis.PTTL(r.Context(), key).Result()
+	if err != nil {
+		s.Logger.Errorf("refreshCooldownTake: Error getting refresh cooldown, key: %s, err: %v", key, err)
+		s.localRateLimits.redisFailed(time.Now())
+		return cooldown
+	}
+	if wait <= 0 {
+		// The cooldown was over between SETNX and PTTL.
+		return 0
+	}
+	return wait
+}
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// commandCounter counts the Redis commands sent, pipelines counting as one.
+type commandCounter struct {
+	commands int32
+}
+
+func (c *commandCounter) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	atomic.AddInt32(&c.commands, 1)
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (c *commandCounter) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	atomic.AddInt32(&c.commands, 1)
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+// TestRateLimitRedisDown checks that once Redis fails, the rate limits and the API quota are checked in process
+// without trying Redis on every request.
+func TestRateLimitRedisDown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialTimeout: time.Second})
+	defer redisClient.Close()
+	counter := &commandCounter{}
+	redisClient.AddHook(counter)
+
+	db := &mock.Database{}
+	s := newTestServer(t, db)
+	s.Redis = redisClient
+	u := model.User{}
+	lt := loginTestUser(t, s, db, &u)
+	router := s.Router()
+
+	for idx := 0; idx < 3; idx++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/unknown", nil)
+		req.Header.Set("Authorization", "Bearer "+lt)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("request %d: got status %d, want %d, body: %s", idx, rec.Code, http.StatusNotFound, rec.Body)
+		}
+	}
+	if got := atomic.LoadInt32(&counter.commands); got != 1 {
+		t.Errorf("got %d Redis commands, want 1", got)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/big"
+	"net/http"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+const referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const referralCodeLength = 8
+
+// referralTrackedItemsBonusMax is the most tracked items a User can earn with referrals.
+const referralTrackedItemsBonusMax = 50
+
+type referralRewardHook func(s Server, ctx context.Context, referrer model.User, referredUserID string) error
+
+var referralRewardHooks = []referralRewardHook{
+	trackedItemsBonusReward,
+}
+
+func trackedItemsBonusReward(s Server, ctx context.Context, referrer model.User, _ string) error {
+	return s.DB.UserReferralRewardAdd(ctx, referrer.ID, s.ReferralRewardTrackedItems, referralTrackedItemsBonusMax)
+}
+
+// rewardReferredUser rewards the referrer of u when u tracked their first Item, so registering alone earns nothing.
+// The referral is claimed before the reward hooks run, so a referrer is rewarded at most once for every User.
+func (s Server) rewardReferredUser(ctx context.Context, u model.User) {
+	if u.Referral.ReferredBy.IsZero() || u.Referral.RewardedAt != 0 || len(u.TrackedItems) > 0 {
+		return
+	}
+	referrerID, err := s.DB.UserReferralRewardClaim(ctx, u.ID)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			s.Logger.Errorf("rewardReferredUser: Error claiming referral reward of User with ID: %s, err: %v", u.ID.Hex(), err)
+		}
+		return
+	}
+	referrer, err := s.DB.UserFindByID(ctx, referrerID.Hex())
+	if err != nil {
+		s.Logger.Errorf("rewardReferredUser: Error finding referrer with ID: %s of User with ID: %s, err: %v",
+			referrerID.Hex(), u.ID.Hex(), err)
+		return
+	}
+	s.rewardReferral(ctx, referrer, u.ID.Hex())
+}
+
+func (s Server) rewardReferral(ctx context.Context, referrer model.User, referredUserID string) {
+	for _, hook := range referralRewardHooks {
+		if err := hook(s, ctx, referrer, referredUserID); err != nil {
+			s.Logger.Errorf("rewardReferral: Error running referral reward hook for ReferrerID: %s, ReferredUserID: %s, err: %v",
+				referrer.ID.Hex(), referredUserID, err)
+		}
+	}
+	s.Logger.Infof("rewardReferral: Rewarded referral for ReferrerID: %s, ReferredUserID: %s", referrer.ID.Hex(), referredUserID)
+}
+
+func (s Server) userReferralCode() http.HandlerFunc {
+	type response struct {
+		Code string `json:"code"`
+	}
+	openAPIRegister("userReferralCode", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userReferralCode: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if uc.user.Referral.Code != "" {
+			s.writeJsonResponse(w, response{Code: uc.user.Referral.Code}, http.StatusOK)
+			return
+		}
+
+		for attempt := 0; attempt < 3; attempt++ {
+			code, err := generateReferralCode()
+			if err != nil {
+				s.Logger.Errorf("userReferralCode: Error generating referral code, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			err = s.DB.UserReferralCodeSet(r.Context(), uc.user.ID.Hex(), code)
+			if err == nil {
+				s.writeJsonResponse(w, response{Code: code}, http.StatusCreated)
+				return
+			}
+			if !mongo.IsDuplicateKeyError(err) {
+				s.Logger.Errorf("userReferralCode: Error setting referral code on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			s.Logger.Debugf("userReferralCode: Duplicate referral code: %s, retrying", code)
+		}
+		s.Logger.Errorf("userReferralCode: Failed to generate unique referral code for User with ID: %s", uc.user.ID.Hex())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (s Server) userReferral() http.HandlerFunc {
+	type response struct {
+		model.Referral
+		TrackedItemsLimit int `json:"tracked_items_limit"`
+	}
+	openAPIRegister("userReferral", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userReferral: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{
+			Referral:          uc.user.Referral,
+			TrackedItemsLimit: s.trackedItemsLimit(uc.user),
+		}, http.StatusOK)
+	}
+}
+
+func (s Server) findReferrer(ctx context.Context, code string) (model.User, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != referralCodeLength {
+		return model.User{}, errors.Wrapf(mongo.ErrNoDocuments, "invalid referral code length: %s", code)
+	}
+	return s.DB.UserFindByReferralCode(ctx, code)
+}
+
+func generateReferralCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(referralCodeAlphabet)))
+	for i := 0; i < referralCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", errors.Wrap(err, "error generating random number for referral code")
+		}
+		sb.WriteByte(referralCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
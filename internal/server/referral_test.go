@@ -0,0 +1,95 @@
+package server_test
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"testing"
+	"time"
+)
+
+// TestRewardReferredUser checks that a referrer is only rewarded for a referred User tracking their first Item,
+// once, and with the bonus capped.
+func TestRewardReferredUser(t *testing.T) {
+	referrerID := primitive.NewObjectID()
+	tests := []struct {
+		name       string
+		user       model.User
+		claimErr   error
+		wantClaim  bool
+		wantReward bool
+	}{
+		{
+			name: "not referred",
+			user: model.User{ID: primitive.NewObjectID()},
+		},
+		{
+			name:       "first tracked item",
+			user:       model.User{ID: primitive.NewObjectID(), Referral: model.Referral{ReferredBy: referrerID}},
+			wantClaim:  true,
+			wantReward: true,
+		},
+		{
+			name: "already tracking items",
+			user: model.User{
+				ID:           primitive.NewObjectID(),
+				Referral:     model.Referral{ReferredBy: referrerID},
+				TrackedItems: []model.TrackedItem{{ItemID: primitive.NewObjectID()}},
+			},
+		},
+		{
+			name: "already rewarded",
+			user: model.User{
+				ID:       primitive.NewObjectID(),
+				Referral: model.Referral{ReferredBy: referrerID, RewardedAt: primitive.NewDateTimeFromTime(time.Now())},
+			},
+		},
+		{
+			name:      "claimed concurrently",
+			user:      model.User{ID: primitive.NewObjectID(), Referral: model.Referral{ReferredBy: referrerID}},
+			claimErr:  errors.Wrap(mongo.ErrNoDocuments, "claimed"),
+			wantClaim: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claimed, rewarded bool
+			var gotBonus, gotBonusMax int
+			db := &mock.Database{
+				UserReferralRewardClaimFunc: func(_ context.Context, userID primitive.ObjectID) (primitive.ObjectID, error) {
+					claimed = true
+					if userID != tt.user.ID {
+						t.Errorf("got claimed UserID %s, want %s", userID.Hex(), tt.user.ID.Hex())
+					}
+					return referrerID, tt.claimErr
+				},
+				UserFindByIDFunc: func(_ context.Context, userID string) (model.User, error) {
+					return model.User{ID: referrerID}, nil
+				},
+				UserReferralRewardAddFunc: func(_ context.Context, userID primitive.ObjectID, bonus int, bonusMax int) error {
+					rewarded = true
+					if userID != referrerID {
+						t.Errorf("got rewarded UserID %s, want %s", userID.Hex(), referrerID.Hex())
+					}
+					gotBonus, gotBonusMax = bonus, bonusMax
+					return nil
+				},
+			}
+			s := newTestServer(t, db)
+			s.ReferralRewardTrackedItems = 10
+			s.RewardReferredUser(context.Background(), tt.user)
+			if claimed != tt.wantClaim {
+				t.Errorf("got claimed %t, want %t", claimed, tt.wantClaim)
+			}
+			if rewarded != tt.wantReward {
+				t.Errorf("got rewarded %t, want %t", rewarded, tt.wantReward)
+			}
+			if tt.wantReward && (gotBonus != 10 || gotBonusMax != 50) {
+				t.Errorf("got bonus %d with max %d, want 10 with max 50", gotBonus, gotBonusMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/exp/slices"
+	"net/http"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+)
+
+func (s Server) adminUserRoles() http.HandlerFunc {
+	type request struct {
+		Roles []string `json:"roles"`
+	}
+	type response struct {
+		UserID string   `json:"user_id"`
+		Roles  []string `json:"roles"`
+	}
+	openAPIRegister("adminUserRoles", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := mux.Vars(r)["userID"]
+
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("adminUserRoles: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		roles := []string{model.RoleUser}
+		for _, role := range req.Roles {
+			if !model.ValidRole(role) {
+				s.Logger.Debugf("adminUserRoles: Invalid role: %s", role)
+				http.Error(w, "Invalid role", http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+
+		if err := s.DB.UserRolesSet(r.Context(), userID, roles); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("adminUserRoles: User with ID: %s not found, err: %v", userID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("adminUserRoles: Error setting Roles on User with ID: %s, err: %v", userID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Infof("adminUserRoles: Set Roles: %v on User with ID: %s", roles, userID)
+		s.writeJsonResponse(w, response{UserID: userID, Roles: roles}, http.StatusOK)
+	}
+}
@@ -3,36 +3,129 @@ package server
 import (
 	"github.com/gorilla/mux"
 	"net/http"
+	"pricetracker/internal/model"
 )
 
+const routeAdminBarcodeImport = "adminBarcodeImport"
+
 func (s Server) Router() *mux.Router {
+	// The routes share the in-process fallbacks of the rate limits of this copy of s.
+	s.localRateLimits = newLocalRateLimits()
 	r := mux.NewRouter()
 	r.Use(s.maxBytesMw)
 	r.Use(s.loggingMw)
 
 	api := r.PathPrefix("/api").Subrouter()
+	api.Use(s.rateLimitMw(ipRateLimit, rateLimitKeyIP))
 
-	api.HandleFunc("/user/register", s.userRegister()).Methods(http.MethodPost)
-	api.HandleFunc("/user/login", s.userLogin()).Methods(http.MethodPost)
+	loginRateLimitMw := s.rateLimitMw(loginRateLimit, rateLimitKeyIP)
+	api.Handle("/user/register", loginRateLimitMw(s.userRegister())).Methods(http.MethodPost).Name("userRegister")
+	api.Handle("/user/login", loginRateLimitMw(s.userLogin())).Methods(http.MethodPost).Name("userLogin")
+	api.Handle("/user/login/google", loginRateLimitMw(s.userLoginGoogle())).Methods(http.MethodPost).Name("userLoginGoogle")
+	api.HandleFunc("/status", s.status()).Methods(http.MethodGet)
+	api.HandleFunc("/openapi.json", s.openAPI(r)).Methods(http.MethodGet)
+	api.HandleFunc("/docs", s.apiDocs()).Methods(http.MethodGet)
+	api.HandleFunc("/billing/midtrans/notification", s.billingMidtransNotification()).Methods(http.MethodPost)
+	api.HandleFunc("/telegram/webhook", s.telegramWebhook()).Methods(http.MethodPost)
+	api.Handle("/stream", s.authMw(s.rateLimitMw(streamRateLimit, rateLimitKeyUser)(s.userStream()))).
+		Methods(http.MethodGet).Name("userStream")
 
 	userAPI := api.PathPrefix("/user").Subrouter()
-	userAPI.Use(s.authMw)
+	userAPI.Use(s.authMw, s.rateLimitMw(userRateLimit, rateLimitKeyUser), s.apiQuotaMw)
 	userAPI.HandleFunc("/logout", s.userLogout()).Methods(http.MethodPost)
 	userAPI.HandleFunc("/info", s.userInfo()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/logins", s.userLogins()).Methods(http.MethodGet)
+	userAPI.HandleFunc("/search-history", s.userSearchHistory()).Methods(http.MethodGet)
+	userAPI.HandleFunc("/search-history/clear", s.userSearchHistoryClear()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/link", s.userLink()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/token/refresh", s.userTokenRefresh()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/referral/code", s.userReferralCode()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/referral", s.userReferral()).Methods(http.MethodGet)
+	userAPI.HandleFunc("/telegram/link", s.userTelegramLink()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/telegram/unlink", s.userTelegramUnlink()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/notification/preferences", s.userNotificationPreferences()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/locale", s.userLocale()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/entitlement", s.userEntitlement()).Methods(http.MethodGet)
+	userAPI.HandleFunc("/export", s.userExport()).Methods(http.MethodPost)
+	userAPI.HandleFunc("/export/{exportID}", s.userExportGet()).Methods(http.MethodGet)
+	userAPI.HandleFunc("/export/{exportID}/download", s.userExportDownload()).Methods(http.MethodGet)
 	userAPI.PathPrefix("").Handler(s.notFoundHandler())
 
 	itemAPI := api.PathPrefix("/item").Subrouter()
-	itemAPI.Use(s.authMw)
+	itemAPI.Use(s.authMw, s.rateLimitMw(userRateLimit, rateLimitKeyUser), s.apiQuotaMw)
 	itemAPI.HandleFunc("/add", s.itemAdd()).Methods(http.MethodPost)
 	itemAPI.HandleFunc("/update", s.itemUpdate()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/update-batch", s.itemUpdateBatch()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/fetch-interval", s.itemFetchInterval()).Methods(http.MethodPost)
 	itemAPI.HandleFunc("/remove", s.itemRemove()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/import", s.itemImport()).Methods(http.MethodPost).Name(routeItemImport)
 	itemAPI.HandleFunc("/check", s.itemCheck()).Methods(http.MethodPost)
-	itemAPI.HandleFunc("/search", s.itemSearch()).Methods(http.MethodGet)
+	itemAPI.Handle("/search", s.rateLimitMw(searchRateLimit, rateLimitKeyUser)(s.itemSearch())).Methods(http.MethodGet).Name("itemSearch")
+	itemAPI.Handle("/search/recent/{searchID}", s.rateLimitMw(searchRateLimit, rateLimitKeyUser)(s.itemSearchRecent())).
+		Methods(http.MethodGet).Name("itemSearchRecent")
+	itemAPI.HandleFunc("/search-local", s.itemSearchLocal()).Methods(http.MethodGet)
+	itemAPI.Handle("/search-by-image", s.rateLimitMw(searchRateLimit, rateLimitKeyUser)(s.itemSearchByImage())).
+		Methods(http.MethodPost).Name(routeItemSearchByImage)
 	itemAPI.HandleFunc("/get/{itemID}", s.itemGetOne()).Methods(http.MethodGet)
+	itemAPI.Handle("/refresh/{itemID}", s.rateLimitMw(refreshRateLimit, rateLimitKeyUser)(s.itemRefresh())).
+		Methods(http.MethodPost).Name("itemRefresh")
 	itemAPI.HandleFunc("/get", s.itemGetAll()).Methods(http.MethodGet)
-	itemAPI.HandleFunc("/history/{itemID}", s.itemHistory()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/history/{itemID}", s.itemHistory()).Methods(http.MethodGet, http.MethodPost)
+	itemAPI.HandleFunc("/history/{itemID}/aggregate", s.itemHistoryAggregate()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/history/{itemID}/export", s.itemHistoryExport()).Methods(http.MethodGet)
+	itemAPI.HandleFunc("/stats/{itemID}", s.itemStats()).Methods(http.MethodGet)
+	itemAPI.HandleFunc("/{itemID:[0-9a-fA-F]{24}}/stats", s.itemStats()).Methods(http.MethodGet)
+	itemAPI.HandleFunc("/{itemID:[0-9a-fA-F]{24}}/alternatives", s.itemAlternatives()).Methods(http.MethodGet)
+	itemAPI.HandleFunc("/{itemID:[0-9a-fA-F]{24}}/forecast", s.itemForecast()).Methods(http.MethodGet)
+	itemAPI.HandleFunc("/webhook/add", s.itemWebhookAdd()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/webhook/remove", s.itemWebhookRemove()).Methods(http.MethodPost)
+	itemAPI.HandleFunc("/webhook/{itemID}", s.itemWebhookGet()).Methods(http.MethodGet)
 	itemAPI.PathPrefix("").Handler(s.notFoundHandler())
 
+	discoverAPI := api.PathPrefix("/discover").Subrouter()
+	discoverAPI.Use(s.authMw, s.rateLimitMw(userRateLimit, rateLimitKeyUser), s.apiQuotaMw)
+	discoverAPI.HandleFunc("/trending", s.discoverTrending()).Methods(http.MethodGet)
+	discoverAPI.PathPrefix("").Handler(s.notFoundHandler())
+
+	merchantAPI := api.PathPrefix("/merchant").Subrouter()
+	merchantAPI.Use(s.authMw, s.rateLimitMw(userRateLimit, rateLimitKeyUser), s.apiQuotaMw)
+	merchantAPI.HandleFunc("/{site}/{merchantID}", s.merchantGet()).Methods(http.MethodGet)
+	merchantAPI.PathPrefix("").Handler(s.notFoundHandler())
+
+	barcodeAPI := api.PathPrefix("/barcode").Subrouter()
+	barcodeAPI.Use(s.authMw, s.rateLimitMw(userRateLimit, rateLimitKeyUser), s.apiQuotaMw)
+	barcodeAPI.HandleFunc("/submit", s.barcodeSubmit()).Methods(http.MethodPost)
+	barcodeAPI.PathPrefix("").Handler(s.notFoundHandler())
+
+	moderationAPI := api.PathPrefix("/moderation").Subrouter()
+	moderationAPI.Use(s.authMw, s.roleMw(model.RoleModerator))
+	moderationAPI.HandleFunc("/barcode/submissions", s.moderationBarcodeSubmissions()).Methods(http.MethodGet)
+	moderationAPI.HandleFunc("/barcode/submissions/{submissionID}/review", s.moderationBarcodeSubmissionReview()).Methods(http.MethodPost)
+	moderationAPI.PathPrefix("").Handler(s.notFoundHandler())
+
+	adminAPI := api.PathPrefix("/admin").Subrouter()
+	adminAPI.Use(s.adminMw)
+	adminAPI.HandleFunc("/user/{userID}/entitlement", s.adminEntitlementGrant()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/user/{userID}/roles", s.adminUserRoles()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/user/{userID}/quota", s.adminUserQuota()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/user/{userID}/billing", s.adminBillingEvents()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/barcode", s.adminBarcodeCreate()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/barcode/import", s.adminBarcodeImport()).Methods(http.MethodPost).Name(routeAdminBarcodeImport)
+	adminAPI.HandleFunc("/barcode/{barcode}", s.adminBarcodeGet()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/barcode/{barcode}", s.adminBarcodeUpdate()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/barcode/{barcode}", s.adminBarcodeDelete()).Methods(http.MethodDelete)
+	adminAPI.HandleFunc("/item/{itemID}/archive", s.adminItemArchive()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/item/{itemID}/unarchive", s.adminItemUnarchive()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/fetch/cycles", s.adminFetchCycles()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/fetch/cycles/{fetchCycleID}", s.adminFetchCycle()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/fetch/refetch", s.adminRefetch()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/fetcher/status", s.adminFetcherStatus()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/db/indexes", s.adminDBIndexes()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/db/indexes/ensure", s.adminDBIndexesEnsure()).Methods(http.MethodPost)
+	adminAPI.HandleFunc("/fingerprints", s.adminSiteFingerprints()).Methods(http.MethodGet)
+	adminAPI.HandleFunc("/fingerprints/reload", s.adminSiteFingerprintsReload()).Methods(http.MethodPost)
+	adminAPI.PathPrefix("").Handler(s.notFoundHandler())
+
 	r.PathPrefix("").Handler(s.notFoundHandler())
 
 	return r
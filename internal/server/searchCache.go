@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"time"
+)
+
+const (
+	// searchCacheFreshFor is how long cached search results are returned without revalidation.
+	searchCacheFreshFor = 10 * time.Minute
+	// searchCacheTTL is how long stale search results are kept to be returned while revalidating.
+	searchCacheTTL = 24 * time.Hour
+	// searchRevalidateLockTTL prevents concurrent revalidations of the same search.
+	searchRevalidateLockTTL = 30 * time.Second
+)
+
+type cachedSearch struct {
+	Items    []model.Item       `json:"items"`
+	CachedAt primitive.DateTime `json:"cached_at"`
+}
+
+func (cs cachedSearch) stale() bool {
+	return time.Since(cs.CachedAt.Time()) > searchCacheFreshFor
+}
+
+func searchCacheKey(qa [2]string) string {
+	h := sha256.Sum256([]byte(qa[0] + "\x00" + qa[1]))
+	return "search:" + hex.EncodeToString(h[:])
+}
+
+func (s Server) searchCacheGet(ctx context.Context, qa [2]string) (cachedSearch, bool) {
+	var cs cachedSearch
+	b, err := s.Cache.Get(ctx, searchCacheKey(qa))
+	if err != nil {
+		if err != client.ErrCacheMiss {
+			s.Logger.Errorf("searchCacheGet: Error getting cached search, err: %v", err)
+		}
+		return cs, false
+	}
+	if err = json.Unmarshal(b, &cs); err != nil {
+		s.Logger.Errorf("searchCacheGet: Error unmarshalling cached search, err: %v", err)
+		return cs, false
+	}
+	return cs, true
+}
+
+func (s Server) searchCacheSet(ctx context.Context, qa [2]string, items []model.Item) {
+	if len(items) == 0 {
+		return
+	}
+	b, err := json.Marshal(cachedSearch{Items: items, CachedAt: primitive.NewDateTimeFromTime(time.Now())})
+	if err != nil {
+		s.Logger.Errorf("searchCacheSet: Error marshalling cached search, err: %v", err)
+		return
+	}
+	if err = s.Cache.Set(ctx, searchCacheKey(qa), b, searchCacheTTL); err != nil {
+		s.Logger.Errorf("searchCacheSet: Error setting cached search, err: %v", err)
+	}
+}
+
+// searchCacheRevalidate refreshes the cached results of a search in the background,
+// only one revalidation per search runs at a time.
+func (s Server) searchCacheRevalidate(qa [2]string, tid string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Logger.Errorf("searchCacheRevalidate: Recovered from panic, err: %v, TraceID: %s", r, tid)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	lockKey := searchCacheKey(qa) + ":revalidate"
+	locked, err := s.Cache.SetNX(ctx, lockKey, []byte{1}, searchRevalidateLockTTL)
+	if err != nil {
+		s.Logger.Errorf("searchCacheRevalidate: Error acquiring revalidate lock, err: %v, TraceID: %s", err, tid)
+		return
+	}
+	if !locked {
+		s.Logger.Debugf("searchCacheRevalidate: Revalidation already in progress, TraceID: %s", tid)
+		return
+	}
+	defer s.Cache.Del(ctx, lockKey)
+
+	items, timedOut := s.searchItems(ctx, qa, tid)
+	if len(timedOut) > 0 {
+		s.Logger.Infof("searchCacheRevalidate: Search timed out on: %v, keeping cached results, TraceID: %s", timedOut, tid)
+		return
+	}
+	s.searchCacheSet(ctx, qa, items)
+	s.Logger.Debugf("searchCacheRevalidate: Revalidated search with %d item(s), TraceID: %s", len(items), tid)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/model"
+)
+
+// searchHistoryLimit is how many recent searches are listed.
+const searchHistoryLimit = 20
+
+// recordSearch records the search of the User of r with the queries of qa, and barcode when they are its queries,
+// as their most recent search.
+func (s Server) recordSearch(r *http.Request, qa [2]string, barcode string) {
+	uc, err := getUserContext(r.Context())
+	if err != nil {
+		s.Logger.Errorf("recordSearch: Error getting userContext, err: %v", err)
+		return
+	}
+	sh := model.SearchHistory{UserID: uc.user.ID, Query: qa[0], Query2: qa[1], Barcode: barcode}
+	if err = s.DB.SearchHistoryUpsert(r.Context(), sh); err != nil {
+		s.Logger.Errorf("recordSearch: Error upserting SearchHistory, err: %v", err)
+	}
+}
+
+// userSearchHistory lists the recent searches of the User, the most recent first.
+func (s Server) userSearchHistory() http.HandlerFunc {
+	type response []model.SearchHistory
+	openAPIRegister("userSearchHistory", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userSearchHistory: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		shs, err := s.DB.SearchHistoriesFindByUser(r.Context(), uc.user.ID, searchHistoryLimit)
+		if err != nil {
+			s.Logger.Errorf("userSearchHistory: Error finding SearchHistories for User with ID: %s, err: %v",
+				uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if shs == nil {
+			shs = []model.SearchHistory{}
+		}
+		s.writeJsonResponse(w, response(shs), http.StatusOK)
+	}
+}
+
+func (s Server) userSearchHistoryClear() http.HandlerFunc {
+	type response struct {
+		Deleted int `json:"deleted"`
+	}
+	openAPIRegister("userSearchHistoryClear", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userSearchHistoryClear: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		deleted, err := s.DB.SearchHistoriesDeleteByUser(r.Context(), uc.user.ID)
+		if err != nil {
+			s.Logger.Errorf("userSearchHistoryClear: Error deleting SearchHistories for User with ID: %s, err: %v",
+				uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.Logger.Debugf("userSearchHistoryClear: Deleted %d SearchHistories for User with ID: %s", deleted, uc.user.ID.Hex())
+		s.writeJsonResponse(w, response{Deleted: deleted}, http.StatusOK)
+	}
+}
+
+// itemSearchRecent runs a recent search of the User again, responding like itemSearch.
+func (s Server) itemSearchRecent() http.HandlerFunc {
+	openAPIRegister("itemSearchRecent", nil, nil)
+	search := s.itemSearch()
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemSearchRecent: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		searchID := mux.Vars(r)["searchID"]
+		sh, err := s.DB.SearchHistoryFindOne(r.Context(), uc.user.ID, searchID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("itemSearchRecent: No SearchHistory with ID: %s for User with ID: %s, err: %v",
+					searchID, uc.user.ID.Hex(), err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("itemSearchRecent: Error finding SearchHistory with ID: %s, err: %v", searchID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query()
+		q.Del("query")
+		q.Del("bc")
+		if sh.Barcode != "" {
+			q.Set("bc", sh.Barcode)
+		} else {
+			q.Set("query", sh.Query)
+		}
+		r.URL.RawQuery = q.Encode()
+		search(w, r)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"math"
+	"pricetracker/internal/model"
+	"sort"
+)
+
+// Weights of the search result score components, each component is between 0 and 1.
+const (
+	searchScoreWeightSimilarity = 0.6
+	searchScoreWeightRating     = 0.25
+	searchScoreWeightSold       = 0.15
+)
+
+// searchScore scores how well i matches query, favoring well rated and popular items among similar ones.
+func searchScore(query string, i model.Item) float64 {
+	rating := math.Min(i.Rating/5, 1)
+	// 10000 sold or more counts as fully popular.
+	sold := math.Min(math.Log10(float64(i.Sold)+1)/4, 1)
+	return searchScoreWeightSimilarity*nameSimilarity(query, i.Name) +
+		searchScoreWeightRating*rating +
+		searchScoreWeightSold*sold
+}
+
+// rankSearchItems orders the results of every site by score and interleaves the sites. Results priced in another
+// currency than IDR, e.g. those of eBay, are ranked separately after them, as their prices cannot be compared.
+func rankSearchItems(query string, sitesItems [][]model.Item) []model.Item {
+	idrSitesItems := make([][]model.Item, len(sitesItems))
+	otherSitesItems := make([][]model.Item, len(sitesItems))
+	for idx, is := range sitesItems {
+		for _, i := range is {
+			if model.CurrencyOrDefault(i.Currency) == model.CurrencyIDR {
+				idrSitesItems[idx] = append(idrSitesItems[idx], i)
+			} else {
+				otherSitesItems[idx] = append(otherSitesItems[idx], i)
+			}
+		}
+	}
+	return append(interleaveSearchItems(query, idrSitesItems), interleaveSearchItems(query, otherSitesItems)...)
+}
+
+// interleaveSearchItems orders the results of every site by score and interleaves the sites,
+// each round takes the next best result of every site and orders the round by score.
+func interleaveSearchItems(query string, sitesItems [][]model.Item) []model.Item {
+	type scoredItem struct {
+		item  model.Item
+		score float64
+	}
+	var total, rounds int
+	sitesScored := make([][]scoredItem, len(sitesItems))
+	for idx, is := range sitesItems {
+		scored := make([]scoredItem, 0, len(is))
+		for _, i := range is {
+			scored = append(scored, scoredItem{item: i, score: searchScore(query, i)})
+		}
+		sort.SliceStable(scored, func(a, b int) bool {
+			return scored[a].score > scored[b].score
+		})
+		sitesScored[idx] = scored
+		total += len(scored)
+		if len(scored) > rounds {
+			rounds = len(scored)
+		}
+	}
+
+	items := make([]model.Item, 0, total)
+	for r := 0; r < rounds; r++ {
+		var round []scoredItem
+		for _, scored := range sitesScored {
+			if r < len(scored) {
+				round = append(round, scored[r])
+			}
+		}
+		sort.SliceStable(round, func(a, b int) bool {
+			return round[a].score > round[b].score
+		})
+		for _, si := range round {
+			items = append(items, si.item)
+		}
+	}
+	return items
+}
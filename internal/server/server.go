@@ -1,16 +1,70 @@
 package server
 
 import (
+	"context"
+	"github.com/go-redis/redis/v8"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"pricetracker/internal/client"
-	"pricetracker/internal/database"
+	"pricetracker/internal/service"
+	"pricetracker/internal/tracing"
+	"time"
 )
 
 type Server struct {
-	DB            database.Database
-	Client        client.Client
+	DB Database
+	// Redis is nil when Redis is disabled, Cache is then a client.NoopCache.
+	Redis         *redis.Client
+	Cache         client.Cache
+	Client        Client
 	Logger        logger
 	AuthSecretKey jwk.Key
+	EmailPolicy   *EmailPolicy
+	AdminAPIKey   string
+	StartedAt     time.Time
+	// Context is cancelled when the application shuts down, work outliving the request starting it (like admin
+	// refetches) is run with it, context.Background is used when it is nil.
+	Context context.Context
+	// Tracer is nil when traces are not exported, requests then still get trace IDs.
+	Tracer *tracing.Tracer
+	// TrustTraceparent continues the traces of the traceparent headers of requests instead of starting new ones.
+	TrustTraceparent bool
+
+	Scrapers      *service.Scrapers
+	Items         service.ItemService
+	Users         service.UserService
+	Notifications service.NotificationService
+
+	ReferralRewardTrackedItems int
+	PremiumDurationDays        int
+	// PremiumPrice is the price of a premium purchase in IDR.
+	PremiumPrice int
+	// TelegramBotUsername is the username of the Telegram bot, empty when Telegram link tokens have no deep link.
+	TelegramBotUsername string
+	// TrackedItemsLimits overrides the tracked items limit of the tiers in it.
+	TrackedItemsLimits map[string]int
+
+	// NotificationCooldown is the minimum time between price drop notifications of the same TrackedItem,
+	// NotificationMaxPerThresholdHit caps them while the price stays below the threshold, zero meaning no cap.
+	NotificationCooldown           time.Duration
+	NotificationMaxPerThresholdHit int
+	// NotificationBatcher is nil when price drop notifications are not batched.
+	NotificationBatcher *NotificationBatcher
+	// PriceStream is nil when price updates are not streamed to Users.
+	PriceStream *PriceStream
+
+	// FetcherWorkersPerSite is how many Items of the same site are fetched concurrently,
+	// FetcherQueueSize bounds the Items queued per site.
+	FetcherWorkersPerSite int
+	FetcherQueueSize      int
+	// FetcherStatus is nil when the fetcher does not run in this process, it is then read from Redis.
+	FetcherStatus *FetcherStatus
+	// SearchResultLimits is how many search results of each site in it are returned, by client site name.
+	SearchResultLimits map[string]int
+	// OrphanedItemGracePeriod is how long Items nobody tracks keep being fetched before they are archived.
+	OrphanedItemGracePeriod time.Duration
+
+	// localRateLimits are set by Router for the rate limits, API quota and refresh cooldowns when Redis is unavailable.
+	localRateLimits *localRateLimits
 }
 
 type logger interface {
@@ -21,3 +75,11 @@ type logger interface {
 	Infof(format string, v ...any)
 	Errorf(format string, v ...any)
 }
+
+// appContext returns Context, or context.Background when it is nil.
+func (s Server) appContext() context.Context {
+	if s.Context == nil {
+		return context.Background()
+	}
+	return s.Context
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+const (
+	statusCacheKey = "status"
+	statusCacheTTL = 30 * time.Second
+
+	siteStatusOperational = "operational"
+	siteStatusDegraded    = "degraded"
+	siteStatusDown        = "down"
+	siteStatusUnknown     = "unknown"
+)
+
+type statusResponse struct {
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	// Sites is the scraper status per site based on the last finished full fetch cycle.
+	Sites          map[string]string   `json:"sites"`
+	LastFetchCycle *primitive.DateTime `json:"last_fetch_cycle_finished_at"`
+	// NotificationBacklog is the number of Items that running fetch cycles have yet to process,
+	// notifications are sent as each Item is fetched so these are the notifications still to be checked.
+	NotificationBacklog int                `json:"notification_backlog"`
+	GeneratedAt         primitive.DateTime `json:"generated_at"`
+}
+
+// siteStatus derives a coarse status from how many of the attempted Items of a site failed to be fetched.
+func siteStatus(ss *model.FetchCycleSiteStats) string {
+	if ss == nil || ss.Attempted == 0 {
+		return siteStatusUnknown
+	}
+	failed := float64(ss.Failed+ss.ParseErrors) / float64(ss.Attempted)
+	switch {
+	case failed >= 0.5:
+		return siteStatusDown
+	case failed >= 0.1:
+		return siteStatusDegraded
+	default:
+		return siteStatusOperational
+	}
+}
+
+func (s Server) status() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(statusCacheTTL.Seconds())))
+
+		b, err := s.Cache.Get(r.Context(), statusCacheKey)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			if _, err = w.Write(b); err != nil {
+				s.Logger.Errorf("status: Error writing cached response, err: %v", err)
+			}
+			return
+		}
+		if err != client.ErrCacheMiss {
+			s.Logger.Errorf("status: Error getting cached status, err: %v", err)
+		}
+
+		resp := statusResponse{
+			Sites:       make(map[string]string),
+			GeneratedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+		if !s.StartedAt.IsZero() {
+			resp.UptimeSeconds = int64(time.Since(s.StartedAt).Seconds())
+		}
+
+		fc, err := s.DB.FetchCycleFindLastFinished(r.Context(), model.FetchCycleKindFull)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			s.Logger.Errorf("status: Error finding last finished FetchCycle, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			resp.LastFetchCycle = &fc.FinishedAt
+		}
+		for _, site := range []string{client.SiteShopee, client.SiteTokopedia, client.SiteBlibli} {
+			resp.Sites[site] = siteStatus(fc.Sites[site])
+		}
+
+		fcs, err := s.DB.FetchCyclesFindRunning(r.Context(), time.Now().Add(-24*time.Hour))
+		if err != nil {
+			s.Logger.Errorf("status: Error finding running FetchCycles, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		for _, rfc := range fcs {
+			resp.NotificationBacklog += rfc.Total - rfc.Done
+		}
+
+		if b, err := json.Marshal(resp); err != nil {
+			s.Logger.Errorf("status: Error marshalling status, err: %v", err)
+		} else if err = s.Cache.Set(r.Context(), statusCacheKey, b, statusCacheTTL); err != nil {
+			s.Logger.Errorf("status: Error setting cached status, err: %v", err)
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
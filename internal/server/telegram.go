@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const telegramLinkTokenTTL = 15 * time.Minute
+
+// telegramLinkTokenNew returns a new Telegram link token and the hash of it that is stored.
+func telegramLinkTokenNew() (string, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errors.Wrap(err, "error generating random bytes")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, telegramLinkTokenHash(token), nil
+}
+
+func telegramLinkTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type telegramUpdate struct {
+	Message *struct {
+		Chat struct {
+			ID   int64  `json:"id"`
+			Type string `json:"type"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// telegramWebhook receives the messages sent to the Telegram bot, linking the chat of a /start message to the User
+// whose link token it carries. Telegram retries updates answered with an error, so every authentic update gets a 200.
+func (s Server) telegramWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tid := getTraceContext(r.Context()).traceID
+		if !s.Client.TelegramEnabled() {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(s.Client.TelegramWebhookSecret())) != 1 {
+			s.Logger.Debugf("telegramWebhook: Invalid secret token, TraceID: %s", tid)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		u := telegramUpdate{}
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			s.Logger.Debugf("telegramWebhook: Error decoding JSON, err: %v, TraceID: %s", err, tid)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if u.Message == nil || u.Message.Chat.ID == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		chatID := u.Message.Chat.ID
+		command, token, _ := strings.Cut(strings.TrimSpace(u.Message.Text), " ")
+		if command != "/start" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		reply := "Your Telegram account is now linked, price alerts will be sent to this chat."
+		token = strings.TrimSpace(token)
+		if token == "" {
+			reply = "Open the Telegram link from the app to link this chat."
+		} else if user, err := s.DB.UserTelegramLinkByToken(r.Context(), telegramLinkTokenHash(token), chatID, time.Now()); err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Errorf("telegramWebhook: Error linking ChatID: %d, err: %v, TraceID: %s", chatID, err, tid)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			s.Logger.Debugf("telegramWebhook: Invalid or expired link token from ChatID: %d, TraceID: %s", chatID, tid)
+			reply = "This link is invalid or has expired, open a new Telegram link from the app."
+		} else {
+			s.Logger.Infof("telegramWebhook: Linked ChatID: %d to User with ID: %s, TraceID: %s", chatID, user.ID.Hex(), tid)
+		}
+
+		if err := s.Client.TelegramSendMessage(r.Context(), chatID, reply); err != nil {
+			s.Logger.Errorf("telegramWebhook: Error replying to ChatID: %d, err: %v, TraceID: %s", chatID, err, tid)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
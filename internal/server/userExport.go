@@ -0,0 +1,353 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"pricetracker/internal/model"
+	"strconv"
+	"time"
+)
+
+const (
+	// userExportsPerDay is how many exports a User can request per day.
+	userExportsPerDay = 3
+	// userExportTTL is how long a generated export can be downloaded.
+	userExportTTL = 72 * time.Hour
+	// userExportTimeout bounds the generation of an export.
+	userExportTimeout = 10 * time.Minute
+)
+
+type userExportData struct {
+	Profile      userExportProfile       `json:"profile"`
+	Devices      []userExportDevice      `json:"devices"`
+	TrackedItems []userExportTrackedItem `json:"tracked_items"`
+	ExportedAt   time.Time               `json:"exported_at"`
+}
+
+type userExportProfile struct {
+	Name         string                        `json:"name"`
+	Email        string                        `json:"email"`
+	GoogleLinked bool                          `json:"google_linked"`
+	Roles        []string                      `json:"roles"`
+	Referral     model.Referral                `json:"referral"`
+	Telegram     model.Telegram                `json:"telegram"`
+	Notification model.NotificationPreferences `json:"notification"`
+	Entitlement  model.Entitlement             `json:"entitlement"`
+	CreatedAt    primitive.DateTime            `json:"created_at"`
+}
+
+type userExportDevice struct {
+	DeviceID  string             `json:"device_id"`
+	LastSeen  primitive.DateTime `json:"last_seen"`
+	CreatedAt primitive.DateTime `json:"created_at"`
+}
+
+type userExportTrackedItem struct {
+	ItemID       string              `json:"item_id"`
+	Item         *model.Item         `json:"item"`
+	Tracking     model.TrackedItem   `json:"tracking"`
+	TrackedAt    primitive.DateTime  `json:"tracked_at"`
+	PriceHistory []model.ItemHistory `json:"price_history,omitempty"`
+}
+
+func (s Server) userExport() http.HandlerFunc {
+	type response struct {
+		model.UserExport
+		StatusURL string `json:"status_url"`
+	}
+	openAPIRegister("userExport", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userExport: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = model.UserExportFormatZIP
+		}
+		if format != model.UserExportFormatZIP && format != model.UserExportFormatJSON {
+			s.Logger.Debugf("userExport: Invalid format: %#v", format)
+			http.Error(w, "Invalid format", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		n, err := s.DB.UserExportsCountSince(r.Context(), uc.user.ID, now.Add(-24*time.Hour))
+		if err != nil {
+			s.Logger.Errorf("userExport: Error counting UserExports, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if n >= userExportsPerDay {
+			s.Logger.Debugf("userExport: User with ID: %s reached %d exports per day", uc.user.ID.Hex(), userExportsPerDay)
+			http.Error(w, "Too many exports", http.StatusTooManyRequests)
+			return
+		}
+
+		ue := model.UserExport{
+			UserID:    uc.user.ID,
+			Format:    format,
+			Status:    model.UserExportStatusPending,
+			ExpiresAt: primitive.NewDateTimeFromTime(now.Add(userExportTTL)),
+		}
+		if ue.ID, err = s.DB.UserExportInsert(r.Context(), ue); err != nil {
+			s.Logger.Errorf("userExport: Error inserting UserExport, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		ue.CreatedAt = primitive.NewDateTimeFromTime(now)
+		s.Logger.Infof("userExport: Generating %s export with ID: %s for User with ID: %s", format, ue.ID.Hex(), uc.user.ID.Hex())
+		go s.generateUserExport(uc.user, ue)
+
+		s.writeJsonResponse(w, response{UserExport: ue, StatusURL: "/api/user/export/" + ue.ID.Hex()}, http.StatusAccepted)
+	}
+}
+
+func (s Server) userExportGet() http.HandlerFunc {
+	type response struct {
+		model.UserExport
+		DownloadURL string `json:"download_url,omitempty"`
+	}
+	openAPIRegister("userExportGet", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userExportGet: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		exportID := mux.Vars(r)["exportID"]
+		ue, err := s.DB.UserExportFindOne(r.Context(), uc.user.ID, exportID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("userExportGet: UserExport with ID: %s not found, err: %v", exportID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("userExportGet: Error finding UserExport with ID: %s, err: %v", exportID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp := response{UserExport: ue}
+		if ue.Status == model.UserExportStatusReady {
+			resp.DownloadURL = "/api/user/export/" + ue.ID.Hex() + "/download"
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
+
+func (s Server) userExportDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userExportDownload: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		exportID := mux.Vars(r)["exportID"]
+		ue, err := s.DB.UserExportFindOne(r.Context(), uc.user.ID, exportID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex) {
+				s.Logger.Debugf("userExportDownload: UserExport with ID: %s not found, err: %v", exportID, err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			s.Logger.Errorf("userExportDownload: Error finding UserExport with ID: %s, err: %v", exportID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if ue.Status != model.UserExportStatusReady || time.Now().After(ue.ExpiresAt.Time()) {
+			s.Logger.Debugf("userExportDownload: UserExport with ID: %s is not downloadable, status: %s", exportID, ue.Status)
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+
+		f, err := s.DB.UserExportFileOpen(ue.FileID)
+		if err != nil {
+			s.Logger.Errorf("userExportDownload: Error opening file of UserExport with ID: %s, err: %v", exportID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				s.Logger.Errorf("userExportDownload: Error closing file of UserExport with ID: %s, err: %v", exportID, err)
+			}
+		}()
+
+		contentType := "application/zip"
+		if ue.Format == model.UserExportFormatJSON {
+			contentType = "application/json; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(ue.Size, 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, userExportFilename(ue)))
+		if _, err = io.Copy(w, f); err != nil {
+			s.Logger.Errorf("userExportDownload: Error writing file of UserExport with ID: %s, err: %v", exportID, err)
+		}
+	}
+}
+
+func userExportFilename(ue model.UserExport) string {
+	return fmt.Sprintf("pricetracker-export-%s.%s", ue.CreatedAt.Time().Format("20060102"), ue.Format)
+}
+
+// generateUserExport collects the data of u and stores it as the archive of ue.
+func (s Server) generateUserExport(u model.User, ue model.UserExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), userExportTimeout)
+	defer cancel()
+
+	ue.Status = model.UserExportStatusReady
+	if err := s.writeUserExportFile(ctx, u, &ue); err != nil {
+		s.Logger.Errorf("generateUserExport: Error generating UserExport with ID: %s, err: %v", ue.ID.Hex(), err)
+		ue.Status = model.UserExportStatusFailed
+		ue.Error = err.Error()
+	}
+	if err := s.DB.UserExportFinish(ctx, ue); err != nil {
+		s.Logger.Errorf("generateUserExport: Error finishing UserExport with ID: %s, err: %v", ue.ID.Hex(), err)
+		return
+	}
+	s.Logger.Infof("generateUserExport: Finished UserExport with ID: %s, status: %s, size: %d", ue.ID.Hex(), ue.Status, ue.Size)
+}
+
+func (s Server) writeUserExportFile(ctx context.Context, u model.User, ue *model.UserExport) error {
+	data, err := s.userExportData(ctx, u)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.DB.UserExportFileCreate(userExportFilename(*ue))
+	if err != nil {
+		return err
+	}
+	cw := &countingWriter{w: f}
+	if ue.Format == model.UserExportFormatJSON {
+		err = json.NewEncoder(cw).Encode(data)
+	} else {
+		err = writeUserExportZip(cw, data)
+	}
+	if err != nil {
+		if abortErr := f.Abort(); abortErr != nil {
+			s.Logger.Errorf("writeUserExportFile: Error aborting file of UserExport with ID: %s, err: %v", ue.ID.Hex(), abortErr)
+		}
+		return errors.Wrap(err, "error writing UserExport file")
+	}
+	if err = f.Close(); err != nil {
+		return errors.Wrap(err, "error closing UserExport file")
+	}
+	ue.FileID = f.FileID.(primitive.ObjectID)
+	ue.Size = cw.n
+	return nil
+}
+
+func (s Server) userExportData(ctx context.Context, u model.User) (userExportData, error) {
+	data := userExportData{
+		Profile: userExportProfile{
+			Name:         u.Name,
+			Email:        u.Email,
+			GoogleLinked: u.GoogleID != "",
+			Roles:        u.Roles,
+			Referral:     u.Referral,
+			Telegram:     u.Telegram,
+			Notification: u.Notification,
+			Entitlement:  u.Entitlement,
+			CreatedAt:    u.CreatedAt,
+		},
+		Devices:      make([]userExportDevice, 0, len(u.Devices)),
+		TrackedItems: make([]userExportTrackedItem, 0, len(u.TrackedItems)),
+		ExportedAt:   time.Now(),
+	}
+	for _, d := range u.Devices {
+		data.Devices = append(data.Devices, userExportDevice{DeviceID: d.DeviceID, LastSeen: d.LastSeen, CreatedAt: d.CreatedAt})
+	}
+
+	itemIDs := make([]primitive.ObjectID, 0, len(u.TrackedItems))
+	for _, ti := range u.TrackedItems {
+		itemIDs = append(itemIDs, ti.ItemID)
+	}
+	is, err := s.DB.ItemsFind(ctx, itemIDs)
+	if err != nil {
+		return data, err
+	}
+	items := make(map[primitive.ObjectID]model.Item, len(is))
+	for _, i := range is {
+		items[i.ID] = i
+	}
+	for _, ti := range u.TrackedItems {
+		ih, err := s.DB.ItemHistoryFindRange(ctx, ti.ItemID.Hex(), time.Time{}, data.ExportedAt, 0, 0)
+		if err != nil {
+			return data, err
+		}
+		eti := userExportTrackedItem{ItemID: ti.ItemID.Hex(), Tracking: ti, TrackedAt: ti.CreatedAt, PriceHistory: ih}
+		if i, ok := items[ti.ItemID]; ok {
+			eti.Item = &i
+		}
+		data.TrackedItems = append(data.TrackedItems, eti)
+	}
+	return data, nil
+}
+
+// writeUserExportZip writes data as a ZIP archive with the price history of every tracked Item in its own file.
+func writeUserExportZip(w io.Writer, data userExportData) error {
+	zw := zip.NewWriter(w)
+	writeJSON := func(name string, v any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return errors.Wrapf(err, "error creating %s in ZIP archive", name)
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return errors.Wrapf(enc.Encode(v), "error encoding %s in ZIP archive", name)
+	}
+
+	if err := writeJSON("profile.json", data.Profile); err != nil {
+		return err
+	}
+	if err := writeJSON("devices.json", data.Devices); err != nil {
+		return err
+	}
+	trackedItems := make([]userExportTrackedItem, 0, len(data.TrackedItems))
+	for _, ti := range data.TrackedItems {
+		if err := writeJSON("price_history/"+ti.ItemID+".json", ti.PriceHistory); err != nil {
+			return err
+		}
+		ti.PriceHistory = nil
+		trackedItems = append(trackedItems, ti)
+	}
+	if err := writeJSON("tracked_items.json", trackedItems); err != nil {
+		return err
+	}
+	return errors.Wrap(zw.Close(), "error closing ZIP archive")
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func (s Server) DeleteExpiredUserExportsInInterval(ctx context.Context, ticker *time.Ticker) {
+	for range ticker.C {
+		deleted, err := s.DB.UserExportsExpiredDelete(ctx, time.Now())
+		if err != nil {
+			s.Logger.Errorf("DeleteExpiredUserExportsInInterval: Error deleting expired UserExports, err: %v", err)
+		} else if deleted > 0 {
+			s.Logger.Infof("DeleteExpiredUserExportsInInterval: Deleted %d expired UserExport(s)", deleted)
+		}
+	}
+}
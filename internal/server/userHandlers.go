@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/pkg/errors"
@@ -13,22 +15,29 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"net/mail"
+	"pricetracker/internal/client"
+	"pricetracker/internal/database"
+	"pricetracker/internal/i18n"
+	"pricetracker/internal/misc"
 	"pricetracker/internal/model"
+	"strconv"
 	"time"
 )
 
 func (s Server) userRegister() http.HandlerFunc {
 	type request struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
-		DeviceID string `json:"device_id"`
-		FCMToken string `json:"fcm_token"`
+		Name         string `json:"name"`
+		Email        string `json:"email"`
+		Password     string `json:"password"`
+		DeviceID     string `json:"device_id"`
+		FCMToken     string `json:"fcm_token"`
+		ReferralCode string `json:"referral_code"`
 	}
 	type response struct {
 		Success    bool   `json:"success"`
 		LoginToken string `json:"login_token"`
 	}
+	openAPIRegister("userRegister", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := request{}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -42,6 +51,28 @@ func (s Server) userRegister() http.HandlerFunc {
 			http.Error(w, "Invalid email", http.StatusBadRequest)
 			return
 		}
+		if s.EmailPolicy != nil {
+			if err = s.EmailPolicy.Check(req.Email); err != nil {
+				s.Logger.Infof("userRegister: Email rejected by EmailPolicy, err: %v", err)
+				http.Error(w, "Email domain not allowed", http.StatusBadRequest)
+				return
+			}
+		}
+		var referrer *model.User
+		if req.ReferralCode != "" {
+			ru, err := s.findReferrer(r.Context(), req.ReferralCode)
+			if err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					s.Logger.Debugf("userRegister: Invalid referral code: %#v, err: %v", req.ReferralCode, err)
+					http.Error(w, "Invalid referral_code", http.StatusBadRequest)
+					return
+				}
+				s.Logger.Errorf("userRegister: Error finding referrer, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			referrer = &ru
+		}
 		password, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			s.Logger.Errorf("userRegister: Error generating bcrypt from password, err: %v", err)
@@ -60,6 +91,9 @@ func (s Server) userRegister() http.HandlerFunc {
 			Password: password,
 			Devices:  []model.Device{d},
 		}
+		if referrer != nil {
+			u.Referral.ReferredBy = referrer.ID
+		}
 
 		id, err := s.DB.UserInsert(r.Context(), u)
 		if err != nil {
@@ -95,6 +129,7 @@ func (s Server) userRegister() http.HandlerFunc {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+		s.recordLoginEvent(r, id, req.DeviceID, model.LoginEventTypeRegister)
 		s.writeJsonResponse(w, response{
 			Success:    true,
 			LoginToken: lt,
@@ -112,6 +147,7 @@ func (s Server) userLogin() http.HandlerFunc {
 	type response struct {
 		LoginToken string `json:"login_token"`
 	}
+	openAPIRegister("userLogin", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := request{}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -133,58 +169,294 @@ func (s Server) userLogin() http.HandlerFunc {
 			return
 		}
 
-		lt, exp, tokenHash, err := s.createLoginTokenAndHash(u.ID.Hex(), req.DeviceID)
+		lt, err := s.loginDevice(r.Context(), u, req.DeviceID, req.FCMToken)
 		if err != nil {
-			s.Logger.Errorf("userLogin: Error creating login token for User, err: %v", err)
+			if errors.Is(err, errFCMTokenDuplicate) {
+				s.Logger.Debugf("userLogin: Error logging in Device, err: %v", err)
+				http.Error(w, "Invalid fcm_token", http.StatusBadRequest)
+				return
+			}
+			s.Logger.Errorf("userLogin: Error logging in Device, err: %v", err)
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
-		var device *model.Device
-		for _, d := range u.Devices {
-			if d.DeviceID == req.DeviceID {
-				device = &d
-				break
+		s.recordLoginEvent(r, u.ID.Hex(), req.DeviceID, model.LoginEventTypeLogin)
+		s.writeJsonResponse(w, response{LoginToken: lt}, http.StatusOK)
+	}
+}
+
+func (s Server) userLoginGoogle() http.HandlerFunc {
+	type request struct {
+		IDToken  string `json:"id_token"`
+		DeviceID string `json:"device_id"`
+		FCMToken string `json:"fcm_token"`
+	}
+	type response struct {
+		LoginToken string `json:"login_token"`
+	}
+	openAPIRegister("userLoginGoogle", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.Client.GoogleEnabled() {
+			s.Logger.Debugf("userLoginGoogle: Google sign-in is not configured")
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		req := request{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("userLoginGoogle: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		claims, err := s.Client.GoogleVerifyIDToken(r.Context(), req.IDToken)
+		if err != nil {
+			if errors.Is(err, client.ErrGoogleIDTokenInvalid) {
+				s.Logger.Debugf("userLoginGoogle: Invalid Google ID token, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
 			}
+			s.Logger.Errorf("userLoginGoogle: Error verifying Google ID token, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
 		}
-		if device == nil {
-			if err = s.DB.UserDeviceAdd(r.Context(), u.ID.Hex(), model.Device{
-				DeviceID: req.DeviceID,
-				LoginToken: model.LoginToken{
-					Token:      tokenHash,
-					Expiration: primitive.NewDateTimeFromTime(exp),
-					CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
-				},
-				FCMToken: req.FCMToken,
-			}); err != nil {
+
+		eventType := model.LoginEventTypeGoogleLogin
+		u, err := s.DB.UserFindByGoogleID(r.Context(), claims.Subject)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Errorf("userLoginGoogle: Error finding User by GoogleID, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if claims.Email == "" || !claims.EmailVerified {
+				s.Logger.Debugf("userLoginGoogle: Google account has no verified email, GoogleID: %s", claims.Subject)
+				http.Error(w, "Google account email is not verified", http.StatusUnauthorized)
+				return
+			}
+			if s.EmailPolicy != nil {
+				if err = s.EmailPolicy.Check(claims.Email); err != nil {
+					s.Logger.Infof("userLoginGoogle: Email rejected by EmailPolicy, err: %v", err)
+					http.Error(w, "Email domain not allowed", http.StatusBadRequest)
+					return
+				}
+			}
+
+			u = model.User{
+				Name:     claims.Name,
+				Email:    claims.Email,
+				GoogleID: claims.Subject,
+				Devices:  []model.Device{},
+			}
+			id, err := s.DB.UserInsert(r.Context(), u)
+			if err != nil {
 				if mongo.IsDuplicateKeyError(err) {
-					s.Logger.Debugf("userLogin: Error duplicate key when adding Device to User, err: %v", err)
-					http.Error(w, "Invalid fcm_token", http.StatusBadRequest)
+					s.Logger.Debugf("userLoginGoogle: Error duplicate key when inserting User, err: %v", err)
+					http.Error(w, "An account with this email already exists, log in and link the Google account instead",
+						http.StatusConflict)
 					return
 				}
-				s.Logger.Errorf("userLogin: Error adding Device to User, err: %v", err)
+				s.Logger.Errorf("userLoginGoogle: Error inserting User, err: %v", err)
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
-		} else {
-			device.LoginToken = model.LoginToken{
-				Token:      tokenHash,
-				Expiration: primitive.NewDateTimeFromTime(exp),
-				CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+			if u.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+				s.Logger.Errorf("userLoginGoogle: Error creating ObjectID from hex: %s, err: %v", id, err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
 			}
-			device.FCMToken = req.FCMToken
-			device.LastSeen = primitive.NewDateTimeFromTime(time.Now())
-			if err = s.DB.UserDeviceUpdate(r.Context(), u.ID.Hex(), *device); err != nil {
+			eventType = model.LoginEventTypeGoogleRegister
+		}
+
+		lt, err := s.loginDevice(r.Context(), u, req.DeviceID, req.FCMToken)
+		if err != nil {
+			if errors.Is(err, errFCMTokenDuplicate) {
+				s.Logger.Debugf("userLoginGoogle: Error logging in Device, err: %v", err)
+				http.Error(w, "Invalid fcm_token", http.StatusBadRequest)
+				return
+			}
+			s.Logger.Errorf("userLoginGoogle: Error logging in Device, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.recordLoginEvent(r, u.ID.Hex(), req.DeviceID, eventType)
+		s.writeJsonResponse(w, response{LoginToken: lt}, http.StatusOK)
+	}
+}
+
+func (s Server) userLink() http.HandlerFunc {
+	type request struct {
+		Provider string `json:"provider"`
+		IDToken  string `json:"id_token"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	type response struct {
+		Success bool `json:"success"`
+	}
+	openAPIRegister("userLink", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userLink: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("userLink: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		switch req.Provider {
+		case "google":
+			if !s.Client.GoogleEnabled() {
+				s.Logger.Debugf("userLink: Google sign-in is not configured")
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			claims, err := s.Client.GoogleVerifyIDToken(r.Context(), req.IDToken)
+			if err != nil {
+				if errors.Is(err, client.ErrGoogleIDTokenInvalid) {
+					s.Logger.Debugf("userLink: Invalid Google ID token, err: %v", err)
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				s.Logger.Errorf("userLink: Error verifying Google ID token, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if uc.user.GoogleID == claims.Subject {
+				s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
+				return
+			}
+			if uc.user.GoogleID != "" {
+				s.Logger.Debugf("userLink: User with ID: %s already linked to another Google account", uc.user.ID.Hex())
+				http.Error(w, "Account is already linked to another Google account", http.StatusConflict)
+				return
+			}
+
+			other, err := s.DB.UserFindByGoogleID(r.Context(), claims.Subject)
+			if err == nil {
+				dropped, err := s.DB.UserMerge(r.Context(), uc.user, other, s.trackedItemsLimit(uc.user))
+				if err != nil {
+					if errors.Is(err, database.ErrNoTransaction) {
+						s.Logger.Errorf("userLink: Merging User with ID: %s into User with ID: %s needs database transactions, err: %v",
+							other.ID.Hex(), uc.user.ID.Hex(), err)
+						http.Error(w, "Merging accounts is not supported", http.StatusConflict)
+						return
+					}
+					if errors.Is(err, database.ErrTrackedItemsLimit) {
+						s.Logger.Debugf("userLink: Merging User with ID: %s into User with ID: %s exceeds tracked items limit, err: %v",
+							other.ID.Hex(), uc.user.ID.Hex(), err)
+						http.Error(w, "Merged account would exceed the tracked items limit", http.StatusUnprocessableEntity)
+						return
+					}
+					s.Logger.Errorf("userLink: Error merging User with ID: %s into User with ID: %s, err: %v",
+						other.ID.Hex(), uc.user.ID.Hex(), err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				s.Logger.Infof("userLink: Merged User with ID: %s into User with ID: %s", other.ID.Hex(), uc.user.ID.Hex())
+				if dropped > 0 {
+					s.Logger.Infof("userLink: Dropped %d least recently seen Devices over the limit of %d when merging into User with ID: %s",
+						dropped, database.UserDevicesLimit, uc.user.ID.Hex())
+				}
+			} else if !errors.Is(err, mongo.ErrNoDocuments) {
+				s.Logger.Errorf("userLink: Error finding User by GoogleID, err: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if err = s.DB.UserGoogleIDSet(r.Context(), uc.user.ID.Hex(), claims.Subject); err != nil {
+				s.Logger.Errorf("userLink: Error setting GoogleID on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		case "password":
+			if len(uc.user.Password) > 0 {
+				s.Logger.Debugf("userLink: User with ID: %s already has a password", uc.user.ID.Hex())
+				http.Error(w, "Account already has a password", http.StatusConflict)
+				return
+			}
+			if _, err = mail.ParseAddress(req.Email); err != nil {
+				s.Logger.Debugf("userLink: Invalid email, err: %v", err)
+				http.Error(w, "Invalid email", http.StatusBadRequest)
+				return
+			}
+
+			var password []byte
+			if req.Email != uc.user.Email {
+				other, err := s.DB.UserFindByEmail(r.Context(), req.Email)
+				if err == nil {
+					if err = bcrypt.CompareHashAndPassword(other.Password, []byte(req.Password)); err != nil {
+						s.Logger.Debugf("userLink: Error comparing hash and password for User with email: %s, err: %v", other.Email, err)
+						http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+						return
+					}
+					dropped, err := s.DB.UserMerge(r.Context(), uc.user, other, s.trackedItemsLimit(uc.user))
+					if err != nil {
+						if errors.Is(err, database.ErrNoTransaction) {
+							s.Logger.Errorf("userLink: Merging User with ID: %s into User with ID: %s needs database transactions, err: %v",
+								other.ID.Hex(), uc.user.ID.Hex(), err)
+							http.Error(w, "Merging accounts is not supported", http.StatusConflict)
+							return
+						}
+						if errors.Is(err, database.ErrTrackedItemsLimit) {
+							s.Logger.Debugf("userLink: Merging User with ID: %s into User with ID: %s exceeds tracked items limit, err: %v",
+								other.ID.Hex(), uc.user.ID.Hex(), err)
+							http.Error(w, "Merged account would exceed the tracked items limit", http.StatusUnprocessableEntity)
+							return
+						}
+						s.Logger.Errorf("userLink: Error merging User with ID: %s into User with ID: %s, err: %v",
+							other.ID.Hex(), uc.user.ID.Hex(), err)
+						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+						return
+					}
+					s.Logger.Infof("userLink: Merged User with ID: %s into User with ID: %s", other.ID.Hex(), uc.user.ID.Hex())
+					if dropped > 0 {
+						s.Logger.Infof("userLink: Dropped %d least recently seen Devices over the limit of %d when merging into User with ID: %s",
+							dropped, database.UserDevicesLimit, uc.user.ID.Hex())
+					}
+					password = other.Password
+				} else if !errors.Is(err, mongo.ErrNoDocuments) {
+					s.Logger.Errorf("userLink: Error finding User by email, err: %v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				} else if s.EmailPolicy != nil {
+					if err = s.EmailPolicy.Check(req.Email); err != nil {
+						s.Logger.Infof("userLink: Email rejected by EmailPolicy, err: %v", err)
+						http.Error(w, "Email domain not allowed", http.StatusBadRequest)
+						return
+					}
+				}
+			}
+			if password == nil {
+				if password, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost); err != nil {
+					s.Logger.Errorf("userLink: Error generating bcrypt from password, err: %v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if err = s.DB.UserCredentialsSet(r.Context(), uc.user.ID.Hex(), req.Email, password); err != nil {
 				if mongo.IsDuplicateKeyError(err) {
-					s.Logger.Debugf("userLogin: Error duplicate key when updating Device on User, err: %v", err)
-					http.Error(w, "Invalid fcm_token", http.StatusBadRequest)
+					s.Logger.Debugf("userLink: Error duplicate key when setting credentials on User, err: %v", err)
+					http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
 					return
 				}
-				s.Logger.Errorf("userLogin: Error updating Device on User, err: %v", err)
+				s.Logger.Errorf("userLink: Error setting credentials on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
+		default:
+			s.Logger.Debugf("userLink: Invalid provider: %#v", req.Provider)
+			http.Error(w, "Invalid provider", http.StatusBadRequest)
+			return
 		}
-		s.writeJsonResponse(w, response{LoginToken: lt}, http.StatusOK)
+		s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
 	}
 }
 
@@ -192,6 +464,7 @@ func (s Server) userLogout() http.HandlerFunc {
 	type response struct {
 		Success bool `json:"success"`
 	}
+	openAPIRegister("userLogout", nil, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -209,14 +482,54 @@ func (s Server) userLogout() http.HandlerFunc {
 	}
 }
 
+func (s Server) userTokenRefresh() http.HandlerFunc {
+	type response struct {
+		LoginToken string    `json:"login_token"`
+		Expiration time.Time `json:"expiration"`
+	}
+	openAPIRegister("userTokenRefresh", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userTokenRefresh: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		lt, exp, tokenHash, err := s.createLoginTokenAndHash(uc.user.ID.Hex(), uc.deviceID)
+		if err != nil {
+			s.Logger.Errorf("userTokenRefresh: Error creating login token for User, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		loginToken := model.LoginToken{
+			Token:      tokenHash,
+			Expiration: primitive.NewDateTimeFromTime(exp),
+			CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+		}
+		if err = s.DB.UserDeviceLoginTokenUpdate(r.Context(), uc.user.ID.Hex(), uc.deviceID, loginToken); err != nil {
+			s.Logger.Errorf("userTokenRefresh: Error updating Device LoginToken, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{
+			LoginToken: lt,
+			Expiration: exp,
+		}, http.StatusOK)
+	}
+}
+
 func (s Server) userInfo() http.HandlerFunc {
 	type request struct {
 		FCMToken string `json:"fcm_token"`
 	}
 	type response struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
+		Name   string   `json:"name"`
+		Email  string   `json:"email"`
+		Roles  []string `json:"roles"`
+		Locale string   `json:"locale"`
 	}
+	openAPIRegister("userInfo", request{}, response{})
 	return func(w http.ResponseWriter, r *http.Request) {
 		uc, err := getUserContext(r.Context())
 		if err != nil {
@@ -252,12 +565,307 @@ func (s Server) userInfo() http.HandlerFunc {
 			}
 		}
 		s.writeJsonResponse(w, response{
-			Name:  uc.user.Name,
-			Email: uc.user.Email,
+			Name:   uc.user.Name,
+			Email:  uc.user.Email,
+			Roles:  uc.user.Roles,
+			Locale: i18n.Normalize(uc.user.Locale),
 		}, http.StatusOK)
 	}
 }
 
+func (s Server) userLogins() http.HandlerFunc {
+	type response []model.LoginEvent
+	openAPIRegister("userLogins", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userLogins: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		limit := int64(50)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsedLimit, err := strconv.ParseInt(l, 10, 64)
+			if err != nil || parsedLimit <= 0 {
+				s.Logger.Debugf("userLogins: Invalid limit: %#v, err: %v", l, err)
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			limit = misc.Min(parsedLimit, 100)
+		}
+
+		les, err := s.DB.LoginEventsFindByUser(r.Context(), uc.user.ID.Hex(), limit)
+		if err != nil {
+			s.Logger.Errorf("userLogins: Error finding LoginEvents for User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if les == nil {
+			les = []model.LoginEvent{}
+		}
+		s.writeJsonResponse(w, response(les), http.StatusOK)
+	}
+}
+
+// userTelegramLink returns a one-time token for linking a Telegram chat, the User sends it to the bot with /start and
+// telegramWebhook links the chat it came from. A new token replaces the previous one.
+func (s Server) userTelegramLink() http.HandlerFunc {
+	type response struct {
+		Token string `json:"token"`
+		// URL is a t.me deep link sending the token to the bot, missing when the bot username is not configured.
+		URL       string             `json:"url,omitempty"`
+		ExpiresAt primitive.DateTime `json:"expires_at"`
+	}
+	openAPIRegister("userTelegramLink", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userTelegramLink: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !s.Client.TelegramEnabled() {
+			s.Logger.Debugf("userTelegramLink: Telegram is not configured")
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		token, tokenHash, err := telegramLinkTokenNew()
+		if err != nil {
+			s.Logger.Errorf("userTelegramLink: Error generating link token, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		expiresAt := time.Now().Add(telegramLinkTokenTTL)
+		if err = s.DB.UserTelegramLinkTokenSet(r.Context(), uc.user.ID.Hex(), tokenHash, expiresAt); err != nil {
+			s.Logger.Errorf("userTelegramLink: Error setting link token on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		resp := response{Token: token, ExpiresAt: primitive.NewDateTimeFromTime(expiresAt)}
+		if s.TelegramBotUsername != "" {
+			resp.URL = fmt.Sprintf("https://t.me/%s?start=%s", s.TelegramBotUsername, token)
+		}
+		s.writeJsonResponse(w, resp, http.StatusOK)
+	}
+}
+
+func (s Server) userTelegramUnlink() http.HandlerFunc {
+	type response struct {
+		Success bool `json:"success"`
+	}
+	openAPIRegister("userTelegramUnlink", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userTelegramUnlink: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if uc.user.Telegram.ChatID == 0 {
+			s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
+			return
+		}
+		if err = s.DB.UserTelegramSet(r.Context(), uc.user.ID.Hex(), model.Telegram{}); err != nil {
+			s.Logger.Errorf("userTelegramUnlink: Error unsetting Telegram on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
+	}
+}
+
+func (s Server) userNotificationPreferences() http.HandlerFunc {
+	// Preferences left out of the request keep their current value.
+	type request struct {
+		FCMEnabled      *bool             `json:"fcm_enabled"`
+		TelegramEnabled *bool             `json:"telegram_enabled"`
+		QuietHours      *model.QuietHours `json:"quiet_hours"`
+		// Digest is daily, weekly or empty to opt out of price digest emails, the current one is kept when it is null.
+		Digest *string `json:"digest"`
+	}
+	type response struct {
+		FCMEnabled      bool             `json:"fcm_enabled"`
+		TelegramEnabled bool             `json:"telegram_enabled"`
+		TelegramLinked  bool             `json:"telegram_linked"`
+		QuietHours      model.QuietHours `json:"quiet_hours"`
+		Digest          string           `json:"digest"`
+	}
+	openAPIRegister("userNotificationPreferences", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userNotificationPreferences: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("userNotificationPreferences: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if req.TelegramEnabled != nil && *req.TelegramEnabled && uc.user.Telegram.ChatID == 0 {
+			s.Logger.Debugf("userNotificationPreferences: Telegram not linked on User with ID: %s", uc.user.ID.Hex())
+			http.Error(w, "Telegram is not linked", http.StatusUnprocessableEntity)
+			return
+		}
+
+		np := uc.user.Notification
+		if req.FCMEnabled != nil {
+			np.FCMDisabled = !*req.FCMEnabled
+		}
+		if req.TelegramEnabled != nil {
+			np.TelegramEnabled = *req.TelegramEnabled
+		}
+		if req.QuietHours != nil {
+			if req.QuietHours.Enabled {
+				if err = req.QuietHours.Validate(); err != nil {
+					s.Logger.Debugf("userNotificationPreferences: Invalid QuietHours: %+v, err: %v", *req.QuietHours, err)
+					http.Error(w, "Invalid quiet_hours: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			np.QuietHours = *req.QuietHours
+		}
+		if req.Digest != nil {
+			if *req.Digest != "" && model.DigestPeriod(*req.Digest) == 0 {
+				s.Logger.Debugf("userNotificationPreferences: Invalid Digest: %#v", *req.Digest)
+				http.Error(w, "Invalid digest", http.StatusBadRequest)
+				return
+			}
+			if *req.Digest != "" && !userLimits(uc.user).Digest {
+				s.Logger.Debugf("userNotificationPreferences: Digest not included in tier of User with ID: %s", uc.user.ID.Hex())
+				http.Error(w, "Price digests require premium", http.StatusForbidden)
+				return
+			}
+			if *req.Digest != "" && uc.user.Email == "" {
+				s.Logger.Debugf("userNotificationPreferences: No email for Digest on User with ID: %s", uc.user.ID.Hex())
+				http.Error(w, "Email is not set", http.StatusUnprocessableEntity)
+				return
+			}
+			np.Digest = *req.Digest
+		}
+		if err = s.DB.UserNotificationPreferencesUpdate(r.Context(), uc.user.ID.Hex(), np); err != nil {
+			s.Logger.Errorf("userNotificationPreferences: Error updating NotificationPreferences on User with ID: %s, err: %v",
+				uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{
+			FCMEnabled:      !np.FCMDisabled,
+			TelegramEnabled: np.TelegramEnabled,
+			TelegramLinked:  uc.user.Telegram.ChatID != 0,
+			QuietHours:      np.QuietHours,
+			Digest:          np.Digest,
+		}, http.StatusOK)
+	}
+}
+
+// userLocale sets the locale of the notifications sent to the User.
+func (s Server) userLocale() http.HandlerFunc {
+	type request struct {
+		Locale string `json:"locale"`
+	}
+	type response struct {
+		Locale string `json:"locale"`
+	}
+	openAPIRegister("userLocale", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("userLocale: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("userLocale: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if !i18n.Supported(req.Locale) {
+			s.Logger.Debugf("userLocale: Unsupported locale: %#v", req.Locale)
+			http.Error(w, "Unsupported locale", http.StatusBadRequest)
+			return
+		}
+
+		locale := i18n.Normalize(req.Locale)
+		if err = s.DB.UserLocaleSet(r.Context(), uc.user.ID.Hex(), locale); err != nil {
+			s.Logger.Errorf("userLocale: Error setting Locale on User with ID: %s, err: %v", uc.user.ID.Hex(), err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{Locale: locale}, http.StatusOK)
+	}
+}
+
+func (s Server) recordLoginEvent(r *http.Request, userID string, deviceID string, eventType string) {
+	userOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		s.Logger.Errorf("recordLoginEvent: Error creating ObjectID from hex: %s, err: %v", userID, err)
+		return
+	}
+	le := model.LoginEvent{
+		UserID:    userOID,
+		Type:      eventType,
+		DeviceID:  deviceID,
+		IP:        requestIP(r),
+		UserAgent: misc.StringLimit(r.UserAgent(), 300),
+	}
+	if err = s.DB.LoginEventInsert(r.Context(), le); err != nil {
+		s.Logger.Errorf("recordLoginEvent: Error inserting LoginEvent, err: %v", err)
+	}
+}
+
+var errFCMTokenDuplicate = errors.New("duplicate fcm_token")
+
+func (s Server) loginDevice(ctx context.Context, u model.User, deviceID string, fcmToken string) (string, error) {
+	lt, exp, tokenHash, err := s.createLoginTokenAndHash(u.ID.Hex(), deviceID)
+	if err != nil {
+		return "", errors.WithMessage(err, "error creating login token for User")
+	}
+	loginToken := model.LoginToken{
+		Token:      tokenHash,
+		Expiration: primitive.NewDateTimeFromTime(exp),
+		CreatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+	}
+	var device *model.Device
+	for _, d := range u.Devices {
+		if d.DeviceID == deviceID {
+			device = &d
+			break
+		}
+	}
+	if device == nil {
+		if err = s.DB.UserDeviceAdd(ctx, u.ID.Hex(), model.Device{
+			DeviceID:   deviceID,
+			LoginToken: loginToken,
+			FCMToken:   fcmToken,
+		}); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return "", errors.Wrapf(errFCMTokenDuplicate, "error duplicate key when adding Device to User, err: %v", err)
+			}
+			return "", errors.WithMessage(err, "error adding Device to User")
+		}
+	} else {
+		device.LoginToken = loginToken
+		device.FCMToken = fcmToken
+		device.LastSeen = primitive.NewDateTimeFromTime(time.Now())
+		if err = s.DB.UserDeviceUpdate(ctx, u.ID.Hex(), *device); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return "", errors.Wrapf(errFCMTokenDuplicate, "error duplicate key when updating Device on User, err: %v", err)
+			}
+			return "", errors.WithMessage(err, "error updating Device on User")
+		}
+	}
+	return lt, nil
+}
+
 func (s Server) createLoginTokenAndHash(userID string, deviceID string) (string, time.Time, []byte, error) {
 	exp := time.Now().AddDate(0, 0, 90)
 	salt := make([]byte, 128)
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"pricetracker/internal/mock"
+	"pricetracker/internal/model"
+	"testing"
+)
+
+// TestUserNotificationPreferencesPartial checks that preferences left out of a request keep their current value.
+func TestUserNotificationPreferencesPartial(t *testing.T) {
+	current := model.NotificationPreferences{
+		FCMDisabled:     false,
+		TelegramEnabled: true,
+		QuietHours:      model.QuietHours{Enabled: false},
+	}
+	tests := []struct {
+		name string
+		body string
+		want func(np model.NotificationPreferences) bool
+	}{
+		{
+			name: "quiet hours only",
+			body: `{"quiet_hours": {"enabled": true, "start": "22:00", "end": "07:00", "timezone": "Asia/Jakarta"}}`,
+			want: func(np model.NotificationPreferences) bool {
+				return np.QuietHours.Enabled && !np.FCMDisabled && np.TelegramEnabled
+			},
+		},
+		{
+			name: "digest only",
+			body: `{"digest": "weekly"}`,
+			want: func(np model.NotificationPreferences) bool {
+				return np.Digest == model.DigestWeekly && !np.FCMDisabled && np.TelegramEnabled
+			},
+		},
+		{
+			name: "fcm only",
+			body: `{"fcm_enabled": false}`,
+			want: func(np model.NotificationPreferences) bool {
+				return np.FCMDisabled && np.TelegramEnabled
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mock.Database{}
+			var got *model.NotificationPreferences
+			db.UserNotificationPreferencesUpdateFunc = func(ctx context.Context, userID string, np model.NotificationPreferences) error {
+				got = &np
+				return nil
+			}
+			s := newTestServer(t, db)
+			u := model.User{
+				Email:        "user@example.com",
+				Entitlement:  model.Entitlement{Tier: model.TierPremium},
+				Notification: current,
+				Telegram:     model.Telegram{ChatID: 42},
+			}
+			lt := loginTestUser(t, s, db, &u)
+
+			rec := serveAuthenticated(s, http.MethodPost, "/api/user/notification/preferences", tt.body, lt)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if got == nil {
+				t.Fatal("NotificationPreferences not updated")
+			}
+			if !tt.want(*got) {
+				t.Errorf("got NotificationPreferences %+v from %+v", *got, current)
+			}
+		})
+	}
+}
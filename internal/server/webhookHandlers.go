@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"pricetracker/internal/client"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+	"time"
+)
+
+const webhooksPerTrackedItemLimit = 5
+
+func (s Server) itemWebhookAdd() http.HandlerFunc {
+	type request struct {
+		ItemID string `json:"item_id"`
+		URL    string `json:"url"`
+	}
+	type response struct {
+		ID     string `json:"id"`
+		URL    string `json:"url"`
+		Secret string `json:"secret"`
+	}
+	openAPIRegister("itemWebhookAdd", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemWebhookAdd: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("itemWebhookAdd: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if err = client.WebhookValidateURL(req.URL); err != nil {
+			s.Logger.Debugf("itemWebhookAdd: Invalid webhook URL, err: %v", err)
+			http.Error(w, "Invalid url", http.StatusBadRequest)
+			return
+		}
+		if !itemTracked(req.ItemID, uc.user.TrackedItems) {
+			s.Logger.Debugf("itemWebhookAdd: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), req.ItemID)
+			http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity)
+			return
+		}
+
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			s.Logger.Errorf("itemWebhookAdd: Error generating webhook secret, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		wh := model.Webhook{
+			ID:        uuid.NewString(),
+			URL:       req.URL,
+			Secret:    hex.EncodeToString(secret),
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+		if err = s.DB.UserTrackedItemWebhookAdd(r.Context(), uc.user.ID.Hex(), req.ItemID, wh, webhooksPerTrackedItemLimit); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("itemWebhookAdd: Webhook limit reached on User with ID: %s, ItemID: %s, err: %v",
+					uc.user.ID.Hex(), req.ItemID, err)
+				http.Error(w, "Webhook limit reached", http.StatusUnprocessableEntity)
+				return
+			}
+			s.Logger.Errorf("itemWebhookAdd: Error adding Webhook on User with ID: %s, ItemID: %s, err: %v",
+				uc.user.ID.Hex(), req.ItemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{ID: wh.ID, URL: wh.URL, Secret: wh.Secret}, http.StatusCreated)
+	}
+}
+
+func (s Server) itemWebhookRemove() http.HandlerFunc {
+	type request struct {
+		ItemID    string `json:"item_id"`
+		WebhookID string `json:"webhook_id"`
+	}
+	type response struct {
+		Success bool `json:"success"`
+	}
+	openAPIRegister("itemWebhookRemove", request{}, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemWebhookRemove: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req := request{}
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.Logger.Debugf("itemWebhookRemove: Error decoding JSON, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if !itemTracked(req.ItemID, uc.user.TrackedItems) {
+			s.Logger.Debugf("itemWebhookRemove: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), req.ItemID)
+			s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
+			return
+		}
+		if err = s.DB.UserTrackedItemWebhookRemove(r.Context(), uc.user.ID.Hex(), req.ItemID, req.WebhookID); err != nil {
+			if errors.Is(err, database.ErrNoDocumentsModified) {
+				s.Logger.Debugf("itemWebhookRemove: Webhook not found on User with ID: %s, ItemID: %s, err: %v",
+					uc.user.ID.Hex(), req.ItemID, err)
+				s.writeJsonResponse(w, response{Success: false}, http.StatusUnprocessableEntity)
+				return
+			}
+			s.Logger.Errorf("itemWebhookRemove: Error removing Webhook on User with ID: %s, ItemID: %s, err: %v",
+				uc.user.ID.Hex(), req.ItemID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		s.writeJsonResponse(w, response{Success: true}, http.StatusOK)
+	}
+}
+
+func (s Server) itemWebhookGet() http.HandlerFunc {
+	type response []model.Webhook
+	openAPIRegister("itemWebhookGet", nil, response{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		uc, err := getUserContext(r.Context())
+		if err != nil {
+			s.Logger.Errorf("itemWebhookGet: Error getting userContext, err: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		itemID := mux.Vars(r)["itemID"]
+		for _, ti := range uc.user.TrackedItems {
+			if ti.ItemID.Hex() == itemID {
+				whs := ti.Webhooks
+				if whs == nil {
+					whs = []model.Webhook{}
+				}
+				s.writeJsonResponse(w, response(whs), http.StatusOK)
+				return
+			}
+		}
+		s.Logger.Debugf("itemWebhookGet: Item not tracked on User with ID: %s, ItemID: %s", uc.user.ID.Hex(), itemID)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+}
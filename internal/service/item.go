@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"pricetracker/internal/model"
+	"strings"
+	"time"
+)
+
+const (
+	// itemMatchMinSimilarity is the minimum NameSimilarity of two Items on different sites to be matched by name.
+	itemMatchMinSimilarity = 0.6
+	// itemMatchCandidatesLimit is how many candidates sharing name tokens are compared with an Item.
+	itemMatchCandidatesLimit = 50
+)
+
+// Scraped is an Item as currently listed on its site.
+type Scraped struct {
+	Item    model.Item
+	Scraper SiteScraper
+	// URL is the clean URL the Item was scraped from, for Blibli variants it is the URL of the variant.
+	URL string
+	// VariationID is the variation of Item to track, only Shopee variants are variations of the same Item.
+	VariationID string
+}
+
+type ItemService interface {
+	// Scrape gets the Item at rawURL from its site, variationID selects a variant of the Item when not empty.
+	// The returned error wraps ErrInvalidURL, ErrVariationUnsupported or ErrVariantNotFound for invalid input,
+	// ErrItemNotFound when the site does not list the Item and ErrSiteUnavailable when the site failed.
+	Scrape(ctx context.Context, rawURL string, variationID string) (Scraped, error)
+	// Variants returns the variants of the scraped Item i, Blibli variants are listed separately on the site.
+	Variants(ctx context.Context, sc Scraped, i model.Item) ([]model.ItemVariant, error)
+	// FindOrInsert returns the stored Item matching ecommerceItem updated with it, or inserts ecommerceItem with
+	// its first ItemHistory when there is none. barcode is set on the Item when it has none yet, it must be verified
+	// as Items with the same barcode are matched as the same product.
+	// Run it in a database transaction for the Item not to be left without its first ItemHistory on errors.
+	FindOrInsert(ctx context.Context, ecommerceItem model.Item, barcode string) (model.Item, error)
+	// Refresh returns the stored Item matching ecommerceItem updated with it, or ecommerceItem itself without
+	// storing it when there is none.
+	Refresh(ctx context.Context, ecommerceItem model.Item) (model.Item, error)
+	// Match links i to the equivalent Items on other sites, Items with the same barcode are always matched
+	// and the others when their names are similar enough. It returns the ItemMatches of i.
+	Match(ctx context.Context, i model.Item) ([]model.ItemMatch, error)
+}
+
+type itemService struct {
+	db       Database
+	scrapers *Scrapers
+	logger   logger
+}
+
+func NewItemService(db Database, scrapers *Scrapers, l logger) ItemService {
+	return itemService{db: db, scrapers: scrapers, logger: l}
+}
+
+func (is itemService) Scrape(ctx context.Context, rawURL string, variationID string) (Scraped, error) {
+	sr, cleanURL, err := is.scrapers.Resolve(rawURL)
+	if err != nil {
+		return Scraped{}, err
+	}
+	variants := sr.VariantsKind()
+	if variationID != "" && variants == VariantsNone {
+		return Scraped{}, errors.Wrap(ErrVariationUnsupported, cleanURL)
+	}
+	sc := Scraped{Scraper: sr, URL: cleanURL}
+	if variationID != "" && variants == VariantsListed {
+		vs, err := sr.Variants(ctx, cleanURL, model.Item{})
+		if err != nil {
+			return Scraped{}, err
+		}
+		variantURL, ok := itemVariantURL(vs, variationID)
+		if !ok {
+			return Scraped{}, errors.Wrapf(ErrVariantNotFound, "variation_id: %s, url: %s", variationID, cleanURL)
+		}
+		sc.URL = variantURL
+	}
+	if sc.Item, err = sr.GetItem(ctx, sc.URL); err != nil {
+		return Scraped{}, err
+	}
+	if variationID != "" && variants == VariantsInItem {
+		v, ok := sc.Item.Variant(variationID)
+		if !ok {
+			return Scraped{}, errors.Wrapf(ErrVariantNotFound, "variation_id: %s, url: %s", variationID, cleanURL)
+		}
+		sc.VariationID = v.VariationID
+	}
+	return sc, nil
+}
+
+// scrapeError maps the error of getting an Item from a site to ErrSiteUnavailable or ErrItemNotFound,
+// siteErr and notFoundErr are the client errors of the site.
+func scrapeError(err error, siteErr error, notFoundErr error, url string) error {
+	if errors.Is(err, notFoundErr) {
+		return errors.Wrapf(ErrItemNotFound, "url: %s, err: %v", url, err)
+	}
+	if errors.Is(err, siteErr) {
+		return errors.Wrapf(ErrSiteUnavailable, "url: %s, err: %v", url, err)
+	}
+	return errors.Wrapf(err, "error getting item with url: %s", url)
+}
+
+// itemVariantURL returns the URL of the variant with variationID among variants.
+func itemVariantURL(variants []model.ItemVariant, variationID string) (string, bool) {
+	for _, v := range variants {
+		if strings.EqualFold(v.VariationID, variationID) {
+			return v.URL, true
+		}
+	}
+	return "", false
+}
+
+func (is itemService) Variants(ctx context.Context, sc Scraped, i model.Item) ([]model.ItemVariant, error) {
+	variants, err := sc.Scraper.Variants(ctx, sc.URL, i)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting %s item variants with url: %s", sc.Scraper.Site(), sc.URL)
+	}
+	return variants, nil
+}
+
+func (is itemService) FindOrInsert(ctx context.Context, ecommerceItem model.Item, barcode string) (model.Item, error) {
+	i, err := is.db.ItemFindExisting(ctx, ecommerceItem)
+	if err == nil {
+		var barcodeAdded bool
+		i, err = is.updateWith(ctx, i, ecommerceItem, func(i *model.Item) {
+			barcodeAdded = i.Barcode == "" && barcode != ""
+			if barcodeAdded {
+				i.Barcode = barcode
+			}
+		})
+		if err != nil {
+			if inTransaction(ctx) {
+				return model.Item{}, errors.Wrap(err, "error updating existing Item")
+			}
+			is.logger.Errorf("FindOrInsert: Error updating existing Item, err: %v", err)
+		} else if barcodeAdded {
+			if _, err = is.Match(ctx, i); err != nil {
+				if inTransaction(ctx) {
+					return model.Item{}, errors.Wrap(err, "error matching Item")
+				}
+				is.logger.Errorf("FindOrInsert: Error matching Item, err: %v", err)
+			}
+		}
+		return i, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Item{}, errors.Wrap(err, "error finding existing Item")
+	}
+
+	i = ecommerceItem
+	i.Barcode = barcode
+	i.PriceHistoryHighest = i.Price
+	i.PriceHistoryLowest = i.Price
+	itemID, err := is.db.ItemInsert(ctx, i)
+	if err != nil {
+		return model.Item{}, errors.Wrap(err, "error inserting Item")
+	}
+	if i.ID, err = primitive.ObjectIDFromHex(itemID); err != nil {
+		return model.Item{}, errors.Wrapf(err, "error creating ObjectID from hex: %s", itemID)
+	}
+	ih := model.ItemHistory{
+		ItemID:       i.ID,
+		FetchCycleID: primitive.NewObjectID(),
+		Price:        i.Price,
+		Currency:     i.Currency,
+		Stock:        i.Stock,
+		Rating:       i.Rating,
+		Sold:         i.Sold,
+		Timestamp:    primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if err = is.db.ItemHistoryUpsert(ctx, ih); err != nil {
+		return model.Item{}, errors.Wrap(err, "error upserting first ItemHistory")
+	}
+	if _, err = is.Match(ctx, i); err != nil {
+		if inTransaction(ctx) {
+			return model.Item{}, errors.Wrap(err, "error matching Item")
+		}
+		is.logger.Errorf("FindOrInsert: Error matching Item, err: %v", err)
+	}
+	return i, nil
+}
+
+// inTransaction reports whether ctx runs a database transaction, whose operations after a failed one fail as well
+// so errors that are otherwise only logged must abort it.
+func inTransaction(ctx context.Context) bool {
+	return mongo.SessionFromContext(ctx) != nil
+}
+
+func (is itemService) Refresh(ctx context.Context, ecommerceItem model.Item) (model.Item, error) {
+	i, err := is.db.ItemFindExisting(ctx, ecommerceItem)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Item{}, errors.Wrap(err, "error finding existing Item")
+		}
+		i = ecommerceItem
+		i.PriceHistoryHighest = i.Price
+		i.PriceHistoryLowest = i.Price
+		return i, nil
+	}
+	if i, err = is.updateWith(ctx, i, ecommerceItem, nil); err != nil {
+		is.logger.Errorf("Refresh: Error updating existing Item, err: %v", err)
+	}
+	return i, nil
+}
+
+// updateWith updates the stored Item i with the fetched ecommerceItem, its price atomically before the other fields.
+// fn is applied to the Item after UpdateWith when it is not nil.
+func (is itemService) updateWith(
+	ctx context.Context, i model.Item, ecommerceItem model.Item, fn func(i *model.Item)) (model.Item, error) {
+	updated, err := is.db.ItemPriceUpdate(ctx, i.ID, ecommerceItem.Price)
+	if err != nil {
+		return i, err
+	}
+	return is.db.ItemUpdateFunc(ctx, updated, func(i *model.Item) {
+		i.UpdateWith(ecommerceItem)
+		if fn != nil {
+			fn(i)
+		}
+	})
+}
+
+func (is itemService) Match(ctx context.Context, i model.Item) ([]model.ItemMatch, error) {
+	if len(i.NameTokens) == 0 {
+		i.NameTokens = model.ItemNameTokens(i.Name)
+	}
+	if len(i.NameTokens) == 0 && i.Barcode == "" {
+		return nil, nil
+	}
+	candidates, err := is.db.ItemsFindMatchCandidates(ctx, i, itemMatchCandidatesLimit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding match candidates for ItemID: %s", i.ID.Hex())
+	}
+	var ims []model.ItemMatch
+	for _, c := range candidates {
+		im := model.ItemMatch{ItemID: i.ID, MatchedItemID: c.ID}
+		if i.Barcode != "" && c.Barcode == i.Barcode {
+			im.Reason = model.ItemMatchReasonBarcode
+			im.Similarity = 1
+		} else if sim := model.NameSimilarity(i.NameTokens, c.NameTokens); sim >= itemMatchMinSimilarity {
+			im.Reason = model.ItemMatchReasonName
+			im.Similarity = sim
+		} else {
+			continue
+		}
+		ims = append(ims, im)
+	}
+	if err = is.db.ItemMatchesUpsert(ctx, ims); err != nil {
+		return nil, errors.Wrapf(err, "error saving ItemMatches for ItemID: %s", i.ID.Hex())
+	}
+	return ims, nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"time"
+)
+
+// Recipients are the notification channels a notification is sent through.
+type Recipients struct {
+	UserIDs         []primitive.ObjectID
+	FCMTokens       []string
+	TelegramChatIDs []int64
+	Webhooks        []model.Webhook
+	// Quiet are the Users in their quiet hours, their notifications are queued instead of sent.
+	Quiet []QuietRecipient
+}
+
+type QuietRecipient struct {
+	UserID primitive.ObjectID
+	Until  time.Time
+}
+
+type Message struct {
+	Event   string
+	Title   string
+	Body    string
+	FCMData client.FCMData
+	// Text replaces the default title, body and URL text of Telegram messages and webhooks when set.
+	Text string
+	// VariationID is the variant of the Item the message is about, webhooks carry its price and stock.
+	VariationID string
+
+	Alternatives []model.ItemAlternative
+}
+
+type NotificationService interface {
+	// Send sends msg about i through every channel in rcp and queues it for Users in their quiet hours,
+	// it returns true if at least one channel succeeded or the notification was queued. Webhooks are sent in the
+	// background and count as succeeded once queued.
+	Send(ctx context.Context, i model.Item, rcp Recipients, msg Message) bool
+}
+
+type notificationService struct {
+	db       Database
+	client   Client
+	logger   logger
+	webhooks *webhookQueue
+}
+
+func NewNotificationService(db Database, c Client, l logger) NotificationService {
+	return notificationService{db: db, client: c, logger: l, webhooks: newWebhookQueue(c, l)}
+}
+
+func (ns notificationService) Send(ctx context.Context, i model.Item, rcp Recipients, msg Message) bool {
+	itemName := i.ShortName()
+	text := fmt.Sprintf("%s\n%s\n%s", msg.Title, msg.Body, i.URL)
+	if msg.Text != "" {
+		text = msg.Text
+	}
+	var sent bool
+	if len(rcp.Quiet) > 0 {
+		sent = ns.queue(ctx, i, rcp.Quiet, msg, text)
+	}
+	if len(rcp.FCMTokens) > 0 {
+		fcmReq := client.FCMSendRequest{
+			Notification: client.FCMNotification{
+				Title:       msg.Title,
+				Body:        msg.Body,
+				ClickAction: "FLUTTER_NOTIFICATION_CLICK",
+				Sound:       "default",
+				Image:       msg.FCMData.ImageURL,
+			},
+			Data:            msg.FCMData,
+			MutableContent:  msg.FCMData.ImageURL != "",
+			RegistrationIDs: rcp.FCMTokens,
+		}
+		ns.logger.Infof("Send: Sending %s notification to %d Device(s) for Item: %s, ID: %s",
+			msg.Event, len(rcp.FCMTokens), itemName, i.ID.Hex())
+		ns.logger.Debugf("Send: FCMSendRequest for Item: %s, ID: %s, req: %+v", itemName, i.ID.Hex(), fcmReq)
+		fcmResp, err := ns.client.FCMSendNotification(ctx, fcmReq)
+		if err != nil {
+			ns.logger.Errorf(
+				"Send: Error sending notification to FCM for Item: %s, ID: %s, FCMSendRequest: %+v, err: %v",
+				itemName, i.ID.Hex(), fcmReq, err,
+			)
+		} else {
+			sent = true
+			ns.logger.Infof("Send: Send notification results for Item: %s, ID: %s, success: %d, failure: %d",
+				itemName, i.ID.Hex(), fcmResp.Success, fcmResp.Failure)
+			ns.logger.Debugf("Send: FCMSendResponse for Item: %s, ID: %s, resp: %+v", itemName, i.ID.Hex(), fcmResp)
+			ns.pruneFCMTokens(ctx, rcp.FCMTokens, fcmResp)
+		}
+	}
+	if len(rcp.TelegramChatIDs) > 0 {
+		ns.logger.Infof("Send: Sending %s Telegram message to %d chat(s) for Item: %s, ID: %s",
+			msg.Event, len(rcp.TelegramChatIDs), itemName, i.ID.Hex())
+		for _, chatID := range rcp.TelegramChatIDs {
+			if err := ns.client.TelegramSendMessage(ctx, chatID, text); err != nil {
+				ns.logger.Errorf("Send: Error sending Telegram message for Item: %s, ID: %s, ChatID: %d, err: %v",
+					itemName, i.ID.Hex(), chatID, err)
+				continue
+			}
+			sent = true
+		}
+	}
+	if len(rcp.Webhooks) > 0 {
+		ns.logger.Infof("Send: Queueing %s webhook to %d URL(s) for Item: %s, ID: %s",
+			msg.Event, len(rcp.Webhooks), itemName, i.ID.Hex())
+		price, stock := i.PriceAndStock(msg.VariationID)
+		payload := client.WebhookPayload{
+			Event:   msg.Event,
+			Text:    text,
+			Content: text,
+			Item: client.WebhookItem{
+				ID:          i.ID.Hex(),
+				VariationID: msg.VariationID,
+				Name:        i.Name,
+				URL:         i.URL,
+				Price:       price,
+				Currency:    model.CurrencyOrDefault(i.Currency),
+				Stock:       stock,
+				ImageURL:    i.ImageURL,
+			},
+		}
+		for _, alt := range msg.Alternatives {
+			payload.Alternatives = append(payload.Alternatives, client.WebhookItem{
+				Name:     alt.Name,
+				URL:      alt.URL,
+				Price:    alt.Price,
+				Currency: alt.Currency,
+				ImageURL: alt.ImageURL,
+			})
+		}
+		for _, wh := range rcp.Webhooks {
+			if !ns.webhooks.enqueue(webhookJob{webhook: wh, payload: payload}) {
+				ns.logger.Errorf("Send: Webhook queue is full, dropped webhook for Item: %s, ID: %s, WebhookID: %s",
+					itemName, i.ID.Hex(), wh.ID)
+				continue
+			}
+			sent = true
+		}
+	}
+	return sent
+}
+
+// queue stores msg to be delivered to each quiet User when their quiet hours end.
+func (ns notificationService) queue(ctx context.Context, i model.Item, quiet []QuietRecipient, msg Message, text string) bool {
+	qns := make([]model.QueuedNotification, 0, len(quiet))
+	for _, q := range quiet {
+		qns = append(qns, model.QueuedNotification{
+			UserID:         q.UserID,
+			ItemID:         i.ID,
+			Event:          msg.Event,
+			Title:          msg.Title,
+			Body:           msg.Body,
+			Text:           text,
+			FCMType:        msg.FCMData.Type,
+			FCMAction:      msg.FCMData.Action,
+			ReplacementURL: msg.FCMData.ReplacementURL,
+			ImageURL:       msg.FCMData.ImageURL,
+			OldPrice:       msg.FCMData.OldPrice,
+			NewPrice:       msg.FCMData.NewPrice,
+			DeepLink:       msg.FCMData.DeepLink,
+			DeliverAt:      primitive.NewDateTimeFromTime(q.Until),
+		})
+	}
+	if err := ns.db.QueuedNotificationsInsert(ctx, qns); err != nil {
+		ns.logger.Errorf("queue: Error queueing %s notification for %d User(s) for Item: %s, ID: %s, err: %v",
+			msg.Event, len(qns), i.ShortName(), i.ID.Hex(), err)
+		return false
+	}
+	ns.logger.Infof("queue: Queued %s notification for %d User(s) in quiet hours for Item: %s, ID: %s",
+		msg.Event, len(qns), i.ShortName(), i.ID.Hex())
+	return true
+}
+
+// pruneFCMTokens unsets the FCM tokens that FCM reported as no longer registered,
+// fcmResp.Results are in the same order as fcmTokens.
+func (ns notificationService) pruneFCMTokens(ctx context.Context, fcmTokens []string, fcmResp client.FCMSendResponse) {
+	if len(fcmResp.Results) != len(fcmTokens) {
+		ns.logger.Errorf("pruneFCMTokens: FCMSendResponse results count mismatch, results: %d, tokens: %d",
+			len(fcmResp.Results), len(fcmTokens))
+		return
+	}
+	var pruned int
+	for idx, result := range fcmResp.Results {
+		if !result.Unregistered() {
+			continue
+		}
+		if err := ns.db.UserDeviceFCMTokenUnset(ctx, fcmTokens[idx]); err != nil {
+			ns.logger.Errorf("pruneFCMTokens: Error unsetting FCMToken, err: %v", err)
+			continue
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		ns.logger.Infof("pruneFCMTokens: Pruned %d invalid FCMToken(s)", pruned)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net/url"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+// VariantsKind is how the variants of the Items of a site are tracked.
+type VariantsKind int
+
+const (
+	// VariantsNone is for sites whose Items can not be tracked by variant.
+	VariantsNone VariantsKind = iota
+	// VariantsInItem is for sites listing the variants in the Item, a variant is tracked as a variation of the Item.
+	VariantsInItem
+	// VariantsListed is for sites listing every variant as an Item with its own URL.
+	VariantsListed
+)
+
+// SiteScraper gets Items from a marketplace, adding a marketplace means adding a SiteScraper to the Scrapers.
+type SiteScraper interface {
+	// Site returns the Item Site name of the marketplace, e.g. Shopee.
+	Site() string
+	// ClientSite returns the client site name of the marketplace, e.g. client.SiteShopee.
+	ClientSite() string
+	// Match reports whether u is a URL of the marketplace.
+	Match(u *url.URL) bool
+	// GetItem gets the Item at the clean URL url, the returned error wraps ErrItemNotFound when the marketplace
+	// does not list the Item and ErrSiteUnavailable when the marketplace failed.
+	GetItem(ctx context.Context, url string) (model.Item, error)
+	// Search returns the Items found on the marketplace with query.
+	Search(ctx context.Context, query string) ([]model.Item, error)
+	VariantsKind() VariantsKind
+	// Variants returns the variants of i, the Item at url.
+	Variants(ctx context.Context, url string, i model.Item) ([]model.ItemVariant, error)
+}
+
+// Scrapers is the registry of the SiteScrapers of the supported marketplaces, in the order they are searched.
+type Scrapers struct {
+	scrapers []SiteScraper
+}
+
+// NewScrapers returns the Scrapers of Shopee, Tokopedia and Blibli getting Items through c,
+// and of eBay when c has eBay enabled.
+func NewScrapers(c Client) *Scrapers {
+	ss := &Scrapers{}
+	ss.Register(shopeeScraper{client: c})
+	ss.Register(tokopediaScraper{client: c})
+	ss.Register(blibliScraper{client: c})
+	if c.EbayEnabled() {
+		ss.Register(ebayScraper{client: c, host: c.EbayHost()})
+	}
+	return ss
+}
+
+// Register adds sr to ss, it must be called before ss is used.
+func (ss *Scrapers) Register(sr SiteScraper) {
+	ss.scrapers = append(ss.scrapers, sr)
+}
+
+func (ss *Scrapers) All() []SiteScraper {
+	return ss.scrapers
+}
+
+// Site returns the SiteScraper of the Item Site name matching site case-insensitively.
+func (ss *Scrapers) Site(site string) (SiteScraper, bool) {
+	for _, sr := range ss.scrapers {
+		if strings.EqualFold(sr.Site(), site) {
+			return sr, true
+		}
+	}
+	return nil, false
+}
+
+// Resolve returns the SiteScraper of urlStr and the URL without its query, the returned error wraps
+// ErrInvalidURL when urlStr is not a URL of a supported marketplace.
+func (ss *Scrapers) Resolve(urlStr string) (SiteScraper, string, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, "", errors.Wrap(ErrInvalidURL, err.Error())
+	}
+	if parsedURL.Host == "" {
+		parsedURL, err = url.Parse("https://" + urlStr)
+		if err != nil {
+			return nil, "", errors.Wrap(ErrInvalidURL, err.Error())
+		}
+	}
+	cleanURL := "https://" + parsedURL.Host + parsedURL.Path
+	for _, sr := range ss.scrapers {
+		if sr.Match(parsedURL) {
+			return sr, cleanURL, nil
+		}
+	}
+	return nil, "", errors.Wrap(ErrInvalidURL, cleanURL)
+}
@@ -0,0 +1,64 @@
+// Package service holds the item, user and notification logic shared by the HTTP handlers and the fetcher,
+// the handlers only translate between HTTP and the services.
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+)
+
+var (
+	ErrInvalidURL           = errors.New("invalid site url")
+	ErrVariationUnsupported = errors.New("variation_id is not supported for this site")
+	ErrVariantNotFound      = errors.New("variation_id is not a variant of the item")
+	ErrItemNotFound         = errors.New("item not found")
+	// ErrSiteUnavailable is returned when a site could not be reached or responded with an error.
+	ErrSiteUnavailable   = errors.New("site unavailable")
+	ErrTrackedItemsLimit = errors.New("tracked items limit reached")
+)
+
+// Database is the part of database.Database used by the services.
+type Database interface {
+	ItemFindExisting(ctx context.Context, i model.Item) (model.Item, error)
+	ItemUpdateFunc(ctx context.Context, i model.Item, fn func(i *model.Item)) (model.Item, error)
+	ItemPriceUpdate(ctx context.Context, itemID primitive.ObjectID, price int) (model.Item, error)
+	ItemInsert(ctx context.Context, i model.Item) (id string, err error)
+	ItemHistoryUpsert(ctx context.Context, ih model.ItemHistory) error
+	ItemsFindMatchCandidates(ctx context.Context, i model.Item, limit int) ([]model.Item, error)
+	ItemMatchesUpsert(ctx context.Context, ims []model.ItemMatch) error
+	UserTrackedItemVariationSet(
+		ctx context.Context, userID string, itemID primitive.ObjectID, variationID string, priceInitial int,
+	) error
+	UserTrackedItemUpdate(ctx context.Context, userID string, ti model.TrackedItem) error
+	UserTrackedItemAdd(ctx context.Context, userID string, ti model.TrackedItem, trackedItemsLimit int) error
+	UserTrackedItemsAdd(ctx context.Context, userID string, tis []model.TrackedItem, trackedItemsLimit int) error
+	QueuedNotificationsInsert(ctx context.Context, qns []model.QueuedNotification) error
+	UserDeviceFCMTokenUnset(ctx context.Context, fcmToken string) error
+}
+
+// Client is the part of client.Client used by the services.
+type Client interface {
+	ShopeeGetItem(ctx context.Context, url string) (model.Item, error)
+	TokopediaGetItem(ctx context.Context, url string) (model.Item, error)
+	BlibliGetItem(ctx context.Context, url string) (model.Item, error)
+	BlibliGetItemVariants(ctx context.Context, url string) ([]model.ItemVariant, error)
+	ShopeeSearch(ctx context.Context, query string) ([]model.Item, error)
+	TokopediaSearch(ctx context.Context, query string) ([]model.Item, error)
+	BlibliSearch(ctx context.Context, query string) ([]model.Item, error)
+	EbayEnabled() bool
+	EbayHost() string
+	EbayGetItem(ctx context.Context, url string) (model.Item, error)
+	EbaySearch(ctx context.Context, query string) ([]model.Item, error)
+	FCMSendNotification(ctx context.Context, fcmReqBody client.FCMSendRequest) (client.FCMSendResponse, error)
+	TelegramSendMessage(ctx context.Context, chatID int64, text string) error
+	WebhookSend(ctx context.Context, webhookURL string, secret string, payload client.WebhookPayload) error
+}
+
+type logger interface {
+	Debugf(format string, v ...any)
+	Infof(format string, v ...any)
+	Errorf(format string, v ...any)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"strings"
+)
+
+type shopeeScraper struct {
+	client Client
+}
+
+func (shopeeScraper) Site() string       { return "Shopee" }
+func (shopeeScraper) ClientSite() string { return client.SiteShopee }
+
+func (shopeeScraper) Match(u *url.URL) bool {
+	return u.Host == "shopee.co.id"
+}
+
+func (sr shopeeScraper) GetItem(ctx context.Context, url string) (model.Item, error) {
+	i, err := sr.client.ShopeeGetItem(ctx, url)
+	if err != nil {
+		return model.Item{}, scrapeError(err, client.ErrShopee, client.ErrShopeeItemNotFound, url)
+	}
+	return i, nil
+}
+
+func (sr shopeeScraper) Search(ctx context.Context, query string) ([]model.Item, error) {
+	return sr.client.ShopeeSearch(ctx, query)
+}
+
+func (shopeeScraper) VariantsKind() VariantsKind { return VariantsInItem }
+
+func (shopeeScraper) Variants(_ context.Context, _ string, i model.Item) ([]model.ItemVariant, error) {
+	return i.Variants, nil
+}
+
+type tokopediaScraper struct {
+	client Client
+}
+
+func (tokopediaScraper) Site() string       { return "Tokopedia" }
+func (tokopediaScraper) ClientSite() string { return client.SiteTokopedia }
+
+func (tokopediaScraper) Match(u *url.URL) bool {
+	return u.Host == "www.tokopedia.com" || u.Host == "tokopedia.com" || u.Host == "tokopedia.link"
+}
+
+func (sr tokopediaScraper) GetItem(ctx context.Context, url string) (model.Item, error) {
+	i, err := sr.client.TokopediaGetItem(ctx, url)
+	if err != nil {
+		return model.Item{}, scrapeError(err, client.ErrTokopedia, client.ErrTokopediaItemNotFound, url)
+	}
+	return i, nil
+}
+
+func (sr tokopediaScraper) Search(ctx context.Context, query string) ([]model.Item, error) {
+	return sr.client.TokopediaSearch(ctx, query)
+}
+
+func (tokopediaScraper) VariantsKind() VariantsKind { return VariantsNone }
+
+func (tokopediaScraper) Variants(_ context.Context, _ string, i model.Item) ([]model.ItemVariant, error) {
+	return i.Variants, nil
+}
+
+type blibliScraper struct {
+	client Client
+}
+
+func (blibliScraper) Site() string       { return "Blibli" }
+func (blibliScraper) ClientSite() string { return client.SiteBlibli }
+
+func (blibliScraper) Match(u *url.URL) bool {
+	return u.Host == "www.blibli.com" || u.Host == "blibli.com" || u.Host == "blibli.app.link"
+}
+
+func (sr blibliScraper) GetItem(ctx context.Context, url string) (model.Item, error) {
+	i, err := sr.client.BlibliGetItem(ctx, url)
+	if err != nil {
+		return model.Item{}, scrapeError(err, client.ErrBlibli, client.ErrBlibliItemNotFound, url)
+	}
+	return i, nil
+}
+
+func (sr blibliScraper) Search(ctx context.Context, query string) ([]model.Item, error) {
+	return sr.client.BlibliSearch(ctx, query)
+}
+
+func (blibliScraper) VariantsKind() VariantsKind { return VariantsListed }
+
+func (sr blibliScraper) Variants(ctx context.Context, url string, _ model.Item) ([]model.ItemVariant, error) {
+	variants, err := sr.client.BlibliGetItemVariants(ctx, url)
+	if err != nil {
+		return nil, scrapeError(err, client.ErrBlibli, client.ErrBlibliItemNotFound, url)
+	}
+	return variants, nil
+}
+
+// ebayScraper gets Items priced in the currency of the eBay marketplace of the client, e.g. USD.
+type ebayScraper struct {
+	client Client
+	// host is the host of the item pages of the eBay marketplace of the client, e.g. www.ebay.com.
+	host string
+}
+
+func (ebayScraper) Site() string       { return "eBay" }
+func (ebayScraper) ClientSite() string { return client.SiteEbay }
+
+func (sr ebayScraper) Match(u *url.URL) bool {
+	domain := strings.TrimPrefix(sr.host, "www.")
+	return u.Host == sr.host || u.Host == domain || u.Host == "m."+domain
+}
+
+func (sr ebayScraper) GetItem(ctx context.Context, url string) (model.Item, error) {
+	i, err := sr.client.EbayGetItem(ctx, url)
+	if err != nil {
+		return model.Item{}, scrapeError(err, client.ErrEbay, client.ErrEbayItemNotFound, url)
+	}
+	return i, nil
+}
+
+func (sr ebayScraper) Search(ctx context.Context, query string) ([]model.Item, error) {
+	return sr.client.EbaySearch(ctx, query)
+}
+
+func (ebayScraper) VariantsKind() VariantsKind { return VariantsNone }
+
+func (ebayScraper) Variants(_ context.Context, _ string, i model.Item) ([]model.ItemVariant, error) {
+	return i.Variants, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"pricetracker/internal/database"
+	"pricetracker/internal/model"
+)
+
+type UserService interface {
+	// TrackItem adds ti to the TrackedItems of u, or replaces the TrackedItem of the same Item when u already
+	// tracks it. The returned error wraps ErrTrackedItemsLimit when u already tracks limit Items.
+	TrackItem(ctx context.Context, u model.User, ti model.TrackedItem, limit int) error
+	// TrackItems adds tis, which u does not track yet, to the TrackedItems of u at once.
+	// The returned error wraps ErrTrackedItemsLimit when u would track more than limit Items.
+	TrackItems(ctx context.Context, u model.User, tis []model.TrackedItem, limit int) error
+}
+
+type userService struct {
+	db     Database
+	logger logger
+}
+
+func NewUserService(db Database, l logger) UserService {
+	return userService{db: db, logger: l}
+}
+
+func (us userService) TrackItem(ctx context.Context, u model.User, ti model.TrackedItem, limit int) error {
+	var tracked bool
+	for _, existing := range u.TrackedItems {
+		if existing.ItemID != ti.ItemID {
+			continue
+		}
+		tracked = true
+		if existing.VariationID == ti.VariationID {
+			continue
+		}
+		err := us.db.UserTrackedItemVariationSet(ctx, u.ID.Hex(), ti.ItemID, ti.VariationID, ti.PriceInitial)
+		if err != nil {
+			return errors.Wrap(err, "error setting TrackedItem variation on User")
+		}
+	}
+	if tracked {
+		return errors.Wrap(us.db.UserTrackedItemUpdate(ctx, u.ID.Hex(), ti), "error updating TrackedItem on User")
+	}
+	err := us.db.UserTrackedItemAdd(ctx, u.ID.Hex(), ti, limit)
+	if errors.Is(err, database.ErrTrackedItemsLimit) {
+		return errors.Wrapf(ErrTrackedItemsLimit, "TrackedItems are limited to %d for User with ID: %s, ItemID: %s",
+			limit, u.ID.Hex(), ti.ItemID.Hex())
+	}
+	return errors.Wrap(err, "error adding TrackedItem to User")
+}
+
+func (us userService) TrackItems(ctx context.Context, u model.User, tis []model.TrackedItem, limit int) error {
+	if len(tis) == 0 {
+		return nil
+	}
+	err := us.db.UserTrackedItemsAdd(ctx, u.ID.Hex(), tis, limit)
+	if errors.Is(err, database.ErrTrackedItemsLimit) {
+		return errors.Wrapf(ErrTrackedItemsLimit, "TrackedItems are limited to %d for User with ID: %s, adding: %d",
+			limit, u.ID.Hex(), len(tis))
+	}
+	return errors.Wrapf(err, "error adding %d TrackedItems to User", len(tis))
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"pricetracker/internal/client"
+	"pricetracker/internal/model"
+	"time"
+)
+
+const (
+	// webhookQueueSize bounds the webhooks waiting to be sent, webhooks queued while it is full are dropped.
+	webhookQueueSize = 1024
+	webhookWorkers   = 4
+	// webhookSendTimeout bounds sending a single webhook.
+	webhookSendTimeout = 15 * time.Second
+)
+
+type webhookJob struct {
+	webhook model.Webhook
+	payload client.WebhookPayload
+}
+
+// webhookQueue sends webhooks in the background, so slow webhook endpoints do not hold up the fetcher.
+type webhookQueue struct {
+	jobs chan webhookJob
+}
+
+func newWebhookQueue(c Client, l logger) *webhookQueue {
+	q := &webhookQueue{jobs: make(chan webhookJob, webhookQueueSize)}
+	for w := 0; w < webhookWorkers; w++ {
+		go func() {
+			for job := range q.jobs {
+				ctx, cancel := context.WithTimeout(context.Background(), webhookSendTimeout)
+				if err := c.WebhookSend(ctx, job.webhook.URL, job.webhook.Secret, job.payload); err != nil {
+					l.Errorf("webhookQueue: Error sending %s webhook for ItemID: %s, WebhookID: %s, err: %v",
+						job.payload.Event, job.payload.Item.ID, job.webhook.ID, err)
+				}
+				cancel()
+			}
+		}()
+	}
+	return q
+}
+
+// enqueue queues job to be sent, it returns false when the queue is full.
+func (q *webhookQueue) enqueue(job webhookJob) bool {
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,274 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// exportQueueSize is how many ended spans wait for export, spans ending while it is full are dropped.
+	exportQueueSize = 4096
+	// exportBatchSize is the most spans exported in one request.
+	exportBatchSize = 512
+	// exportInterval is how often the queued spans are exported.
+	exportInterval = 5 * time.Second
+	// exportTimeout bounds every export request.
+	exportTimeout = 10 * time.Second
+	// exportAttempts is how many times a batch is sent before it is dropped, retries wait exportBackoff doubling
+	// every time.
+	exportAttempts = 4
+	exportBackoff  = 500 * time.Millisecond
+)
+
+// exporter sends ended spans in batches to an OTLP/HTTP traces endpoint.
+type exporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	logger      logger
+
+	queue     chan otlpSpan
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	dropped int
+}
+
+func newExporter(url string, serviceName string, l logger) *exporter {
+	e := &exporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		logger:      l,
+		queue:       make(chan otlpSpan, exportQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// The types below are the OTLP/JSON encoding of the spans, see opentelemetry-proto trace/v1/trace.proto.
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func attribute(key string, v any) (otlpAttribute, bool) {
+	a := otlpAttribute{Key: key}
+	switch v := v.(type) {
+	case string:
+		a.Value.StringValue = &v
+	case bool:
+		a.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		a.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		a.Value.IntValue = &s
+	case float64:
+		a.Value.DoubleValue = &v
+	default:
+		return a, false
+	}
+	return a, true
+}
+
+// export queues s ended at end for export.
+func (e *exporter) export(s *Span, end time.Time) {
+	sp := otlpSpan{
+		TraceID:           hex.EncodeToString(s.sc.traceID[:]),
+		SpanID:            hex.EncodeToString(s.sc.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Status:            otlpStatus{Code: 1},
+	}
+	if s.parentID != [8]byte{} {
+		sp.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	s.mu.Lock()
+	for k, v := range s.attrs {
+		if a, ok := attribute(k, v); ok {
+			sp.Attributes = append(sp.Attributes, a)
+		}
+	}
+	if s.err != "" {
+		sp.Status = otlpStatus{Code: 2, Message: s.err}
+	}
+	s.mu.Unlock()
+	sort.Slice(sp.Attributes, func(a, b int) bool { return sp.Attributes[a].Key < sp.Attributes[b].Key })
+
+	select {
+	case e.queue <- sp:
+	default:
+		e.mu.Lock()
+		e.dropped++
+		e.mu.Unlock()
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]otlpSpan, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = batch[:0]
+		}
+		e.mu.Lock()
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+		if dropped > 0 {
+			e.logger.Errorf("tracing: Dropped %d span(s), the export queue was full", dropped)
+		}
+	}
+	for {
+		select {
+		case sp := <-e.queue:
+			if batch = append(batch, sp); len(batch) == exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case sp := <-e.queue:
+					if batch = append(batch, sp); len(batch) == exportBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) send(spans []otlpSpan) {
+	serviceName, _ := attribute("service.name", e.serviceName)
+	req := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{serviceName}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "pricetracker/internal/tracing"},
+			Spans: spans,
+		}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		e.logger.Errorf("tracing: Error marshalling %d span(s), err: %v", len(spans), err)
+		return
+	}
+	backoff := exportBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := e.post(body)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt == exportAttempts {
+			e.logger.Errorf("tracing: Error exporting %d span(s) after %d attempt(s), err: %v", len(spans), attempt, err)
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-e.stop:
+			// Closing, the last attempt is made without waiting.
+			backoff = 0
+		}
+	}
+}
+
+// post sends an export request with body, it reports whether a failed request can be retried, which are the ones
+// failing to connect and the ones the collector answers with a status the OTLP specification lists as retryable.
+func (e *exporter) post(body []byte) (bool, error) {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode < http.StatusBadRequest:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout:
+		return true, errors.New("response status: " + resp.Status)
+	default:
+		return false, errors.New("response status: " + resp.Status)
+	}
+}
+
+// close exports the queued spans and stops the exporter, waiting until ctx is done at most.
+func (e *exporter) close(ctx context.Context) {
+	e.closeOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CommandMonitor returns a MongoDB command monitor recording a client span of every command run within a span,
+// it is nil when t is nil.
+func (t *Tracer) CommandMonitor() *event.CommandMonitor {
+	if t == nil {
+		return nil
+	}
+	var spans sync.Map
+	key := func(connectionID string, requestID int64) string {
+		return connectionID + "/" + strconv.FormatInt(requestID, 10)
+	}
+	end := func(connectionID string, requestID int64, err error) {
+		if s, ok := spans.LoadAndDelete(key(connectionID, requestID)); ok {
+			s.(*Span).SetError(err)
+			s.(*Span).End()
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !HasSpan(ctx) {
+				return
+			}
+			_, s := t.Start(ctx, "mongodb."+e.CommandName, KindClient)
+			s.SetAttribute("db.system", "mongodb")
+			s.SetAttribute("db.name", e.DatabaseName)
+			s.SetAttribute("db.operation", e.CommandName)
+			// The first element of a command is the command name with the collection name as its value.
+			if elems, err := e.Command.Elements(); err == nil && len(elems) > 0 {
+				if collection, ok := elems[0].Value().StringValueOK(); ok {
+					s.SetAttribute("db.mongodb.collection", collection)
+				}
+			}
+			spans.Store(key(e.ConnectionID, e.RequestID), s)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.ConnectionID, e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.ConnectionID, e.RequestID, spanError(e.Failure))
+		},
+	}
+}
+
+// spanError is the error of a span failing without an error value.
+type spanError string
+
+func (e spanError) Error() string { return string(e) }
+
+type redisSpanKey struct{}
+
+// RedisHook records a client span of every Redis command run within a span.
+type RedisHook struct {
+	Tracer *Tracer
+}
+
+var _ redis.Hook = RedisHook{}
+
+func (h RedisHook) start(ctx context.Context, name string) context.Context {
+	if !HasSpan(ctx) {
+		return ctx
+	}
+	ctx, s := h.Tracer.Start(ctx, name, KindClient)
+	s.SetAttribute("db.system", "redis")
+	return context.WithValue(ctx, redisSpanKey{}, s)
+}
+
+func (h RedisHook) end(ctx context.Context, err error) {
+	if s, ok := ctx.Value(redisSpanKey{}).(*Span); ok {
+		if err != redis.Nil {
+			s.SetError(err)
+		}
+		s.End()
+	}
+}
+
+func (h RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx, "redis."+cmd.Name()), nil
+}
+
+func (h RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.end(ctx, cmd.Err())
+	return nil
+}
+
+func (h RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name())
+	}
+	ctx = h.start(ctx, "redis.pipeline")
+	if s, ok := ctx.Value(redisSpanKey{}).(*Span); ok {
+		s.SetAttribute("db.operation", strings.Join(names, " "))
+	}
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
+
+// Transport returns base recording a client span of every request made within a span, it is base when t is nil.
+// The trace context is not sent to the requested hosts, which are third party sites.
+func (t *Tracer) Transport(base http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return base
+	}
+	return transport{tracer: t, base: base}
+}
+
+type transport struct {
+	tracer *Tracer
+	base   http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !HasSpan(req.Context()) {
+		return t.base.RoundTrip(req)
+	}
+	_, s := t.tracer.Start(req.Context(), "HTTP "+req.Method+" "+req.URL.Host, KindClient)
+	defer s.End()
+	s.SetAttribute("http.method", req.Method)
+	s.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		s.SetError(err)
+		return nil, err
+	}
+	s.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.SetError(spanError(resp.Status))
+	}
+	return resp, nil
+}
@@ -0,0 +1,200 @@
+// Package tracing records spans of the HTTP server, the database, Redis and the site requests, and exports them to
+// an OpenTelemetry collector with OTLP over HTTP in its JSON encoding. Trace contexts are propagated between services
+// with the W3C traceparent header.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the OpenTelemetry span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Tracer records spans and exports the sampled ones. A nil Tracer records nothing, its spans only carry trace IDs
+// for logs and propagation.
+type Tracer struct {
+	exporter    *exporter
+	sampleRatio float64
+}
+
+type logger interface {
+	Errorf(format string, v ...any)
+}
+
+// New returns a Tracer exporting spans of serviceName to the OTLP/HTTP endpoint, e.g. http://localhost:4318.
+// sampleRatio is the share of traces that are exported, decided from the trace ID for traces started by the Tracer and
+// for traces continued from a traceparent header alike, as the sampled flag of a remote parent is not trusted.
+// Spans follow the sampling decision of their local parent. Close must be called to export the last spans.
+func New(endpoint string, serviceName string, sampleRatio float64, l logger) *Tracer {
+	return &Tracer{
+		exporter:    newExporter(strings.TrimSuffix(endpoint, "/")+"/v1/traces", serviceName, l),
+		sampleRatio: sampleRatio,
+	}
+}
+
+// Close exports the spans not yet exported, waiting until ctx is done at most.
+func (t *Tracer) Close(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.exporter.close(ctx)
+}
+
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+	// remote is set for the parent span of another service, extracted from a traceparent header.
+	remote bool
+}
+
+type spanContextKey struct{}
+
+// Span is an operation of a trace, it is exported when it ends. Span methods can be called on a nil Span.
+type Span struct {
+	tracer   *Tracer
+	sc       spanContext
+	parentID [8]byte
+	name     string
+	kind     Kind
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	err   string
+	ended bool
+}
+
+// Start starts a span named name as a child of the span of ctx, or of a new trace when ctx has none, and returns
+// ctx with the started span.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		s.sc.traceID = parent.traceID
+		s.sc.sampled = parent.sampled
+		if parent.remote {
+			s.sc.sampled = t.sampled(parent.traceID)
+		}
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.sc.traceID[:])
+		s.sc.sampled = t.sampled(s.sc.traceID)
+	}
+	_, _ = rand.Read(s.sc.spanID[:])
+	return context.WithValue(ctx, spanContextKey{}, s.sc), s
+}
+
+// sampled reports whether the trace with traceID is exported, for sampleRatio of the trace IDs like the
+// OpenTelemetry TraceIDRatioBased sampler so every service sampling the same ratio makes the same decision.
+func (t *Tracer) sampled(traceID [16]byte) bool {
+	if t == nil {
+		return false
+	}
+	bound := uint64(t.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+}
+
+// HasSpan reports whether ctx has a span, spans of clients are only recorded within the span of another operation.
+func HasSpan(ctx context.Context) bool {
+	_, ok := ctx.Value(spanContextKey{}).(spanContext)
+	return ok
+}
+
+// TraceID returns the hex encoded trace ID of the span of ctx, empty when it has none.
+func TraceID(ctx context.Context) string {
+	sc, ok := ctx.Value(spanContextKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	return hex.EncodeToString(sc.traceID[:])
+}
+
+// Extract returns ctx with the remote parent span of the traceparent header of h, ctx itself when it has no valid one.
+// The trace ID of the header is continued, so it should only be extracted from trusted callers.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	// traceparent: version-traceid-parentid-flags, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	parts := strings.Split(h.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 ||
+		len(parts[3]) != 2 {
+		return ctx
+	}
+	var sc spanContext
+	var flags [1]byte
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == [16]byte{} {
+		return ctx
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == [8]byte{} {
+		return ctx
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return ctx
+	}
+	sc.sampled = flags[0]&1 == 1
+	sc.remote = true
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// Inject sets the traceparent header of h to the span of ctx.
+func Inject(ctx context.Context, h http.Header) {
+	sc, ok := ctx.Value(spanContextKey{}).(spanContext)
+	if !ok {
+		return
+	}
+	flags := "00"
+	if sc.sampled {
+		flags = "01"
+	}
+	h.Set("traceparent", fmt.Sprintf("00-%x-%x-%s", sc.traceID, sc.spanID, flags))
+}
+
+// SetAttribute sets the attribute key of s to v, which is a string, bool, int, int64 or float64.
+func (s *Span) SetAttribute(key string, v any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = v
+}
+
+// SetError marks s as failed with err, nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends s and queues it for export when its trace is sampled, only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	ended := s.ended
+	s.ended = true
+	s.mu.Unlock()
+	if ended || s.tracer == nil || !s.sc.sampled {
+		return
+	}
+	s.tracer.exporter.export(s, time.Now())
+}